@@ -11,11 +11,25 @@ import (
 	"time"
 
 	"github.com/eventpulse/backend/config"
+	"github.com/eventpulse/backend/internal/analitica"
+	"github.com/eventpulse/backend/internal/anuncios"
 	"github.com/eventpulse/backend/internal/auth"
+	"github.com/eventpulse/backend/internal/carga"
 	"github.com/eventpulse/backend/internal/db"
+	"github.com/eventpulse/backend/internal/eventos"
 	"github.com/eventpulse/backend/internal/handlers"
+	"github.com/eventpulse/backend/internal/iot"
+	"github.com/eventpulse/backend/internal/metricas"
 	"github.com/eventpulse/backend/internal/middleware"
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/eventpulse/backend/internal/notificaciones"
+	"github.com/eventpulse/backend/internal/ocupacion"
+	"github.com/eventpulse/backend/internal/patrullas"
+	"github.com/eventpulse/backend/internal/puente"
+	"github.com/eventpulse/backend/internal/reportes"
 	"github.com/eventpulse/backend/internal/repository"
+	"github.com/eventpulse/backend/internal/ubicaciones"
+	"github.com/eventpulse/backend/internal/webhooks"
 	"github.com/eventpulse/backend/internal/ws"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -39,34 +53,178 @@ func main() {
 	incidenciaRepo := repository.NewIncidenciaRepo(postgres)
 	tareaRepo := repository.NewTareaRepo(postgres)
 	mensajeRepo := repository.NewMensajeRepo(postgres)
+	conversacionRepo := repository.NewConversacionRepo(postgres)
+	moderacionRepo := repository.NewModeracionRepo(postgres)
+	anuncioRepo := repository.NewAnuncioRepo(postgres)
+	webhookRepo := repository.NewWebhookRepo(postgres)
+	notificacionRepo := repository.NewNotificacionRepo(postgres)
+	dispositivoRepo := repository.NewDispositivoRepo(postgres)
+	sosRepo := repository.NewSOSRepo(postgres)
+	ubicacionRepo := repository.NewUbicacionRepo(postgres)
+	patrullaRepo := repository.NewPatrullaRepo(postgres)
+	plantillaRepo := repository.NewPlantillaRepo(postgres)
+	cierreRepo := repository.NewCierreRepo(postgres)
+	reporteRepo := repository.NewReporteRepo(postgres)
+	metricaRepo := repository.NewMetricaRepo(postgres)
+	analiticaRepo := repository.NewAnaliticaRepo(postgres)
+	cargaRepo := repository.NewCargaRepo(postgres)
 
 	// ── Servicios ─────────────────────────────────────────────────────────────
-	jwtSvc := auth.NewJWTService(cfg)
+	jwtSvc := auth.NewJWTService(cfg, redisClient)
+
+	// Notificaciones fuera de la app: solo los canales configurados
+	var canales []notificaciones.Canal
+	var webPush *notificaciones.WebPush
+	nc := cfg.Notificaciones
+	if nc.VAPIDPrivada != "" {
+		wp, err := notificaciones.NewWebPush(notificacionRepo, nc.VAPIDPrivada, nc.VAPIDSubject)
+		if err != nil {
+			log.Fatalf("Web Push: %v", err)
+		}
+		webPush = wp
+		canales = append(canales, wp)
+	}
+	if nc.SMTPHost != "" {
+		canales = append(canales, notificaciones.NewEmail(nc.SMTPHost, nc.SMTPPort, nc.SMTPUser, nc.SMTPPassword, nc.SMTPFrom))
+	}
+	if nc.SMSGatewayURL != "" {
+		canales = append(canales, notificaciones.NewSMS(nc.SMSGatewayURL, nc.SMSGatewayToken))
+	}
+	notificador := notificaciones.NewNotificador(notificacionRepo, canales...)
 
 	// ── WebSocket Hub ─────────────────────────────────────────────────────────
 	hub := ws.NewHub(redisClient, cfg)
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	go hub.Run(ctx)
+
+	// Webhooks salientes: se alimentan de lo que se publica a todo el evento
+	despachador := webhooks.NewDespachador(webhookRepo, redisClient, cfg.Webhooks)
+	hub.AlPublicar(despachador.Encolar)
+
+	// Lecturas de sensores: mismo camino por HTTP y por MQTT
+	procesadorIoT := iot.NewProcesador(dispositivoRepo, incidenciaRepo, eventoRepo, hub)
+
+	// Puente MQTT opcional: entrada de sensores y espejo de los eventos WS
+	var puenteMQTT *puente.MQTT
+	if cfg.MQTT.BrokerURL != "" {
+		puenteMQTT = puente.NewMQTT(cfg.MQTT, dispositivoRepo, procesadorIoT)
+		hub.AlPublicar(puenteMQTT.Republicar)
+	}
+
+	// Ubicación del staff: en vivo en Redis, historial muestreado en Postgres
+	rastreador := ubicaciones.NewRastreador(ubicacionRepo, zonaRepo, redisClient, hub, cfg.Ubicaciones)
+
+	// QR firmados de zona y rondas de vigilancia
+	firmadorQR := patrullas.NewFirmador(cfg.Patrullas.SecretoQR, cfg.Patrullas.AceptarIDPlano)
+	vigilante := patrullas.NewVigilante(patrullaRepo, zonaRepo, ubicacionRepo, rastreador, firmadorQR, hub, notificador,
+		time.Duration(cfg.Patrullas.RevisionSegundos)*time.Second)
+
+	// Aforo: conteo atómico en Redis e incidencia al cruzar umbrales
+	contador := ocupacion.NewContador(zonaRepo, incidenciaRepo, redisClient, hub, cfg.Ocupacion)
+
+	// Ciclo de vida de los eventos: inicio y fin programados, cierre guiado,
+	// reapertura con gracia
+	revisionEventos := time.Duration(cfg.Eventos.RevisionSegundos) * time.Second
+	cerrador := eventos.NewCerrador(cierreRepo, eventoRepo, jwtSvc, hub, revisionEventos)
+	ciclo := eventos.NewCiclo(eventoRepo, patrullaRepo, hub, cerrador,
+		time.Duration(cfg.Eventos.ReaperturaHoras)*time.Hour, revisionEventos)
+
+	// Reportes PDF/CSV del evento, generados en segundo plano
+	generador := reportes.NewGenerador(reporteRepo,
+		time.Duration(cfg.Reportes.RevisionSegundos)*time.Second, cfg.Reportes.RetencionDias)
+
+	// Carga del personal: quién está libre o sobrecargado, y a quién asignar
+	medidor := carga.NewMedidor(cargaRepo, cfg.Carga.UmbralSobrecarga)
+
+	// Tablero de operación: agregados en Postgres, ubicaciones y aforo en Redis
+	tablero := metricas.NewTablero(metricaRepo, eventoRepo, ubicacionRepo, rastreador, contador, medidor, redisClient, hub,
+		time.Duration(cfg.Metricas.Segundos)*time.Second)
 
 	// ── Handlers ──────────────────────────────────────────────────────────────
-	authH := handlers.NewAuthHandler(usuarioRepo, eventoRepo, jwtSvc)
-	eventoH := handlers.NewEventoHandler(eventoRepo, usuarioRepo, hub)
+	authH := handlers.NewAuthHandler(usuarioRepo, eventoRepo, jwtSvc, conversacionRepo)
+	eventoH := handlers.NewEventoHandler(eventoRepo, usuarioRepo, cierreRepo, hub, ciclo, cerrador)
 	usuarioH := handlers.NewUsuarioHandler(usuarioRepo, eventoRepo)
-	zonaH := handlers.NewZonaHandler(zonaRepo, eventoRepo)
-	incidenciaH := handlers.NewIncidenciaHandler(incidenciaRepo, eventoRepo, hub)
-	tareaH := handlers.NewTareaHandler(tareaRepo, eventoRepo, hub)
-	chatH := handlers.NewChatHandler(mensajeRepo, eventoRepo, hub)
+	zonaH := handlers.NewZonaHandler(zonaRepo, eventoRepo, hub)
+	incidenciaH := handlers.NewIncidenciaHandler(incidenciaRepo, eventoRepo, hub, notificador)
+	tareaH := handlers.NewTareaHandler(tareaRepo, eventoRepo, zonaRepo, hub, notificador, firmadorQR)
+	chatH := handlers.NewChatHandler(mensajeRepo, conversacionRepo, moderacionRepo, usuarioRepo, eventoRepo, hub)
+	anuncioH := handlers.NewAnuncioHandler(anuncioRepo, eventoRepo, hub, notificador, cfg.Anuncios.ReenvioSegundos)
+	notificacionH := handlers.NewNotificacionHandler(notificacionRepo, notificador, webPush)
+	webhookH := handlers.NewWebhookHandler(webhookRepo, eventoRepo, despachador)
+	dispositivoH := handlers.NewDispositivoHandler(dispositivoRepo, eventoRepo, procesadorIoT)
+	sosH := handlers.NewSOSHandler(sosRepo, incidenciaRepo, eventoRepo, hub, notificador)
+	ubicacionH := handlers.NewUbicacionHandler(ubicacionRepo, eventoRepo, rastreador)
+	patrullaH := handlers.NewPatrullaHandler(patrullaRepo, zonaRepo, eventoRepo, vigilante, firmadorQR)
+	plantillaH := handlers.NewPlantillaHandler(plantillaRepo, eventoRepo, hub)
+	metricaH := handlers.NewMetricaHandler(tablero, eventoRepo)
+	reporteH := handlers.NewReporteHandler(reporteRepo, eventoRepo, generador)
+	analiticaH := handlers.NewAnaliticaHandler(analiticaRepo)
+	cargaH := handlers.NewCargaHandler(medidor, cargaRepo, eventoRepo)
+	ocupacionH := handlers.NewOcupacionHandler(contador, eventoRepo, dispositivoRepo)
 	wsH := handlers.NewWSHandler(hub, jwtSvc, eventoRepo)
 
+	// Comandos efímeros que los clientes envían por el socket
+	hub.RegistrarComando(models.WSEscribiendo, chatH.Escribiendo)
+	hub.RegistrarComando(models.WSSOS, sosH.ComandoSOS)
+	hub.RegistrarComando(models.WSSOSUbicacion, sosH.ComandoUbicacion)
+	hub.RegistrarComando(models.WSUbicacion, ubicacionH.ComandoUbicacion)
+
+	// Al resolverse una incidencia SOS se cierra el canal de ubicación
+	hub.AlPublicar(sosH.AlPublicar)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go hub.Run(ctx)
+
+	// Reenvío de anuncios a quien no confirmó
+	repetidor := anuncios.NewRepetidor(anuncioRepo, hub, notificador, time.Duration(cfg.Anuncios.RevisionSegundos)*time.Second)
+	go repetidor.Run(ctx)
+
+	// Entregas de webhooks con reintentos
+	go despachador.Run(ctx)
+
+	if puenteMQTT != nil {
+		go puenteMQTT.Run(ctx)
+	}
+
+	// Cierre de turnos olvidados y purga del historial de ubicaciones
+	go rastreador.Run(ctx)
+
+	// Avisos de puntos de ronda no cubiertos a tiempo
+	go vigilante.Run(ctx)
+
+	// Inicia y termina los eventos según su programa
+	go ciclo.Run(ctx)
+
+	// Cierres de evento pendientes (reintentos y los de una instancia caída)
+	go cerrador.Run(ctx)
+
+	// Reportes pendientes o abandonados, y purga de los vencidos
+	go generador.Run(ctx)
+
+	// Métricas del tablero que cambiaron, a admins y supervisores
+	if cfg.Metricas.Segundos > 0 {
+		go tablero.Run(ctx)
+	}
+
+	// Vista de incidencias por hora de la analítica entre eventos
+	if cfg.Analitica.RefrescoMinutos > 0 {
+		refrescador := analitica.NewRefrescador(analiticaRepo, time.Duration(cfg.Analitica.RefrescoMinutos)*time.Minute)
+		go refrescador.Run(ctx)
+	}
+
+	// Escalamiento de incidencias que nadie atiende
+	if nc.EscalarMinutos > 0 {
+		escalador := notificaciones.NewEscalador(notificacionRepo, notificador, time.Duration(nc.EscalarMinutos)*time.Minute)
+		go escalador.Run(ctx)
+	}
+
 	// ── Router ────────────────────────────────────────────────────────────────
 	r := gin.New()
 	r.Use(gin.Logger(), gin.Recovery())
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:  []string{"*"},
 		AllowMethods:  []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:  []string{"Origin", "Content-Type", "Authorization"},
-		ExposeHeaders: []string{"Content-Length"},
+		AllowHeaders:  []string{"Origin", "Content-Type", "Authorization", "If-Match"},
+		ExposeHeaders: []string{"Content-Length", "ETag"},
 		MaxAge:        12 * time.Hour,
 	}))
 
@@ -84,6 +242,10 @@ func main() {
 	// ── Rutas públicas ────────────────────────────────────────────────────────
 	api.POST("/auth/login", authH.Login)
 
+	// Lecturas de sensores — auth por token del dispositivo, no JWT
+	api.POST("/iot/lecturas", dispositivoH.Lectura)
+	api.POST("/iot/ocupacion", ocupacionH.ContarDispositivo)
+
 	// ── Rutas protegidas (cualquier usuario autenticado) ──────────────────────
 	auth := api.Group("")
 	auth.Use(middleware.Auth(jwtSvc))
@@ -96,20 +258,116 @@ func main() {
 
 		// Zonas
 		auth.GET("/zonas", zonaH.Listar)
+		auth.GET("/zonas/arbol", zonaH.Arbol)
+		auth.GET("/zonas/geojson", zonaH.GeoJSON)
+		auth.GET("/zonas/cercana", zonaH.Cercana)
 
 		// Incidencias — todos pueden ver y editar estado
 		auth.GET("/incidencias", incidenciaH.Listar)
 		auth.GET("/incidencias/:id", incidenciaH.ObtenerPorID)
 		auth.PATCH("/incidencias/:id", incidenciaH.Editar)
+		auth.PATCH("/incidencias/:id/atender", incidenciaH.Atender)
 
 		// Tareas — todos pueden ver y editar estado
 		auth.GET("/tareas", tareaH.Listar)
 		auth.GET("/tareas/:id", tareaH.ObtenerPorID)
 		auth.PATCH("/tareas/:id", tareaH.Editar)
+		auth.PATCH("/tareas/:id/tomar", tareaH.Tomar)
+		auth.POST("/tareas/:id/evidencias", tareaH.SubirEvidencia)
+		auth.GET("/tareas/:id/evidencias", tareaH.ListarEvidencias)
+		auth.GET("/tareas/:id/evidencias/:evidenciaId/foto", tareaH.FotoEvidencia)
 
 		// Chat — admin y trabajadores
 		auth.GET("/chat/historial", chatH.Historial)
 		auth.POST("/chat/mensaje", chatH.Enviar)
+		auth.GET("/chat/conversaciones", chatH.ListarConversaciones)
+		auth.POST("/chat/conversaciones", chatH.CrearConversacion)
+		auth.POST("/chat/directos", chatH.AbrirDirecto)
+		auth.POST("/chat/conversaciones/:id/miembros", chatH.AgregarMiembros)
+		auth.GET("/chat/conversaciones/:id/mensajes", chatH.HistorialConversacion)
+		auth.POST("/chat/conversaciones/:id/mensajes", chatH.EnviarAConversacion)
+		auth.POST("/chat/conversaciones/:id/leido", chatH.MarcarLeido)
+		auth.GET("/chat/conversaciones/:id/mensajes/:mensajeId/lecturas", chatH.Lecturas)
+		auth.GET("/chat/no-leidos", chatH.NoLeidos)
+		auth.PATCH("/chat/mensajes/:id", chatH.EditarMensaje)
+		auth.DELETE("/chat/mensajes/:id", chatH.EliminarMensaje)
+		auth.GET("/chat/mensajes/:id/revisiones", chatH.Revisiones)
+
+		// Notificaciones fuera de la app (email, SMS, Web Push)
+		auth.GET("/notificaciones", notificacionH.Listar)
+		auth.GET("/notificaciones/preferencias", notificacionH.Preferencias)
+		auth.PUT("/notificaciones/preferencias", notificacionH.GuardarPreferencias)
+		auth.GET("/notificaciones/push/clave", notificacionH.ClavePush)
+		auth.POST("/notificaciones/push", notificacionH.SuscribirPush)
+		auth.DELETE("/notificaciones/push", notificacionH.DesuscribirPush)
+
+		// Anuncios — todos ven los suyos y confirman
+		auth.GET("/anuncios", anuncioH.Listar)
+		auth.POST("/anuncios/:id/confirmar", anuncioH.Confirmar)
+
+		// SOS — cualquiera lo activa; la ubicación la manda quien lo activó
+		auth.POST("/sos", sosH.Activar)
+		auth.GET("/sos/activo", sosH.MiSOS)
+		auth.POST("/sos/:id/ubicacion", sosH.Ubicacion)
+		auth.GET("/sos/:id/ubicaciones", sosH.Ubicaciones)
+
+		// Ubicación del staff — solo dentro de un turno abierto
+		auth.POST("/ubicaciones/turno", ubicacionH.IniciarTurno)
+		auth.GET("/ubicaciones/turno", ubicacionH.MiTurno)
+		auth.DELETE("/ubicaciones/turno", ubicacionH.TerminarTurno)
+		auth.POST("/ubicaciones", ubicacionH.Reportar)
+		auth.GET("/ubicaciones/usuarios/:usuarioId/rastro", ubicacionH.Rastro)
+
+		// Patrullas — check-in con el QR de la zona y rondas propias
+		auth.POST("/checkins", patrullaH.Checkin)
+		auth.GET("/checkins", patrullaH.Checkins)
+		auth.GET("/patrullas/rutas", patrullaH.Rutas)
+		auth.POST("/patrullas/rondas", patrullaH.IniciarRonda)
+		auth.GET("/patrullas/rondas/actual", patrullaH.MiRonda)
+		auth.DELETE("/patrullas/rondas/actual", patrullaH.CancelarRonda)
+		auth.GET("/patrullas/rondas/:id", patrullaH.Ronda)
+
+		// Aforo — contadores manuales de entradas y salidas
+		auth.GET("/zonas/ocupacion", ocupacionH.Listar)
+		auth.POST("/zonas/:id/ocupacion", ocupacionH.Contar)
+	}
+
+	// ── Rutas de quienes atienden un SOS ──────────────────────────────────────
+	respuesta := api.Group("")
+	respuesta.Use(middleware.Auth(jwtSvc), middleware.SoloRoles(models.RolAdmin, models.RolSupervisor, models.RolGuardia, models.RolMedico))
+	{
+		respuesta.GET("/sos", sosH.Listar)
+	}
+
+	// ── Rutas admin o supervisor ──────────────────────────────────────────────
+	mando := api.Group("")
+	mando.Use(middleware.Auth(jwtSvc), middleware.SoloRoles(models.RolAdmin, models.RolSupervisor))
+	{
+		// Anuncios prioritarios con confirmación
+		mando.POST("/anuncios", anuncioH.Crear)
+		mando.GET("/anuncios/:id/estado", anuncioH.Estado)
+		mando.PATCH("/anuncios/:id/cerrar", anuncioH.Cerrar)
+
+		// Mapa en vivo del staff
+		mando.GET("/ubicaciones", ubicacionH.EnVivo)
+		mando.DELETE("/ubicaciones/turnos/:usuarioId", ubicacionH.TerminarTurnoDe)
+
+		// Tablero de operación
+		mando.GET("/eventos/:id/metricas", metricaH.Obtener)
+
+		// Carga y desempeño del personal
+		mando.GET("/eventos/:id/carga", cargaH.EnVivo)
+		mando.GET("/eventos/:id/desempeno", cargaH.Desempeno)
+
+		// QR imprimibles y rutas de patrulla
+		mando.GET("/zonas/qr", patrullaH.HojaQR)
+		mando.GET("/zonas/:id/qr", patrullaH.QR)
+		mando.POST("/patrullas/rutas", patrullaH.CrearRuta)
+		mando.DELETE("/patrullas/rutas/:id", patrullaH.DesactivarRuta)
+		mando.GET("/patrullas/rondas", patrullaH.Rondas)
+
+		// Recuento de aforo
+		mando.PUT("/zonas/:id/ocupacion", ocupacionH.Ajustar)
 	}
 
 	// ── Rutas solo admin ──────────────────────────────────────────────────────
@@ -118,7 +376,25 @@ func main() {
 	{
 		// Gestión de eventos
 		admin.POST("/eventos", eventoH.Crear)
+		admin.PATCH("/eventos/:id", eventoH.Editar)
 		admin.PATCH("/eventos/:id/terminar", eventoH.Terminar)
+		admin.POST("/eventos/:id/estado", eventoH.CambiarEstado)
+		admin.GET("/eventos/:id/transiciones", eventoH.Transiciones)
+		admin.GET("/eventos/:id/cierre", eventoH.Pendientes)
+		admin.POST("/eventos/:id/cierre", eventoH.Cerrar)
+		admin.POST("/eventos/:id/reportes", reporteH.Solicitar)
+		admin.GET("/eventos/:id/reportes", reporteH.Listar)
+		admin.GET("/reportes/:id", reporteH.Obtener)
+		admin.GET("/reportes/:id/archivo", reporteH.Descargar)
+		admin.GET("/analitica/incidencias/zonas-horas", analiticaH.ZonasHoras)
+		admin.GET("/analitica/incidencias/tipos", analiticaH.Tipos)
+		admin.GET("/analitica/incidencias/dias", analiticaH.Dias)
+		admin.POST("/eventos/instanciar", plantillaH.Instanciar)
+		admin.POST("/plantillas", plantillaH.Crear)
+		admin.GET("/plantillas", plantillaH.Listar)
+		admin.GET("/plantillas/:id", plantillaH.Obtener)
+		admin.DELETE("/plantillas/:id", plantillaH.Eliminar)
+		admin.POST("/eventos/:id/zonas/clonar", zonaH.Clonar)
 
 		// Gestión de usuarios (crear staff)
 		admin.POST("/usuarios", usuarioH.Crear)
@@ -126,7 +402,34 @@ func main() {
 
 		// Gestión de zonas
 		admin.POST("/zonas", zonaH.Crear)
+		admin.POST("/zonas/importar", zonaH.Importar)
+		admin.PATCH("/zonas/:id", zonaH.Editar)
 		admin.DELETE("/zonas/:id", zonaH.Eliminar)
+		admin.POST("/zonas/:id/archivar", zonaH.Archivar)
+		admin.POST("/zonas/:id/restaurar", zonaH.Restaurar)
+
+		// Moderación del chat
+		admin.POST("/chat/silencios", chatH.Silenciar)
+		admin.GET("/chat/silencios", chatH.ListarSilencios)
+		admin.DELETE("/chat/silencios/:usuarioId", chatH.Reactivar)
+		admin.GET("/chat/filtros", chatH.ListarFiltros)
+		admin.POST("/chat/filtros", chatH.CrearFiltro)
+		admin.DELETE("/chat/filtros/:id", chatH.EliminarFiltro)
+
+		// Webhooks salientes
+		admin.POST("/webhooks", webhookH.Crear)
+		admin.GET("/webhooks", webhookH.Listar)
+		admin.PATCH("/webhooks/:id", webhookH.Editar)
+		admin.DELETE("/webhooks/:id", webhookH.Eliminar)
+		admin.GET("/webhooks/:id/entregas", webhookH.Entregas)
+		admin.POST("/webhooks/entregas/:id/reenviar", webhookH.Reenviar)
+
+		// Sensores IoT que crean incidencias
+		admin.POST("/dispositivos", dispositivoH.Crear)
+		admin.GET("/dispositivos", dispositivoH.Listar)
+		admin.PATCH("/dispositivos/:id", dispositivoH.Editar)
+		admin.DELETE("/dispositivos/:id", dispositivoH.Eliminar)
+		admin.POST("/dispositivos/:id/token", dispositivoH.RotarToken)
 
 		// Crear incidencias y tareas (solo admin)
 		admin.POST("/incidencias", incidenciaH.Crear)
@@ -1,12 +1,17 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
+	"golang.org/x/crypto/hkdf"
 )
 
 type Config struct {
@@ -16,6 +21,30 @@ type Config struct {
 	Redis RedisConfig
 	JWT   JWTConfig
 	WS    WSConfig
+	// Anuncios con confirmación obligatoria
+	Anuncios AnunciosConfig
+	// Webhooks salientes
+	Webhooks WebhooksConfig
+	// Notificaciones fuera de la app; cada canal se activa si está configurado
+	Notificaciones NotificacionesConfig
+	// Puente MQTT opcional para sensores y gateways de radio
+	MQTT MQTTConfig
+	// Rastreo de ubicación del staff
+	Ubicaciones UbicacionesConfig
+	// QR de zonas y rondas de vigilancia
+	Patrullas PatrullasConfig
+	// Aforo y conteo de personas por zona
+	Ocupacion OcupacionConfig
+	// Programador de inicio y fin de eventos
+	Eventos EventosConfig
+	// Reportes PDF/CSV generados en segundo plano
+	Reportes ReportesConfig
+	// Tablero de operación en vivo
+	Metricas MetricasConfig
+	// Analítica de incidencias entre eventos
+	Analitica AnaliticaConfig
+	// Carga de trabajo del personal
+	Carga CargaConfig
 }
 
 type DBConfig struct {
@@ -43,6 +72,79 @@ type WSConfig struct {
 	PongWait       int
 }
 
+type AnunciosConfig struct {
+	ReenvioSegundos  int // intervalo por defecto entre reenvíos a quien no confirmó
+	RevisionSegundos int // cada cuánto se buscan reenvíos vencidos
+}
+
+type WebhooksConfig struct {
+	MaxIntentos     int // intentos antes de pasar la entrega a muerta
+	BackoffSegundos int // espera del primer reintento; se duplica en cada fallo
+	TimeoutSegundos int // timeout de cada POST al destino
+}
+
+type NotificacionesConfig struct {
+	SMTPHost        string // vacío = sin canal email
+	SMTPPort        string
+	SMTPUser        string
+	SMTPPassword    string
+	SMTPFrom        string
+	SMSGatewayURL   string // vacío = sin canal SMS
+	SMSGatewayToken string
+	VAPIDPrivada    string // vacío = sin canal push
+	VAPIDSubject    string
+	EscalarMinutos  int // 0 = no escalar incidencias pendientes
+}
+
+type MQTTConfig struct {
+	BrokerURL string // vacío = sin puente MQTT; ej: tcp://mosquitto:1883
+	ClientID  string // único por instancia
+	User      string
+	Password  string
+	Prefijo   string   // raíz de los temas republicados: <prefijo>/<evento>/<tipo>
+	Temas     []string // filtros de entrada a suscribir
+}
+
+type UbicacionesConfig struct {
+	TTLSegundos      int // sin reporte en este tiempo el usuario sale del mapa en vivo
+	MuestreoSegundos int // mínimo entre puntos guardados en el historial (salvo cambio de zona)
+	TurnoMaxHoras    int // turno abierto más tiempo se cierra solo (0 = sin límite)
+	RetencionDias    int // historial más viejo se borra (0 = no borrar)
+}
+
+type PatrullasConfig struct {
+	SecretoQR        string // firma los QR de zona; sin QR_SECRETO se deriva de JWT_SECRET
+	AceptarIDPlano   bool   // acepta QR viejos con solo el ID de la zona como evidencia de tareas
+	RevisionSegundos int    // cada cuánto se buscan puntos de ronda vencidos
+}
+
+type OcupacionConfig struct {
+	UmbralPct     int // % de la capacidad que dispara la alerta si la zona no define el suyo
+	HisteresisPct int // hay que bajar este % bajo un umbral para volver a alertar al cruzarlo
+}
+
+type EventosConfig struct {
+	ReaperturaHoras  int // plazo para reabrir un evento terminado (0 = no se reabre)
+	RevisionSegundos int // cada cuánto se buscan eventos por iniciar o terminar
+}
+
+type ReportesConfig struct {
+	RevisionSegundos int // cada cuánto se buscan reportes pendientes o abandonados
+	RetencionDias    int // los reportes más viejos se borran (0 = nunca)
+}
+
+type MetricasConfig struct {
+	Segundos int // cada cuánto se envían por WS las métricas que cambiaron (0 = no se envían)
+}
+
+type AnaliticaConfig struct {
+	RefrescoMinutos int // cada cuánto se refresca la vista de incidencias por hora (0 = nunca)
+}
+
+type CargaConfig struct {
+	UmbralSobrecarga int // incidencias + tareas abiertas desde las que alguien está sobrecargado
+}
+
 func (d DBConfig) DSN() string {
 	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
 		d.Host, d.Port, d.User, d.Password, d.Name, d.SSLMode)
@@ -56,6 +158,28 @@ func Load() *Config {
 	jwtExp, _ := strconv.Atoi(getEnv("JWT_EXPIRATION_HOURS", "24"))
 	wsMaxMsg, _ := strconv.ParseInt(getEnv("WS_MAX_MESSAGE_SIZE", "2048"), 10, 64)
 	wsPong, _ := strconv.Atoi(getEnv("WS_PONG_WAIT_SECONDS", "60"))
+	anuncioReenvio, _ := strconv.Atoi(getEnv("ANUNCIO_REENVIO_SEGUNDOS", "60"))
+	anuncioRevision, _ := strconv.Atoi(getEnv("ANUNCIO_REVISION_SEGUNDOS", "10"))
+	webhookIntentos, _ := strconv.Atoi(getEnv("WEBHOOK_MAX_INTENTOS", "8"))
+	webhookBackoff, _ := strconv.Atoi(getEnv("WEBHOOK_BACKOFF_SEGUNDOS", "10"))
+	webhookTimeout, _ := strconv.Atoi(getEnv("WEBHOOK_TIMEOUT_SEGUNDOS", "10"))
+	escalarMin, _ := strconv.Atoi(getEnv("NOTIF_ESCALAR_MINUTOS", "10"))
+	ubicTTL, _ := strconv.Atoi(getEnv("UBICACION_TTL_SEGUNDOS", "120"))
+	ubicMuestreo, _ := strconv.Atoi(getEnv("UBICACION_MUESTREO_SEGUNDOS", "60"))
+	ubicTurno, _ := strconv.Atoi(getEnv("UBICACION_TURNO_MAX_HORAS", "16"))
+	ubicRetencion, _ := strconv.Atoi(getEnv("UBICACION_RETENCION_DIAS", "30"))
+	rondaRevision, _ := strconv.Atoi(getEnv("RONDA_REVISION_SEGUNDOS", "30"))
+	ocupacionUmbral, _ := strconv.Atoi(getEnv("OCUPACION_UMBRAL_PCT", "90"))
+	ocupacionHisteresis, _ := strconv.Atoi(getEnv("OCUPACION_HISTERESIS_PCT", "5"))
+	eventoReapertura, _ := strconv.Atoi(getEnv("EVENTO_REAPERTURA_HORAS", "24"))
+	eventoRevision, _ := strconv.Atoi(getEnv("EVENTO_REVISION_SEGUNDOS", "30"))
+	reporteRevision, _ := strconv.Atoi(getEnv("REPORTE_REVISION_SEGUNDOS", "30"))
+	reporteRetencion, _ := strconv.Atoi(getEnv("REPORTE_RETENCION_DIAS", "30"))
+	metricasSegundos, _ := strconv.Atoi(getEnv("METRICAS_SEGUNDOS", "5"))
+	analiticaRefresco, _ := strconv.Atoi(getEnv("ANALITICA_REFRESCO_MINUTOS", "15"))
+	cargaUmbral, _ := strconv.Atoi(getEnv("CARGA_UMBRAL_SOBRECARGA", "4"))
+	hostname, _ := os.Hostname()
+	jwtSecret := getEnv("JWT_SECRET", "")
 
 	return &Config{
 		Port: getEnv("PORT", "8080"),
@@ -74,13 +198,74 @@ func Load() *Config {
 			DB:       redisDB,
 		},
 		JWT: JWTConfig{
-			Secret:          getEnv("JWT_SECRET", ""),
+			Secret:          jwtSecret,
 			ExpirationHours: jwtExp,
 		},
 		WS: WSConfig{
 			MaxMessageSize: wsMaxMsg,
 			PongWait:       wsPong,
 		},
+		Anuncios: AnunciosConfig{
+			ReenvioSegundos:  anuncioReenvio,
+			RevisionSegundos: anuncioRevision,
+		},
+		Webhooks: WebhooksConfig{
+			MaxIntentos:     webhookIntentos,
+			BackoffSegundos: webhookBackoff,
+			TimeoutSegundos: webhookTimeout,
+		},
+		Notificaciones: NotificacionesConfig{
+			SMTPHost:        os.Getenv("SMTP_HOST"),
+			SMTPPort:        getEnv("SMTP_PORT", "587"),
+			SMTPUser:        os.Getenv("SMTP_USER"),
+			SMTPPassword:    os.Getenv("SMTP_PASSWORD"),
+			SMTPFrom:        getEnv("SMTP_FROM", "noreply@eventpulse.app"),
+			SMSGatewayURL:   os.Getenv("SMS_GATEWAY_URL"),
+			SMSGatewayToken: os.Getenv("SMS_GATEWAY_TOKEN"),
+			VAPIDPrivada:    os.Getenv("VAPID_PRIVATE_KEY"),
+			VAPIDSubject:    getEnv("VAPID_SUBJECT", "mailto:soporte@eventpulse.app"),
+			EscalarMinutos:  escalarMin,
+		},
+		MQTT: MQTTConfig{
+			BrokerURL: os.Getenv("MQTT_BROKER_URL"),
+			ClientID:  getEnv("MQTT_CLIENT_ID", "eventpulse-"+hostname),
+			User:      os.Getenv("MQTT_USER"),
+			Password:  os.Getenv("MQTT_PASSWORD"),
+			Prefijo:   getEnv("MQTT_PREFIJO", "eventpulse"),
+			Temas:     lista(getEnv("MQTT_TEMAS", "eventpulse/entrada/#")),
+		},
+		Ubicaciones: UbicacionesConfig{
+			TTLSegundos:      ubicTTL,
+			MuestreoSegundos: ubicMuestreo,
+			TurnoMaxHoras:    ubicTurno,
+			RetencionDias:    ubicRetencion,
+		},
+		Patrullas: PatrullasConfig{
+			SecretoQR:        secretoQR(os.Getenv("QR_SECRETO"), jwtSecret),
+			AceptarIDPlano:   os.Getenv("QR_ACEPTAR_ID_PLANO") == "true",
+			RevisionSegundos: rondaRevision,
+		},
+		Ocupacion: OcupacionConfig{
+			UmbralPct:     ocupacionUmbral,
+			HisteresisPct: ocupacionHisteresis,
+		},
+		Eventos: EventosConfig{
+			ReaperturaHoras:  eventoReapertura,
+			RevisionSegundos: eventoRevision,
+		},
+		Reportes: ReportesConfig{
+			RevisionSegundos: reporteRevision,
+			RetencionDias:    reporteRetencion,
+		},
+		Metricas: MetricasConfig{
+			Segundos: metricasSegundos,
+		},
+		Analitica: AnaliticaConfig{
+			RefrescoMinutos: analiticaRefresco,
+		},
+		Carga: CargaConfig{
+			UmbralSobrecarga: cargaUmbral,
+		},
 	}
 }
 
@@ -93,3 +278,31 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+// secretoQR usa QR_SECRETO si está. Si no, deriva una clave propia de
+// JWT_SECRET con HKDF: los QR impresos nunca llevan una firma hecha con la
+// misma clave que los tokens de sesión, y filtrar una no revela la otra.
+func secretoQR(propio, jwtSecret string) string {
+	if propio != "" {
+		return propio
+	}
+	if jwtSecret == "" {
+		return ""
+	}
+	clave := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, []byte(jwtSecret), nil, []byte("eventpulse qr v1")), clave); err != nil {
+		log.Fatalf("derivando el secreto de los QR: %v", err)
+	}
+	return hex.EncodeToString(clave)
+}
+
+// lista separa valores por coma e ignora los vacíos
+func lista(v string) []string {
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,22 @@
+package config
+
+import "testing"
+
+func TestSecretoQR(t *testing.T) {
+	derivado := secretoQR("", "jwt-secreto")
+	if derivado == "" || derivado == "jwt-secreto" {
+		t.Fatalf("sin QR_SECRETO se esperaba una clave derivada, se obtuvo %q", derivado)
+	}
+	if otra := secretoQR("", "jwt-secreto"); otra != derivado {
+		t.Errorf("la derivación no es estable: %q y %q", derivado, otra)
+	}
+	if otra := secretoQR("", "otro-jwt"); otra == derivado {
+		t.Error("dos JWT_SECRET distintos derivaron la misma clave")
+	}
+	if got := secretoQR("propio", "jwt-secreto"); got != "propio" {
+		t.Errorf("QR_SECRETO definido: se obtuvo %q", got)
+	}
+	if got := secretoQR("", ""); got != "" {
+		t.Errorf("sin secretos: se obtuvo %q", got)
+	}
+}
@@ -0,0 +1,48 @@
+package analitica
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/eventpulse/backend/internal/repository"
+)
+
+// Refrescador mantiene al día mv_incidencias_hora. Entre refrescos las
+// consultas cuentan lo nuevo directo de incidencias, así que el intervalo solo
+// decide cuánto trabajo hace cada consulta, no qué tan frescos son los datos.
+type Refrescador struct {
+	repo      *repository.AnaliticaRepo
+	intervalo time.Duration
+}
+
+func NewRefrescador(r *repository.AnaliticaRepo, intervalo time.Duration) *Refrescador {
+	return &Refrescador{repo: r, intervalo: intervalo}
+}
+
+// Run refresca al arrancar y después en cada intervalo
+func (r *Refrescador) Run(ctx context.Context) {
+	r.refrescar(ctx)
+	ticker := time.NewTicker(r.intervalo)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.refrescar(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *Refrescador) refrescar(ctx context.Context) {
+	inicio := time.Now()
+	hecho, err := r.repo.Refrescar(ctx, r.intervalo)
+	if err != nil {
+		log.Println("❌ Error refrescando la analítica de incidencias:", err)
+		return
+	}
+	if hecho {
+		log.Printf("📊 Analítica de incidencias refrescada en %s", time.Since(inicio).Round(time.Millisecond))
+	}
+}
@@ -0,0 +1,71 @@
+package anuncios
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/eventpulse/backend/internal/notificaciones"
+	"github.com/eventpulse/backend/internal/repository"
+	"github.com/eventpulse/backend/internal/ws"
+)
+
+// Repetidor reenvía periódicamente los anuncios abiertos a quien todavía no
+// los confirmó. Cada anuncio define su propio intervalo (reenviar_cada_seg);
+// el repetidor solo revisa cada `revision` cuáles ya vencieron. El reenvío va
+// por WS y por los canales de notificación que cada uno tenga habilitados.
+type Repetidor struct {
+	repo        *repository.AnuncioRepo
+	hub         *ws.Hub
+	notificador *notificaciones.Notificador
+	revision    time.Duration
+}
+
+func NewRepetidor(r *repository.AnuncioRepo, h *ws.Hub, n *notificaciones.Notificador, revision time.Duration) *Repetidor {
+	return &Repetidor{repo: r, hub: h, notificador: n, revision: revision}
+}
+
+func (r *Repetidor) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.revision)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.revisar(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *Repetidor) revisar(ctx context.Context) {
+	reenvios, err := r.repo.TomarReenviosPendientes(ctx)
+	if err != nil {
+		log.Println("❌ Error buscando anuncios sin confirmar:", err)
+		return
+	}
+	for _, re := range reenvios {
+		anuncio, err := r.repo.ObtenerPorID(ctx, re.AnuncioID)
+		if err != nil || anuncio == nil {
+			continue
+		}
+		err = r.hub.PublicarAUsuarios(ctx, re.Usuarios, models.EventoWS{
+			Tipo:     models.WSAnuncioRecordatorio,
+			Payload:  anuncio,
+			EventoID: anuncio.EventoID,
+		})
+		if err != nil {
+			log.Println("❌ Error reenviando anuncio en Redis:", err)
+		}
+		// Fuera de la app también, aunque el WS haya fallado
+		r.notificador.NotificarAhora(ctx, re.Usuarios, models.Notificacion{
+			Tipo:    models.NotifAnuncio,
+			Titulo:  anuncio.Titulo,
+			Cuerpo:  anuncio.Contenido,
+			Urgente: true,
+			Ref:     anuncio.ID,
+		})
+		log.Printf("🔁 Anuncio %s reenviado a %d sin confirmar", anuncio.ID, len(re.Usuarios))
+	}
+}
@@ -1,41 +1,58 @@
 package auth
 
 import (
+	"context"
 	"errors"
+	"log"
+	"strconv"
 	"time"
 
 	"github.com/eventpulse/backend/config"
 	"github.com/eventpulse/backend/internal/models"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
 )
 
+// ep:sesiones:revocadas:<usuario> guarda el unix en milisegundos desde el que
+// valen sus tokens; expira cuando ya venció todo lo emitido antes
+const prefijoRevocadas = "ep:sesiones:revocadas:"
+
+var ErrSesionRevocada = errors.New("sesión revocada")
+
 type Claims struct {
 	UsuarioID string     `json:"usuario_id"`
 	EventoID  *string    `json:"evento_id"`
 	Rol       models.Rol `json:"rol"`
+	// iat en milisegundos: el iat estándar va en segundos y no distingue un
+	// token emitido justo antes de la revocación de uno emitido justo después
+	EmitidoMs int64 `json:"iat_ms,omitempty"`
 	jwt.RegisteredClaims
 }
 
 type JWTService struct {
 	secret     []byte
 	expiration time.Duration
+	redis      *redis.Client
 }
 
-func NewJWTService(cfg *config.Config) *JWTService {
+func NewJWTService(cfg *config.Config, rdb *redis.Client) *JWTService {
 	return &JWTService{
 		secret:     []byte(cfg.JWT.Secret),
 		expiration: time.Duration(cfg.JWT.ExpirationHours) * time.Hour,
+		redis:      rdb,
 	}
 }
 
 func (j *JWTService) GenerarToken(usuario *models.Usuario) (string, error) {
+	ahora := time.Now()
 	claims := Claims{
 		UsuarioID: usuario.ID,
 		EventoID:  usuario.EventoID,
 		Rol:       usuario.Rol,
+		EmitidoMs: ahora.UnixMilli(),
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.expiration)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(ahora.Add(j.expiration)),
+			IssuedAt:  jwt.NewNumericDate(ahora),
 			Subject:   usuario.ID,
 		},
 	}
@@ -44,7 +61,9 @@ func (j *JWTService) GenerarToken(usuario *models.Usuario) (string, error) {
 	return token.SignedString(j.secret)
 }
 
-func (j *JWTService) ValidarToken(tokenStr string) (*Claims, error) {
+// ValidarToken revisa firma, vencimiento y que la sesión no se haya revocado
+// después de emitirse el token
+func (j *JWTService) ValidarToken(ctx context.Context, tokenStr string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenStr, &Claims{}, func(t *jwt.Token) (interface{}, error) {
 		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, errors.New("método de firma inesperado")
@@ -60,6 +79,62 @@ func (j *JWTService) ValidarToken(tokenStr string) (*Claims, error) {
 	if !ok || !token.Valid {
 		return nil, errors.New("token inválido")
 	}
+	if j.revocado(ctx, claims) {
+		return nil, ErrSesionRevocada
+	}
 
 	return claims, nil
 }
+
+// RevocarSesiones invalida los tokens de esos usuarios emitidos hasta desde;
+// al volver a iniciar sesión reciben uno nuevo. Repetirla con el mismo desde
+// no corta las sesiones abiertas después.
+func (j *JWTService) RevocarSesiones(ctx context.Context, usuarioIDs []string, desde time.Time) error {
+	valor := strconv.FormatInt(desde.UnixMilli(), 10)
+	pipe := j.redis.Pipeline()
+	for _, id := range usuarioIDs {
+		pipe.Set(ctx, prefijoRevocadas+id, valor, j.expiration)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// revocado: emitido antes de la última revocación (o en el mismo
+// milisegundo). Si Redis no responde se deja pasar: sin Redis tampoco hay
+// tiempo real, y así no se corta todo el API; queda en el log por cada token
+// que entra sin verificar.
+func (j *JWTService) revocado(ctx context.Context, claims *Claims) bool {
+	v, err := j.redis.Get(ctx, prefijoRevocadas+claims.UsuarioID).Result()
+	if errors.Is(err, redis.Nil) {
+		return false
+	}
+	if err != nil {
+		log.Printf("⚠️ Token de %s aceptado sin revisar revocaciones (Redis): %v", claims.UsuarioID, err)
+		return false
+	}
+	desde, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		log.Printf("❌ Revocación ilegible para %s: %q", claims.UsuarioID, v)
+		return false
+	}
+	return emitidoAntesDe(claims, desde)
+}
+
+// emitidoAntesDe compara el momento de emisión con la revocación, ambos en
+// milisegundos. Con una revocación guardada en segundos o un token sin
+// iat_ms (anteriores al cambio) se compara por segundo, y el mismo segundo
+// cuenta como revocado.
+func emitidoAntesDe(claims *Claims, desdeMs int64) bool {
+	if desdeMs < 1e12 { // unix en segundos (en milisegundos sería antes de 2001)
+		desdeMs = desdeMs*1000 + 999
+	}
+	emitido := claims.EmitidoMs
+	if emitido == 0 {
+		if claims.IssuedAt == nil {
+			return true
+		}
+		emitido = claims.IssuedAt.Unix() * 1000
+		desdeMs = desdeMs - desdeMs%1000
+	}
+	return emitido <= desdeMs
+}
@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/eventpulse/backend/config"
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestEmitidoAntesDe(t *testing.T) {
+	revocacion := time.Date(2026, 10, 18, 12, 0, 0, 500*int(time.Millisecond), time.UTC)
+	ms := func(d time.Duration) *Claims {
+		return &Claims{EmitidoMs: revocacion.Add(d).UnixMilli()}
+	}
+	soloIat := func(d time.Duration) *Claims {
+		return &Claims{RegisteredClaims: jwt.RegisteredClaims{IssuedAt: jwt.NewNumericDate(revocacion.Add(d))}}
+	}
+	casos := []struct {
+		nombre   string
+		claims   *Claims
+		desde    int64
+		revocado bool
+	}{
+		{"antes", ms(-time.Millisecond), revocacion.UnixMilli(), true},
+		{"mismo milisegundo", ms(0), revocacion.UnixMilli(), true},
+		{"después, en el mismo segundo", ms(time.Millisecond), revocacion.UnixMilli(), false},
+		{"un segundo después", ms(time.Second), revocacion.UnixMilli(), false},
+		{"token viejo del mismo segundo", soloIat(400 * time.Millisecond), revocacion.UnixMilli(), true},
+		{"token viejo del segundo siguiente", soloIat(time.Second), revocacion.UnixMilli(), false},
+		{"revocación guardada en segundos", ms(499 * time.Millisecond), revocacion.Unix(), true},
+		{"revocación en segundos, segundo siguiente", ms(time.Second), revocacion.Unix(), false},
+		{"sin iat", &Claims{}, revocacion.UnixMilli(), true},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			if got := emitidoAntesDe(c.claims, c.desde); got != c.revocado {
+				t.Errorf("emitidoAntesDe = %v, esperado %v", got, c.revocado)
+			}
+		})
+	}
+}
+
+// Sin Redis el token se acepta (y queda en el log): no se corta todo el API
+func TestValidarTokenSinRedis(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1, DialTimeout: 200 * time.Millisecond})
+	defer rdb.Close()
+	cfg := &config.Config{}
+	cfg.JWT.Secret = "secreto-de-prueba"
+	cfg.JWT.ExpirationHours = 1
+	svc := NewJWTService(cfg, rdb)
+
+	token, err := svc.GenerarToken(&models.Usuario{ID: "u1", Rol: models.RolGuardia})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := svc.ValidarToken(context.Background(), token)
+	if err != nil {
+		t.Fatalf("ValidarToken: %v", err)
+	}
+	if claims.UsuarioID != "u1" || claims.EmitidoMs == 0 {
+		t.Errorf("claims = %+v", claims)
+	}
+}
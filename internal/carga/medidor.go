@@ -0,0 +1,91 @@
+package carga
+
+import (
+	"cmp"
+	"context"
+	"slices"
+
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/eventpulse/backend/internal/repository"
+)
+
+// Medidor dice quién está libre y quién sobrecargado. El orden de EnVivo es
+// el de asignación: quien está en turno, con menos abierto y que hace más
+// que no toma nada va primero.
+type Medidor struct {
+	repo   cargaAbierta
+	umbral int // abiertas desde las que alguien está sobrecargado
+}
+
+// Lo que el medidor usa del repositorio (en producción, *repository.CargaRepo).
+// La lista llega sin orden: el de asignación se decide solo acá.
+type cargaAbierta interface {
+	EnVivo(ctx context.Context, eventoID string, rol models.Rol) ([]models.CargaTrabajador, error)
+}
+
+func NewMedidor(r *repository.CargaRepo, umbralSobrecarga int) *Medidor {
+	return nuevoMedidor(r, umbralSobrecarga)
+}
+
+func nuevoMedidor(r cargaAbierta, umbralSobrecarga int) *Medidor {
+	if umbralSobrecarga < 1 {
+		umbralSobrecarga = 1
+	}
+	return &Medidor{repo: r, umbral: umbralSobrecarga}
+}
+
+func (m *Medidor) Umbral() int { return m.umbral }
+
+// EnVivo: la carga del personal de campo del evento (rol "" = todos), del
+// menos al más cargado
+func (m *Medidor) EnVivo(ctx context.Context, eventoID string, rol models.Rol) ([]models.CargaTrabajador, error) {
+	lista, err := m.repo.EnVivo(ctx, eventoID, rol)
+	if err != nil {
+		return nil, err
+	}
+	for i := range lista {
+		c := &lista[i]
+		c.Abiertas = c.IncidenciasAbiertas + c.TareasAbiertas
+		switch {
+		case c.Abiertas == 0:
+			c.Nivel = models.CargaLibre
+		case c.Abiertas >= m.umbral:
+			c.Nivel = models.CargaSobrecargado
+		default:
+			c.Nivel = models.CargaOcupado
+		}
+	}
+	slices.SortStableFunc(lista, ordenAsignacion)
+	return lista, nil
+}
+
+// ordenAsignacion: en turno, menos abierto, menos tareas de prioridad alta,
+// sin tomas antes que con y después la toma más vieja; el nombre desempata
+func ordenAsignacion(x, y models.CargaTrabajador) int {
+	return cmp.Or(
+		-cmp.Compare(b2i(x.EnTurno), b2i(y.EnTurno)),
+		cmp.Compare(x.Abiertas, y.Abiertas),
+		cmp.Compare(x.TareasAlta, y.TareasAlta),
+		compararToma(x, y),
+		cmp.Compare(x.Nombre, y.Nombre),
+	)
+}
+
+func compararToma(x, y models.CargaTrabajador) int {
+	switch {
+	case x.UltimaToma == nil && y.UltimaToma == nil:
+		return 0
+	case x.UltimaToma == nil:
+		return -1
+	case y.UltimaToma == nil:
+		return 1
+	}
+	return x.UltimaToma.Compare(*y.UltimaToma)
+}
+
+func b2i(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,99 @@
+package carga
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/eventpulse/backend/internal/models"
+)
+
+type cargaFija struct {
+	lista []models.CargaTrabajador
+	err   error
+	rol   models.Rol
+}
+
+func (f *cargaFija) EnVivo(_ context.Context, _ string, rol models.Rol) ([]models.CargaTrabajador, error) {
+	f.rol = rol
+	return append([]models.CargaTrabajador(nil), f.lista...), f.err
+}
+
+func hace(min int) *time.Time {
+	t := time.Date(2026, 7, 10, 20, 0, 0, 0, time.UTC).Add(-time.Duration(min) * time.Minute)
+	return &t
+}
+
+func TestMedidorNivel(t *testing.T) {
+	casos := []struct {
+		nombre              string
+		umbral              int
+		incidencias, tareas int
+		abiertas            int
+		nivel               models.NivelCarga
+	}{
+		{"nada abierto", 4, 0, 0, 0, models.CargaLibre},
+		{"una incidencia", 4, 1, 0, 1, models.CargaOcupado},
+		{"bajo el umbral", 4, 2, 1, 3, models.CargaOcupado},
+		{"en el umbral", 4, 2, 2, 4, models.CargaSobrecargado},
+		{"sobre el umbral", 4, 0, 6, 6, models.CargaSobrecargado},
+		{"umbral inválido vale 1", 0, 0, 1, 1, models.CargaSobrecargado},
+		{"umbral inválido, libre", -3, 0, 0, 0, models.CargaLibre},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			repo := &cargaFija{lista: []models.CargaTrabajador{{IncidenciasAbiertas: c.incidencias, TareasAbiertas: c.tareas}}}
+			lista, err := nuevoMedidor(repo, c.umbral).EnVivo(context.Background(), "evento", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := lista[0]; got.Abiertas != c.abiertas || got.Nivel != c.nivel {
+				t.Errorf("abiertas %d nivel %q, esperado %d %q", got.Abiertas, got.Nivel, c.abiertas, c.nivel)
+			}
+		})
+	}
+}
+
+func TestMedidorOrden(t *testing.T) {
+	repo := &cargaFija{lista: []models.CargaTrabajador{
+		{Nombre: "fuera de turno y libre"},
+		{Nombre: "con más abierto", EnTurno: true, IncidenciasAbiertas: 2},
+		{Nombre: "tomó hace poco", EnTurno: true, TareasAbiertas: 1, UltimaToma: hace(5)},
+		{Nombre: "con tarea alta", EnTurno: true, TareasAbiertas: 1, TareasAlta: 1},
+		{Nombre: "tomó hace rato", EnTurno: true, IncidenciasAbiertas: 1, UltimaToma: hace(90)},
+		{Nombre: "nunca tomó", EnTurno: true, TareasAbiertas: 1},
+		{Nombre: "Beto", EnTurno: true},
+		{Nombre: "Ana", EnTurno: true},
+		{Nombre: "fuera de turno y cargado", TareasAbiertas: 5},
+	}}
+	esperado := []string{
+		"Ana", "Beto", // libres en turno, por nombre
+		"nunca tomó", "tomó hace rato", "tomó hace poco",
+		"con tarea alta",
+		"con más abierto",
+		"fuera de turno y libre", "fuera de turno y cargado",
+	}
+	lista, err := nuevoMedidor(repo, 4).EnVivo(context.Background(), "evento", models.RolGuardia)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.rol != models.RolGuardia {
+		t.Errorf("rol pedido al repositorio = %q, esperado %q", repo.rol, models.RolGuardia)
+	}
+	if len(lista) != len(esperado) {
+		t.Fatalf("%d en la lista, esperado %d", len(lista), len(esperado))
+	}
+	for i, nombre := range esperado {
+		if lista[i].Nombre != nombre {
+			t.Errorf("posición %d = %q, esperado %q", i, lista[i].Nombre, nombre)
+		}
+	}
+}
+
+func TestMedidorError(t *testing.T) {
+	falla := errors.New("sin conexión")
+	if _, err := nuevoMedidor(&cargaFija{err: falla}, 4).EnVivo(context.Background(), "evento", ""); !errors.Is(err, falla) {
+		t.Errorf("EnVivo = %v, esperado %v", err, falla)
+	}
+}
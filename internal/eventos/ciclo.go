@@ -0,0 +1,163 @@
+package eventos
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/eventpulse/backend/internal/repository"
+	"github.com/eventpulse/backend/internal/ws"
+)
+
+var (
+	ErrSinInicio     = errors.New("para programar el evento hace falta un inicio_programado futuro")
+	ErrFueraDeGracia = errors.New("ya venció el plazo para reabrir el evento")
+)
+
+// transiciones permitidas → aviso WS de cada una
+var transiciones = map[[2]models.EstadoEvento]models.TipoEventoWS{
+	{models.EventoBorrador, models.EventoProgramado}: models.WSEventoProgramado,
+	{models.EventoBorrador, models.EventoActivo}:     models.WSEventoIniciado,
+	{models.EventoProgramado, models.EventoBorrador}: models.WSEventoDesprogramado,
+	{models.EventoProgramado, models.EventoActivo}:   models.WSEventoIniciado,
+	{models.EventoActivo, models.EventoPausado}:      models.WSEventoPausado,
+	{models.EventoActivo, models.EventoTerminado}:    models.WSEventoTerminado,
+	{models.EventoPausado, models.EventoActivo}:      models.WSEventoReanudado,
+	{models.EventoPausado, models.EventoTerminado}:   models.WSEventoTerminado,
+	{models.EventoTerminado, models.EventoActivo}:    models.WSEventoReabierto,
+}
+
+// Ciclo lleva los cambios de estado de los eventos, los pida un admin o los
+// dispare el programador: inicia los programados al llegar su hora y pide el
+// cierre de los que llegan a su fin. Cada cambio queda en el historial y se publica a
+// todo el evento. Con varias instancias no hay doble transición: el cambio
+// solo aplica si el estado sigue siendo el que se leyó.
+type Ciclo struct {
+	repo         *repository.EventoRepo
+	patrullaRepo *repository.PatrullaRepo
+	hub          *ws.Hub
+	cerrador     *Cerrador
+	gracia       time.Duration // plazo para reabrir un evento terminado (0 = no se reabre)
+	revision     time.Duration
+	bloqueados   map[string]bool // programados que esperan a que termine otro; solo lo usa Run
+}
+
+func NewCiclo(r *repository.EventoRepo, p *repository.PatrullaRepo, h *ws.Hub, cerrador *Cerrador, gracia, revision time.Duration) *Ciclo {
+	return &Ciclo{repo: r, patrullaRepo: p, hub: h, cerrador: cerrador, gracia: gracia, revision: revision, bloqueados: map[string]bool{}}
+}
+
+// Cambiar aplica la transición que pide un admin. version, si viene, es la
+// del If-Match. Terminar no pasa por acá sino por el Cerrador.
+func (c *Ciclo) Cambiar(ctx context.Context, eventoID string, hacia models.EstadoEvento, usuarioID string, version *int) (*models.Evento, error) {
+	ev, err := c.repo.ObtenerPorID(ctx, eventoID)
+	if err != nil {
+		return nil, err
+	}
+	if ev == nil {
+		return nil, repository.ErrNoEncontrado
+	}
+	if version != nil && *version != ev.Version {
+		return nil, repository.ErrVersionConflicto
+	}
+	if _, ok := transiciones[[2]models.EstadoEvento{ev.Estado, hacia}]; !ok {
+		return nil, repository.ErrTransicionInvalida
+	}
+	switch {
+	case hacia == models.EventoTerminado:
+		return nil, ErrRequiereCierre
+	case hacia == models.EventoProgramado && (ev.InicioProgramado == nil || !ev.InicioProgramado.After(time.Now())):
+		return nil, ErrSinInicio
+	case ev.Estado == models.EventoTerminado && !c.Reabrible(ev):
+		return nil, ErrFueraDeGracia
+	}
+	return c.aplicar(ctx, ev, hacia, models.TransicionAdmin, &usuarioID, version)
+}
+
+// Reabrible: terminado hace menos que el plazo de gracia
+func (c *Ciclo) Reabrible(ev *models.Evento) bool {
+	return ev.Estado == models.EventoTerminado && ev.TerminadoEn != nil && c.gracia > 0 &&
+		time.Since(*ev.TerminadoEn) < c.gracia
+}
+
+// Run revisa periódicamente los eventos que tienen que iniciar o terminar
+func (c *Ciclo) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.revision)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.revisar(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// revisar termina primero, así un evento puede empezar en el mismo instante
+// en que termina el anterior. El cierre programado usa las opciones por
+// defecto (resolver lo abierto, desvincular al personal) y corre enseguida;
+// si todavía no terminó, el siguiente se reintenta en la próxima revisión.
+func (c *Ciclo) revisar(ctx context.Context) {
+	porTerminar, err := c.repo.PorTerminar(ctx)
+	if err != nil {
+		log.Println("❌ Error buscando eventos por terminar:", err)
+		return
+	}
+	for i := range porTerminar {
+		_, err := c.cerrador.Solicitar(ctx, porTerminar[i].ID, models.OpcionesCierre{}, models.TransicionPrograma, nil, nil)
+		if err != nil && !errors.Is(err, repository.ErrTransicionInvalida) && !errors.Is(err, repository.ErrCierreEnCurso) {
+			log.Printf("❌ Error pidiendo el cierre del evento %s: %v", porTerminar[i].ID, err)
+		}
+	}
+
+	porIniciar, err := c.repo.PorIniciar(ctx)
+	if err != nil {
+		log.Println("❌ Error buscando eventos por iniciar:", err)
+		return
+	}
+	for i := range porIniciar {
+		ev := &porIniciar[i]
+		_, err := c.aplicar(ctx, ev, models.EventoActivo, models.TransicionPrograma, nil, nil)
+		switch {
+		case errors.Is(err, repository.ErrOtroEventoEnCurso):
+			// Se reintenta en cada revisión; se avisa una sola vez
+			if !c.bloqueados[ev.ID] {
+				c.bloqueados[ev.ID] = true
+				log.Printf("⚠️ El evento %s no pudo iniciar a su hora: hay otro en curso", ev.Nombre)
+			}
+		case err != nil && !errors.Is(err, repository.ErrTransicionInvalida):
+			log.Printf("❌ Error iniciando el evento %s: %v", ev.ID, err)
+		default:
+			delete(c.bloqueados, ev.ID)
+		}
+	}
+}
+
+func (c *Ciclo) aplicar(ctx context.Context, ev *models.Evento, hacia models.EstadoEvento, motivo models.MotivoTransicion, usuarioID *string, version *int) (*models.Evento, error) {
+	nuevo, err := c.repo.CambiarEstado(ctx, ev.ID, ev.Estado, hacia, motivo, usuarioID, version)
+	if err != nil {
+		return nil, err
+	}
+	// Las rondas en curso no pierden el tiempo que duró la pausa
+	if ev.Estado == models.EventoPausado && hacia == models.EventoActivo && ev.PausadoEn != nil {
+		if err := c.patrullaRepo.Desplazar(ctx, ev.ID, time.Since(*ev.PausadoEn)); err != nil {
+			log.Println("❌ Error desplazando rondas tras la pausa:", err)
+		}
+	}
+	// Al reabrir vuelve el personal que sacó el cierre
+	if ev.Estado == models.EventoTerminado {
+		if n, err := c.cerrador.repo.RestaurarPersonal(ctx, ev.ID); err != nil {
+			log.Println("❌ Error restaurando el personal del evento:", err)
+		} else if n > 0 {
+			log.Printf("📅 Evento %s: %d personas vuelven a estar vinculadas", nuevo.Nombre, n)
+		}
+	}
+	tipo := transiciones[[2]models.EstadoEvento{ev.Estado, hacia}]
+	if err := c.hub.Publicar(ctx, ev.ID, models.EventoWS{Tipo: tipo, Payload: nuevo, EventoID: ev.ID}); err != nil {
+		log.Printf("❌ Error publicando %s en Redis: %v", tipo, err)
+	}
+	log.Printf("📅 Evento %s: %s → %s (%s)", nuevo.Nombre, ev.Estado, hacia, motivo)
+	return nuevo, nil
+}
@@ -0,0 +1,177 @@
+package eventos
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/eventpulse/backend/internal/auth"
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/eventpulse/backend/internal/repository"
+	"github.com/eventpulse/backend/internal/ws"
+)
+
+// ErrRequiereCierre: un evento no se termina con un cambio de estado suelto,
+// pasa por el cierre
+var ErrRequiereCierre = errors.New("para terminar el evento usa POST /eventos/:id/cierre")
+
+// intentos antes de dar un cierre por fallido
+const maxIntentosCierre = 3
+
+// Cerrador termina los eventos como un trabajo: resuelve o traslada lo
+// abierto, saca al personal, guarda el reporte final y pasa el evento a
+// terminado, todo en una transacción. Lo que no se deshace con un rollback
+// (revocar sesiones, avisar por WS) se hace después del commit.
+type Cerrador struct {
+	repo       *repository.CierreRepo
+	eventoRepo *repository.EventoRepo
+	jwtSvc     *auth.JWTService
+	hub        *ws.Hub
+	revision   time.Duration
+}
+
+func NewCerrador(r *repository.CierreRepo, e *repository.EventoRepo, j *auth.JWTService, h *ws.Hub, revision time.Duration) *Cerrador {
+	return &Cerrador{repo: r, eventoRepo: e, jwtSvc: j, hub: h, revision: revision}
+}
+
+// Solicitar valida y encola el cierre; el trabajo arranca enseguida. version,
+// si viene, es la del If-Match.
+func (c *Cerrador) Solicitar(ctx context.Context, eventoID string, o models.OpcionesCierre, motivo models.MotivoTransicion, usuarioID *string, version *int) (*models.CierreEvento, error) {
+	ev, err := c.eventoRepo.ObtenerPorID(ctx, eventoID)
+	if err != nil {
+		return nil, err
+	}
+	if ev == nil {
+		return nil, repository.ErrNoEncontrado
+	}
+	if version != nil && *version != ev.Version {
+		return nil, repository.ErrVersionConflicto
+	}
+	if !ev.Estado.EnCurso() {
+		return nil, repository.ErrTransicionInvalida
+	}
+	if o.Traslada() {
+		if o.DestinoEventoID == nil || *o.DestinoEventoID == eventoID {
+			return nil, repository.ErrDestinoCierre
+		}
+		destino, err := c.eventoRepo.ObtenerPorID(ctx, *o.DestinoEventoID)
+		if err != nil {
+			return nil, err
+		}
+		if destino == nil || (destino.Estado != models.EventoBorrador && destino.Estado != models.EventoProgramado) {
+			return nil, repository.ErrDestinoCierre
+		}
+	} else {
+		o.DestinoEventoID = nil
+	}
+	cierre, err := c.repo.Crear(ctx, eventoID, o.ConDefectos(), motivo, usuarioID)
+	if err != nil {
+		return nil, err
+	}
+	go c.procesar(context.Background())
+	return cierre, nil
+}
+
+// Run retoma los cierres que quedaron pendientes (los que fallaron por algo
+// pasajero o los de una instancia que cayó a mitad) y las revocaciones de
+// sesiones que no se lograron
+func (c *Cerrador) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.revision)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.procesar(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// procesar reintenta las revocaciones que quedaron pendientes y después
+// ejecuta los cierres pendientes
+func (c *Cerrador) procesar(ctx context.Context) {
+	c.revocarPendientes(ctx)
+	c.ejecutarPendientes(ctx)
+}
+
+// ejecutarPendientes los toma de a uno; los que fallan en esta pasada no se
+// vuelven a tomar hasta la próxima
+func (c *Cerrador) ejecutarPendientes(ctx context.Context) {
+	var fallidos []string
+	for {
+		res, err := c.repo.EjecutarSiguiente(ctx, fallidos)
+		if res == nil && err == nil {
+			return
+		}
+		if err != nil {
+			if res == nil {
+				log.Println("❌ Error tomando un cierre de evento:", err)
+				return
+			}
+			fallidos = append(fallidos, res.Cierre.ID)
+			definitivo := errors.Is(err, repository.ErrCierreNoAplica) ||
+				errors.Is(err, repository.ErrDestinoCierre) || errors.Is(err, repository.ErrNoEncontrado)
+			log.Printf("❌ Error cerrando el evento %s: %v", res.Cierre.EventoID, err)
+			if err := c.repo.MarcarFallo(ctx, res.Cierre.ID, err, definitivo, maxIntentosCierre); err != nil {
+				log.Println("❌ Error registrando el fallo del cierre:", err)
+			}
+			continue
+		}
+		c.avisar(ctx, res)
+	}
+}
+
+func (c *Cerrador) avisar(ctx context.Context, res *repository.CierreEjecutado) {
+	ev := res.Evento
+	// Primero el aviso a todo el evento, después se corta al personal
+	if err := c.hub.Publicar(ctx, ev.ID, models.EventoWS{Tipo: models.WSEventoTerminado, Payload: ev, EventoID: ev.ID}); err != nil {
+		log.Printf("❌ Error publicando %s en Redis: %v", models.WSEventoTerminado, err)
+	}
+	// Como al resolver un SOS a mano: quien lo activó deja de mandar ubicación
+	for i := range res.SOS {
+		sos := &res.SOS[i]
+		ids := append(slices.DeleteFunc(slices.Clone(res.RespuestaSOS), func(id string) bool { return id == sos.UsuarioID }), sos.UsuarioID)
+		if err := c.hub.PublicarAUsuarios(ctx, ids, models.EventoWS{Tipo: models.WSSOSFinalizado, Payload: sos, EventoID: ev.ID}); err != nil {
+			log.Printf("❌ Error publicando %s en Redis: %v", models.WSSOSFinalizado, err)
+		}
+	}
+	if res.Cierre.Estado == models.CierreRevocando {
+		c.revocar(ctx, res.Cierre, res.Personal)
+	}
+	log.Printf("📅 Evento %s cerrado (%s): %d personas fuera", ev.Nombre, res.Cierre.Motivo, len(res.Personal))
+}
+
+// revocar corta las sesiones del personal que sacó el cierre (los tokens
+// emitidos hasta el cierre) y cierra sus sockets. Recién entonces el cierre
+// queda completado; si Redis falla sigue revocando y se reintenta en la
+// próxima revisión.
+func (c *Cerrador) revocar(ctx context.Context, cierre *models.CierreEvento, personal []string) {
+	err := c.jwtSvc.RevocarSesiones(ctx, personal, *cierre.TerminadoEn)
+	if err == nil {
+		err = c.hub.PublicarAUsuarios(ctx, personal, models.EventoWS{
+			Tipo:     models.WSSesionRevocada,
+			Payload:  map[string]string{"motivo": "evento_terminado"},
+			EventoID: cierre.EventoID,
+		})
+	}
+	if err != nil {
+		log.Printf("❌ Error revocando sesiones del cierre %s (se reintenta): %v", cierre.ID, err)
+	}
+	if err := c.repo.TerminarRevocacion(ctx, cierre.ID, err); err != nil {
+		log.Println("❌ Error registrando la revocación del cierre:", err)
+	}
+}
+
+func (c *Cerrador) revocarPendientes(ctx context.Context) {
+	pendientes, err := c.repo.RevocacionesPendientes(ctx)
+	if err != nil {
+		log.Println("❌ Error buscando revocaciones pendientes:", err)
+		return
+	}
+	for i := range pendientes {
+		c.revocar(ctx, &pendientes[i].CierreEvento, pendientes[i].Personal)
+	}
+}
@@ -0,0 +1,167 @@
+package geo
+
+import (
+	"errors"
+	"math"
+
+	"github.com/eventpulse/backend/internal/models"
+)
+
+const radioTierraM = 6371000.0
+
+// RadioPorDefectoM es el alcance de una zona definida solo por un punto
+const RadioPorDefectoM = 25.0
+
+var ErrPoligonoInvalido = errors.New("polígono inválido: anillos cerrados de al menos 4 posiciones [lng, lat]")
+
+// DistanciaM entre dos coordenadas (haversine)
+func DistanciaM(lat1, lng1, lat2, lng2 float64) float64 {
+	dLat, dLng := rad(lat2-lat1), rad(lng2-lng1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(rad(lat1))*math.Cos(rad(lat2))*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * radioTierraM * math.Asin(math.Sqrt(a))
+}
+
+// ValidarPoligono exige anillos cerrados con coordenadas en rango
+func ValidarPoligono(p models.Poligono) error {
+	if len(p) == 0 {
+		return ErrPoligonoInvalido
+	}
+	for _, anillo := range p {
+		if len(anillo) < 4 {
+			return ErrPoligonoInvalido
+		}
+		for _, pos := range anillo {
+			if len(pos) < 2 || pos[0] < -180 || pos[0] > 180 || pos[1] < -90 || pos[1] > 90 {
+				return ErrPoligonoInvalido
+			}
+		}
+		primera, ultima := anillo[0], anillo[len(anillo)-1]
+		if primera[0] != ultima[0] || primera[1] != ultima[1] {
+			return ErrPoligonoInvalido
+		}
+	}
+	return nil
+}
+
+// Contiene dice si el punto cae en el anillo exterior y fuera de los huecos
+func Contiene(p models.Poligono, lat, lng float64) bool {
+	if len(p) == 0 || !enAnillo(p[0], lat, lng) {
+		return false
+	}
+	for _, hueco := range p[1:] {
+		if enAnillo(hueco, lat, lng) {
+			return false
+		}
+	}
+	return true
+}
+
+// DistanciaZona son los metros del punto al borde de la zona (0 si está
+// dentro). ok=false si la zona no tiene geometría.
+func DistanciaZona(z *models.Zona, lat, lng float64) (float64, bool) {
+	if len(z.Poligono) > 0 {
+		if Contiene(z.Poligono, lat, lng) {
+			return 0, true
+		}
+		return distanciaBorde(z.Poligono, lat, lng), true
+	}
+	if z.Latitud != nil && z.Longitud != nil {
+		radio := RadioPorDefectoM
+		if z.RadioM != nil {
+			radio = *z.RadioM
+		}
+		return math.Max(0, DistanciaM(lat, lng, *z.Latitud, *z.Longitud)-radio), true
+	}
+	return 0, false
+}
+
+// Centro es el punto donde se dibuja la zona: su punto si lo tiene, si no
+// el promedio de los vértices del anillo exterior
+func Centro(z *models.Zona) (lat, lng float64, ok bool) {
+	if z.Latitud != nil && z.Longitud != nil {
+		return *z.Latitud, *z.Longitud, true
+	}
+	if len(z.Poligono) == 0 || len(z.Poligono[0]) < 2 {
+		return 0, 0, false
+	}
+	exterior := z.Poligono[0][:len(z.Poligono[0])-1] // sin repetir el cierre
+	for _, pos := range exterior {
+		lng += pos[0]
+		lat += pos[1]
+	}
+	n := float64(len(exterior))
+	return lat / n, lng / n, true
+}
+
+// Cercana elige la zona para un punto: entre las que lo contienen, la más
+// específica (punto antes que area antes que sector); si ninguna lo
+// contiene, la más cercana. Con piso, se ignoran las zonas de otro piso (las
+// que no tienen piso valen para todos). nil si ninguna tiene geometría.
+func Cercana(zonas []models.Zona, lat, lng float64, piso *int) *models.ZonaCercana {
+	var mejor *models.ZonaCercana
+	for i := range zonas {
+		z := &zonas[i]
+		if piso != nil && z.Piso != nil && *z.Piso != *piso {
+			continue
+		}
+		d, ok := DistanciaZona(z, lat, lng)
+		if !ok {
+			continue
+		}
+		c := &models.ZonaCercana{Zona: *z, Dentro: d == 0, DistanciaM: math.Round(d*10) / 10}
+		if mejor == nil || preferible(c, mejor) {
+			mejor = c
+		}
+	}
+	return mejor
+}
+
+func preferible(a, b *models.ZonaCercana) bool {
+	switch {
+	case a.Dentro && b.Dentro:
+		return a.Zona.Tipo.Nivel() > b.Zona.Tipo.Nivel()
+	case a.Dentro != b.Dentro:
+		return a.Dentro
+	}
+	return a.DistanciaM < b.DistanciaM
+}
+
+// enAnillo: ray casting sobre [lng, lat]
+func enAnillo(anillo [][]float64, lat, lng float64) bool {
+	dentro := false
+	for i, j := 0, len(anillo)-1; i < len(anillo); j, i = i, i+1 {
+		xi, yi := anillo[i][0], anillo[i][1]
+		xj, yj := anillo[j][0], anillo[j][1]
+		if (yi > lat) != (yj > lat) && lng < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+			dentro = !dentro
+		}
+	}
+	return dentro
+}
+
+// distanciaBorde proyecta alrededor del punto (equirectangular: sobra
+// precisión a la escala de un recinto) y mide al segmento más cercano
+func distanciaBorde(p models.Poligono, lat, lng float64) float64 {
+	kx := rad(1) * radioTierraM * math.Cos(rad(lat))
+	ky := rad(1) * radioTierraM
+	minimo := math.Inf(1)
+	for _, anillo := range p {
+		for i := 1; i < len(anillo); i++ {
+			ax, ay := (anillo[i-1][0]-lng)*kx, (anillo[i-1][1]-lat)*ky
+			bx, by := (anillo[i][0]-lng)*kx, (anillo[i][1]-lat)*ky
+			minimo = math.Min(minimo, distanciaOrigenSegmento(ax, ay, bx, by))
+		}
+	}
+	return minimo
+}
+
+func distanciaOrigenSegmento(ax, ay, bx, by float64) float64 {
+	dx, dy := bx-ax, by-ay
+	t := 0.0
+	if l := dx*dx + dy*dy; l > 0 {
+		t = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/l))
+	}
+	return math.Hypot(ax+t*dx, ay+t*dy)
+}
+
+func rad(g float64) float64 { return g * math.Pi / 180 }
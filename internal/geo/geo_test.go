@@ -0,0 +1,184 @@
+package geo
+
+import (
+	"math"
+	"testing"
+
+	"github.com/eventpulse/backend/internal/models"
+)
+
+// cuadrado de lado 2*d grados centrado en (lat, lng), en [lng, lat] como GeoJSON
+func cuadrado(lat, lng, d float64) [][]float64 {
+	return [][]float64{
+		{lng - d, lat - d}, {lng + d, lat - d}, {lng + d, lat + d}, {lng - d, lat + d}, {lng - d, lat - d},
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func TestDistanciaM(t *testing.T) {
+	casos := []struct {
+		nombre                 string
+		lat1, lng1, lat2, lng2 float64
+		esperado               float64
+	}{
+		{"mismo punto", 10, 20, 10, 20, 0},
+		{"un grado de latitud", 0, 0, 1, 0, 111195},
+		{"un grado de longitud en el ecuador", 0, 0, 0, 1, 111195},
+		{"un grado de longitud a 60°", 60, 0, 60, 1, 55597},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			if got := DistanciaM(c.lat1, c.lng1, c.lat2, c.lng2); math.Abs(got-c.esperado) > 1 {
+				t.Errorf("DistanciaM = %.1f, esperado %.0f", got, c.esperado)
+			}
+		})
+	}
+}
+
+func TestValidarPoligono(t *testing.T) {
+	casos := []struct {
+		nombre string
+		p      models.Poligono
+		valido bool
+	}{
+		{"cuadrado", models.Poligono{cuadrado(10, 20, 0.001)}, true},
+		{"con hueco", models.Poligono{cuadrado(10, 20, 0.001), cuadrado(10, 20, 0.0002)}, true},
+		{"vacío", models.Poligono{}, false},
+		{"tres posiciones", models.Poligono{{{20, 10}, {20.001, 10}, {20, 10}}}, false},
+		{"sin cerrar", models.Poligono{cuadrado(10, 20, 0.001)[:4]}, false},
+		{"posición sin latitud", models.Poligono{{{20, 10}, {20.001}, {20.001, 10.001}, {20, 10}}}, false},
+		{"latitud fuera de rango", models.Poligono{cuadrado(89.9995, 20, 0.001)}, false},
+		{"longitud fuera de rango", models.Poligono{cuadrado(10, 179.9995, 0.001)}, false},
+		{"hueco inválido", models.Poligono{cuadrado(10, 20, 0.001), {{20, 10}}}, false},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			if err := ValidarPoligono(c.p); (err == nil) != c.valido {
+				t.Errorf("ValidarPoligono = %v, esperado válido=%v", err, c.valido)
+			}
+		})
+	}
+}
+
+func TestContiene(t *testing.T) {
+	conHueco := models.Poligono{cuadrado(10, 20, 0.001), cuadrado(10, 20, 0.0002)}
+	// L: el cuadrante noreste queda fuera
+	ele := models.Poligono{{{0, 0}, {2, 0}, {2, 1}, {1, 1}, {1, 2}, {0, 2}, {0, 0}}}
+	casos := []struct {
+		nombre   string
+		p        models.Poligono
+		lat, lng float64
+		dentro   bool
+	}{
+		{"centro sin hueco", models.Poligono{cuadrado(10, 20, 0.001)}, 10, 20, true},
+		{"dentro del anillo, fuera del hueco", conHueco, 10.0005, 20.0005, true},
+		{"en el hueco", conHueco, 10, 20, false},
+		{"fuera al este", conHueco, 10, 20.002, false},
+		{"fuera al sur", conHueco, 9.998, 20, false},
+		{"brazo de la L", ele, 0.5, 1.5, true},
+		{"hueco de la L", ele, 1.5, 1.5, false},
+		{"sin geometría", nil, 10, 20, false},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			if got := Contiene(c.p, c.lat, c.lng); got != c.dentro {
+				t.Errorf("Contiene(%v, %v) = %v, esperado %v", c.lat, c.lng, got, c.dentro)
+			}
+		})
+	}
+}
+
+func TestDistanciaZona(t *testing.T) {
+	poligono := &models.Zona{Poligono: models.Poligono{cuadrado(10, 20, 0.001)}}
+	// 0.001° de longitud a 10° de latitud
+	alBorde := 0.001 * math.Pi / 180 * radioTierraM * math.Cos(10*math.Pi/180)
+	casos := []struct {
+		nombre   string
+		z        *models.Zona
+		lat, lng float64
+		esperado float64
+		ok       bool
+	}{
+		{"dentro del polígono", poligono, 10, 20, 0, true},
+		{"fuera del polígono", poligono, 10, 20.002, alBorde, true},
+		{"punto con radio, dentro", &models.Zona{Latitud: ptr(10.0), Longitud: ptr(20.0), RadioM: ptr(50.0)}, 10.0003, 20, 0, true},
+		{"punto con radio, fuera", &models.Zona{Latitud: ptr(10.0), Longitud: ptr(20.0), RadioM: ptr(50.0)}, 10.001, 20, 111.195 - 50, true},
+		{"punto con radio por defecto", &models.Zona{Latitud: ptr(10.0), Longitud: ptr(20.0)}, 10.001, 20, 111.195 - RadioPorDefectoM, true},
+		{"el polígono manda sobre el punto", &models.Zona{Latitud: ptr(50.0), Longitud: ptr(50.0), Poligono: poligono.Poligono}, 10, 20, 0, true},
+		{"sin geometría", &models.Zona{}, 10, 20, 0, false},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			got, ok := DistanciaZona(c.z, c.lat, c.lng)
+			if ok != c.ok || math.Abs(got-c.esperado) > 0.5 {
+				t.Errorf("DistanciaZona = (%.2f, %v), esperado (%.2f, %v)", got, ok, c.esperado, c.ok)
+			}
+		})
+	}
+}
+
+func TestCentro(t *testing.T) {
+	lat, lng, ok := Centro(&models.Zona{Poligono: models.Poligono{cuadrado(10, 20, 0.001)}})
+	if !ok || math.Abs(lat-10) > 1e-9 || math.Abs(lng-20) > 1e-9 {
+		t.Errorf("Centro del cuadrado = (%v, %v, %v), esperado (10, 20, true)", lat, lng, ok)
+	}
+	lat, lng, ok = Centro(&models.Zona{Latitud: ptr(1.0), Longitud: ptr(2.0), Poligono: models.Poligono{cuadrado(10, 20, 0.001)}})
+	if !ok || lat != 1 || lng != 2 {
+		t.Errorf("Centro con punto = (%v, %v, %v), esperado (1, 2, true)", lat, lng, ok)
+	}
+	if _, _, ok := Centro(&models.Zona{}); ok {
+		t.Error("Centro sin geometría debería dar ok=false")
+	}
+}
+
+func TestCercana(t *testing.T) {
+	sector := models.Zona{ID: "sector", Tipo: models.ZonaSector, Poligono: models.Poligono{cuadrado(10, 20, 0.01)}}
+	area := models.Zona{ID: "area", Tipo: models.ZonaArea, Poligono: models.Poligono{cuadrado(10, 20, 0.001)}}
+	punto := models.Zona{ID: "punto", Tipo: models.ZonaPunto, Latitud: ptr(10.0), Longitud: ptr(20.0), RadioM: ptr(10.0)}
+	lejos := models.Zona{ID: "lejos", Tipo: models.ZonaArea, Latitud: ptr(10.1), Longitud: ptr(20.0)}
+	cerca := models.Zona{ID: "cerca", Tipo: models.ZonaArea, Latitud: ptr(10.05), Longitud: ptr(20.0)}
+	piso2 := models.Zona{ID: "piso2", Tipo: models.ZonaPunto, Latitud: ptr(10.0), Longitud: ptr(20.0), Piso: ptr(2)}
+	sinGeometria := models.Zona{ID: "sin", Tipo: models.ZonaPunto}
+
+	casos := []struct {
+		nombre   string
+		zonas    []models.Zona
+		lat, lng float64
+		piso     *int
+		esperado string // "" = nil
+		dentro   bool
+	}{
+		{"la más específica que lo contiene", []models.Zona{sector, area, punto}, 10, 20, nil, "punto", true},
+		{"el orden no importa", []models.Zona{punto, area, sector}, 10, 20, nil, "punto", true},
+		{"fuera del punto, dentro del área", []models.Zona{sector, area, punto}, 10.0005, 20, nil, "area", true},
+		{"dentro gana a cerca", []models.Zona{cerca, sector}, 10.005, 20, nil, "sector", true},
+		{"ninguna lo contiene: la más cercana", []models.Zona{lejos, cerca}, 10.2, 20, nil, "lejos", false},
+		{"sin piso vale para todos", []models.Zona{area, piso2}, 10, 20, ptr(1), "area", true},
+		{"zona del mismo piso", []models.Zona{area, piso2}, 10, 20, ptr(2), "piso2", true},
+		{"sin filtro de piso cuenta todas", []models.Zona{area, piso2}, 10, 20, nil, "piso2", true},
+		{"se ignoran las zonas sin geometría", []models.Zona{sinGeometria, cerca}, 10, 20, nil, "cerca", false},
+		{"ninguna con geometría", []models.Zona{sinGeometria}, 10, 20, nil, "", false},
+		{"sin zonas", nil, 10, 20, nil, "", false},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			got := Cercana(c.zonas, c.lat, c.lng, c.piso)
+			if c.esperado == "" {
+				if got != nil {
+					t.Fatalf("Cercana = %q, esperado nil", got.Zona.ID)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatalf("Cercana = nil, esperado %q", c.esperado)
+			}
+			if got.Zona.ID != c.esperado || got.Dentro != c.dentro {
+				t.Errorf("Cercana = %q dentro=%v, esperado %q dentro=%v", got.Zona.ID, got.Dentro, c.esperado, c.dentro)
+			}
+			if got.Dentro != (got.DistanciaM == 0) {
+				t.Errorf("dentro=%v con distancia_m=%v", got.Dentro, got.DistanciaM)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/eventpulse/backend/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// ─── Analítica de incidencias ─────────────────────────────────────────────────
+
+type AnaliticaHandler struct {
+	repo *repository.AnaliticaRepo
+}
+
+func NewAnaliticaHandler(r *repository.AnaliticaRepo) *AnaliticaHandler {
+	return &AnaliticaHandler{repo: r}
+}
+
+var reUUID = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// GET /api/v1/analitica/incidencias/zonas-horas  [solo admin]
+// Mapa de calor zona × hora del día. Con ?formato=geojson devuelve una
+// FeatureCollection de las zonas (Polygon, Point o sin geometría) con los
+// conteos en properties.
+func (h *AnaliticaHandler) ZonasHoras(c *gin.Context) {
+	f, ok := filtroAnalitica(c)
+	if !ok {
+		return
+	}
+	formato := c.DefaultQuery("formato", "json")
+	if formato != "json" && formato != "geojson" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "formato admite: json, geojson"})
+		return
+	}
+	res, zonas, err := h.repo.ZonasHoras(c.Request.Context(), f)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error calculando la analítica"})
+		return
+	}
+	if formato == "json" {
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	porID := make(map[string]*models.Zona, len(zonas))
+	for i := range zonas {
+		porID[zonas[i].ID] = &zonas[i]
+	}
+	col := models.ColeccionGeoJSON{Type: "FeatureCollection", Features: []models.FeatureGeoJSON{}}
+	for _, zh := range res.Zonas {
+		var geometria *models.GeometriaGeoJSON
+		if z, ok := porID[zh.ZonaID]; ok {
+			geometria = geometriaZona(z)
+		}
+		col.Features = append(col.Features, models.FeatureGeoJSON{
+			Type:     "Feature",
+			ID:       zh.ZonaID,
+			Geometry: geometria,
+			Properties: map[string]interface{}{
+				"nombre":       zh.ZonaNombre,
+				"total":        zh.Total,
+				"por_hora":     zh.PorHora,
+				"hora_pico":    zh.HoraPico,
+				"zona_horaria": res.ZonaHoraria,
+			},
+		})
+	}
+	c.JSON(http.StatusOK, col)
+}
+
+// GET /api/v1/analitica/incidencias/tipos?intervalo=hora|dia  [solo admin]
+// Una serie por tipo; solo aparecen los intervalos con incidencias
+func (h *AnaliticaHandler) Tipos(c *gin.Context) {
+	f, ok := filtroAnalitica(c)
+	if !ok {
+		return
+	}
+	intervalo := models.IntervaloSerie(c.DefaultQuery("intervalo", string(models.IntervaloHora)))
+	if !intervalo.EsValido() {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "intervalo admite: hora, dia"})
+		return
+	}
+	res, err := h.repo.Tipos(c.Request.Context(), f, intervalo)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error calculando la analítica"})
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// GET /api/v1/analitica/incidencias/dias  [solo admin]
+// Incidencias por día del evento (1 = el de inicio), de cada evento y sumadas
+func (h *AnaliticaHandler) Dias(c *gin.Context) {
+	f, ok := filtroAnalitica(c)
+	if !ok {
+		return
+	}
+	res, err := h.repo.Dias(c.Request.Context(), f)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error calculando la analítica"})
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// filtroAnalitica lee ?eventos=id,id, ?desde y ?hasta (RFC 3339), ?tipo y
+// ?tz (IANA, por defecto UTC). Responde 400 si algo no es válido.
+func filtroAnalitica(c *gin.Context) (models.FiltroAnalitica, bool) {
+	f := models.FiltroAnalitica{ZonaHoraria: c.DefaultQuery("tz", "UTC")}
+	for _, id := range strings.Split(c.Query("eventos"), ",") {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		if !reUUID.MatchString(id) {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "eventos debe ser una lista de ids separados por coma"})
+			return f, false
+		}
+		f.Eventos = append(f.Eventos, id)
+	}
+	for param, destino := range map[string]**time.Time{"desde": &f.Desde, "hasta": &f.Hasta} {
+		if v := c.Query(param); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: param + " debe ser RFC 3339"})
+				return f, false
+			}
+			*destino = &t
+		}
+	}
+	if f.Desde != nil && f.Hasta != nil && !f.Desde.Before(*f.Hasta) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "desde debe ser anterior a hasta"})
+		return f, false
+	}
+	if tipo := models.TipoIncidencia(c.Query("tipo")); tipo != "" {
+		if !tipo.EsValido() && tipo != models.TipoSOS {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "tipo de incidencia no válido"})
+			return f, false
+		}
+		f.Tipo = string(tipo)
+	}
+	if _, err := time.LoadLocation(f.ZonaHoraria); err != nil || f.ZonaHoraria == "Local" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Zona horaria inválida (usar IANA, ej: America/Mexico_City)"})
+		return f, false
+	}
+	return f, true
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/eventpulse/backend/internal/middleware"
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/eventpulse/backend/internal/notificaciones"
+	"github.com/eventpulse/backend/internal/repository"
+	"github.com/eventpulse/backend/internal/ws"
+	"github.com/gin-gonic/gin"
+)
+
+// ─── Anuncio ──────────────────────────────────────────────────────────────────
+
+type AnuncioHandler struct {
+	repo           *repository.AnuncioRepo
+	eventoRepo     *repository.EventoRepo
+	hub            *ws.Hub
+	notificador    *notificaciones.Notificador
+	reenvioDefecto int
+}
+
+func NewAnuncioHandler(r *repository.AnuncioRepo, e *repository.EventoRepo, h *ws.Hub, n *notificaciones.Notificador, reenvioDefecto int) *AnuncioHandler {
+	return &AnuncioHandler{repo: r, eventoRepo: e, hub: h, notificador: n, reenvioDefecto: reenvioDefecto}
+}
+
+// POST /api/v1/anuncios  [admin o supervisor]
+func (h *AnuncioHandler) Crear(c *gin.Context) {
+	var req models.CrearAnuncioRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	switch req.Destino {
+	case models.DestinoTodos:
+	case models.DestinoRol:
+		if req.Rol == nil || !req.Rol.EsValido() || *req.Rol == models.RolAdmin {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Rol inválido. Válidos: aseo, guardia, medico, logistica, supervisor"})
+			return
+		}
+	case models.DestinoZona:
+		if req.ZonaID == "" {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Se requiere zona_id"})
+			return
+		}
+	default:
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Destino inválido. Válidos: todos, rol, zona"})
+		return
+	}
+	reenvio := req.ReenviarCadaSegundos
+	if reenvio == 0 {
+		reenvio = h.reenvioDefecto
+	}
+	if reenvio < 10 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "reenviar_cada_segundos debe ser al menos 10"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	evento, err := h.eventoRepo.ObtenerActivo(ctx)
+	if err != nil || evento == nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "No hay evento activo"})
+		return
+	}
+	anuncio, destinatarios, err := h.repo.Crear(ctx, &req, evento.ID, middleware.GetUsuarioID(c), reenvio)
+	if errors.Is(err, repository.ErrSinDestinatarios) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Nadie recibe este anuncio con ese destino"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error creando anuncio"})
+		return
+	}
+
+	go func() {
+		if err := h.hub.PublicarAUsuarios(context.Background(), destinatarios, models.EventoWS{
+			Tipo:     models.WSAnuncioNuevo,
+			Payload:  anuncio,
+			EventoID: anuncio.EventoID,
+		}); err != nil {
+			log.Println("❌ Error publicando anuncio en Redis:", err)
+		}
+	}()
+	// Fuera de la app también: quien tiene la app en segundo plano no ve el WS
+	h.notificador.Notificar(destinatarios, models.Notificacion{
+		Tipo:    models.NotifAnuncio,
+		Titulo:  anuncio.Titulo,
+		Cuerpo:  anuncio.Contenido,
+		Urgente: true,
+		Ref:     anuncio.ID,
+	})
+
+	c.JSON(http.StatusCreated, anuncio)
+}
+
+// GET /api/v1/anuncios  (admin/supervisor: todos | trabajador: los suyos)
+func (h *AnuncioHandler) Listar(c *gin.Context) {
+	ctx := c.Request.Context()
+	evento, err := h.eventoRepo.ObtenerActivo(ctx)
+	if err != nil || evento == nil {
+		c.JSON(http.StatusOK, []models.Anuncio{})
+		return
+	}
+	var lista []models.Anuncio
+	if rol := middleware.GetRol(c); rol == models.RolAdmin || rol == models.RolSupervisor {
+		lista, err = h.repo.Listar(ctx, evento.ID)
+	} else {
+		lista, err = h.repo.ListarDeUsuario(ctx, evento.ID, middleware.GetUsuarioID(c))
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error listando anuncios"})
+		return
+	}
+	c.JSON(http.StatusOK, lista)
+}
+
+// GET /api/v1/anuncios/:id/estado  [admin o supervisor] tablero de confirmaciones
+func (h *AnuncioHandler) Estado(c *gin.Context) {
+	ctx := c.Request.Context()
+	anuncio, err := h.repo.ObtenerPorID(ctx, c.Param("id"))
+	if err != nil || anuncio == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Anuncio no encontrado"})
+		return
+	}
+	destinatarios, err := h.repo.ListarDestinatarios(ctx, anuncio.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error listando destinatarios"})
+		return
+	}
+	estado := models.EstadoAnuncio{
+		Anuncio:     *anuncio,
+		Confirmados: []models.DestinatarioAnuncio{},
+		Pendientes:  []models.DestinatarioAnuncio{},
+	}
+	for _, d := range destinatarios {
+		if d.ConfirmadoEn != nil {
+			estado.Confirmados = append(estado.Confirmados, d)
+		} else {
+			estado.Pendientes = append(estado.Pendientes, d)
+		}
+	}
+	c.JSON(http.StatusOK, estado)
+}
+
+// POST /api/v1/anuncios/:id/confirmar  (destinatario)
+func (h *AnuncioHandler) Confirmar(c *gin.Context) {
+	ctx := c.Request.Context()
+	usuarioID := middleware.GetUsuarioID(c)
+	destinatario, err := h.repo.Confirmar(ctx, c.Param("id"), usuarioID)
+	if errors.Is(err, repository.ErrNoEncontrado) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "No eres destinatario de este anuncio"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error confirmando anuncio"})
+		return
+	}
+	anuncio, err := h.repo.ObtenerPorID(ctx, c.Param("id"))
+	if err != nil || anuncio == nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error confirmando anuncio"})
+		return
+	}
+
+	// El tablero se actualiza en vivo: avisar al autor y a los admins
+	confirmacion := models.ConfirmacionAnuncio{
+		AnuncioID:    anuncio.ID,
+		UsuarioID:    usuarioID,
+		Nombre:       destinatario.Nombre,
+		ConfirmadoEn: *destinatario.ConfirmadoEn,
+		Confirmados:  anuncio.Confirmados,
+		Total:        anuncio.Destinatarios,
+	}
+	go func() {
+		ctx := context.Background()
+		ids, err := h.repo.IDsSupervision(ctx, anuncio)
+		if err == nil {
+			err = h.hub.PublicarAUsuarios(ctx, ids, models.EventoWS{
+				Tipo:     models.WSAnuncioConfirmado,
+				Payload:  confirmacion,
+				EventoID: anuncio.EventoID,
+			})
+		}
+		if err != nil {
+			log.Println("❌ Error publicando confirmación de anuncio en Redis:", err)
+		}
+	}()
+
+	c.JSON(http.StatusOK, confirmacion)
+}
+
+// PATCH /api/v1/anuncios/:id/cerrar  [admin o supervisor] detiene los reenvíos
+func (h *AnuncioHandler) Cerrar(c *gin.Context) {
+	ctx := c.Request.Context()
+	if err := h.repo.Cerrar(ctx, c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Anuncio no encontrado o ya cerrado"})
+		return
+	}
+	anuncio, err := h.repo.ObtenerPorID(ctx, c.Param("id"))
+	if err != nil || anuncio == nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error cerrando anuncio"})
+		return
+	}
+	go h.hub.Publicar(context.Background(), anuncio.EventoID, models.EventoWS{
+		Tipo:     models.WSAnuncioCerrado,
+		Payload:  anuncio,
+		EventoID: anuncio.EventoID,
+	})
+	c.JSON(http.StatusOK, anuncio)
+}
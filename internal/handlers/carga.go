@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/eventpulse/backend/internal/carga"
+	"github.com/eventpulse/backend/internal/middleware"
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/eventpulse/backend/internal/reportes"
+	"github.com/eventpulse/backend/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// ─── Carga y desempeño del personal ───────────────────────────────────────────
+
+type CargaHandler struct {
+	medidor    *carga.Medidor
+	cargaRepo  *repository.CargaRepo
+	eventoRepo *repository.EventoRepo
+}
+
+func NewCargaHandler(m *carga.Medidor, r *repository.CargaRepo, e *repository.EventoRepo) *CargaHandler {
+	return &CargaHandler{medidor: m, cargaRepo: r, eventoRepo: e}
+}
+
+// GET /api/v1/eventos/:id/carga?rol=guardia  [admin o supervisor del evento]
+// Lo abierto de cada uno ahora, del menos al más cargado. Por WS llega como
+// la sección "carga" del tablero.
+func (h *CargaHandler) EnVivo(c *gin.Context) {
+	ev, ok := h.eventoMando(c)
+	if !ok {
+		return
+	}
+	rol := models.Rol(c.Query("rol"))
+	if rol != "" && !rol.EsValido() {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "rol no válido"})
+		return
+	}
+	lista, err := h.medidor.EnVivo(c.Request.Context(), ev.ID, rol)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error calculando la carga"})
+		return
+	}
+	res := models.CargaEvento{EventoID: ev.ID, Umbral: h.medidor.Umbral(), Personal: lista, GeneradoEn: time.Now()}
+	for _, t := range lista {
+		switch t.Nivel {
+		case models.CargaLibre:
+			res.Libres++
+		case models.CargaOcupado:
+			res.Ocupados++
+		case models.CargaSobrecargado:
+			res.Sobrecargados++
+		}
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// GET /api/v1/eventos/:id/desempeno?formato=csv  [admin o supervisor del evento]
+// Por persona: abierto, cerrado, tiempos de aceptación y resolución, horas de
+// turno y horas activo. Con formato=csv se descarga como archivo.
+func (h *CargaHandler) Desempeno(c *gin.Context) {
+	ev, ok := h.eventoMando(c)
+	if !ok {
+		return
+	}
+	formato := c.DefaultQuery("formato", "json")
+	if formato != "json" && formato != "csv" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "formato admite: json, csv"})
+		return
+	}
+	lista, err := h.cargaRepo.Desempeno(c.Request.Context(), ev.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error calculando el desempeño"})
+		return
+	}
+	if formato == "json" {
+		c.JSON(http.StatusOK, models.ReporteDesempeno{EventoID: ev.ID, Personal: lista, GeneradoEn: time.Now()})
+		return
+	}
+	data, err := reportes.DesempenoCSV(lista)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error armando el CSV"})
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+reportes.NombreDesempeno(ev.Nombre)+`"`)
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", data)
+}
+
+// eventoMando: el evento de la ruta, si quien pide es admin o supervisor de él
+func (h *CargaHandler) eventoMando(c *gin.Context) (*models.Evento, bool) {
+	eventoID := c.Param("id")
+	if middleware.GetRol(c) != models.RolAdmin && middleware.GetEventoID(c) != eventoID {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{Error: "Solo puedes ver el personal de tu evento"})
+		return nil, false
+	}
+	ev, err := h.eventoRepo.ObtenerPorID(c.Request.Context(), eventoID)
+	if err != nil || ev == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Evento no encontrado"})
+		return nil, false
+	}
+	return ev, true
+}
@@ -0,0 +1,833 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/eventpulse/backend/internal/middleware"
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/eventpulse/backend/internal/repository"
+	"github.com/eventpulse/backend/internal/ws"
+	"github.com/gin-gonic/gin"
+)
+
+// ─── Chat ─────────────────────────────────────────────────────────────────────
+
+type ChatHandler struct {
+	mensajeRepo      *repository.MensajeRepo
+	conversacionRepo *repository.ConversacionRepo
+	moderacionRepo   *repository.ModeracionRepo
+	usuarioRepo      *repository.UsuarioRepo
+	eventoRepo       *repository.EventoRepo
+	hub              *ws.Hub
+
+	// último "escribiendo" reenviado por usuario+conversación, para no
+	// inundar a los miembros si el cliente lo manda en cada tecla
+	ultimoEscribiendo sync.Map
+}
+
+// Intervalo mínimo entre dos "escribiendo" del mismo usuario en la misma conversación
+const intervaloEscribiendo = 2 * time.Second
+
+func NewChatHandler(m *repository.MensajeRepo, cv *repository.ConversacionRepo, mod *repository.ModeracionRepo, u *repository.UsuarioRepo, e *repository.EventoRepo, h *ws.Hub) *ChatHandler {
+	return &ChatHandler{mensajeRepo: m, conversacionRepo: cv, moderacionRepo: mod, usuarioRepo: u, eventoRepo: e, hub: h}
+}
+
+// eventoID devuelve el evento del token o, para el admin, el evento activo
+func (h *ChatHandler) eventoID(c *gin.Context) string {
+	eventoID := middleware.GetEventoID(c)
+	if eventoID == "" {
+		if ev, _ := h.eventoRepo.ObtenerActivo(c.Request.Context()); ev != nil {
+			eventoID = ev.ID
+		}
+	}
+	return eventoID
+}
+
+// GET /api/v1/chat/historial  (sala general del evento)
+func (h *ChatHandler) Historial(c *gin.Context) {
+	eventoID := h.eventoID(c)
+	if eventoID == "" {
+		c.JSON(http.StatusOK, []models.Mensaje{})
+		return
+	}
+	conv, err := h.conversacionRepo.ObtenerGeneral(c.Request.Context(), eventoID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error obteniendo historial"})
+		return
+	}
+	h.responderHistorial(c, conv)
+}
+
+// POST /api/v1/chat/mensaje  (admin y trabajadores, sala general)
+func (h *ChatHandler) Enviar(c *gin.Context) {
+	var req models.EnviarMensajeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	// Admin puede enviar aunque no tenga evento_id en token, usar el activo
+	eventoID := h.eventoID(c)
+	if eventoID == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "No hay evento activo"})
+		return
+	}
+	conv, err := h.conversacionRepo.ObtenerGeneral(c.Request.Context(), eventoID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error enviando mensaje"})
+		return
+	}
+	h.enviar(c, conv, req.Contenido)
+}
+
+// GET /api/v1/chat/conversaciones
+func (h *ChatHandler) ListarConversaciones(c *gin.Context) {
+	eventoID := h.eventoID(c)
+	if eventoID == "" {
+		c.JSON(http.StatusOK, []models.Conversacion{})
+		return
+	}
+	lista, err := h.conversacionRepo.ListarDeUsuario(c.Request.Context(), eventoID, middleware.GetUsuarioID(c), middleware.GetRol(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error listando conversaciones"})
+		return
+	}
+	c.JSON(http.StatusOK, lista)
+}
+
+// POST /api/v1/chat/conversaciones  (grupo: cualquiera | zona: admin o supervisor)
+func (h *ChatHandler) CrearConversacion(c *gin.Context) {
+	var req models.CrearConversacionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	switch req.Tipo {
+	case models.ConversacionGrupo:
+		if strings.TrimSpace(req.Nombre) == "" {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "El grupo necesita un nombre"})
+			return
+		}
+	case models.ConversacionZona:
+		if rol := middleware.GetRol(c); rol != models.RolAdmin && rol != models.RolSupervisor {
+			c.JSON(http.StatusForbidden, models.ErrorResponse{Error: "Solo admin o supervisor pueden crear canales de zona"})
+			return
+		}
+		if req.ZonaID == "" {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Se requiere zona_id"})
+			return
+		}
+		if req.Nombre == "" {
+			req.Nombre = req.ZonaID
+		}
+	default:
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Tipo inválido. Válidos: grupo, zona"})
+		return
+	}
+	eventoID := h.eventoID(c)
+	if eventoID == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "No hay evento activo"})
+		return
+	}
+	ctx := c.Request.Context()
+	if req.Tipo == models.ConversacionZona {
+		existe, err := h.conversacionRepo.ZonaDelEvento(ctx, req.ZonaID, eventoID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error verificando la zona"})
+			return
+		}
+		if !existe {
+			c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{Error: "La zona no existe en este evento"})
+			return
+		}
+	}
+	if !h.miembrosDelEvento(c, eventoID, req.Miembros) {
+		return
+	}
+	conv, err := h.conversacionRepo.Crear(ctx, eventoID, middleware.GetUsuarioID(c), &req)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate") || strings.Contains(err.Error(), "unique") {
+			c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Ya existe un canal para esa zona"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error creando conversación"})
+		return
+	}
+	c.JSON(http.StatusCreated, conv)
+}
+
+// POST /api/v1/chat/directos  → abre (o recupera) el 1:1 con usuario_id
+func (h *ChatHandler) AbrirDirecto(c *gin.Context) {
+	var req models.AbrirDirectoRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	ctx := c.Request.Context()
+	usuarioID := middleware.GetUsuarioID(c)
+	if req.UsuarioID == usuarioID {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "No puedes abrir un chat contigo mismo"})
+		return
+	}
+	eventoID := h.eventoID(c)
+	otro, err := h.usuarioRepo.BuscarPorID(ctx, req.UsuarioID)
+	if err != nil || otro == nil || !otro.Activo ||
+		(otro.Rol != models.RolAdmin && (otro.EventoID == nil || *otro.EventoID != eventoID)) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Usuario no encontrado en este evento"})
+		return
+	}
+	conv, err := h.conversacionRepo.AbrirDirecto(ctx, eventoID, usuarioID, otro.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error abriendo conversación"})
+		return
+	}
+	conv.Nombre = otro.Nombre
+	c.JSON(http.StatusOK, conv)
+}
+
+// POST /api/v1/chat/conversaciones/:id/miembros  (creador o admin; solo grupo y zona)
+func (h *ChatHandler) AgregarMiembros(c *gin.Context) {
+	var req models.AgregarMiembrosRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	conv, ok := h.cargarConversacion(c)
+	if !ok {
+		return
+	}
+	if conv.Tipo != models.ConversacionGrupo && conv.Tipo != models.ConversacionZona {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Solo se pueden agregar miembros a grupos y canales de zona"})
+		return
+	}
+	usuarioID := middleware.GetUsuarioID(c)
+	if middleware.GetRol(c) != models.RolAdmin && (conv.CreadaPor == nil || *conv.CreadaPor != usuarioID) {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{Error: "Solo quien creó la conversación puede agregar miembros"})
+		return
+	}
+	if !h.miembrosDelEvento(c, conv.EventoID, req.UsuarioIDs) {
+		return
+	}
+	if err := h.conversacionRepo.AgregarMiembros(c.Request.Context(), conv.ID, req.UsuarioIDs); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Error agregando miembros"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"mensaje": "Miembros agregados"})
+}
+
+// miembrosDelEvento verifica que todos los ids sean usuarios activos del evento
+// de la conversación. Si no, ya respondió 422 con los rechazados y devuelve false.
+func (h *ChatHandler) miembrosDelEvento(c *gin.Context, eventoID string, ids []string) bool {
+	ajenos, err := h.conversacionRepo.AjenosAlEvento(c.Request.Context(), eventoID, ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error verificando miembros"})
+		return false
+	}
+	if len(ajenos) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Hay usuarios que no pertenecen a este evento", "usuarios": ajenos})
+		return false
+	}
+	return true
+}
+
+// GET /api/v1/chat/conversaciones/:id/mensajes?limite=50
+func (h *ChatHandler) HistorialConversacion(c *gin.Context) {
+	conv, ok := h.cargarConversacion(c)
+	if !ok {
+		return
+	}
+	h.responderHistorial(c, conv)
+}
+
+// POST /api/v1/chat/conversaciones/:id/mensajes
+func (h *ChatHandler) EnviarAConversacion(c *gin.Context) {
+	var req models.EnviarMensajeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	conv, ok := h.cargarConversacion(c)
+	if !ok {
+		return
+	}
+	h.enviar(c, conv, req.Contenido)
+}
+
+// cargarConversacion lee :id y verifica que el usuario pertenezca a ella.
+// Si no, ya respondió 404/403 y devuelve ok=false.
+func (h *ChatHandler) cargarConversacion(c *gin.Context) (*models.Conversacion, bool) {
+	ctx := c.Request.Context()
+	conv, err := h.conversacionRepo.ObtenerPorID(ctx, c.Param("id"))
+	if err != nil || conv == nil || conv.EventoID != h.eventoID(c) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Conversación no encontrada"})
+		return nil, false
+	}
+	esMiembro, err := h.conversacionRepo.EsMiembro(ctx, conv, middleware.GetUsuarioID(c), middleware.GetRol(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error verificando membresía"})
+		return nil, false
+	}
+	if !esMiembro {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{Error: "No eres miembro de esta conversación"})
+		return nil, false
+	}
+	return conv, true
+}
+
+func (h *ChatHandler) responderHistorial(c *gin.Context, conv *models.Conversacion) {
+	limite, _ := strconv.Atoi(c.DefaultQuery("limite", "50"))
+	if limite <= 0 || limite > 200 {
+		limite = 50
+	}
+	msgs, err := h.mensajeRepo.Listar(c.Request.Context(), conv.ID, limite)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error obteniendo historial"})
+		return
+	}
+	// Abrir el historial cuenta como haber leído hasta el último mensaje
+	if len(msgs) > 0 {
+		h.marcarLeido(c, conv, msgs[len(msgs)-1].ID)
+	}
+	c.JSON(http.StatusOK, msgs)
+}
+
+// POST /api/v1/chat/conversaciones/:id/leido  { "mensaje_id": "uuid" }
+func (h *ChatHandler) MarcarLeido(c *gin.Context) {
+	var req models.MarcarLeidoRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	conv, ok := h.cargarConversacion(c)
+	if !ok {
+		return
+	}
+	h.marcarLeido(c, conv, req.MensajeID)
+	c.JSON(http.StatusOK, gin.H{"mensaje": "Leído"})
+}
+
+// GET /api/v1/chat/conversaciones/:id/mensajes/:mensajeId/lecturas
+func (h *ChatHandler) Lecturas(c *gin.Context) {
+	conv, ok := h.cargarConversacion(c)
+	if !ok {
+		return
+	}
+	lista, err := h.conversacionRepo.ListarLecturas(c.Request.Context(), conv.ID, c.Param("mensajeId"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error listando lecturas"})
+		return
+	}
+	c.JSON(http.StatusOK, lista)
+}
+
+// GET /api/v1/chat/no-leidos
+func (h *ChatHandler) NoLeidos(c *gin.Context) {
+	resp, err := h.conversacionRepo.ContarNoLeidos(c.Request.Context(), h.eventoID(c), middleware.GetUsuarioID(c), middleware.GetRol(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error contando no leídos"})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// marcarLeido avanza el cursor y, si avanzó, avisa a los miembros con WSMensajeLeido
+func (h *ChatHandler) marcarLeido(c *gin.Context, conv *models.Conversacion, mensajeID string) {
+	lectura, err := h.conversacionRepo.MarcarLeido(c.Request.Context(), conv.ID, middleware.GetUsuarioID(c), mensajeID)
+	if err != nil {
+		log.Println("❌ Error marcando conversación como leída:", err)
+		return
+	}
+	if lectura != nil {
+		h.publicar(conv, models.EventoWS{Tipo: models.WSMensajeLeido, Payload: lectura, EventoID: conv.EventoID})
+	}
+}
+
+// Escribiendo es el manejador del comando WS "escribiendo":
+//
+//	{"tipo": "escribiendo", "payload": {"conversacion_id": "uuid", "escribiendo": true}}
+//
+// Se reenvía a los miembros de la conversación y no se guarda en Postgres.
+func (h *ChatHandler) Escribiendo(cl *ws.Cliente, payload json.RawMessage) {
+	var in models.Escribiendo
+	if err := json.Unmarshal(payload, &in); err != nil || in.ConversacionID == "" {
+		return
+	}
+	clave := cl.UsuarioID + ":" + in.ConversacionID
+	ahora := time.Now()
+	if in.Escribiendo {
+		if prev, ok := h.ultimoEscribiendo.Load(clave); ok && ahora.Sub(prev.(time.Time)) < intervaloEscribiendo {
+			return
+		}
+		h.ultimoEscribiendo.Store(clave, ahora)
+		// Pasado el intervalo la entrada ya no frena nada: se borra, salvo que
+		// otro "escribiendo" la haya renovado
+		time.AfterFunc(intervaloEscribiendo, func() { h.ultimoEscribiendo.CompareAndDelete(clave, ahora) })
+	} else {
+		h.ultimoEscribiendo.Delete(clave)
+	}
+
+	ctx := context.Background()
+	conv, err := h.conversacionRepo.ObtenerPorID(ctx, in.ConversacionID)
+	if err != nil || conv == nil || conv.EventoID != cl.EventoID {
+		return
+	}
+	if ok, err := h.conversacionRepo.EsMiembro(ctx, conv, cl.UsuarioID, cl.Rol); err != nil || !ok {
+		return
+	}
+	in.UsuarioID = cl.UsuarioID
+	h.publicar(conv, models.EventoWS{Tipo: models.WSEscribiendo, Payload: in, EventoID: conv.EventoID})
+}
+
+func (h *ChatHandler) enviar(c *gin.Context, conv *models.Conversacion, contenido string) {
+	contenido, ok := h.prepararContenido(c, conv.EventoID, contenido)
+	if !ok {
+		return
+	}
+	msg, err := h.mensajeRepo.Crear(c.Request.Context(), conv.EventoID, conv.ID, middleware.GetUsuarioID(c), contenido, extraerMenciones(contenido))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error enviando mensaje"})
+		return
+	}
+	h.publicar(conv, models.EventoWS{Tipo: models.WSMensajeNuevo, Payload: msg, EventoID: conv.EventoID})
+	h.notificarMenciones(conv, msg, nil)
+	c.JSON(http.StatusCreated, msg)
+}
+
+var (
+	reMencion    = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_.@])@([\p{L}\p{N}_.\-]{2,50})`)
+	reReferencia = regexp.MustCompile(`(?i)(?:^|[^\p{L}\p{N}_])#(inc|incidencia|tarea)-([0-9a-f\-]{6,36})`)
+)
+
+// extraerMenciones busca @usuario, @rol, #inc-<id> y #tarea-<id> en el texto.
+// Los ids pueden abreviarse a sus primeros 6+ caracteres. No consulta la base:
+// la resolución la hace el repositorio al guardar.
+func extraerMenciones(contenido string) []models.CandidatoMencion {
+	var lista []models.CandidatoMencion
+	vistos := map[string]bool{}
+	agregar := func(tipo models.TipoMencion, clave, texto string) {
+		if vistos[string(tipo)+clave] {
+			return
+		}
+		vistos[string(tipo)+clave] = true
+		lista = append(lista, models.CandidatoMencion{Tipo: tipo, Clave: clave, Texto: texto})
+	}
+
+	for _, m := range reMencion.FindAllStringSubmatch(contenido, -1) {
+		nombre := strings.TrimRight(m[1], ".-") // "@ana." al final de la oración
+		if len([]rune(nombre)) < 2 {
+			continue
+		}
+		clave := strings.ToLower(nombre)
+		if models.Rol(clave).EsValido() {
+			agregar(models.MencionRol, clave, "@"+nombre)
+		} else {
+			agregar(models.MencionUsuario, clave, "@"+nombre)
+		}
+	}
+	for _, m := range reReferencia.FindAllStringSubmatch(contenido, -1) {
+		prefijo := strings.ToLower(strings.TrimRight(m[2], "-"))
+		if len(prefijo) < 6 {
+			continue
+		}
+		tipo := models.MencionTarea
+		if strings.ToLower(m[1]) != "tarea" {
+			tipo = models.MencionIncidencia
+		}
+		agregar(tipo, prefijo, "#"+m[1]+"-"+m[2])
+	}
+	return lista
+}
+
+// notificarMenciones avisa por WS a cada usuario mencionado (directamente o
+// por rol) que pueda ver la conversación. En una edición, yaAvisados trae a
+// quienes alcanzaba la versión anterior para no volver a avisarles.
+func (h *ChatHandler) notificarMenciones(conv *models.Conversacion, msg *models.Mensaje, yaAvisados map[string]bool) {
+	if len(msg.Menciones) == 0 {
+		return
+	}
+	go func() {
+		ctx := context.Background()
+		candidatos, err := h.mensajeRepo.DestinatariosMencion(ctx, msg.ID)
+		if err != nil {
+			log.Printf("❌ Error resolviendo menciones de %s: %v", msg.ID, err)
+			return
+		}
+
+		var ids []string
+		for _, u := range candidatos {
+			if yaAvisados[u.ID] {
+				continue
+			}
+			if ok, err := h.conversacionRepo.EsMiembro(ctx, conv, u.ID, u.Rol); err != nil || !ok {
+				continue
+			}
+			ids = append(ids, u.ID)
+		}
+		if len(ids) == 0 {
+			return
+		}
+		evento := models.EventoWS{Tipo: models.WSMencion, Payload: msg, EventoID: conv.EventoID}
+		if err := h.hub.PublicarAUsuarios(ctx, ids, evento); err != nil {
+			log.Printf("❌ Error publicando %s en Redis: %v", evento.Tipo, err)
+		}
+	}()
+}
+
+// prepararContenido verifica que el autor no esté silenciado y aplica los
+// filtros de palabras del evento. Si el mensaje no puede enviarse ya respondió
+// y devuelve ok=false.
+func (h *ChatHandler) prepararContenido(c *gin.Context, eventoID, contenido string) (string, bool) {
+	ctx := c.Request.Context()
+	silencio, err := h.moderacionRepo.SilencioActivo(ctx, eventoID, middleware.GetUsuarioID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error enviando mensaje"})
+		return "", false
+	}
+	if silencio != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Estás silenciado en el chat", "hasta": silencio.Hasta})
+		return "", false
+	}
+	filtros, err := h.moderacionRepo.ListarFiltros(ctx, eventoID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error enviando mensaje"})
+		return "", false
+	}
+	contenido, bloqueo := aplicarFiltros(contenido, filtros)
+	if bloqueo != nil {
+		c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{Error: "El mensaje contiene una palabra no permitida"})
+		return "", false
+	}
+	return contenido, true
+}
+
+// aplicarFiltros censura las palabras con acción censurar y devuelve el primer
+// filtro de bloqueo que coincida (o nil). Compara sin mayúsculas y solo palabras
+// completas, contando letras acentuadas como parte de la palabra.
+func aplicarFiltros(contenido string, filtros []models.FiltroChat) (string, *models.FiltroChat) {
+	for i := range filtros {
+		f := &filtros[i]
+		re, err := regexp.Compile(`(?i)` + regexp.QuoteMeta(f.Palabra))
+		if err != nil {
+			continue
+		}
+		censurado, coincide := censurar(contenido, re)
+		if !coincide {
+			continue
+		}
+		if f.Accion == models.FiltroBloquear {
+			return contenido, f
+		}
+		contenido = censurado
+	}
+	return contenido, nil
+}
+
+// censurar reemplaza por asteriscos cada aparición de `re` como palabra
+// completa, en una sola pasada. Los bordes se revisan a mano porque RE2 no
+// tiene lookahead: así dos apariciones seguidas pueden compartir el separador.
+func censurar(contenido string, re *regexp.Regexp) (string, bool) {
+	var b strings.Builder
+	coincide := false
+	desde, copiado := 0, 0
+	for desde <= len(contenido) {
+		loc := re.FindStringIndex(contenido[desde:])
+		if loc == nil || loc[0] == loc[1] {
+			break
+		}
+		ini, fin := desde+loc[0], desde+loc[1]
+		antes, _ := utf8.DecodeLastRuneInString(contenido[:ini])
+		despues, _ := utf8.DecodeRuneInString(contenido[fin:])
+		if ini > 0 && esPalabra(antes) || fin < len(contenido) && esPalabra(despues) {
+			// No es palabra completa: probar desde el carácter siguiente
+			_, n := utf8.DecodeRuneInString(contenido[ini:])
+			desde = ini + n
+			continue
+		}
+		coincide = true
+		b.WriteString(contenido[copiado:ini])
+		b.WriteString(strings.Repeat("*", utf8.RuneCountInString(contenido[ini:fin])))
+		desde, copiado = fin, fin
+	}
+	if !coincide {
+		return contenido, false
+	}
+	b.WriteString(contenido[copiado:])
+	return b.String(), true
+}
+
+func esPalabra(r rune) bool { return unicode.IsLetter(r) || unicode.IsNumber(r) }
+
+// ─── Edición y borrado ────────────────────────────────────────────────────────
+
+// PATCH /api/v1/chat/mensajes/:id  (solo el autor)
+func (h *ChatHandler) EditarMensaje(c *gin.Context) {
+	var req models.EditarMensajeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	version, err := versionIfMatch(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	msg, conv, ok := h.cargarMensaje(c)
+	if !ok {
+		return
+	}
+	usuarioID := middleware.GetUsuarioID(c)
+	if msg.UsuarioID != usuarioID {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{Error: "Solo el autor puede editar el mensaje"})
+		return
+	}
+	contenido, ok := h.prepararContenido(c, conv.EventoID, req.Contenido)
+	if !ok {
+		return
+	}
+	yaAvisados := map[string]bool{}
+	if previos, err := h.mensajeRepo.DestinatariosMencion(c.Request.Context(), msg.ID); err == nil {
+		for _, u := range previos {
+			yaAvisados[u.ID] = true
+		}
+	}
+	editado, err := h.mensajeRepo.Editar(c.Request.Context(), msg.ID, usuarioID, contenido, extraerMenciones(contenido), version)
+	if errors.Is(err, repository.ErrNoEncontrado) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Mensaje no encontrado o eliminado"})
+		return
+	}
+	if errors.Is(err, repository.ErrVersionConflicto) {
+		c.JSON(http.StatusPreconditionFailed, models.ErrorResponse{Error: "El mensaje cambió desde que lo leíste. Recarga e intenta de nuevo"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error editando mensaje"})
+		return
+	}
+	h.publicar(conv, models.EventoWS{Tipo: models.WSMensajeEditado, Payload: editado, EventoID: conv.EventoID})
+	h.notificarMenciones(conv, editado, yaAvisados)
+	c.Header("ETag", etag(editado.Version))
+	c.JSON(http.StatusOK, editado)
+}
+
+// DELETE /api/v1/chat/mensajes/:id  (el autor, o el admin como moderación)
+func (h *ChatHandler) EliminarMensaje(c *gin.Context) {
+	msg, conv, ok := h.cargarMensaje(c)
+	if !ok {
+		return
+	}
+	usuarioID := middleware.GetUsuarioID(c)
+	if msg.UsuarioID != usuarioID && middleware.GetRol(c) != models.RolAdmin {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{Error: "Solo el autor o el admin pueden eliminar el mensaje"})
+		return
+	}
+	eliminado, err := h.mensajeRepo.Eliminar(c.Request.Context(), msg.ID, usuarioID)
+	if errors.Is(err, repository.ErrNoEncontrado) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Mensaje no encontrado o ya eliminado"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error eliminando mensaje"})
+		return
+	}
+	h.publicar(conv, models.EventoWS{Tipo: models.WSMensajeEliminado, Payload: eliminado, EventoID: conv.EventoID})
+	c.JSON(http.StatusOK, eliminado)
+}
+
+// GET /api/v1/chat/mensajes/:id/revisiones  (el autor o el admin)
+func (h *ChatHandler) Revisiones(c *gin.Context) {
+	msg, _, ok := h.cargarMensaje(c)
+	if !ok {
+		return
+	}
+	if msg.UsuarioID != middleware.GetUsuarioID(c) && middleware.GetRol(c) != models.RolAdmin {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{Error: "Solo el autor o el admin pueden ver las revisiones"})
+		return
+	}
+	lista, err := h.mensajeRepo.ListarRevisiones(c.Request.Context(), msg.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error listando revisiones"})
+		return
+	}
+	c.JSON(http.StatusOK, lista)
+}
+
+// cargarMensaje lee :id y su conversación. El admin puede moderar cualquier
+// mensaje del evento; el resto debe pertenecer a la conversación.
+func (h *ChatHandler) cargarMensaje(c *gin.Context) (*models.Mensaje, *models.Conversacion, bool) {
+	ctx := c.Request.Context()
+	msg, err := h.mensajeRepo.ObtenerPorID(ctx, c.Param("id"))
+	if err != nil || msg == nil || msg.EventoID != h.eventoID(c) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Mensaje no encontrado"})
+		return nil, nil, false
+	}
+	conv, err := h.conversacionRepo.ObtenerPorID(ctx, msg.ConversacionID)
+	if err != nil || conv == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Mensaje no encontrado"})
+		return nil, nil, false
+	}
+	if middleware.GetRol(c) != models.RolAdmin {
+		esMiembro, err := h.conversacionRepo.EsMiembro(ctx, conv, middleware.GetUsuarioID(c), middleware.GetRol(c))
+		if err != nil || !esMiembro {
+			c.JSON(http.StatusForbidden, models.ErrorResponse{Error: "No eres miembro de esta conversación"})
+			return nil, nil, false
+		}
+	}
+	return msg, conv, true
+}
+
+// ─── Moderación (solo admin) ──────────────────────────────────────────────────
+
+// POST /api/v1/chat/silencios  [solo admin]
+func (h *ChatHandler) Silenciar(c *gin.Context) {
+	var req models.SilenciarRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	eventoID := h.eventoID(c)
+	if eventoID == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "No hay evento activo"})
+		return
+	}
+	otro, err := h.usuarioRepo.BuscarPorID(c.Request.Context(), req.UsuarioID)
+	if err != nil || otro == nil || otro.EventoID == nil || *otro.EventoID != eventoID {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Usuario no encontrado en este evento"})
+		return
+	}
+	silencio, err := h.moderacionRepo.Silenciar(c.Request.Context(), eventoID, middleware.GetUsuarioID(c), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Error silenciando usuario"})
+		return
+	}
+	go h.hub.Publicar(context.Background(), eventoID, models.EventoWS{
+		Tipo:     models.WSUsuarioSilenciado,
+		Payload:  silencio,
+		EventoID: eventoID,
+	})
+	c.JSON(http.StatusCreated, silencio)
+}
+
+// DELETE /api/v1/chat/silencios/:usuarioId  [solo admin]
+func (h *ChatHandler) Reactivar(c *gin.Context) {
+	eventoID := h.eventoID(c)
+	usuarioID := c.Param("usuarioId")
+	if err := h.moderacionRepo.Reactivar(c.Request.Context(), eventoID, usuarioID); err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "El usuario no está silenciado"})
+		return
+	}
+	go h.hub.Publicar(context.Background(), eventoID, models.EventoWS{
+		Tipo:     models.WSUsuarioReactivado,
+		Payload:  gin.H{"usuario_id": usuarioID},
+		EventoID: eventoID,
+	})
+	c.JSON(http.StatusOK, gin.H{"mensaje": "Usuario reactivado"})
+}
+
+// GET /api/v1/chat/silencios  [solo admin]
+func (h *ChatHandler) ListarSilencios(c *gin.Context) {
+	lista, err := h.moderacionRepo.ListarSilencios(c.Request.Context(), h.eventoID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error listando silencios"})
+		return
+	}
+	c.JSON(http.StatusOK, lista)
+}
+
+// GET /api/v1/chat/filtros  [solo admin]
+func (h *ChatHandler) ListarFiltros(c *gin.Context) {
+	lista, err := h.moderacionRepo.ListarFiltros(c.Request.Context(), h.eventoID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error listando filtros"})
+		return
+	}
+	c.JSON(http.StatusOK, lista)
+}
+
+// POST /api/v1/chat/filtros  [solo admin]
+func (h *ChatHandler) CrearFiltro(c *gin.Context) {
+	var req models.CrearFiltroRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if req.Accion == "" {
+		req.Accion = models.FiltroCensurar
+	}
+	if req.Accion != models.FiltroCensurar && req.Accion != models.FiltroBloquear {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Acción inválida. Válidas: censurar, bloquear"})
+		return
+	}
+	req.Palabra = strings.TrimSpace(req.Palabra)
+	if strings.IndexFunc(req.Palabra, esPalabra) < 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "La palabra debe tener al menos una letra o número"})
+		return
+	}
+	eventoID := h.eventoID(c)
+	if eventoID == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "No hay evento activo"})
+		return
+	}
+	filtro, err := h.moderacionRepo.CrearFiltro(c.Request.Context(), eventoID, &req)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate") || strings.Contains(err.Error(), "unique") {
+			c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Esa palabra ya está filtrada"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error creando filtro"})
+		return
+	}
+	go h.hub.Publicar(context.Background(), eventoID, models.EventoWS{
+		Tipo:     models.WSFiltroCreado,
+		Payload:  filtro,
+		EventoID: eventoID,
+	})
+	c.JSON(http.StatusCreated, filtro)
+}
+
+// DELETE /api/v1/chat/filtros/:id  [solo admin]
+func (h *ChatHandler) EliminarFiltro(c *gin.Context) {
+	eventoID := h.eventoID(c)
+	filtroID := c.Param("id")
+	if err := h.moderacionRepo.EliminarFiltro(c.Request.Context(), eventoID, filtroID); err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Filtro no encontrado"})
+		return
+	}
+	go h.hub.Publicar(context.Background(), eventoID, models.EventoWS{
+		Tipo:     models.WSFiltroEliminado,
+		Payload:  gin.H{"filtro_id": filtroID},
+		EventoID: eventoID,
+	})
+	c.JSON(http.StatusOK, gin.H{"mensaje": "Filtro eliminado"})
+}
+
+// publicar distribuye por WS solo a los miembros de la conversación.
+// La sala general va a todo el evento.
+// ✅ context.Background() para que no muera cuando Gin cancela el ctx de la request
+func (h *ChatHandler) publicar(conv *models.Conversacion, evento models.EventoWS) {
+	go func() {
+		ctx := context.Background()
+		var err error
+		if conv.Tipo == models.ConversacionEvento {
+			err = h.hub.Publicar(ctx, conv.EventoID, evento)
+		} else {
+			var ids []string
+			ids, err = h.conversacionRepo.MiembrosIDs(ctx, conv)
+			if err == nil {
+				err = h.hub.PublicarAUsuarios(ctx, ids, evento)
+			}
+		}
+		if err != nil {
+			log.Printf("❌ Error publicando %s en Redis: %v", evento.Tipo, err)
+		}
+	}()
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eventpulse/backend/internal/models"
+)
+
+func TestAplicarFiltros(t *testing.T) {
+	censura := func(p string) models.FiltroChat {
+		return models.FiltroChat{Palabra: p, Accion: models.FiltroCensurar}
+	}
+	casos := []struct {
+		nombre    string
+		contenido string
+		filtros   []models.FiltroChat
+		esperado  string
+		bloquea   bool
+	}{
+		{"palabra completa", "Qué tonto eres", []models.FiltroChat{censura("tonto")}, "Qué ***** eres", false},
+		{"sin mayúsculas", "TONTO!", []models.FiltroChat{censura("tonto")}, "*****!", false},
+		{"dentro de otra palabra no", "tontorrón", []models.FiltroChat{censura("tonto")}, "tontorrón", false},
+		{"acentos cuentan como letra", "tontoá tonto", []models.FiltroChat{censura("tonto")}, "tontoá *****", false},
+		{"seguidas comparten separador", "tonto tonto tonto", []models.FiltroChat{censura("tonto")}, "***** ***** *****", false},
+		{"cuenta runas", "ñoño", []models.FiltroChat{censura("ñoño")}, "****", false},
+		{"solo asteriscos termina", "hola ** chau **", []models.FiltroChat{censura("**")}, "hola ** chau **", false},
+		{"bloqueo", "eres un tonto", []models.FiltroChat{{Palabra: "tonto", Accion: models.FiltroBloquear}}, "eres un tonto", true},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			hecho := make(chan struct{})
+			var got string
+			var filtro *models.FiltroChat
+			go func() {
+				got, filtro = aplicarFiltros(c.contenido, c.filtros)
+				close(hecho)
+			}()
+			select {
+			case <-hecho:
+			case <-time.After(time.Second):
+				t.Fatal("aplicarFiltros no terminó")
+			}
+			if got != c.esperado {
+				t.Errorf("contenido = %q, esperado %q", got, c.esperado)
+			}
+			if (filtro != nil) != c.bloquea {
+				t.Errorf("bloqueo = %v, esperado %v", filtro != nil, c.bloquea)
+			}
+		})
+	}
+}
@@ -0,0 +1,296 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/eventpulse/backend/internal/iot"
+	"github.com/eventpulse/backend/internal/middleware"
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/eventpulse/backend/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// ─── Dispositivo IoT ──────────────────────────────────────────────────────────
+
+type DispositivoHandler struct {
+	repo       *repository.DispositivoRepo
+	eventoRepo *repository.EventoRepo
+	procesador *iot.Procesador
+}
+
+func NewDispositivoHandler(r *repository.DispositivoRepo, e *repository.EventoRepo, p *iot.Procesador) *DispositivoHandler {
+	return &DispositivoHandler{repo: r, eventoRepo: e, procesador: p}
+}
+
+// Tamaño máximo de una lectura; los sensores mandan payloads chicos
+const maxLecturaIoT = 64 << 10
+
+// POST /api/v1/dispositivos  [solo admin] registra un sensor en el evento activo
+func (h *DispositivoHandler) Crear(c *gin.Context) {
+	var req models.CrearDispositivoRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	ctx := c.Request.Context()
+	evento, err := h.eventoRepo.ObtenerActivo(ctx)
+	if err != nil || evento == nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "No hay evento activo"})
+		return
+	}
+	if !h.validarConfiguracion(c, evento.ID, &req.ZonaID, req.TemaMQTT, req.Reglas, req.VentanaSegundos) {
+		return
+	}
+	token, hash, err := nuevoTokenDispositivo()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error generando token"})
+		return
+	}
+	d, err := h.repo.Crear(ctx, evento.ID, middleware.GetUsuarioID(c), hash, &req)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate") || strings.Contains(err.Error(), "unique") {
+			c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Ese tema MQTT ya pertenece a otro dispositivo"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error registrando dispositivo"})
+		return
+	}
+	// Única vez que se devuelve el token (además de al rotarlo)
+	d.Token = token
+	c.JSON(http.StatusCreated, d)
+}
+
+// GET /api/v1/dispositivos  [solo admin]
+func (h *DispositivoHandler) Listar(c *gin.Context) {
+	ctx := c.Request.Context()
+	evento, err := h.eventoRepo.ObtenerActivo(ctx)
+	if err != nil || evento == nil {
+		c.JSON(http.StatusOK, []models.Dispositivo{})
+		return
+	}
+	lista, err := h.repo.Listar(ctx, evento.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error listando dispositivos"})
+		return
+	}
+	c.JSON(http.StatusOK, lista)
+}
+
+// PATCH /api/v1/dispositivos/:id  [solo admin] nombre, zona, reglas, ventana o activo
+func (h *DispositivoHandler) Editar(c *gin.Context) {
+	var req models.EditarDispositivoRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	version, err := versionIfMatch(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	ctx := c.Request.Context()
+	d, err := h.repo.ObtenerPorID(ctx, c.Param("id"))
+	if err != nil || d == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Dispositivo no encontrado"})
+		return
+	}
+	if version != nil && *version != d.Version {
+		c.JSON(http.StatusPreconditionFailed, models.ErrorResponse{Error: msgDispositivoConflicto})
+		return
+	}
+	if req.Reglas != nil && len(req.Reglas) == 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "reglas no puede quedar vacío"})
+		return
+	}
+	if !h.validarConfiguracion(c, d.EventoID, req.ZonaID, req.TemaMQTT, req.Reglas, req.VentanaSegundos) {
+		return
+	}
+	d, err = h.repo.Editar(ctx, d.ID, &req, version)
+	if errors.Is(err, repository.ErrNoEncontrado) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Dispositivo no encontrado"})
+		return
+	}
+	if errors.Is(err, repository.ErrVersionConflicto) {
+		c.JSON(http.StatusPreconditionFailed, models.ErrorResponse{Error: msgDispositivoConflicto})
+		return
+	}
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate") || strings.Contains(err.Error(), "unique") {
+			c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Ese tema MQTT ya pertenece a otro dispositivo"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error actualizando dispositivo"})
+		return
+	}
+	c.Header("ETag", etag(d.Version))
+	c.JSON(http.StatusOK, d)
+}
+
+const msgDispositivoConflicto = "El dispositivo cambió desde que lo leíste. Recarga e intenta de nuevo"
+
+// POST /api/v1/dispositivos/:id/token  [solo admin] emite un token nuevo e invalida el anterior
+func (h *DispositivoHandler) RotarToken(c *gin.Context) {
+	token, hash, err := nuevoTokenDispositivo()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error generando token"})
+		return
+	}
+	d, err := h.repo.RotarToken(c.Request.Context(), c.Param("id"), hash)
+	if errors.Is(err, repository.ErrNoEncontrado) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Dispositivo no encontrado"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error rotando token"})
+		return
+	}
+	d.Token = token
+	c.JSON(http.StatusOK, d)
+}
+
+// DELETE /api/v1/dispositivos/:id  [solo admin] las incidencias que creó se conservan
+func (h *DispositivoHandler) Eliminar(c *gin.Context) {
+	if err := h.repo.Eliminar(c.Request.Context(), c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Dispositivo no encontrado"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"mensaje": "Dispositivo eliminado"})
+}
+
+// POST /api/v1/iot/lecturas  autenticado con el token del dispositivo
+// (Authorization: Bearer <token> o X-Dispositivo-Token). Si alguna regla se
+// cumple crea la incidencia por el mismo camino que POST /incidencias.
+func (h *DispositivoHandler) Lectura(c *gin.Context) {
+	d, ok := dispositivoAutenticado(c, h.repo)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	crudo, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxLecturaIoT))
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, models.ErrorResponse{Error: "Lectura demasiado grande"})
+		return
+	}
+	res, err := h.procesador.Procesar(ctx, d, crudo)
+	switch {
+	case errors.Is(err, iot.ErrLecturaInvalida):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "La lectura debe ser un objeto JSON"})
+		return
+	case errors.Is(err, iot.ErrEventoInactivo):
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "El evento del dispositivo no está activo"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error procesando lectura"})
+		return
+	}
+	switch res.Accion {
+	case iot.AccionCreada:
+		c.JSON(http.StatusCreated, res)
+	case iot.AccionIgnorada:
+		c.JSON(http.StatusAccepted, res)
+	default:
+		c.JSON(http.StatusOK, res)
+	}
+}
+
+// dispositivoAutenticado lee el token del dispositivo (Authorization: Bearer
+// o X-Dispositivo-Token); si no es válido responde 401 y devuelve false
+func dispositivoAutenticado(c *gin.Context, repo *repository.DispositivoRepo) (*models.Dispositivo, bool) {
+	token := c.GetHeader("X-Dispositivo-Token")
+	if token == "" {
+		token = strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	}
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Token de dispositivo requerido"})
+		return nil, false
+	}
+	d, err := repo.ObtenerPorToken(c.Request.Context(), hashTokenDispositivo(token))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error autenticando dispositivo"})
+		return nil, false
+	}
+	if d == nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Token de dispositivo inválido"})
+		return nil, false
+	}
+	return d, true
+}
+
+// validarConfiguracion revisa zona, tema, reglas y ventana; responde 400 y
+// devuelve false si algo no cuadra. Los nil se ignoran (PATCH parcial).
+func (h *DispositivoHandler) validarConfiguracion(c *gin.Context, eventoID string, zonaID, tema *string, reglas []models.ReglaDispositivo, ventana *int) bool {
+	ctx := c.Request.Context()
+	// Es el tema exacto en el que publica el dispositivo, no un filtro
+	if tema != nil && strings.ContainsAny(*tema, "+#") {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "tema_mqtt no admite comodines (+ o #)"})
+		return false
+	}
+	zonas := []string{}
+	if zonaID != nil {
+		zonas = append(zonas, *zonaID)
+	}
+	for i, r := range reglas {
+		switch {
+		case strings.TrimSpace(r.Campo) == "":
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: fmt.Sprintf("Regla %d: falta campo", i+1)})
+			return false
+		case !r.Operador.EsValido():
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: fmt.Sprintf("Regla %d: operador inválido. Válidos: =, !=, >, >=, <, <=, existe", i+1)})
+			return false
+		case !r.Tipo.EsValido():
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: fmt.Sprintf("Regla %d: tipo inválido. Válidos: derrame, seguridad, reabastecimiento, medico, otro", i+1)})
+			return false
+		case r.Operador != models.OpExiste && r.Valor == nil:
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: fmt.Sprintf("Regla %d: falta valor", i+1)})
+			return false
+		}
+		switch r.Operador {
+		case models.OpMayor, models.OpMayorIgual, models.OpMenor, models.OpMenorIgual:
+			if _, ok := r.Valor.(float64); !ok {
+				c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: fmt.Sprintf("Regla %d: %s requiere un valor numérico", i+1, r.Operador)})
+				return false
+			}
+		}
+		if r.ZonaID != nil {
+			zonas = append(zonas, *r.ZonaID)
+		}
+	}
+	for _, z := range zonas {
+		ok, err := h.repo.ZonaExiste(ctx, eventoID, z)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error validando zona"})
+			return false
+		}
+		if !ok {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Zona no encontrada: " + z})
+			return false
+		}
+	}
+	if ventana != nil && (*ventana < 0 || *ventana > 86400) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "ventana_segundos entre 0 y 86400"})
+		return false
+	}
+	return true
+}
+
+// El token se entrega una vez; en la base solo queda su sha256
+func nuevoTokenDispositivo() (token, hash string, err error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = "epd_" + hex.EncodeToString(b)
+	return token, hashTokenDispositivo(token), nil
+}
+
+func hashTokenDispositivo(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
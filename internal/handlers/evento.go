@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eventpulse/backend/internal/eventos"
+	"github.com/eventpulse/backend/internal/middleware"
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/eventpulse/backend/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// ─── Evento: programa y ciclo de vida ─────────────────────────────────────────
+
+// PATCH /api/v1/eventos/:id  [solo admin] nombre, descripción y programa (acepta If-Match)
+func (h *EventoHandler) Editar(c *gin.Context) {
+	var req models.EditarEventoRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	version, err := versionIfMatch(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	ctx := c.Request.Context()
+	actual, err := h.eventoRepo.ObtenerPorID(ctx, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error leyendo el evento"})
+		return
+	}
+	if actual == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Evento no encontrado"})
+		return
+	}
+	if actual.Estado == models.EventoTerminado {
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Un evento terminado no se edita; reábrelo primero"})
+		return
+	}
+
+	e := *actual
+	cambiaInicio := req.InicioProgramado != nil
+	for _, campo := range req.Quitar {
+		switch campo {
+		case "inicio_programado":
+			e.InicioProgramado, cambiaInicio = nil, true
+		case "fin_programado":
+			e.FinProgramado = nil
+		default:
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "quitar admite: inicio_programado, fin_programado"})
+			return
+		}
+	}
+	if req.Nombre != nil {
+		if len([]rune(strings.TrimSpace(*req.Nombre))) < 3 {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "El nombre necesita al menos 3 caracteres"})
+			return
+		}
+		e.Nombre = strings.TrimSpace(*req.Nombre)
+	}
+	if req.Descripcion != nil {
+		e.Descripcion = *req.Descripcion
+	}
+	if req.InicioProgramado != nil {
+		e.InicioProgramado = req.InicioProgramado
+	}
+	if req.FinProgramado != nil {
+		e.FinProgramado = req.FinProgramado
+	}
+	if cambiaInicio && actual.Estado.EnCurso() {
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "El evento ya empezó; solo se puede cambiar su fin"})
+		return
+	}
+	if e.Estado == models.EventoProgramado && e.InicioProgramado == nil {
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Un evento programado necesita inicio; pásalo a borrador para quitarlo"})
+		return
+	}
+	if msg := validarPrograma(&e, cambiaInicio, req.FinProgramado != nil); msg != "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: msg})
+		return
+	}
+
+	evento, err := h.eventoRepo.Actualizar(ctx, &e, version)
+	if err != nil {
+		responderCambioEvento(c, nil, err)
+		return
+	}
+	c.Header("ETag", etag(evento.Version))
+	c.JSON(http.StatusOK, evento)
+}
+
+// POST /api/v1/eventos/:id/estado  [solo admin] {"estado": "pausado"} (acepta If-Match)
+//
+//	borrador   → programado | activo
+//	programado → borrador | activo
+//	activo     → pausado | terminado
+//	pausado    → activo | terminado
+//	terminado  → activo  (reabrir, dentro del plazo de gracia)
+//
+// "terminado" pide el cierre con las opciones por defecto, como POST /cierre.
+func (h *EventoHandler) CambiarEstado(c *gin.Context) {
+	var req models.CambiarEstadoEventoRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if req.Estado == models.EventoTerminado {
+		h.solicitarCierre(c, models.OpcionesCierre{})
+		return
+	}
+	h.cambiarEstado(c, req.Estado)
+}
+
+// GET /api/v1/eventos/:id/transiciones  [solo admin] historial de estados
+func (h *EventoHandler) Transiciones(c *gin.Context) {
+	lista, err := h.eventoRepo.Transiciones(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error leyendo el historial del evento"})
+		return
+	}
+	c.JSON(http.StatusOK, lista)
+}
+
+// ─── Evento: cierre ───────────────────────────────────────────────────────────
+
+// GET /api/v1/eventos/:id/cierre  [solo admin]
+// Lo que sigue abierto antes de cerrar y el último cierre pedido (con su
+// reporte final si ya terminó). Con ?destino_evento_id= muestra además las
+// zonas que habría que copiarle para trasladar lo abierto.
+func (h *EventoHandler) Pendientes(c *gin.Context) {
+	ctx := c.Request.Context()
+	if ev, err := h.eventoRepo.ObtenerPorID(ctx, c.Param("id")); err != nil || ev == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Evento no encontrado"})
+		return
+	}
+	pendientes, err := h.cierreRepo.Pendientes(ctx, c.Param("id"), c.Query("destino_evento_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error leyendo lo pendiente del evento"})
+		return
+	}
+	c.JSON(http.StatusOK, pendientes)
+}
+
+// POST /api/v1/eventos/:id/cierre  [solo admin] (acepta If-Match)
+// {"incidencias": "resolver|trasladar", "tareas": "resolver|trasladar",
+// "personal": "desvincular|desactivar", "destino_evento_id": "...", "nota": "..."}
+// Todo es opcional. Responde 202 con el cierre pendiente; el resultado se
+// consulta en GET /cierre y el fin se avisa por WS con evento_terminado.
+func (h *EventoHandler) Cerrar(c *gin.Context) {
+	var o models.OpcionesCierre
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&o); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+			return
+		}
+	}
+	h.solicitarCierre(c, o)
+}
+
+func (h *EventoHandler) solicitarCierre(c *gin.Context, o models.OpcionesCierre) {
+	if (o.Incidencias != "" && !o.Incidencias.EsValida()) || (o.Tareas != "" && !o.Tareas.EsValida()) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "incidencias y tareas admiten: resolver, trasladar"})
+		return
+	}
+	if o.Personal != "" && !o.Personal.EsValida() {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "personal admite: desvincular, desactivar"})
+		return
+	}
+	if o.Traslada() && o.DestinoEventoID == nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Para trasladar indica destino_evento_id"})
+		return
+	}
+	version, err := versionIfMatch(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	adminID := middleware.GetUsuarioID(c)
+	cierre, err := h.cerrador.Solicitar(c.Request.Context(), c.Param("id"), o, models.TransicionAdmin, &adminID, version)
+	switch {
+	case errors.Is(err, repository.ErrCierreEnCurso):
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "El evento ya se está cerrando"})
+	case errors.Is(err, repository.ErrDestinoCierre):
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "El evento destino tiene que ser otro, en borrador o programado"})
+	case err != nil:
+		responderCambioEvento(c, nil, err)
+	default:
+		c.JSON(http.StatusAccepted, cierre)
+	}
+}
+
+func (h *EventoHandler) cambiarEstado(c *gin.Context, hacia models.EstadoEvento) {
+	version, err := versionIfMatch(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	evento, err := h.ciclo.Cambiar(c.Request.Context(), c.Param("id"), hacia, middleware.GetUsuarioID(c), version)
+	responderCambioEvento(c, evento, err)
+}
+
+func responderCambioEvento(c *gin.Context, evento *models.Evento, err error) {
+	switch {
+	case errors.Is(err, repository.ErrNoEncontrado):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Evento no encontrado"})
+	case errors.Is(err, repository.ErrVersionConflicto):
+		c.JSON(http.StatusPreconditionFailed, models.ErrorResponse{Error: "El evento cambió desde que lo leíste. Recarga e intenta de nuevo"})
+	case errors.Is(err, repository.ErrTransicionInvalida):
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "El evento no admite ese cambio de estado desde el estado actual"})
+	case errors.Is(err, repository.ErrOtroEventoEnCurso):
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Ya hay otro evento activo o pausado; termínalo primero"})
+	case errors.Is(err, eventos.ErrFueraDeGracia), errors.Is(err, eventos.ErrSinInicio), errors.Is(err, eventos.ErrRequiereCierre):
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error actualizando el evento"})
+	default:
+		c.Header("ETag", etag(evento.Version))
+		c.JSON(http.StatusOK, evento)
+	}
+}
+
+// validarPrograma: lo que se programa tiene que quedar en el futuro y el fin
+// después del inicio. Solo se revisa lo que cambia, para no trabar la edición
+// del nombre de un evento con un programa ya vencido.
+func validarPrograma(e *models.Evento, cambiaInicio, cambiaFin bool) string {
+	ahora := time.Now()
+	if cambiaInicio && e.InicioProgramado != nil && !e.InicioProgramado.After(ahora) {
+		return "inicio_programado debe ser una hora futura"
+	}
+	if cambiaFin && e.FinProgramado != nil && !e.FinProgramado.After(ahora) {
+		return "fin_programado debe ser una hora futura"
+	}
+	if e.InicioProgramado != nil && e.FinProgramado != nil && !e.FinProgramado.After(*e.InicioProgramado) &&
+		!e.Estado.EnCurso() {
+		return "fin_programado debe ser posterior a inicio_programado"
+	}
+	return ""
+}
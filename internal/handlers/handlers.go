@@ -2,13 +2,21 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/eventpulse/backend/internal/auth"
+	"github.com/eventpulse/backend/internal/eventos"
+	"github.com/eventpulse/backend/internal/geo"
 	"github.com/eventpulse/backend/internal/middleware"
 	"github.com/eventpulse/backend/internal/models"
+	"github.com/eventpulse/backend/internal/notificaciones"
+	"github.com/eventpulse/backend/internal/patrullas"
 	"github.com/eventpulse/backend/internal/repository"
 	"github.com/eventpulse/backend/internal/ws"
 	"github.com/gin-gonic/gin"
@@ -20,10 +28,11 @@ type AuthHandler struct {
 	usuarioRepo *repository.UsuarioRepo
 	eventoRepo  *repository.EventoRepo
 	jwtSvc      *auth.JWTService
+	convRepo    *repository.ConversacionRepo
 }
 
-func NewAuthHandler(u *repository.UsuarioRepo, e *repository.EventoRepo, j *auth.JWTService) *AuthHandler {
-	return &AuthHandler{usuarioRepo: u, eventoRepo: e, jwtSvc: j}
+func NewAuthHandler(u *repository.UsuarioRepo, e *repository.EventoRepo, j *auth.JWTService, cv *repository.ConversacionRepo) *AuthHandler {
+	return &AuthHandler{usuarioRepo: u, eventoRepo: e, jwtSvc: j, convRepo: cv}
 }
 
 // POST /api/v1/auth/login
@@ -55,7 +64,17 @@ func (h *AuthHandler) Me(c *gin.Context) {
 		return
 	}
 	u.Password = ""
-	c.JSON(http.StatusOK, u)
+	perfil := models.PerfilResponse{Usuario: *u}
+	eventoID := ""
+	if u.EventoID != nil {
+		eventoID = *u.EventoID
+	} else if ev, _ := h.eventoRepo.ObtenerActivo(c.Request.Context()); ev != nil {
+		eventoID = ev.ID
+	}
+	if noLeidos, err := h.convRepo.ContarNoLeidos(c.Request.Context(), eventoID, u.ID, u.Rol); err == nil {
+		perfil.NoLeidos = noLeidos.Total
+	}
+	c.JSON(http.StatusOK, perfil)
 }
 
 // ─── Evento ───────────────────────────────────────────────────────────────────
@@ -63,11 +82,14 @@ func (h *AuthHandler) Me(c *gin.Context) {
 type EventoHandler struct {
 	eventoRepo  *repository.EventoRepo
 	usuarioRepo *repository.UsuarioRepo
+	cierreRepo  *repository.CierreRepo
 	hub         *ws.Hub
+	ciclo       *eventos.Ciclo
+	cerrador    *eventos.Cerrador
 }
 
-func NewEventoHandler(e *repository.EventoRepo, u *repository.UsuarioRepo, h *ws.Hub) *EventoHandler {
-	return &EventoHandler{eventoRepo: e, usuarioRepo: u, hub: h}
+func NewEventoHandler(e *repository.EventoRepo, u *repository.UsuarioRepo, cr *repository.CierreRepo, h *ws.Hub, ciclo *eventos.Ciclo, cerrador *eventos.Cerrador) *EventoHandler {
+	return &EventoHandler{eventoRepo: e, usuarioRepo: u, cierreRepo: cr, hub: h, ciclo: ciclo, cerrador: cerrador}
 }
 
 // GET /api/v1/eventos  (admin: todos | trabajador: solo el activo vinculado)
@@ -97,39 +119,37 @@ func (h *EventoHandler) Listar(c *gin.Context) {
 }
 
 // POST /api/v1/eventos  [solo admin]
+// Nace en borrador, o programado si trae inicio_programado
 func (h *EventoHandler) Crear(c *gin.Context) {
 	var req models.CrearEventoRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
 		return
 	}
+	programa := models.Evento{InicioProgramado: req.InicioProgramado, FinProgramado: req.FinProgramado}
+	if msg := validarPrograma(&programa, true, true); msg != "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: msg})
+		return
+	}
 	evento, err := h.eventoRepo.Crear(c.Request.Context(), &req, middleware.GetUsuarioID(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error creando evento"})
 		return
 	}
+	if evento.Estado == models.EventoProgramado {
+		go h.hub.Publicar(context.Background(), evento.ID, models.EventoWS{
+			Tipo:     models.WSEventoProgramado,
+			Payload:  evento,
+			EventoID: evento.ID,
+		})
+	}
 	c.JSON(http.StatusCreated, evento)
 }
 
 // PATCH /api/v1/eventos/:id/terminar  [solo admin]
+// Atajo de POST /eventos/:id/cierre: mismo cuerpo opcional, misma respuesta 202
 func (h *EventoHandler) Terminar(c *gin.Context) {
-	eventoID := c.Param("id")
-	ctx := c.Request.Context()
-
-	evento, err := h.eventoRepo.Terminar(ctx, eventoID)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
-		return
-	}
-
-	// Notificar a todos los conectados que el evento terminó
-	go h.hub.Publicar(ctx, eventoID, models.EventoWS{
-		Tipo:     models.WSEventoTerminado,
-		Payload:  evento,
-		EventoID: eventoID,
-	})
-
-	c.JSON(http.StatusOK, evento)
+	h.Cerrar(c)
 }
 
 // ─── Usuario ──────────────────────────────────────────────────────────────────
@@ -144,7 +164,8 @@ func NewUsuarioHandler(u *repository.UsuarioRepo, e *repository.EventoRepo) *Usu
 }
 
 // POST /api/v1/usuarios  [solo admin]
-// Crea el usuario y lo vincula automáticamente al evento activo
+// Crea el usuario y lo vincula al evento activo, o al de ?evento_id para
+// armar el equipo de un evento en borrador o programado
 func (h *UsuarioHandler) Crear(c *gin.Context) {
 	var req models.CrearUsuarioRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -158,8 +179,18 @@ func (h *UsuarioHandler) Crear(c *gin.Context) {
 		return
 	}
 
-	// Obtener evento activo para vincular
-	evento, err := h.eventoRepo.ObtenerActivo(c.Request.Context())
+	// Obtener el evento al que se vincula
+	var evento *models.Evento
+	var err error
+	if qID := c.Query("evento_id"); qID != "" {
+		evento, err = h.eventoRepo.ObtenerPorID(c.Request.Context(), qID)
+		if err == nil && evento != nil && evento.Estado == models.EventoTerminado {
+			c.JSON(http.StatusConflict, models.ErrorResponse{Error: "El evento ya terminó"})
+			return
+		}
+	} else {
+		evento, err = h.eventoRepo.ObtenerActivo(c.Request.Context())
+	}
 	if err != nil || evento == nil {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "No hay ningún evento activo. Crea un evento primero"})
 		return
@@ -201,10 +232,11 @@ func (h *UsuarioHandler) Listar(c *gin.Context) {
 type ZonaHandler struct {
 	zonaRepo   *repository.ZonaRepo
 	eventoRepo *repository.EventoRepo
+	hub        *ws.Hub
 }
 
-func NewZonaHandler(z *repository.ZonaRepo, e *repository.EventoRepo) *ZonaHandler {
-	return &ZonaHandler{zonaRepo: z, eventoRepo: e}
+func NewZonaHandler(z *repository.ZonaRepo, e *repository.EventoRepo, h *ws.Hub) *ZonaHandler {
+	return &ZonaHandler{zonaRepo: z, eventoRepo: e, hub: h}
 }
 
 // GET /api/v1/zonas
@@ -213,7 +245,7 @@ func (h *ZonaHandler) Listar(c *gin.Context) {
 	if qID := c.Query("evento_id"); qID != "" && middleware.GetRol(c) == models.RolAdmin {
 		eventoID = qID
 	}
-	lista, err := h.zonaRepo.Listar(c.Request.Context(), eventoID)
+	lista, err := h.zonaRepo.Listar(c.Request.Context(), eventoID, c.Query("archivadas") == "true")
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error listando zonas"})
 		return
@@ -228,13 +260,17 @@ func (h *ZonaHandler) Crear(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
 		return
 	}
-	// Obtener evento activo
-	evento, err := h.eventoRepo.ObtenerActivo(c.Request.Context())
-	if err != nil || evento == nil {
+	// Evento activo, o el de ?evento_id para preparar uno en borrador
+	eventoID := h.eventoDe(c)
+	if eventoID == "" {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "No hay evento activo"})
 		return
 	}
-	zona, err := h.zonaRepo.Crear(c.Request.Context(), &req, evento.ID)
+	if msg := h.validarZona(c.Request.Context(), eventoID, &req); msg != "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: msg})
+		return
+	}
+	zona, err := h.zonaRepo.Crear(c.Request.Context(), &req, eventoID)
 	if err != nil {
 		if strings.Contains(err.Error(), "duplicate") || strings.Contains(err.Error(), "unique") {
 			c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Ya existe una zona con ese ID en este evento"})
@@ -246,31 +282,23 @@ func (h *ZonaHandler) Crear(c *gin.Context) {
 	c.JSON(http.StatusCreated, zona)
 }
 
-// DELETE /api/v1/zonas/:id  [solo admin]
+// DELETE /api/v1/zonas/:id?reasignar_a=  [solo admin]
+// Si la zona tiene historial se archiva en vez de borrarse
 func (h *ZonaHandler) Eliminar(c *gin.Context) {
-	zonaID := c.Param("id")
-	evento, err := h.eventoRepo.ObtenerActivo(c.Request.Context())
-	if err != nil || evento == nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "No hay evento activo"})
-		return
-	}
-	if err := h.zonaRepo.Eliminar(c.Request.Context(), zonaID, evento.ID); err != nil {
-		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: err.Error()})
-		return
-	}
-	c.JSON(http.StatusOK, gin.H{"mensaje": "Zona eliminada"})
+	h.retirar(c, false)
 }
 
 // ─── Incidencia ───────────────────────────────────────────────────────────────
 
 type IncidenciaHandler struct {
-	repo       *repository.IncidenciaRepo
-	eventoRepo *repository.EventoRepo
-	hub        *ws.Hub
+	repo        *repository.IncidenciaRepo
+	eventoRepo  *repository.EventoRepo
+	hub         *ws.Hub
+	notificador *notificaciones.Notificador
 }
 
-func NewIncidenciaHandler(r *repository.IncidenciaRepo, e *repository.EventoRepo, h *ws.Hub) *IncidenciaHandler {
-	return &IncidenciaHandler{repo: r, eventoRepo: e, hub: h}
+func NewIncidenciaHandler(r *repository.IncidenciaRepo, e *repository.EventoRepo, h *ws.Hub, n *notificaciones.Notificador) *IncidenciaHandler {
+	return &IncidenciaHandler{repo: r, eventoRepo: e, hub: h, notificador: n}
 }
 
 // GET /api/v1/incidencias
@@ -296,6 +324,7 @@ func (h *IncidenciaHandler) ObtenerPorID(c *gin.Context) {
 		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Incidencia no encontrada"})
 		return
 	}
+	c.Header("ETag", etag(inc.Version))
 	c.JSON(http.StatusOK, inc)
 }
 
@@ -306,6 +335,10 @@ func (h *IncidenciaHandler) Crear(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
 		return
 	}
+	if !req.Tipo.EsValido() {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Tipo inválido. Válidos: derrame, seguridad, reabastecimiento, medico, otro (el SOS va por POST /sos)"})
+		return
+	}
 
 	ctx := c.Request.Context()
 
@@ -339,6 +372,9 @@ func (h *IncidenciaHandler) Crear(c *gin.Context) {
 			log.Println("✅ Publicado WS: incidencia nueva evento:", evento.ID)
 		}
 	}
+	if inc.AsignadaA != nil {
+		h.notificador.Notificar([]string{*inc.AsignadaA}, notificacionIncidencia(inc))
+	}
 
 	c.JSON(http.StatusCreated, inc)
 }
@@ -351,10 +387,23 @@ func (h *IncidenciaHandler) Editar(c *gin.Context) {
 		return
 	}
 
+	version, err := versionIfMatch(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
 	ctx := c.Request.Context()
 
-	inc, err := h.repo.Editar(ctx, c.Param("id"), &req, middleware.GetUsuarioID(c))
-	if err != nil || inc == nil {
+	inc, err := h.repo.Editar(ctx, c.Param("id"), &req, middleware.GetUsuarioID(c), version)
+	switch {
+	case errors.Is(err, repository.ErrNoEncontrado):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Incidencia no encontrada"})
+		return
+	case errors.Is(err, repository.ErrVersionConflicto):
+		c.JSON(http.StatusPreconditionFailed, models.ErrorResponse{Error: "La incidencia cambió desde que la leíste. Recarga e intenta de nuevo"})
+		return
+	case err != nil || inc == nil:
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Error editando incidencia"})
 		return
 	}
@@ -377,20 +426,79 @@ func (h *IncidenciaHandler) Editar(c *gin.Context) {
 			log.Println("✅ Publicado WS: incidencia actualizada evento:", inc.EventoID)
 		}
 	}
+	// Reasignación: avisar al nuevo responsable si no se la asignó él mismo
+	if req.AsignadaA != nil && *req.AsignadaA != middleware.GetUsuarioID(c) {
+		h.notificador.Notificar([]string{*req.AsignadaA}, notificacionIncidencia(inc))
+	}
 
+	c.Header("ETag", etag(inc.Version))
+	c.JSON(http.StatusOK, inc)
+}
+
+// PATCH /api/v1/incidencias/:id/atender
+// Toma la incidencia solo si está libre. Si otra persona la tomó primero → 409
+// y WSIncidenciaConflicto solo a quien perdió, para que la app haga rollback.
+func (h *IncidenciaHandler) Atender(c *gin.Context) {
+	ctx := c.Request.Context()
+	usuarioID := middleware.GetUsuarioID(c)
+
+	inc, err := h.repo.Reclamar(ctx, c.Param("id"), usuarioID)
+	switch {
+	case errors.Is(err, repository.ErrNoEncontrado):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Incidencia no encontrada"})
+		return
+	case errors.Is(err, repository.ErrYaAsignada):
+		conflicto := models.ConflictoAsignacion{
+			IncidenciaID:   inc.ID,
+			Mensaje:        mensajeConflicto("La incidencia", inc.NombreAsignado),
+			AsignadaA:      inc.AsignadaA,
+			NombreAsignado: inc.NombreAsignado,
+		}
+		eventoIDCopy := inc.EventoID
+		go func() {
+			if err := h.hub.PublicarAUsuario(context.Background(), usuarioID, models.EventoWS{
+				Tipo:     models.WSIncidenciaConflicto,
+				Payload:  conflicto,
+				EventoID: eventoIDCopy,
+			}); err != nil {
+				log.Println("❌ Error publicando conflicto de incidencia en Redis:", err)
+			}
+		}()
+		c.JSON(http.StatusConflict, conflicto)
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error atendiendo incidencia"})
+		return
+	}
+
+	eventoIDCopy := inc.EventoID
+	go func() {
+		if err := h.hub.Publicar(context.Background(), eventoIDCopy, models.EventoWS{
+			Tipo:     models.WSIncidenciaActualizada,
+			Payload:  inc,
+			EventoID: eventoIDCopy,
+		}); err != nil {
+			log.Println("❌ Error publicando incidencia atendida en Redis:", err)
+		}
+	}()
+
+	c.Header("ETag", etag(inc.Version))
 	c.JSON(http.StatusOK, inc)
 }
 
 // ─── Tarea ────────────────────────────────────────────────────────────────────
 
 type TareaHandler struct {
-	repo       *repository.TareaRepo
-	eventoRepo *repository.EventoRepo
-	hub        *ws.Hub
+	repo        *repository.TareaRepo
+	eventoRepo  *repository.EventoRepo
+	zonaRepo    *repository.ZonaRepo
+	hub         *ws.Hub
+	notificador *notificaciones.Notificador
+	firmador    *patrullas.Firmador
 }
 
-func NewTareaHandler(r *repository.TareaRepo, e *repository.EventoRepo, h *ws.Hub) *TareaHandler {
-	return &TareaHandler{repo: r, eventoRepo: e, hub: h}
+func NewTareaHandler(r *repository.TareaRepo, e *repository.EventoRepo, z *repository.ZonaRepo, h *ws.Hub, n *notificaciones.Notificador, f *patrullas.Firmador) *TareaHandler {
+	return &TareaHandler{repo: r, eventoRepo: e, zonaRepo: z, hub: h, notificador: n, firmador: f}
 }
 
 // GET /api/v1/tareas
@@ -416,6 +524,7 @@ func (h *TareaHandler) ObtenerPorID(c *gin.Context) {
 		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Tarea no encontrada"})
 		return
 	}
+	c.Header("ETag", etag(t.Version))
 	c.JSON(http.StatusOK, t)
 }
 
@@ -426,6 +535,12 @@ func (h *TareaHandler) Crear(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
 		return
 	}
+	for _, t := range req.RequiereEvidencia {
+		if !t.EsValido() {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Evidencia inválida. Válidas: foto, nota, qr, gps"})
+			return
+		}
+	}
 	ctx := c.Request.Context()
 	evento, err := h.eventoRepo.ObtenerActivo(ctx)
 	if err != nil || evento == nil {
@@ -451,6 +566,9 @@ func (h *TareaHandler) Crear(c *gin.Context) {
 			log.Println("✅ Publicado WS: tarea nueva evento:", eventoIDCopy)
 		}
 	}()
+	if tarea.AsignadaA != nil {
+		h.notificador.Notificar([]string{*tarea.AsignadaA}, notificacionTarea(tarea))
+	}
 
 	c.JSON(http.StatusCreated, tarea)
 }
@@ -462,9 +580,32 @@ func (h *TareaHandler) Editar(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
 		return
 	}
+	if req.Estado != nil && *req.Estado == models.TareaCancelada {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Una tarea solo se cancela al cerrar el evento"})
+		return
+	}
+	version, err := versionIfMatch(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
 	ctx := c.Request.Context()
-	tarea, err := h.repo.Editar(ctx, c.Param("id"), &req)
-	if err != nil || tarea == nil {
+	tarea, err := h.repo.Editar(ctx, c.Param("id"), &req, middleware.GetUsuarioID(c), version)
+	var faltante *repository.EvidenciaFaltanteError
+	switch {
+	case errors.As(err, &faltante):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Falta evidencia para completar la tarea", "faltantes": faltante.Tipos})
+		return
+	case errors.Is(err, repository.ErrNoEncontrado):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Tarea no encontrada"})
+		return
+	case errors.Is(err, repository.ErrVersionConflicto):
+		c.JSON(http.StatusPreconditionFailed, models.ErrorResponse{Error: "La tarea cambió desde que la leíste. Recarga e intenta de nuevo"})
+		return
+	case errors.Is(err, repository.ErrTareaCancelada):
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "La tarea se canceló al cerrar el evento"})
+		return
+	case err != nil || tarea == nil:
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Error editando tarea"})
 		return
 	}
@@ -482,79 +623,210 @@ func (h *TareaHandler) Editar(c *gin.Context) {
 			log.Println("✅ Publicado WS: tarea actualizada evento:", eventoIDCopy)
 		}
 	}()
+	if req.AsignadaA != nil && *req.AsignadaA != middleware.GetUsuarioID(c) {
+		h.notificador.Notificar([]string{*req.AsignadaA}, notificacionTarea(tarea))
+	}
 
+	c.Header("ETag", etag(tarea.Version))
 	c.JSON(http.StatusOK, tarea)
 }
 
-// ─── Chat ─────────────────────────────────────────────────────────────────────
-
-type ChatHandler struct {
-	mensajeRepo *repository.MensajeRepo
-	eventoRepo  *repository.EventoRepo
-	hub         *ws.Hub
-}
-
-func NewChatHandler(m *repository.MensajeRepo, e *repository.EventoRepo, h *ws.Hub) *ChatHandler {
-	return &ChatHandler{mensajeRepo: m, eventoRepo: e, hub: h}
-}
+// PATCH /api/v1/tareas/:id/tomar
+// Igual que IncidenciaHandler.Atender: solo uno gana, el resto recibe 409.
+func (h *TareaHandler) Tomar(c *gin.Context) {
+	ctx := c.Request.Context()
+	usuarioID := middleware.GetUsuarioID(c)
 
-// GET /api/v1/chat/historial
-func (h *ChatHandler) Historial(c *gin.Context) {
-	eventoID := middleware.GetEventoID(c)
-	if eventoID == "" {
-		if ev, _ := h.eventoRepo.ObtenerActivo(c.Request.Context()); ev != nil {
-			eventoID = ev.ID
+	tarea, err := h.repo.Reclamar(ctx, c.Param("id"), usuarioID)
+	switch {
+	case errors.Is(err, repository.ErrNoEncontrado):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Tarea no encontrada"})
+		return
+	case errors.Is(err, repository.ErrYaAsignada):
+		conflicto := models.ConflictoAsignacion{
+			TareaID:        tarea.ID,
+			Mensaje:        mensajeConflicto("La tarea", tarea.NombreAsignado),
+			AsignadaA:      tarea.AsignadaA,
+			NombreAsignado: tarea.NombreAsignado,
 		}
-	}
-	msgs, err := h.mensajeRepo.Listar(c.Request.Context(), eventoID, 50)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error obteniendo historial"})
+		eventoIDCopy := tarea.EventoID
+		go func() {
+			if err := h.hub.PublicarAUsuario(context.Background(), usuarioID, models.EventoWS{
+				Tipo:     models.WSTareaConflicto,
+				Payload:  conflicto,
+				EventoID: eventoIDCopy,
+			}); err != nil {
+				log.Println("❌ Error publicando conflicto de tarea en Redis:", err)
+			}
+		}()
+		c.JSON(http.StatusConflict, conflicto)
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error tomando tarea"})
 		return
 	}
-	c.JSON(http.StatusOK, msgs)
+
+	eventoIDCopy := tarea.EventoID
+	go func() {
+		if err := h.hub.Publicar(context.Background(), eventoIDCopy, models.EventoWS{
+			Tipo:     models.WSTareaActualizada,
+			Payload:  tarea,
+			EventoID: eventoIDCopy,
+		}); err != nil {
+			log.Println("❌ Error publicando tarea tomada en Redis:", err)
+		}
+	}()
+
+	c.Header("ETag", etag(tarea.Version))
+	c.JSON(http.StatusOK, tarea)
 }
 
-// POST /api/v1/chat/mensaje  (admin y trabajadores)
-func (h *ChatHandler) Enviar(c *gin.Context) {
-	var req models.EnviarMensajeRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+// Tamaño máximo de una foto de evidencia
+const maxFotoEvidencia = 5 << 20
+
+// Margen para el error del GPS al comprobar que la evidencia está en la zona
+const toleranciaEvidenciaGPS = 30.0
+
+// POST /api/v1/tareas/:id/evidencias  (multipart: tipo, nota, qr, latitud, longitud, foto)
+func (h *TareaHandler) SubirEvidencia(c *gin.Context) {
+	var req models.SubirEvidenciaRequest
+	if err := c.ShouldBind(&req); err != nil {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
 		return
 	}
 	ctx := c.Request.Context()
-	usuarioID := middleware.GetUsuarioID(c)
-	eventoID := middleware.GetEventoID(c)
+	tarea, ok := h.tareaConEvidencias(c)
+	if !ok {
+		return
+	}
 
-	// Admin puede enviar aunque no tenga evento_id en token, usar el activo
-	if eventoID == "" {
-		if ev, _ := h.eventoRepo.ObtenerActivo(ctx); ev != nil {
-			eventoID = ev.ID
+	var foto []byte
+	var fotoMime string
+	switch req.Tipo {
+	case models.EvidenciaFoto:
+		archivo, err := c.FormFile("foto")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Se requiere el archivo 'foto'"})
+			return
+		}
+		if archivo.Size > maxFotoEvidencia {
+			c.JSON(http.StatusRequestEntityTooLarge, models.ErrorResponse{Error: "La foto supera los 5 MB"})
+			return
+		}
+		f, err := archivo.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "No se pudo leer la foto"})
+			return
 		}
+		defer f.Close()
+		foto, err = io.ReadAll(f)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "No se pudo leer la foto"})
+			return
+		}
+		fotoMime = http.DetectContentType(foto)
+		if !strings.HasPrefix(fotoMime, "image/") {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "El archivo no es una imagen"})
+			return
+		}
+	case models.EvidenciaNota:
+		if strings.TrimSpace(req.Nota) == "" {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "La nota no puede estar vacía"})
+			return
+		}
+	case models.EvidenciaQR:
+		// El QR impreso en la zona lleva un token firmado con evento y zona
+		if tarea.ZonaID == nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "La tarea no tiene zona, no admite evidencia QR"})
+			return
+		}
+		if err := h.firmador.ZonaDe(req.QR, tarea.EventoID, *tarea.ZonaID); err != nil {
+			msg := "El QR escaneado no corresponde a la zona de la tarea"
+			if errors.Is(err, patrullas.ErrTokenInvalido) {
+				msg = err.Error()
+			}
+			c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{Error: msg})
+			return
+		}
+	case models.EvidenciaGPS:
+		if req.Latitud == nil || req.Longitud == nil ||
+			*req.Latitud < -90 || *req.Latitud > 90 || *req.Longitud < -180 || *req.Longitud > 180 {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Se requieren latitud y longitud válidas"})
+			return
+		}
+		// Si la zona de la tarea tiene geometría, la posición debe caer en ella
+		if tarea.ZonaID != nil {
+			zona, err := h.zonaRepo.ObtenerPorID(ctx, *tarea.ZonaID, tarea.EventoID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error leyendo la zona de la tarea"})
+				return
+			}
+			if zona != nil {
+				if d, ok := geo.DistanciaZona(zona, *req.Latitud, *req.Longitud); ok && d > toleranciaEvidenciaGPS {
+					c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{
+						Error: fmt.Sprintf("La posición está a %.0f m de la zona de la tarea", d),
+					})
+					return
+				}
+			}
+		}
+	default:
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Evidencia inválida. Válidas: foto, nota, qr, gps"})
+		return
 	}
-	if eventoID == "" {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "No hay evento activo"})
+
+	ev, err := h.repo.AgregarEvidencia(ctx, tarea.ID, middleware.GetUsuarioID(c), &req, foto, fotoMime)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error guardando evidencia"})
 		return
 	}
+	c.JSON(http.StatusCreated, ev)
+}
 
-	msg, err := h.mensajeRepo.Crear(ctx, eventoID, usuarioID, req.Contenido)
+// GET /api/v1/tareas/:id/evidencias
+func (h *TareaHandler) ListarEvidencias(c *gin.Context) {
+	tarea, ok := h.tareaConEvidencias(c)
+	if !ok {
+		return
+	}
+	lista, err := h.repo.ListarEvidencias(c.Request.Context(), tarea.ID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error enviando mensaje"})
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error listando evidencias"})
 		return
 	}
+	c.JSON(http.StatusOK, lista)
+}
 
-	// ✅ FIX: context.Background() para que no muera cuando Gin cancela el ctx de la request
-	eventoIDCopy := eventoID
-	go func() {
-		if err := h.hub.Publicar(context.Background(), eventoIDCopy, models.EventoWS{
-			Tipo:     models.WSMensajeNuevo,
-			Payload:  msg,
-			EventoID: eventoIDCopy,
-		}); err != nil {
-			log.Println("❌ Error publicando mensaje en Redis:", err)
-		}
-	}()
+// GET /api/v1/tareas/:id/evidencias/:evidenciaId/foto
+func (h *TareaHandler) FotoEvidencia(c *gin.Context) {
+	tarea, ok := h.tareaConEvidencias(c)
+	if !ok {
+		return
+	}
+	foto, mime, err := h.repo.ObtenerFotoEvidencia(c.Request.Context(), tarea.ID, c.Param("evidenciaId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Foto no encontrada"})
+		return
+	}
+	c.Data(http.StatusOK, mime, foto)
+}
 
-	c.JSON(http.StatusCreated, msg)
+// tareaConEvidencias: la tarea de la ruta si quien pide puede ver o subir sus
+// evidencias. Admin ve todas; supervisor, las de su evento; el resto, solo
+// las que tiene asignadas. Una tarea de otro evento responde 404.
+func (h *TareaHandler) tareaConEvidencias(c *gin.Context) (*models.Tarea, bool) {
+	tarea, err := h.repo.ObtenerPorID(c.Request.Context(), c.Param("id"))
+	rol := middleware.GetRol(c)
+	if err != nil || tarea == nil || (rol != models.RolAdmin && tarea.EventoID != middleware.GetEventoID(c)) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Tarea no encontrada"})
+		return nil, false
+	}
+	if rol != models.RolAdmin && rol != models.RolSupervisor &&
+		(tarea.AsignadaA == nil || *tarea.AsignadaA != middleware.GetUsuarioID(c)) {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{Error: "Solo quien tiene asignada la tarea maneja sus evidencias"})
+		return nil, false
+	}
+	return tarea, true
 }
 
 // ─── WebSocket ────────────────────────────────────────────────────────────────
@@ -577,7 +849,7 @@ func (h *WSHandler) Conectar(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Token requerido como query param: ?token=..."})
 		return
 	}
-	claims, err := h.jwtSvc.ValidarToken(tokenStr)
+	claims, err := h.jwtSvc.ValidarToken(c.Request.Context(), tokenStr)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Token inválido"})
 		return
@@ -597,5 +869,62 @@ func (h *WSHandler) Conectar(c *gin.Context) {
 		return
 	}
 
-	h.hub.HandleConexion(c.Writer, c.Request, claims.UsuarioID, *eventoID)
-}
\ No newline at end of file
+	h.hub.HandleConexion(c.Writer, c.Request, claims.UsuarioID, *eventoID, claims.Rol)
+}
+
+// ─── Concurrencia optimista ───────────────────────────────────────────────────
+
+// etag representa la versión de un registro como entity-tag fuerte: "3"
+func etag(version int) string { return `"` + strconv.Itoa(version) + `"` }
+
+// versionIfMatch lee el header If-Match. Sin header (o con "*") devuelve nil y
+// la escritura es incondicional, así las apps que no lo envían siguen funcionando.
+func versionIfMatch(c *gin.Context) (*int, error) {
+	v := strings.TrimSpace(c.GetHeader("If-Match"))
+	if v == "" || v == "*" {
+		return nil, nil
+	}
+	v = strings.Trim(strings.TrimPrefix(v, "W/"), `"`)
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return nil, errors.New("Header If-Match inválido, se espera el ETag recibido")
+	}
+	return &n, nil
+}
+
+func mensajeConflicto(sujeto string, nombreAsignado *string) string {
+	if nombreAsignado == nil {
+		return sujeto + " ya no está pendiente"
+	}
+	return sujeto + " ya fue tomada por " + *nombreAsignado
+}
+
+// ─── Avisos de asignación fuera de la app ─────────────────────────────────────
+
+func notificacionIncidencia(inc *models.Incidencia) models.Notificacion {
+	zona := inc.ZonaID
+	if inc.ZonaNombre != "" {
+		zona = inc.ZonaNombre
+	}
+	return models.Notificacion{
+		Tipo:    models.NotifAsignacion,
+		Titulo:  fmt.Sprintf("Te asignaron una incidencia: %s en %s", inc.Tipo, zona),
+		Cuerpo:  inc.Descripcion,
+		Urgente: inc.Tipo == models.TipoMedico || inc.Tipo == models.TipoSeguridad,
+		Ref:     inc.ID,
+	}
+}
+
+func notificacionTarea(t *models.Tarea) models.Notificacion {
+	cuerpo := t.Descripcion
+	if t.ZonaNombre != nil {
+		cuerpo = *t.ZonaNombre + ". " + cuerpo
+	}
+	return models.Notificacion{
+		Tipo:    models.NotifAsignacion,
+		Titulo:  "Te asignaron una tarea: " + t.Titulo,
+		Cuerpo:  cuerpo,
+		Urgente: t.Prioridad == models.PrioridadAlta,
+		Ref:     t.ID,
+	}
+}
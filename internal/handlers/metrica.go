@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/eventpulse/backend/internal/metricas"
+	"github.com/eventpulse/backend/internal/middleware"
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/eventpulse/backend/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// ─── Métricas en vivo ─────────────────────────────────────────────────────────
+
+type MetricaHandler struct {
+	tablero    *metricas.Tablero
+	eventoRepo *repository.EventoRepo
+}
+
+func NewMetricaHandler(t *metricas.Tablero, e *repository.EventoRepo) *MetricaHandler {
+	return &MetricaHandler{tablero: t, eventoRepo: e}
+}
+
+// GET /api/v1/eventos/:id/metricas  [admin o supervisor del evento]
+// Tablero completo; después llegan por WS solo las secciones que cambian
+func (h *MetricaHandler) Obtener(c *gin.Context) {
+	eventoID := c.Param("id")
+	if middleware.GetRol(c) != models.RolAdmin && middleware.GetEventoID(c) != eventoID {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{Error: "Solo puedes ver las métricas de tu evento"})
+		return
+	}
+	ctx := c.Request.Context()
+	if ev, err := h.eventoRepo.ObtenerPorID(ctx, eventoID); err != nil || ev == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Evento no encontrado"})
+		return
+	}
+	m, err := h.tablero.Calcular(ctx, eventoID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error calculando las métricas"})
+		return
+	}
+	c.JSON(http.StatusOK, m)
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/eventpulse/backend/internal/middleware"
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/eventpulse/backend/internal/notificaciones"
+	"github.com/eventpulse/backend/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// ─── Notificación ─────────────────────────────────────────────────────────────
+
+type NotificacionHandler struct {
+	repo        *repository.NotificacionRepo
+	notificador *notificaciones.Notificador
+	webPush     *notificaciones.WebPush // nil si el servidor no tiene VAPID
+}
+
+func NewNotificacionHandler(r *repository.NotificacionRepo, n *notificaciones.Notificador, wp *notificaciones.WebPush) *NotificacionHandler {
+	return &NotificacionHandler{repo: r, notificador: n, webPush: wp}
+}
+
+// GET /api/v1/notificaciones  últimas notificaciones enviadas (u omitidas) al usuario
+func (h *NotificacionHandler) Listar(c *gin.Context) {
+	limite, _ := strconv.Atoi(c.DefaultQuery("limite", "50"))
+	if limite <= 0 || limite > 200 {
+		limite = 50
+	}
+	lista, err := h.repo.ListarEnvios(c.Request.Context(), middleware.GetUsuarioID(c), limite)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error listando notificaciones"})
+		return
+	}
+	c.JSON(http.StatusOK, lista)
+}
+
+// GET /api/v1/notificaciones/preferencias
+func (h *NotificacionHandler) Preferencias(c *gin.Context) {
+	p, err := h.repo.ObtenerPreferencias(c.Request.Context(), middleware.GetUsuarioID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error leyendo preferencias"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"preferencias": p, "canales_disponibles": h.notificador.Canales()})
+}
+
+// PUT /api/v1/notificaciones/preferencias  reemplaza todas las preferencias
+func (h *NotificacionHandler) GuardarPreferencias(c *gin.Context) {
+	var req models.PreferenciasNotificacionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	for _, canal := range req.Canales {
+		if !canal.EsValido() {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Canal inválido. Válidos: push, email, sms"})
+			return
+		}
+	}
+	for _, t := range req.Tipos {
+		if !t.EsValido() {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Tipo inválido. Válidos: asignacion, escalamiento, anuncio"})
+			return
+		}
+	}
+	if (req.SilencioDesde == nil) != (req.SilencioHasta == nil) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "silencio_desde y silencio_hasta van juntos"})
+		return
+	}
+	for _, hora := range []*string{req.SilencioDesde, req.SilencioHasta} {
+		if hora == nil {
+			continue
+		}
+		if _, err := time.Parse("15:04", *hora); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Horario de silencio en formato HH:MM"})
+			return
+		}
+	}
+	if req.ZonaHoraria == "" {
+		req.ZonaHoraria = "UTC"
+	}
+	if _, err := time.LoadLocation(req.ZonaHoraria); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Zona horaria inválida (usar IANA, ej: America/Mexico_City)"})
+		return
+	}
+
+	p, err := h.repo.GuardarPreferencias(c.Request.Context(), middleware.GetUsuarioID(c), &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error guardando preferencias"})
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+// GET /api/v1/notificaciones/push/clave  applicationServerKey para pushManager.subscribe
+func (h *NotificacionHandler) ClavePush(c *gin.Context) {
+	if h.webPush == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Web Push no está configurado en el servidor"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"clave_publica": h.webPush.ClavePublica()})
+}
+
+// POST /api/v1/notificaciones/push  registra la suscripción del navegador
+func (h *NotificacionHandler) SuscribirPush(c *gin.Context) {
+	if h.webPush == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Web Push no está configurado en el servidor"})
+		return
+	}
+	var req models.SuscribirPushRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	s, err := h.repo.Suscribir(c.Request.Context(), middleware.GetUsuarioID(c), &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error registrando suscripción"})
+		return
+	}
+	c.JSON(http.StatusCreated, s)
+}
+
+// DELETE /api/v1/notificaciones/push  { "endpoint": "..." }
+func (h *NotificacionHandler) DesuscribirPush(c *gin.Context) {
+	var req models.DesuscribirPushRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	err := h.repo.Desuscribir(c.Request.Context(), middleware.GetUsuarioID(c), req.Endpoint)
+	if errors.Is(err, repository.ErrNoEncontrado) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Suscripción no encontrada"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error eliminando suscripción"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"mensaje": "Suscripción eliminada"})
+}
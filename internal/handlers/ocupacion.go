@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/eventpulse/backend/internal/middleware"
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/eventpulse/backend/internal/ocupacion"
+	"github.com/eventpulse/backend/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// ─── Ocupación de zonas ───────────────────────────────────────────────────────
+
+type OcupacionHandler struct {
+	contador        *ocupacion.Contador
+	eventoRepo      *repository.EventoRepo
+	dispositivoRepo *repository.DispositivoRepo
+}
+
+func NewOcupacionHandler(c *ocupacion.Contador, e *repository.EventoRepo, d *repository.DispositivoRepo) *OcupacionHandler {
+	return &OcupacionHandler{contador: c, eventoRepo: e, dispositivoRepo: d}
+}
+
+// GET /api/v1/zonas/ocupacion  zonas con capacidad o con gente contada
+func (h *OcupacionHandler) Listar(c *gin.Context) {
+	eventoID := eventoPedido(c, h.eventoRepo)
+	if eventoID == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "No hay evento activo"})
+		return
+	}
+	lista, err := h.contador.Listar(c.Request.Context(), eventoID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error leyendo la ocupación"})
+		return
+	}
+	c.JSON(http.StatusOK, lista)
+}
+
+// POST /api/v1/zonas/:id/ocupacion  contador manual: {"entradas": 1} o {"salidas": 1}
+func (h *OcupacionHandler) Contar(c *gin.Context) {
+	var req models.ConteoRequest
+	if !bindConteo(c, &req) {
+		return
+	}
+	eventoID := eventoPedido(c, h.eventoRepo)
+	if eventoID == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "No hay evento activo"})
+		return
+	}
+	o, err := h.contador.Contar(c.Request.Context(), eventoID, c.Param("id"), middleware.GetUsuarioID(c), req.Entradas, req.Salidas)
+	responderOcupacion(c, o, err)
+}
+
+// PUT /api/v1/zonas/:id/ocupacion  [admin, supervisor] fija el conteo tras un recuento
+func (h *OcupacionHandler) Ajustar(c *gin.Context) {
+	var req models.AjustarOcupacionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	eventoID := eventoPedido(c, h.eventoRepo)
+	if eventoID == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "No hay evento activo"})
+		return
+	}
+	o, err := h.contador.Ajustar(c.Request.Context(), eventoID, c.Param("id"), middleware.GetUsuarioID(c), *req.Personas)
+	responderOcupacion(c, o, err)
+}
+
+// POST /api/v1/iot/ocupacion  torniquetes y contadores automáticos, con el
+// token del dispositivo. La zona es la del dispositivo salvo que venga zona_id.
+// Las incidencias de aforo quedan a nombre del admin que lo registró.
+func (h *OcupacionHandler) ContarDispositivo(c *gin.Context) {
+	d, ok := dispositivoAutenticado(c, h.dispositivoRepo)
+	if !ok {
+		return
+	}
+	var req models.ConteoRequest
+	if !bindConteo(c, &req) {
+		return
+	}
+	ctx := c.Request.Context()
+	if ev, err := h.eventoRepo.ObtenerActivo(ctx); err != nil || ev == nil || ev.ID != d.EventoID {
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "El evento del dispositivo no está activo"})
+		return
+	}
+	zonaID := d.ZonaID
+	if req.ZonaID != "" {
+		zonaID = req.ZonaID
+	}
+	o, err := h.contador.Contar(ctx, d.EventoID, zonaID, d.CreadoPor, req.Entradas, req.Salidas)
+	responderOcupacion(c, o, err)
+}
+
+func bindConteo(c *gin.Context, req *models.ConteoRequest) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return false
+	}
+	if req.Entradas == 0 && req.Salidas == 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Indica entradas o salidas"})
+		return false
+	}
+	return true
+}
+
+func responderOcupacion(c *gin.Context, o *models.Ocupacion, err error) {
+	switch {
+	case errors.Is(err, ocupacion.ErrZonaInexistente):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Zona no encontrada"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error actualizando la ocupación"})
+	default:
+		c.JSON(http.StatusOK, o)
+	}
+}
@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eventpulse/backend/internal/middleware"
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/eventpulse/backend/internal/patrullas"
+	"github.com/eventpulse/backend/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// ─── Patrullas: QR de zona, check-in y rondas ─────────────────────────────────
+
+type PatrullaHandler struct {
+	repo       *repository.PatrullaRepo
+	zonaRepo   *repository.ZonaRepo
+	eventoRepo *repository.EventoRepo
+	vigilante  *patrullas.Vigilante
+	firmador   *patrullas.Firmador
+}
+
+func NewPatrullaHandler(r *repository.PatrullaRepo, z *repository.ZonaRepo, e *repository.EventoRepo, v *patrullas.Vigilante, f *patrullas.Firmador) *PatrullaHandler {
+	return &PatrullaHandler{repo: r, zonaRepo: z, eventoRepo: e, vigilante: v, firmador: f}
+}
+
+// GET /api/v1/zonas/:id/qr?tamano=512  [admin o supervisor]  PNG
+func (h *PatrullaHandler) QR(c *gin.Context) {
+	eventoID := eventoPedido(c, h.eventoRepo)
+	if eventoID == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "No hay evento activo"})
+		return
+	}
+	tamano, err := strconv.Atoi(c.DefaultQuery("tamano", strconv.Itoa(patrullas.TamanoQRPorDefecto)))
+	if err != nil || tamano < patrullas.TamanoQRMin || tamano > patrullas.TamanoQRMax {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "tamano debe estar entre 128 y 2048"})
+		return
+	}
+	zona, err := h.zonaRepo.ObtenerPorID(c.Request.Context(), c.Param("id"), eventoID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error leyendo la zona"})
+		return
+	}
+	if zona == nil || zona.ArchivadaEn != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Zona no encontrada"})
+		return
+	}
+	png, err := h.firmador.PNG(eventoID, zona.ID, tamano)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error generando el QR"})
+		return
+	}
+	c.Header("Content-Disposition", `inline; filename="qr-`+zona.ID+`.png"`)
+	c.Data(http.StatusOK, "image/png", png)
+}
+
+// GET /api/v1/zonas/qr  [admin o supervisor]  PDF con los QR de todas las zonas
+func (h *PatrullaHandler) HojaQR(c *gin.Context) {
+	eventoID := eventoPedido(c, h.eventoRepo)
+	ctx := c.Request.Context()
+	evento, err := h.eventoRepo.ObtenerPorID(ctx, eventoID)
+	if err != nil || evento == nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "No hay evento activo"})
+		return
+	}
+	zonas, err := h.zonaRepo.Listar(ctx, eventoID, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error listando zonas"})
+		return
+	}
+	pdf, err := h.firmador.HojaPDF(evento, zonas)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error generando el PDF"})
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="qr-zonas-`+evento.ID+`.pdf"`)
+	c.Data(http.StatusOK, "application/pdf", pdf)
+}
+
+// POST /api/v1/checkins  escaneo del QR de una zona
+func (h *PatrullaHandler) Checkin(c *gin.Context) {
+	var req models.CheckinRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	res, err := h.vigilante.Checkin(c.Request.Context(), middleware.GetUsuarioID(c), middleware.GetEventoID(c), req.Token)
+	switch {
+	case errors.Is(err, patrullas.ErrTokenInvalido), errors.Is(err, patrullas.ErrQROtroEvento):
+		c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, patrullas.ErrZonaArchiva):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error registrando el check-in"})
+	default:
+		c.JSON(http.StatusCreated, res)
+	}
+}
+
+// GET /api/v1/checkins?zona_id=&usuario_id=&desde=&limite=
+// Admin y supervisor ven todos; el resto, solo los propios
+func (h *PatrullaHandler) Checkins(c *gin.Context) {
+	eventoID := eventoPedido(c, h.eventoRepo)
+	if eventoID == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "No hay evento activo"})
+		return
+	}
+	usuarioID := c.Query("usuario_id")
+	if !esMando(c) {
+		usuarioID = middleware.GetUsuarioID(c)
+	}
+	desde, ok := desdeQuery(c)
+	if !ok {
+		return
+	}
+	limite, _ := strconv.Atoi(c.DefaultQuery("limite", "200"))
+	if limite <= 0 || limite > 1000 {
+		limite = 200
+	}
+	lista, err := h.repo.Checkins(c.Request.Context(), eventoID, c.Query("zona_id"), usuarioID, desde, limite)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error listando check-ins"})
+		return
+	}
+	c.JSON(http.StatusOK, lista)
+}
+
+// POST /api/v1/patrullas/rutas  [admin o supervisor]
+func (h *PatrullaHandler) CrearRuta(c *gin.Context) {
+	var req models.CrearRutaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if strings.TrimSpace(req.Nombre) == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "El nombre no puede estar vacío"})
+		return
+	}
+	if req.ToleranciaMin != nil && *req.ToleranciaMin < 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "tolerancia_min no puede ser negativa"})
+		return
+	}
+	eventoID := eventoPedido(c, h.eventoRepo)
+	if eventoID == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "No hay evento activo"})
+		return
+	}
+	ruta, err := h.repo.CrearRuta(c.Request.Context(), eventoID, middleware.GetUsuarioID(c), &req)
+	if errors.Is(err, repository.ErrZonaNoDisponible) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Alguna zona de la ruta no existe en el evento o está archivada"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error creando la ruta"})
+		return
+	}
+	c.JSON(http.StatusCreated, ruta)
+}
+
+// GET /api/v1/patrullas/rutas?inactivas=true
+func (h *PatrullaHandler) Rutas(c *gin.Context) {
+	eventoID := eventoPedido(c, h.eventoRepo)
+	if eventoID == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "No hay evento activo"})
+		return
+	}
+	lista, err := h.repo.Rutas(c.Request.Context(), eventoID, c.Query("inactivas") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error listando rutas"})
+		return
+	}
+	c.JSON(http.StatusOK, lista)
+}
+
+// DELETE /api/v1/patrullas/rutas/:id  [admin o supervisor]  la desactiva
+func (h *PatrullaHandler) DesactivarRuta(c *gin.Context) {
+	eventoID := eventoPedido(c, h.eventoRepo)
+	err := h.repo.DesactivarRuta(c.Request.Context(), c.Param("id"), eventoID)
+	if errors.Is(err, repository.ErrNoEncontrado) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Ruta no encontrada"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error desactivando la ruta"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"mensaje": "Ruta desactivada"})
+}
+
+// POST /api/v1/patrullas/rondas  arranca una ronda de la ruta
+func (h *PatrullaHandler) IniciarRonda(c *gin.Context) {
+	var req models.IniciarRondaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	eventoID := eventoPedido(c, h.eventoRepo)
+	if eventoID == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "No hay evento activo"})
+		return
+	}
+	ronda, err := h.vigilante.IniciarRonda(c.Request.Context(), middleware.GetUsuarioID(c), eventoID, req.RutaID)
+	switch {
+	case errors.Is(err, patrullas.ErrRutaInactiva):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, repository.ErrRondaEnCurso):
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Ya tienes una ronda en curso; termínala o cancélala primero"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error iniciando la ronda"})
+	default:
+		c.JSON(http.StatusCreated, ronda)
+	}
+}
+
+// GET /api/v1/patrullas/rondas/actual  204 si no hay
+func (h *PatrullaHandler) MiRonda(c *gin.Context) {
+	ronda, err := h.repo.RondaEnCurso(c.Request.Context(), middleware.GetUsuarioID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error leyendo la ronda"})
+		return
+	}
+	if ronda == nil {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	c.JSON(http.StatusOK, ronda)
+}
+
+// DELETE /api/v1/patrullas/rondas/actual
+func (h *PatrullaHandler) CancelarRonda(c *gin.Context) {
+	ronda, err := h.vigilante.CancelarRonda(c.Request.Context(), middleware.GetUsuarioID(c))
+	if errors.Is(err, repository.ErrNoEncontrado) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "No hay ronda en curso"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error cancelando la ronda"})
+		return
+	}
+	c.JSON(http.StatusOK, ronda)
+}
+
+// GET /api/v1/patrullas/rondas?estado=&usuario_id=&desde=  [admin o supervisor]
+func (h *PatrullaHandler) Rondas(c *gin.Context) {
+	eventoID := eventoPedido(c, h.eventoRepo)
+	if eventoID == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "No hay evento activo"})
+		return
+	}
+	desde, ok := desdeQuery(c)
+	if !ok {
+		return
+	}
+	lista, err := h.repo.Rondas(c.Request.Context(), eventoID, c.Query("estado"), c.Query("usuario_id"), desde)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error listando rondas"})
+		return
+	}
+	c.JSON(http.StatusOK, lista)
+}
+
+// GET /api/v1/patrullas/rondas/:id  la propia, o cualquiera para admin/supervisor
+func (h *PatrullaHandler) Ronda(c *gin.Context) {
+	ronda, err := h.repo.Ronda(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error leyendo la ronda"})
+		return
+	}
+	if ronda == nil || (ronda.UsuarioID != middleware.GetUsuarioID(c) && !esMando(c)) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Ronda no encontrada"})
+		return
+	}
+	c.JSON(http.StatusOK, ronda)
+}
+
+func esMando(c *gin.Context) bool {
+	rol := middleware.GetRol(c)
+	return rol == models.RolAdmin || rol == models.RolSupervisor
+}
+
+// desdeQuery lee ?desde en RFC 3339; por defecto, las últimas 24 horas
+func desdeQuery(c *gin.Context) (time.Time, bool) {
+	v := c.Query("desde")
+	if v == "" {
+		return time.Now().Add(-24 * time.Hour), true
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "desde debe ser RFC 3339"})
+		return time.Time{}, false
+	}
+	return t, true
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/eventpulse/backend/internal/middleware"
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/eventpulse/backend/internal/repository"
+	"github.com/eventpulse/backend/internal/ws"
+	"github.com/gin-gonic/gin"
+)
+
+// ─── Plantillas de evento ─────────────────────────────────────────────────────
+
+type PlantillaHandler struct {
+	plantillaRepo *repository.PlantillaRepo
+	eventoRepo    *repository.EventoRepo
+	hub           *ws.Hub
+}
+
+func NewPlantillaHandler(p *repository.PlantillaRepo, e *repository.EventoRepo, h *ws.Hub) *PlantillaHandler {
+	return &PlantillaHandler{plantillaRepo: p, eventoRepo: e, hub: h}
+}
+
+// POST /api/v1/plantillas  [solo admin] guarda la preparación de un evento
+func (h *PlantillaHandler) Crear(c *gin.Context) {
+	var req models.CrearPlantillaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	ctx := c.Request.Context()
+	if ev, err := h.eventoRepo.ObtenerPorID(ctx, req.EventoID); err != nil || ev == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Evento no encontrado"})
+		return
+	}
+	contenido, err := h.plantillaRepo.Capturar(ctx, req.EventoID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error leyendo el evento"})
+		return
+	}
+	plantilla, err := h.plantillaRepo.Crear(ctx, &req, contenido, middleware.GetUsuarioID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error guardando la plantilla"})
+		return
+	}
+	c.JSON(http.StatusCreated, plantilla)
+}
+
+// GET /api/v1/plantillas  [solo admin] sin contenido, con lo que trae cada parte
+func (h *PlantillaHandler) Listar(c *gin.Context) {
+	lista, err := h.plantillaRepo.Listar(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error listando plantillas"})
+		return
+	}
+	c.JSON(http.StatusOK, lista)
+}
+
+// GET /api/v1/plantillas/:id  [solo admin]
+func (h *PlantillaHandler) Obtener(c *gin.Context) {
+	plantilla, err := h.plantillaRepo.ObtenerPorID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error leyendo la plantilla"})
+		return
+	}
+	if plantilla == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Plantilla no encontrada"})
+		return
+	}
+	c.JSON(http.StatusOK, plantilla)
+}
+
+// DELETE /api/v1/plantillas/:id  [solo admin] los eventos creados con ella no cambian
+func (h *PlantillaHandler) Eliminar(c *gin.Context) {
+	err := h.plantillaRepo.Eliminar(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, repository.ErrNoEncontrado) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Plantilla no encontrada"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error eliminando la plantilla"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// POST /api/v1/eventos/instanciar  [solo admin]
+// Copia una plantilla (plantilla_id) o la preparación de otro evento
+// (desde_evento_id) a un evento nuevo (evento) o a uno en borrador o
+// programado (evento_id). Con vista_previa=true devuelve el diff sin guardar
+// nada; el mismo pedido sin ella lo aplica.
+func (h *PlantillaHandler) Instanciar(c *gin.Context) {
+	var req models.InstanciarEventoRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if (req.PlantillaID == "") == (req.DesdeEventoID == "") {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Indica plantilla_id o desde_evento_id"})
+		return
+	}
+	if (req.Evento == nil) == (req.EventoID == "") {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Indica evento (nuevo) o evento_id (existente)"})
+		return
+	}
+	if req.DesdeEventoID != "" && req.DesdeEventoID == req.EventoID {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "El evento de origen y el de destino son el mismo"})
+		return
+	}
+	partes := map[models.ParteEvento]bool{}
+	for _, p := range req.Partes {
+		if !p.EsValida() {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "partes admite: zonas, tareas, personal, reglas, canales"})
+			return
+		}
+		partes[p] = true
+	}
+	if len(partes) == 0 {
+		for _, p := range models.PartesEvento {
+			partes[p] = true
+		}
+	}
+	if req.Evento != nil {
+		programa := models.Evento{InicioProgramado: req.Evento.InicioProgramado, FinProgramado: req.Evento.FinProgramado}
+		if msg := validarPrograma(&programa, true, true); msg != "" {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: msg})
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	if req.EventoID != "" {
+		destino, err := h.eventoRepo.ObtenerPorID(ctx, req.EventoID)
+		if err != nil || destino == nil {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Evento de destino no encontrado"})
+			return
+		}
+		if destino.Estado != models.EventoBorrador && destino.Estado != models.EventoProgramado {
+			c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Solo se copia a un evento en borrador o programado"})
+			return
+		}
+	}
+	contenido, status, msg := h.contenidoOrigen(ctx, &req)
+	if msg != "" {
+		c.JSON(status, models.ErrorResponse{Error: msg})
+		return
+	}
+
+	inst, err := h.plantillaRepo.Instanciar(ctx, contenido, partes, req.EventoID, req.Evento, middleware.GetUsuarioID(c), req.VistaPrevia)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate") || strings.Contains(err.Error(), "unique") {
+			c.JSON(http.StatusConflict, models.ErrorResponse{Error: "El evento de destino cambió mientras tanto; reintenta"})
+			return
+		}
+		log.Println("❌ Error instanciando evento:", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error copiando la preparación del evento"})
+		return
+	}
+	if req.VistaPrevia {
+		c.JSON(http.StatusOK, inst)
+		return
+	}
+	if req.Evento != nil && inst.Evento.Estado == models.EventoProgramado {
+		go h.hub.Publicar(context.Background(), inst.Evento.ID, models.EventoWS{
+			Tipo:     models.WSEventoProgramado,
+			Payload:  inst.Evento,
+			EventoID: inst.Evento.ID,
+		})
+	}
+	c.JSON(http.StatusCreated, inst)
+}
+
+// contenidoOrigen lee la plantilla o captura el otro evento en este momento
+func (h *PlantillaHandler) contenidoOrigen(ctx context.Context, req *models.InstanciarEventoRequest) (*models.ContenidoPlantilla, int, string) {
+	if req.PlantillaID != "" {
+		plantilla, err := h.plantillaRepo.ObtenerPorID(ctx, req.PlantillaID)
+		if err != nil {
+			return nil, http.StatusInternalServerError, "Error leyendo la plantilla"
+		}
+		if plantilla == nil {
+			return nil, http.StatusNotFound, "Plantilla no encontrada"
+		}
+		return plantilla.Contenido, 0, ""
+	}
+	if ev, err := h.eventoRepo.ObtenerPorID(ctx, req.DesdeEventoID); err != nil || ev == nil {
+		return nil, http.StatusNotFound, "Evento de origen no encontrado"
+	}
+	contenido, err := h.plantillaRepo.Capturar(ctx, req.DesdeEventoID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, "Error leyendo el evento de origen"
+	}
+	return contenido, 0, ""
+}
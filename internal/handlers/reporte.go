@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/eventpulse/backend/internal/middleware"
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/eventpulse/backend/internal/reportes"
+	"github.com/eventpulse/backend/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// ─── Reportes de evento ───────────────────────────────────────────────────────
+
+type ReporteHandler struct {
+	reporteRepo *repository.ReporteRepo
+	eventoRepo  *repository.EventoRepo
+	generador   *reportes.Generador
+}
+
+func NewReporteHandler(r *repository.ReporteRepo, e *repository.EventoRepo, g *reportes.Generador) *ReporteHandler {
+	return &ReporteHandler{reporteRepo: r, eventoRepo: e, generador: g}
+}
+
+// POST /api/v1/eventos/:id/reportes  [solo admin] {"formato": "pdf" | "csv"}
+// Responde 202 con el trabajo; el estado se consulta en GET /reportes/:id.
+// Si ya hay uno igual en curso se devuelve ese.
+func (h *ReporteHandler) Solicitar(c *gin.Context) {
+	var req models.SolicitarReporteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if !req.Formato.EsValido() {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "formato admite: pdf, csv"})
+		return
+	}
+	ctx := c.Request.Context()
+	if ev, err := h.eventoRepo.ObtenerPorID(ctx, c.Param("id")); err != nil || ev == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Evento no encontrado"})
+		return
+	}
+	rep, err := h.generador.Solicitar(ctx, c.Param("id"), req.Formato, middleware.GetUsuarioID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error pidiendo el reporte"})
+		return
+	}
+	c.Header("Location", "/api/v1/reportes/"+rep.ID)
+	c.JSON(http.StatusAccepted, rep)
+}
+
+// GET /api/v1/eventos/:id/reportes  [solo admin] los pedidos del evento, del más nuevo
+func (h *ReporteHandler) Listar(c *gin.Context) {
+	lista, err := h.reporteRepo.Listar(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error listando reportes"})
+		return
+	}
+	c.JSON(http.StatusOK, lista)
+}
+
+// GET /api/v1/reportes/:id  [solo admin] estado del trabajo
+func (h *ReporteHandler) Obtener(c *gin.Context) {
+	rep, err := h.reporteRepo.ObtenerPorID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error leyendo el reporte"})
+		return
+	}
+	if rep == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Reporte no encontrado"})
+		return
+	}
+	c.JSON(http.StatusOK, rep)
+}
+
+// GET /api/v1/reportes/:id/archivo  [solo admin] PDF o ZIP, cuando está listo
+func (h *ReporteHandler) Descargar(c *gin.Context) {
+	ctx := c.Request.Context()
+	rep, err := h.reporteRepo.ObtenerPorID(ctx, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error leyendo el reporte"})
+		return
+	}
+	if rep == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Reporte no encontrado"})
+		return
+	}
+	if rep.Estado != models.ReporteListo {
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "El reporte todavía no está listo (" + string(rep.Estado) + ")"})
+		return
+	}
+	archivo, err := h.reporteRepo.Archivo(ctx, rep.ID)
+	if err != nil || archivo == nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error leyendo el archivo del reporte"})
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+*rep.NombreArchivo+`"`)
+	c.Data(http.StatusOK, reportes.MIME(rep.Formato), archivo)
+}
@@ -0,0 +1,370 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eventpulse/backend/internal/middleware"
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/eventpulse/backend/internal/notificaciones"
+	"github.com/eventpulse/backend/internal/repository"
+	"github.com/eventpulse/backend/internal/ws"
+	"github.com/gin-gonic/gin"
+)
+
+// ─── SOS ──────────────────────────────────────────────────────────────────────
+
+type SOSHandler struct {
+	repo           *repository.SOSRepo
+	incidenciaRepo *repository.IncidenciaRepo
+	eventoRepo     *repository.EventoRepo
+	hub            *ws.Hub
+	notificador    *notificaciones.Notificador
+}
+
+func NewSOSHandler(r *repository.SOSRepo, i *repository.IncidenciaRepo, e *repository.EventoRepo, h *ws.Hub, n *notificaciones.Notificador) *SOSHandler {
+	return &SOSHandler{repo: r, incidenciaRepo: i, eventoRepo: e, hub: h, notificador: n}
+}
+
+// Zona de la incidencia cuando no se sabe dónde está quien pidió auxilio
+const zonaDesconocida = "desconocida"
+
+// esperaComandoWS acota un comando recibido por WebSocket: no tiene request
+// que lo cancele, y con la base o Redis colgados no debe quedar esperando
+const esperaComandoWS = 10 * time.Second
+
+var (
+	errSOSCerrado  = errors.New("el SOS ya fue resuelto")
+	errSOSAjeno    = errors.New("el SOS es de otra persona")
+	errCoordenadas = errors.New("coordenadas fuera de rango")
+)
+
+// POST /api/v1/sos  un toque: crea (o devuelve, si ya hay uno abierto) el SOS
+func (h *SOSHandler) Activar(c *gin.Context) {
+	var req models.ActivarSOSRequest
+	// Cuerpo vacío es válido: el botón no obliga a nada
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+			return
+		}
+	}
+	ctx := c.Request.Context()
+	eventoID := middleware.GetEventoID(c)
+	if eventoID == "" {
+		if ev, _ := h.eventoRepo.ObtenerActivo(ctx); ev != nil {
+			eventoID = ev.ID
+		}
+	}
+	if eventoID == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "No hay evento activo"})
+		return
+	}
+	sos, nuevo, err := h.activar(ctx, eventoID, middleware.GetUsuarioID(c), &req)
+	if errors.Is(err, errCoordenadas) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Coordenadas fuera de rango"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error activando SOS"})
+		return
+	}
+	if !nuevo {
+		c.JSON(http.StatusOK, sos)
+		return
+	}
+	c.JSON(http.StatusCreated, sos)
+}
+
+// ComandoSOS es el mismo botón por WebSocket: {"tipo":"sos","payload":{...}}.
+// La confirmación le llega al propio usuario como WSSOS.
+func (h *SOSHandler) ComandoSOS(cl *ws.Cliente, payload json.RawMessage) {
+	var req models.ActivarSOSRequest
+	if len(payload) > 0 && string(payload) != "null" {
+		if err := json.Unmarshal(payload, &req); err != nil {
+			log.Printf("WS SOS inválido de %s: %v", cl.UsuarioID, err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), esperaComandoWS)
+	defer cancel()
+	eventoID := cl.EventoID
+	if eventoID == "" {
+		if ev, _ := h.eventoRepo.ObtenerActivo(ctx); ev != nil {
+			eventoID = ev.ID
+		}
+	}
+	if eventoID == "" {
+		return
+	}
+	if errors.Is(ubicacionValida(req.Latitud, req.Longitud), errCoordenadas) {
+		req.Latitud, req.Longitud = nil, nil // mejor sin ubicación que sin alerta
+	}
+	if _, _, err := h.activar(ctx, eventoID, cl.UsuarioID, &req); err != nil {
+		log.Printf("❌ Error activando SOS de %s por WS: %v", cl.UsuarioID, err)
+	}
+}
+
+// GET /api/v1/sos/activo  el SOS abierto del usuario (para retomar el envío de ubicación)
+func (h *SOSHandler) MiSOS(c *gin.Context) {
+	sos, err := h.repo.ActivaDe(c.Request.Context(), middleware.GetUsuarioID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error buscando SOS"})
+		return
+	}
+	if sos == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "No tienes un SOS abierto"})
+		return
+	}
+	c.JSON(http.StatusOK, sos)
+}
+
+// POST /api/v1/sos/:id/ubicacion  solo quien lo activó, mientras siga abierto
+func (h *SOSHandler) Ubicacion(c *gin.Context) {
+	var req models.UbicacionSOSRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	u, err := h.ubicar(c.Request.Context(), middleware.GetUsuarioID(c), c.Param("id"), &req)
+	switch {
+	case errors.Is(err, repository.ErrNoEncontrado):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "SOS no encontrado"})
+	case errors.Is(err, errSOSAjeno):
+		c.JSON(http.StatusForbidden, models.ErrorResponse{Error: "Solo quien activó el SOS envía su ubicación"})
+	case errors.Is(err, errSOSCerrado):
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "El SOS ya fue resuelto"})
+	case errors.Is(err, errCoordenadas):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Coordenadas fuera de rango"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error registrando ubicación"})
+	default:
+		c.JSON(http.StatusCreated, u)
+	}
+}
+
+// ComandoUbicacion: {"tipo":"sos_ubicacion","payload":{"sos_id","latitud","longitud"}}
+func (h *SOSHandler) ComandoUbicacion(cl *ws.Cliente, payload json.RawMessage) {
+	var req models.UbicacionSOSRequest
+	if err := json.Unmarshal(payload, &req); err != nil || req.SOSID == "" || req.Latitud == nil || req.Longitud == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), esperaComandoWS)
+	defer cancel()
+	if _, err := h.ubicar(ctx, cl.UsuarioID, req.SOSID, &req); err != nil && !errors.Is(err, errSOSCerrado) {
+		log.Printf("WS ubicación SOS de %s descartada: %v", cl.UsuarioID, err)
+	}
+}
+
+// GET /api/v1/sos?activas=true  [admin, supervisor, guardia, medico]
+func (h *SOSHandler) Listar(c *gin.Context) {
+	ctx := c.Request.Context()
+	eventoID := middleware.GetEventoID(c)
+	if eventoID == "" {
+		if ev, _ := h.eventoRepo.ObtenerActivo(ctx); ev != nil {
+			eventoID = ev.ID
+		}
+	}
+	if eventoID == "" {
+		c.JSON(http.StatusOK, []models.SOS{})
+		return
+	}
+	lista, err := h.repo.Listar(ctx, eventoID, c.Query("activas") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error listando SOS"})
+		return
+	}
+	c.JSON(http.StatusOK, lista)
+}
+
+// GET /api/v1/sos/:id/ubicaciones?limite=200  rastro; quien respondió o quien lo activó
+func (h *SOSHandler) Ubicaciones(c *gin.Context) {
+	ctx := c.Request.Context()
+	sos, err := h.repo.ObtenerPorID(ctx, c.Param("id"))
+	if err != nil || sos == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "SOS no encontrado"})
+		return
+	}
+	if sos.UsuarioID != middleware.GetUsuarioID(c) && !atiendeSOS(middleware.GetRol(c)) {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{Error: "Tu rol no puede ver este SOS"})
+		return
+	}
+	limite, _ := strconv.Atoi(c.DefaultQuery("limite", "200"))
+	if limite <= 0 || limite > 2000 {
+		limite = 200
+	}
+	lista, err := h.repo.Ubicaciones(ctx, sos.ID, limite)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error listando ubicaciones"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"sos": sos, "ubicaciones": lista})
+}
+
+// AlPublicar observa el Hub: cuando una incidencia SOS se resuelve avisa a la
+// app de quien la activó (para que deje de mandar ubicación) y a quienes respondían.
+func (h *SOSHandler) AlPublicar(ctx context.Context, eventoID string, evento models.EventoWS) {
+	if evento.Tipo != models.WSIncidenciaActualizada {
+		return
+	}
+	inc, ok := evento.Payload.(*models.Incidencia)
+	if !ok || inc.Tipo != models.TipoSOS || inc.Estado != models.IncidenciaResuelta {
+		return
+	}
+	sos, err := h.repo.ObtenerPorIncidencia(ctx, inc.ID)
+	if err != nil || sos == nil {
+		return
+	}
+	h.avisar(ctx, sos, models.EventoWS{Tipo: models.WSSOSFinalizado, Payload: sos, EventoID: eventoID})
+}
+
+func (h *SOSHandler) activar(ctx context.Context, eventoID, usuarioID string, req *models.ActivarSOSRequest) (*models.SOS, bool, error) {
+	if err := ubicacionValida(req.Latitud, req.Longitud); err != nil {
+		return nil, false, err
+	}
+	// Sin ubicación en el toque: la última conocida
+	var inicial *models.UbicacionSOS
+	zona := zonaDesconocida
+	if req.Latitud != nil && req.Longitud != nil {
+		inicial = &models.UbicacionSOS{
+			Latitud: *req.Latitud, Longitud: *req.Longitud, PrecisionM: req.PrecisionM,
+			ZonaID: req.ZonaID, RegistradaEn: time.Now(),
+		}
+	}
+	if req.ZonaID != nil && *req.ZonaID != "" {
+		zona = *req.ZonaID
+	}
+	if inicial == nil || zona == zonaDesconocida {
+		previa, err := h.repo.UltimaPosicion(ctx, usuarioID)
+		if err != nil {
+			log.Println("❌ Error buscando última posición para SOS:", err)
+		}
+		if previa != nil {
+			if inicial == nil && previa.Latitud != nil && previa.Longitud != nil {
+				inicial = &models.UbicacionSOS{
+					Latitud: *previa.Latitud, Longitud: *previa.Longitud,
+					ZonaID: previa.ZonaID, RegistradaEn: previa.En,
+				}
+			}
+			if zona == zonaDesconocida && previa.ZonaID != nil {
+				zona = *previa.ZonaID
+			}
+		}
+	}
+
+	descripcion := "SOS: pidió auxilio"
+	if req.Nota != nil && strings.TrimSpace(*req.Nota) != "" {
+		descripcion += " — " + strings.TrimSpace(*req.Nota)
+	}
+	sos, nuevo, err := h.repo.Activar(ctx, eventoID, usuarioID, zona, descripcion, inicial)
+	if err != nil {
+		return nil, false, err
+	}
+	if !nuevo {
+		// Reintento: solo se le confirma a quien lo activó
+		h.hub.PublicarAUsuario(ctx, usuarioID, models.EventoWS{Tipo: models.WSSOS, Payload: sos, EventoID: eventoID})
+		return sos, false, nil
+	}
+	log.Printf("🆘 SOS %s activado por %s (zona %s)", sos.ID, sos.NombreUsuario, sos.ZonaID)
+
+	// Sin triage: va directo a la incidencia del evento y a toda la respuesta
+	if inc, err := h.incidenciaRepo.ObtenerPorID(ctx, sos.IncidenciaID); err == nil && inc != nil {
+		if err := h.hub.Publicar(ctx, eventoID, models.EventoWS{Tipo: models.WSIncidenciaNueva, Payload: inc, EventoID: eventoID}); err != nil {
+			log.Println("❌ Error publicando incidencia SOS en Redis:", err)
+		}
+	}
+	destinatarios := h.avisar(ctx, sos, models.EventoWS{Tipo: models.WSSOS, Payload: sos, EventoID: eventoID})
+	h.notificador.Notificar(sinUsuario(destinatarios, usuarioID), notificacionSOS(sos))
+	return sos, true, nil
+}
+
+func (h *SOSHandler) ubicar(ctx context.Context, usuarioID, sosID string, req *models.UbicacionSOSRequest) (*models.UbicacionSOS, error) {
+	if err := ubicacionValida(req.Latitud, req.Longitud); err != nil {
+		return nil, err
+	}
+	sos, err := h.repo.ObtenerPorID(ctx, sosID)
+	if err != nil {
+		return nil, err
+	}
+	if sos == nil {
+		return nil, repository.ErrNoEncontrado
+	}
+	if sos.UsuarioID != usuarioID {
+		return nil, errSOSAjeno
+	}
+	if !sos.Activa {
+		return nil, errSOSCerrado
+	}
+	u, err := h.repo.RegistrarUbicacion(ctx, sos.ID, req)
+	if err != nil {
+		return nil, err
+	}
+	h.avisar(ctx, sos, models.EventoWS{Tipo: models.WSSOSUbicacion, Payload: u, EventoID: sos.EventoID})
+	return u, nil
+}
+
+// avisar publica a quien activó el SOS y a todos los que responden; devuelve
+// los destinatarios
+func (h *SOSHandler) avisar(ctx context.Context, sos *models.SOS, evento models.EventoWS) []string {
+	ids, err := h.repo.IDsRespuesta(ctx, sos.EventoID)
+	if err != nil {
+		log.Println("❌ Error buscando destinatarios del SOS:", err)
+	}
+	ids = append(sinUsuario(ids, sos.UsuarioID), sos.UsuarioID)
+	if err := h.hub.PublicarAUsuarios(ctx, ids, evento); err != nil {
+		log.Printf("❌ Error publicando %s en Redis: %v", evento.Tipo, err)
+	}
+	return ids
+}
+
+func ubicacionValida(lat, lng *float64) error {
+	if (lat == nil) != (lng == nil) {
+		return errCoordenadas
+	}
+	if lat != nil && (*lat < -90 || *lat > 90 || *lng < -180 || *lng > 180) {
+		return errCoordenadas
+	}
+	return nil
+}
+
+func atiendeSOS(rol models.Rol) bool {
+	switch rol {
+	case models.RolAdmin, models.RolSupervisor, models.RolGuardia, models.RolMedico:
+		return true
+	}
+	return false
+}
+
+func sinUsuario(ids []string, usuarioID string) []string {
+	out := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id != usuarioID {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+func notificacionSOS(s *models.SOS) models.Notificacion {
+	zona := s.ZonaID
+	if s.ZonaNombre != nil {
+		zona = *s.ZonaNombre
+	}
+	cuerpo := "Zona: " + zona
+	if s.Latitud != nil && s.Longitud != nil {
+		cuerpo += fmt.Sprintf(". Ubicación: %.6f,%.6f", *s.Latitud, *s.Longitud)
+	}
+	return models.Notificacion{
+		Tipo:    models.NotifSOS,
+		Titulo:  fmt.Sprintf("SOS: %s (%s) pide auxilio", s.NombreUsuario, s.RolUsuario),
+		Cuerpo:  cuerpo,
+		Urgente: true,
+		Ref:     s.IncidenciaID,
+	}
+}
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/eventpulse/backend/internal/middleware"
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/eventpulse/backend/internal/repository"
+	"github.com/eventpulse/backend/internal/ubicaciones"
+	"github.com/eventpulse/backend/internal/ws"
+	"github.com/gin-gonic/gin"
+)
+
+// ─── Ubicación del staff ──────────────────────────────────────────────────────
+
+type UbicacionHandler struct {
+	repo       *repository.UbicacionRepo
+	eventoRepo *repository.EventoRepo
+	rastreador *ubicaciones.Rastreador
+}
+
+func NewUbicacionHandler(r *repository.UbicacionRepo, e *repository.EventoRepo, ra *ubicaciones.Rastreador) *UbicacionHandler {
+	return &UbicacionHandler{repo: r, eventoRepo: e, rastreador: ra}
+}
+
+// POST /api/v1/ubicaciones/turno  inicia el turno: desde aquí se acepta ubicación
+func (h *UbicacionHandler) IniciarTurno(c *gin.Context) {
+	ctx := c.Request.Context()
+	eventoID := middleware.GetEventoID(c)
+	if eventoID == "" {
+		if ev, _ := h.eventoRepo.ObtenerActivo(ctx); ev != nil {
+			eventoID = ev.ID
+		}
+	}
+	if eventoID == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "No hay evento activo"})
+		return
+	}
+	turno, nuevo, err := h.rastreador.IniciarTurno(ctx, middleware.GetUsuarioID(c), eventoID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error iniciando turno"})
+		return
+	}
+	if !nuevo {
+		c.JSON(http.StatusOK, turno)
+		return
+	}
+	c.JSON(http.StatusCreated, turno)
+}
+
+// GET /api/v1/ubicaciones/turno  el turno abierto propio
+func (h *UbicacionHandler) MiTurno(c *gin.Context) {
+	turno, err := h.repo.TurnoAbierto(c.Request.Context(), middleware.GetUsuarioID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error buscando turno"})
+		return
+	}
+	if turno == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "No tienes un turno abierto"})
+		return
+	}
+	c.JSON(http.StatusOK, turno)
+}
+
+// DELETE /api/v1/ubicaciones/turno  termina el turno propio y deja de rastrear
+func (h *UbicacionHandler) TerminarTurno(c *gin.Context) {
+	h.terminar(c, middleware.GetUsuarioID(c), nil, models.FinPorUsuario)
+}
+
+// DELETE /api/v1/ubicaciones/turnos/:usuarioId  [admin, supervisor]
+// El supervisor solo cierra turnos de su evento
+func (h *UbicacionHandler) TerminarTurnoDe(c *gin.Context) {
+	eventoID, ok := alcanceSupervisor(c)
+	if !ok {
+		return
+	}
+	h.terminar(c, c.Param("usuarioId"), eventoID, models.FinPorSupervisor)
+}
+
+// alcanceSupervisor limita al supervisor a su propio evento, como el mapa en
+// vivo; el admin no tiene límite (nil). Si el supervisor no tiene evento ya
+// respondió 403 y devuelve ok=false.
+func alcanceSupervisor(c *gin.Context) (*string, bool) {
+	if middleware.GetRol(c) != models.RolSupervisor {
+		return nil, true
+	}
+	eventoID := middleware.GetEventoID(c)
+	if eventoID == "" {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{Error: "No estás asignado a un evento"})
+		return nil, false
+	}
+	return &eventoID, true
+}
+
+// POST /api/v1/ubicaciones  reporte periódico de GPS o check-in de zona (QR/baliza)
+func (h *UbicacionHandler) Reportar(c *gin.Context) {
+	var req models.UbicacionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	u, err := h.rastreador.Registrar(c.Request.Context(), middleware.GetUsuarioID(c), &req)
+	switch {
+	case errors.Is(err, ubicaciones.ErrSinTurno):
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "No tienes un turno abierto: inicia turno para compartir ubicación"})
+	case errors.Is(err, ubicaciones.ErrUbicacionInvalida):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Ubicación inválida: gps requiere latitud y longitud; qr y baliza, zona_id"})
+	case errors.Is(err, ubicaciones.ErrZonaInexistente):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "La zona no existe en el evento"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error registrando ubicación"})
+	default:
+		c.JSON(http.StatusCreated, u)
+	}
+}
+
+// ComandoUbicacion: {"tipo":"ubicacion","payload":{"latitud","longitud"}}
+func (h *UbicacionHandler) ComandoUbicacion(cl *ws.Cliente, payload json.RawMessage) {
+	var req models.UbicacionRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), esperaComandoWS)
+	defer cancel()
+	_, err := h.rastreador.Registrar(ctx, cl.UsuarioID, &req)
+	if err != nil && !errors.Is(err, ubicaciones.ErrSinTurno) {
+		log.Printf("WS ubicación de %s descartada: %v", cl.UsuarioID, err)
+	}
+}
+
+// GET /api/v1/ubicaciones  mapa en vivo [admin, supervisor]
+func (h *UbicacionHandler) EnVivo(c *gin.Context) {
+	ctx := c.Request.Context()
+	eventoID := middleware.GetEventoID(c)
+	if eventoID == "" {
+		if ev, _ := h.eventoRepo.ObtenerActivo(ctx); ev != nil {
+			eventoID = ev.ID
+		}
+	}
+	if eventoID == "" {
+		c.JSON(http.StatusOK, []models.Ubicacion{})
+		return
+	}
+	lista, err := h.rastreador.EnVivo(ctx, eventoID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error leyendo ubicaciones"})
+		return
+	}
+	c.JSON(http.StatusOK, lista)
+}
+
+// GET /api/v1/ubicaciones/usuarios/:usuarioId/rastro?desde=&hasta=&limite=
+// Historial muestreado para revisar un incidente; el propio usuario o admin/supervisor.
+func (h *UbicacionHandler) Rastro(c *gin.Context) {
+	usuarioID := c.Param("usuarioId")
+	rol := middleware.GetRol(c)
+	if usuarioID != middleware.GetUsuarioID(c) && rol != models.RolAdmin && rol != models.RolSupervisor {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{Error: "Solo admin o supervisor ven el rastro de otra persona"})
+		return
+	}
+	// El propio rastro se ve completo; el de otros, el supervisor solo en su evento
+	var eventoID *string
+	if usuarioID != middleware.GetUsuarioID(c) {
+		var ok bool
+		if eventoID, ok = alcanceSupervisor(c); !ok {
+			return
+		}
+	}
+	hasta := time.Now()
+	desde := hasta.Add(-24 * time.Hour)
+	for param, destino := range map[string]*time.Time{"desde": &desde, "hasta": &hasta} {
+		if v := c.Query(param); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: param + " debe ser RFC 3339"})
+				return
+			}
+			*destino = t
+		}
+	}
+	if !desde.Before(hasta) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "desde debe ser anterior a hasta"})
+		return
+	}
+	limite, _ := strconv.Atoi(c.DefaultQuery("limite", "1000"))
+	if limite <= 0 || limite > 5000 {
+		limite = 1000
+	}
+	ctx := c.Request.Context()
+	puntos, err := h.repo.Rastro(ctx, usuarioID, eventoID, desde, hasta, limite)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error leyendo rastro"})
+		return
+	}
+	turnos, err := h.repo.Turnos(ctx, usuarioID, eventoID, desde, hasta)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error leyendo turnos"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"turnos": turnos, "ubicaciones": puntos})
+}
+
+func (h *UbicacionHandler) terminar(c *gin.Context, usuarioID string, eventoID *string, motivo models.MotivoFinTurno) {
+	turno, err := h.rastreador.TerminarTurno(c.Request.Context(), usuarioID, eventoID, motivo)
+	if errors.Is(err, repository.ErrNoEncontrado) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "No hay turno abierto"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error terminando turno"})
+		return
+	}
+	c.JSON(http.StatusOK, turno)
+}
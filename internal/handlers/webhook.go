@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eventpulse/backend/internal/middleware"
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/eventpulse/backend/internal/repository"
+	"github.com/eventpulse/backend/internal/webhooks"
+	"github.com/gin-gonic/gin"
+)
+
+// ─── Webhook ──────────────────────────────────────────────────────────────────
+
+type WebhookHandler struct {
+	repo        *repository.WebhookRepo
+	eventoRepo  *repository.EventoRepo
+	despachador *webhooks.Despachador
+}
+
+func NewWebhookHandler(r *repository.WebhookRepo, e *repository.EventoRepo, d *webhooks.Despachador) *WebhookHandler {
+	return &WebhookHandler{repo: r, eventoRepo: e, despachador: d}
+}
+
+// POST /api/v1/webhooks  [solo admin] suscribe una URL a tipos de evento del evento activo
+func (h *WebhookHandler) Crear(c *gin.Context) {
+	var req models.CrearWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if !tiposWebhookValidos(c, req.Tipos) {
+		return
+	}
+	ctx := c.Request.Context()
+	evento, err := h.eventoRepo.ObtenerActivo(ctx)
+	if err != nil || evento == nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "No hay evento activo"})
+		return
+	}
+	secreto := make([]byte, 32)
+	if _, err := rand.Read(secreto); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error generando secreto"})
+		return
+	}
+	w, err := h.repo.Crear(ctx, evento.ID, middleware.GetUsuarioID(c), hex.EncodeToString(secreto), &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error creando webhook"})
+		return
+	}
+	// Única vez que se devuelve el secreto
+	c.JSON(http.StatusCreated, w)
+}
+
+// GET /api/v1/webhooks  [solo admin]
+func (h *WebhookHandler) Listar(c *gin.Context) {
+	ctx := c.Request.Context()
+	evento, err := h.eventoRepo.ObtenerActivo(ctx)
+	if err != nil || evento == nil {
+		c.JSON(http.StatusOK, []models.Webhook{})
+		return
+	}
+	lista, err := h.repo.Listar(ctx, evento.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error listando webhooks"})
+		return
+	}
+	c.JSON(http.StatusOK, lista)
+}
+
+// PATCH /api/v1/webhooks/:id  [solo admin] cambiar URL, tipos o activar/desactivar
+func (h *WebhookHandler) Editar(c *gin.Context) {
+	var req models.EditarWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if req.Tipos != nil && len(req.Tipos) == 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "tipos no puede quedar vacío"})
+		return
+	}
+	if !tiposWebhookValidos(c, req.Tipos) {
+		return
+	}
+	version, err := versionIfMatch(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	w, err := h.repo.Editar(c.Request.Context(), c.Param("id"), &req, version)
+	switch {
+	case errors.Is(err, repository.ErrNoEncontrado):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Webhook no encontrado"})
+	case errors.Is(err, repository.ErrVersionConflicto):
+		c.JSON(http.StatusPreconditionFailed, models.ErrorResponse{Error: "El webhook cambió desde que lo leíste. Recarga e intenta de nuevo"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error actualizando webhook"})
+	default:
+		c.Header("ETag", etag(w.Version))
+		c.JSON(http.StatusOK, w)
+	}
+}
+
+// DELETE /api/v1/webhooks/:id  [solo admin] borra la suscripción y su registro de entregas
+func (h *WebhookHandler) Eliminar(c *gin.Context) {
+	if err := h.repo.Eliminar(c.Request.Context(), c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Webhook no encontrado"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"mensaje": "Webhook eliminado"})
+}
+
+// GET /api/v1/webhooks/:id/entregas?estado=muerta&limite=50  [solo admin]
+func (h *WebhookHandler) Entregas(c *gin.Context) {
+	ctx := c.Request.Context()
+	w, err := h.repo.ObtenerPorID(ctx, c.Param("id"))
+	if err != nil || w == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Webhook no encontrado"})
+		return
+	}
+	estado := c.Query("estado")
+	switch models.EstadoEntrega(estado) {
+	case "", models.EntregaPendiente, models.EntregaEntregada, models.EntregaMuerta:
+	default:
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Estado inválido. Válidos: pendiente, entregada, muerta"})
+		return
+	}
+	limite, _ := strconv.Atoi(c.DefaultQuery("limite", "50"))
+	if limite <= 0 || limite > 500 {
+		limite = 50
+	}
+	lista, err := h.repo.ListarEntregas(ctx, w.ID, estado, limite)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error listando entregas"})
+		return
+	}
+	c.JSON(http.StatusOK, lista)
+}
+
+// POST /api/v1/webhooks/entregas/:id/reenviar  [solo admin] replay manual
+func (h *WebhookHandler) Reenviar(c *gin.Context) {
+	ctx := c.Request.Context()
+	e, err := h.repo.ObtenerEntrega(ctx, c.Param("id"))
+	if err != nil || e == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Entrega no encontrada"})
+		return
+	}
+	if e.Estado == models.EntregaPendiente {
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "La entrega ya está en cola"})
+		return
+	}
+	e, err = h.repo.Reenviar(ctx, e.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error reenviando entrega"})
+		return
+	}
+	h.despachador.Programar(ctx, e.ID, time.Now())
+	c.JSON(http.StatusAccepted, e)
+}
+
+func tiposWebhookValidos(c *gin.Context, tipos []models.TipoEventoWS) bool {
+	for _, t := range tipos {
+		if !t.AdmiteWebhook() {
+			validos := make([]string, len(models.TiposWebhook))
+			for i, v := range models.TiposWebhook {
+				validos[i] = string(v)
+			}
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error: "Tipo inválido: " + string(t) + ". Válidos: " + strings.Join(validos, ", "),
+			})
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,479 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/eventpulse/backend/internal/geo"
+	"github.com/eventpulse/backend/internal/middleware"
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/eventpulse/backend/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// ─── Zona: jerarquía y geometría ──────────────────────────────────────────────
+
+// GET /api/v1/zonas/arbol  sector → area → punto; las zonas sin padre son raíces
+func (h *ZonaHandler) Arbol(c *gin.Context) {
+	_, lista, ok := h.listarDelEvento(c)
+	if !ok {
+		return
+	}
+	nodos := make(map[string]*models.ZonaNodo, len(lista))
+	for _, z := range lista {
+		nodos[z.ID] = &models.ZonaNodo{Zona: z, Hijas: []*models.ZonaNodo{}}
+	}
+	raices := []*models.ZonaNodo{}
+	for _, z := range lista {
+		n := nodos[z.ID]
+		if z.PadreID != nil {
+			if padre, ok := nodos[*z.PadreID]; ok {
+				padre.Hijas = append(padre.Hijas, n)
+				continue
+			}
+		}
+		raices = append(raices, n)
+	}
+	c.JSON(http.StatusOK, raices)
+}
+
+// GET /api/v1/zonas/geojson?capas=incidencias,tareas
+// FeatureCollection de las zonas (Polygon, Point o sin geometría) con sus
+// incidencias y tareas abiertas contadas en properties. Cada capa pedida
+// agrega un Point por elemento, en el centro de su zona.
+func (h *ZonaHandler) GeoJSON(c *gin.Context) {
+	eventoID, lista, ok := h.listarDelEvento(c)
+	if !ok {
+		return
+	}
+	elementos, err := h.zonaRepo.ElementosAbiertos(c.Request.Context(), eventoID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error leyendo incidencias y tareas"})
+		return
+	}
+	capas := map[string]bool{}
+	for _, capa := range strings.Split(c.Query("capas"), ",") {
+		capas[strings.TrimSuffix(strings.TrimSpace(capa), "s")] = true // incidencias → incidencia
+	}
+
+	abiertas := map[string]map[string]int{} // zona → capa → cantidad
+	for _, e := range elementos {
+		if abiertas[e.ZonaID] == nil {
+			abiertas[e.ZonaID] = map[string]int{}
+		}
+		abiertas[e.ZonaID][e.Capa]++
+	}
+
+	col := models.ColeccionGeoJSON{Type: "FeatureCollection", Features: []models.FeatureGeoJSON{}}
+	zonas := make(map[string]*models.Zona, len(lista))
+	for i := range lista {
+		z := &lista[i]
+		zonas[z.ID] = z
+		col.Features = append(col.Features, models.FeatureGeoJSON{
+			Type:     "Feature",
+			ID:       z.ID,
+			Geometry: geometriaZona(z),
+			Properties: map[string]interface{}{
+				"capa":                 "zona",
+				"nombre":               z.Nombre,
+				"tipo":                 z.Tipo,
+				"padre_id":             z.PadreID,
+				"piso":                 z.Piso,
+				"radio_m":              z.RadioM,
+				"capacidad":            z.Capacidad,
+				"incidencias_abiertas": abiertas[z.ID]["incidencia"],
+				"tareas_abiertas":      abiertas[z.ID]["tarea"],
+			},
+		})
+	}
+	for _, e := range elementos {
+		z, ok := zonas[e.ZonaID]
+		if !capas[e.Capa] || !ok {
+			continue
+		}
+		lat, lng, ok := geo.Centro(z)
+		if !ok {
+			continue
+		}
+		col.Features = append(col.Features, models.FeatureGeoJSON{
+			Type:     "Feature",
+			ID:       e.ID,
+			Geometry: &models.GeometriaGeoJSON{Type: "Point", Coordinates: []float64{lng, lat}},
+			Properties: map[string]interface{}{
+				"capa":     e.Capa,
+				"zona_id":  e.ZonaID,
+				"etiqueta": e.Etiqueta,
+				"estado":   e.Estado,
+			},
+		})
+	}
+	c.Header("Content-Type", "application/geo+json")
+	c.JSON(http.StatusOK, col)
+}
+
+// GET /api/v1/zonas/cercana?latitud=&longitud=&piso=
+// La zona más específica que contiene el punto o, si ninguna, la más cercana
+func (h *ZonaHandler) Cercana(c *gin.Context) {
+	lat, errLat := strconv.ParseFloat(c.Query("latitud"), 64)
+	lng, errLng := strconv.ParseFloat(c.Query("longitud"), 64)
+	if errLat != nil || errLng != nil || lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Se requieren latitud y longitud válidas"})
+		return
+	}
+	var piso *int
+	if v := c.Query("piso"); v != "" {
+		p, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "piso debe ser un entero"})
+			return
+		}
+		piso = &p
+	}
+	eventoID := h.eventoDe(c)
+	if eventoID == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "No hay evento activo"})
+		return
+	}
+	zonas, err := h.zonaRepo.ConGeometria(c.Request.Context(), eventoID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error listando zonas"})
+		return
+	}
+	cercana := geo.Cercana(zonas, lat, lng, piso)
+	if cercana == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Ninguna zona del evento tiene geometría"})
+		return
+	}
+	c.JSON(http.StatusOK, cercana)
+}
+
+// ─── Zona: edición y retiro ───────────────────────────────────────────────────
+
+// PATCH /api/v1/zonas/:id  [solo admin]
+func (h *ZonaHandler) Editar(c *gin.Context) {
+	var req models.EditarZonaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	version, err := versionIfMatch(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	eventoID := h.eventoDe(c)
+	if eventoID == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "No hay evento activo"})
+		return
+	}
+	ctx := c.Request.Context()
+	actual, err := h.zonaRepo.ObtenerPorID(ctx, c.Param("id"), eventoID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error leyendo la zona"})
+		return
+	}
+	if actual == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Zona no encontrada"})
+		return
+	}
+	// Los cambios se aplican sobre actual: si ya no es la versión que leyó el
+	// cliente, validar contra ella no tiene sentido
+	if version != nil && *version != actual.Version {
+		c.JSON(http.StatusPreconditionFailed, models.ErrorResponse{Error: msgZonaConflicto})
+		return
+	}
+
+	// Se valida el estado resultante completo, no solo lo que cambia
+	z := models.CrearZonaRequest{
+		ID: actual.ID, Nombre: actual.Nombre, Tipo: actual.Tipo, PadreID: actual.PadreID,
+		Latitud: actual.Latitud, Longitud: actual.Longitud, RadioM: actual.RadioM,
+		Poligono: actual.Poligono, Piso: actual.Piso,
+		Capacidad: actual.Capacidad, UmbralOcupacion: actual.UmbralOcupacion,
+	}
+	for _, campo := range req.Quitar {
+		switch campo {
+		case "punto":
+			z.Latitud, z.Longitud, z.RadioM = nil, nil, nil
+		case "poligono":
+			z.Poligono = nil
+		case "piso":
+			z.Piso = nil
+		case "capacidad":
+			z.Capacidad, z.UmbralOcupacion = nil, nil
+		case "umbral_ocupacion":
+			z.UmbralOcupacion = nil
+		default:
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "quitar admite: punto, poligono, piso, capacidad, umbral_ocupacion"})
+			return
+		}
+	}
+	if req.Nombre != nil {
+		if strings.TrimSpace(*req.Nombre) == "" {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "El nombre no puede quedar vacío"})
+			return
+		}
+		z.Nombre = strings.TrimSpace(*req.Nombre)
+	}
+	if req.Tipo != nil {
+		z.Tipo = *req.Tipo
+	}
+	if req.PadreID != nil {
+		z.PadreID = req.PadreID
+		if *req.PadreID == "" {
+			z.PadreID = nil
+		}
+	}
+	if req.Latitud != nil || req.Longitud != nil {
+		z.Latitud, z.Longitud = req.Latitud, req.Longitud
+	}
+	if req.RadioM != nil {
+		z.RadioM = req.RadioM
+	}
+	if req.Poligono != nil {
+		z.Poligono = req.Poligono
+	}
+	if req.Piso != nil {
+		z.Piso = req.Piso
+	}
+	if req.Capacidad != nil {
+		z.Capacidad = req.Capacidad
+	}
+	if req.UmbralOcupacion != nil {
+		z.UmbralOcupacion = req.UmbralOcupacion
+	}
+	if msg := h.validarZona(ctx, eventoID, &z); msg != "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: msg})
+		return
+	}
+	// Bajar de nivel no puede dejar hijas al mismo nivel o por encima
+	if z.Tipo != actual.Tipo {
+		nivelHijas, err := h.zonaRepo.NivelMinimoHijas(ctx, actual.ID, eventoID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error leyendo las zonas hijas"})
+			return
+		}
+		if nivelHijas > 0 && nivelHijas <= z.Tipo.Nivel() {
+			c.JSON(http.StatusConflict, models.ErrorResponse{Error: "La zona tiene hijas que no caben dentro de una " + string(z.Tipo)})
+			return
+		}
+	}
+
+	zona, err := h.zonaRepo.Actualizar(ctx, &models.Zona{
+		ID: actual.ID, EventoID: eventoID, Nombre: z.Nombre, Tipo: z.Tipo, PadreID: z.PadreID,
+		Latitud: z.Latitud, Longitud: z.Longitud, RadioM: z.RadioM, Poligono: z.Poligono, Piso: z.Piso,
+		Capacidad: z.Capacidad, UmbralOcupacion: z.UmbralOcupacion,
+	}, &actual.Version)
+	switch {
+	case errors.Is(err, repository.ErrNoEncontrado):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Zona no encontrada"})
+	case errors.Is(err, repository.ErrVersionConflicto):
+		c.JSON(http.StatusPreconditionFailed, models.ErrorResponse{Error: msgZonaConflicto})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error actualizando zona"})
+	default:
+		c.Header("ETag", etag(zona.Version))
+		c.JSON(http.StatusOK, zona)
+	}
+}
+
+const msgZonaConflicto = "La zona cambió desde que la leíste. Recarga e intenta de nuevo"
+
+// POST /api/v1/zonas/:id/archivar?reasignar_a=  [solo admin]
+// Sale de listados, mapa y ubicación; incidencias y tareas cerradas la conservan
+func (h *ZonaHandler) Archivar(c *gin.Context) {
+	h.retirar(c, true)
+}
+
+// POST /api/v1/zonas/:id/restaurar  [solo admin]
+func (h *ZonaHandler) Restaurar(c *gin.Context) {
+	eventoID := h.eventoDe(c)
+	if eventoID == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "No hay evento activo"})
+		return
+	}
+	zona, err := h.zonaRepo.Restaurar(c.Request.Context(), c.Param("id"), eventoID)
+	if errors.Is(err, repository.ErrNoEncontrado) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Zona no encontrada"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error restaurando zona"})
+		return
+	}
+	c.JSON(http.StatusOK, zona)
+}
+
+// retirar borra o archiva. Con trabajo abierto y sin ?reasignar_a responde
+// 409 con las referencias para que el admin elija destino.
+func (h *ZonaHandler) retirar(c *gin.Context, archivar bool) {
+	eventoID := h.eventoDe(c)
+	if eventoID == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "No hay evento activo"})
+		return
+	}
+	zonaID := c.Param("id")
+	var reasignarA *string
+	if v := c.Query("reasignar_a"); v != "" {
+		if v == zonaID {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "No se puede reasignar a la misma zona"})
+			return
+		}
+		reasignarA = &v
+	}
+	res, ref, err := h.zonaRepo.Retirar(c.Request.Context(), zonaID, eventoID, reasignarA, archivar)
+	switch {
+	case errors.Is(err, repository.ErrZonaEnUso):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":       "La zona tiene trabajo abierto; indica reasignar_a con otra zona del evento",
+			"referencias": ref,
+		})
+	case errors.Is(err, repository.ErrNoEncontrado) && ref != nil:
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "La zona destino no existe o está archivada"})
+	case errors.Is(err, repository.ErrNoEncontrado):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Zona no encontrada"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error retirando zona"})
+	default:
+		h.avisarReasignadas(c.Request.Context(), eventoID, res)
+		c.JSON(http.StatusOK, res)
+	}
+}
+
+// avisarReasignadas publica cada incidencia y tarea que cambió de zona, igual
+// que si se hubiera editado una por una
+func (h *ZonaHandler) avisarReasignadas(ctx context.Context, eventoID string, res *models.RetiroZona) {
+	for i := range res.Incidencias {
+		if err := h.hub.Publicar(ctx, eventoID, models.EventoWS{
+			Tipo: models.WSIncidenciaActualizada, Payload: &res.Incidencias[i], EventoID: eventoID,
+		}); err != nil {
+			log.Printf("Error publicando incidencia reasignada %s: %v", res.Incidencias[i].ID, err)
+		}
+	}
+	for i := range res.Tareas {
+		if err := h.hub.Publicar(ctx, eventoID, models.EventoWS{
+			Tipo: models.WSTareaActualizada, Payload: &res.Tareas[i], EventoID: eventoID,
+		}); err != nil {
+			log.Printf("Error publicando tarea reasignada %s: %v", res.Tareas[i].ID, err)
+		}
+	}
+}
+
+// validarZona revisa tipo, padre y geometría; devuelve el mensaje de error o ""
+func (h *ZonaHandler) validarZona(ctx context.Context, eventoID string, req *models.CrearZonaRequest) string {
+	if msg := validarCamposZona(req); msg != "" {
+		return msg
+	}
+	if req.PadreID != nil && *req.PadreID != "" {
+		padre, err := h.zonaRepo.ObtenerPorID(ctx, *req.PadreID, eventoID)
+		if err != nil {
+			return "Error leyendo la zona padre"
+		}
+		return validarPadre(req, padre)
+	}
+	return ""
+}
+
+// validarCamposZona revisa lo que no necesita la base de datos
+func validarCamposZona(req *models.CrearZonaRequest) string {
+	if req.ID == "" || len(req.ID) > 50 {
+		return "id requerido, hasta 50 caracteres"
+	}
+	if strings.TrimSpace(req.Nombre) == "" || len([]rune(req.Nombre)) > 100 {
+		return "nombre requerido, hasta 100 caracteres"
+	}
+	if req.Tipo == "" {
+		req.Tipo = models.ZonaArea
+	}
+	if req.Tipo.Nivel() == 0 {
+		return "Tipo inválido. Válidos: sector, area, punto"
+	}
+	if req.PadreID != nil && *req.PadreID == req.ID {
+		return "Una zona no puede ser su propio padre"
+	}
+	if (req.Latitud == nil) != (req.Longitud == nil) {
+		return "latitud y longitud van juntas"
+	}
+	if req.Latitud != nil && (*req.Latitud < -90 || *req.Latitud > 90 || *req.Longitud < -180 || *req.Longitud > 180) {
+		return "Coordenadas fuera de rango"
+	}
+	if req.RadioM != nil && *req.RadioM <= 0 {
+		return "radio_m debe ser mayor a 0"
+	}
+	if req.Poligono != nil {
+		if err := geo.ValidarPoligono(req.Poligono); err != nil {
+			return err.Error()
+		}
+	}
+	if req.Capacidad != nil && *req.Capacidad <= 0 {
+		return "capacidad debe ser mayor a 0"
+	}
+	if req.UmbralOcupacion != nil && (*req.UmbralOcupacion < 1 || *req.UmbralOcupacion > 100) {
+		return "umbral_ocupacion es un porcentaje entre 1 y 100"
+	}
+	if req.UmbralOcupacion != nil && req.Capacidad == nil {
+		return "umbral_ocupacion requiere capacidad"
+	}
+	return ""
+}
+
+// validarPadre: el padre existe, no está archivado y es de un nivel superior
+func validarPadre(req *models.CrearZonaRequest, padre *models.Zona) string {
+	if padre == nil {
+		return "La zona padre no existe en el evento"
+	}
+	if padre.ArchivadaEn != nil {
+		return "La zona padre está archivada"
+	}
+	if padre.Tipo.Nivel() >= req.Tipo.Nivel() {
+		return "Una zona " + string(req.Tipo) + " no puede estar dentro de una " + string(padre.Tipo)
+	}
+	return ""
+}
+
+func (h *ZonaHandler) eventoDe(c *gin.Context) string {
+	return eventoPedido(c, h.eventoRepo)
+}
+
+// eventoPedido: el admin puede elegir evento con ?evento_id; el resto usa el
+// suyo o, en su defecto, el activo
+func eventoPedido(c *gin.Context, eventoRepo *repository.EventoRepo) string {
+	if qID := c.Query("evento_id"); qID != "" && middleware.GetRol(c) == models.RolAdmin {
+		return qID
+	}
+	if id := middleware.GetEventoID(c); id != "" {
+		return id
+	}
+	if ev, _ := eventoRepo.ObtenerActivo(c.Request.Context()); ev != nil {
+		return ev.ID
+	}
+	return ""
+}
+
+func (h *ZonaHandler) listarDelEvento(c *gin.Context) (string, []models.Zona, bool) {
+	eventoID := h.eventoDe(c)
+	if eventoID == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "No hay evento activo"})
+		return "", nil, false
+	}
+	lista, err := h.zonaRepo.Listar(c.Request.Context(), eventoID, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error listando zonas"})
+		return "", nil, false
+	}
+	return eventoID, lista, true
+}
+
+// geometriaZona: el polígono manda sobre el punto; nil si no tiene ninguno
+func geometriaZona(z *models.Zona) *models.GeometriaGeoJSON {
+	if len(z.Poligono) > 0 {
+		return &models.GeometriaGeoJSON{Type: "Polygon", Coordinates: z.Poligono}
+	}
+	if z.Latitud != nil && z.Longitud != nil {
+		return &models.GeometriaGeoJSON{Type: "Point", Coordinates: []float64{*z.Longitud, *z.Latitud}}
+	}
+	return nil
+}
@@ -0,0 +1,436 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// ─── Zona: importación y clonado ──────────────────────────────────────────────
+
+const maxImportacionZonas = 5 << 20
+
+// columnasCSVZona son las columnas aceptadas; id y nombre son obligatorias
+var columnasCSVZona = map[string]bool{
+	"id": true, "nombre": true, "tipo": true, "padre_id": true, "latitud": true,
+	"longitud": true, "radio_m": true, "piso": true, "poligono": true,
+	"capacidad": true, "umbral_ocupacion": true,
+}
+
+// filaZona es una zona leída del archivo, con su número de fila
+type filaZona struct {
+	fila  int
+	req   models.CrearZonaRequest
+	error string
+}
+
+// POST /api/v1/zonas/importar?formato=csv|geojson&validar=true  [solo admin]
+// Acepta el archivo como multipart (campo "archivo") o como cuerpo crudo. Se
+// valida todo antes de escribir: con un solo error no se crea nada y la
+// respuesta lista los problemas por fila.
+func (h *ZonaHandler) Importar(c *gin.Context) {
+	eventoID := h.eventoDe(c)
+	if eventoID == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "No hay evento activo"})
+		return
+	}
+	datos, nombre, err := leerArchivoImportacion(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	formato := formatoImportacion(c.Query("formato"), nombre, c.ContentType(), datos)
+
+	var filas []filaZona
+	switch formato {
+	case "csv":
+		filas, err = parsearCSVZonas(datos)
+	case "geojson":
+		filas, err = parsearGeoJSONZonas(datos)
+	default:
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "formato debe ser csv o geojson"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if len(filas) == 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "El archivo no trae zonas"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	existentes, err := h.zonaRepo.Listar(ctx, eventoID, true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error listando zonas"})
+		return
+	}
+	reporte := models.ImportacionZonas{
+		Formato:        formato,
+		Filas:          len(filas),
+		SoloValidacion: c.Query("validar") == "true",
+		Errores:        validarLoteZonas(filas, existentes),
+	}
+	if len(reporte.Errores) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, reporte)
+		return
+	}
+	if reporte.SoloValidacion {
+		c.JSON(http.StatusOK, reporte)
+		return
+	}
+
+	// Padres antes que hijas: el nivel estrictamente creciente lo garantiza
+	lote := make([]models.CrearZonaRequest, len(filas))
+	for i, f := range filas {
+		lote[i] = f.req
+	}
+	sort.SliceStable(lote, func(i, j int) bool { return lote[i].Tipo.Nivel() < lote[j].Tipo.Nivel() })
+	if err := h.zonaRepo.CrearVarias(ctx, lote, eventoID); err != nil {
+		if strings.Contains(err.Error(), "duplicate") || strings.Contains(err.Error(), "unique") {
+			c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Otra zona con alguno de esos IDs se creó mientras tanto; reintenta"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error importando zonas"})
+		return
+	}
+	reporte.Creadas = len(lote)
+	c.JSON(http.StatusCreated, reporte)
+}
+
+// POST /api/v1/eventos/:id/zonas/clonar  [solo admin]
+// Copia las zonas de un evento anterior, con jerarquía y geometría
+func (h *ZonaHandler) Clonar(c *gin.Context) {
+	var req models.ClonarZonasRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	eventoID := c.Param("id")
+	if req.DesdeEventoID == eventoID {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "El evento de origen y el de destino son el mismo"})
+		return
+	}
+	ctx := c.Request.Context()
+	for _, id := range []string{eventoID, req.DesdeEventoID} {
+		ev, err := h.eventoRepo.ObtenerPorID(ctx, id)
+		if err != nil || ev == nil {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Evento no encontrado: " + id})
+			return
+		}
+		if id == eventoID && ev.Estado == models.EventoTerminado {
+			c.JSON(http.StatusConflict, models.ErrorResponse{Error: "El evento de destino ya terminó"})
+			return
+		}
+	}
+	repetidas, err := h.zonaRepo.IDsEnComun(ctx, req.DesdeEventoID, eventoID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error comparando zonas"})
+		return
+	}
+	if len(repetidas) > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "El evento de destino ya tiene zonas con esos IDs", "ids": repetidas})
+		return
+	}
+	creadas, err := h.zonaRepo.Clonar(ctx, req.DesdeEventoID, eventoID)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate") || strings.Contains(err.Error(), "unique") {
+			c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Se crearon zonas en el destino mientras tanto; reintenta"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Error clonando zonas"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"evento_id": eventoID, "desde_evento_id": req.DesdeEventoID, "creadas": creadas})
+}
+
+// validarLoteZonas revisa cada fila y la jerarquía del lote completo. El
+// padre puede venir en el mismo archivo o existir ya en el evento.
+func validarLoteZonas(filas []filaZona, existentes []models.Zona) []models.ErrorImportacion {
+	errores := []models.ErrorImportacion{}
+	enEvento := make(map[string]*models.Zona, len(existentes))
+	for i := range existentes {
+		enEvento[existentes[i].ID] = &existentes[i]
+	}
+	enArchivo := make(map[string]*models.CrearZonaRequest, len(filas))
+	conError := map[string]bool{}
+	for i := range filas {
+		f := &filas[i]
+		if f.error == "" {
+			f.error = validarCamposZona(&f.req)
+		}
+		if f.error == "" && enEvento[f.req.ID] != nil {
+			f.error = "Ya existe una zona con ese ID en este evento"
+		}
+		if f.error == "" && enArchivo[f.req.ID] != nil {
+			f.error = "ID repetido en el archivo"
+		}
+		if f.error == "" {
+			enArchivo[f.req.ID] = &f.req
+		} else if f.req.ID != "" {
+			conError[f.req.ID] = true
+		}
+	}
+	for _, f := range filas {
+		if f.error == "" && f.req.PadreID != nil && *f.req.PadreID != "" {
+			if conError[*f.req.PadreID] {
+				f.error = "La zona padre tiene errores en el archivo"
+			} else if padre, ok := enArchivo[*f.req.PadreID]; ok {
+				if padre.Tipo.Nivel() >= f.req.Tipo.Nivel() {
+					f.error = "Una zona " + string(f.req.Tipo) + " no puede estar dentro de una " + string(padre.Tipo)
+				}
+			} else {
+				f.error = validarPadre(&f.req, enEvento[*f.req.PadreID])
+			}
+		}
+		if f.error != "" {
+			errores = append(errores, models.ErrorImportacion{Fila: f.fila, ID: f.req.ID, Error: f.error})
+		}
+	}
+	return errores
+}
+
+// leerArchivoImportacion devuelve el contenido y el nombre del archivo (vacío
+// si vino como cuerpo crudo)
+func leerArchivoImportacion(c *gin.Context) ([]byte, string, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportacionZonas)
+	if !strings.HasPrefix(c.ContentType(), "multipart/") {
+		datos, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			return nil, "", errors.New("El archivo supera los 5 MB")
+		}
+		return datos, "", nil
+	}
+	archivo, err := c.FormFile("archivo")
+	if err != nil {
+		return nil, "", errors.New("Se requiere el archivo 'archivo' (hasta 5 MB)")
+	}
+	f, err := archivo.Open()
+	if err != nil {
+		return nil, "", errors.New("No se pudo leer el archivo")
+	}
+	defer f.Close()
+	datos, err := io.ReadAll(f)
+	if err != nil {
+		return nil, "", errors.New("No se pudo leer el archivo")
+	}
+	return datos, archivo.Filename, nil
+}
+
+// formatoImportacion: ?formato manda; si no, extensión, Content-Type o contenido
+func formatoImportacion(formato, nombre, contentType string, datos []byte) string {
+	if formato != "" {
+		return strings.ToLower(formato)
+	}
+	switch strings.ToLower(filepath.Ext(nombre)) {
+	case ".csv":
+		return "csv"
+	case ".geojson", ".json":
+		return "geojson"
+	}
+	switch contentType {
+	case "text/csv":
+		return "csv"
+	case "application/geo+json", "application/json":
+		return "geojson"
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(datos), []byte("{")) {
+		return "geojson"
+	}
+	return "csv"
+}
+
+// parsearCSVZonas lee un CSV con cabecera. Acepta "," o ";" (Excel en
+// español exporta con punto y coma). poligono va como JSON en su celda.
+func parsearCSVZonas(datos []byte) ([]filaZona, error) {
+	datos = bytes.TrimPrefix(datos, []byte("\xef\xbb\xbf")) // BOM de Excel
+	r := csv.NewReader(bytes.NewReader(datos))
+	primera, _, _ := bytes.Cut(datos, []byte("\n"))
+	if bytes.Count(primera, []byte(";")) > bytes.Count(primera, []byte(",")) {
+		r.Comma = ';'
+	}
+	cabecera, err := r.Read()
+	if err != nil {
+		return nil, errors.New("CSV sin cabecera")
+	}
+	col := map[string]int{}
+	for i, nombre := range cabecera {
+		nombre = strings.ToLower(strings.TrimSpace(nombre))
+		if !columnasCSVZona[nombre] {
+			return nil, fmt.Errorf("columna desconocida %q. Válidas: id, nombre, tipo, padre_id, latitud, longitud, radio_m, piso, poligono, capacidad, umbral_ocupacion", nombre)
+		}
+		col[nombre] = i
+	}
+	if _, ok := col["id"]; !ok {
+		return nil, errors.New("falta la columna id")
+	}
+	if _, ok := col["nombre"]; !ok {
+		return nil, errors.New("falta la columna nombre")
+	}
+
+	filas := []filaZona{}
+	for {
+		registro, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var perr *csv.ParseError
+			if !errors.As(err, &perr) {
+				return nil, err
+			}
+			// StartLine: la fila donde empieza el registro, no donde se notó el error
+			filas = append(filas, filaZona{fila: perr.StartLine, error: perr.Err.Error()})
+			if !errors.Is(perr.Err, csv.ErrFieldCount) {
+				return filas, nil // comillas rotas: lo que sigue no es confiable
+			}
+			continue
+		}
+		celda := func(nombre string) string {
+			if i, ok := col[nombre]; ok {
+				return strings.TrimSpace(registro[i])
+			}
+			return ""
+		}
+		linea, _ := r.FieldPos(0)
+		f := filaZona{fila: linea}
+		f.req.ID = celda("id")
+		f.req.Nombre = celda("nombre")
+		f.req.Tipo = models.TipoZona(strings.ToLower(celda("tipo")))
+		if v := celda("padre_id"); v != "" {
+			f.req.PadreID = &v
+		}
+		var errCelda error
+		numero := func(nombre string) *float64 {
+			v := celda(nombre)
+			if v == "" || errCelda != nil {
+				return nil
+			}
+			n, err := strconv.ParseFloat(strings.Replace(v, ",", ".", 1), 64)
+			if err != nil {
+				errCelda = fmt.Errorf("%s no es un número", nombre)
+				return nil
+			}
+			return &n
+		}
+		f.req.Latitud, f.req.Longitud, f.req.RadioM = numero("latitud"), numero("longitud"), numero("radio_m")
+		entero := func(nombre string) *int {
+			v := celda(nombre)
+			if v == "" || errCelda != nil {
+				return nil
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				errCelda = fmt.Errorf("%s debe ser un entero", nombre)
+				return nil
+			}
+			return &n
+		}
+		f.req.Piso, f.req.Capacidad, f.req.UmbralOcupacion = entero("piso"), entero("capacidad"), entero("umbral_ocupacion")
+		if v := celda("poligono"); v != "" && errCelda == nil {
+			if err := json.Unmarshal([]byte(v), &f.req.Poligono); err != nil {
+				errCelda = errors.New("poligono debe ser JSON [[[lng, lat], ...]]")
+			}
+		}
+		if errCelda != nil {
+			f.error = errCelda.Error()
+		}
+		filas = append(filas, f)
+	}
+	return filas, nil
+}
+
+type featureImportada struct {
+	ID         interface{}     `json:"id"`
+	Geometry   json.RawMessage `json:"geometry"`
+	Properties json.RawMessage `json:"properties"`
+}
+
+type propiedadesImportadas struct {
+	ID      string          `json:"id"`
+	Nombre  string          `json:"nombre"`
+	Tipo    models.TipoZona `json:"tipo"`
+	PadreID *string         `json:"padre_id"`
+	RadioM  *float64        `json:"radio_m"`
+	Piso    *int            `json:"piso"`
+	// Aforo
+	Capacidad       *int `json:"capacidad"`
+	UmbralOcupacion *int `json:"umbral_ocupacion"`
+}
+
+// parsearGeoJSONZonas lee una FeatureCollection: Point da latitud/longitud,
+// Polygon da poligono y null deja la zona sin geometría. El resto de los
+// campos van en properties; id también puede venir en el id del feature.
+func parsearGeoJSONZonas(datos []byte) ([]filaZona, error) {
+	var col struct {
+		Type     string             `json:"type"`
+		Features []featureImportada `json:"features"`
+	}
+	if err := json.Unmarshal(datos, &col); err != nil || col.Type != "FeatureCollection" {
+		return nil, errors.New("Se espera una FeatureCollection GeoJSON")
+	}
+	filas := make([]filaZona, 0, len(col.Features))
+	for i, feat := range col.Features {
+		f := filaZona{fila: i + 1}
+		var props propiedadesImportadas
+		if len(feat.Properties) > 0 && string(feat.Properties) != "null" {
+			if err := json.Unmarshal(feat.Properties, &props); err != nil {
+				f.error = "properties inválidas: " + err.Error()
+				filas = append(filas, f)
+				continue
+			}
+		}
+		f.req = models.CrearZonaRequest{
+			ID: props.ID, Nombre: props.Nombre, Tipo: props.Tipo, PadreID: props.PadreID,
+			RadioM: props.RadioM, Piso: props.Piso, Capacidad: props.Capacidad, UmbralOcupacion: props.UmbralOcupacion,
+		}
+		if f.req.ID == "" && feat.ID != nil {
+			f.req.ID = fmt.Sprint(feat.ID)
+		}
+		if msg := geometriaImportada(feat.Geometry, &f.req); msg != "" {
+			f.error = msg
+		}
+		filas = append(filas, f)
+	}
+	return filas, nil
+}
+
+func geometriaImportada(crudo json.RawMessage, req *models.CrearZonaRequest) string {
+	if len(crudo) == 0 || string(crudo) == "null" {
+		return ""
+	}
+	var g struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	}
+	if err := json.Unmarshal(crudo, &g); err != nil {
+		return "geometry inválida"
+	}
+	switch g.Type {
+	case "Point":
+		var pos []float64
+		if err := json.Unmarshal(g.Coordinates, &pos); err != nil || len(pos) < 2 {
+			return "Point debe ser [lng, lat]"
+		}
+		req.Longitud, req.Latitud = &pos[0], &pos[1]
+	case "Polygon":
+		if err := json.Unmarshal(g.Coordinates, &req.Poligono); err != nil {
+			return "Polygon debe ser [[[lng, lat], ...]]"
+		}
+	default:
+		return "geometry " + g.Type + " no soportada: solo Point o Polygon"
+	}
+	return ""
+}
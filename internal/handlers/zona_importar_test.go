@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eventpulse/backend/internal/models"
+)
+
+func TestParsearCSVZonas(t *testing.T) {
+	casos := []struct {
+		nombre  string
+		csv     string
+		err     string // fragmento del error del archivo, "" si se lee
+		filas   []int  // número de fila de cada zona leída
+		errores []string
+		revisar func(t *testing.T, filas []filaZona)
+	}{
+		{
+			nombre: "coma y todas las columnas",
+			csv: "id,nombre,tipo,padre_id,latitud,longitud,radio_m,piso,poligono,capacidad,umbral_ocupacion\n" +
+				"norte,Sector norte,Sector,,,,,,\"[[[0,0],[1,0],[1,1],[0,0]]]\",,\n" +
+				"vip,VIP,punto,norte,-33.45,-70.66,15,2,,200,80\n",
+			filas:   []int{2, 3},
+			errores: []string{"", ""},
+			revisar: func(t *testing.T, filas []filaZona) {
+				norte, vip := filas[0].req, filas[1].req
+				if norte.Tipo != models.ZonaSector || len(norte.Poligono) != 1 || norte.PadreID != nil {
+					t.Errorf("norte = %+v", norte)
+				}
+				if *vip.PadreID != "norte" || *vip.Latitud != -33.45 || *vip.Longitud != -70.66 || *vip.RadioM != 15 ||
+					*vip.Piso != 2 || *vip.Capacidad != 200 || *vip.UmbralOcupacion != 80 {
+					t.Errorf("vip = %+v", vip)
+				}
+			},
+		},
+		{
+			nombre:  "punto y coma, coma decimal y BOM",
+			csv:     "\xef\xbb\xbfID;Nombre;Latitud;Longitud\nacceso; Acceso ;-33,45;-70,66\n",
+			filas:   []int{2},
+			errores: []string{""},
+			revisar: func(t *testing.T, filas []filaZona) {
+				z := filas[0].req
+				if z.ID != "acceso" || z.Nombre != "Acceso" || *z.Latitud != -33.45 || *z.Longitud != -70.66 {
+					t.Errorf("zona = %+v", z)
+				}
+			},
+		},
+		{
+			nombre:  "errores por celda",
+			csv:     "id,nombre,latitud,piso,poligono\na,A,norte,,\nb,B,,1.5,\nc,C,,,[[\nd,D,,,\n",
+			filas:   []int{2, 3, 4, 5},
+			errores: []string{"latitud no es un número", "piso debe ser un entero", "poligono debe ser JSON", ""},
+		},
+		{
+			nombre:  "fila con columnas de más sigue leyendo",
+			csv:     "id,nombre\na,A\nb,B,extra\nc,C\n",
+			filas:   []int{2, 3, 4},
+			errores: []string{"", "wrong number of fields", ""},
+		},
+		{
+			nombre:  "comillas rotas cortan la lectura",
+			csv:     "id,nombre\na,A\n\"b,B\nc,C\n",
+			filas:   []int{2, 3},
+			errores: []string{"", "quote"},
+		},
+		{nombre: "solo cabecera", csv: "id,nombre\n", filas: []int{}},
+		{nombre: "vacío", csv: "", err: "sin cabecera"},
+		{nombre: "columna desconocida", csv: "id,nombre,color\n", err: `columna desconocida "color"`},
+		{nombre: "sin id", csv: "nombre,tipo\nA,area\n", err: "falta la columna id"},
+		{nombre: "sin nombre", csv: "id,tipo\na,area\n", err: "falta la columna nombre"},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			filas, err := parsearCSVZonas([]byte(c.csv))
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Fatalf("error = %v, esperado %q", err, c.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			verificarFilas(t, filas, c.filas, c.errores)
+			if c.revisar != nil && !t.Failed() {
+				c.revisar(t, filas)
+			}
+		})
+	}
+}
+
+func TestParsearGeoJSONZonas(t *testing.T) {
+	casos := []struct {
+		nombre  string
+		geojson string
+		err     bool
+		filas   []int
+		errores []string
+		revisar func(t *testing.T, filas []filaZona)
+	}{
+		{
+			nombre: "Point, Polygon y sin geometría",
+			geojson: `{"type":"FeatureCollection","features":[
+				{"type":"Feature","geometry":{"type":"Point","coordinates":[-70.66,-33.45]},
+				 "properties":{"id":"vip","nombre":"VIP","tipo":"punto","padre_id":"norte","radio_m":15,"piso":2,"capacidad":200,"umbral_ocupacion":80}},
+				{"type":"Feature","id":"norte","geometry":{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,0]]]},
+				 "properties":{"nombre":"Norte","tipo":"sector"}},
+				{"type":"Feature","id":7,"geometry":null,"properties":{"nombre":"Bodega"}}
+			]}`,
+			filas:   []int{1, 2, 3},
+			errores: []string{"", "", ""},
+			revisar: func(t *testing.T, filas []filaZona) {
+				vip, norte, bodega := filas[0].req, filas[1].req, filas[2].req
+				if *vip.Latitud != -33.45 || *vip.Longitud != -70.66 || *vip.PadreID != "norte" || *vip.RadioM != 15 ||
+					*vip.Piso != 2 || *vip.Capacidad != 200 || *vip.UmbralOcupacion != 80 {
+					t.Errorf("vip = %+v", vip)
+				}
+				if norte.ID != "norte" || norte.Tipo != models.ZonaSector || len(norte.Poligono[0]) != 4 {
+					t.Errorf("norte = %+v", norte)
+				}
+				if bodega.ID != "7" || bodega.Latitud != nil || bodega.Poligono != nil {
+					t.Errorf("bodega = %+v", bodega)
+				}
+			},
+		},
+		{
+			nombre: "properties.id manda sobre el id del feature",
+			geojson: `{"type":"FeatureCollection","features":[
+				{"type":"Feature","id":"otro","properties":{"id":"acceso","nombre":"Acceso"}}]}`,
+			filas:   []int{1},
+			errores: []string{""},
+			revisar: func(t *testing.T, filas []filaZona) {
+				if filas[0].req.ID != "acceso" {
+					t.Errorf("id = %q", filas[0].req.ID)
+				}
+			},
+		},
+		{
+			nombre: "errores por feature",
+			geojson: `{"type":"FeatureCollection","features":[
+				{"type":"Feature","geometry":{"type":"LineString","coordinates":[[0,0],[1,1]]},"properties":{"id":"a","nombre":"A"}},
+				{"type":"Feature","geometry":{"type":"Point","coordinates":[1]},"properties":{"id":"b","nombre":"B"}},
+				{"type":"Feature","geometry":{"type":"Polygon","coordinates":[0,0]},"properties":{"id":"c","nombre":"C"}},
+				{"type":"Feature","properties":{"id":"d","nombre":"D","piso":"alto"}},
+				{"type":"Feature","properties":{"id":"e","nombre":"E"}}
+			]}`,
+			filas:   []int{1, 2, 3, 4, 5},
+			errores: []string{"LineString no soportada", "Point debe ser [lng, lat]", "Polygon debe ser", "properties inválidas", ""},
+		},
+		{nombre: "sin features", geojson: `{"type":"FeatureCollection","features":[]}`, filas: []int{}},
+		{nombre: "no es FeatureCollection", geojson: `{"type":"Feature","properties":{}}`, err: true},
+		{nombre: "JSON roto", geojson: `{"type":`, err: true},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			filas, err := parsearGeoJSONZonas([]byte(c.geojson))
+			if c.err {
+				if err == nil {
+					t.Fatal("se esperaba error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			verificarFilas(t, filas, c.filas, c.errores)
+			if c.revisar != nil && !t.Failed() {
+				c.revisar(t, filas)
+			}
+		})
+	}
+}
+
+func verificarFilas(t *testing.T, filas []filaZona, numeros []int, errores []string) {
+	t.Helper()
+	got := make([]int, len(filas))
+	for i, f := range filas {
+		got[i] = f.fila
+	}
+	if !reflect.DeepEqual(got, numeros) {
+		t.Fatalf("filas %v, esperado %v", got, numeros)
+	}
+	for i, f := range filas {
+		if errores[i] == "" && f.error != "" || !strings.Contains(f.error, errores[i]) {
+			t.Errorf("fila %d: error %q, esperado %q", f.fila, f.error, errores[i])
+		}
+	}
+}
+
+func TestValidarLoteZonas(t *testing.T) {
+	archivada := time.Now()
+	existentes := []models.Zona{
+		{ID: "norte", Tipo: models.ZonaSector},
+		{ID: "plaza", Tipo: models.ZonaArea},
+		{ID: "viejo", Tipo: models.ZonaSector, ArchivadaEn: &archivada},
+	}
+	fila := func(n int, id string, tipo models.TipoZona, padre string) filaZona {
+		f := filaZona{fila: n, req: models.CrearZonaRequest{ID: id, Nombre: "Zona " + id, Tipo: tipo}}
+		if padre != "" {
+			f.req.PadreID = &padre
+		}
+		return f
+	}
+	conError := func(f filaZona, msg string) filaZona { f.error = msg; return f }
+	sinNombre := fila(9, "x", "", "")
+	sinNombre.req.Nombre = " "
+
+	casos := []struct {
+		nombre   string
+		filas    []filaZona
+		esperado map[int]string // fila → fragmento del error
+	}{
+		{
+			nombre: "lote válido con padres en archivo y en evento",
+			filas: []filaZona{
+				fila(2, "vip", models.ZonaPunto, "sur"),
+				fila(3, "sur", models.ZonaSector, ""),
+				fila(4, "barra", models.ZonaPunto, "plaza"),
+				fila(5, "patio", "", "norte"), // tipo por defecto: area
+			},
+			esperado: map[int]string{},
+		},
+		{
+			nombre: "IDs repetidos y ya existentes",
+			filas: []filaZona{
+				fila(2, "a", models.ZonaArea, ""),
+				fila(3, "a", models.ZonaArea, ""),
+				fila(4, "norte", models.ZonaSector, ""),
+			},
+			esperado: map[int]string{3: "ID repetido", 4: "Ya existe"},
+		},
+		{
+			nombre: "jerarquía",
+			filas: []filaZona{
+				fila(2, "s", models.ZonaSector, ""),
+				fila(3, "s2", models.ZonaSector, "s"),
+				fila(4, "p", models.ZonaPunto, ""),
+				fila(5, "a", models.ZonaArea, "p"),
+				fila(6, "b", models.ZonaArea, "plaza"),
+				fila(7, "c", models.ZonaArea, "viejo"),
+				fila(8, "d", models.ZonaArea, "no-existe"),
+				fila(9, "e", models.ZonaArea, "e"),
+			},
+			esperado: map[int]string{
+				3: "sector no puede estar dentro de una sector",
+				5: "area no puede estar dentro de una punto",
+				6: "area no puede estar dentro de una area",
+				7: "archivada",
+				8: "no existe",
+				9: "su propio padre",
+			},
+		},
+		{
+			nombre: "padre con errores en el archivo",
+			filas: []filaZona{
+				conError(fila(2, "s", models.ZonaSector, ""), "latitud no es un número"),
+				fila(3, "a", models.ZonaArea, "s"),
+			},
+			esperado: map[int]string{2: "latitud", 3: "padre tiene errores"},
+		},
+		{
+			nombre:   "campos inválidos",
+			filas:    []filaZona{fila(2, "", models.ZonaArea, ""), fila(3, "t", "zona", ""), sinNombre},
+			esperado: map[int]string{2: "id requerido", 3: "Tipo inválido", 9: "nombre requerido"},
+		},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			errores := validarLoteZonas(c.filas, existentes)
+			got := map[int]string{}
+			for _, e := range errores {
+				got[e.Fila] = e.Error
+			}
+			if len(got) != len(c.esperado) {
+				t.Fatalf("errores %v, esperado %v", got, c.esperado)
+			}
+			for n, frag := range c.esperado {
+				if !strings.Contains(got[n], frag) {
+					t.Errorf("fila %d: error %q, esperado %q", n, got[n], frag)
+				}
+			}
+		})
+	}
+}
+
+func TestFormatoImportacion(t *testing.T) {
+	casos := []struct {
+		nombre, formato, archivo, contentType, datos string
+		esperado                                     string
+	}{
+		{"el parámetro manda", "GeoJSON", "zonas.csv", "text/csv", "id,nombre", "geojson"},
+		{"extensión csv", "", "zonas.CSV", "application/octet-stream", "{", "csv"},
+		{"extensión geojson", "", "zonas.geojson", "", "", "geojson"},
+		{"extensión json", "", "zonas.json", "", "", "geojson"},
+		{"content-type csv", "", "", "text/csv", "{", "csv"},
+		{"content-type geo+json", "", "", "application/geo+json", "", "geojson"},
+		{"contenido JSON", "", "", "text/plain", "  {\"type\":", "geojson"},
+		{"por defecto csv", "", "", "", "id,nombre", "csv"},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			if got := formatoImportacion(c.formato, c.archivo, c.contentType, []byte(c.datos)); got != c.esperado {
+				t.Errorf("formatoImportacion = %q, esperado %q", got, c.esperado)
+			}
+		})
+	}
+}
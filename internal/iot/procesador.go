@@ -0,0 +1,154 @@
+package iot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/eventpulse/backend/internal/repository"
+	"github.com/eventpulse/backend/internal/ws"
+)
+
+var (
+	ErrLecturaInvalida = errors.New("la lectura debe ser un objeto JSON")
+	ErrEventoInactivo  = errors.New("el evento del dispositivo no está activo")
+	ErrEstadoInvalido  = errors.New("estado inválido")
+)
+
+// Acciones posibles ante una lectura
+const (
+	AccionCreada      = "creada"
+	AccionDeduplicada = "deduplicada"
+	AccionIgnorada    = "ignorada"
+	AccionActualizada = "actualizada"
+)
+
+type Resultado struct {
+	Accion       string             `json:"accion"`
+	Incidencia   *models.Incidencia `json:"incidencia,omitempty"`
+	IncidenciaID *string            `json:"incidencia_id,omitempty"`
+	Suprimidas   int                `json:"suprimidas,omitempty"`
+}
+
+// Procesador es el camino común de las lecturas, lleguen por HTTP o por MQTT:
+// evalúa reglas, deduplica ráfagas y crea la incidencia igual que
+// POST /incidencias (IncidenciaRepo.Crear + incidencia_nueva).
+type Procesador struct {
+	repo           *repository.DispositivoRepo
+	incidenciaRepo *repository.IncidenciaRepo
+	eventoRepo     *repository.EventoRepo
+	hub            *ws.Hub
+}
+
+func NewProcesador(r *repository.DispositivoRepo, i *repository.IncidenciaRepo, e *repository.EventoRepo, h *ws.Hub) *Procesador {
+	return &Procesador{repo: r, incidenciaRepo: i, eventoRepo: e, hub: h}
+}
+
+// Procesar recibe el cuerpo crudo de la lectura de un dispositivo ya autenticado
+func (p *Procesador) Procesar(ctx context.Context, d *models.Dispositivo, crudo []byte) (*Resultado, error) {
+	payload, err := decodificar(crudo)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.repo.RegistrarDato(ctx, d.ID, crudo); err != nil {
+		log.Println("❌ Error registrando lectura IoT:", err)
+	}
+	if err := p.eventoActivo(ctx, d); err != nil {
+		return nil, err
+	}
+
+	m := Evaluar(d, payload)
+	if m == nil {
+		return &Resultado{Accion: AccionIgnorada}, nil
+	}
+	// Zona del payload desconocida: se cae a la del dispositivo antes que perder la alerta
+	if m.ZonaID != d.ZonaID {
+		if ok, err := p.repo.ZonaExiste(ctx, d.EventoID, m.ZonaID); err == nil && !ok {
+			log.Printf("⚠️ Dispositivo %s reportó zona desconocida %q; se usa %q", d.ID, m.ZonaID, d.ZonaID)
+			m.ZonaID = d.ZonaID
+		}
+	}
+
+	reclamada, previa, suprimidas, err := p.repo.ReclamarAlerta(ctx, d.ID, m.Tipo, m.ZonaID, d.VentanaSegundos)
+	if err != nil {
+		return nil, err
+	}
+	if !reclamada {
+		return &Resultado{Accion: AccionDeduplicada, IncidenciaID: previa, Suprimidas: suprimidas}, nil
+	}
+
+	inc, err := p.incidenciaRepo.Crear(ctx, &models.CrearIncidenciaRequest{
+		ZonaID:      m.ZonaID,
+		Tipo:        m.Tipo,
+		Descripcion: m.Descripcion,
+	}, d.EventoID, d.CreadoPor)
+	if err != nil {
+		if err := p.repo.LiberarAlerta(ctx, d.ID, m.Tipo, m.ZonaID); err != nil {
+			log.Println("❌ Error liberando alerta IoT:", err)
+		}
+		return nil, err
+	}
+	if err := p.repo.VincularAlerta(ctx, d.ID, m.Tipo, m.ZonaID, inc.ID); err != nil {
+		log.Println("❌ Error vinculando alerta IoT:", err)
+	}
+	p.publicar(ctx, models.WSIncidenciaNueva, inc)
+	log.Printf("📡 Incidencia %s creada por dispositivo %s", inc.ID, d.Nombre)
+	return &Resultado{Accion: AccionCreada, Incidencia: inc}, nil
+}
+
+// Actualizar cambia el estado de una incidencia del evento del dispositivo
+// (ej: un gateway de radio que marca "en_atencion" o "resuelta"). Queda en el
+// historial a nombre del admin que registró el dispositivo.
+func (p *Procesador) Actualizar(ctx context.Context, d *models.Dispositivo, incidenciaID string, estado models.EstadoIncidencia) (*Resultado, error) {
+	switch estado {
+	case models.IncidenciaPendiente, models.IncidenciaEnAtencion, models.IncidenciaResuelta:
+	default:
+		return nil, ErrEstadoInvalido
+	}
+	if err := p.eventoActivo(ctx, d); err != nil {
+		return nil, err
+	}
+	actual, err := p.incidenciaRepo.ObtenerPorID(ctx, incidenciaID)
+	if err != nil || actual == nil || actual.EventoID != d.EventoID {
+		return nil, repository.ErrNoEncontrado
+	}
+	inc, err := p.incidenciaRepo.Editar(ctx, incidenciaID, &models.EditarIncidenciaRequest{Estado: &estado}, d.CreadoPor, nil)
+	if err != nil {
+		return nil, err
+	}
+	p.publicar(ctx, models.WSIncidenciaActualizada, inc)
+	return &Resultado{Accion: AccionActualizada, Incidencia: inc}, nil
+}
+
+func (p *Procesador) eventoActivo(ctx context.Context, d *models.Dispositivo) error {
+	evento, err := p.eventoRepo.ObtenerActivo(ctx)
+	if err != nil || evento == nil || evento.ID != d.EventoID {
+		return ErrEventoInactivo
+	}
+	return nil
+}
+
+func (p *Procesador) publicar(ctx context.Context, tipo models.TipoEventoWS, inc *models.Incidencia) {
+	err := p.hub.Publicar(ctx, inc.EventoID, models.EventoWS{
+		Tipo:     tipo,
+		Payload:  inc,
+		EventoID: inc.EventoID,
+	})
+	if err != nil {
+		log.Printf("❌ Error publicando %s de dispositivo en Redis: %v", tipo, err)
+	}
+}
+
+// decodificar usa json.Number para no perder precisión en lecturas numéricas
+func decodificar(crudo []byte) (map[string]interface{}, error) {
+	var payload map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(crudo))
+	dec.UseNumber()
+	if err := dec.Decode(&payload); err != nil || payload == nil {
+		return nil, ErrLecturaInvalida
+	}
+	return payload, nil
+}
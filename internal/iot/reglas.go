@@ -0,0 +1,141 @@
+package iot
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/eventpulse/backend/internal/models"
+)
+
+// Coincidencia es lo que produce una lectura que cumple una regla
+type Coincidencia struct {
+	Regla       *models.ReglaDispositivo
+	Tipo        models.TipoIncidencia
+	ZonaID      string
+	Descripcion string
+}
+
+// Evaluar aplica las reglas en orden y devuelve la primera que se cumple, o
+// nil si la lectura no amerita incidencia. La zona sale de la regla, luego
+// del campo_zona del payload y por último de la zona del dispositivo.
+func Evaluar(d *models.Dispositivo, payload map[string]interface{}) *Coincidencia {
+	for i := range d.Reglas {
+		regla := &d.Reglas[i]
+		valor, ok := Campo(payload, regla.Campo)
+		if !Cumple(regla, valor, ok) {
+			continue
+		}
+		zona := d.ZonaID
+		if d.CampoZona != nil {
+			if v, ok := Campo(payload, *d.CampoZona); ok {
+				if s := texto(v); s != "" {
+					zona = s
+				}
+			}
+		}
+		if regla.ZonaID != nil {
+			zona = *regla.ZonaID
+		}
+		return &Coincidencia{
+			Regla:       regla,
+			Tipo:        regla.Tipo,
+			ZonaID:      zona,
+			Descripcion: describir(d, regla, valor),
+		}
+	}
+	return nil
+}
+
+// Campo busca una ruta con puntos ("alarma.estado") en el payload
+func Campo(payload map[string]interface{}, ruta string) (interface{}, bool) {
+	var actual interface{} = payload
+	for _, parte := range strings.Split(ruta, ".") {
+		m, ok := actual.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		actual, ok = m[parte]
+		if !ok {
+			return nil, false
+		}
+	}
+	return actual, true
+}
+
+// Cumple compara el valor leído contra la regla. Los operadores de orden
+// solo aplican a números (se aceptan números en texto, "87.5"); = y !=
+// comparan números como números y el resto como texto.
+func Cumple(r *models.ReglaDispositivo, valor interface{}, presente bool) bool {
+	if r.Operador == models.OpExiste {
+		return presente
+	}
+	if !presente {
+		return false
+	}
+	a, aNum := numero(valor)
+	b, bNum := numero(r.Valor)
+	switch r.Operador {
+	case models.OpIgual, models.OpDistinto:
+		igual := texto(valor) == texto(r.Valor)
+		if aNum && bNum {
+			igual = a == b
+		}
+		return igual == (r.Operador == models.OpIgual)
+	}
+	if !aNum || !bNum {
+		return false
+	}
+	switch r.Operador {
+	case models.OpMayor:
+		return a > b
+	case models.OpMayorIgual:
+		return a >= b
+	case models.OpMenor:
+		return a < b
+	case models.OpMenorIgual:
+		return a <= b
+	}
+	return false
+}
+
+func numero(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case int:
+		return float64(n), true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func texto(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+func describir(d *models.Dispositivo, r *models.ReglaDispositivo, valor interface{}) string {
+	plantilla := r.Descripcion
+	if plantilla == "" {
+		plantilla = "Sensor {dispositivo}: {campo} = {valor}"
+	}
+	return strings.NewReplacer(
+		"{dispositivo}", d.Nombre,
+		"{campo}", r.Campo,
+		"{valor}", texto(valor),
+	).Replace(plantilla)
+}
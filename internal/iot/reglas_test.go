@@ -0,0 +1,135 @@
+package iot
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/eventpulse/backend/internal/models"
+)
+
+func TestCumple(t *testing.T) {
+	regla := func(op models.OperadorRegla, valor interface{}) *models.ReglaDispositivo {
+		return &models.ReglaDispositivo{Campo: "x", Operador: op, Valor: valor}
+	}
+	casos := []struct {
+		nombre   string
+		regla    *models.ReglaDispositivo
+		valor    interface{}
+		presente bool
+		cumple   bool
+	}{
+		{"existe con valor", regla(models.OpExiste, nil), "algo", true, true},
+		{"existe con null", regla(models.OpExiste, nil), nil, true, true},
+		{"existe ausente", regla(models.OpExiste, nil), nil, false, false},
+		{"ausente nunca cumple", regla(models.OpDistinto, "ok"), nil, false, false},
+
+		{"> número", regla(models.OpMayor, 80.0), 87.5, true, true},
+		{"> en el límite", regla(models.OpMayor, 80.0), 80.0, true, false},
+		{">= en el límite", regla(models.OpMayorIgual, 80.0), 80.0, true, true},
+		{"< número", regla(models.OpMenor, 10.0), 9.99, true, true},
+		{"<= en el límite", regla(models.OpMenorIgual, 10.0), 10.0, true, true},
+		{"<= por encima", regla(models.OpMenorIgual, 10.0), 10.01, true, false},
+		{"número en texto", regla(models.OpMayor, 80.0), " 87.5 ", true, true},
+		{"umbral en texto", regla(models.OpMayor, "80"), 87.5, true, true},
+		{"json.Number", regla(models.OpMayorIgual, 3), json.Number("3"), true, true},
+		{"orden con texto no numérico", regla(models.OpMayor, 80.0), "alto", true, false},
+		{"orden con booleano", regla(models.OpMenor, 1.0), false, true, false},
+
+		{"= número como número", regla(models.OpIgual, 1.0), "1.0", true, true},
+		{"= texto", regla(models.OpIgual, "alarma"), "alarma", true, true},
+		{"= texto distinto", regla(models.OpIgual, "alarma"), "Alarma", true, false},
+		{"= booleano", regla(models.OpIgual, true), true, true, true},
+		{"!= texto", regla(models.OpDistinto, "ok"), "falla", true, true},
+		{"!= igual", regla(models.OpDistinto, "ok"), "ok", true, false},
+		{"!= número como número", regla(models.OpDistinto, 2.0), "2", true, false},
+		{"operador desconocido", regla("~", 1.0), 1.0, true, false},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			if got := Cumple(c.regla, c.valor, c.presente); got != c.cumple {
+				t.Errorf("Cumple(%v %v, %#v) = %v, esperado %v", c.regla.Operador, c.regla.Valor, c.valor, got, c.cumple)
+			}
+		})
+	}
+}
+
+func TestCampo(t *testing.T) {
+	payload := map[string]interface{}{
+		"temp":   21.5,
+		"alarma": map[string]interface{}{"estado": "activa", "nivel": nil},
+	}
+	casos := []struct {
+		ruta     string
+		valor    interface{}
+		presente bool
+	}{
+		{"temp", 21.5, true},
+		{"alarma.estado", "activa", true},
+		{"alarma.nivel", nil, true},
+		{"alarma.otro", nil, false},
+		{"temp.grados", nil, false},
+		{"falta", nil, false},
+	}
+	for _, c := range casos {
+		t.Run(c.ruta, func(t *testing.T) {
+			v, ok := Campo(payload, c.ruta)
+			if ok != c.presente || v != c.valor {
+				t.Errorf("Campo(%q) = (%v, %v), esperado (%v, %v)", c.ruta, v, ok, c.valor, c.presente)
+			}
+		})
+	}
+}
+
+func TestEvaluar(t *testing.T) {
+	zonaRegla := "cocina"
+	campoZona := "sala"
+	d := &models.Dispositivo{
+		Nombre:    "Sensor 3",
+		ZonaID:    "bodega",
+		CampoZona: &campoZona,
+		Reglas: models.ReglasDispositivo{
+			{Campo: "humo", Operador: models.OpIgual, Valor: true, Tipo: models.TipoSeguridad, ZonaID: &zonaRegla,
+				Descripcion: "Humo en {dispositivo}"},
+			{Campo: "temp", Operador: models.OpMayor, Valor: 60.0, Tipo: models.TipoSeguridad},
+			{Campo: "agua", Operador: models.OpExiste, Tipo: models.TipoDerrame},
+		},
+	}
+	casos := []struct {
+		nombre      string
+		payload     map[string]interface{}
+		regla       int // índice esperado, -1 si nada
+		zona        string
+		descripcion string
+	}{
+		{"nada se cumple", map[string]interface{}{"temp": 20.0}, -1, "", ""},
+		{"zona del dispositivo", map[string]interface{}{"temp": 75.0}, 1, "bodega", "Sensor Sensor 3: temp = 75"},
+		{"zona del payload", map[string]interface{}{"temp": 75.0, "sala": "andén"}, 1, "andén", "Sensor Sensor 3: temp = 75"},
+		{"campo_zona vacío no pisa", map[string]interface{}{"temp": 75.0, "sala": ""}, 1, "bodega", "Sensor Sensor 3: temp = 75"},
+		{"la regla manda sobre el payload", map[string]interface{}{"humo": true, "sala": "andén"}, 0, "cocina", "Humo en Sensor 3"},
+		{"gana la primera que se cumple", map[string]interface{}{"humo": true, "temp": 75.0}, 0, "cocina", "Humo en Sensor 3"},
+		{"existe", map[string]interface{}{"agua": nil}, 2, "bodega", "Sensor Sensor 3: agua = "},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			got := Evaluar(d, c.payload)
+			if c.regla < 0 {
+				if got != nil {
+					t.Fatalf("Evaluar = regla %q, esperado nil", got.Regla.Campo)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatal("Evaluar = nil, esperado una coincidencia")
+			}
+			if got.Regla != &d.Reglas[c.regla] {
+				t.Errorf("regla %q, esperado %q", got.Regla.Campo, d.Reglas[c.regla].Campo)
+			}
+			if got.Tipo != d.Reglas[c.regla].Tipo || got.ZonaID != c.zona {
+				t.Errorf("tipo %q zona %q, esperado %q %q", got.Tipo, got.ZonaID, d.Reglas[c.regla].Tipo, c.zona)
+			}
+			if got.Descripcion != c.descripcion {
+				t.Errorf("descripción %q, esperado %q", got.Descripcion, c.descripcion)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package metricas
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/eventpulse/backend/internal/carga"
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/eventpulse/backend/internal/ocupacion"
+	"github.com/eventpulse/backend/internal/repository"
+	"github.com/eventpulse/backend/internal/ubicaciones"
+	"github.com/eventpulse/backend/internal/ws"
+	"github.com/redis/go-redis/v9"
+)
+
+// Claves en Redis:
+//   - ep:metricas:turno:<evento>  marca de la instancia que calcula en este intervalo
+//   - ep:metricas:<evento>        hash sección → huella de lo último enviado
+const (
+	prefijoTurno   = "ep:metricas:turno:"
+	prefijoHuellas = "ep:metricas:"
+	// cuántas zonas calientes se muestran
+	maxZonasCalientes = 5
+)
+
+// Tablero calcula las métricas de operación de un evento y, para el que está
+// en curso, envía cada pocos segundos a admins y supervisores las secciones
+// que cambiaron. Con varias instancias calcula una sola por intervalo, y la
+// huella de lo enviado vive en Redis para que cualquiera compare contra lo
+// mismo.
+type Tablero struct {
+	repo          *repository.MetricaRepo
+	eventoRepo    *repository.EventoRepo
+	ubicacionRepo *repository.UbicacionRepo
+	rastreador    *ubicaciones.Rastreador
+	contador      *ocupacion.Contador
+	medidor       *carga.Medidor
+	redis         *redis.Client
+	hub           *ws.Hub
+	intervalo     time.Duration
+}
+
+func NewTablero(r *repository.MetricaRepo, e *repository.EventoRepo, u *repository.UbicacionRepo, rs *ubicaciones.Rastreador,
+	c *ocupacion.Contador, md *carga.Medidor, rdb *redis.Client, h *ws.Hub, intervalo time.Duration) *Tablero {
+	return &Tablero{repo: r, eventoRepo: e, ubicacionRepo: u, rastreador: rs, contador: c, medidor: md, redis: rdb, hub: h, intervalo: intervalo}
+}
+
+// Calcular arma el tablero completo: agregados de Postgres más ubicaciones y
+// aforo en vivo de Redis, y la carga de cada uno
+func (t *Tablero) Calcular(ctx context.Context, eventoID string) (*models.MetricasEvento, error) {
+	m, err := t.repo.Metricas(ctx, eventoID)
+	if err != nil {
+		return nil, err
+	}
+	enVivo, err := t.rastreador.EnVivo(ctx, eventoID)
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range enVivo {
+		m.Personal.ConUbicacion++
+		m.Personal.ConUbicacionPorRol[string(u.RolUsuario)]++
+	}
+	aforo, err := t.contador.Listar(ctx, eventoID)
+	if err != nil {
+		return nil, err
+	}
+	m.ZonasCalientes = calientes(m.ZonasCalientes, aforo)
+	if m.Carga, err = t.medidor.EnVivo(ctx, eventoID, ""); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// calientes ordena por abiertas + recientes y después por aforo. Una zona en
+// alerta o llena entra aunque no tenga incidencias.
+func calientes(zonas []models.ZonaCaliente, aforo []models.Ocupacion) []models.ZonaCaliente {
+	idx := map[string]int{}
+	for i := range zonas {
+		idx[zonas[i].ZonaID] = i
+	}
+	for _, o := range aforo {
+		i, ok := idx[o.ZonaID]
+		if !ok {
+			if o.Nivel == models.OcupacionNormal {
+				continue
+			}
+			nombre := o.ZonaNombre
+			zonas = append(zonas, models.ZonaCaliente{ZonaID: o.ZonaID, ZonaNombre: &nombre})
+			i = len(zonas) - 1
+		}
+		nivel := o.Nivel
+		zonas[i].Porcentaje, zonas[i].Nivel = o.Porcentaje, &nivel
+	}
+	pct := func(z models.ZonaCaliente) float64 {
+		if z.Porcentaje == nil {
+			return 0
+		}
+		return *z.Porcentaje
+	}
+	sort.SliceStable(zonas, func(a, b int) bool {
+		pa, pb := zonas[a].Abiertas+zonas[a].Recientes, zonas[b].Abiertas+zonas[b].Recientes
+		if pa != pb {
+			return pa > pb
+		}
+		if pct(zonas[a]) != pct(zonas[b]) {
+			return pct(zonas[a]) > pct(zonas[b])
+		}
+		return zonas[a].ZonaID < zonas[b].ZonaID
+	})
+	if len(zonas) > maxZonasCalientes {
+		zonas = zonas[:maxZonasCalientes]
+	}
+	return zonas
+}
+
+// Run recalcula el evento en curso en cada intervalo
+func (t *Tablero) Run(ctx context.Context) {
+	ticker := time.NewTicker(t.intervalo)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			t.enviar(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (t *Tablero) enviar(ctx context.Context) {
+	ev, err := t.eventoRepo.ObtenerActivo(ctx)
+	if err != nil || ev == nil {
+		return
+	}
+	// Una sola instancia por intervalo
+	turno, err := t.redis.SetNX(ctx, prefijoTurno+ev.ID, 1, t.intervalo*9/10).Result()
+	if err != nil || !turno {
+		return
+	}
+	m, err := t.Calcular(ctx, ev.ID)
+	if err != nil {
+		log.Println("❌ Error calculando métricas:", err)
+		return
+	}
+	secciones := map[string]interface{}{
+		models.SeccionIncidencias:    m.Incidencias,
+		models.SeccionTareas:         m.Tareas,
+		models.SeccionTiempos:        m.Tiempos,
+		models.SeccionPersonal:       m.Personal,
+		models.SeccionZonasCalientes: m.ZonasCalientes,
+		models.SeccionCarga:          m.Carga,
+	}
+	clave := prefijoHuellas + ev.ID
+	previas, err := t.redis.HGetAll(ctx, clave).Result()
+	if err != nil {
+		log.Println("❌ Error leyendo métricas enviadas:", err)
+		return
+	}
+	cambio := models.MetricasCambio{EventoID: ev.ID, Secciones: map[string]json.RawMessage{}, GeneradoEn: m.GeneradoEn}
+	huellas := map[string]interface{}{}
+	for nombre, valor := range secciones {
+		data, err := json.Marshal(valor)
+		if err != nil {
+			continue
+		}
+		suma := sha1.Sum(data)
+		huella := hex.EncodeToString(suma[:])
+		if previas[nombre] != huella {
+			cambio.Secciones[nombre] = data
+			huellas[nombre] = huella
+		}
+	}
+	if len(cambio.Secciones) == 0 {
+		return
+	}
+	ids, err := t.ubicacionRepo.IDsMando(ctx, ev.ID)
+	if err != nil {
+		log.Println("❌ Error buscando destinatarios de métricas:", err)
+		return
+	}
+	if err := t.hub.PublicarAUsuarios(ctx, ids, models.EventoWS{
+		Tipo:     models.WSMetricasActualizadas,
+		Payload:  cambio,
+		EventoID: ev.ID,
+	}); err != nil {
+		log.Printf("❌ Error publicando %s en Redis: %v", models.WSMetricasActualizadas, err)
+		return
+	}
+	// Las huellas se guardan solo si el envío salió: si no, el próximo
+	// intervalo vuelve a mandar estas secciones
+	pipe := t.redis.TxPipeline()
+	pipe.HSet(ctx, clave, huellas)
+	pipe.Expire(ctx, clave, time.Hour)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Println("❌ Error guardando métricas enviadas:", err)
+	}
+}
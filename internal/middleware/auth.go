@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
@@ -27,7 +28,11 @@ func Auth(jwtSvc *auth.JWTService) gin.HandlerFunc {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Formato inválido: Bearer <token>"})
 			return
 		}
-		claims, err := jwtSvc.ValidarToken(parts[1])
+		claims, err := jwtSvc.ValidarToken(c.Request.Context(), parts[1])
+		if errors.Is(err, auth.ErrSesionRevocada) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Tu sesión terminó. Vuelve a iniciar sesión"})
+			return
+		}
 		if err != nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Token inválido o expirado"})
 			return
@@ -50,6 +55,20 @@ func SoloAdmin() gin.HandlerFunc {
 	}
 }
 
+// SoloRoles restringe el acceso a los roles indicados
+func SoloRoles(roles ...models.Rol) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rol := models.Rol(c.GetString(CtxRol))
+		for _, r := range roles {
+			if rol == r {
+				c.Next()
+				return
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, models.ErrorResponse{Error: "Tu rol no puede realizar esta acción"})
+	}
+}
+
 // SoloTrabajador permite cualquier rol excepto acciones reservadas al admin
 func RequiereEventoActivo() gin.HandlerFunc {
 	return func(c *gin.Context) {
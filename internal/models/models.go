@@ -1,6 +1,13 @@
 package models
 
-import "time"
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
 
 // ─── Roles ────────────────────────────────────────────────────────────────────
 
@@ -44,13 +51,24 @@ func (r Rol) Etiqueta() string {
 
 // ─── Evento ───────────────────────────────────────────────────────────────────
 
+// EstadoEvento: borrador → programado → activo ⇄ pausado → terminado
 type EstadoEvento string
 
 const (
-	EventoActivo    EstadoEvento = "activo"
-	EventoTerminado EstadoEvento = "terminado"
+	EventoBorrador   EstadoEvento = "borrador"
+	EventoProgramado EstadoEvento = "programado"
+	EventoActivo     EstadoEvento = "activo"
+	EventoPausado    EstadoEvento = "pausado"
+	EventoTerminado  EstadoEvento = "terminado"
 )
 
+// EnCurso: ya empezó y no terminó. Un evento pausado sigue siendo el evento
+// en curso (el staff sigue vinculado y puede reportar), solo se frenan los
+// procesos automáticos.
+func (e EstadoEvento) EnCurso() bool {
+	return e == EventoActivo || e == EventoPausado
+}
+
 type Evento struct {
 	ID          string       `json:"id" db:"id"`
 	Nombre      string       `json:"nombre" db:"nombre"`
@@ -59,27 +77,181 @@ type Evento struct {
 	CreadoPor   string       `json:"creado_por" db:"creado_por"`
 	CreadoEn    time.Time    `json:"creado_en" db:"creado_en"`
 	TerminadoEn *time.Time   `json:"terminado_en,omitempty" db:"terminado_en"`
+	Version     int          `json:"version" db:"version"`
+	// Programa: el programador inicia y termina el evento a estas horas
+	InicioProgramado *time.Time `json:"inicio_programado,omitempty" db:"inicio_programado"`
+	FinProgramado    *time.Time `json:"fin_programado,omitempty" db:"fin_programado"`
+	IniciadoEn       *time.Time `json:"iniciado_en,omitempty" db:"iniciado_en"`
+	PausadoEn        *time.Time `json:"pausado_en,omitempty" db:"pausado_en"`
+}
+
+// MotivoTransicion: la pidió un admin, la hizo el programador o la
+// migración 019 (eventos viejos que quedaron activos)
+type MotivoTransicion string
+
+const (
+	TransicionAdmin     MotivoTransicion = "admin"
+	TransicionPrograma  MotivoTransicion = "programa"
+	TransicionMigracion MotivoTransicion = "migracion"
+)
+
+// TransicionEvento es un cambio de estado del evento
+type TransicionEvento struct {
+	ID            string           `json:"id" db:"id"`
+	EventoID      string           `json:"evento_id" db:"evento_id"`
+	Desde         EstadoEvento     `json:"desde" db:"desde"`
+	Hacia         EstadoEvento     `json:"hacia" db:"hacia"`
+	Motivo        MotivoTransicion `json:"motivo" db:"motivo"`
+	UsuarioID     *string          `json:"usuario_id,omitempty" db:"usuario_id"`
+	NombreUsuario *string          `json:"nombre_usuario,omitempty" db:"nombre_usuario"`
+	CreadaEn      time.Time        `json:"creada_en" db:"creada_en"`
 }
 
 // ─── Zona ─────────────────────────────────────────────────────────────────────
 
+// TipoZona es el nivel en la jerarquía: sector → area → punto
+type TipoZona string
+
+const (
+	ZonaSector TipoZona = "sector"
+	ZonaArea   TipoZona = "area"
+	ZonaPunto  TipoZona = "punto"
+)
+
+// Nivel: 1 sector, 2 area, 3 punto; 0 si no es válido. Un hijo siempre
+// tiene nivel mayor que su padre.
+func (t TipoZona) Nivel() int {
+	switch t {
+	case ZonaSector:
+		return 1
+	case ZonaArea:
+		return 2
+	case ZonaPunto:
+		return 3
+	}
+	return 0
+}
+
 type Zona struct {
-	ID       string `json:"id" db:"id"`             // ID manual ej: "bano-norte", "pasillo-4"
-	EventoID string `json:"evento_id" db:"evento_id"`
-	Nombre   string `json:"nombre" db:"nombre"`
+	ID       string   `json:"id" db:"id"` // ID manual ej: "bano-norte", "pasillo-4"
+	EventoID string   `json:"evento_id" db:"evento_id"`
+	Nombre   string   `json:"nombre" db:"nombre"`
+	Tipo     TipoZona `json:"tipo" db:"tipo"`
+	PadreID  *string  `json:"padre_id,omitempty" db:"padre_id"`
+	// Geometría opcional: punto con radio o polígono
+	Latitud  *float64 `json:"latitud,omitempty" db:"latitud"`
+	Longitud *float64 `json:"longitud,omitempty" db:"longitud"`
+	RadioM   *float64 `json:"radio_m,omitempty" db:"radio_m"`
+	Poligono Poligono `json:"poligono,omitempty" db:"poligono"`
+	Piso     *int     `json:"piso,omitempty" db:"piso"`
+	// Aforo: sin capacidad se cuenta gente pero no se alerta
+	Capacidad       *int `json:"capacidad,omitempty" db:"capacidad"`
+	UmbralOcupacion *int `json:"umbral_ocupacion,omitempty" db:"umbral_ocupacion"` // % de la capacidad; nil = el global
+	// Archivada: fuera del mapa y de trabajo nuevo, pero conserva el historial
+	ArchivadaEn *time.Time `json:"archivada_en,omitempty" db:"archivada_en"`
+	Version     int        `json:"version" db:"version"`
+}
+
+// Poligono son las coordenadas de un Polygon GeoJSON: anillos de posiciones
+// [lng, lat]; el primero es el exterior y los demás, huecos
+type Poligono [][][]float64
+
+func (p Poligono) Value() (driver.Value, error) {
+	if p == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(p)
+	return string(b), err
+}
+
+func (p *Poligono) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, p)
+	case string:
+		return json.Unmarshal([]byte(v), p)
+	case nil:
+		*p = nil
+		return nil
+	}
+	return fmt.Errorf("poligono: tipo %T no soportado", src)
+}
+
+// ReferenciasZona cuenta lo que apunta a una zona. Lo abierto impide
+// borrarla o archivarla sin reasignar; el historial impide borrarla.
+type ReferenciasZona struct {
+	IncidenciasAbiertas int `json:"incidencias_abiertas" db:"incidencias_abiertas"`
+	TareasAbiertas      int `json:"tareas_abiertas" db:"tareas_abiertas"`
+	Dispositivos        int `json:"dispositivos" db:"dispositivos"`
+	Historial           int `json:"historial" db:"historial"` // incidencias/tareas cerradas, canales, anuncios, check-ins y rutas
+}
+
+func (r ReferenciasZona) Abiertas() int {
+	return r.IncidenciasAbiertas + r.TareasAbiertas + r.Dispositivos
+}
+
+// RetiroZona es la respuesta de borrar o archivar una zona
+type RetiroZona struct {
+	Accion      string          `json:"accion"` // eliminada | archivada
+	ReasignadaA *string         `json:"reasignada_a,omitempty"`
+	Movidas     ReferenciasZona `json:"movidas"`
+	// Lo reasignado, ya con la zona nueva, para avisar por WebSocket
+	Incidencias []Incidencia `json:"-"`
+	Tareas      []Tarea      `json:"-"`
+}
+
+// ZonaNodo es una zona con sus hijas (GET /zonas/arbol)
+type ZonaNodo struct {
+	Zona
+	Hijas []*ZonaNodo `json:"hijas"`
+}
+
+// ZonaCercana es la respuesta de GET /zonas/cercana
+type ZonaCercana struct {
+	Zona       Zona    `json:"zona"`
+	Dentro     bool    `json:"dentro"`      // el punto cae dentro de la geometría
+	DistanciaM float64 `json:"distancia_m"` // 0 si está dentro
+}
+
+// ElementoMapa es una incidencia o tarea abierta ubicada en su zona
+type ElementoMapa struct {
+	Capa     string `json:"capa" db:"capa"` // incidencia | tarea
+	ID       string `json:"id" db:"id"`
+	ZonaID   string `json:"zona_id" db:"zona_id"`
+	Etiqueta string `json:"etiqueta" db:"etiqueta"` // tipo de la incidencia o título de la tarea
+	Estado   string `json:"estado" db:"estado"`
+}
+
+// ─── GeoJSON ──────────────────────────────────────────────────────────────────
+
+type ColeccionGeoJSON struct {
+	Type     string           `json:"type"` // siempre "FeatureCollection"
+	Features []FeatureGeoJSON `json:"features"`
+}
+
+type FeatureGeoJSON struct {
+	Type       string                 `json:"type"` // siempre "Feature"
+	ID         string                 `json:"id,omitempty"`
+	Geometry   *GeometriaGeoJSON      `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type GeometriaGeoJSON struct {
+	Type        string      `json:"type"` // "Point" o "Polygon"
+	Coordinates interface{} `json:"coordinates"`
 }
 
 // ─── Usuario ──────────────────────────────────────────────────────────────────
 
 type Usuario struct {
-	ID           string     `json:"id" db:"id"`
+	ID            string    `json:"id" db:"id"`
 	NombreUsuario string    `json:"nombre_usuario" db:"nombre_usuario"` // username de login
-	Nombre       string     `json:"nombre" db:"nombre"`                 // nombre visible
-	Password     string     `json:"-" db:"password_hash"`
-	Rol          Rol        `json:"rol" db:"rol"`
-	EventoID     *string    `json:"evento_id,omitempty" db:"evento_id"` // vinculado al evento activo
-	Activo       bool       `json:"activo" db:"activo"`
-	CreadoEn     time.Time  `json:"creado_en" db:"creado_en"`
+	Nombre        string    `json:"nombre" db:"nombre"`                 // nombre visible
+	Password      string    `json:"-" db:"password_hash"`
+	Rol           Rol       `json:"rol" db:"rol"`
+	EventoID      *string   `json:"evento_id,omitempty" db:"evento_id"` // vinculado al evento activo
+	Activo        bool      `json:"activo" db:"activo"`
+	CreadoEn      time.Time `json:"creado_en" db:"creado_en"`
 }
 
 // ─── Incidencia ───────────────────────────────────────────────────────────────
@@ -100,21 +272,32 @@ const (
 	TipoReabastecimiento TipoIncidencia = "reabastecimiento"
 	TipoMedico           TipoIncidencia = "medico"
 	TipoOtro             TipoIncidencia = "otro"
+	TipoSOS              TipoIncidencia = "sos" // botón de pánico; solo se crea por /sos
 )
 
+// EsValido cubre los tipos que se pueden elegir al crear; TipoSOS queda fuera
+func (t TipoIncidencia) EsValido() bool {
+	switch t {
+	case TipoDerrame, TipoSeguridad, TipoReabastecimiento, TipoMedico, TipoOtro:
+		return true
+	}
+	return false
+}
+
 type Incidencia struct {
-	ID            string           `json:"id" db:"id"`
-	EventoID      string           `json:"evento_id" db:"evento_id"`
-	ZonaID        string           `json:"zona_id" db:"zona_id"`
-	ZonaNombre    string           `json:"zona_nombre,omitempty" db:"zona_nombre"`
-	Tipo          TipoIncidencia   `json:"tipo" db:"tipo"`
-	Descripcion   string           `json:"descripcion" db:"descripcion"`
-	Estado        EstadoIncidencia `json:"estado" db:"estado"`
-	CreadaPor     string           `json:"creada_por" db:"creada_por"`          // admin
-	AsignadaA     *string          `json:"asignada_a,omitempty" db:"asignada_a"` // trabajador que la atiende
-	NombreAsignado *string         `json:"nombre_asignado,omitempty" db:"nombre_asignado"`
-	CreadaEn      time.Time        `json:"creada_en" db:"creada_en"`
-	ActualizadaEn time.Time        `json:"actualizada_en" db:"actualizada_en"`
+	ID             string           `json:"id" db:"id"`
+	EventoID       string           `json:"evento_id" db:"evento_id"`
+	ZonaID         string           `json:"zona_id" db:"zona_id"`
+	ZonaNombre     string           `json:"zona_nombre,omitempty" db:"zona_nombre"`
+	Tipo           TipoIncidencia   `json:"tipo" db:"tipo"`
+	Descripcion    string           `json:"descripcion" db:"descripcion"`
+	Estado         EstadoIncidencia `json:"estado" db:"estado"`
+	CreadaPor      string           `json:"creada_por" db:"creada_por"`           // admin
+	AsignadaA      *string          `json:"asignada_a,omitempty" db:"asignada_a"` // trabajador que la atiende
+	NombreAsignado *string          `json:"nombre_asignado,omitempty" db:"nombre_asignado"`
+	CreadaEn       time.Time        `json:"creada_en" db:"creada_en"`
+	ActualizadaEn  time.Time        `json:"actualizada_en" db:"actualizada_en"`
+	Version        int              `json:"version" db:"version"`
 }
 
 // ─── Tarea ────────────────────────────────────────────────────────────────────
@@ -125,6 +308,7 @@ const (
 	TareaPendiente  EstadoTarea = "pendiente"
 	TareaEnProgreso EstadoTarea = "en_progreso"
 	TareaCompletada EstadoTarea = "completada"
+	TareaCancelada  EstadoTarea = "cancelada" // la cerró el cierre del evento sin hacerse
 )
 
 type PrioridadTarea string
@@ -149,101 +333,1800 @@ type Tarea struct {
 	NombreAsignado *string        `json:"nombre_asignado,omitempty" db:"nombre_asignado"`
 	CompletadaEn   *time.Time     `json:"completada_en,omitempty" db:"completada_en"`
 	CreadaEn       time.Time      `json:"creada_en" db:"creada_en"`
+	Version        int            `json:"version" db:"version"`
+	// Tipos de TipoEvidencia exigidos antes de pasar a TareaCompletada
+	RequiereEvidencia pq.StringArray `json:"requiere_evidencia" db:"requiere_evidencia"`
+}
+
+// ─── Evidencia de Tarea ───────────────────────────────────────────────────────
+
+type TipoEvidencia string
+
+const (
+	EvidenciaFoto TipoEvidencia = "foto"
+	EvidenciaNota TipoEvidencia = "nota"
+	EvidenciaQR   TipoEvidencia = "qr"  // QR de la zona escaneado en sitio
+	EvidenciaGPS  TipoEvidencia = "gps" // posición al completar
+)
+
+func (t TipoEvidencia) EsValido() bool {
+	switch t {
+	case EvidenciaFoto, EvidenciaNota, EvidenciaQR, EvidenciaGPS:
+		return true
+	}
+	return false
+}
+
+type EvidenciaTarea struct {
+	ID            string        `json:"id" db:"id"`
+	TareaID       string        `json:"tarea_id" db:"tarea_id"`
+	Tipo          TipoEvidencia `json:"tipo" db:"tipo"`
+	Nota          *string       `json:"nota,omitempty" db:"nota"`
+	QR            *string       `json:"qr,omitempty" db:"qr"`
+	Latitud       *float64      `json:"latitud,omitempty" db:"latitud"`
+	Longitud      *float64      `json:"longitud,omitempty" db:"longitud"`
+	FotoMime      *string       `json:"foto_mime,omitempty" db:"foto_mime"` // la foto se descarga aparte
+	UsuarioID     string        `json:"usuario_id" db:"usuario_id"`
+	NombreUsuario string        `json:"nombre_usuario" db:"nombre_usuario"`
+	CreadaEn      time.Time     `json:"creada_en" db:"creada_en"`
 }
 
 // ─── Mensaje Chat ─────────────────────────────────────────────────────────────
 
 type Mensaje struct {
-	ID            string    `json:"id" db:"id"`
-	EventoID      string    `json:"evento_id" db:"evento_id"`
-	UsuarioID     string    `json:"usuario_id" db:"usuario_id"`
-	NombreUsuario string    `json:"nombre_usuario" db:"nombre_usuario"`
-	RolUsuario    Rol       `json:"rol_usuario" db:"rol_usuario"`
-	Contenido     string    `json:"contenido" db:"contenido"`
-	EnviadoEn     time.Time `json:"enviado_en" db:"enviado_en"`
+	ID             string     `json:"id" db:"id"`
+	EventoID       string     `json:"evento_id" db:"evento_id"`
+	ConversacionID string     `json:"conversacion_id" db:"conversacion_id"`
+	UsuarioID      string     `json:"usuario_id" db:"usuario_id"`
+	NombreUsuario  string     `json:"nombre_usuario" db:"nombre_usuario"`
+	RolUsuario     Rol        `json:"rol_usuario" db:"rol_usuario"`
+	Contenido      string     `json:"contenido" db:"contenido"` // vacío si fue eliminado
+	EnviadoEn      time.Time  `json:"enviado_en" db:"enviado_en"`
+	EditadoEn      *time.Time `json:"editado_en,omitempty" db:"editado_en"`
+	Eliminado      bool       `json:"eliminado" db:"eliminado"`
+	Version        int        `json:"version" db:"version"`
+	Menciones      []Mencion  `json:"menciones,omitempty" db:"-"`
 }
 
-// ─── Eventos WebSocket ────────────────────────────────────────────────────────
+// ─── Menciones ────────────────────────────────────────────────────────────────
 
-type TipoEventoWS string
+type TipoMencion string
 
 const (
-	// Incidencias
-	WSIncidenciaNueva       TipoEventoWS = "incidencia_nueva"
-	WSIncidenciaActualizada TipoEventoWS = "incidencia_actualizada"
-	// Tareas
-	WSTareaNueva       TipoEventoWS = "tarea_nueva"
-	WSTareaActualizada TipoEventoWS = "tarea_actualizada"
-	// Chat grupal
-	WSMensajeNuevo TipoEventoWS = "mensaje_nuevo"
-	// Sistema
-	WSEventoTerminado TipoEventoWS = "evento_terminado"
-	WSPing            TipoEventoWS = "ping"
+	MencionUsuario    TipoMencion = "usuario"    // @nombre_usuario
+	MencionRol        TipoMencion = "rol"        // @guardia
+	MencionIncidencia TipoMencion = "incidencia" // #inc-3f2a9c
+	MencionTarea      TipoMencion = "tarea"      // #tarea-81b0d2
 )
 
-type EventoWS struct {
-	Tipo     TipoEventoWS `json:"tipo"`
-	Payload  interface{}  `json:"payload"`
-	EventoID string       `json:"evento_id"`
+// CandidatoMencion es un token encontrado en el texto, todavía sin resolver.
+// Clave va en minúsculas: nombre de usuario, rol o prefijo del id.
+type CandidatoMencion struct {
+	Tipo  TipoMencion
+	Clave string
+	Texto string
 }
 
-// ─── DTOs Request ─────────────────────────────────────────────────────────────
+// Mencion es una referencia ya resuelta. Según el tipo viene solo uno de:
+// UsuarioID+Nombre, Rol, Incidencia o Tarea. Las vistas previas reflejan el
+// estado actual de la incidencia o tarea, no el del momento del mensaje.
+type Mencion struct {
+	Tipo       TipoMencion        `json:"tipo"`
+	Texto      string             `json:"texto"`
+	UsuarioID  *string            `json:"usuario_id,omitempty"`
+	Nombre     *string            `json:"nombre,omitempty"`
+	Rol        *Rol               `json:"rol,omitempty"`
+	Incidencia *ResumenIncidencia `json:"incidencia,omitempty"`
+	Tarea      *ResumenTarea      `json:"tarea,omitempty"`
+}
 
-type LoginRequest struct {
-	NombreUsuario string `json:"nombre_usuario" binding:"required"`
-	Password_hash     string `json:"password_hash" binding:"required,min=4"`
+type ResumenIncidencia struct {
+	ID             string           `json:"id" db:"id"`
+	Tipo           TipoIncidencia   `json:"tipo" db:"tipo"`
+	Estado         EstadoIncidencia `json:"estado" db:"estado"`
+	ZonaID         string           `json:"zona_id" db:"zona_id"`
+	ZonaNombre     *string          `json:"zona_nombre,omitempty" db:"zona_nombre"`
+	Descripcion    string           `json:"descripcion" db:"descripcion"`
+	NombreAsignado *string          `json:"nombre_asignado,omitempty" db:"nombre_asignado"`
 }
 
-type LoginResponse struct {
-	Token   string  `json:"token"`
-	Usuario Usuario `json:"usuario"`
+type ResumenTarea struct {
+	ID             string         `json:"id" db:"id"`
+	Titulo         string         `json:"titulo" db:"titulo"`
+	Estado         EstadoTarea    `json:"estado" db:"estado"`
+	Prioridad      PrioridadTarea `json:"prioridad" db:"prioridad"`
+	ZonaID         *string        `json:"zona_id,omitempty" db:"zona_id"`
+	ZonaNombre     *string        `json:"zona_nombre,omitempty" db:"zona_nombre"`
+	NombreAsignado *string        `json:"nombre_asignado,omitempty" db:"nombre_asignado"`
 }
 
-type CrearEventoRequest struct {
-	Nombre      string `json:"nombre" binding:"required,min=3"`
-	Descripcion string `json:"descripcion"`
+type RevisionMensaje struct {
+	ID         string    `json:"id" db:"id"`
+	MensajeID  string    `json:"mensaje_id" db:"mensaje_id"`
+	Contenido  string    `json:"contenido" db:"contenido"`
+	EditadoPor string    `json:"editado_por" db:"editado_por"`
+	EditadoEn  time.Time `json:"editado_en" db:"editado_en"`
 }
 
-type TerminarEventoRequest struct {
-	EventoID string `json:"evento_id" binding:"required"`
+// ─── Moderación ───────────────────────────────────────────────────────────────
+
+type Silencio struct {
+	EventoID  string    `json:"evento_id" db:"evento_id"`
+	UsuarioID string    `json:"usuario_id" db:"usuario_id"`
+	Hasta     time.Time `json:"hasta" db:"hasta"`
+	Motivo    *string   `json:"motivo,omitempty" db:"motivo"`
+	CreadoPor string    `json:"creado_por" db:"creado_por"`
+	CreadoEn  time.Time `json:"creado_en" db:"creado_en"`
 }
 
-type CrearZonaRequest struct {
-	ID     string `json:"id" binding:"required"`     // manual ej: "bano-norte"
-	Nombre string `json:"nombre" binding:"required"`
+type AccionFiltro string
+
+const (
+	FiltroCensurar AccionFiltro = "censurar" // la palabra se reemplaza por asteriscos
+	FiltroBloquear AccionFiltro = "bloquear" // el mensaje se rechaza
+)
+
+type FiltroChat struct {
+	ID       string       `json:"id" db:"id"`
+	EventoID string       `json:"evento_id" db:"evento_id"`
+	Palabra  string       `json:"palabra" db:"palabra"`
+	Accion   AccionFiltro `json:"accion" db:"accion"`
+	CreadoEn time.Time    `json:"creado_en" db:"creado_en"`
 }
 
-type CrearUsuarioRequest struct {
-	NombreUsuario string  `json:"nombre_usuario" binding:"required,min=3"`
-	Nombre        string  `json:"nombre" binding:"required,min=2"`
-	Password      string  `json:"password" binding:"required,min=4"`
-	Rol           Rol     `json:"rol" binding:"required"`
-	ZonaID        *string `json:"zona_id,omitempty"`
+// ─── Conversación ─────────────────────────────────────────────────────────────
+
+type TipoConversacion string
+
+const (
+	ConversacionEvento  TipoConversacion = "evento"  // sala general, todos los del evento
+	ConversacionRol     TipoConversacion = "rol"     // ej: todos los guardias
+	ConversacionDirecto TipoConversacion = "directo" // 1:1
+	ConversacionGrupo   TipoConversacion = "grupo"   // ad-hoc
+	ConversacionZona    TipoConversacion = "zona"    // staff de una zona
+)
+
+type Conversacion struct {
+	ID        string           `json:"id" db:"id"`
+	EventoID  string           `json:"evento_id" db:"evento_id"`
+	Tipo      TipoConversacion `json:"tipo" db:"tipo"`
+	Nombre    string           `json:"nombre" db:"nombre"` // en directos: el nombre del otro
+	Rol       *Rol             `json:"rol,omitempty" db:"rol"`
+	ZonaID    *string          `json:"zona_id,omitempty" db:"zona_id"`
+	CreadaPor *string          `json:"creada_por,omitempty" db:"creada_por"`
+	CreadaEn  time.Time        `json:"creada_en" db:"creada_en"`
+	NoLeidos  int              `json:"no_leidos" db:"no_leidos"`
 }
 
-type CrearIncidenciaRequest struct {
-	ZonaID      string         `json:"zona_id" binding:"required"`
-	Tipo        TipoIncidencia `json:"tipo" binding:"required"`
-	Descripcion string         `json:"descripcion" binding:"required,min=5"`
-	AsignadaA   *string        `json:"asignada_a,omitempty"`
+// LecturaMensaje es el payload de WSMensajeLeido: usuario leyó hasta mensaje_id
+type LecturaMensaje struct {
+	ConversacionID string    `json:"conversacion_id" db:"conversacion_id"`
+	UsuarioID      string    `json:"usuario_id" db:"usuario_id"`
+	NombreUsuario  string    `json:"nombre_usuario" db:"nombre_usuario"`
+	MensajeID      string    `json:"mensaje_id" db:"mensaje_id"`
+	LeidoEn        time.Time `json:"leido_en" db:"leido_en"`
 }
 
-type EditarIncidenciaRequest struct {
-	Estado    *EstadoIncidencia `json:"estado,omitempty"`
-	AsignadaA *string           `json:"asignada_a,omitempty"`
+type NoLeidosConversacion struct {
+	ConversacionID string `json:"conversacion_id"`
+	NoLeidos       int    `json:"no_leidos"`
 }
 
-type CrearTareaRequest struct {
-	ZonaID      string         `json:"zona_id,omitempty"`
-	Titulo      string         `json:"titulo" binding:"required,min=3"`
-	Descripcion string         `json:"descripcion"`
-	Prioridad   PrioridadTarea `json:"prioridad" binding:"required"`
-	AsignadaA   *string        `json:"asignada_a,omitempty"`
+type NoLeidosResponse struct {
+	Total          int                    `json:"total"`
+	Conversaciones []NoLeidosConversacion `json:"conversaciones"`
 }
 
-type EditarTareaRequest struct {
-	Estado    *EstadoTarea `json:"estado,omitempty"`
-	AsignadaA *string      `json:"asignada_a,omitempty"`
+// Escribiendo es el payload efímero de WSEscribiendo (nunca se guarda)
+type Escribiendo struct {
+	ConversacionID string `json:"conversacion_id"`
+	UsuarioID      string `json:"usuario_id"`
+	Escribiendo    bool   `json:"escribiendo"`
+}
+
+// ─── Anuncio ──────────────────────────────────────────────────────────────────
+
+type DestinoAnuncio string
+
+const (
+	DestinoTodos DestinoAnuncio = "todos"
+	DestinoRol   DestinoAnuncio = "rol"
+	DestinoZona  DestinoAnuncio = "zona" // miembros del canal de chat de la zona
+)
+
+type Anuncio struct {
+	ID              string         `json:"id" db:"id"`
+	EventoID        string         `json:"evento_id" db:"evento_id"`
+	Titulo          string         `json:"titulo" db:"titulo"`
+	Contenido       string         `json:"contenido" db:"contenido"`
+	Destino         DestinoAnuncio `json:"destino" db:"destino"`
+	Rol             *Rol           `json:"rol,omitempty" db:"rol"`
+	ZonaID          *string        `json:"zona_id,omitempty" db:"zona_id"`
+	ReenviarCadaSeg int            `json:"reenviar_cada_seg" db:"reenviar_cada_seg"`
+	CreadoPor       string         `json:"creado_por" db:"creado_por"`
+	NombreCreador   string         `json:"nombre_creador" db:"nombre_creador"`
+	CreadoEn        time.Time      `json:"creado_en" db:"creado_en"`
+	CerradoEn       *time.Time     `json:"cerrado_en,omitempty" db:"cerrado_en"`
+	Destinatarios   int            `json:"destinatarios" db:"destinatarios"`
+	Confirmados     int            `json:"confirmados" db:"confirmados"`
+	// Solo en la vista del trabajador: cuándo confirmó él
+	ConfirmadoEn *time.Time `json:"confirmado_en,omitempty" db:"confirmado_en"`
+}
+
+type DestinatarioAnuncio struct {
+	UsuarioID    string     `json:"usuario_id" db:"usuario_id"`
+	Nombre       string     `json:"nombre" db:"nombre"`
+	Rol          Rol        `json:"rol" db:"rol"`
+	Entregas     int        `json:"entregas" db:"entregas"`
+	UltimoEnvio  time.Time  `json:"ultimo_envio" db:"ultimo_envio"`
+	ConfirmadoEn *time.Time `json:"confirmado_en,omitempty" db:"confirmado_en"`
+}
+
+// EstadoAnuncio es el tablero de confirmaciones de un anuncio
+type EstadoAnuncio struct {
+	Anuncio     Anuncio               `json:"anuncio"`
+	Confirmados []DestinatarioAnuncio `json:"confirmados"`
+	Pendientes  []DestinatarioAnuncio `json:"pendientes"`
+}
+
+// ConfirmacionAnuncio es el payload de WSAnuncioConfirmado
+type ConfirmacionAnuncio struct {
+	AnuncioID    string    `json:"anuncio_id"`
+	UsuarioID    string    `json:"usuario_id"`
+	Nombre       string    `json:"nombre"`
+	ConfirmadoEn time.Time `json:"confirmado_en"`
+	Confirmados  int       `json:"confirmados"`
+	Total        int       `json:"destinatarios"`
+}
+
+// ─── Notificaciones ───────────────────────────────────────────────────────────
+
+type CanalNotificacion string
+
+const (
+	CanalEmail CanalNotificacion = "email"
+	CanalSMS   CanalNotificacion = "sms"
+	CanalPush  CanalNotificacion = "push"
+)
+
+func (c CanalNotificacion) EsValido() bool {
+	return c == CanalEmail || c == CanalSMS || c == CanalPush
+}
+
+type TipoNotificacion string
+
+const (
+	NotifAsignacion   TipoNotificacion = "asignacion"   // te asignaron una incidencia o tarea
+	NotifEscalamiento TipoNotificacion = "escalamiento" // incidencia pendiente sin atender
+	NotifAnuncio      TipoNotificacion = "anuncio"      // anuncio prioritario
+	NotifSOS          TipoNotificacion = "sos"          // alguien del staff pidió auxilio
+	NotifRonda        TipoNotificacion = "ronda"        // un guardia no pasó por un punto de su ronda
+)
+
+// EsValido cubre los tipos que el usuario puede elegir en sus preferencias
+func (t TipoNotificacion) EsValido() bool {
+	return t == NotifAsignacion || t == NotifEscalamiento || t == NotifAnuncio || t == NotifRonda
+}
+
+// Obligatoria: no se puede desactivar ni la frena el horario de silencio
+func (t TipoNotificacion) Obligatoria() bool { return t == NotifSOS }
+
+// Notificacion es lo que se entrega fuera de la app. Urgente atraviesa el
+// horario de silencio si el usuario lo permite.
+type Notificacion struct {
+	Tipo    TipoNotificacion `json:"tipo"`
+	Titulo  string           `json:"titulo"`
+	Cuerpo  string           `json:"cuerpo"`
+	Urgente bool             `json:"urgente"`
+	Ref     string           `json:"ref,omitempty"` // id de la incidencia, tarea o anuncio
+}
+
+type PreferenciasNotificacion struct {
+	UsuarioID          string         `json:"usuario_id" db:"usuario_id"`
+	Canales            pq.StringArray `json:"canales" db:"canales"`
+	Tipos              pq.StringArray `json:"tipos" db:"tipos"`
+	Email              *string        `json:"email,omitempty" db:"email"`
+	Telefono           *string        `json:"telefono,omitempty" db:"telefono"`
+	SilencioDesde      *string        `json:"silencio_desde,omitempty" db:"silencio_desde"` // "22:00"
+	SilencioHasta      *string        `json:"silencio_hasta,omitempty" db:"silencio_hasta"` // "07:00"
+	ZonaHoraria        string         `json:"zona_horaria" db:"zona_horaria"`
+	UrgentesEnSilencio bool           `json:"urgentes_en_silencio" db:"urgentes_en_silencio"`
+}
+
+type SuscripcionPush struct {
+	ID        string    `json:"id" db:"id"`
+	UsuarioID string    `json:"usuario_id" db:"usuario_id"`
+	Endpoint  string    `json:"endpoint" db:"endpoint"`
+	P256dh    string    `json:"-" db:"p256dh"`
+	Auth      string    `json:"-" db:"auth"`
+	CreadaEn  time.Time `json:"creada_en" db:"creada_en"`
+}
+
+type EstadoEnvio string
+
+const (
+	EnvioEnviado EstadoEnvio = "enviado"
+	EnvioFallido EstadoEnvio = "fallido"
+	EnvioOmitido EstadoEnvio = "omitido" // horario de silencio, tipo desactivado o sin contacto
+)
+
+type EnvioNotificacion struct {
+	ID        string             `json:"id" db:"id"`
+	UsuarioID string             `json:"usuario_id" db:"usuario_id"`
+	Tipo      TipoNotificacion   `json:"tipo" db:"tipo"`
+	Canal     *CanalNotificacion `json:"canal,omitempty" db:"canal"`
+	Titulo    string             `json:"titulo" db:"titulo"`
+	Cuerpo    string             `json:"cuerpo" db:"cuerpo"`
+	Estado    EstadoEnvio        `json:"estado" db:"estado"`
+	Detalle   *string            `json:"detalle,omitempty" db:"detalle"`
+	CreadoEn  time.Time          `json:"creado_en" db:"creado_en"`
+}
+
+// ─── Webhook ──────────────────────────────────────────────────────────────────
+
+type Webhook struct {
+	ID          string         `json:"id" db:"id"`
+	EventoID    string         `json:"evento_id" db:"evento_id"`
+	URL         string         `json:"url" db:"url"`
+	Descripcion *string        `json:"descripcion,omitempty" db:"descripcion"`
+	Tipos       pq.StringArray `json:"tipos" db:"tipos"`
+	Secreto     string         `json:"secreto,omitempty" db:"secreto"` // solo al crear
+	Activo      bool           `json:"activo" db:"activo"`
+	CreadoPor   string         `json:"creado_por" db:"creado_por"`
+	CreadoEn    time.Time      `json:"creado_en" db:"creado_en"`
+	Version     int            `json:"version" db:"version"`
+}
+
+type EstadoEntrega string
+
+const (
+	EntregaPendiente EstadoEntrega = "pendiente"
+	EntregaEntregada EstadoEntrega = "entregada"
+	EntregaMuerta    EstadoEntrega = "muerta" // agotó los intentos
+)
+
+type EntregaWebhook struct {
+	ID             string          `json:"id" db:"id"`
+	WebhookID      string          `json:"webhook_id" db:"webhook_id"`
+	Tipo           TipoEventoWS    `json:"tipo" db:"tipo"`
+	Payload        json.RawMessage `json:"payload" db:"payload"`
+	Estado         EstadoEntrega   `json:"estado" db:"estado"`
+	Intentos       int             `json:"intentos" db:"intentos"`
+	UltimoStatus   *int            `json:"ultimo_status,omitempty" db:"ultimo_status"`
+	UltimoError    *string         `json:"ultimo_error,omitempty" db:"ultimo_error"`
+	ProximoIntento time.Time       `json:"proximo_intento" db:"proximo_intento"`
+	CreadaEn       time.Time       `json:"creada_en" db:"creada_en"`
+	EntregadaEn    *time.Time      `json:"entregada_en,omitempty" db:"entregada_en"`
+}
+
+// ─── SOS ──────────────────────────────────────────────────────────────────────
+
+// SOS es la alerta de pánico con la última ubicación conocida de quien la
+// activó. Activa mientras su incidencia no esté resuelta.
+type SOS struct {
+	ID            string           `json:"id" db:"id"`
+	IncidenciaID  string           `json:"incidencia_id" db:"incidencia_id"`
+	EventoID      string           `json:"evento_id" db:"evento_id"`
+	UsuarioID     string           `json:"usuario_id" db:"usuario_id"`
+	NombreUsuario string           `json:"nombre_usuario" db:"nombre_usuario"`
+	RolUsuario    Rol              `json:"rol_usuario" db:"rol_usuario"`
+	ZonaID        string           `json:"zona_id" db:"zona_id"`
+	ZonaNombre    *string          `json:"zona_nombre,omitempty" db:"zona_nombre"`
+	Estado        EstadoIncidencia `json:"estado" db:"estado"`
+	Activa        bool             `json:"activa" db:"activa"`
+	Latitud       *float64         `json:"latitud,omitempty" db:"latitud"`
+	Longitud      *float64         `json:"longitud,omitempty" db:"longitud"`
+	PrecisionM    *float64         `json:"precision_m,omitempty" db:"precision_m"`
+	UbicacionEn   *time.Time       `json:"ubicacion_en,omitempty" db:"ubicacion_en"` // antigüedad de la posición
+	CreadaEn      time.Time        `json:"creada_en" db:"creada_en"`
+}
+
+// UbicacionSOS es un punto del rastro; también es el payload de WSSOSUbicacion
+type UbicacionSOS struct {
+	SOSID        string    `json:"sos_id" db:"sos_id"`
+	Latitud      float64   `json:"latitud" db:"latitud"`
+	Longitud     float64   `json:"longitud" db:"longitud"`
+	PrecisionM   *float64  `json:"precision_m,omitempty" db:"precision_m"`
+	ZonaID       *string   `json:"zona_id,omitempty" db:"zona_id"`
+	RegistradaEn time.Time `json:"registrada_en" db:"registrada_en"`
+}
+
+// PosicionConocida es lo último que se sabe de dónde anda un usuario
+type PosicionConocida struct {
+	Latitud  *float64  `db:"latitud"`
+	Longitud *float64  `db:"longitud"`
+	ZonaID   *string   `db:"zona_id"`
+	En       time.Time `db:"en"`
+}
+
+// ─── Ubicación del staff ──────────────────────────────────────────────────────
+
+type FuenteUbicacion string
+
+const (
+	FuenteGPS    FuenteUbicacion = "gps"
+	FuenteQR     FuenteUbicacion = "qr"     // check-in en el QR de una zona
+	FuenteBaliza FuenteUbicacion = "baliza" // beacon BLE de una zona
+)
+
+func (f FuenteUbicacion) EsValida() bool {
+	switch f {
+	case FuenteGPS, FuenteQR, FuenteBaliza:
+		return true
+	}
+	return false
+}
+
+type MotivoFinTurno string
+
+const (
+	FinPorUsuario    MotivoFinTurno = "usuario"
+	FinPorSupervisor MotivoFinTurno = "supervisor"
+	FinPorDuracion   MotivoFinTurno = "duracion" // superó la duración máxima
+	FinPorEvento     MotivoFinTurno = "evento"   // el evento dejó de estar activo
+)
+
+// Turno delimita cuándo se rastrea a alguien: fuera de un turno abierto no se
+// acepta ni se guarda su ubicación.
+type Turno struct {
+	ID            string          `json:"id" db:"id"`
+	UsuarioID     string          `json:"usuario_id" db:"usuario_id"`
+	NombreUsuario string          `json:"nombre_usuario" db:"nombre_usuario"`
+	RolUsuario    Rol             `json:"rol_usuario" db:"rol_usuario"`
+	EventoID      string          `json:"evento_id" db:"evento_id"`
+	Inicio        time.Time       `json:"inicio" db:"inicio"`
+	Fin           *time.Time      `json:"fin,omitempty" db:"fin"`
+	MotivoFin     *MotivoFinTurno `json:"motivo_fin,omitempty" db:"motivo_fin"`
+}
+
+// Ubicacion es la posición en vivo (Redis) o un punto del historial muestreado
+// (Postgres). También es el payload de WSUbicacionActualizada.
+type Ubicacion struct {
+	UsuarioID     string          `json:"usuario_id" db:"usuario_id"`
+	NombreUsuario string          `json:"nombre_usuario,omitempty" db:"nombre_usuario"`
+	RolUsuario    Rol             `json:"rol_usuario,omitempty" db:"rol_usuario"`
+	EventoID      string          `json:"evento_id" db:"evento_id"`
+	Latitud       *float64        `json:"latitud,omitempty" db:"latitud"`
+	Longitud      *float64        `json:"longitud,omitempty" db:"longitud"`
+	PrecisionM    *float64        `json:"precision_m,omitempty" db:"precision_m"`
+	ZonaID        *string         `json:"zona_id,omitempty" db:"zona_id"`
+	Fuente        FuenteUbicacion `json:"fuente" db:"fuente"`
+	RegistradaEn  time.Time       `json:"registrada_en" db:"registrada_en"`
+}
+
+// UbicacionRetirada es el payload de WSUbicacionRetirada: el usuario sale del
+// mapa porque terminó su turno
+type UbicacionRetirada struct {
+	UsuarioID string         `json:"usuario_id"`
+	Motivo    MotivoFinTurno `json:"motivo"`
+}
+
+// ─── Patrullas ────────────────────────────────────────────────────────────────
+
+// Checkin es un escaneo del QR de una zona
+type Checkin struct {
+	ID            string    `json:"id" db:"id"`
+	EventoID      string    `json:"evento_id" db:"evento_id"`
+	ZonaID        string    `json:"zona_id" db:"zona_id"`
+	ZonaNombre    string    `json:"zona_nombre" db:"zona_nombre"`
+	UsuarioID     string    `json:"usuario_id" db:"usuario_id"`
+	NombreUsuario string    `json:"nombre_usuario" db:"nombre_usuario"`
+	RondaID       *string   `json:"ronda_id,omitempty" db:"ronda_id"`
+	RegistradoEn  time.Time `json:"registrado_en" db:"registrado_en"`
+}
+
+// PuntoRuta es una parada de la ruta; IntervaloMin se cuenta desde la parada
+// anterior (o desde el inicio de la ronda, para la primera)
+type PuntoRuta struct {
+	Orden        int    `json:"orden" db:"orden"`
+	ZonaID       string `json:"zona_id" db:"zona_id" binding:"required"`
+	ZonaNombre   string `json:"zona_nombre,omitempty" db:"zona_nombre"`
+	IntervaloMin int    `json:"intervalo_min" db:"intervalo_min" binding:"required,min=1"`
+}
+
+type RutaPatrulla struct {
+	ID            string      `json:"id" db:"id"`
+	EventoID      string      `json:"evento_id" db:"evento_id"`
+	Nombre        string      `json:"nombre" db:"nombre"`
+	ToleranciaMin int         `json:"tolerancia_min" db:"tolerancia_min"`
+	Activa        bool        `json:"activa" db:"activa"`
+	CreadaPor     *string     `json:"creada_por,omitempty" db:"creada_por"`
+	CreadaEn      time.Time   `json:"creada_en" db:"creada_en"`
+	Puntos        []PuntoRuta `json:"puntos" db:"-"`
+}
+
+type EstadoRonda string
+
+const (
+	RondaEnCurso    EstadoRonda = "en_curso"
+	RondaCompletada EstadoRonda = "completada" // se marcaron todos los puntos (aunque sea tarde)
+	RondaIncompleta EstadoRonda = "incompleta" // terminó con puntos omitidos
+	RondaCancelada  EstadoRonda = "cancelada"
+)
+
+type EstadoMarca string
+
+const (
+	MarcaATiempo EstadoMarca = "a_tiempo"
+	MarcaTarde   EstadoMarca = "tarde"   // pasado lo esperado, dentro de la tolerancia
+	MarcaOmitida EstadoMarca = "omitido" // venció la tolerancia o se saltó el punto
+)
+
+type MarcaRonda struct {
+	Orden      int         `json:"orden" db:"orden"`
+	ZonaID     string      `json:"zona_id" db:"zona_id"`
+	Estado     EstadoMarca `json:"estado" db:"estado"`
+	EsperadoEn time.Time   `json:"esperado_en" db:"esperado_en"`
+	MarcadaEn  *time.Time  `json:"marcada_en,omitempty" db:"marcada_en"`
+}
+
+// Ronda es una pasada de un guardia por una ruta. También es el payload de
+// WSRondaActualizada.
+type Ronda struct {
+	ID            string       `json:"id" db:"id"`
+	RutaID        string       `json:"ruta_id" db:"ruta_id"`
+	RutaNombre    string       `json:"ruta_nombre" db:"ruta_nombre"`
+	EventoID      string       `json:"evento_id" db:"evento_id"`
+	UsuarioID     string       `json:"usuario_id" db:"usuario_id"`
+	NombreUsuario string       `json:"nombre_usuario" db:"nombre_usuario"`
+	Estado        EstadoRonda  `json:"estado" db:"estado"`
+	Siguiente     int          `json:"siguiente" db:"siguiente"`
+	VenceEn       *time.Time   `json:"vence_en,omitempty" db:"vence_en"`
+	IniciadaEn    time.Time    `json:"iniciada_en" db:"iniciada_en"`
+	TerminadaEn   *time.Time   `json:"terminada_en,omitempty" db:"terminada_en"`
+	Marcas        []MarcaRonda `json:"marcas" db:"-"`
+}
+
+// PuntoOmitido es el payload de WSRondaPuntoOmitido
+type PuntoOmitido struct {
+	RondaID       string    `json:"ronda_id"`
+	RutaNombre    string    `json:"ruta_nombre"`
+	UsuarioID     string    `json:"usuario_id"`
+	NombreUsuario string    `json:"nombre_usuario"`
+	Orden         int       `json:"orden"`
+	ZonaID        string    `json:"zona_id"`
+	EsperadoEn    time.Time `json:"esperado_en"`
+}
+
+// ResultadoCheckin es la respuesta del escaneo: el check-in y, si contó para
+// la ronda en curso, cómo quedó
+type ResultadoCheckin struct {
+	Checkin Checkin `json:"checkin"`
+	Ronda   *Ronda  `json:"ronda,omitempty"`
+}
+
+// ─── Ocupación ────────────────────────────────────────────────────────────────
+
+// NivelOcupacion: normal, alerta (pasó el umbral) o llena (llegó a la capacidad)
+type NivelOcupacion string
+
+const (
+	OcupacionNormal NivelOcupacion = "normal"
+	OcupacionAlerta NivelOcupacion = "alerta"
+	OcupacionLlena  NivelOcupacion = "llena"
+)
+
+// Ocupacion es el conteo en vivo de una zona; también es el payload de
+// WSOcupacionActualizada
+type Ocupacion struct {
+	ZonaID        string         `json:"zona_id"`
+	ZonaNombre    string         `json:"zona_nombre"`
+	Personas      int            `json:"personas"`
+	Capacidad     *int           `json:"capacidad,omitempty"`
+	Porcentaje    *float64       `json:"porcentaje,omitempty"`
+	Umbral        *int           `json:"umbral,omitempty"` // % que dispara la alerta
+	Nivel         NivelOcupacion `json:"nivel"`
+	IncidenciaID  *string        `json:"incidencia_id,omitempty"` // creada por este cruce de umbral
+	ActualizadaEn *time.Time     `json:"actualizada_en,omitempty"`
+}
+
+// ─── Plantillas de evento ─────────────────────────────────────────────────────
+
+// ParteEvento es lo que se puede copiar de una plantilla o de otro evento
+type ParteEvento string
+
+const (
+	ParteZonas    ParteEvento = "zonas"
+	ParteTareas   ParteEvento = "tareas"
+	PartePersonal ParteEvento = "personal"
+	ParteReglas   ParteEvento = "reglas"  // rutas de patrulla con sus tiempos y tolerancias
+	ParteCanales  ParteEvento = "canales" // grupos, canales de zona y palabras filtradas del chat
+)
+
+// PartesEvento en el orden en que se aplican: cada parte puede depender de las anteriores
+var PartesEvento = []ParteEvento{ParteZonas, PartePersonal, ParteTareas, ParteReglas, ParteCanales}
+
+func (p ParteEvento) EsValida() bool {
+	for _, v := range PartesEvento {
+		if p == v {
+			return true
+		}
+	}
+	return false
+}
+
+// ContenidoPlantilla es la preparación de un evento, sin nada de lo que pasó
+// durante él: ni incidencias, ni mensajes, ni el estado de las tareas
+type ContenidoPlantilla struct {
+	Zonas    []CrearZonaRequest `json:"zonas"` // padres antes que hijas
+	Tareas   []TareaPlantilla   `json:"tareas"`
+	Personal []MiembroPlantilla `json:"personal"`
+	Rutas    []RutaPlantilla    `json:"rutas"`
+	Canales  []CanalPlantilla   `json:"canales"`
+	Filtros  []FiltroPlantilla  `json:"filtros"`
+}
+
+func (c ContenidoPlantilla) Value() (driver.Value, error) {
+	b, err := json.Marshal(c)
+	return string(b), err
+}
+
+func (c *ContenidoPlantilla) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, c)
+	case string:
+		return json.Unmarshal([]byte(v), c)
+	}
+	return fmt.Errorf("contenido de plantilla: tipo %T no soportado", src)
+}
+
+// TareaPlantilla es una tarea que se repite en cada edición; nace pendiente
+type TareaPlantilla struct {
+	Titulo            string         `json:"titulo" db:"titulo"`
+	Descripcion       string         `json:"descripcion" db:"descripcion"`
+	Prioridad         PrioridadTarea `json:"prioridad" db:"prioridad"`
+	ZonaID            *string        `json:"zona_id,omitempty" db:"zona_id"`
+	AsignadaA         *string        `json:"asignada_a,omitempty" db:"asignada_a"`
+	RequiereEvidencia pq.StringArray `json:"requiere_evidencia" db:"requiere_evidencia"`
+}
+
+// MiembroPlantilla es alguien del equipo; nombre y rol son para mostrar, al
+// instanciar se vincula la cuenta por su ID
+type MiembroPlantilla struct {
+	UsuarioID     string `json:"usuario_id" db:"id"`
+	NombreUsuario string `json:"nombre_usuario" db:"nombre_usuario"`
+	Nombre        string `json:"nombre" db:"nombre"`
+	Rol           Rol    `json:"rol" db:"rol"`
+}
+
+type RutaPlantilla struct {
+	Nombre        string      `json:"nombre" db:"nombre"`
+	ToleranciaMin int         `json:"tolerancia_min" db:"tolerancia_min"`
+	Puntos        []PuntoRuta `json:"puntos" db:"-"`
+}
+
+// CanalPlantilla: conversación de grupo o de zona con sus miembros. La sala
+// general y los canales de rol se crean solos en cada evento.
+type CanalPlantilla struct {
+	Tipo     TipoConversacion `json:"tipo" db:"tipo"`
+	Nombre   string           `json:"nombre" db:"nombre"`
+	ZonaID   *string          `json:"zona_id,omitempty" db:"zona_id"`
+	Miembros pq.StringArray   `json:"miembros" db:"miembros"`
+}
+
+type FiltroPlantilla struct {
+	Palabra string       `json:"palabra" db:"palabra"`
+	Accion  AccionFiltro `json:"accion" db:"accion"`
+}
+
+// ResumenPlantilla cuenta lo que trae cada parte (para listar sin el contenido)
+type ResumenPlantilla struct {
+	Zonas    int `json:"zonas" db:"zonas"`
+	Tareas   int `json:"tareas" db:"tareas"`
+	Personal int `json:"personal" db:"personal"`
+	Rutas    int `json:"rutas" db:"rutas"`
+	Canales  int `json:"canales" db:"canales"`
+	Filtros  int `json:"filtros" db:"filtros"`
+}
+
+type PlantillaEvento struct {
+	ID             string              `json:"id" db:"id"`
+	Nombre         string              `json:"nombre" db:"nombre"`
+	Descripcion    string              `json:"descripcion" db:"descripcion"`
+	OrigenEventoID *string             `json:"origen_evento_id,omitempty" db:"origen_evento_id"`
+	CreadaPor      *string             `json:"creada_por,omitempty" db:"creada_por"`
+	CreadaEn       time.Time           `json:"creada_en" db:"creada_en"`
+	Resumen        ResumenPlantilla    `json:"resumen" db:"resumen"`
+	Contenido      *ContenidoPlantilla `json:"contenido,omitempty" db:"contenido"`
+}
+
+// AccionCambio: qué hace la instanciación con cada elemento
+type AccionCambio string
+
+const (
+	CambioCrear    AccionCambio = "crear"
+	CambioVincular AccionCambio = "vincular" // personal: la cuenta pasa al evento
+	CambioOmitir   AccionCambio = "omitir"
+)
+
+// CambioInstancia es una línea del diff. Clave identifica el elemento: ID de
+// zona, título de tarea, usuario, nombre de ruta o canal, palabra filtrada.
+type CambioInstancia struct {
+	Parte   ParteEvento  `json:"parte"`
+	Accion  AccionCambio `json:"accion"`
+	Clave   string       `json:"clave"`
+	Detalle string       `json:"detalle,omitempty"` // por qué se omite o qué se ajustó
+}
+
+type ConteoCambios struct {
+	Aplicados int `json:"aplicados"`
+	Omitidos  int `json:"omitidos"`
+}
+
+// InstanciaEvento es el diff de POST /eventos/instanciar. En vista previa
+// nada se guarda y el evento nuevo todavía no tiene ID.
+type InstanciaEvento struct {
+	Evento      *Evento                        `json:"evento"`
+	VistaPrevia bool                           `json:"vista_previa"`
+	Resumen     map[ParteEvento]*ConteoCambios `json:"resumen"`
+	Cambios     []CambioInstancia              `json:"cambios"`
+}
+
+// Anotar suma un cambio al diff
+func (i *InstanciaEvento) Anotar(parte ParteEvento, accion AccionCambio, clave, detalle string) {
+	if i.Resumen == nil {
+		i.Resumen = map[ParteEvento]*ConteoCambios{}
+	}
+	conteo := i.Resumen[parte]
+	if conteo == nil {
+		conteo = &ConteoCambios{}
+		i.Resumen[parte] = conteo
+	}
+	if accion == CambioOmitir {
+		conteo.Omitidos++
+	} else {
+		conteo.Aplicados++
+	}
+	i.Cambios = append(i.Cambios, CambioInstancia{Parte: parte, Accion: accion, Clave: clave, Detalle: detalle})
+}
+
+// ─── Cierre de evento ─────────────────────────────────────────────────────────
+
+// AccionPendientes: qué pasa con las incidencias y tareas abiertas al cerrar
+type AccionPendientes string
+
+const (
+	PendientesResolver  AccionPendientes = "resolver"  // incidencias resueltas, tareas completadas
+	PendientesTrasladar AccionPendientes = "trasladar" // pasan al evento destino
+)
+
+func (a AccionPendientes) EsValida() bool {
+	return a == PendientesResolver || a == PendientesTrasladar
+}
+
+// AccionPersonal: qué pasa con las cuentas vinculadas al evento
+type AccionPersonal string
+
+const (
+	PersonalDesvincular AccionPersonal = "desvincular" // siguen activas, sin evento
+	PersonalDesactivar  AccionPersonal = "desactivar"  // además no pueden iniciar sesión
+)
+
+func (a AccionPersonal) EsValida() bool {
+	return a == PersonalDesvincular || a == PersonalDesactivar
+}
+
+// OpcionesCierre es el cuerpo de POST /eventos/:id/cierre; lo que no viene
+// toma el valor por defecto (resolver, resolver, desvincular)
+type OpcionesCierre struct {
+	Incidencias     AccionPendientes `json:"incidencias"`
+	Tareas          AccionPendientes `json:"tareas"`
+	Personal        AccionPersonal   `json:"personal"`
+	DestinoEventoID *string          `json:"destino_evento_id,omitempty"` // para trasladar
+	Nota            string           `json:"nota,omitempty"`
+}
+
+// ConDefectos completa lo que no vino
+func (o OpcionesCierre) ConDefectos() OpcionesCierre {
+	if o.Incidencias == "" {
+		o.Incidencias = PendientesResolver
+	}
+	if o.Tareas == "" {
+		o.Tareas = PendientesResolver
+	}
+	if o.Personal == "" {
+		o.Personal = PersonalDesvincular
+	}
+	return o
+}
+
+// Traslada: alguna parte pasa al evento destino
+func (o OpcionesCierre) Traslada() bool {
+	return o.Incidencias == PendientesTrasladar || o.Tareas == PendientesTrasladar
+}
+
+func (o OpcionesCierre) Value() (driver.Value, error) {
+	b, err := json.Marshal(o)
+	return string(b), err
+}
+
+func (o *OpcionesCierre) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, o)
+	case string:
+		return json.Unmarshal([]byte(v), o)
+	}
+	return fmt.Errorf("opciones de cierre: tipo %T no soportado", src)
+}
+
+type EstadoCierre string
+
+const (
+	CierrePendiente  EstadoCierre = "pendiente"
+	CierreRevocando  EstadoCierre = "revocando" // ejecutado; faltan revocar las sesiones
+	CierreCompletado EstadoCierre = "completado"
+	CierreFallido    EstadoCierre = "fallido"
+)
+
+type CierreEvento struct {
+	ID            string           `json:"id" db:"id"`
+	EventoID      string           `json:"evento_id" db:"evento_id"`
+	Estado        EstadoCierre     `json:"estado" db:"estado"`
+	Opciones      OpcionesCierre   `json:"opciones" db:"opciones"`
+	Motivo        MotivoTransicion `json:"motivo" db:"motivo"`
+	SolicitadoPor *string          `json:"solicitado_por,omitempty" db:"solicitado_por"`
+	Intentos      int              `json:"intentos" db:"intentos"`
+	Error         *string          `json:"error,omitempty" db:"error"`
+	Reporte       *ReporteFinal    `json:"reporte,omitempty" db:"reporte"`
+	CreadoEn      time.Time        `json:"creado_en" db:"creado_en"`
+	TerminadoEn   *time.Time       `json:"terminado_en,omitempty" db:"terminado_en"`
+}
+
+// PendientesCierre es lo que GET /eventos/:id/cierre muestra antes de cerrar
+type PendientesCierre struct {
+	EventoID         string              `json:"evento_id"`
+	Incidencias      []ResumenIncidencia `json:"incidencias"`
+	Tareas           []ResumenTarea      `json:"tareas"`
+	RondasEnCurso    int                 `json:"rondas_en_curso"`
+	TurnosAbiertos   int                 `json:"turnos_abiertos"`
+	AnunciosAbiertos int                 `json:"anuncios_abiertos"`
+	Personal         int                 `json:"personal"`
+	// Con destino_evento_id: zonas de lo abierto que el destino no tiene (se copian al trasladar)
+	ZonasACopiar []string      `json:"zonas_a_copiar,omitempty"`
+	UltimoCierre *CierreEvento `json:"ultimo_cierre,omitempty"`
+}
+
+// ReporteFinal es el balance que deja el cierre; no cambia si el evento se reabre
+type ReporteFinal struct {
+	EventoID    string     `json:"evento_id"`
+	Nombre      string     `json:"nombre"`
+	IniciadoEn  *time.Time `json:"iniciado_en,omitempty"`
+	TerminadoEn time.Time  `json:"terminado_en"`
+	DuracionMin int        `json:"duracion_min"`
+
+	Incidencias ReporteIncidencias `json:"incidencias"`
+	Tareas      ReporteTareas      `json:"tareas"`
+	Rondas      ReporteRondas      `json:"rondas"`
+	Personal    ReportePersonal    `json:"personal"`
+	SOS         int                `json:"sos"`
+	Checkins    int                `json:"checkins"`
+	Mensajes    int                `json:"mensajes"`
+	Anuncios    int                `json:"anuncios"`
+	// Al trasladar: evento destino y zonas que hubo que copiarle
+	DestinoEventoID *string `json:"destino_evento_id,omitempty"`
+	ZonasCopiadas   int     `json:"zonas_copiadas"`
+	Nota            string  `json:"nota,omitempty"`
+}
+
+func (r ReporteFinal) Value() (driver.Value, error) {
+	b, err := json.Marshal(r)
+	return string(b), err
+}
+
+func (r *ReporteFinal) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, r)
+	case string:
+		return json.Unmarshal([]byte(v), r)
+	}
+	return fmt.Errorf("reporte final: tipo %T no soportado", src)
+}
+
+type ReporteIncidencias struct {
+	Total             int            `json:"total" db:"total"`
+	PorTipo           map[string]int `json:"por_tipo" db:"-"`
+	Resueltas         int            `json:"resueltas" db:"resueltas"`                     // antes del cierre
+	ResueltasAlCierre int            `json:"resueltas_al_cierre" db:"resueltas_al_cierre"` // por el cierre
+	Trasladadas       int            `json:"trasladadas" db:"trasladadas"`
+	// Minutos promedio de creada a resuelta, sin contar las resueltas por el cierre
+	ResolucionPromedioMin *float64 `json:"resolucion_promedio_min,omitempty" db:"resolucion_promedio_min"`
+}
+
+type ReporteTareas struct {
+	Total              int `json:"total" db:"total"`
+	Completadas        int `json:"completadas" db:"completadas"`
+	CanceladasAlCierre int `json:"canceladas_al_cierre" db:"canceladas_al_cierre"` // quedaron sin hacer
+	Trasladadas        int `json:"trasladadas" db:"trasladadas"`
+}
+
+type ReporteRondas struct {
+	Total       int `json:"total" db:"total"`
+	Completadas int `json:"completadas" db:"completadas"`
+	Incompletas int `json:"incompletas" db:"incompletas"`
+	Canceladas  int `json:"canceladas" db:"canceladas"` // incluye las cortadas por el cierre
+}
+
+type ReportePersonal struct {
+	Total         int            `json:"total"`
+	PorRol        map[string]int `json:"por_rol"`
+	Desvinculados int            `json:"desvinculados"`
+	Desactivados  int            `json:"desactivados"`
+}
+
+// ─── Reportes de evento ───────────────────────────────────────────────────────
+
+type FormatoReporte string
+
+const (
+	FormatoPDF FormatoReporte = "pdf"
+	FormatoCSV FormatoReporte = "csv" // ZIP con un CSV por tabla
+)
+
+func (f FormatoReporte) EsValido() bool {
+	return f == FormatoPDF || f == FormatoCSV
+}
+
+type EstadoReporte string
+
+const (
+	ReportePendiente EstadoReporte = "pendiente"
+	ReporteGenerando EstadoReporte = "generando"
+	ReporteListo     EstadoReporte = "listo"
+	ReporteFallido   EstadoReporte = "fallido"
+)
+
+// ReporteEvento es el trabajo de generación; el archivo se baja aparte
+type ReporteEvento struct {
+	ID            string         `json:"id" db:"id"`
+	EventoID      string         `json:"evento_id" db:"evento_id"`
+	Formato       FormatoReporte `json:"formato" db:"formato"`
+	Estado        EstadoReporte  `json:"estado" db:"estado"`
+	SolicitadoPor *string        `json:"solicitado_por,omitempty" db:"solicitado_por"`
+	Intentos      int            `json:"intentos" db:"intentos"`
+	Error         *string        `json:"error,omitempty" db:"error"`
+	NombreArchivo *string        `json:"nombre_archivo,omitempty" db:"nombre_archivo"`
+	Tamano        *int           `json:"tamano,omitempty" db:"tamano"` // bytes
+	CreadoEn      time.Time      `json:"creado_en" db:"creado_en"`
+	IniciadoEn    *time.Time     `json:"iniciado_en,omitempty" db:"iniciado_en"`
+	TerminadoEn   *time.Time     `json:"terminado_en,omitempty" db:"terminado_en"`
+}
+
+type SolicitarReporteRequest struct {
+	Formato FormatoReporte `json:"formato" binding:"required"`
+}
+
+// DatosReporte es lo que se vuelca al PDF o a los CSV
+type DatosReporte struct {
+	Evento      Evento
+	GeneradoEn  time.Time
+	Incidencias FilaIncidencias // totales del evento
+	PorTipo     []FilaIncidencias
+	PorZona     []FilaIncidencias
+	TareasRol   []FilaTareasRol
+	Personal    []FilaPersonal
+	Evidencias  []FilaEvidencias
+	ChatPorHora []PuntoChat
+}
+
+// FilaIncidencias agrupa por tipo o por zona. Los tiempos salen del
+// historial: hasta el primer en_atencion y hasta el primer resuelta, sin
+// contar lo resuelto por el cierre del evento.
+type FilaIncidencias struct {
+	Clave                 string   `db:"clave"`
+	Nombre                *string  `db:"nombre"` // nombre de la zona
+	Total                 int      `db:"total"`
+	Resueltas             int      `db:"resueltas"`
+	AtencionPromedioMin   *float64 `db:"atencion_promedio_min"`
+	ResolucionPromedioMin *float64 `db:"resolucion_promedio_min"`
+}
+
+// FilaTareasRol: tareas por rol de quien las tiene asignadas ("sin_asignar")
+type FilaTareasRol struct {
+	Rol         string `db:"rol"`
+	Total       int    `db:"total"`
+	Completadas int    `db:"completadas"` // sin las completadas por el cierre
+}
+
+func (f FilaTareasRol) TasaPct() float64 {
+	if f.Total == 0 {
+		return 0
+	}
+	return float64(f.Completadas) * 100 / float64(f.Total)
+}
+
+// FilaEvidencias: las pruebas que dejó una tarea, por tipo. Entran las que
+// pedían evidencia y las que tienen alguna.
+type FilaEvidencias struct {
+	TareaID    string         `db:"tarea_id"`
+	Titulo     string         `db:"titulo"`
+	ZonaID     *string        `db:"zona_id"`
+	Estado     string         `db:"estado"`
+	Requeridas pq.StringArray `db:"requeridas"`
+	Fotos      int            `db:"fotos"`
+	Notas      int            `db:"notas"`
+	QRs        int            `db:"qrs"`
+	GPS        int            `db:"gps"`
+}
+
+type FilaPersonal struct {
+	UsuarioID            string  `db:"id"`
+	Nombre               string  `db:"nombre"`
+	Rol                  Rol     `db:"rol"`
+	IncidenciasAsignadas int     `db:"incidencias_asignadas"`
+	IncidenciasResueltas int     `db:"incidencias_resueltas"` // cambios a resuelta hechos por la persona
+	TareasAsignadas      int     `db:"tareas_asignadas"`
+	TareasCompletadas    int     `db:"tareas_completadas"`
+	Mensajes             int     `db:"mensajes"`
+	Checkins             int     `db:"checkins"`
+	HorasTurno           float64 `db:"horas_turno"`
+}
+
+type PuntoChat struct {
+	Hora     time.Time `db:"hora"`
+	Mensajes int       `db:"mensajes"`
+}
+
+// ─── Métricas en vivo ─────────────────────────────────────────────────────────
+
+// MetricasEvento es el tablero de operación: GET /eventos/:id/metricas y, por
+// secciones, el payload de WSMetricasActualizadas
+type MetricasEvento struct {
+	EventoID       string              `json:"evento_id"`
+	Incidencias    MetricasIncidencias `json:"incidencias"`
+	Tareas         MetricasTareas      `json:"tareas"`
+	Tiempos        []VentanaTiempos    `json:"tiempos"`
+	Personal       MetricasPersonal    `json:"personal"`
+	ZonasCalientes []ZonaCaliente      `json:"zonas_calientes"`
+	Carga          []CargaTrabajador   `json:"carga"`
+	GeneradoEn     time.Time           `json:"generado_en"`
+}
+
+// Secciones del tablero, con el nombre que llevan en el JSON
+const (
+	SeccionIncidencias    = "incidencias"
+	SeccionTareas         = "tareas"
+	SeccionTiempos        = "tiempos"
+	SeccionPersonal       = "personal"
+	SeccionZonasCalientes = "zonas_calientes"
+	SeccionCarga          = "carga"
+)
+
+// MetricasIncidencias: las abiertas (pendiente o en_atencion)
+type MetricasIncidencias struct {
+	Abiertas  int            `json:"abiertas"`
+	PorEstado map[string]int `json:"por_estado"`
+	PorTipo   map[string]int `json:"por_tipo"`
+	PorZona   []ConteoZona   `json:"por_zona"`
+}
+
+type ConteoZona struct {
+	ZonaID     string  `json:"zona_id" db:"zona_id"`
+	ZonaNombre *string `json:"zona_nombre,omitempty" db:"zona_nombre"`
+	Total      int     `json:"total" db:"total"`
+}
+
+// MetricasTareas: las que no están completadas
+type MetricasTareas struct {
+	Pendientes   int            `json:"pendientes"`
+	PorPrioridad map[string]int `json:"por_prioridad"`
+	PorEstado    map[string]int `json:"por_estado"`
+	SinAsignar   int            `json:"sin_asignar"`
+}
+
+// VentanaTiempos: promedios de lo atendido y lo resuelto en los últimos
+// Minutos, medidos desde que se creó la incidencia
+type VentanaTiempos struct {
+	Minutos               int      `json:"minutos" db:"minutos"`
+	Atendidas             int      `json:"atendidas" db:"atendidas"`
+	AtencionPromedioMin   *float64 `json:"atencion_promedio_min" db:"atencion_promedio_min"`
+	Resueltas             int      `json:"resueltas" db:"resueltas"`
+	ResolucionPromedioMin *float64 `json:"resolucion_promedio_min" db:"resolucion_promedio_min"`
+}
+
+// MetricasPersonal: en turno (turno abierto) y con ubicación reciente, por rol
+type MetricasPersonal struct {
+	EnTurno            int            `json:"en_turno"`
+	EnTurnoPorRol      map[string]int `json:"en_turno_por_rol"`
+	ConUbicacion       int            `json:"con_ubicacion"`
+	ConUbicacionPorRol map[string]int `json:"con_ubicacion_por_rol"`
+}
+
+// ZonaCaliente: zonas con más incidencias abiertas y recientes; el aforo
+// desempata y suma las zonas en alerta o llenas
+type ZonaCaliente struct {
+	ZonaID     string          `json:"zona_id" db:"zona_id"`
+	ZonaNombre *string         `json:"zona_nombre,omitempty" db:"zona_nombre"`
+	Abiertas   int             `json:"abiertas" db:"abiertas"`
+	Recientes  int             `json:"recientes" db:"recientes"` // creadas en la última media hora
+	Porcentaje *float64        `json:"porcentaje_aforo,omitempty" db:"-"`
+	Nivel      *NivelOcupacion `json:"nivel_aforo,omitempty" db:"-"`
+}
+
+// MetricasCambio es el payload de WSMetricasActualizadas: solo las secciones
+// que cambiaron desde el último envío, para mezclar sobre lo ya recibido
+type MetricasCambio struct {
+	EventoID   string                     `json:"evento_id"`
+	Secciones  map[string]json.RawMessage `json:"secciones"`
+	GeneradoEn time.Time                  `json:"generado_en"`
+}
+
+// ─── Carga y desempeño del personal ───────────────────────────────────────────
+
+// NivelCarga según lo que alguien tiene abierto: libre sin nada, sobrecargado
+// desde CARGA_UMBRAL_SOBRECARGA
+type NivelCarga string
+
+const (
+	CargaLibre        NivelCarga = "libre"
+	CargaOcupado      NivelCarga = "ocupado"
+	CargaSobrecargado NivelCarga = "sobrecargado"
+)
+
+// CargaTrabajador: incidencias y tareas que alguien del personal de campo
+// tiene asignadas y sin cerrar ahora mismo
+type CargaTrabajador struct {
+	UsuarioID           string     `json:"usuario_id" db:"id"`
+	Nombre              string     `json:"nombre" db:"nombre"`
+	Rol                 Rol        `json:"rol" db:"rol"`
+	EnTurno             bool       `json:"en_turno" db:"en_turno"`
+	IncidenciasAbiertas int        `json:"incidencias_abiertas" db:"incidencias_abiertas"`
+	TareasAbiertas      int        `json:"tareas_abiertas" db:"tareas_abiertas"`
+	TareasAlta          int        `json:"tareas_alta" db:"tareas_alta"` // de las abiertas, las de prioridad alta
+	Abiertas            int        `json:"abiertas" db:"-"`
+	Nivel               NivelCarga `json:"nivel" db:"-"`
+	// Último en_atencion o en_progreso que hizo en el evento
+	UltimaToma *time.Time `json:"ultima_toma,omitempty" db:"ultima_toma"`
+}
+
+// CargaEvento: GET /eventos/:id/carga. Personal va del menos al más cargado,
+// primero quienes están en turno.
+type CargaEvento struct {
+	EventoID      string            `json:"evento_id"`
+	Umbral        int               `json:"umbral_sobrecarga"`
+	Libres        int               `json:"libres"`
+	Ocupados      int               `json:"ocupados"`
+	Sobrecargados int               `json:"sobrecargados"`
+	Personal      []CargaTrabajador `json:"personal"`
+	GeneradoEn    time.Time         `json:"generado_en"`
+}
+
+// DesempenoTrabajador: lo que hizo cada uno con lo que tuvo asignado. Los
+// tiempos salen del historial; lo cerrado por el cierre del evento no cuenta.
+type DesempenoTrabajador struct {
+	UsuarioID            string `json:"usuario_id" db:"id"`
+	Nombre               string `json:"nombre" db:"nombre"`
+	Rol                  Rol    `json:"rol" db:"rol"`
+	IncidenciasAbiertas  int    `json:"incidencias_abiertas" db:"incidencias_abiertas"`
+	TareasAbiertas       int    `json:"tareas_abiertas" db:"tareas_abiertas"`
+	IncidenciasResueltas int    `json:"incidencias_resueltas" db:"incidencias_resueltas"`
+	TareasCompletadas    int    `json:"tareas_completadas" db:"tareas_completadas"`
+	// De la creación al primer en_atencion / en_progreso
+	AceptacionPromedioMin *float64 `json:"aceptacion_promedio_min" db:"aceptacion_promedio_min"`
+	// De ahí a resuelta / completada
+	ResolucionPromedioMin *float64 `json:"resolucion_promedio_min" db:"resolucion_promedio_min"`
+	HorasTurno            float64  `json:"horas_turno" db:"horas_turno"`
+	// Horas con al menos algo en curso, sin contar dos veces lo simultáneo
+	HorasActivo float64 `json:"horas_activo" db:"horas_activo"`
+	// Horas activo sobre horas de turno; null sin turnos
+	OcupacionPct *float64 `json:"ocupacion_pct" db:"-"`
+}
+
+// ReporteDesempeno: GET /eventos/:id/desempeno
+type ReporteDesempeno struct {
+	EventoID   string                `json:"evento_id"`
+	Personal   []DesempenoTrabajador `json:"personal"`
+	GeneradoEn time.Time             `json:"generado_en"`
+}
+
+// ─── Analítica de incidencias ─────────────────────────────────────────────────
+
+// FiltroAnalitica acota las consultas de analítica. Sin eventos cuenta todos;
+// desde y hasta se aplican con resolución de una hora.
+type FiltroAnalitica struct {
+	Eventos     []string
+	Desde       *time.Time
+	Hasta       *time.Time
+	Tipo        string
+	ZonaHoraria string // IANA; agrupa horas del día y días
+}
+
+// IntervaloSerie: ancho de cada punto de una serie temporal
+type IntervaloSerie string
+
+const (
+	IntervaloHora IntervaloSerie = "hora"
+	IntervaloDia  IntervaloSerie = "dia"
+)
+
+func (i IntervaloSerie) EsValido() bool { return i == IntervaloHora || i == IntervaloDia }
+
+// ConteoZonaHora es una celda del mapa de calor: incidencias de la zona a
+// esa hora del día (0-23, en la zona horaria pedida)
+type ConteoZonaHora struct {
+	ZonaID string `db:"zona_id"`
+	Hora   int    `db:"hora"`
+	Total  int    `db:"total"`
+}
+
+// ZonaPorHora: una fila del mapa de calor. Las zonas con el mismo id en
+// distintos eventos (clonadas o de plantilla) se cuentan juntas.
+type ZonaPorHora struct {
+	ZonaID     string  `json:"zona_id"`
+	ZonaNombre *string `json:"zona_nombre,omitempty"`
+	Total      int     `json:"total"`
+	PorHora    [24]int `json:"por_hora"`
+	HoraPico   int     `json:"hora_pico"`
+}
+
+// AnaliticaZonasHoras: GET /analitica/incidencias/zonas-horas
+type AnaliticaZonasHoras struct {
+	ZonaHoraria string        `json:"zona_horaria"`
+	Total       int           `json:"total"`
+	PorHora     [24]int       `json:"por_hora"`
+	Zonas       []ZonaPorHora `json:"zonas"`
+}
+
+// ConteoTipo: incidencias de un tipo en la hora UTC que empieza en Inicio
+type ConteoTipo struct {
+	Tipo   string    `db:"tipo"`
+	Inicio time.Time `db:"inicio"`
+	Total  int       `db:"total"`
+}
+
+type PuntoSerie struct {
+	Inicio time.Time `json:"inicio"`
+	Total  int       `json:"total"`
+}
+
+// SerieTipo: solo los intervalos con incidencias
+type SerieTipo struct {
+	Tipo   string       `json:"tipo"`
+	Total  int          `json:"total"`
+	Puntos []PuntoSerie `json:"puntos"`
+}
+
+// AnaliticaTipos: GET /analitica/incidencias/tipos
+type AnaliticaTipos struct {
+	Intervalo   IntervaloSerie `json:"intervalo"`
+	ZonaHoraria string         `json:"zona_horaria"`
+	Series      []SerieTipo    `json:"series"`
+}
+
+// ConteoDia: incidencias de un tipo en una hora UTC del evento; el número de
+// día (el 1 es el del inicio) se saca en la zona horaria pedida
+type ConteoDia struct {
+	EventoID string    `db:"evento_id"`
+	Nombre   string    `db:"nombre"`
+	InicioEn time.Time `db:"inicio_en"`
+	Hora     time.Time `db:"hora"`
+	Tipo     string    `db:"tipo"`
+	Total    int       `db:"total"`
+}
+
+// PuntoDia: en el agregado, Eventos dice cuántos eventos tuvieron
+// incidencias ese día
+type PuntoDia struct {
+	Dia     int            `json:"dia"`
+	Total   int            `json:"total"`
+	PorTipo map[string]int `json:"por_tipo"`
+	Eventos int            `json:"eventos,omitempty"`
+}
+
+type DiasEvento struct {
+	EventoID string     `json:"evento_id"`
+	Nombre   string     `json:"nombre"`
+	InicioEn time.Time  `json:"inicio_en"` // iniciado_en, o el programado si no arrancó
+	Total    int        `json:"total"`
+	Dias     []PuntoDia `json:"dias"`
+}
+
+// AnaliticaDias: GET /analitica/incidencias/dias
+type AnaliticaDias struct {
+	ZonaHoraria string       `json:"zona_horaria"`
+	Dias        []PuntoDia   `json:"dias"`
+	PorEvento   []DiasEvento `json:"por_evento"`
+}
+
+// ─── Dispositivo IoT ──────────────────────────────────────────────────────────
+
+type OperadorRegla string
+
+const (
+	OpIgual      OperadorRegla = "="
+	OpDistinto   OperadorRegla = "!="
+	OpMayor      OperadorRegla = ">"
+	OpMayorIgual OperadorRegla = ">="
+	OpMenor      OperadorRegla = "<"
+	OpMenorIgual OperadorRegla = "<="
+	OpExiste     OperadorRegla = "existe" // el campo viene en el payload, con cualquier valor
+)
+
+func (o OperadorRegla) EsValido() bool {
+	switch o {
+	case OpIgual, OpDistinto, OpMayor, OpMayorIgual, OpMenor, OpMenorIgual, OpExiste:
+		return true
+	}
+	return false
+}
+
+// ReglaDispositivo compara un campo del payload (ruta con puntos, ej:
+// "alarma.estado") contra Valor. La primera regla que se cumple decide el
+// tipo de incidencia; ZonaID la fija aunque el dispositivo tenga otra.
+type ReglaDispositivo struct {
+	Campo       string         `json:"campo"`
+	Operador    OperadorRegla  `json:"operador"`
+	Valor       interface{}    `json:"valor,omitempty"`
+	Tipo        TipoIncidencia `json:"tipo"`
+	ZonaID      *string        `json:"zona_id,omitempty"`
+	Descripcion string         `json:"descripcion,omitempty"` // admite {dispositivo}, {campo} y {valor}
+}
+
+// ReglasDispositivo se guarda como JSONB
+type ReglasDispositivo []ReglaDispositivo
+
+func (r ReglasDispositivo) Value() (driver.Value, error) {
+	if r == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal(r)
+	return string(b), err
+}
+
+func (r *ReglasDispositivo) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, r)
+	case string:
+		return json.Unmarshal([]byte(v), r)
+	case nil:
+		*r = nil
+		return nil
+	}
+	return fmt.Errorf("reglas: tipo %T no soportado", src)
+}
+
+type Dispositivo struct {
+	ID              string            `json:"id" db:"id"`
+	EventoID        string            `json:"evento_id" db:"evento_id"`
+	Nombre          string            `json:"nombre" db:"nombre"`
+	ZonaID          string            `json:"zona_id" db:"zona_id"`
+	CampoZona       *string           `json:"campo_zona,omitempty" db:"campo_zona"`
+	TemaMQTT        *string           `json:"tema_mqtt,omitempty" db:"tema_mqtt"` // si publica por MQTT
+	Reglas          ReglasDispositivo `json:"reglas" db:"reglas"`
+	VentanaSegundos int               `json:"ventana_segundos" db:"ventana_segundos"`
+	Token           string            `json:"token,omitempty" db:"-"` // solo al crear o rotar
+	Activo          bool              `json:"activo" db:"activo"`
+	CreadoPor       string            `json:"creado_por" db:"creado_por"`
+	CreadoEn        time.Time         `json:"creado_en" db:"creado_en"`
+	UltimoDatoEn    *time.Time        `json:"ultimo_dato_en,omitempty" db:"ultimo_dato_en"`
+	UltimoPayload   json.RawMessage   `json:"ultimo_payload,omitempty" db:"ultimo_payload"`
+	Version         int               `json:"version" db:"version"` // no sube con cada lectura
+}
+
+// ─── Eventos WebSocket ────────────────────────────────────────────────────────
+
+type TipoEventoWS string
+
+const (
+	// Incidencias
+	WSIncidenciaNueva       TipoEventoWS = "incidencia_nueva"
+	WSIncidenciaActualizada TipoEventoWS = "incidencia_actualizada"
+	WSIncidenciaConflicto   TipoEventoWS = "incidencia_conflicto" // solo al usuario que perdió la carrera
+	// Tareas
+	WSTareaNueva       TipoEventoWS = "tarea_nueva"
+	WSTareaActualizada TipoEventoWS = "tarea_actualizada"
+	WSTareaConflicto   TipoEventoWS = "tarea_conflicto"
+	// Chat
+	WSMensajeNuevo      TipoEventoWS = "mensaje_nuevo"
+	WSMensajeLeido      TipoEventoWS = "mensaje_leido"
+	WSEscribiendo       TipoEventoWS = "escribiendo" // también es comando cliente → servidor
+	WSMensajeEditado    TipoEventoWS = "mensaje_editado"
+	WSMensajeEliminado  TipoEventoWS = "mensaje_eliminado"
+	WSUsuarioSilenciado TipoEventoWS = "usuario_silenciado"
+	WSMencion           TipoEventoWS = "mencion" // solo a los usuarios mencionados
+	WSUsuarioReactivado TipoEventoWS = "usuario_reactivado"
+	WSFiltroCreado      TipoEventoWS = "filtro_creado"
+	WSFiltroEliminado   TipoEventoWS = "filtro_eliminado"
+	// Anuncios
+	WSAnuncioNuevo        TipoEventoWS = "anuncio_nuevo"        // a los destinatarios
+	WSAnuncioRecordatorio TipoEventoWS = "anuncio_recordatorio" // reenvío a quien no confirmó
+	WSAnuncioConfirmado   TipoEventoWS = "anuncio_confirmado"   // a quien lo envió y a los admins
+	WSAnuncioCerrado      TipoEventoWS = "anuncio_cerrado"
+	// SOS (a quien lo activó y a admins, supervisores, guardias y médicos)
+	WSSOS           TipoEventoWS = "sos"           // también es comando cliente → servidor
+	WSSOSUbicacion  TipoEventoWS = "sos_ubicacion" // también es comando cliente → servidor
+	WSSOSFinalizado TipoEventoWS = "sos_finalizado"
+	// Ubicación del staff (a admins y supervisores; la retirada también al usuario)
+	WSUbicacion            TipoEventoWS = "ubicacion" // solo comando cliente → servidor
+	WSUbicacionActualizada TipoEventoWS = "ubicacion_actualizada"
+	WSUbicacionRetirada    TipoEventoWS = "ubicacion_retirada"
+	// Patrullas (a admins y supervisores; la ronda también a su guardia)
+	WSCheckin           TipoEventoWS = "checkin"
+	WSRondaActualizada  TipoEventoWS = "ronda_actualizada"
+	WSRondaPuntoOmitido TipoEventoWS = "ronda_punto_omitido"
+	// Aforo de zonas
+	WSOcupacionActualizada TipoEventoWS = "ocupacion_actualizada"
+	// Tablero de operación (a admins y supervisores)
+	WSMetricasActualizadas TipoEventoWS = "metricas_actualizadas"
+	// Ciclo de vida del evento (a todo el evento; payload: el evento)
+	WSEventoProgramado    TipoEventoWS = "evento_programado"
+	WSEventoDesprogramado TipoEventoWS = "evento_desprogramado"
+	WSEventoIniciado      TipoEventoWS = "evento_iniciado"
+	WSEventoPausado       TipoEventoWS = "evento_pausado"
+	WSEventoReanudado     TipoEventoWS = "evento_reanudado"
+	WSEventoTerminado     TipoEventoWS = "evento_terminado"
+	WSEventoReabierto     TipoEventoWS = "evento_reabierto"
+	// Sistema
+	WSPing           TipoEventoWS = "ping"
+	WSSesionRevocada TipoEventoWS = "sesion_revocada" // al usuario: su sesión terminó, hay que volver a entrar
+)
+
+// TiposWebhook son los eventos que se publican a todo el evento (Hub.Publicar)
+// y por eso pueden reenviarse a un sistema externo. Los dirigidos a usuarios
+// puntuales (menciones, anuncios, conflictos) no salen de EventPulse.
+var TiposWebhook = []TipoEventoWS{
+	WSIncidenciaNueva, WSIncidenciaActualizada,
+	WSTareaNueva, WSTareaActualizada,
+	WSMensajeNuevo, WSMensajeEditado, WSMensajeEliminado,
+	WSUsuarioSilenciado, WSUsuarioReactivado, WSFiltroCreado, WSFiltroEliminado,
+	WSAnuncioCerrado,
+	WSEventoProgramado, WSEventoDesprogramado, WSEventoIniciado, WSEventoPausado, WSEventoReanudado, WSEventoTerminado, WSEventoReabierto,
+	WSOcupacionActualizada,
+}
+
+func (t TipoEventoWS) AdmiteWebhook() bool {
+	for _, v := range TiposWebhook {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
+type EventoWS struct {
+	Tipo     TipoEventoWS `json:"tipo"`
+	Payload  interface{}  `json:"payload"`
+	EventoID string       `json:"evento_id"`
+}
+
+// ComandoWS es lo que el cliente envía por el socket (ej: indicador de escritura).
+// El payload se decodifica según el tipo en el manejador registrado en el Hub.
+type ComandoWS struct {
+	Tipo    TipoEventoWS    `json:"tipo"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// ConflictoAsignacion es el payload de WSIncidenciaConflicto / WSTareaConflicto
+// y el cuerpo del 409 cuando alguien intenta tomar algo que ya tiene dueño.
+type ConflictoAsignacion struct {
+	IncidenciaID   string  `json:"incidencia_id,omitempty"`
+	TareaID        string  `json:"tarea_id,omitempty"`
+	Mensaje        string  `json:"mensaje"`
+	AsignadaA      *string `json:"asignada_a,omitempty"`
+	NombreAsignado *string `json:"atendida_por,omitempty"`
+}
+
+// ─── DTOs Request ─────────────────────────────────────────────────────────────
+
+type LoginRequest struct {
+	NombreUsuario string `json:"nombre_usuario" binding:"required"`
+	Password_hash string `json:"password_hash" binding:"required,min=4"`
+}
+
+type LoginResponse struct {
+	Token   string  `json:"token"`
+	Usuario Usuario `json:"usuario"`
+}
+
+// PerfilResponse es /auth/me: el usuario más su total de mensajes sin leer
+type PerfilResponse struct {
+	Usuario
+	NoLeidos int `json:"no_leidos"`
+}
+
+// CrearEventoRequest: sin inicio_programado el evento queda en borrador
+type CrearEventoRequest struct {
+	Nombre           string     `json:"nombre" binding:"required,min=3"`
+	Descripcion      string     `json:"descripcion"`
+	InicioProgramado *time.Time `json:"inicio_programado,omitempty"`
+	FinProgramado    *time.Time `json:"fin_programado,omitempty"`
+}
+
+// EditarEventoRequest: quitar admite inicio_programado y fin_programado
+type EditarEventoRequest struct {
+	Nombre           *string    `json:"nombre,omitempty"`
+	Descripcion      *string    `json:"descripcion,omitempty"`
+	InicioProgramado *time.Time `json:"inicio_programado,omitempty"`
+	FinProgramado    *time.Time `json:"fin_programado,omitempty"`
+	Quitar           []string   `json:"quitar,omitempty"`
+}
+
+// CambiarEstadoEventoRequest pide una transición: programado, borrador,
+// activo (iniciar, reanudar o reabrir), pausado o terminado
+type CambiarEstadoEventoRequest struct {
+	Estado EstadoEvento `json:"estado" binding:"required"`
+}
+
+type TerminarEventoRequest struct {
+	EventoID string `json:"evento_id" binding:"required"`
+}
+
+type CrearZonaRequest struct {
+	ID       string   `json:"id" binding:"required"` // manual ej: "bano-norte"
+	Nombre   string   `json:"nombre" binding:"required"`
+	Tipo     TipoZona `json:"tipo,omitempty"` // por defecto area
+	PadreID  *string  `json:"padre_id,omitempty"`
+	Latitud  *float64 `json:"latitud,omitempty"`
+	Longitud *float64 `json:"longitud,omitempty"`
+	RadioM   *float64 `json:"radio_m,omitempty"`
+	Poligono Poligono `json:"poligono,omitempty"`
+	Piso     *int     `json:"piso,omitempty"`
+	// Aforo opcional
+	Capacidad       *int `json:"capacidad,omitempty"`
+	UmbralOcupacion *int `json:"umbral_ocupacion,omitempty"`
+}
+
+// ErrorImportacion es un problema en una fila (CSV: línea; GeoJSON: feature, desde 1)
+type ErrorImportacion struct {
+	Fila  int    `json:"fila"`
+	ID    string `json:"id,omitempty"`
+	Error string `json:"error"`
+}
+
+// ImportacionZonas es el reporte de POST /zonas/importar. Con errores no se
+// crea ninguna zona.
+type ImportacionZonas struct {
+	Formato        string             `json:"formato"` // csv | geojson
+	Filas          int                `json:"filas"`
+	Creadas        int                `json:"creadas"`
+	SoloValidacion bool               `json:"solo_validacion"`
+	Errores        []ErrorImportacion `json:"errores"`
+}
+
+// CrearPlantillaRequest guarda la preparación actual de un evento como plantilla
+type CrearPlantillaRequest struct {
+	Nombre      string `json:"nombre" binding:"required,min=3"`
+	Descripcion string `json:"descripcion"`
+	EventoID    string `json:"evento_id" binding:"required"`
+}
+
+// InstanciarEventoRequest: el origen es plantilla_id o desde_evento_id; el
+// destino, un evento nuevo (evento) o uno en borrador o programado
+// (evento_id). Sin partes se copia todo.
+type InstanciarEventoRequest struct {
+	PlantillaID   string              `json:"plantilla_id"`
+	DesdeEventoID string              `json:"desde_evento_id"`
+	Evento        *CrearEventoRequest `json:"evento"`
+	EventoID      string              `json:"evento_id"`
+	Partes        []ParteEvento       `json:"partes"`
+	VistaPrevia   bool                `json:"vista_previa"`
+}
+
+type ClonarZonasRequest struct {
+	DesdeEventoID string `json:"desde_evento_id" binding:"required"`
+}
+
+// EditarZonaRequest: solo se cambia lo que viene. El ID no se edita (lo
+// referencian incidencias y tareas). padre_id "" la deja en la raíz; quitar
+// borra "punto", "poligono" o "piso".
+type EditarZonaRequest struct {
+	Nombre   *string   `json:"nombre,omitempty"`
+	Tipo     *TipoZona `json:"tipo,omitempty"`
+	PadreID  *string   `json:"padre_id,omitempty"`
+	Latitud  *float64  `json:"latitud,omitempty"`
+	Longitud *float64  `json:"longitud,omitempty"`
+	RadioM   *float64  `json:"radio_m,omitempty"`
+	Poligono Poligono  `json:"poligono,omitempty"`
+	Piso     *int      `json:"piso,omitempty"`
+	// Aforo
+	Capacidad       *int     `json:"capacidad,omitempty"`
+	UmbralOcupacion *int     `json:"umbral_ocupacion,omitempty"`
+	Quitar          []string `json:"quitar,omitempty"`
+}
+
+type CrearUsuarioRequest struct {
+	NombreUsuario string  `json:"nombre_usuario" binding:"required,min=3"`
+	Nombre        string  `json:"nombre" binding:"required,min=2"`
+	Password      string  `json:"password" binding:"required,min=4"`
+	Rol           Rol     `json:"rol" binding:"required"`
+	ZonaID        *string `json:"zona_id,omitempty"`
+}
+
+type CrearIncidenciaRequest struct {
+	ZonaID      string         `json:"zona_id" binding:"required"`
+	Tipo        TipoIncidencia `json:"tipo" binding:"required"`
+	Descripcion string         `json:"descripcion" binding:"required,min=5"`
+	AsignadaA   *string        `json:"asignada_a,omitempty"`
+}
+
+type EditarIncidenciaRequest struct {
+	Estado    *EstadoIncidencia `json:"estado,omitempty"`
+	AsignadaA *string           `json:"asignada_a,omitempty"`
+}
+
+type CrearTareaRequest struct {
+	ZonaID      string         `json:"zona_id,omitempty"`
+	Titulo      string         `json:"titulo" binding:"required,min=3"`
+	Descripcion string         `json:"descripcion"`
+	Prioridad   PrioridadTarea `json:"prioridad" binding:"required"`
+	AsignadaA   *string        `json:"asignada_a,omitempty"`
+	// Evidencia exigida para completarla: foto | nota | qr | gps
+	RequiereEvidencia []TipoEvidencia `json:"requiere_evidencia,omitempty"`
+}
+
+type EditarTareaRequest struct {
+	Estado    *EstadoTarea `json:"estado,omitempty"`
+	AsignadaA *string      `json:"asignada_a,omitempty"`
+}
+
+// SubirEvidenciaRequest llega como multipart/form-data (la foto va en el campo "foto")
+type SubirEvidenciaRequest struct {
+	Tipo     TipoEvidencia `form:"tipo" binding:"required"`
+	Nota     string        `form:"nota"`
+	QR       string        `form:"qr"`
+	Latitud  *float64      `form:"latitud"`
+	Longitud *float64      `form:"longitud"`
+}
+
+type CrearConversacionRequest struct {
+	Tipo     TipoConversacion `json:"tipo" binding:"required"` // grupo | zona
+	Nombre   string           `json:"nombre"`
+	ZonaID   string           `json:"zona_id,omitempty"`
+	Miembros []string         `json:"miembros"`
+}
+
+type AbrirDirectoRequest struct {
+	UsuarioID string `json:"usuario_id" binding:"required"`
+}
+
+type AgregarMiembrosRequest struct {
+	UsuarioIDs []string `json:"usuario_ids" binding:"required,min=1"`
+}
+
+type EditarMensajeRequest struct {
+	Contenido string `json:"contenido" binding:"required,min=1,max=500"`
+}
+
+type SilenciarRequest struct {
+	UsuarioID string `json:"usuario_id" binding:"required"`
+	Minutos   int    `json:"minutos" binding:"required,min=1,max=1440"`
+	Motivo    string `json:"motivo"`
+}
+
+type CrearFiltroRequest struct {
+	Palabra string       `json:"palabra" binding:"required,min=2,max=100"`
+	Accion  AccionFiltro `json:"accion"` // por defecto censurar
+}
+
+type PreferenciasNotificacionRequest struct {
+	Canales            []CanalNotificacion `json:"canales" binding:"required"`
+	Tipos              []TipoNotificacion  `json:"tipos" binding:"required"`
+	Email              *string             `json:"email,omitempty" binding:"omitempty,email,max=255"`
+	Telefono           *string             `json:"telefono,omitempty" binding:"omitempty,e164"`
+	SilencioDesde      *string             `json:"silencio_desde,omitempty"`
+	SilencioHasta      *string             `json:"silencio_hasta,omitempty"`
+	ZonaHoraria        string              `json:"zona_horaria"` // IANA, ej: America/Mexico_City
+	UrgentesEnSilencio *bool               `json:"urgentes_en_silencio,omitempty"`
+}
+
+// SuscribirPushRequest es el PushSubscription.toJSON() del navegador
+type SuscribirPushRequest struct {
+	Endpoint string `json:"endpoint" binding:"required,url"`
+	Keys     struct {
+		P256dh string `json:"p256dh" binding:"required"`
+		Auth   string `json:"auth" binding:"required"`
+	} `json:"keys" binding:"required"`
+}
+
+type DesuscribirPushRequest struct {
+	Endpoint string `json:"endpoint" binding:"required"`
+}
+
+type CrearWebhookRequest struct {
+	URL         string         `json:"url" binding:"required,url,max=500"`
+	Descripcion string         `json:"descripcion" binding:"max=255"`
+	Tipos       []TipoEventoWS `json:"tipos" binding:"required,min=1"`
+}
+
+// EditarWebhookRequest: solo se actualizan los campos presentes
+type EditarWebhookRequest struct {
+	URL         *string        `json:"url,omitempty" binding:"omitempty,url,max=500"`
+	Descripcion *string        `json:"descripcion,omitempty" binding:"omitempty,max=255"`
+	Tipos       []TipoEventoWS `json:"tipos,omitempty"`
+	Activo      *bool          `json:"activo,omitempty"`
+}
+
+// ActivarSOSRequest: todo es opcional, basta el toque. Sin ubicación se usa la
+// última conocida del usuario.
+type ActivarSOSRequest struct {
+	Latitud    *float64 `json:"latitud,omitempty"`
+	Longitud   *float64 `json:"longitud,omitempty"`
+	PrecisionM *float64 `json:"precision_m,omitempty"`
+	ZonaID     *string  `json:"zona_id,omitempty"`
+	Nota       *string  `json:"nota,omitempty"`
+}
+
+type UbicacionSOSRequest struct {
+	SOSID      string   `json:"sos_id,omitempty"` // solo por WebSocket; por REST va en la ruta
+	Latitud    *float64 `json:"latitud" binding:"required"`
+	Longitud   *float64 `json:"longitud" binding:"required"`
+	PrecisionM *float64 `json:"precision_m,omitempty"`
+	ZonaID     *string  `json:"zona_id,omitempty"`
+}
+
+// UbicacionRequest: GPS manda coordenadas; QR y baliza, la zona escaneada
+// (fuente por defecto: gps)
+type UbicacionRequest struct {
+	Latitud    *float64        `json:"latitud,omitempty"`
+	Longitud   *float64        `json:"longitud,omitempty"`
+	PrecisionM *float64        `json:"precision_m,omitempty"`
+	ZonaID     *string         `json:"zona_id,omitempty"`
+	Fuente     FuenteUbicacion `json:"fuente,omitempty"`
+}
+
+// CheckinRequest lleva el contenido del QR escaneado
+type CheckinRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type CrearRutaRequest struct {
+	Nombre        string      `json:"nombre" binding:"required"`
+	ToleranciaMin *int        `json:"tolerancia_min,omitempty"` // por defecto 5
+	Puntos        []PuntoRuta `json:"puntos" binding:"required,min=1,dive"`
+}
+
+type IniciarRondaRequest struct {
+	RutaID string `json:"ruta_id" binding:"required"`
+}
+
+// ConteoRequest suma entradas y resta salidas; un contador manual manda
+// {"entradas": 1} por clic, un torniquete puede mandar el acumulado del minuto
+type ConteoRequest struct {
+	ZonaID   string `json:"zona_id,omitempty"` // solo torniquetes; por defecto la del dispositivo
+	Entradas int    `json:"entradas" binding:"min=0,max=10000"`
+	Salidas  int    `json:"salidas" binding:"min=0,max=10000"`
+}
+
+// AjustarOcupacionRequest fija el conteo tras un recuento a mano
+type AjustarOcupacionRequest struct {
+	Personas *int `json:"personas" binding:"required,min=0"`
+}
+
+type CrearDispositivoRequest struct {
+	Nombre          string             `json:"nombre" binding:"required"`
+	ZonaID          string             `json:"zona_id" binding:"required"`
+	CampoZona       *string            `json:"campo_zona,omitempty"`
+	TemaMQTT        *string            `json:"tema_mqtt,omitempty"`
+	Reglas          []ReglaDispositivo `json:"reglas" binding:"required,min=1"`
+	VentanaSegundos *int               `json:"ventana_segundos,omitempty"` // por defecto 300
+}
+
+// EditarDispositivoRequest: reglas, si viene, reemplaza la lista completa
+type EditarDispositivoRequest struct {
+	Nombre          *string            `json:"nombre,omitempty"`
+	ZonaID          *string            `json:"zona_id,omitempty"`
+	CampoZona       *string            `json:"campo_zona,omitempty"` // "" lo quita
+	TemaMQTT        *string            `json:"tema_mqtt,omitempty"`  // "" lo quita
+	Reglas          []ReglaDispositivo `json:"reglas,omitempty"`
+	VentanaSegundos *int               `json:"ventana_segundos,omitempty"`
+	Activo          *bool              `json:"activo,omitempty"`
+}
+
+type MarcarLeidoRequest struct {
+	MensajeID string `json:"mensaje_id" binding:"required"`
+}
+
+type CrearAnuncioRequest struct {
+	Titulo    string         `json:"titulo" binding:"required,min=3,max=150"`
+	Contenido string         `json:"contenido" binding:"required"`
+	Destino   DestinoAnuncio `json:"destino" binding:"required"`
+	Rol       *Rol           `json:"rol,omitempty"`
+	ZonaID    string         `json:"zona_id,omitempty"`
+	// 0 = valor por defecto de la config (ANUNCIO_REENVIO_SEGUNDOS)
+	ReenviarCadaSegundos int `json:"reenviar_cada_segundos,omitempty"`
 }
 
 type EnviarMensajeRequest struct {
@@ -0,0 +1,114 @@
+package notificaciones
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/eventpulse/backend/internal/models"
+)
+
+// Email envía por SMTP, con STARTTLS si el servidor lo ofrece. Con usuario
+// vacío no autentica (relays internos, servidores de prueba).
+type Email struct {
+	host      string
+	addr      string
+	auth      smtp.Auth
+	remitente string
+	espera    time.Duration // tope para toda la conversación SMTP
+}
+
+// Tiempo máximo de un envío: un servidor colgado no retiene al worker
+const esperaSMTP = 30 * time.Second
+
+func NewEmail(host, puerto, usuario, password, remitente string) *Email {
+	e := &Email{host: host, addr: net.JoinHostPort(host, puerto), remitente: remitente, espera: esperaSMTP}
+	if usuario != "" {
+		e.auth = smtp.PlainAuth("", usuario, password, host)
+	}
+	return e
+}
+
+func (e *Email) Nombre() models.CanalNotificacion { return models.CanalEmail }
+
+func (e *Email) Enviar(ctx context.Context, p *models.PreferenciasNotificacion, n models.Notificacion) error {
+	if p.Email == nil || *p.Email == "" {
+		return ErrSinContacto
+	}
+	asunto := n.Titulo
+	if n.Urgente {
+		asunto = "[URGENTE] " + asunto
+	}
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: EventPulse <%s>\r\n", e.remitente)
+	fmt.Fprintf(&msg, "To: %s\r\n", *p.Email)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", asunto))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(n.Cuerpo, "\n", "\r\n"))
+	msg.WriteString("\r\n")
+
+	return e.enviarSMTP(ctx, *p.Email, []byte(msg.String()))
+}
+
+// enviarSMTP hace lo mismo que smtp.SendMail, pero sobre una conexión con
+// plazo: la del contexto o `espera`, lo que venza antes. Así ni la conexión
+// ni un servidor que deja de responder a mitad de camino bloquean para siempre.
+func (e *Email) enviarSMTP(ctx context.Context, para string, msg []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, e.espera)
+	defer cancel()
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", e.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	limite, _ := ctx.Deadline()
+	if err := conn.SetDeadline(limite); err != nil {
+		return err
+	}
+
+	c, err := smtp.NewClient(conn, e.host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: e.host}); err != nil {
+			return err
+		}
+	}
+	if e.auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("el servidor SMTP no admite AUTH")
+		}
+		if err := c.Auth(e.auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(e.remitente); err != nil {
+		return err
+	}
+	if err := c.Rcpt(para); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
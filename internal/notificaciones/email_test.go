@@ -0,0 +1,188 @@
+package notificaciones
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net"
+	"net/mail"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eventpulse/backend/internal/models"
+)
+
+// servidorSMTP es un SMTP mínimo en proceso: acepta un mensaje y lo guarda
+type servidorSMTP struct {
+	ln   net.Listener
+	mu   sync.Mutex
+	de   string
+	para []string
+	data string
+}
+
+func nuevoServidorSMTP(t *testing.T) *servidorSMTP {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &servidorSMTP{ln: ln}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.atender(conn)
+		}
+	}()
+	return s
+}
+
+func (s *servidorSMTP) atender(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	escribir := func(linea string) { conn.Write([]byte(linea + "\r\n")) }
+	escribir("220 localhost ESMTP prueba")
+	for {
+		linea, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		linea = strings.TrimRight(linea, "\r\n")
+		cmd := strings.ToUpper(linea)
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			escribir("250-localhost")
+			escribir("250 8BITMIME")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			s.mu.Lock()
+			s.de = direccion(linea[len("MAIL FROM:"):])
+			s.mu.Unlock()
+			escribir("250 ok")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			s.mu.Lock()
+			s.para = append(s.para, direccion(linea[len("RCPT TO:"):]))
+			s.mu.Unlock()
+			escribir("250 ok")
+		case cmd == "DATA":
+			escribir("354 adelante")
+			var b strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				b.WriteString(l)
+			}
+			s.mu.Lock()
+			s.data = b.String()
+			s.mu.Unlock()
+			escribir("250 encolado")
+		case cmd == "QUIT":
+			escribir("221 chau")
+			return
+		default:
+			escribir("250 ok")
+		}
+	}
+}
+
+// direccion saca lo que va entre <> en "<a@b> BODY=8BITMIME"
+func direccion(arg string) string {
+	arg = strings.TrimSpace(arg)
+	if fin := strings.IndexByte(arg, '>'); strings.HasPrefix(arg, "<") && fin > 0 {
+		return arg[1:fin]
+	}
+	return arg
+}
+
+func (s *servidorSMTP) puerto() string {
+	_, p, _ := net.SplitHostPort(s.ln.Addr().String())
+	return p
+}
+
+func TestEmailEnviar(t *testing.T) {
+	srv := nuevoServidorSMTP(t)
+	e := NewEmail("127.0.0.1", srv.puerto(), "", "", "avisos@eventpulse.test")
+	correo := "guardia@eventpulse.test"
+	p := &models.PreferenciasNotificacion{UsuarioID: "u1", Email: &correo}
+	n := models.Notificacion{Tipo: models.NotifAnuncio, Titulo: "Evacuación sector C", Cuerpo: "Salida 4\nYa", Urgente: true}
+
+	if err := e.Enviar(context.Background(), p, n); err != nil {
+		t.Fatalf("Enviar: %v", err)
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.de != "avisos@eventpulse.test" {
+		t.Errorf("MAIL FROM = %q", srv.de)
+	}
+	if len(srv.para) != 1 || srv.para[0] != correo {
+		t.Errorf("RCPT TO = %v", srv.para)
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(srv.data))
+	if err != nil {
+		t.Fatalf("mensaje mal formado: %v\n%s", err, srv.data)
+	}
+	if to := msg.Header.Get("To"); to != correo {
+		t.Errorf("To = %q", to)
+	}
+	asunto, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || asunto != "[URGENTE] Evacuación sector C" {
+		t.Errorf("Subject = %q (%v)", asunto, err)
+	}
+	if ct := msg.Header.Get("Content-Type"); ct != "text/plain; charset=UTF-8" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if cuerpo, _ := io.ReadAll(msg.Body); string(cuerpo) != "Salida 4\r\nYa\r\n" {
+		t.Errorf("cuerpo = %q", cuerpo)
+	}
+}
+
+func TestEmailSinCorreo(t *testing.T) {
+	e := NewEmail("127.0.0.1", "1", "", "", "avisos@eventpulse.test")
+	err := e.Enviar(context.Background(), &models.PreferenciasNotificacion{UsuarioID: "u1"}, models.Notificacion{Titulo: "x"})
+	if !errors.Is(err, ErrSinContacto) {
+		t.Fatalf("err = %v, esperado ErrSinContacto", err)
+	}
+}
+
+// Un servidor que acepta la conexión y nunca saluda no debe colgar el envío
+func TestEmailServidorColgado(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	_, puerto, _ := net.SplitHostPort(ln.Addr().String())
+	e := NewEmail("127.0.0.1", puerto, "", "", "avisos@eventpulse.test")
+	e.espera = 200 * time.Millisecond
+	correo := "guardia@eventpulse.test"
+
+	inicio := time.Now()
+	err = e.Enviar(context.Background(), &models.PreferenciasNotificacion{Email: &correo}, models.Notificacion{Titulo: "x"})
+	if err == nil {
+		t.Fatal("se esperaba error por plazo vencido")
+	}
+	if d := time.Since(inicio); d > 2*time.Second {
+		t.Fatalf("tardó %v en rendirse", d)
+	}
+}
@@ -0,0 +1,64 @@
+package notificaciones
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/eventpulse/backend/internal/repository"
+)
+
+// Escalador avisa a admins y supervisores cuando una incidencia sigue
+// pendiente más de `espera`. Cada incidencia se escala una sola vez.
+type Escalador struct {
+	repo        *repository.NotificacionRepo
+	notificador *Notificador
+	espera      time.Duration
+}
+
+func NewEscalador(r *repository.NotificacionRepo, n *Notificador, espera time.Duration) *Escalador {
+	return &Escalador{repo: r, notificador: n, espera: espera}
+}
+
+func (e *Escalador) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			e.revisar(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (e *Escalador) revisar(ctx context.Context) {
+	minutos := int(e.espera / time.Minute)
+	incidencias, err := e.repo.TomarIncidenciasParaEscalar(ctx, minutos)
+	if err != nil {
+		log.Println("❌ Error buscando incidencias para escalar:", err)
+		return
+	}
+	for _, inc := range incidencias {
+		ids, err := e.repo.IDsMando(ctx, inc.EventoID)
+		if err != nil {
+			log.Println("❌ Error buscando supervisión para escalar:", err)
+			continue
+		}
+		zona := inc.ZonaID
+		if inc.ZonaNombre != nil {
+			zona = *inc.ZonaNombre
+		}
+		e.notificador.NotificarAhora(ctx, ids, models.Notificacion{
+			Tipo:    models.NotifEscalamiento,
+			Titulo:  fmt.Sprintf("Incidencia sin atender: %s en %s", inc.Tipo, zona),
+			Cuerpo:  fmt.Sprintf("Lleva %d min pendiente. %s", int(time.Since(inc.CreadaEn).Minutes()), inc.Descripcion),
+			Urgente: true,
+			Ref:     inc.ID,
+		})
+		log.Printf("⏫ Incidencia %s escalada a %d usuarios", inc.ID, len(ids))
+	}
+}
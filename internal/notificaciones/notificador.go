@@ -0,0 +1,142 @@
+package notificaciones
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/eventpulse/backend/internal/repository"
+)
+
+// ErrSinContacto: el usuario no tiene dato de contacto para el canal
+// (sin email, sin teléfono o sin suscripciones push). Se registra como omitido.
+var ErrSinContacto = errors.New("sin datos de contacto para el canal")
+
+// Canal entrega una notificación por un medio concreto
+type Canal interface {
+	Nombre() models.CanalNotificacion
+	Enviar(ctx context.Context, p *models.PreferenciasNotificacion, n models.Notificacion) error
+}
+
+// Notificador reparte una notificación por los canales que cada usuario tiene
+// habilitados, respetando los tipos que aceptó y su horario de silencio. Solo
+// se usan los canales configurados en el servidor.
+type Notificador struct {
+	repo    *repository.NotificacionRepo
+	canales map[models.CanalNotificacion]Canal
+}
+
+func NewNotificador(r *repository.NotificacionRepo, canales ...Canal) *Notificador {
+	n := &Notificador{repo: r, canales: make(map[models.CanalNotificacion]Canal)}
+	for _, c := range canales {
+		n.canales[c.Nombre()] = c
+	}
+	return n
+}
+
+// Canales lista los canales disponibles en este servidor
+func (n *Notificador) Canales() []models.CanalNotificacion {
+	lista := make([]models.CanalNotificacion, 0, len(n.canales))
+	for _, c := range []models.CanalNotificacion{models.CanalPush, models.CanalEmail, models.CanalSMS} {
+		if _, ok := n.canales[c]; ok {
+			lista = append(lista, c)
+		}
+	}
+	return lista
+}
+
+// Notificar envía en segundo plano; nunca bloquea la request que lo dispara
+func (n *Notificador) Notificar(usuarioIDs []string, notif models.Notificacion) {
+	if len(usuarioIDs) == 0 {
+		return
+	}
+	go n.NotificarAhora(context.Background(), usuarioIDs, notif)
+}
+
+// NotificarAhora hace el envío de forma síncrona (para workers que ya corren aparte)
+func (n *Notificador) NotificarAhora(ctx context.Context, usuarioIDs []string, notif models.Notificacion) {
+	prefs, err := n.repo.PreferenciasDe(ctx, usuarioIDs)
+	if err != nil {
+		log.Printf("❌ Error cargando preferencias de notificación: %v", err)
+		return
+	}
+	ahora := time.Now()
+	for i := range prefs {
+		p := &prefs[i]
+		canales, silencio := n.canalesPara(p, notif, ahora)
+		if silencio {
+			n.registrar(ctx, p.UsuarioID, notif, nil, models.EnvioOmitido, "horario de silencio")
+			continue
+		}
+		for _, canal := range canales {
+			c := canal.Nombre()
+			switch err := canal.Enviar(ctx, p, notif); {
+			case errors.Is(err, ErrSinContacto):
+				n.registrar(ctx, p.UsuarioID, notif, &c, models.EnvioOmitido, err.Error())
+			case err != nil:
+				log.Printf("❌ Notificación %s por %s a %s: %v", notif.Tipo, c, p.UsuarioID, err)
+				n.registrar(ctx, p.UsuarioID, notif, &c, models.EnvioFallido, err.Error())
+			default:
+				n.registrar(ctx, p.UsuarioID, notif, &c, models.EnvioEnviado, "")
+			}
+		}
+	}
+}
+
+// canalesPara decide por qué canales le llega `notif` al usuario en `ahora`:
+// los que eligió y están configurados en el servidor, salvo que no acepte el
+// tipo (ninguno) o esté en horario de silencio (ninguno, y silencio = true).
+// Las obligatorias pasan siempre; las urgentes, si el usuario lo permite.
+func (n *Notificador) canalesPara(p *models.PreferenciasNotificacion, notif models.Notificacion, ahora time.Time) (canales []Canal, silencio bool) {
+	obligatoria := notif.Tipo.Obligatoria()
+	if !obligatoria && !slices.Contains(p.Tipos, string(notif.Tipo)) {
+		return nil, false // el usuario no quiere este tipo fuera de la app
+	}
+	if !obligatoria && EnSilencio(p, ahora) && !(notif.Urgente && p.UrgentesEnSilencio) {
+		return nil, true
+	}
+	for _, nombre := range p.Canales {
+		if canal, ok := n.canales[models.CanalNotificacion(nombre)]; ok {
+			canales = append(canales, canal)
+		}
+	}
+	return canales, false
+}
+
+func (n *Notificador) registrar(ctx context.Context, usuarioID string, notif models.Notificacion, canal *models.CanalNotificacion, estado models.EstadoEnvio, detalle string) {
+	if err := n.repo.RegistrarEnvio(ctx, usuarioID, notif, canal, estado, detalle); err != nil {
+		log.Printf("❌ Error registrando notificación: %v", err)
+	}
+}
+
+// EnSilencio indica si `t` cae dentro del horario de silencio del usuario,
+// en su zona horaria. El rango puede cruzar la medianoche (22:00 → 07:00).
+func EnSilencio(p *models.PreferenciasNotificacion, t time.Time) bool {
+	if p.SilencioDesde == nil || p.SilencioHasta == nil {
+		return false
+	}
+	desde, err1 := minutoDelDia(*p.SilencioDesde)
+	hasta, err2 := minutoDelDia(*p.SilencioHasta)
+	if err1 != nil || err2 != nil || desde == hasta {
+		return false
+	}
+	if loc, err := time.LoadLocation(p.ZonaHoraria); err == nil {
+		t = t.In(loc)
+	}
+	ahora := t.Hour()*60 + t.Minute()
+	if desde < hasta {
+		return ahora >= desde && ahora < hasta
+	}
+	return ahora >= desde || ahora < hasta
+}
+
+func minutoDelDia(hhmm string) (int, error) {
+	t, err := time.Parse("15:04", hhmm)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
@@ -0,0 +1,127 @@
+package notificaciones
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/eventpulse/backend/internal/models"
+)
+
+type canalFalso models.CanalNotificacion
+
+func (c canalFalso) Nombre() models.CanalNotificacion { return models.CanalNotificacion(c) }
+
+func (c canalFalso) Enviar(context.Context, *models.PreferenciasNotificacion, models.Notificacion) error {
+	return nil
+}
+
+func hora(hhmm string) *string { return &hhmm }
+
+func TestEnSilencio(t *testing.T) {
+	mx, _ := time.LoadLocation("America/Mexico_City")
+	a := func(h, m int) time.Time { return time.Date(2026, 3, 10, h, m, 0, 0, mx) }
+	casos := []struct {
+		nombre       string
+		desde, hasta *string
+		zona         string
+		t            time.Time
+		esperado     bool
+	}{
+		{"sin horario", nil, nil, "America/Mexico_City", a(3, 0), false},
+		{"mismo día, dentro", hora("13:00"), hora("15:00"), "America/Mexico_City", a(14, 0), true},
+		{"mismo día, fuera", hora("13:00"), hora("15:00"), "America/Mexico_City", a(16, 0), false},
+		{"desde incluido", hora("13:00"), hora("15:00"), "America/Mexico_City", a(13, 0), true},
+		{"hasta excluido", hora("13:00"), hora("15:00"), "America/Mexico_City", a(15, 0), false},
+		{"cruza medianoche, de noche", hora("22:00"), hora("07:00"), "America/Mexico_City", a(23, 30), true},
+		{"cruza medianoche, de madrugada", hora("22:00"), hora("07:00"), "America/Mexico_City", a(6, 59), true},
+		{"cruza medianoche, de día", hora("22:00"), hora("07:00"), "America/Mexico_City", a(12, 0), false},
+		{"desde igual a hasta", hora("08:00"), hora("08:00"), "America/Mexico_City", a(8, 0), false},
+		{"hora inválida", hora("25:00"), hora("07:00"), "America/Mexico_City", a(23, 0), false},
+		// 23:30 en México son las 05:30 UTC: en silencio para quien vive en UTC también
+		{"en la zona del usuario", hora("22:00"), hora("07:00"), "UTC", a(23, 30), true},
+		{"en la zona del usuario, fuera", hora("22:00"), hora("07:00"), "Asia/Tokyo", a(23, 30), false},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			p := &models.PreferenciasNotificacion{SilencioDesde: c.desde, SilencioHasta: c.hasta, ZonaHoraria: c.zona}
+			if got := EnSilencio(p, c.t); got != c.esperado {
+				t.Errorf("EnSilencio = %v, esperado %v", got, c.esperado)
+			}
+		})
+	}
+}
+
+func TestCanalesPara(t *testing.T) {
+	// El servidor solo tiene push y email configurados
+	n := NewNotificador(nil, canalFalso(models.CanalPush), canalFalso(models.CanalEmail))
+	mx, _ := time.LoadLocation("America/Mexico_City")
+	deNoche := time.Date(2026, 3, 10, 23, 30, 0, 0, mx)
+	deDia := time.Date(2026, 3, 10, 12, 0, 0, 0, mx)
+
+	prefs := func(urgentesEnSilencio bool) *models.PreferenciasNotificacion {
+		return &models.PreferenciasNotificacion{
+			UsuarioID:          "u1",
+			Canales:            []string{"sms", "email", "push"},
+			Tipos:              []string{"asignacion", "anuncio"},
+			SilencioDesde:      hora("22:00"),
+			SilencioHasta:      hora("07:00"),
+			ZonaHoraria:        "America/Mexico_City",
+			UrgentesEnSilencio: urgentesEnSilencio,
+		}
+	}
+	casos := []struct {
+		nombre   string
+		p        *models.PreferenciasNotificacion
+		notif    models.Notificacion
+		ahora    time.Time
+		canales  []models.CanalNotificacion
+		silencio bool
+	}{
+		{"canales del usuario que hay en el servidor, en su orden", prefs(false),
+			models.Notificacion{Tipo: models.NotifAsignacion}, deDia,
+			[]models.CanalNotificacion{models.CanalEmail, models.CanalPush}, false},
+		{"tipo no aceptado", prefs(false),
+			models.Notificacion{Tipo: models.NotifEscalamiento}, deDia, nil, false},
+		{"en silencio", prefs(false),
+			models.Notificacion{Tipo: models.NotifAsignacion}, deNoche, nil, true},
+		{"urgente sin permiso en silencio", prefs(false),
+			models.Notificacion{Tipo: models.NotifAnuncio, Urgente: true}, deNoche, nil, true},
+		{"urgente con permiso en silencio", prefs(true),
+			models.Notificacion{Tipo: models.NotifAnuncio, Urgente: true}, deNoche,
+			[]models.CanalNotificacion{models.CanalEmail, models.CanalPush}, false},
+		{"SOS es obligatoria: sin aceptarla y en silencio", prefs(false),
+			models.Notificacion{Tipo: models.NotifSOS}, deNoche,
+			[]models.CanalNotificacion{models.CanalEmail, models.CanalPush}, false},
+		{"sin canales elegidos", &models.PreferenciasNotificacion{Tipos: []string{"asignacion"}},
+			models.Notificacion{Tipo: models.NotifAsignacion}, deDia, nil, false},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			canales, silencio := n.canalesPara(c.p, c.notif, c.ahora)
+			if silencio != c.silencio {
+				t.Errorf("silencio = %v, esperado %v", silencio, c.silencio)
+			}
+			var nombres []models.CanalNotificacion
+			for _, canal := range canales {
+				nombres = append(nombres, canal.Nombre())
+			}
+			if len(nombres) != len(c.canales) {
+				t.Fatalf("canales = %v, esperado %v", nombres, c.canales)
+			}
+			for i := range nombres {
+				if nombres[i] != c.canales[i] {
+					t.Fatalf("canales = %v, esperado %v", nombres, c.canales)
+				}
+			}
+		})
+	}
+}
+
+func TestCanalesDelServidor(t *testing.T) {
+	n := NewNotificador(nil, canalFalso(models.CanalSMS), canalFalso(models.CanalPush))
+	got := n.Canales()
+	if len(got) != 2 || got[0] != models.CanalPush || got[1] != models.CanalSMS {
+		t.Errorf("Canales = %v, esperado [push sms]", got)
+	}
+}
@@ -0,0 +1,62 @@
+package notificaciones
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/eventpulse/backend/internal/models"
+)
+
+// SMS envía por un gateway HTTP genérico: POST JSON {"to", "message"} con
+// token Bearer opcional. Cualquier 2xx cuenta como aceptado.
+type SMS struct {
+	url     string
+	token   string
+	cliente *http.Client
+}
+
+func NewSMS(url, token string) *SMS {
+	return &SMS{url: url, token: token, cliente: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (s *SMS) Nombre() models.CanalNotificacion { return models.CanalSMS }
+
+// Tope del texto en caracteres. Un segmento SMS lleva 160 en GSM-7 o 70 si
+// hay acentos o emojis; el proveedor concatena, así que 300 son unos pocos
+// segmentos y no un solo SMS
+const maxSMS = 300
+
+func (s *SMS) Enviar(ctx context.Context, p *models.PreferenciasNotificacion, n models.Notificacion) error {
+	if p.Telefono == nil || *p.Telefono == "" {
+		return ErrSinContacto
+	}
+	texto := []rune("EventPulse: " + n.Titulo + " — " + n.Cuerpo)
+	if len(texto) > maxSMS {
+		texto = append(texto[:maxSMS-1], '…')
+	}
+	cuerpo, _ := json.Marshal(map[string]string{"to": *p.Telefono, "message": string(texto)})
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(cuerpo))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	resp, err := s.cliente.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detalle, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return fmt.Errorf("gateway SMS respondió %d: %s", resp.StatusCode, detalle)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package notificaciones
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/eventpulse/backend/internal/models"
+)
+
+func TestSMSEnviar(t *testing.T) {
+	var recibido map[string]string
+	var autorizacion string
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "mal pedido", http.StatusBadRequest)
+			return
+		}
+		autorizacion = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&recibido)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer gateway.Close()
+
+	tel := "+5215512345678"
+	s := NewSMS(gateway.URL, "secreto")
+	err := s.Enviar(context.Background(), &models.PreferenciasNotificacion{Telefono: &tel},
+		models.Notificacion{Titulo: "Incidencia asignada", Cuerpo: "Derrame en sector B"})
+	if err != nil {
+		t.Fatalf("Enviar: %v", err)
+	}
+	if autorizacion != "Bearer secreto" {
+		t.Errorf("Authorization = %q", autorizacion)
+	}
+	if recibido["to"] != tel {
+		t.Errorf("to = %q", recibido["to"])
+	}
+	if recibido["message"] != "EventPulse: Incidencia asignada — Derrame en sector B" {
+		t.Errorf("message = %q", recibido["message"])
+	}
+}
+
+func TestSMSRecortaTexto(t *testing.T) {
+	var recibido map[string]string
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&recibido)
+	}))
+	defer gateway.Close()
+
+	tel := "+5215512345678"
+	err := NewSMS(gateway.URL, "").Enviar(context.Background(), &models.PreferenciasNotificacion{Telefono: &tel},
+		models.Notificacion{Titulo: "Aviso", Cuerpo: strings.Repeat("ñ", 500)})
+	if err != nil {
+		t.Fatalf("Enviar: %v", err)
+	}
+	msg := recibido["message"]
+	if n := utf8.RuneCountInString(msg); n != maxSMS {
+		t.Errorf("largo = %d runas, esperado %d", n, maxSMS)
+	}
+	if !strings.HasSuffix(msg, "…") {
+		t.Errorf("el texto recortado debe terminar en …: %q", msg[len(msg)-10:])
+	}
+}
+
+func TestSMSErrorDelGateway(t *testing.T) {
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "saldo insuficiente", http.StatusPaymentRequired)
+	}))
+	defer gateway.Close()
+
+	tel := "+5215512345678"
+	err := NewSMS(gateway.URL, "").Enviar(context.Background(), &models.PreferenciasNotificacion{Telefono: &tel},
+		models.Notificacion{Titulo: "x"})
+	if err == nil || !strings.Contains(err.Error(), "402") || !strings.Contains(err.Error(), "saldo insuficiente") {
+		t.Fatalf("err = %v, esperado el 402 con su detalle", err)
+	}
+
+	err = NewSMS(gateway.URL, "").Enviar(context.Background(), &models.PreferenciasNotificacion{}, models.Notificacion{Titulo: "x"})
+	if !errors.Is(err, ErrSinContacto) {
+		t.Fatalf("sin teléfono: err = %v, esperado ErrSinContacto", err)
+	}
+}
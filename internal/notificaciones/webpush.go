@@ -0,0 +1,242 @@
+package notificaciones
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/eventpulse/backend/internal/repository"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/hkdf"
+)
+
+// WebPush entrega a los navegadores suscritos usando VAPID (RFC 8292) y
+// cifrado aes128gcm (RFC 8291). No depende de ningún servicio propio: cada
+// suscripción trae la URL del servicio push del navegador.
+type WebPush struct {
+	repo     *repository.NotificacionRepo
+	privada  *ecdsa.PrivateKey
+	publica  string // base64url sin padding, la que usa el navegador como applicationServerKey
+	contacto string // "mailto:..." para el claim sub
+	cliente  *http.Client
+}
+
+// NewWebPush recibe la clave privada VAPID en base64url (32 bytes, el formato
+// de `web-push generate-vapid-keys`). La pública se deriva de ella.
+func NewWebPush(r *repository.NotificacionRepo, clavePrivada, contacto string) (*WebPush, error) {
+	raw, err := decodificarB64(clavePrivada)
+	if err != nil {
+		return nil, fmt.Errorf("clave VAPID inválida: %w", err)
+	}
+	priv, err := ecdh.P256().NewPrivateKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("clave VAPID inválida: %w", err)
+	}
+	pub := priv.PublicKey().Bytes() // 0x04 || X || Y
+	return &WebPush{
+		repo: r,
+		privada: &ecdsa.PrivateKey{
+			PublicKey: ecdsa.PublicKey{
+				Curve: elliptic.P256(),
+				X:     new(big.Int).SetBytes(pub[1:33]),
+				Y:     new(big.Int).SetBytes(pub[33:]),
+			},
+			D: new(big.Int).SetBytes(raw),
+		},
+		publica:  base64.RawURLEncoding.EncodeToString(pub),
+		contacto: contacto,
+		cliente:  &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (w *WebPush) Nombre() models.CanalNotificacion { return models.CanalPush }
+
+// ClavePublica es la applicationServerKey que el cliente pasa a pushManager.subscribe
+func (w *WebPush) ClavePublica() string { return w.publica }
+
+func (w *WebPush) Enviar(ctx context.Context, p *models.PreferenciasNotificacion, n models.Notificacion) error {
+	subs, err := w.repo.SuscripcionesDe(ctx, p.UsuarioID)
+	if err != nil {
+		return err
+	}
+	if len(subs) == 0 {
+		return ErrSinContacto
+	}
+	payload, _ := json.Marshal(n)
+
+	var ultimoErr error
+	enviados := 0
+	for _, s := range subs {
+		err := w.enviarA(ctx, &s, payload, n.Urgente)
+		if errors.Is(err, errSuscripcionVencida) {
+			// El navegador se desuscribió o expiró: no volver a intentarlo
+			if err := w.repo.Desuscribir(ctx, "", s.Endpoint); err != nil && !errors.Is(err, repository.ErrNoEncontrado) {
+				log.Printf("❌ Error borrando suscripción push vencida: %v", err)
+			}
+			continue
+		}
+		if err != nil {
+			ultimoErr = err
+			continue
+		}
+		enviados++
+	}
+	if enviados > 0 {
+		return nil
+	}
+	if ultimoErr == nil {
+		return ErrSinContacto // todas vencidas
+	}
+	return ultimoErr
+}
+
+var errSuscripcionVencida = errors.New("suscripción push vencida")
+
+func (w *WebPush) enviarA(ctx context.Context, s *models.SuscripcionPush, payload []byte, urgente bool) error {
+	cuerpo, err := cifrar(s, payload)
+	if err != nil {
+		return err
+	}
+	autorizacion, err := w.vapid(s.Endpoint)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.Endpoint, bytes.NewReader(cuerpo))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("TTL", "86400")
+	req.Header.Set("Authorization", autorizacion)
+	if urgente {
+		req.Header.Set("Urgency", "high")
+	}
+	resp, err := w.cliente.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return errSuscripcionVencida
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		detalle, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return fmt.Errorf("servicio push respondió %d: %s", resp.StatusCode, detalle)
+	}
+	return nil
+}
+
+// vapid arma el header Authorization: un JWT ES256 para el origen del
+// servicio push, más la clave pública.
+func (w *WebPush) vapid(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": time.Now().Add(12 * time.Hour).Unix(),
+		"sub": w.contacto,
+	}).SignedString(w.privada)
+	if err != nil {
+		return "", err
+	}
+	return "vapid t=" + token + ", k=" + w.publica, nil
+}
+
+// Tamaño de registro declarado en el encabezado aes128gcm; el payload va en un solo registro
+const tamRegistro = 4096
+
+// cifrar aplica RFC 8291: ECDH efímero con la clave del navegador, HKDF con
+// su secreto auth, y AES-128-GCM en un único registro.
+func cifrar(s *models.SuscripcionPush, payload []byte) ([]byte, error) {
+	uaPub, err := decodificarB64(s.P256dh)
+	if err != nil {
+		return nil, fmt.Errorf("p256dh inválido: %w", err)
+	}
+	authSecreto, err := decodificarB64(s.Auth)
+	if err != nil {
+		return nil, fmt.Errorf("auth inválido: %w", err)
+	}
+	clienteClave, err := ecdh.P256().NewPublicKey(uaPub)
+	if err != nil {
+		return nil, fmt.Errorf("p256dh inválido: %w", err)
+	}
+	efimera, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	compartido, err := efimera.ECDH(clienteClave)
+	if err != nil {
+		return nil, err
+	}
+	asPub := efimera.PublicKey().Bytes()
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	// IKM = HKDF(auth, ecdh, "WebPush: info" || 0x00 || ua_public || as_public)
+	info := append([]byte("WebPush: info\x00"), uaPub...)
+	info = append(info, asPub...)
+	ikm := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, compartido, authSecreto, info), ikm); err != nil {
+		return nil, err
+	}
+	cek := make([]byte, 16)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, ikm, salt, []byte("Content-Encoding: aes128gcm\x00")), cek); err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, 12)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, ikm, salt, []byte("Content-Encoding: nonce\x00")), nonce); err != nil {
+		return nil, err
+	}
+
+	bloque, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(bloque)
+	if err != nil {
+		return nil, err
+	}
+	// Delimitador 0x02 = último (y único) registro
+	plano := append(append([]byte{}, payload...), 0x02)
+	if len(plano)+gcm.Overhead() > tamRegistro {
+		return nil, errors.New("payload demasiado grande para web push")
+	}
+
+	// Encabezado: salt(16) || rs(4) || idlen(1) || keyid(as_public)
+	cuerpo := make([]byte, 0, 21+len(asPub)+len(plano)+gcm.Overhead())
+	cuerpo = append(cuerpo, salt...)
+	cuerpo = binary.BigEndian.AppendUint32(cuerpo, tamRegistro)
+	cuerpo = append(cuerpo, byte(len(asPub)))
+	cuerpo = append(cuerpo, asPub...)
+	return gcm.Seal(cuerpo, nonce, plano, nil), nil
+}
+
+// decodificarB64 acepta base64url con o sin padding (los navegadores varían)
+func decodificarB64(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
@@ -0,0 +1,206 @@
+package notificaciones
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/hkdf"
+)
+
+// navegador simula la suscripción de un navegador: sus claves y lo que recibió
+type navegador struct {
+	privada *ecdh.PrivateKey
+	auth    []byte
+}
+
+func nuevoNavegador(t *testing.T) *navegador {
+	t.Helper()
+	priv, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := make([]byte, 16)
+	rand.Read(auth)
+	return &navegador{privada: priv, auth: auth}
+}
+
+func (n *navegador) suscripcion(endpoint string) *models.SuscripcionPush {
+	return &models.SuscripcionPush{
+		Endpoint: endpoint,
+		P256dh:   base64.RawURLEncoding.EncodeToString(n.privada.PublicKey().Bytes()),
+		Auth:     base64.URLEncoding.EncodeToString(n.auth), // con padding: también se acepta
+	}
+}
+
+// descifrar es el lado del navegador de RFC 8291 / RFC 8188
+func (n *navegador) descifrar(t *testing.T, cuerpo []byte) []byte {
+	t.Helper()
+	if len(cuerpo) < 21 {
+		t.Fatalf("cuerpo demasiado corto: %d bytes", len(cuerpo))
+	}
+	salt := cuerpo[:16]
+	if rs := binary.BigEndian.Uint32(cuerpo[16:20]); rs != tamRegistro {
+		t.Errorf("rs = %d", rs)
+	}
+	idlen := int(cuerpo[20])
+	asPubRaw := cuerpo[21 : 21+idlen]
+	cifrado := cuerpo[21+idlen:]
+
+	asPub, err := ecdh.P256().NewPublicKey(asPubRaw)
+	if err != nil {
+		t.Fatalf("keyid no es una clave P-256: %v", err)
+	}
+	compartido, err := n.privada.ECDH(asPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := append([]byte("WebPush: info\x00"), n.privada.PublicKey().Bytes()...)
+	info = append(info, asPubRaw...)
+	ikm := make([]byte, 32)
+	io.ReadFull(hkdf.New(sha256.New, compartido, n.auth, info), ikm)
+	cek := make([]byte, 16)
+	io.ReadFull(hkdf.New(sha256.New, ikm, salt, []byte("Content-Encoding: aes128gcm\x00")), cek)
+	nonce := make([]byte, 12)
+	io.ReadFull(hkdf.New(sha256.New, ikm, salt, []byte("Content-Encoding: nonce\x00")), nonce)
+
+	bloque, _ := aes.NewCipher(cek)
+	gcm, _ := cipher.NewGCM(bloque)
+	plano, err := gcm.Open(nil, nonce, cifrado, nil)
+	if err != nil {
+		t.Fatalf("no se pudo descifrar: %v", err)
+	}
+	if len(plano) == 0 || plano[len(plano)-1] != 0x02 {
+		t.Fatalf("falta el delimitador de último registro")
+	}
+	return plano[:len(plano)-1]
+}
+
+// verificarVAPID valida el header Authorization como lo haría el servicio push
+func verificarVAPID(t *testing.T, header, audiencia, contacto string) {
+	t.Helper()
+	if !strings.HasPrefix(header, "vapid t=") {
+		t.Fatalf("Authorization = %q", header)
+	}
+	partes := strings.SplitN(strings.TrimPrefix(header, "vapid t="), ", k=", 2)
+	if len(partes) != 2 {
+		t.Fatalf("Authorization sin k=: %q", header)
+	}
+	k, err := base64.RawURLEncoding.DecodeString(partes[1])
+	if err != nil || len(k) != 65 || k[0] != 0x04 {
+		t.Fatalf("k no es una clave pública sin comprimir: %q", partes[1])
+	}
+	publica := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(k[1:33]), Y: new(big.Int).SetBytes(k[33:])}
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(partes[0], claims, func(tk *jwt.Token) (interface{}, error) {
+		if tk.Method != jwt.SigningMethodES256 {
+			return nil, errors.New("se esperaba ES256")
+		}
+		return publica, nil
+	})
+	if err != nil {
+		t.Fatalf("JWT VAPID inválido: %v", err)
+	}
+	if claims["aud"] != audiencia {
+		t.Errorf("aud = %v, esperado %s", claims["aud"], audiencia)
+	}
+	if claims["sub"] != contacto {
+		t.Errorf("sub = %v", claims["sub"])
+	}
+	exp, _ := claims.GetExpirationTime()
+	if exp == nil || exp.Before(time.Now()) || exp.After(time.Now().Add(24*time.Hour)) {
+		t.Errorf("exp fuera de rango: %v", exp)
+	}
+}
+
+func nuevoWebPush(t *testing.T) *WebPush {
+	t.Helper()
+	clave, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewWebPush(nil, base64.RawURLEncoding.EncodeToString(clave.Bytes()), "mailto:ops@eventpulse.test")
+	if err != nil {
+		t.Fatalf("NewWebPush: %v", err)
+	}
+	return w
+}
+
+func TestWebPushCifradoYVAPID(t *testing.T) {
+	w := nuevoWebPush(t)
+	nav := nuevoNavegador(t)
+	// El servicio push solo guarda lo recibido; se verifica en el test
+	var pedido *http.Request
+	var cuerpo []byte
+	servicio := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		pedido = r
+		cuerpo, _ = io.ReadAll(r.Body)
+		rw.WriteHeader(http.StatusCreated)
+	}))
+	defer servicio.Close()
+
+	n := models.Notificacion{Tipo: models.NotifAnuncio, Titulo: "Evacuar sector C", Cuerpo: "Salida 4", Urgente: true, Ref: "a1"}
+	payload, _ := json.Marshal(n)
+	if err := w.enviarA(context.Background(), nav.suscripcion(servicio.URL+"/push/abc"), payload, true); err != nil {
+		t.Fatalf("enviarA: %v", err)
+	}
+	if pedido.Header.Get("Content-Encoding") != "aes128gcm" || pedido.Header.Get("TTL") == "" || pedido.Header.Get("Urgency") != "high" {
+		t.Errorf("headers = %v", pedido.Header)
+	}
+	verificarVAPID(t, pedido.Header.Get("Authorization"), "http://"+pedido.Host, "mailto:ops@eventpulse.test")
+	recibido := nav.descifrar(t, cuerpo)
+
+	var got models.Notificacion
+	if err := json.Unmarshal(recibido, &got); err != nil {
+		t.Fatalf("payload descifrado no es JSON: %v (%q)", err, recibido)
+	}
+	if got != n {
+		t.Errorf("payload = %+v, esperado %+v", got, n)
+	}
+}
+
+func TestWebPushClavePublica(t *testing.T) {
+	clave, _ := ecdh.P256().GenerateKey(rand.Reader)
+	w, err := NewWebPush(nil, base64.RawURLEncoding.EncodeToString(clave.Bytes()), "mailto:ops@eventpulse.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.ClavePublica() != base64.RawURLEncoding.EncodeToString(clave.PublicKey().Bytes()) {
+		t.Error("la clave pública no corresponde a la privada")
+	}
+	if _, err := NewWebPush(nil, "no-es-una-clave", "mailto:x"); err == nil {
+		t.Error("se esperaba error con una clave inválida")
+	}
+}
+
+func TestWebPushSuscripcionVencida(t *testing.T) {
+	w := nuevoWebPush(t)
+	servicio := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusGone)
+	}))
+	defer servicio.Close()
+
+	err := w.enviarA(context.Background(), nuevoNavegador(t).suscripcion(servicio.URL), []byte(`{}`), false)
+	if !errors.Is(err, errSuscripcionVencida) {
+		t.Fatalf("err = %v, esperado errSuscripcionVencida", err)
+	}
+}
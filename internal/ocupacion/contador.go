@@ -0,0 +1,220 @@
+package ocupacion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/eventpulse/backend/config"
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/eventpulse/backend/internal/repository"
+	"github.com/eventpulse/backend/internal/ws"
+	"github.com/redis/go-redis/v9"
+)
+
+// Claves en Redis (hashes zona → valor, uno por evento):
+//   - ep:ocupacion:<evento>         personas dentro
+//   - ep:ocupacion:nivel:<evento>   último nivel alertado (0 normal, 1 alerta, 2 llena)
+//   - ep:ocupacion:hora:<evento>    unix del último conteo
+//
+// Expiran a los 7 días sin conteos: el evento ya terminó.
+const (
+	prefijoPersonas = "ep:ocupacion:"
+	prefijoNivel    = "ep:ocupacion:nivel:"
+	prefijoHora     = "ep:ocupacion:hora:"
+	retencion       = 7 * 24 * time.Hour
+)
+
+var ErrZonaInexistente = errors.New("la zona no existe o está archivada")
+
+// conteo suma (o fija, con ARGV[3] = "1") y decide el nivel en la misma
+// operación, así dos contadores que cruzan el umbral a la vez no crean dos
+// incidencias. Para subir de nivel basta llegar al umbral; para bajar hay que
+// quedar la histéresis por debajo. Devuelve {personas, nivel previo, nivel}.
+var conteo = redis.NewScript(`
+local n
+if ARGV[3] == '1' then
+  n = tonumber(ARGV[2])
+  redis.call('HSET', KEYS[1], ARGV[1], n)
+else
+  n = redis.call('HINCRBY', KEYS[1], ARGV[1], ARGV[2])
+  if n < 0 then
+    n = 0
+    redis.call('HSET', KEYS[1], ARGV[1], 0)
+  end
+end
+redis.call('HSET', KEYS[3], ARGV[1], ARGV[7])
+for i = 1, 3 do redis.call('PEXPIRE', KEYS[i], ARGV[8]) end
+
+local cap = tonumber(ARGV[5])
+if cap == 0 then
+  redis.call('HDEL', KEYS[2], ARGV[1])
+  return {n, 0, 0}
+end
+local umbral, h = tonumber(ARGV[4]), tonumber(ARGV[6])
+local previo = tonumber(redis.call('HGET', KEYS[2], ARGV[1]) or '0')
+local sube, baja = 0, 0
+if n >= cap then sube = 2 elseif n >= umbral then sube = 1 end
+if n >= cap - h then baja = 2 elseif n >= umbral - h then baja = 1 end
+local nivel = previo
+if sube > previo then nivel = sube elseif baja < previo then nivel = baja end
+redis.call('HSET', KEYS[2], ARGV[1], nivel)
+return {n, previo, nivel}
+`)
+
+// Contador lleva las personas dentro de cada zona a partir de entradas y
+// salidas (contadores manuales o torniquetes). Al cruzar el umbral de alerta o
+// la capacidad crea una incidencia de seguridad en la zona, por el mismo
+// camino que POST /incidencias.
+type Contador struct {
+	zonaRepo       *repository.ZonaRepo
+	incidenciaRepo *repository.IncidenciaRepo
+	redis          *redis.Client
+	hub            *ws.Hub
+	cfg            config.OcupacionConfig
+}
+
+func NewContador(z *repository.ZonaRepo, i *repository.IncidenciaRepo, rdb *redis.Client, h *ws.Hub, cfg config.OcupacionConfig) *Contador {
+	return &Contador{zonaRepo: z, incidenciaRepo: i, redis: rdb, hub: h, cfg: cfg}
+}
+
+// Contar suma entradas y resta salidas; el conteo nunca baja de cero.
+// usuarioID figura como autor de la incidencia si se cruza un umbral.
+func (c *Contador) Contar(ctx context.Context, eventoID, zonaID, usuarioID string, entradas, salidas int) (*models.Ocupacion, error) {
+	return c.aplicar(ctx, eventoID, zonaID, usuarioID, entradas-salidas, false)
+}
+
+// Ajustar fija el conteo (recuento a mano o puesta a cero)
+func (c *Contador) Ajustar(ctx context.Context, eventoID, zonaID, usuarioID string, personas int) (*models.Ocupacion, error) {
+	return c.aplicar(ctx, eventoID, zonaID, usuarioID, personas, true)
+}
+
+// Listar devuelve las zonas activas del evento con capacidad o con gente contada
+func (c *Contador) Listar(ctx context.Context, eventoID string) ([]models.Ocupacion, error) {
+	zonas, err := c.zonaRepo.Listar(ctx, eventoID, false)
+	if err != nil {
+		return nil, err
+	}
+	personas, err := c.redis.HGetAll(ctx, prefijoPersonas+eventoID).Result()
+	if err != nil {
+		return nil, err
+	}
+	horas, err := c.redis.HGetAll(ctx, prefijoHora+eventoID).Result()
+	if err != nil {
+		return nil, err
+	}
+	lista := []models.Ocupacion{}
+	for i := range zonas {
+		z := &zonas[i]
+		v, contada := personas[z.ID]
+		if z.Capacidad == nil && !contada {
+			continue
+		}
+		n, _ := strconv.Atoi(v)
+		o := c.ocupacion(z, n)
+		if unix, err := strconv.ParseInt(horas[z.ID], 10, 64); err == nil {
+			t := time.Unix(unix, 0)
+			o.ActualizadaEn = &t
+		}
+		lista = append(lista, *o)
+	}
+	return lista, nil
+}
+
+func (c *Contador) aplicar(ctx context.Context, eventoID, zonaID, usuarioID string, valor int, absoluto bool) (*models.Ocupacion, error) {
+	zona, err := c.zonaRepo.ObtenerPorID(ctx, zonaID, eventoID)
+	if err != nil {
+		return nil, err
+	}
+	if zona == nil || zona.ArchivadaEn != nil {
+		return nil, ErrZonaInexistente
+	}
+	capacidad, umbral, histeresis := 0, 0, 0
+	if zona.Capacidad != nil {
+		capacidad = *zona.Capacidad
+		umbral = personasUmbral(capacidad, c.umbralPct(zona))
+		histeresis = int(math.Ceil(float64(capacidad) * float64(c.cfg.HisteresisPct) / 100))
+	}
+	ahora := time.Now()
+	abs := "0"
+	if absoluto {
+		abs = "1"
+	}
+	res, err := conteo.Run(ctx, c.redis,
+		[]string{prefijoPersonas + eventoID, prefijoNivel + eventoID, prefijoHora + eventoID},
+		zonaID, valor, abs, umbral, capacidad, histeresis, ahora.Unix(), retencion.Milliseconds(),
+	).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	o := c.ocupacion(zona, int(res[0]))
+	o.ActualizadaEn = &ahora
+
+	if previo, nivel := res[1], res[2]; nivel > previo {
+		if inc := c.alertar(ctx, zona, o, usuarioID); inc != nil {
+			o.IncidenciaID = &inc.ID
+		}
+	}
+	if err := c.hub.Publicar(ctx, eventoID, models.EventoWS{Tipo: models.WSOcupacionActualizada, Payload: o, EventoID: eventoID}); err != nil {
+		log.Println("❌ Error publicando ocupación en Redis:", err)
+	}
+	return o, nil
+}
+
+// alertar crea la incidencia de seguridad del cruce de umbral. Si falla se
+// registra y el conteo sigue: perder el aviso es mejor que perder la cuenta.
+func (c *Contador) alertar(ctx context.Context, zona *models.Zona, o *models.Ocupacion, usuarioID string) *models.Incidencia {
+	desc := fmt.Sprintf("Aforo de %s al %.0f%% (%d de %d personas)", zona.Nombre, *o.Porcentaje, o.Personas, *o.Capacidad)
+	if o.Nivel == models.OcupacionLlena {
+		desc = fmt.Sprintf("%s llegó a su capacidad (%d de %d personas)", zona.Nombre, o.Personas, *o.Capacidad)
+	}
+	inc, err := c.incidenciaRepo.Crear(ctx, &models.CrearIncidenciaRequest{
+		ZonaID:      zona.ID,
+		Tipo:        models.TipoSeguridad,
+		Descripcion: desc,
+	}, zona.EventoID, usuarioID)
+	if err != nil {
+		log.Printf("❌ Error creando incidencia de aforo en %s: %v", zona.ID, err)
+		return nil
+	}
+	err = c.hub.Publicar(ctx, zona.EventoID, models.EventoWS{Tipo: models.WSIncidenciaNueva, Payload: inc, EventoID: zona.EventoID})
+	if err != nil {
+		log.Println("❌ Error publicando incidencia de aforo en Redis:", err)
+	}
+	log.Printf("👥 Incidencia %s: %s", inc.ID, desc)
+	return inc
+}
+
+// ocupacion arma la respuesta; el nivel es el del conteo actual, sin histéresis
+func (c *Contador) ocupacion(z *models.Zona, personas int) *models.Ocupacion {
+	o := &models.Ocupacion{ZonaID: z.ID, ZonaNombre: z.Nombre, Personas: personas, Nivel: models.OcupacionNormal}
+	if z.Capacidad == nil {
+		return o
+	}
+	umbral := c.umbralPct(z)
+	pct := math.Round(float64(personas)*1000/float64(*z.Capacidad)) / 10
+	o.Capacidad, o.Porcentaje, o.Umbral = z.Capacidad, &pct, &umbral
+	switch {
+	case personas >= *z.Capacidad:
+		o.Nivel = models.OcupacionLlena
+	case personas >= personasUmbral(*z.Capacidad, umbral):
+		o.Nivel = models.OcupacionAlerta
+	}
+	return o
+}
+
+func (c *Contador) umbralPct(z *models.Zona) int {
+	if z.UmbralOcupacion != nil {
+		return *z.UmbralOcupacion
+	}
+	return c.cfg.UmbralPct
+}
+
+// personasUmbral: 90% de 45 son 40,5 → alerta desde 41
+func personasUmbral(capacidad, pct int) int {
+	return int(math.Ceil(float64(capacidad) * float64(pct) / 100))
+}
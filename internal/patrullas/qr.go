@@ -0,0 +1,81 @@
+package patrullas
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/jung-kurt/gofpdf"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// Tamaño del PNG en píxeles
+const (
+	TamanoQRMin        = 128
+	TamanoQRPorDefecto = 512
+	TamanoQRMax        = 2048
+)
+
+// PNG del QR con el token de la zona. Corrección alta: sobrevive a stickers
+// rayados o mal iluminados.
+func (f *Firmador) PNG(eventoID, zonaID string, tamano int) ([]byte, error) {
+	return qrcode.Encode(f.Token(eventoID, zonaID), qrcode.High, tamano)
+}
+
+// Hoja A4 para imprimir: seis QR por página (2 × 3), cada uno con el nombre
+// y el ID de la zona y una línea de corte
+func (f *Firmador) HojaPDF(evento *models.Evento, zonas []models.Zona) ([]byte, error) {
+	const (
+		columnas, filas = 2, 3
+		margen          = 12.0
+		anchoCelda      = (210 - 2*margen) / columnas
+		altoCelda       = (297 - 2*margen) / filas
+		ladoQR          = 62.0
+	)
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle("QR de zonas - "+evento.Nombre, true)
+	pdf.SetAutoPageBreak(false, 0)
+	tr := pdf.UnicodeTranslatorFromDescriptor("") // acentos y ñ en las fuentes base
+	pdf.SetDrawColor(180, 180, 180)
+
+	for i := range zonas {
+		z := &zonas[i]
+		pos := i % (columnas * filas)
+		if pos == 0 {
+			pdf.AddPage()
+		}
+		x := margen + float64(pos%columnas)*anchoCelda
+		y := margen + float64(pos/columnas)*altoCelda
+
+		png, err := f.PNG(evento.ID, z.ID, TamanoQRPorDefecto)
+		if err != nil {
+			return nil, fmt.Errorf("QR de %s: %w", z.ID, err)
+		}
+		nombreImg := "qr-" + z.ID
+		pdf.RegisterImageOptionsReader(nombreImg, gofpdf.ImageOptions{ImageType: "PNG"}, bytes.NewReader(png))
+
+		pdf.SetDashPattern([]float64{1.5, 1.5}, 0)
+		pdf.Rect(x, y, anchoCelda, altoCelda, "D")
+		pdf.SetDashPattern([]float64{}, 0)
+
+		pdf.SetFont("Helvetica", "B", 15)
+		pdf.SetXY(x+4, y+6)
+		pdf.CellFormat(anchoCelda-8, 8, tr(z.Nombre), "", 0, "C", false, 0, "")
+		pdf.ImageOptions(nombreImg, x+(anchoCelda-ladoQR)/2, y+16, ladoQR, ladoQR, false, gofpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+		pdf.SetFont("Courier", "", 10)
+		pdf.SetXY(x+4, y+16+ladoQR+3)
+		pdf.CellFormat(anchoCelda-8, 5, tr(z.ID), "", 0, "C", false, 0, "")
+		pdf.SetFont("Helvetica", "", 8)
+		pdf.SetXY(x+4, y+altoCelda-9)
+		pdf.CellFormat(anchoCelda-8, 4, tr(evento.Nombre+" · "+string(z.Tipo)), "", 0, "C", false, 0, "")
+	}
+	if len(zonas) == 0 {
+		pdf.AddPage()
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,85 @@
+package patrullas
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// Formato del QR: EP1.<base64url(evento \n zona)>.<base64url(hmac[:16])>.
+// El token ata la zona al evento: los QR de un evento no sirven en el
+// siguiente aunque la zona se haya clonado con el mismo ID.
+const prefijoToken = "EP1."
+
+var (
+	ErrTokenInvalido = errors.New("el QR no es de EventPulse o fue alterado")
+	ErrQROtraZona    = errors.New("el QR escaneado no corresponde a la zona")
+)
+
+// Firmador genera y verifica los tokens de los QR de zona
+type Firmador struct {
+	clave          []byte
+	aceptarIDPlano bool
+}
+
+// NewFirmador; con aceptarIDPlano, ZonaDe también acepta los QR viejos que
+// solo traen el ID de la zona (para la transición, mientras se reimprimen)
+func NewFirmador(secreto string, aceptarIDPlano bool) *Firmador {
+	return &Firmador{clave: []byte(secreto), aceptarIDPlano: aceptarIDPlano}
+}
+
+// Token es el contenido del QR de la zona
+func (f *Firmador) Token(eventoID, zonaID string) string {
+	carga := base64.RawURLEncoding.EncodeToString([]byte(eventoID + "\n" + zonaID))
+	return prefijoToken + carga + "." + base64.RawURLEncoding.EncodeToString(f.firma(carga))
+}
+
+// Verificar devuelve el evento y la zona de un token válido
+func (f *Firmador) Verificar(token string) (eventoID, zonaID string, err error) {
+	resto, ok := strings.CutPrefix(strings.TrimSpace(token), prefijoToken)
+	if !ok {
+		return "", "", ErrTokenInvalido
+	}
+	carga, firma, ok := strings.Cut(resto, ".")
+	if !ok {
+		return "", "", ErrTokenInvalido
+	}
+	// Strict: los bits de relleno del último carácter también cuentan, así
+	// cada firma tiene una sola escritura válida
+	recibida, err := base64.RawURLEncoding.Strict().DecodeString(firma)
+	if err != nil || !hmac.Equal(recibida, f.firma(carga)) {
+		return "", "", ErrTokenInvalido
+	}
+	datos, err := base64.RawURLEncoding.Strict().DecodeString(carga)
+	if err != nil {
+		return "", "", ErrTokenInvalido
+	}
+	eventoID, zonaID, ok = strings.Cut(string(datos), "\n")
+	if !ok || eventoID == "" || zonaID == "" {
+		return "", "", ErrTokenInvalido
+	}
+	return eventoID, zonaID, nil
+}
+
+// ZonaDe comprueba que lo escaneado sea el QR de la zona en el evento
+func (f *Firmador) ZonaDe(contenido, eventoID, zonaID string) error {
+	ev, zona, err := f.Verificar(contenido)
+	if err != nil {
+		if f.aceptarIDPlano && strings.TrimSpace(contenido) == zonaID {
+			return nil
+		}
+		return err
+	}
+	if ev != eventoID || zona != zonaID {
+		return ErrQROtraZona
+	}
+	return nil
+}
+
+func (f *Firmador) firma(carga string) []byte {
+	mac := hmac.New(sha256.New, f.clave)
+	mac.Write([]byte(carga))
+	return mac.Sum(nil)[:16]
+}
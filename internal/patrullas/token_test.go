@@ -0,0 +1,114 @@
+package patrullas
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const (
+	eventoPrueba = "6f1c2a9e-0b7d-4e53-9a44-2f7d1c3b8e10"
+	zonaPrueba   = "acceso-norte"
+)
+
+func TestFirmadorVerificar(t *testing.T) {
+	f := NewFirmador("secreto-de-prueba", false)
+	token := f.Token(eventoPrueba, zonaPrueba)
+	carga, firma, _ := strings.Cut(strings.TrimPrefix(token, prefijoToken), ".")
+	conCarga := func(evento, zona string) string {
+		return prefijoToken + base64.RawURLEncoding.EncodeToString([]byte(evento+"\n"+zona)) + "." + firma
+	}
+
+	casos := []struct {
+		nombre string
+		token  string
+		valido bool
+	}{
+		{"recién firmado", token, true},
+		{"con espacios alrededor", "  " + token + "\n", true},
+		{"otra clave", NewFirmador("otro-secreto", false).Token(eventoPrueba, zonaPrueba), false},
+		{"sin prefijo", strings.TrimPrefix(token, prefijoToken), false},
+		{"otra versión", "EP2." + strings.TrimPrefix(token, prefijoToken), false},
+		{"sin firma", prefijoToken + carga, false},
+		{"firma vacía", prefijoToken + carga + ".", false},
+		{"firma truncada", token[:len(token)-4], false},
+		{"firma no canónica", noCanonica(token), false},
+		{"firma que no es base64", prefijoToken + carga + ".%%%", false},
+		{"carga truncada", prefijoToken + carga[:len(carga)-3] + "." + firma, false},
+		{"zona cambiada", conCarga(eventoPrueba, "vip"), false},
+		{"evento cambiado", conCarga("otro-evento", zonaPrueba), false},
+		{"vacío", "", false},
+		{"ID plano", zonaPrueba, false},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			ev, zona, err := f.Verificar(c.token)
+			if !c.valido {
+				if !errors.Is(err, ErrTokenInvalido) {
+					t.Fatalf("Verificar = (%q, %q, %v), esperado ErrTokenInvalido", ev, zona, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verificar: %v", err)
+			}
+			if ev != eventoPrueba || zona != zonaPrueba {
+				t.Errorf("Verificar = (%q, %q), esperado (%q, %q)", ev, zona, eventoPrueba, zonaPrueba)
+			}
+		})
+	}
+}
+
+// Una carga sin separador o con un lado vacío no es válida aunque esté bien firmada
+func TestFirmadorCargaIncompleta(t *testing.T) {
+	f := NewFirmador("secreto-de-prueba", false)
+	for _, datos := range []string{"solo-evento", "\n" + zonaPrueba, eventoPrueba + "\n"} {
+		carga := base64.RawURLEncoding.EncodeToString([]byte(datos))
+		token := prefijoToken + carga + "." + base64.RawURLEncoding.EncodeToString(f.firma(carga))
+		if _, _, err := f.Verificar(token); !errors.Is(err, ErrTokenInvalido) {
+			t.Errorf("Verificar(%q) = %v, esperado ErrTokenInvalido", datos, err)
+		}
+	}
+}
+
+func TestFirmadorZonaDe(t *testing.T) {
+	f := NewFirmador("secreto-de-prueba", false)
+	plano := NewFirmador("secreto-de-prueba", true)
+	token := f.Token(eventoPrueba, zonaPrueba)
+	alterado := []byte(token)
+	alterado[len(prefijoToken)+2] ^= 1
+
+	casos := []struct {
+		nombre    string
+		firmador  *Firmador
+		contenido string
+		evento    string
+		zona      string
+		esperado  error
+	}{
+		{"su zona", f, token, eventoPrueba, zonaPrueba, nil},
+		{"otra zona", f, token, eventoPrueba, "vip", ErrQROtraZona},
+		{"otro evento", f, token, "otro-evento", zonaPrueba, ErrQROtraZona},
+		{"alterado", f, string(alterado), eventoPrueba, zonaPrueba, ErrTokenInvalido},
+		{"ID plano sin transición", f, zonaPrueba, eventoPrueba, zonaPrueba, ErrTokenInvalido},
+		{"ID plano en transición", plano, zonaPrueba, eventoPrueba, zonaPrueba, nil},
+		{"ID plano de otra zona", plano, "vip", eventoPrueba, zonaPrueba, ErrTokenInvalido},
+		{"firmado sigue exigiendo la zona", plano, token, eventoPrueba, "vip", ErrQROtraZona},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			if err := c.firmador.ZonaDe(c.contenido, c.evento, c.zona); !errors.Is(err, c.esperado) {
+				t.Errorf("ZonaDe = %v, esperado %v", err, c.esperado)
+			}
+		})
+	}
+}
+
+// noCanonica cambia los bits de relleno del último carácter: la firma
+// decodifica a los mismos bytes, pero no es la escritura que emite Token
+func noCanonica(token string) string {
+	const alfabeto = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+	i := strings.IndexByte(alfabeto, token[len(token)-1])
+	return token[:len(token)-1] + string(alfabeto[i^1])
+}
@@ -0,0 +1,329 @@
+package patrullas
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/eventpulse/backend/internal/notificaciones"
+	"github.com/eventpulse/backend/internal/repository"
+	"github.com/eventpulse/backend/internal/ubicaciones"
+	"github.com/eventpulse/backend/internal/ws"
+)
+
+var (
+	ErrQROtroEvento = errors.New("el QR es de otro evento")
+	ErrZonaArchiva  = errors.New("la zona del QR no existe o está archivada")
+	ErrRutaInactiva = errors.New("la ruta no existe o no está activa")
+)
+
+// Vigilante registra los escaneos de QR, lleva las rondas en curso y avisa
+// a admins y supervisores cuando un guardia no pasa por un punto a tiempo.
+//
+// Cálculo de los tiempos: el punto k se espera IntervaloMin después de la
+// marca anterior (o del inicio de la ronda) y vence ToleranciaMin más tarde.
+// Si el punto se omite, el siguiente se cuenta desde lo esperado para el
+// omitido, así la ronda no se corre.
+type Vigilante struct {
+	repo          *repository.PatrullaRepo
+	zonaRepo      *repository.ZonaRepo
+	ubicacionRepo *repository.UbicacionRepo
+	rastreador    *ubicaciones.Rastreador
+	firmador      *Firmador
+	hub           *ws.Hub
+	notificador   *notificaciones.Notificador
+	revision      time.Duration
+}
+
+func NewVigilante(r *repository.PatrullaRepo, z *repository.ZonaRepo, u *repository.UbicacionRepo, ra *ubicaciones.Rastreador, f *Firmador, h *ws.Hub, n *notificaciones.Notificador, revision time.Duration) *Vigilante {
+	return &Vigilante{repo: r, zonaRepo: z, ubicacionRepo: u, rastreador: ra, firmador: f, hub: h, notificador: n, revision: revision}
+}
+
+// Checkin verifica el QR y registra el paso del usuario. Si tiene una ronda en
+// curso y la zona es uno de sus puntos pendientes, la ronda avanza. Con turno
+// abierto, el escaneo también actualiza su posición en el mapa.
+func (v *Vigilante) Checkin(ctx context.Context, usuarioID, eventoUsuario, token string) (*models.ResultadoCheckin, error) {
+	eventoID, zonaID, err := v.firmador.Verificar(token)
+	if err != nil {
+		return nil, err
+	}
+	if eventoUsuario != "" && eventoUsuario != eventoID {
+		return nil, ErrQROtroEvento
+	}
+	zona, err := v.zonaRepo.ObtenerPorID(ctx, zonaID, eventoID)
+	if err != nil {
+		return nil, err
+	}
+	if zona == nil || zona.ArchivadaEn != nil {
+		return nil, ErrZonaArchiva
+	}
+	checkin, err := v.repo.GuardarCheckin(ctx, eventoID, zonaID, usuarioID)
+	if err != nil {
+		return nil, err
+	}
+	res := &models.ResultadoCheckin{Checkin: *checkin}
+
+	if _, err := v.rastreador.Registrar(ctx, usuarioID, &models.UbicacionRequest{ZonaID: &zonaID, Fuente: models.FuenteQR}); err != nil && !errors.Is(err, ubicaciones.ErrSinTurno) {
+		log.Println("❌ Error registrando la ubicación del check-in:", err)
+	}
+
+	ronda, err := v.repo.RondaEnCurso(ctx, usuarioID)
+	if err != nil {
+		return nil, err
+	}
+	if ronda != nil && ronda.EventoID == eventoID {
+		if ronda, err = v.marcar(ctx, ronda, checkin); err != nil {
+			return nil, err
+		}
+		if ronda != nil && ronda.ID == derefStr(checkin.RondaID) {
+			res.Ronda = ronda
+		}
+	}
+	v.publicarAMando(ctx, eventoID, nil, models.EventoWS{Tipo: models.WSCheckin, Payload: res.Checkin, EventoID: eventoID})
+	return res, nil
+}
+
+// IniciarRonda arranca una pasada por la ruta; el primer punto se espera
+// IntervaloMin después de ahora
+func (v *Vigilante) IniciarRonda(ctx context.Context, usuarioID, eventoID, rutaID string) (*models.Ronda, error) {
+	ruta, err := v.repo.Ruta(ctx, rutaID)
+	if err != nil {
+		return nil, err
+	}
+	if ruta == nil || !ruta.Activa || ruta.EventoID != eventoID || len(ruta.Puntos) == 0 {
+		return nil, ErrRutaInactiva
+	}
+	vence := time.Now().Add(minutos(ruta.Puntos[0].IntervaloMin + ruta.ToleranciaMin))
+	ronda, err := v.repo.IniciarRonda(ctx, rutaID, eventoID, usuarioID, vence)
+	if err != nil {
+		return nil, err
+	}
+	v.publicarRonda(ctx, ronda)
+	return ronda, nil
+}
+
+// CancelarRonda la deja como cancelada, sin marcar omisiones
+func (v *Vigilante) CancelarRonda(ctx context.Context, usuarioID string) (*models.Ronda, error) {
+	ronda, err := v.repo.CancelarRonda(ctx, usuarioID)
+	if err != nil {
+		return nil, err
+	}
+	v.publicarRonda(ctx, ronda)
+	return ronda, nil
+}
+
+// Run revisa periódicamente las rondas con el punto siguiente vencido
+func (v *Vigilante) Run(ctx context.Context) {
+	ticker := time.NewTicker(v.revision)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			v.revisarVencidas(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (v *Vigilante) revisarVencidas(ctx context.Context) {
+	vencidas, err := v.repo.Vencidas(ctx)
+	if err != nil {
+		log.Println("❌ Error buscando rondas vencidas:", err)
+		return
+	}
+	for i := range vencidas {
+		if err := v.omitirVencidos(ctx, &vencidas[i]); err != nil {
+			log.Printf("❌ Error revisando la ronda %s: %v", vencidas[i].ID, err)
+		}
+	}
+}
+
+// omitirVencidos marca como omitidos los puntos cuya tolerancia ya pasó
+// (varios si el servidor estuvo caído) y avisa por cada uno
+func (v *Vigilante) omitirVencidos(ctx context.Context, ronda *models.Ronda) error {
+	ruta, err := v.repo.Ruta(ctx, ronda.RutaID)
+	if err != nil || ruta == nil {
+		return err
+	}
+	leido := ronda.Siguiente
+	tolerancia := minutos(ruta.ToleranciaMin)
+	ahora := time.Now()
+	var nuevas []models.MarcaRonda
+	for ronda.Estado == models.RondaEnCurso && ronda.VenceEn != nil && ronda.VenceEn.Before(ahora) {
+		p := punto(ruta, ronda.Siguiente)
+		if p == nil {
+			break
+		}
+		esperado := ronda.VenceEn.Add(-tolerancia)
+		nuevas = append(nuevas, models.MarcaRonda{Orden: p.Orden, ZonaID: p.ZonaID, Estado: models.MarcaOmitida, EsperadoEn: esperado})
+		avanzarA(ronda, ruta, p.Orden+1, esperado, true)
+	}
+	if len(nuevas) == 0 {
+		return nil
+	}
+	ok, err := v.repo.Avanzar(ctx, ronda, leido, nuevas, nil)
+	if err != nil || !ok {
+		return err // !ok: el guardia escaneó justo ahora; se revisa en la próxima pasada
+	}
+	ronda.Marcas = append(ronda.Marcas, nuevas...)
+	for _, m := range nuevas {
+		v.avisarOmision(ctx, ronda, m)
+	}
+	v.publicarRonda(ctx, ronda)
+	return nil
+}
+
+// marcar aplica el check-in a la ronda. Si la zona es un punto posterior al
+// siguiente, los intermedios quedan omitidos. Devuelve la ronda actualizada,
+// o la misma sin cambios si la zona no es un punto pendiente.
+func (v *Vigilante) marcar(ctx context.Context, ronda *models.Ronda, checkin *models.Checkin) (*models.Ronda, error) {
+	ruta, err := v.repo.Ruta(ctx, ronda.RutaID)
+	if err != nil || ruta == nil {
+		return ronda, err
+	}
+	destino := 0
+	for _, p := range ruta.Puntos {
+		if p.Orden >= ronda.Siguiente && p.ZonaID == checkin.ZonaID {
+			destino = p.Orden
+			break
+		}
+	}
+	if destino == 0 || ronda.VenceEn == nil {
+		return ronda, nil
+	}
+
+	leido := ronda.Siguiente
+	tolerancia := minutos(ruta.ToleranciaMin)
+	esperado := ronda.VenceEn.Add(-tolerancia)
+	var nuevas []models.MarcaRonda
+	for orden := ronda.Siguiente; orden < destino; orden++ {
+		p := punto(ruta, orden)
+		nuevas = append(nuevas, models.MarcaRonda{Orden: orden, ZonaID: p.ZonaID, Estado: models.MarcaOmitida, EsperadoEn: esperado})
+		esperado = esperado.Add(minutos(punto(ruta, orden+1).IntervaloMin))
+	}
+	marca := models.MarcaRonda{Orden: destino, ZonaID: checkin.ZonaID, Estado: models.MarcaATiempo, EsperadoEn: esperado, MarcadaEn: &checkin.RegistradoEn}
+	switch {
+	case checkin.RegistradoEn.After(esperado.Add(tolerancia)):
+		marca.Estado = models.MarcaOmitida // llegó después de vencido (el revisor aún no pasó)
+	case checkin.RegistradoEn.After(esperado):
+		marca.Estado = models.MarcaTarde
+	}
+	nuevas = append(nuevas, marca)
+	avanzarA(ronda, ruta, destino+1, checkin.RegistradoEn, hayOmision(ronda.Marcas, nuevas))
+
+	ok, err := v.repo.Avanzar(ctx, ronda, leido, nuevas, &checkin.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		// La movió el revisor en paralelo: se relee y se reintenta una vez
+		actual, err := v.repo.RondaEnCurso(ctx, checkin.UsuarioID)
+		if err != nil || actual == nil || actual.Siguiente == leido {
+			return actual, err
+		}
+		return v.marcar(ctx, actual, checkin)
+	}
+	checkin.RondaID = &ronda.ID
+	ronda.Marcas = append(ronda.Marcas, nuevas...)
+	for _, m := range nuevas {
+		if m.Estado == models.MarcaOmitida {
+			v.avisarOmision(ctx, ronda, m)
+		}
+	}
+	v.publicarRonda(ctx, ronda)
+	return ronda, nil
+}
+
+// avanzarA deja la ronda apuntando al punto orden, con el vencimiento
+// contado desde desde; si ya no quedan puntos la termina
+func avanzarA(ronda *models.Ronda, ruta *models.RutaPatrulla, orden int, desde time.Time, conOmisiones bool) {
+	ronda.Siguiente = orden
+	p := punto(ruta, orden)
+	if p == nil {
+		ronda.VenceEn = nil
+		ronda.Estado = models.RondaCompletada
+		if conOmisiones {
+			ronda.Estado = models.RondaIncompleta
+		}
+		return
+	}
+	vence := desde.Add(minutos(p.IntervaloMin + ruta.ToleranciaMin))
+	ronda.VenceEn = &vence
+}
+
+func hayOmision(listas ...[]models.MarcaRonda) bool {
+	for _, marcas := range listas {
+		for _, m := range marcas {
+			if m.Estado == models.MarcaOmitida {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func punto(ruta *models.RutaPatrulla, orden int) *models.PuntoRuta {
+	for i := range ruta.Puntos {
+		if ruta.Puntos[i].Orden == orden {
+			return &ruta.Puntos[i]
+		}
+	}
+	return nil
+}
+
+func (v *Vigilante) avisarOmision(ctx context.Context, ronda *models.Ronda, m models.MarcaRonda) {
+	aviso := models.PuntoOmitido{
+		RondaID:       ronda.ID,
+		RutaNombre:    ronda.RutaNombre,
+		UsuarioID:     ronda.UsuarioID,
+		NombreUsuario: ronda.NombreUsuario,
+		Orden:         m.Orden,
+		ZonaID:        m.ZonaID,
+		EsperadoEn:    m.EsperadoEn,
+	}
+	ids := v.publicarAMando(ctx, ronda.EventoID, nil, models.EventoWS{Tipo: models.WSRondaPuntoOmitido, Payload: aviso, EventoID: ronda.EventoID})
+	v.notificador.Notificar(ids, models.Notificacion{
+		Tipo:   models.NotifRonda,
+		Titulo: fmt.Sprintf("Ronda %s: %s no pasó por %s", ronda.RutaNombre, ronda.NombreUsuario, m.ZonaID),
+		Cuerpo: fmt.Sprintf("Punto %d, esperado a las %s", m.Orden, m.EsperadoEn.Format("15:04")),
+		Ref:    ronda.ID,
+	})
+}
+
+func (v *Vigilante) publicarRonda(ctx context.Context, ronda *models.Ronda) {
+	v.publicarAMando(ctx, ronda.EventoID, []string{ronda.UsuarioID}, models.EventoWS{Tipo: models.WSRondaActualizada, Payload: ronda, EventoID: ronda.EventoID})
+}
+
+// publicarAMando envía a admins y supervisores del evento, más los extra;
+// devuelve los destinatarios de mando
+func (v *Vigilante) publicarAMando(ctx context.Context, eventoID string, extra []string, evento models.EventoWS) []string {
+	mando, err := v.ubicacionRepo.IDsMando(ctx, eventoID)
+	if err != nil {
+		log.Println("❌ Error buscando destinatarios de patrullas:", err)
+		return nil
+	}
+	ids := append([]string{}, mando...)
+	for _, id := range extra {
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	if err := v.hub.PublicarAUsuarios(ctx, ids, evento); err != nil {
+		log.Printf("❌ Error publicando %s en Redis: %v", evento.Tipo, err)
+	}
+	return mando
+}
+
+func minutos(n int) time.Duration { return time.Duration(n) * time.Minute }
+
+func derefStr(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,156 @@
+package puente
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/eventpulse/backend/config"
+	"github.com/eventpulse/backend/internal/iot"
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/eventpulse/backend/internal/repository"
+)
+
+// QoS 1: el broker confirma cada mensaje; los duplicados ocasionales los
+// absorbe la ventana de deduplicación de los dispositivos.
+const qos = 1
+
+// MQTT conecta EventPulse con un broker en los dos sentidos:
+//   - entrada: lo que se publica en los temas configurados se atribuye al
+//     dispositivo con ese tema_mqtt y pasa por el mismo procesador que
+//     POST /iot/lecturas (o cambia el estado de una incidencia);
+//   - salida: cada EventoWS publicado a todo un evento se republica en
+//     <prefijo>/<evento>/<tipo>.
+type MQTT struct {
+	cfg        config.MQTTConfig
+	repo       dispositivosPorTema
+	procesador procesadorLecturas
+	cliente    paho.Client
+}
+
+// Lo que el puente usa del repositorio de dispositivos y del procesador IoT
+// (en producción, *repository.DispositivoRepo y *iot.Procesador)
+type dispositivosPorTema interface {
+	ObtenerPorTema(ctx context.Context, tema string) (*models.Dispositivo, error)
+}
+
+type procesadorLecturas interface {
+	Procesar(ctx context.Context, d *models.Dispositivo, crudo []byte) (*iot.Resultado, error)
+	Actualizar(ctx context.Context, d *models.Dispositivo, incidenciaID string, estado models.EstadoIncidencia) (*iot.Resultado, error)
+}
+
+func NewMQTT(cfg config.MQTTConfig, r *repository.DispositivoRepo, p *iot.Procesador) *MQTT {
+	return nuevoMQTT(cfg, r, p)
+}
+
+func nuevoMQTT(cfg config.MQTTConfig, r dispositivosPorTema, p procesadorLecturas) *MQTT {
+	m := &MQTT{cfg: cfg, repo: r, procesador: p}
+
+	opts := paho.NewClientOptions().
+		AddBroker(cfg.BrokerURL).
+		SetClientID(cfg.ClientID).
+		SetUsername(cfg.User).
+		SetPassword(cfg.Password).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(5 * time.Second).
+		SetMaxReconnectInterval(time.Minute).
+		// Cada mensaje entrante toca la base: que uno lento no frene al resto
+		SetOrderMatters(false).
+		SetOnConnectHandler(m.suscribir).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			log.Println("⚠️ MQTT: conexión perdida, reintentando:", err)
+		})
+	m.cliente = paho.NewClient(opts)
+	return m
+}
+
+// Run conecta (reintentando hasta que el broker responda) y se desconecta al
+// cancelar el contexto
+func (m *MQTT) Run(ctx context.Context) {
+	m.cliente.Connect()
+	<-ctx.Done()
+	m.cliente.Disconnect(250)
+}
+
+// suscribir corre en cada (re)conexión: el broker no guarda suscripciones de
+// sesiones limpias
+func (m *MQTT) suscribir(c paho.Client) {
+	log.Printf("✅ MQTT conectado a %s", m.cfg.BrokerURL)
+	if len(m.cfg.Temas) == 0 {
+		return
+	}
+	filtros := make(map[string]byte, len(m.cfg.Temas))
+	for _, t := range m.cfg.Temas {
+		filtros[t] = qos
+	}
+	tok := c.SubscribeMultiple(filtros, m.recibir)
+	go func() {
+		if tok.WaitTimeout(10*time.Second) && tok.Error() != nil {
+			log.Println("❌ MQTT: error suscribiendo:", tok.Error())
+		}
+	}()
+}
+
+// comandoIncidencia es el payload que cambia el estado de una incidencia
+// existente en vez de ser una lectura (ej: gateway de radio)
+type comandoIncidencia struct {
+	IncidenciaID string                  `json:"incidencia_id"`
+	Estado       models.EstadoIncidencia `json:"estado"`
+}
+
+func (m *MQTT) recibir(_ paho.Client, msg paho.Message) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	d, err := m.repo.ObtenerPorTema(ctx, msg.Topic())
+	if err != nil {
+		log.Printf("❌ MQTT: error buscando dispositivo de %s: %v", msg.Topic(), err)
+		return
+	}
+	if d == nil {
+		log.Printf("⚠️ MQTT: mensaje en %s sin dispositivo registrado", msg.Topic())
+		return
+	}
+
+	var cmd comandoIncidencia
+	_ = json.Unmarshal(msg.Payload(), &cmd) // si no es un comando, se trata como lectura
+	var res *iot.Resultado
+	if cmd.IncidenciaID != "" && cmd.Estado != "" {
+		res, err = m.procesador.Actualizar(ctx, d, cmd.IncidenciaID, cmd.Estado)
+	} else {
+		res, err = m.procesador.Procesar(ctx, d, msg.Payload())
+	}
+	switch {
+	case errors.Is(err, iot.ErrEventoInactivo), errors.Is(err, iot.ErrLecturaInvalida),
+		errors.Is(err, iot.ErrEstadoInvalido), errors.Is(err, repository.ErrNoEncontrado):
+		log.Printf("⚠️ MQTT: mensaje de %s descartado: %v", d.Nombre, err)
+	case err != nil:
+		log.Printf("❌ MQTT: error procesando mensaje de %s: %v", d.Nombre, err)
+	case res.Accion != iot.AccionIgnorada:
+		log.Printf("📡 MQTT: %s → %s", d.Nombre, res.Accion)
+	}
+}
+
+// Republicar es el observador de Hub.Publicar. Si el broker no está
+// disponible el evento no se encola: MQTT es un espejo en vivo, no un registro.
+func (m *MQTT) Republicar(_ context.Context, eventoID string, evento models.EventoWS) {
+	if !m.cliente.IsConnectionOpen() {
+		return
+	}
+	data, err := json.Marshal(evento)
+	if err != nil {
+		log.Printf("❌ MQTT: payload de %s inválido: %v", evento.Tipo, err)
+		return
+	}
+	tema := m.cfg.Prefijo + "/" + eventoID + "/" + string(evento.Tipo)
+	tok := m.cliente.Publish(tema, qos, false, data)
+	go func() {
+		if tok.WaitTimeout(10*time.Second) && tok.Error() != nil {
+			log.Printf("❌ MQTT: error publicando en %s: %v", tema, tok.Error())
+		}
+	}()
+}
@@ -0,0 +1,252 @@
+package puente
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eventpulse/backend/config"
+	"github.com/eventpulse/backend/internal/iot"
+	"github.com/eventpulse/backend/internal/models"
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+)
+
+// ─── Dobles del repositorio y el procesador ──────────────────────────────────
+
+type repoFalso struct {
+	mu           sync.Mutex
+	dispositivos map[string]*models.Dispositivo
+	buscados     []string
+}
+
+func (r *repoFalso) ObtenerPorTema(_ context.Context, tema string) (*models.Dispositivo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.buscados = append(r.buscados, tema)
+	return r.dispositivos[tema], nil
+}
+
+func (r *repoFalso) busco(tema string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, t := range r.buscados {
+		if t == tema {
+			return true
+		}
+	}
+	return false
+}
+
+// lectura es lo que le llegó al procesador: una lectura cruda o un comando
+type lectura struct {
+	dispositivo  string
+	crudo        string
+	incidenciaID string
+	estado       models.EstadoIncidencia
+}
+
+type procesadorFalso struct{ recibidas chan lectura }
+
+func (p *procesadorFalso) Procesar(_ context.Context, d *models.Dispositivo, crudo []byte) (*iot.Resultado, error) {
+	p.recibidas <- lectura{dispositivo: d.ID, crudo: string(crudo)}
+	return &iot.Resultado{Accion: iot.AccionCreada}, nil
+}
+
+func (p *procesadorFalso) Actualizar(_ context.Context, d *models.Dispositivo, incidenciaID string, estado models.EstadoIncidencia) (*iot.Resultado, error) {
+	p.recibidas <- lectura{dispositivo: d.ID, incidenciaID: incidenciaID, estado: estado}
+	return &iot.Resultado{Accion: iot.AccionActualizada}, nil
+}
+
+// ─── Broker embebido ──────────────────────────────────────────────────────────
+
+func direccionLibre(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+func nuevoBroker(t *testing.T, addr string) *mqtt.Server {
+	t.Helper()
+	b := mqtt.New(&mqtt.Options{
+		InlineClient: true, // para publicar y suscribirse desde el test
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	if err := b.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatal(err)
+	}
+	var err error
+	for intento := 0; intento < 50; intento++ { // al reiniciar, el puerto puede tardar en liberarse
+		if err = b.AddListener(listeners.NewTCP(listeners.Config{ID: "tcp", Address: addr})); err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("broker en %s: %v", addr, err)
+	}
+	if err := b.Serve(); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// publicarHasta publica en el broker cada 100 ms hasta que el procesador
+// recibe algo: el puente puede no estar suscrito todavía. Las copias que
+// lleguen después se descartan para no confundir al resto del test.
+func publicarHasta(t *testing.T, b *mqtt.Server, tema, payload string, recibidas <-chan lectura) lectura {
+	t.Helper()
+	limite := time.After(15 * time.Second)
+	tick := time.NewTicker(100 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		if err := b.Publish(tema, []byte(payload), false, qos); err != nil {
+			t.Fatalf("publicando en %s: %v", tema, err)
+		}
+		select {
+		case l := <-recibidas:
+			for {
+				select {
+				case <-recibidas:
+				case <-time.After(300 * time.Millisecond):
+					return l
+				}
+			}
+		case <-tick.C:
+		case <-limite:
+			t.Fatalf("el puente nunca recibió lo publicado en %s", tema)
+		}
+	}
+}
+
+func nuevoPuente(t *testing.T, addr string) (*MQTT, *repoFalso, *procesadorFalso) {
+	t.Helper()
+	repo := &repoFalso{dispositivos: map[string]*models.Dispositivo{
+		"sensores/humo-1": {ID: "d1", Nombre: "Humo escenario"},
+	}}
+	proc := &procesadorFalso{recibidas: make(chan lectura, 100)}
+	m := nuevoMQTT(config.MQTTConfig{
+		BrokerURL: "tcp://" + addr,
+		ClientID:  "eventpulse-test",
+		Prefijo:   "eventpulse",
+		Temas:     []string{"sensores/#"},
+	}, repo, proc)
+	ctx, cancel := context.WithCancel(context.Background())
+	hecho := make(chan struct{})
+	go func() {
+		m.Run(ctx)
+		close(hecho)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-hecho
+	})
+	return m, repo, proc
+}
+
+// ─── Tests ────────────────────────────────────────────────────────────────────
+
+func TestMQTTEntrada(t *testing.T) {
+	addr := direccionLibre(t)
+	b := nuevoBroker(t, addr)
+	defer b.Close()
+	_, repo, proc := nuevoPuente(t, addr)
+
+	// Una lectura del dispositivo llega al procesador con su payload tal cual
+	l := publicarHasta(t, b, "sensores/humo-1", `{"humo":0.9}`, proc.recibidas)
+	if l.dispositivo != "d1" || l.crudo != `{"humo":0.9}` {
+		t.Errorf("lectura = %+v", l)
+	}
+	// Un comando de incidencia cambia su estado en vez de ser una lectura
+	if err := b.Publish("sensores/humo-1", []byte(`{"incidencia_id":"i1","estado":"resuelta"}`), false, qos); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case l := <-proc.recibidas:
+		if l.dispositivo != "d1" || l.incidenciaID != "i1" || l.estado != models.IncidenciaResuelta {
+			t.Errorf("comando = %+v", l)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("el comando no llegó al procesador")
+	}
+	// Un tema sin dispositivo se busca pero no se procesa
+	if err := b.Publish("sensores/desconocido", []byte(`{"humo":1}`), false, qos); err != nil {
+		t.Fatal(err)
+	}
+	limite := time.Now().Add(5 * time.Second)
+	for !repo.busco("sensores/desconocido") {
+		if time.Now().After(limite) {
+			t.Fatal("no se buscó el dispositivo del tema desconocido")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	select {
+	case l := <-proc.recibidas:
+		t.Errorf("se procesó un mensaje sin dispositivo: %+v", l)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestMQTTRepublicar(t *testing.T) {
+	addr := direccionLibre(t)
+	b := nuevoBroker(t, addr)
+	defer b.Close()
+	m, _, proc := nuevoPuente(t, addr)
+	publicarHasta(t, b, "sensores/humo-1", `{}`, proc.recibidas) // ya conectado
+
+	type publicado struct {
+		tema    string
+		payload []byte
+	}
+	espejo := make(chan publicado, 10)
+	err := b.Subscribe("eventpulse/#", 1, func(_ *mqtt.Client, _ packets.Subscription, pk packets.Packet) {
+		espejo <- publicado{tema: pk.TopicName, payload: pk.Payload}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Republicar es el observador que main registra con Hub.AlPublicar
+	evento := models.EventoWS{Tipo: models.WSIncidenciaNueva, EventoID: "ev1", Payload: map[string]any{"id": "i9", "zona_id": "sector-c"}}
+	m.Republicar(context.Background(), "ev1", evento)
+	select {
+	case p := <-espejo:
+		if p.tema != "eventpulse/ev1/incidencia_nueva" {
+			t.Errorf("tema = %q", p.tema)
+		}
+		esperado, _ := json.Marshal(evento)
+		if string(p.payload) != string(esperado) {
+			t.Errorf("payload = %s, esperado %s", p.payload, esperado)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("el evento no se republicó en el broker")
+	}
+}
+
+func TestMQTTResuscribeAlReconectar(t *testing.T) {
+	addr := direccionLibre(t)
+	b := nuevoBroker(t, addr)
+	_, _, proc := nuevoPuente(t, addr)
+	publicarHasta(t, b, "sensores/humo-1", `{"antes":true}`, proc.recibidas)
+
+	// Se reinicia el broker: la sesión y sus suscripciones se pierden
+	b.Close()
+	b = nuevoBroker(t, addr)
+	defer b.Close()
+
+	l := publicarHasta(t, b, "sensores/humo-1", `{"despues":true}`, proc.recibidas)
+	if l.crudo != `{"despues":true}` {
+		t.Errorf("lectura = %+v", l)
+	}
+}
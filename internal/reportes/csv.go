@@ -0,0 +1,122 @@
+package reportes
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eventpulse/backend/internal/models"
+)
+
+// ZIP con un CSV por tabla del reporte. Los números van sin formato y las
+// horas en RFC 3339, para abrirlos directo en una planilla.
+func ZIP(d *models.DatosReporte) ([]byte, error) {
+	var buf bytes.Buffer
+	z := zip.NewWriter(&buf)
+
+	fmtIncidencias := func(f models.FilaIncidencias, nombre bool) []string {
+		fila := []string{f.Clave}
+		if nombre {
+			fila = append(fila, texto(f.Nombre))
+		}
+		return append(fila, strconv.Itoa(f.Total), strconv.Itoa(f.Resueltas),
+			decimal(f.AtencionPromedioMin), decimal(f.ResolucionPromedioMin))
+	}
+	cabecera := []string{"total", "resueltas", "atencion_promedio_min", "resolucion_promedio_min"}
+
+	resumen := [][]string{
+		{"campo", "valor"},
+		{"evento_id", d.Evento.ID},
+		{"evento", d.Evento.Nombre},
+		{"estado", string(d.Evento.Estado)},
+		{"iniciado_en", hora(d.Evento.IniciadoEn)},
+		{"terminado_en", hora(d.Evento.TerminadoEn)},
+		{"generado_en", d.GeneradoEn.Format(time.RFC3339)},
+		{"incidencias", strconv.Itoa(d.Incidencias.Total)},
+		{"incidencias_resueltas", strconv.Itoa(d.Incidencias.Resueltas)},
+		{"atencion_promedio_min", decimal(d.Incidencias.AtencionPromedioMin)},
+		{"resolucion_promedio_min", decimal(d.Incidencias.ResolucionPromedioMin)},
+	}
+	porTipo := [][]string{append([]string{"tipo"}, cabecera...)}
+	for _, f := range d.PorTipo {
+		porTipo = append(porTipo, fmtIncidencias(f, false))
+	}
+	porZona := [][]string{append([]string{"zona_id", "zona"}, cabecera...)}
+	for _, f := range d.PorZona {
+		porZona = append(porZona, fmtIncidencias(f, true))
+	}
+	tareas := [][]string{{"rol", "total", "completadas", "tasa_pct"}}
+	for _, f := range d.TareasRol {
+		tareas = append(tareas, []string{f.Rol, strconv.Itoa(f.Total), strconv.Itoa(f.Completadas),
+			strconv.FormatFloat(f.TasaPct(), 'f', 1, 64)})
+	}
+	personal := [][]string{{"usuario_id", "nombre", "rol", "incidencias_asignadas", "incidencias_resueltas",
+		"tareas_asignadas", "tareas_completadas", "mensajes", "checkins", "horas_turno"}}
+	for _, p := range d.Personal {
+		personal = append(personal, []string{p.UsuarioID, p.Nombre, string(p.Rol),
+			strconv.Itoa(p.IncidenciasAsignadas), strconv.Itoa(p.IncidenciasResueltas),
+			strconv.Itoa(p.TareasAsignadas), strconv.Itoa(p.TareasCompletadas),
+			strconv.Itoa(p.Mensajes), strconv.Itoa(p.Checkins), strconv.FormatFloat(p.HorasTurno, 'f', 1, 64)})
+	}
+	evidencias := [][]string{{"tarea_id", "titulo", "zona_id", "estado", "requeridas", "fotos", "notas", "qr", "gps"}}
+	for _, e := range d.Evidencias {
+		evidencias = append(evidencias, []string{e.TareaID, e.Titulo, texto(e.ZonaID), e.Estado,
+			strings.Join(e.Requeridas, ";"), strconv.Itoa(e.Fotos), strconv.Itoa(e.Notas),
+			strconv.Itoa(e.QRs), strconv.Itoa(e.GPS)})
+	}
+	chat := [][]string{{"hora", "mensajes"}}
+	for _, p := range d.ChatPorHora {
+		chat = append(chat, []string{p.Hora.Format(time.RFC3339), strconv.Itoa(p.Mensajes)})
+	}
+
+	archivos := []struct {
+		nombre string
+		filas  [][]string
+	}{
+		{"resumen.csv", resumen},
+		{"incidencias_por_tipo.csv", porTipo},
+		{"incidencias_por_zona.csv", porZona},
+		{"tareas_por_rol.csv", tareas},
+		{"personal.csv", personal},
+		{"evidencias_por_tarea.csv", evidencias},
+		{"chat_por_hora.csv", chat},
+	}
+	for _, a := range archivos {
+		w, err := z.CreateHeader(&zip.FileHeader{Name: a.nombre, Method: zip.Deflate, Modified: d.GeneradoEn})
+		if err != nil {
+			return nil, err
+		}
+		cw := csv.NewWriter(w)
+		if err := cw.WriteAll(a.filas); err != nil {
+			return nil, err
+		}
+	}
+	if err := z.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decimal(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', 1, 64)
+}
+
+func texto(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func hora(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
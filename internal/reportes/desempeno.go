@@ -0,0 +1,35 @@
+package reportes
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strconv"
+
+	"github.com/eventpulse/backend/internal/models"
+)
+
+// DesempenoCSV: una fila por persona, con el mismo formato que los CSV del
+// reporte del evento
+func DesempenoCSV(lista []models.DesempenoTrabajador) ([]byte, error) {
+	filas := [][]string{{"usuario_id", "nombre", "rol", "incidencias_abiertas", "tareas_abiertas",
+		"incidencias_resueltas", "tareas_completadas", "aceptacion_promedio_min", "resolucion_promedio_min",
+		"horas_turno", "horas_activo", "ocupacion_pct"}}
+	for _, d := range lista {
+		filas = append(filas, []string{d.UsuarioID, d.Nombre, string(d.Rol),
+			strconv.Itoa(d.IncidenciasAbiertas), strconv.Itoa(d.TareasAbiertas),
+			strconv.Itoa(d.IncidenciasResueltas), strconv.Itoa(d.TareasCompletadas),
+			decimal(d.AceptacionPromedioMin), decimal(d.ResolucionPromedioMin),
+			strconv.FormatFloat(d.HorasTurno, 'f', 1, 64), strconv.FormatFloat(d.HorasActivo, 'f', 1, 64),
+			decimal(d.OcupacionPct)})
+	}
+	var buf bytes.Buffer
+	if err := csv.NewWriter(&buf).WriteAll(filas); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// NombreDesempeno: nombre del CSV para descargar
+func NombreDesempeno(evento string) string {
+	return "desempeno-" + slug(evento) + ".csv"
+}
@@ -0,0 +1,139 @@
+package reportes
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/eventpulse/backend/internal/repository"
+)
+
+const (
+	// intentos antes de dar un reporte por fallido
+	maxIntentos = 3
+	// generando por más de esto: la instancia que lo tomó se cayó
+	abandono = 10 * time.Minute
+)
+
+// Generador arma los reportes pedidos en segundo plano: lee los datos, los
+// vuelca al formato pedido y guarda el archivo para bajarlo después
+type Generador struct {
+	repo      *repository.ReporteRepo
+	revision  time.Duration
+	retencion int // días; 0 = no se borran
+}
+
+func NewGenerador(r *repository.ReporteRepo, revision time.Duration, retencionDias int) *Generador {
+	return &Generador{repo: r, revision: revision, retencion: retencionDias}
+}
+
+// Solicitar encola el reporte y lo empieza enseguida
+func (g *Generador) Solicitar(ctx context.Context, eventoID string, formato models.FormatoReporte, usuarioID string) (*models.ReporteEvento, error) {
+	rep, err := g.repo.Crear(ctx, eventoID, formato, usuarioID)
+	if err != nil {
+		return nil, err
+	}
+	if rep.Estado == models.ReportePendiente {
+		go g.procesar(context.Background())
+	}
+	return rep, nil
+}
+
+// Run retoma los pendientes (reintentos, abandonados) y purga los vencidos
+func (g *Generador) Run(ctx context.Context) {
+	revision := time.NewTicker(g.revision)
+	defer revision.Stop()
+	purga := time.NewTicker(time.Hour)
+	defer purga.Stop()
+	for {
+		select {
+		case <-revision.C:
+			g.procesar(ctx)
+		case <-purga.C:
+			if g.retencion <= 0 {
+				continue
+			}
+			n, err := g.repo.Purgar(ctx, g.retencion)
+			if err != nil {
+				log.Println("❌ Error purgando reportes:", err)
+			} else if n > 0 {
+				log.Printf("🧹 %d reportes de más de %d días borrados", n, g.retencion)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (g *Generador) procesar(ctx context.Context) {
+	for {
+		rep, err := g.repo.Tomar(ctx, abandono)
+		if err != nil {
+			log.Println("❌ Error tomando un reporte pendiente:", err)
+			return
+		}
+		if rep == nil {
+			return
+		}
+		if err := g.generar(ctx, rep); err != nil {
+			log.Printf("❌ Error generando el reporte %s: %v", rep.ID, err)
+			if err := g.repo.MarcarFallo(ctx, rep.ID, err, maxIntentos); err != nil {
+				log.Println("❌ Error registrando el fallo del reporte:", err)
+			}
+			// Lo que falló vuelve a pendiente; se reintenta en la próxima revisión
+			return
+		}
+	}
+}
+
+func (g *Generador) generar(ctx context.Context, rep *models.ReporteEvento) error {
+	datos, err := g.repo.Datos(ctx, rep.EventoID)
+	if err != nil {
+		return err
+	}
+	var archivo []byte
+	nombre := "reporte-" + slug(datos.Evento.Nombre)
+	switch rep.Formato {
+	case models.FormatoPDF:
+		archivo, err = PDF(datos)
+		nombre += ".pdf"
+	case models.FormatoCSV:
+		archivo, err = ZIP(datos)
+		nombre += ".zip"
+	default:
+		err = fmt.Errorf("formato %q no soportado", rep.Formato)
+	}
+	if err != nil {
+		return err
+	}
+	if err := g.repo.Guardar(ctx, rep.ID, archivo, nombre); err != nil {
+		return err
+	}
+	log.Printf("📄 Reporte %s de %s listo (%d bytes)", rep.Formato, datos.Evento.Nombre, len(archivo))
+	return nil
+}
+
+// MIME del archivo según el formato
+func MIME(f models.FormatoReporte) string {
+	if f == models.FormatoPDF {
+		return "application/pdf"
+	}
+	return "application/zip"
+}
+
+var noSlug = regexp.MustCompile(`[^a-z0-9]+`)
+
+// slug para el nombre del archivo: sin acentos ni espacios
+func slug(s string) string {
+	s = strings.NewReplacer("á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u", "ü", "u", "ñ", "n").
+		Replace(strings.ToLower(s))
+	s = strings.Trim(noSlug.ReplaceAllString(s, "-"), "-")
+	if s == "" {
+		return "evento"
+	}
+	return s
+}
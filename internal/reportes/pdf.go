@@ -0,0 +1,265 @@
+package reportes
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/jung-kurt/gofpdf"
+)
+
+const (
+	margen     = 12.0
+	anchoUtil  = 210 - 2*margen
+	altoPagina = 297.0
+	altoFila   = 6.0
+)
+
+type columna struct {
+	titulo string
+	ancho  float64 // mm
+	alinea string  // L | C | R
+}
+
+// hoja envuelve el PDF con el traductor de acentos y cortes de página que
+// repiten la cabecera de la tabla
+type hoja struct {
+	pdf *gofpdf.Fpdf
+	tr  func(string) string
+}
+
+// PDF A4 con el resumen del evento: incidencias por tipo y por zona con sus
+// tiempos, tareas por rol, actividad del personal y mensajes por hora
+func PDF(d *models.DatosReporte) ([]byte, error) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle("Reporte - "+d.Evento.Nombre, true)
+	pdf.SetMargins(margen, margen, margen)
+	pdf.SetAutoPageBreak(false, 0)
+	h := &hoja{pdf: pdf, tr: pdf.UnicodeTranslatorFromDescriptor("")}
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-10)
+		pdf.SetFont("Helvetica", "", 7)
+		pdf.SetTextColor(120, 120, 120)
+		pdf.CellFormat(0, 4, h.tr(fmt.Sprintf("%s · generado %s · página %d",
+			d.Evento.Nombre, d.GeneradoEn.Format("02/01/2006 15:04"), pdf.PageNo())), "", 0, "C", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+	})
+	pdf.AddPage()
+
+	pdf.SetFont("Helvetica", "B", 18)
+	pdf.CellFormat(0, 10, h.tr(d.Evento.Nombre), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	periodo := "Sin iniciar"
+	if d.Evento.IniciadoEn != nil {
+		periodo = "Desde " + d.Evento.IniciadoEn.Format("02/01/2006 15:04")
+		if d.Evento.TerminadoEn != nil {
+			periodo += " hasta " + d.Evento.TerminadoEn.Format("02/01/2006 15:04")
+		}
+	}
+	pdf.CellFormat(0, 6, h.tr(periodo+" · estado: "+string(d.Evento.Estado)), "", 1, "L", false, 0, "")
+	pdf.Ln(3)
+
+	inc := d.Incidencias
+	h.cifras([][2]string{
+		{"Incidencias", strconv.Itoa(inc.Total)},
+		{"Resueltas", strconv.Itoa(inc.Resueltas)},
+		{"Atención promedio", minutos(inc.AtencionPromedioMin)},
+		{"Resolución promedio", minutos(inc.ResolucionPromedioMin)},
+	})
+
+	colsIncidencias := func(primera columna) []columna {
+		return []columna{primera, {"Total", 22, "R"}, {"Resueltas", 24, "R"}, {"Atención", 30, "R"}, {"Resolución", 30, "R"}}
+	}
+	filas := [][]string{}
+	for _, f := range d.PorTipo {
+		filas = append(filas, []string{f.Clave, strconv.Itoa(f.Total), strconv.Itoa(f.Resueltas),
+			minutos(f.AtencionPromedioMin), minutos(f.ResolucionPromedioMin)})
+	}
+	h.tabla("Incidencias por tipo", colsIncidencias(columna{"Tipo", anchoUtil - 106, "L"}), filas)
+
+	filas = [][]string{}
+	for _, f := range d.PorZona {
+		zona := f.Clave
+		if f.Nombre != nil {
+			zona = *f.Nombre + " (" + f.Clave + ")"
+		}
+		filas = append(filas, []string{zona, strconv.Itoa(f.Total), strconv.Itoa(f.Resueltas),
+			minutos(f.AtencionPromedioMin), minutos(f.ResolucionPromedioMin)})
+	}
+	h.tabla("Incidencias por zona", colsIncidencias(columna{"Zona", anchoUtil - 106, "L"}), filas)
+
+	filas = [][]string{}
+	for _, f := range d.TareasRol {
+		filas = append(filas, []string{f.Rol, strconv.Itoa(f.Total), strconv.Itoa(f.Completadas),
+			strconv.FormatFloat(f.TasaPct(), 'f', 0, 64) + " %"})
+	}
+	h.tabla("Tareas por rol", []columna{{"Rol", anchoUtil - 84, "L"}, {"Total", 28, "R"}, {"Completadas", 28, "R"}, {"Tasa", 28, "R"}}, filas)
+
+	filas = [][]string{}
+	for _, p := range d.Personal {
+		filas = append(filas, []string{p.Nombre, string(p.Rol),
+			fmt.Sprintf("%d / %d", p.IncidenciasResueltas, p.IncidenciasAsignadas),
+			fmt.Sprintf("%d / %d", p.TareasCompletadas, p.TareasAsignadas),
+			strconv.Itoa(p.Mensajes), strconv.Itoa(p.Checkins), strconv.FormatFloat(p.HorasTurno, 'f', 1, 64)})
+	}
+	h.tabla("Actividad del personal", []columna{
+		{"Nombre", anchoUtil - 142, "L"}, {"Rol", 24, "L"}, {"Incid. res./asig.", 26, "R"},
+		{"Tareas comp./asig.", 28, "R"}, {"Mensajes", 20, "R"}, {"Check-ins", 20, "R"}, {"Horas turno", 24, "R"},
+	}, filas)
+
+	filas = [][]string{}
+	for _, e := range d.Evidencias {
+		requeridas := "—"
+		if len(e.Requeridas) > 0 {
+			requeridas = strings.Join(e.Requeridas, ", ")
+		}
+		filas = append(filas, []string{e.Titulo, e.Estado, requeridas,
+			strconv.Itoa(e.Fotos), strconv.Itoa(e.Notas), strconv.Itoa(e.QRs), strconv.Itoa(e.GPS)})
+	}
+	h.tabla("Evidencias por tarea", []columna{
+		{"Tarea", anchoUtil - 140, "L"}, {"Estado", 24, "L"}, {"Requeridas", 36, "L"},
+		{"Fotos", 20, "R"}, {"Notas", 20, "R"}, {"QR", 20, "R"}, {"GPS", 20, "R"},
+	}, filas)
+
+	h.chat(d.ChatPorHora)
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// espacio: salta de página si no entra alto
+func (h *hoja) espacio(alto float64) bool {
+	if h.pdf.GetY()+alto > altoPagina-margen-6 {
+		h.pdf.AddPage()
+		return true
+	}
+	return false
+}
+
+func (h *hoja) titulo(s string) {
+	h.espacio(20)
+	h.pdf.Ln(4)
+	h.pdf.SetFont("Helvetica", "B", 12)
+	h.pdf.CellFormat(0, 8, h.tr(s), "", 1, "L", false, 0, "")
+}
+
+// cifras: recuadros con los números principales en una fila
+func (h *hoja) cifras(pares [][2]string) {
+	ancho := anchoUtil / float64(len(pares))
+	x, y := h.pdf.GetX(), h.pdf.GetY()
+	h.pdf.SetFillColor(240, 240, 240)
+	for i, p := range pares {
+		h.pdf.Rect(x+float64(i)*ancho+1, y, ancho-2, 18, "F")
+		h.pdf.SetXY(x+float64(i)*ancho+1, y+2)
+		h.pdf.SetFont("Helvetica", "B", 14)
+		h.pdf.CellFormat(ancho-2, 8, h.tr(p[1]), "", 0, "C", false, 0, "")
+		h.pdf.SetXY(x+float64(i)*ancho+1, y+10)
+		h.pdf.SetFont("Helvetica", "", 8)
+		h.pdf.CellFormat(ancho-2, 5, h.tr(p[0]), "", 0, "C", false, 0, "")
+	}
+	h.pdf.SetXY(x, y+20)
+}
+
+func (h *hoja) tabla(titulo string, cols []columna, filas [][]string) {
+	h.titulo(titulo)
+	cabecera := func() {
+		h.pdf.SetFont("Helvetica", "B", 8)
+		h.pdf.SetFillColor(225, 225, 225)
+		for _, c := range cols {
+			h.pdf.CellFormat(c.ancho, altoFila, h.tr(c.titulo), "B", 0, c.alinea, true, 0, "")
+		}
+		h.pdf.Ln(-1)
+		h.pdf.SetFont("Helvetica", "", 8)
+	}
+	cabecera()
+	if len(filas) == 0 {
+		h.pdf.SetFont("Helvetica", "I", 8)
+		h.pdf.CellFormat(0, altoFila, "Sin datos", "", 1, "L", false, 0, "")
+		return
+	}
+	for i, fila := range filas {
+		if h.espacio(altoFila) {
+			cabecera()
+		}
+		h.pdf.SetFillColor(248, 248, 248)
+		for j, c := range cols {
+			h.pdf.CellFormat(c.ancho, altoFila, h.tr(recortar(h.pdf, fila[j], c.ancho-2)), "", 0, c.alinea, i%2 == 1, 0, "")
+		}
+		h.pdf.Ln(-1)
+	}
+}
+
+// chat: barras de mensajes por hora
+func (h *hoja) chat(serie []models.PuntoChat) {
+	h.titulo("Mensajes de chat por hora")
+	if len(serie) == 0 {
+		h.pdf.SetFont("Helvetica", "I", 8)
+		h.pdf.CellFormat(0, altoFila, "Sin mensajes", "", 1, "L", false, 0, "")
+		return
+	}
+	const alto = 50.0
+	h.espacio(alto + 12)
+	maximo := 1
+	for _, p := range serie {
+		if p.Mensajes > maximo {
+			maximo = p.Mensajes
+		}
+	}
+	x0, y0 := margen+10, h.pdf.GetY()+2
+	ancho := (anchoUtil - 10) / float64(len(serie))
+	h.pdf.SetFont("Helvetica", "", 6)
+	h.pdf.SetDrawColor(180, 180, 180)
+	h.pdf.Line(x0, y0+alto, x0+anchoUtil-10, y0+alto)
+	h.pdf.SetXY(margen, y0-1)
+	h.pdf.CellFormat(9, 3, strconv.Itoa(maximo), "", 0, "R", false, 0, "")
+	h.pdf.SetFillColor(70, 110, 180)
+	// Con muchas horas no entran todas las etiquetas
+	cada := 1 + len(serie)/24
+	for i, p := range serie {
+		barra := alto * float64(p.Mensajes) / float64(maximo)
+		x := x0 + float64(i)*ancho
+		if barra > 0 {
+			h.pdf.Rect(x+ancho*0.15, y0+alto-barra, ancho*0.7, barra, "F")
+		}
+		if i%cada == 0 {
+			h.pdf.SetXY(x-2, y0+alto+1)
+			h.pdf.CellFormat(ancho*float64(cada)+4, 3, etiquetaHora(p.Hora, i == 0), "", 0, "L", false, 0, "")
+		}
+	}
+	h.pdf.SetXY(margen, y0+alto+8)
+}
+
+func etiquetaHora(t time.Time, primera bool) string {
+	if primera || t.Hour() == 0 {
+		return t.Format("02/01 15h")
+	}
+	return t.Format("15h")
+}
+
+func minutos(v *float64) string {
+	if v == nil {
+		return "—"
+	}
+	if *v >= 120 {
+		return fmt.Sprintf("%.1f h", *v/60)
+	}
+	return fmt.Sprintf("%.0f min", *v)
+}
+
+// recortar corta el texto con "…" si no entra en el ancho
+func recortar(pdf *gofpdf.Fpdf, s string, ancho float64) string {
+	if pdf.GetStringWidth(s) <= ancho {
+		return s
+	}
+	r := []rune(s)
+	for len(r) > 1 && pdf.GetStringWidth(string(r)+"...") > ancho {
+		r = r[:len(r)-1]
+	}
+	return string(r) + "..."
+}
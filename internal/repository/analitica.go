@@ -0,0 +1,282 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ─── Analítica de incidencias ─────────────────────────────────────────────────
+
+type AnaliticaRepo struct{ db *sqlx.DB }
+
+func NewAnaliticaRepo(db *sqlx.DB) *AnaliticaRepo { return &AnaliticaRepo{db: db} }
+
+// baseAnalitica: conteos por evento, zona, tipo y hora UTC con los filtros
+// $1 eventos, $2 desde, $3 hasta y $4 tipo. Las horas anteriores al último
+// refresco salen de mv_incidencias_hora y el resto de incidencias. Si desde
+// esa hora alguna incidencia cambió de zona, tipo o evento (027), la vista ya
+// no sirve y se cuenta todo de la tabla hasta el siguiente refresco.
+const baseAnalitica = `
+	corte AS (
+		SELECT COALESCE(MAX(CASE
+			WHEN invalidada_en >= date_trunc('hour', refrescada_en, 'UTC') THEN '-infinity'
+			ELSE date_trunc('hour', refrescada_en, 'UTC')
+		END), '-infinity')::timestamptz AS t
+		FROM analitica_refrescos WHERE vista = 'mv_incidencias_hora'
+	),
+	base AS (
+		SELECT m.evento_id, m.zona_id, m.tipo, m.hora, m.total
+		FROM mv_incidencias_hora m, corte WHERE m.hora < corte.t
+		UNION ALL
+		SELECT i.evento_id, i.zona_id, i.tipo, date_trunc('hour', i.creada_en, 'UTC'), COUNT(*)::int
+		FROM incidencias i, corte WHERE i.creada_en >= corte.t
+		GROUP BY 1, 2, 3, 4
+	),
+	filtrada AS (
+		SELECT * FROM base
+		WHERE ($1::uuid[] IS NULL OR evento_id = ANY($1::uuid[]))
+		  AND ($2::timestamptz IS NULL OR hora >= date_trunc('hour', $2::timestamptz, 'UTC'))
+		  AND ($3::timestamptz IS NULL OR hora < $3::timestamptz)
+		  AND ($4 = '' OR tipo = $4)
+	)`
+
+func argsAnalitica(f models.FiltroAnalitica) []interface{} {
+	var eventos interface{}
+	if len(f.Eventos) > 0 {
+		eventos = pq.Array(f.Eventos)
+	}
+	return []interface{}{eventos, f.Desde, f.Hasta, f.Tipo}
+}
+
+// Refrescar rehace la vista si nadie lo hizo en el último intervalo. Con
+// varias instancias refresca una sola; REFRESH … CONCURRENTLY no bloquea
+// las lecturas mientras tanto.
+func (r *AnaliticaRepo) Refrescar(ctx context.Context, intervalo time.Duration) (bool, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var toca bool
+	err = tx.GetContext(ctx, &toca, `
+		SELECT pg_try_advisory_xact_lock(hashtext('mv_incidencias_hora'))
+		   AND NOT EXISTS (
+			SELECT 1 FROM analitica_refrescos
+			WHERE vista = 'mv_incidencias_hora' AND refrescada_en > NOW() - make_interval(secs => $1)
+		)
+	`, intervalo.Seconds()*0.9)
+	if err != nil || !toca {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, `REFRESH MATERIALIZED VIEW CONCURRENTLY mv_incidencias_hora`); err != nil {
+		return false, err
+	}
+	// NOW() es el inicio de la transacción: lo creado antes ya está en la vista
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO analitica_refrescos (vista, refrescada_en) VALUES ('mv_incidencias_hora', NOW())
+		ON CONFLICT (vista) DO UPDATE SET refrescada_en = EXCLUDED.refrescada_en
+	`)
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// ZonasHoras arma el mapa de calor zona × hora del día. Devuelve también la
+// versión más reciente de cada zona (nombre y geometría) entre los eventos
+// filtrados.
+func (r *AnaliticaRepo) ZonasHoras(ctx context.Context, f models.FiltroAnalitica) (*models.AnaliticaZonasHoras, []models.Zona, error) {
+	var celdas []models.ConteoZonaHora
+	err := r.db.SelectContext(ctx, &celdas, `WITH `+baseAnalitica+`
+		SELECT zona_id, EXTRACT(HOUR FROM hora AT TIME ZONE $5)::int AS hora, SUM(total)::int AS total
+		FROM filtrada
+		GROUP BY 1, 2
+	`, append(argsAnalitica(f), f.ZonaHoraria)...)
+	if err != nil {
+		return nil, nil, err
+	}
+	res := &models.AnaliticaZonasHoras{ZonaHoraria: f.ZonaHoraria, Zonas: []models.ZonaPorHora{}}
+	idx := map[string]int{}
+	ids := []string{}
+	for _, c := range celdas {
+		i, ok := idx[c.ZonaID]
+		if !ok {
+			i = len(res.Zonas)
+			idx[c.ZonaID] = i
+			ids = append(ids, c.ZonaID)
+			res.Zonas = append(res.Zonas, models.ZonaPorHora{ZonaID: c.ZonaID})
+		}
+		res.Zonas[i].Total += c.Total
+		res.Zonas[i].PorHora[c.Hora] += c.Total
+		res.Total += c.Total
+		res.PorHora[c.Hora] += c.Total
+	}
+
+	zonas := []models.Zona{}
+	if len(ids) > 0 {
+		var eventos interface{}
+		if len(f.Eventos) > 0 {
+			eventos = pq.Array(f.Eventos)
+		}
+		err = r.db.SelectContext(ctx, &zonas, `
+			SELECT `+columnasZona+` FROM (
+				SELECT DISTINCT ON (z.id) z.*
+				FROM zonas z JOIN eventos e ON e.id = z.evento_id
+				WHERE z.id = ANY($1) AND ($2::uuid[] IS NULL OR z.evento_id = ANY($2::uuid[]))
+				ORDER BY z.id, e.creado_en DESC
+			) z
+		`, pq.Array(ids), eventos)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	for i := range zonas {
+		if j, ok := idx[zonas[i].ID]; ok {
+			res.Zonas[j].ZonaNombre = &zonas[i].Nombre
+		}
+	}
+	for i := range res.Zonas {
+		z := &res.Zonas[i]
+		for h, n := range z.PorHora {
+			if n > z.PorHora[z.HoraPico] {
+				z.HoraPico = h
+			}
+		}
+	}
+	// Las de más incidencias primero
+	sort.SliceStable(res.Zonas, func(a, b int) bool {
+		if res.Zonas[a].Total != res.Zonas[b].Total {
+			return res.Zonas[a].Total > res.Zonas[b].Total
+		}
+		return res.Zonas[a].ZonaID < res.Zonas[b].ZonaID
+	})
+	return res, zonas, nil
+}
+
+// Tipos: una serie por tipo de incidencia, por hora o por día
+func (r *AnaliticaRepo) Tipos(ctx context.Context, f models.FiltroAnalitica, intervalo models.IntervaloSerie) (*models.AnaliticaTipos, error) {
+	loc, err := time.LoadLocation(f.ZonaHoraria)
+	if err != nil {
+		return nil, err
+	}
+	var conteos []models.ConteoTipo
+	err = r.db.SelectContext(ctx, &conteos, `WITH `+baseAnalitica+`
+		SELECT tipo, hora AS inicio, SUM(total)::int AS total
+		FROM filtrada
+		GROUP BY 1, 2
+		ORDER BY 1, 2
+	`, argsAnalitica(f)...)
+	if err != nil {
+		return nil, err
+	}
+	return &models.AnaliticaTipos{
+		Intervalo: intervalo, ZonaHoraria: f.ZonaHoraria, Series: seriesTipos(conteos, intervalo, loc),
+	}, nil
+}
+
+// seriesTipos junta las horas (ordenadas por tipo y hora) en intervalos
+func seriesTipos(conteos []models.ConteoTipo, intervalo models.IntervaloSerie, loc *time.Location) []models.SerieTipo {
+	series := []models.SerieTipo{}
+	for _, c := range conteos {
+		if n := len(series); n == 0 || series[n-1].Tipo != c.Tipo {
+			series = append(series, models.SerieTipo{Tipo: c.Tipo, Puntos: []models.PuntoSerie{}})
+		}
+		s := &series[len(series)-1]
+		s.Total += c.Total
+		inicio := inicioIntervalo(c.Inicio, intervalo, loc)
+		if n := len(s.Puntos); n > 0 && s.Puntos[n-1].Inicio.Equal(inicio) {
+			s.Puntos[n-1].Total += c.Total
+			continue
+		}
+		s.Puntos = append(s.Puntos, models.PuntoSerie{Inicio: inicio, Total: c.Total})
+	}
+	return series
+}
+
+// inicioIntervalo: la hora tal cual, o la medianoche local de su día
+func inicioIntervalo(hora time.Time, intervalo models.IntervaloSerie, loc *time.Location) time.Time {
+	if intervalo != models.IntervaloDia {
+		return hora
+	}
+	a, m, d := hora.In(loc).Date()
+	return time.Date(a, m, d, 0, 0, 0, 0, loc)
+}
+
+// Dias cuenta por día del evento, contando desde su inicio real (o el
+// programado, o la creación si nunca arrancó). Devuelve cada evento y el
+// agregado de todos por número de día.
+func (r *AnaliticaRepo) Dias(ctx context.Context, f models.FiltroAnalitica) (*models.AnaliticaDias, error) {
+	loc, err := time.LoadLocation(f.ZonaHoraria)
+	if err != nil {
+		return nil, err
+	}
+	var conteos []models.ConteoDia
+	err = r.db.SelectContext(ctx, &conteos, `WITH `+baseAnalitica+`,
+	inicios AS (
+		SELECT id, nombre, COALESCE(iniciado_en, inicio_programado, creado_en) AS inicio FROM eventos
+	)
+	SELECT f.evento_id, e.nombre, e.inicio AS inicio_en, f.hora, f.tipo, SUM(f.total)::int AS total
+	FROM filtrada f JOIN inicios e ON e.id = f.evento_id
+	GROUP BY 1, 2, 3, 4, 5
+	ORDER BY 3, 1, 4, 5
+	`, argsAnalitica(f)...)
+	if err != nil {
+		return nil, err
+	}
+	res := &models.AnaliticaDias{ZonaHoraria: f.ZonaHoraria}
+	res.Dias, res.PorEvento = diasEventos(conteos, loc)
+	return res, nil
+}
+
+// diasEventos reparte las horas de cada evento (ordenadas por evento y hora)
+// en sus días, y suma cada número de día entre todos los eventos
+func diasEventos(conteos []models.ConteoDia, loc *time.Location) ([]models.PuntoDia, []models.DiasEvento) {
+	porEvento := []models.DiasEvento{}
+	agregado := map[int]*models.PuntoDia{}
+	for _, c := range conteos {
+		if n := len(porEvento); n == 0 || porEvento[n-1].EventoID != c.EventoID {
+			porEvento = append(porEvento, models.DiasEvento{
+				EventoID: c.EventoID, Nombre: c.Nombre, InicioEn: c.InicioEn, Dias: []models.PuntoDia{},
+			})
+		}
+		ev := &porEvento[len(porEvento)-1]
+		ev.Total += c.Total
+		dia := diaDelEvento(c.Hora, c.InicioEn, loc)
+		if n := len(ev.Dias); n == 0 || ev.Dias[n-1].Dia != dia {
+			ev.Dias = append(ev.Dias, models.PuntoDia{Dia: dia, PorTipo: map[string]int{}})
+			a, ok := agregado[dia]
+			if !ok {
+				a = &models.PuntoDia{Dia: dia, PorTipo: map[string]int{}}
+				agregado[dia] = a
+			}
+			a.Eventos++
+		}
+		d := &ev.Dias[len(ev.Dias)-1]
+		d.Total += c.Total
+		d.PorTipo[c.Tipo] += c.Total
+		agregado[dia].Total += c.Total
+		agregado[dia].PorTipo[c.Tipo] += c.Total
+	}
+	dias := []models.PuntoDia{}
+	for _, a := range agregado {
+		dias = append(dias, *a)
+	}
+	sort.Slice(dias, func(i, j int) bool { return dias[i].Dia < dias[j].Dia })
+	return dias, porEvento
+}
+
+// diaDelEvento: 1 el día (local) del inicio, 2 el siguiente… Cuenta fechas
+// del calendario, no bloques de 24 h: un cambio de horario no corre el día.
+// Lo anterior al inicio da 0 o menos.
+func diaDelEvento(hora, inicio time.Time, loc *time.Location) int {
+	a, m, d := hora.In(loc).Date()
+	a0, m0, d0 := inicio.In(loc).Date()
+	dias := time.Date(a, m, d, 0, 0, 0, 0, time.UTC).Sub(time.Date(a0, m0, d0, 0, 0, 0, 0, time.UTC)) / (24 * time.Hour)
+	return int(dias) + 1
+}
@@ -0,0 +1,187 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eventpulse/backend/internal/models"
+)
+
+func cargarZona(t *testing.T, nombre string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(nombre)
+	if err != nil {
+		t.Skipf("sin datos de zona horaria para %s: %v", nombre, err)
+	}
+	return loc
+}
+
+func utc(s string) time.Time {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestDiaDelEvento(t *testing.T) {
+	mx := cargarZona(t, "America/Mexico_City")
+	ny := cargarZona(t, "America/New_York")
+	casos := []struct {
+		nombre       string
+		hora, inicio string
+		loc          *time.Location
+		esperado     int
+	}{
+		{"misma hora del inicio", "2026-07-10T18:00:00Z", "2026-07-10T18:00:00Z", time.UTC, 1},
+		{"antes de medianoche", "2026-07-10T23:00:00Z", "2026-07-10T18:00:00Z", time.UTC, 1},
+		{"pasada la medianoche", "2026-07-11T00:00:00Z", "2026-07-10T23:30:00Z", time.UTC, 2},
+		{"menos de 24 h pero otro día", "2026-07-11T01:00:00Z", "2026-07-10T22:00:00Z", time.UTC, 2},
+		{"tercer día", "2026-07-12T12:00:00Z", "2026-07-10T08:00:00Z", time.UTC, 3},
+		// 02:00 UTC del 11 son las 20:00 del 10 en Ciudad de México
+		{"el día es el local", "2026-07-11T02:00:00Z", "2026-07-10T18:00:00Z", mx, 1},
+		{"el inicio también es local", "2026-07-11T15:00:00Z", "2026-07-11T03:00:00Z", mx, 2},
+		{"antes del inicio", "2026-07-09T12:00:00Z", "2026-07-10T12:00:00Z", time.UTC, 0},
+		{"dos días antes", "2026-07-08T12:00:00Z", "2026-07-10T12:00:00Z", time.UTC, -1},
+		// 8 de marzo de 2026 dura 23 h en Nueva York
+		{"cambio de horario", "2026-03-09T04:30:00Z", "2026-03-08T05:00:00Z", ny, 2},
+		{"fin del día con cambio de horario", "2026-03-09T03:30:00Z", "2026-03-08T05:00:00Z", ny, 1},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			if got := diaDelEvento(utc(c.hora), utc(c.inicio), c.loc); got != c.esperado {
+				t.Errorf("diaDelEvento(%s, %s, %s) = %d, esperado %d", c.hora, c.inicio, c.loc, got, c.esperado)
+			}
+		})
+	}
+}
+
+func TestInicioIntervalo(t *testing.T) {
+	mx := cargarZona(t, "America/Mexico_City")
+	casos := []struct {
+		nombre    string
+		hora      string
+		intervalo models.IntervaloSerie
+		loc       *time.Location
+		esperado  string
+	}{
+		{"por hora queda igual", "2026-07-10T05:00:00Z", models.IntervaloHora, mx, "2026-07-10T05:00:00Z"},
+		{"por día en UTC", "2026-07-10T05:00:00Z", models.IntervaloDia, time.UTC, "2026-07-10T00:00:00Z"},
+		{"por día local", "2026-07-10T05:00:00Z", models.IntervaloDia, mx, "2026-07-09T06:00:00Z"},
+		{"medianoche local exacta", "2026-07-10T06:00:00Z", models.IntervaloDia, mx, "2026-07-10T06:00:00Z"},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			if got := inicioIntervalo(utc(c.hora), c.intervalo, c.loc); !got.Equal(utc(c.esperado)) {
+				t.Errorf("inicioIntervalo = %s, esperado %s", got.UTC().Format(time.RFC3339), c.esperado)
+			}
+		})
+	}
+}
+
+func TestSeriesTipos(t *testing.T) {
+	mx := cargarZona(t, "America/Mexico_City")
+	conteos := []models.ConteoTipo{
+		{Tipo: "derrame", Inicio: utc("2026-07-10T04:00:00Z"), Total: 1},
+		{Tipo: "seguridad", Inicio: utc("2026-07-10T04:00:00Z"), Total: 2}, // 22:00 del 9 local
+		{Tipo: "seguridad", Inicio: utc("2026-07-10T05:00:00Z"), Total: 3}, // 00:00 del 10 en UTC, 23:00 del 9 local
+		{Tipo: "seguridad", Inicio: utc("2026-07-10T06:00:00Z"), Total: 4}, // 00:00 del 10 local
+		{Tipo: "seguridad", Inicio: utc("2026-07-10T20:00:00Z"), Total: 5},
+	}
+	type punto struct {
+		inicio string
+		total  int
+	}
+	casos := []struct {
+		nombre    string
+		intervalo models.IntervaloSerie
+		loc       *time.Location
+		seguridad []punto
+	}{
+		{"por hora", models.IntervaloHora, mx, []punto{
+			{"2026-07-10T04:00:00Z", 2}, {"2026-07-10T05:00:00Z", 3}, {"2026-07-10T06:00:00Z", 4}, {"2026-07-10T20:00:00Z", 5},
+		}},
+		{"por día local", models.IntervaloDia, mx, []punto{
+			{"2026-07-09T06:00:00Z", 5}, {"2026-07-10T06:00:00Z", 9},
+		}},
+		{"por día en UTC", models.IntervaloDia, time.UTC, []punto{
+			{"2026-07-10T00:00:00Z", 14},
+		}},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			series := seriesTipos(conteos, c.intervalo, c.loc)
+			if len(series) != 2 || series[0].Tipo != "derrame" || series[1].Tipo != "seguridad" {
+				t.Fatalf("series = %+v, esperado derrame y seguridad", series)
+			}
+			if series[0].Total != 1 || len(series[0].Puntos) != 1 {
+				t.Errorf("derrame = %+v, esperado un punto con 1", series[0])
+			}
+			s := series[1]
+			if s.Total != 14 {
+				t.Errorf("total de seguridad = %d, esperado 14", s.Total)
+			}
+			if len(s.Puntos) != len(c.seguridad) {
+				t.Fatalf("puntos de seguridad = %+v, esperado %v", s.Puntos, c.seguridad)
+			}
+			for i, p := range c.seguridad {
+				if !s.Puntos[i].Inicio.Equal(utc(p.inicio)) || s.Puntos[i].Total != p.total {
+					t.Errorf("punto %d = (%s, %d), esperado (%s, %d)",
+						i, s.Puntos[i].Inicio.UTC().Format(time.RFC3339), s.Puntos[i].Total, p.inicio, p.total)
+				}
+			}
+		})
+	}
+	if got := seriesTipos(nil, models.IntervaloDia, time.UTC); got == nil || len(got) != 0 {
+		t.Errorf("sin conteos = %#v, esperado una lista vacía", got)
+	}
+}
+
+func TestDiasEventos(t *testing.T) {
+	festival := utc("2026-07-10T18:00:00Z")
+	feria := utc("2026-08-01T09:00:00Z")
+	conteos := []models.ConteoDia{
+		{EventoID: "festival", InicioEn: festival, Hora: utc("2026-07-10T19:00:00Z"), Tipo: "seguridad", Total: 2},
+		{EventoID: "festival", InicioEn: festival, Hora: utc("2026-07-10T23:00:00Z"), Tipo: "derrame", Total: 1},
+		{EventoID: "festival", InicioEn: festival, Hora: utc("2026-07-10T23:00:00Z"), Tipo: "seguridad", Total: 3},
+		{EventoID: "festival", InicioEn: festival, Hora: utc("2026-07-11T01:00:00Z"), Tipo: "seguridad", Total: 4},
+		{EventoID: "feria", InicioEn: feria, Hora: utc("2026-08-01T10:00:00Z"), Tipo: "derrame", Total: 5},
+		{EventoID: "feria", InicioEn: feria, Hora: utc("2026-08-03T10:00:00Z"), Tipo: "derrame", Total: 6},
+	}
+	dias, porEvento := diasEventos(conteos, time.UTC)
+
+	if len(porEvento) != 2 || porEvento[0].EventoID != "festival" || porEvento[1].EventoID != "feria" {
+		t.Fatalf("por_evento = %+v, esperado festival y feria en orden", porEvento)
+	}
+	fest := porEvento[0]
+	if fest.Total != 10 || len(fest.Dias) != 2 {
+		t.Fatalf("festival = %+v, esperado 10 en 2 días", fest)
+	}
+	if d := fest.Dias[0]; d.Dia != 1 || d.Total != 6 || d.PorTipo["seguridad"] != 5 || d.PorTipo["derrame"] != 1 {
+		t.Errorf("festival día 1 = %+v", d)
+	}
+	if d := fest.Dias[1]; d.Dia != 2 || d.Total != 4 {
+		t.Errorf("festival día 2 = %+v", d)
+	}
+	if f := porEvento[1]; len(f.Dias) != 2 || f.Dias[0].Dia != 1 || f.Dias[1].Dia != 3 {
+		t.Errorf("feria = %+v, esperado días 1 y 3", f)
+	}
+
+	esperado := []struct{ dia, total, eventos int }{{1, 11, 2}, {2, 4, 1}, {3, 6, 1}}
+	if len(dias) != len(esperado) {
+		t.Fatalf("dias = %+v, esperado %v", dias, esperado)
+	}
+	for i, e := range esperado {
+		if d := dias[i]; d.Dia != e.dia || d.Total != e.total || d.Eventos != e.eventos {
+			t.Errorf("agregado %d = día %d total %d eventos %d, esperado %v", i, d.Dia, d.Total, d.Eventos, e)
+		}
+	}
+	if dias[0].PorTipo["derrame"] != 6 || dias[0].PorTipo["seguridad"] != 5 {
+		t.Errorf("agregado día 1 por tipo = %v", dias[0].PorTipo)
+	}
+
+	dias, porEvento = diasEventos(nil, time.UTC)
+	if dias == nil || porEvento == nil || len(dias)+len(porEvento) != 0 {
+		t.Errorf("sin conteos = (%#v, %#v), esperado listas vacías", dias, porEvento)
+	}
+}
@@ -0,0 +1,223 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// ─── Anuncio ──────────────────────────────────────────────────────────────────
+
+// ErrSinDestinatarios: el destino elegido no resuelve a ningún usuario
+var ErrSinDestinatarios = errors.New("el anuncio no tiene destinatarios")
+
+type AnuncioRepo struct{ db *sqlx.DB }
+
+func NewAnuncioRepo(db *sqlx.DB) *AnuncioRepo { return &AnuncioRepo{db: db} }
+
+const selectAnuncio = `
+	SELECT a.id, a.evento_id, a.titulo, a.contenido, a.destino, a.rol, a.zona_id,
+	       a.reenviar_cada_seg, a.creado_por, u.nombre AS nombre_creador,
+	       a.creado_en, a.cerrado_en,
+	       (SELECT COUNT(*) FROM anuncios_destinatarios d WHERE d.anuncio_id = a.id) AS destinatarios,
+	       (SELECT COUNT(*) FROM anuncios_destinatarios d
+	        WHERE d.anuncio_id = a.id AND d.confirmado_en IS NOT NULL) AS confirmados
+	FROM anuncios a
+	JOIN usuarios u ON u.id = a.creado_por`
+
+// Crear guarda el anuncio y fija sus destinatarios según el destino:
+// todos los del evento, los de un rol, o el personal asignado a la zona o
+// presente en ella.
+func (r *AnuncioRepo) Crear(ctx context.Context, req *models.CrearAnuncioRequest, eventoID, creadorID string, reenviarCadaSeg int) (*models.Anuncio, []string, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	var zonaID *string
+	if req.ZonaID != "" {
+		zonaID = &req.ZonaID
+	}
+	var id string
+	err = tx.GetContext(ctx, &id, `
+		INSERT INTO anuncios (evento_id, titulo, contenido, destino, rol, zona_id, reenviar_cada_seg, creado_por)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	`, eventoID, req.Titulo, req.Contenido, req.Destino, req.Rol, zonaID, reenviarCadaSeg, creadorID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var destinatarios []string
+	err = tx.SelectContext(ctx, &destinatarios, `
+		INSERT INTO anuncios_destinatarios (anuncio_id, usuario_id)
+		SELECT $1::uuid, u.id FROM usuarios u
+		WHERE u.activo = true AND u.id <> $3 AND u.evento_id = $2
+		  AND ($4 = 'todos'
+		       OR ($4 = 'rol' AND u.rol = $5)
+		       OR ($4 = 'zona' AND (
+		           -- Asignados a la zona: algo abierto ahí o una ronda en curso que pasa por ella
+		           EXISTS (SELECT 1 FROM tareas t
+		                   WHERE t.evento_id = $2 AND t.zona_id = $6 AND t.asignada_a = u.id
+		                     AND t.estado NOT IN ('completada','cancelada'))
+		           OR EXISTS (SELECT 1 FROM incidencias i
+		                      WHERE i.evento_id = $2 AND i.zona_id = $6 AND i.asignada_a = u.id
+		                        AND i.estado <> 'resuelta')
+		           OR EXISTS (SELECT 1 FROM rondas ro JOIN rutas_puntos rp ON rp.ruta_id = ro.ruta_id
+		                      WHERE ro.evento_id = $2 AND ro.usuario_id = u.id
+		                        AND ro.estado = 'en_curso' AND rp.zona_id = $6)
+		           -- Presentes: en turno, y su última ubicación (GPS, QR o baliza) cae en la zona
+		           OR EXISTS (SELECT 1 FROM turnos tu
+		                      WHERE tu.usuario_id = u.id AND tu.evento_id = $2 AND tu.fin IS NULL
+		                        AND (SELECT ub.zona_id FROM ubicaciones ub WHERE ub.usuario_id = u.id AND ub.turno_id = tu.id
+		                             ORDER BY ub.registrada_en DESC LIMIT 1) = $6))))
+		RETURNING usuario_id
+	`, id, eventoID, creadorID, req.Destino, req.Rol, zonaID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(destinatarios) == 0 {
+		return nil, nil, ErrSinDestinatarios
+	}
+
+	var a models.Anuncio
+	if err := tx.GetContext(ctx, &a, selectAnuncio+` WHERE a.id = $1`, id); err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+	return &a, destinatarios, nil
+}
+
+func (r *AnuncioRepo) ObtenerPorID(ctx context.Context, id string) (*models.Anuncio, error) {
+	var a models.Anuncio
+	err := r.db.GetContext(ctx, &a, selectAnuncio+` WHERE a.id = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return &a, err
+}
+
+// Listar devuelve todos los anuncios del evento (vista admin/supervisor)
+func (r *AnuncioRepo) Listar(ctx context.Context, eventoID string) ([]models.Anuncio, error) {
+	var lista []models.Anuncio
+	err := r.db.SelectContext(ctx, &lista, selectAnuncio+`
+		WHERE a.evento_id = $1
+		ORDER BY a.creado_en DESC
+	`, eventoID)
+	return lista, err
+}
+
+// ListarDeUsuario devuelve los anuncios dirigidos al usuario, con su confirmación
+func (r *AnuncioRepo) ListarDeUsuario(ctx context.Context, eventoID, usuarioID string) ([]models.Anuncio, error) {
+	var lista []models.Anuncio
+	err := r.db.SelectContext(ctx, &lista, `
+		SELECT x.*, d.confirmado_en FROM (`+selectAnuncio+` WHERE a.evento_id = $1) x
+		JOIN anuncios_destinatarios d ON d.anuncio_id = x.id AND d.usuario_id = $2
+		ORDER BY d.confirmado_en IS NULL DESC, x.creado_en DESC
+	`, eventoID, usuarioID)
+	return lista, err
+}
+
+func (r *AnuncioRepo) ListarDestinatarios(ctx context.Context, anuncioID string) ([]models.DestinatarioAnuncio, error) {
+	var lista []models.DestinatarioAnuncio
+	err := r.db.SelectContext(ctx, &lista, `
+		SELECT d.usuario_id, u.nombre, u.rol, d.entregas, d.ultimo_envio, d.confirmado_en
+		FROM anuncios_destinatarios d
+		JOIN usuarios u ON u.id = d.usuario_id
+		WHERE d.anuncio_id = $1
+		ORDER BY d.confirmado_en NULLS FIRST, u.nombre
+	`, anuncioID)
+	return lista, err
+}
+
+// Confirmar registra el acuse del destinatario. Es idempotente: si ya había
+// confirmado devuelve la hora original. ErrNoEncontrado si no es destinatario.
+func (r *AnuncioRepo) Confirmar(ctx context.Context, anuncioID, usuarioID string) (*models.DestinatarioAnuncio, error) {
+	var d models.DestinatarioAnuncio
+	err := r.db.GetContext(ctx, &d, `
+		WITH updated AS (
+			UPDATE anuncios_destinatarios
+			SET confirmado_en = COALESCE(confirmado_en, NOW())
+			WHERE anuncio_id = $1 AND usuario_id = $2
+			RETURNING *
+		)
+		SELECT d.usuario_id, u.nombre, u.rol, d.entregas, d.ultimo_envio, d.confirmado_en
+		FROM updated d
+		JOIN usuarios u ON u.id = d.usuario_id
+	`, anuncioID, usuarioID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoEncontrado
+	}
+	return &d, err
+}
+
+// Cerrar detiene los reenvíos del anuncio
+func (r *AnuncioRepo) Cerrar(ctx context.Context, anuncioID string) error {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE anuncios SET cerrado_en = NOW() WHERE id = $1 AND cerrado_en IS NULL
+	`, anuncioID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNoEncontrado
+	}
+	return nil
+}
+
+// Reenvio agrupa los destinatarios a los que toca volver a enviar un anuncio
+type Reenvio struct {
+	AnuncioID string
+	Usuarios  []string
+}
+
+// TomarReenviosPendientes marca como reenviados (y devuelve) los destinatarios
+// sin confirmar cuyo último envío es más viejo que el intervalo del anuncio.
+// El UPDATE ... RETURNING hace que con varias instancias cada reenvío lo tome una sola.
+func (r *AnuncioRepo) TomarReenviosPendientes(ctx context.Context) ([]Reenvio, error) {
+	var filas []struct {
+		AnuncioID string `db:"anuncio_id"`
+		UsuarioID string `db:"usuario_id"`
+	}
+	err := r.db.SelectContext(ctx, &filas, `
+		UPDATE anuncios_destinatarios d
+		SET ultimo_envio = NOW(), entregas = d.entregas + 1
+		FROM anuncios a
+		JOIN eventos e ON e.id = a.evento_id
+		WHERE a.id = d.anuncio_id
+		  AND a.cerrado_en IS NULL AND e.estado = 'activo'
+		  AND d.confirmado_en IS NULL
+		  AND d.ultimo_envio < NOW() - make_interval(secs => a.reenviar_cada_seg)
+		RETURNING d.anuncio_id, d.usuario_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	indice := map[string]int{}
+	var out []Reenvio
+	for _, f := range filas {
+		i, ok := indice[f.AnuncioID]
+		if !ok {
+			i = len(out)
+			indice[f.AnuncioID] = i
+			out = append(out, Reenvio{AnuncioID: f.AnuncioID})
+		}
+		out[i].Usuarios = append(out[i].Usuarios, f.UsuarioID)
+	}
+	return out, nil
+}
+
+// IDsSupervision devuelve quién sigue el tablero de un anuncio: su autor y los admins
+func (r *AnuncioRepo) IDsSupervision(ctx context.Context, anuncio *models.Anuncio) ([]string, error) {
+	var ids []string
+	err := r.db.SelectContext(ctx, &ids, `
+		SELECT id FROM usuarios WHERE activo = true AND (rol = 'admin' OR id = $1)
+	`, anuncio.CreadoPor)
+	return ids, err
+}
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"math"
+
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// ─── Carga y desempeño del personal ───────────────────────────────────────────
+
+type CargaRepo struct{ db *sqlx.DB }
+
+func NewCargaRepo(db *sqlx.DB) *CargaRepo { return &CargaRepo{db: db} }
+
+// EnVivo: lo abierto de cada persona activa del personal de campo vinculada
+// al evento (rol "" = todos). Abiertas y Nivel los completa quien llama.
+func (r *CargaRepo) EnVivo(ctx context.Context, eventoID string, rol models.Rol) ([]models.CargaTrabajador, error) {
+	lista := []models.CargaTrabajador{}
+	err := r.db.SelectContext(ctx, &lista, `
+		SELECT u.id, u.nombre, u.rol,
+			EXISTS (SELECT 1 FROM turnos tu WHERE tu.usuario_id = u.id AND tu.evento_id = $1 AND tu.fin IS NULL) AS en_turno,
+			(SELECT COUNT(*) FROM incidencias i
+			  WHERE i.asignada_a = u.id AND i.evento_id = $1 AND i.estado <> 'resuelta') AS incidencias_abiertas,
+			(SELECT COUNT(*) FROM tareas t
+			  WHERE t.asignada_a = u.id AND t.evento_id = $1 AND t.estado NOT IN ('completada','cancelada')) AS tareas_abiertas,
+			(SELECT COUNT(*) FROM tareas t
+			  WHERE t.asignada_a = u.id AND t.evento_id = $1 AND t.estado NOT IN ('completada','cancelada') AND t.prioridad = 'alta') AS tareas_alta,
+			GREATEST(
+				(SELECT MAX(h.cambiado_en) FROM incidencias_historial h JOIN incidencias i ON i.id = h.incidencia_id
+				  WHERE h.usuario_id = u.id AND h.estado_nuevo = 'en_atencion' AND i.evento_id = $1),
+				(SELECT MAX(h.cambiado_en) FROM tareas_historial h JOIN tareas t ON t.id = h.tarea_id
+				  WHERE h.usuario_id = u.id AND h.estado_nuevo = 'en_progreso' AND t.evento_id = $1)
+			) AS ultima_toma
+		FROM usuarios u
+		WHERE u.evento_id = $1 AND u.activo AND u.rol NOT IN ('admin','supervisor')
+		  AND ($2 = '' OR u.rol = $2)
+	`, eventoID, string(rol))
+	return lista, err
+}
+
+// Desempeno: por persona, lo que tiene abierto, lo que cerró y cuánto tardó,
+// sobre lo que tiene asignado en el evento. Entra el personal de campo
+// vinculado (también el que sacó el cierre) y cualquiera con algo asignado.
+func (r *CargaRepo) Desempeno(ctx context.Context, eventoID string) ([]models.DesempenoTrabajador, error) {
+	lista := []models.DesempenoTrabajador{}
+	err := r.db.SelectContext(ctx, &lista, `
+		WITH items AS (
+			SELECT i.asignada_a AS usuario_id, 'incidencia' AS clase, i.estado <> 'resuelta' AS abierta, i.creada_en,
+			       MIN(h.cambiado_en) FILTER (WHERE h.estado_nuevo = 'en_atencion') AS aceptada_en,
+			       MIN(h.cambiado_en) FILTER (WHERE h.estado_nuevo = 'resuelta')    AS cerrada_en
+			FROM incidencias i
+			LEFT JOIN incidencias_historial h ON h.incidencia_id = i.id
+			     AND h.origen <> 'cierre'
+			WHERE i.evento_id = $1 AND i.asignada_a IS NOT NULL
+			GROUP BY i.id
+			UNION ALL
+			SELECT t.asignada_a, 'tarea', t.estado NOT IN ('completada','cancelada'), t.creada_en,
+			       MIN(h.cambiado_en) FILTER (WHERE h.estado_nuevo = 'en_progreso'),
+			       MIN(h.cambiado_en) FILTER (WHERE h.estado_nuevo = 'completada')
+			FROM tareas t
+			LEFT JOIN tareas_historial h ON h.tarea_id = t.id
+			     AND h.origen <> 'cierre'
+			WHERE t.evento_id = $1 AND t.asignada_a IS NOT NULL
+			GROUP BY t.id
+		), activo AS (
+			-- Unión de los intervalos en curso de cada uno: lo simultáneo cuenta una vez
+			SELECT usuario_id, SUM(EXTRACT(EPOCH FROM upper(r) - lower(r))) / 3600 AS horas
+			FROM (
+				SELECT usuario_id,
+				       unnest(range_agg(tstzrange(aceptada_en, GREATEST(aceptada_en, COALESCE(cerrada_en, NOW()))))) AS r
+				FROM items
+				WHERE aceptada_en IS NOT NULL AND (abierta OR cerrada_en IS NOT NULL)
+				GROUP BY usuario_id
+			) x
+			GROUP BY usuario_id
+		), personas AS (
+			SELECT id FROM usuarios WHERE evento_id = $1 AND rol NOT IN ('admin','supervisor')
+			UNION SELECT u.id FROM cierres_evento c JOIN usuarios u ON u.id = ANY(c.personal)
+			      WHERE c.evento_id = $1 AND c.estado IN ('revocando','completado') AND u.rol NOT IN ('admin','supervisor')
+			UNION SELECT usuario_id FROM items
+		)
+		SELECT u.id, u.nombre, u.rol,
+			COUNT(it.clase) FILTER (WHERE it.clase = 'incidencia' AND it.abierta) AS incidencias_abiertas,
+			COUNT(it.clase) FILTER (WHERE it.clase = 'tarea' AND it.abierta) AS tareas_abiertas,
+			COUNT(it.clase) FILTER (WHERE it.clase = 'incidencia' AND NOT it.abierta AND it.cerrada_en IS NOT NULL) AS incidencias_resueltas,
+			COUNT(it.clase) FILTER (WHERE it.clase = 'tarea' AND NOT it.abierta AND it.cerrada_en IS NOT NULL) AS tareas_completadas,
+			ROUND((AVG(EXTRACT(EPOCH FROM it.aceptada_en - it.creada_en)) / 60)::numeric, 1)::float8 AS aceptacion_promedio_min,
+			ROUND((AVG(EXTRACT(EPOCH FROM it.cerrada_en - it.aceptada_en))
+				FILTER (WHERE NOT it.abierta AND it.cerrada_en >= it.aceptada_en) / 60)::numeric, 1)::float8 AS resolucion_promedio_min,
+			(SELECT COALESCE(ROUND((SUM(EXTRACT(EPOCH FROM COALESCE(tu.fin, NOW()) - tu.inicio)) / 3600)::numeric, 1), 0)::float8
+			   FROM turnos tu WHERE tu.evento_id = $1 AND tu.usuario_id = u.id) AS horas_turno,
+			COALESCE((SELECT ROUND(a.horas::numeric, 1)::float8 FROM activo a WHERE a.usuario_id = u.id), 0) AS horas_activo
+		FROM usuarios u
+		JOIN personas p ON p.id = u.id
+		LEFT JOIN items it ON it.usuario_id = u.id
+		GROUP BY u.id, u.nombre, u.rol
+		ORDER BY u.rol, u.nombre
+	`, eventoID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range lista {
+		if d := &lista[i]; d.HorasTurno > 0 {
+			pct := math.Round(d.HorasActivo/d.HorasTurno*1000) / 10
+			d.OcupacionPct = &pct
+		}
+	}
+	return lista, nil
+}
@@ -0,0 +1,709 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ─── Mensaje ──────────────────────────────────────────────────────────────────
+
+type MensajeRepo struct{ db *sqlx.DB }
+
+func NewMensajeRepo(db *sqlx.DB) *MensajeRepo { return &MensajeRepo{db: db} }
+
+// Columnas de un mensaje; el contenido de los eliminados no sale de la base
+const columnasMensaje = `m.id, m.evento_id, m.conversacion_id, m.usuario_id,
+	u.nombre AS nombre_usuario,
+	u.rol    AS rol_usuario,
+	CASE WHEN m.eliminado_en IS NULL THEN m.contenido ELSE '' END AS contenido,
+	m.enviado_en, m.editado_en, m.eliminado_en IS NOT NULL AS eliminado, m.version`
+
+func (r *MensajeRepo) Listar(ctx context.Context, conversacionID string, limite int) ([]models.Mensaje, error) {
+	var lista []models.Mensaje
+	err := r.db.SelectContext(ctx, &lista, `
+		SELECT `+columnasMensaje+`
+		FROM mensajes m
+		JOIN usuarios u ON u.id = m.usuario_id
+		WHERE m.conversacion_id = $1
+		ORDER BY m.enviado_en DESC
+		LIMIT $2
+	`, conversacionID, limite)
+	// Invertir a orden cronológico
+	for i, j := 0, len(lista)-1; i < j; i, j = i+1, j-1 {
+		lista[i], lista[j] = lista[j], lista[i]
+	}
+	if err != nil {
+		return nil, err
+	}
+	return lista, r.cargarMenciones(ctx, lista)
+}
+
+func (r *MensajeRepo) ObtenerPorID(ctx context.Context, id string) (*models.Mensaje, error) {
+	var msg models.Mensaje
+	err := r.db.GetContext(ctx, &msg, `
+		SELECT `+columnasMensaje+`
+		FROM mensajes m
+		JOIN usuarios u ON u.id = m.usuario_id
+		WHERE m.id = $1
+	`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	lista := []models.Mensaje{msg}
+	if err := r.cargarMenciones(ctx, lista); err != nil {
+		return nil, err
+	}
+	return &lista[0], nil
+}
+
+// Crear guarda el mensaje junto con las menciones que se puedan resolver.
+// Las que no apuntan a nada del evento se ignoran.
+func (r *MensajeRepo) Crear(ctx context.Context, eventoID, conversacionID, usuarioID, contenido string, menciones []models.CandidatoMencion) (*models.Mensaje, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var id string
+	err = tx.GetContext(ctx, &id, `
+		INSERT INTO mensajes (evento_id, conversacion_id, usuario_id, contenido)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, eventoID, conversacionID, usuarioID, contenido)
+	if err != nil {
+		return nil, err
+	}
+	if err := guardarMenciones(ctx, tx, eventoID, id, menciones); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.ObtenerPorID(ctx, id)
+}
+
+// Editar reemplaza el contenido guardando el anterior en mensajes_revisiones
+// y vuelve a resolver las menciones. No se pueden editar mensajes eliminados
+// (ErrNoEncontrado). Si version != nil solo escribe cuando coincide (If-Match).
+func (r *MensajeRepo) Editar(ctx context.Context, id, usuarioID, contenido string, menciones []models.CandidatoMencion, version *int) (*models.Mensaje, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO mensajes_revisiones (mensaje_id, contenido, editado_por)
+		SELECT id, contenido, $2 FROM mensajes
+		WHERE id = $1 AND eliminado_en IS NULL AND ($3::int IS NULL OR version = $3)
+		FOR UPDATE
+	`, id, usuarioID, version)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, sinCambio(ctx, tx, version, `SELECT 1 FROM mensajes WHERE id = $1 AND eliminado_en IS NULL`, id)
+	}
+	var eventoID string
+	err = tx.GetContext(ctx, &eventoID, `
+		UPDATE mensajes SET contenido = $2, editado_en = NOW() WHERE id = $1
+		RETURNING evento_id
+	`, id, contenido)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mensajes_menciones WHERE mensaje_id = $1`, id); err != nil {
+		return nil, err
+	}
+	if err := guardarMenciones(ctx, tx, eventoID, id, menciones); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.ObtenerPorID(ctx, id)
+}
+
+// Eliminar hace el borrado lógico. ErrNoEncontrado si no existe o ya estaba eliminado.
+func (r *MensajeRepo) Eliminar(ctx context.Context, id, usuarioID string) (*models.Mensaje, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE mensajes SET eliminado_en = NOW(), eliminado_por = $2
+		WHERE id = $1 AND eliminado_en IS NULL
+	`, id, usuarioID)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, ErrNoEncontrado
+	}
+	return r.ObtenerPorID(ctx, id)
+}
+
+func (r *MensajeRepo) ListarRevisiones(ctx context.Context, mensajeID string) ([]models.RevisionMensaje, error) {
+	var lista []models.RevisionMensaje
+	err := r.db.SelectContext(ctx, &lista, `
+		SELECT id, mensaje_id, contenido, editado_por, editado_en
+		FROM mensajes_revisiones WHERE mensaje_id = $1
+		ORDER BY editado_en
+	`, mensajeID)
+	return lista, err
+}
+
+// guardarMenciones resuelve los candidatos contra el evento. Usuarios por
+// nombre de usuario (vinculados al evento o admin); incidencias y tareas por
+// prefijo del id, descartando los prefijos que coincidan con más de una. Un
+// rol solo se guarda si alguien del evento lo tiene.
+func guardarMenciones(ctx context.Context, tx *sqlx.Tx, eventoID, mensajeID string, menciones []models.CandidatoMencion) error {
+	claves := map[models.TipoMencion][]string{}
+	textos := map[models.TipoMencion][]string{}
+	for _, m := range menciones {
+		claves[m.Tipo] = append(claves[m.Tipo], m.Clave)
+		textos[m.Tipo] = append(textos[m.Tipo], m.Texto)
+	}
+
+	consultas := map[models.TipoMencion]string{
+		models.MencionUsuario: `
+			INSERT INTO mensajes_menciones (mensaje_id, tipo, objetivo, texto)
+			SELECT $1, 'usuario', u.id::text, c.texto
+			FROM unnest($2::text[], $3::text[]) AS c(clave, texto)
+			JOIN usuarios u ON LOWER(u.nombre_usuario) = c.clave
+			WHERE u.activo = true AND (u.evento_id = $4 OR u.rol = 'admin')
+			ON CONFLICT DO NOTHING`,
+		models.MencionRol: `
+			INSERT INTO mensajes_menciones (mensaje_id, tipo, objetivo, texto)
+			SELECT $1, 'rol', c.clave, c.texto
+			FROM unnest($2::text[], $3::text[]) AS c(clave, texto)
+			WHERE EXISTS (
+				SELECT 1 FROM usuarios u
+				WHERE u.rol = c.clave AND u.activo = true AND (u.evento_id = $4 OR u.rol = 'admin')
+			)
+			ON CONFLICT DO NOTHING`,
+		models.MencionIncidencia: `
+			INSERT INTO mensajes_menciones (mensaje_id, tipo, objetivo, texto)
+			SELECT $1, 'incidencia', MIN(i.id::text), c.texto
+			FROM unnest($2::text[], $3::text[]) AS c(clave, texto)
+			JOIN incidencias i ON i.evento_id = $4 AND i.id::text LIKE c.clave || '%'
+			GROUP BY c.clave, c.texto
+			HAVING COUNT(*) = 1
+			ON CONFLICT DO NOTHING`,
+		models.MencionTarea: `
+			INSERT INTO mensajes_menciones (mensaje_id, tipo, objetivo, texto)
+			SELECT $1, 'tarea', MIN(t.id::text), c.texto
+			FROM unnest($2::text[], $3::text[]) AS c(clave, texto)
+			JOIN tareas t ON t.evento_id = $4 AND t.id::text LIKE c.clave || '%'
+			GROUP BY c.clave, c.texto
+			HAVING COUNT(*) = 1
+			ON CONFLICT DO NOTHING`,
+	}
+	for tipo, consulta := range consultas {
+		if len(claves[tipo]) == 0 {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, consulta, mensajeID, pq.Array(claves[tipo]), pq.Array(textos[tipo]), eventoID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// filaMencion es una mención con las columnas de todas las vistas previas;
+// solo vienen las del tipo correspondiente.
+type filaMencion struct {
+	MensajeID string             `db:"mensaje_id"`
+	Tipo      models.TipoMencion `db:"tipo"`
+	Texto     string             `db:"texto"`
+	Objetivo  string             `db:"objetivo"`
+
+	UsuarioNombre *string `db:"usuario_nombre"`
+
+	IncTipo        *models.TipoIncidencia   `db:"inc_tipo"`
+	IncEstado      *models.EstadoIncidencia `db:"inc_estado"`
+	IncZonaID      *string                  `db:"inc_zona_id"`
+	IncZonaNombre  *string                  `db:"inc_zona_nombre"`
+	IncDescripcion *string                  `db:"inc_descripcion"`
+	IncAsignado    *string                  `db:"inc_asignado"`
+
+	TareaTitulo     *string                `db:"tarea_titulo"`
+	TareaEstado     *models.EstadoTarea    `db:"tarea_estado"`
+	TareaPrioridad  *models.PrioridadTarea `db:"tarea_prioridad"`
+	TareaZonaID     *string                `db:"tarea_zona_id"`
+	TareaZonaNombre *string                `db:"tarea_zona_nombre"`
+	TareaAsignado   *string                `db:"tarea_asignado"`
+}
+
+// cargarMenciones completa Menciones de cada mensaje con sus vistas previas.
+// Los mensajes eliminados no exponen menciones.
+func (r *MensajeRepo) cargarMenciones(ctx context.Context, lista []models.Mensaje) error {
+	ids := make([]string, 0, len(lista))
+	for _, m := range lista {
+		if !m.Eliminado {
+			ids = append(ids, m.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var filas []filaMencion
+	err := r.db.SelectContext(ctx, &filas, `
+		SELECT mm.mensaje_id, mm.tipo, mm.texto, mm.objetivo,
+		       u.nombre AS usuario_nombre,
+		       i.tipo AS inc_tipo, i.estado AS inc_estado, i.zona_id AS inc_zona_id,
+		       zi.nombre AS inc_zona_nombre, i.descripcion AS inc_descripcion,
+		       ui.nombre AS inc_asignado,
+		       t.titulo AS tarea_titulo, t.estado AS tarea_estado, t.prioridad AS tarea_prioridad,
+		       t.zona_id AS tarea_zona_id, zt.nombre AS tarea_zona_nombre,
+		       ut.nombre AS tarea_asignado
+		FROM mensajes_menciones mm
+		LEFT JOIN usuarios u     ON u.id = CASE WHEN mm.tipo = 'usuario' THEN mm.objetivo::uuid END
+		LEFT JOIN incidencias i  ON i.id = CASE WHEN mm.tipo = 'incidencia' THEN mm.objetivo::uuid END
+		LEFT JOIN zonas zi       ON zi.id = i.zona_id AND zi.evento_id = i.evento_id
+		LEFT JOIN usuarios ui    ON ui.id = i.asignada_a
+		LEFT JOIN tareas t       ON t.id = CASE WHEN mm.tipo = 'tarea' THEN mm.objetivo::uuid END
+		LEFT JOIN zonas zt       ON zt.id = t.zona_id AND zt.evento_id = t.evento_id
+		LEFT JOIN usuarios ut    ON ut.id = t.asignada_a
+		WHERE mm.mensaje_id = ANY($1)
+		ORDER BY mm.tipo, mm.texto
+	`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+
+	indice := make(map[string]int, len(lista))
+	for i, m := range lista {
+		indice[m.ID] = i
+	}
+	for _, f := range filas {
+		men := models.Mencion{Tipo: f.Tipo, Texto: f.Texto}
+		switch f.Tipo {
+		case models.MencionUsuario:
+			if f.UsuarioNombre == nil {
+				continue
+			}
+			objetivo := f.Objetivo
+			men.UsuarioID, men.Nombre = &objetivo, f.UsuarioNombre
+		case models.MencionRol:
+			rol := models.Rol(f.Objetivo)
+			men.Rol = &rol
+		case models.MencionIncidencia:
+			if f.IncEstado == nil {
+				continue // borrada después del mensaje
+			}
+			men.Incidencia = &models.ResumenIncidencia{
+				ID: f.Objetivo, Tipo: *f.IncTipo, Estado: *f.IncEstado,
+				ZonaID: *f.IncZonaID, ZonaNombre: f.IncZonaNombre,
+				Descripcion: *f.IncDescripcion, NombreAsignado: f.IncAsignado,
+			}
+		case models.MencionTarea:
+			if f.TareaEstado == nil {
+				continue
+			}
+			men.Tarea = &models.ResumenTarea{
+				ID: f.Objetivo, Titulo: *f.TareaTitulo, Estado: *f.TareaEstado,
+				Prioridad: *f.TareaPrioridad, ZonaID: f.TareaZonaID,
+				ZonaNombre: f.TareaZonaNombre, NombreAsignado: f.TareaAsignado,
+			}
+		}
+		i := indice[f.MensajeID]
+		lista[i].Menciones = append(lista[i].Menciones, men)
+	}
+	return nil
+}
+
+// DestinatariosMencion devuelve los usuarios a notificar por las menciones del
+// mensaje: los mencionados directamente y los del rol mencionado en el evento.
+// Excluye al autor. El filtro de pertenencia a la conversación lo hace quien llama.
+func (r *MensajeRepo) DestinatariosMencion(ctx context.Context, mensajeID string) ([]models.Usuario, error) {
+	var lista []models.Usuario
+	err := r.db.SelectContext(ctx, &lista, `
+		SELECT DISTINCT u.id, u.nombre_usuario, u.nombre, u.rol, u.evento_id, u.activo, u.creado_en
+		FROM mensajes m
+		JOIN mensajes_menciones mm ON mm.mensaje_id = m.id
+		JOIN usuarios u ON (mm.tipo = 'usuario' AND u.id::text = mm.objetivo)
+		                OR (mm.tipo = 'rol' AND u.rol = mm.objetivo
+		                    AND (u.evento_id = m.evento_id OR u.rol = 'admin'))
+		WHERE m.id = $1 AND u.activo = true AND u.id <> m.usuario_id
+	`, mensajeID)
+	return lista, err
+}
+
+// ─── Moderación ───────────────────────────────────────────────────────────────
+
+type ModeracionRepo struct{ db *sqlx.DB }
+
+func NewModeracionRepo(db *sqlx.DB) *ModeracionRepo { return &ModeracionRepo{db: db} }
+
+// Silenciar impide al usuario escribir en el chat del evento durante `minutos`.
+// Si ya estaba silenciado, el nuevo plazo reemplaza al anterior.
+func (r *ModeracionRepo) Silenciar(ctx context.Context, eventoID, adminID string, req *models.SilenciarRequest) (*models.Silencio, error) {
+	var s models.Silencio
+	err := r.db.GetContext(ctx, &s, `
+		INSERT INTO chat_silencios (evento_id, usuario_id, hasta, motivo, creado_por)
+		VALUES ($1, $2, NOW() + make_interval(mins => $3), NULLIF($4, ''), $5)
+		ON CONFLICT (evento_id, usuario_id) DO UPDATE
+		SET hasta = EXCLUDED.hasta, motivo = EXCLUDED.motivo,
+		    creado_por = EXCLUDED.creado_por, creado_en = NOW()
+		RETURNING evento_id, usuario_id, hasta, motivo, creado_por, creado_en
+	`, eventoID, req.UsuarioID, req.Minutos, req.Motivo, adminID)
+	return &s, err
+}
+
+func (r *ModeracionRepo) Reactivar(ctx context.Context, eventoID, usuarioID string) error {
+	res, err := r.db.ExecContext(ctx, `
+		DELETE FROM chat_silencios WHERE evento_id = $1 AND usuario_id = $2 AND hasta > NOW()
+	`, eventoID, usuarioID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNoEncontrado
+	}
+	return nil
+}
+
+// SilencioActivo devuelve el silencio vigente del usuario o nil
+func (r *ModeracionRepo) SilencioActivo(ctx context.Context, eventoID, usuarioID string) (*models.Silencio, error) {
+	var s models.Silencio
+	err := r.db.GetContext(ctx, &s, `
+		SELECT evento_id, usuario_id, hasta, motivo, creado_por, creado_en
+		FROM chat_silencios WHERE evento_id = $1 AND usuario_id = $2 AND hasta > NOW()
+	`, eventoID, usuarioID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return &s, err
+}
+
+func (r *ModeracionRepo) ListarSilencios(ctx context.Context, eventoID string) ([]models.Silencio, error) {
+	var lista []models.Silencio
+	err := r.db.SelectContext(ctx, &lista, `
+		SELECT evento_id, usuario_id, hasta, motivo, creado_por, creado_en
+		FROM chat_silencios WHERE evento_id = $1 AND hasta > NOW()
+		ORDER BY hasta
+	`, eventoID)
+	return lista, err
+}
+
+func (r *ModeracionRepo) ListarFiltros(ctx context.Context, eventoID string) ([]models.FiltroChat, error) {
+	var lista []models.FiltroChat
+	err := r.db.SelectContext(ctx, &lista, `
+		SELECT id, evento_id, palabra, accion, creado_en
+		FROM chat_filtros WHERE evento_id = $1
+		ORDER BY palabra
+	`, eventoID)
+	return lista, err
+}
+
+func (r *ModeracionRepo) CrearFiltro(ctx context.Context, eventoID string, req *models.CrearFiltroRequest) (*models.FiltroChat, error) {
+	var f models.FiltroChat
+	err := r.db.GetContext(ctx, &f, `
+		INSERT INTO chat_filtros (evento_id, palabra, accion) VALUES ($1, LOWER($2), $3)
+		RETURNING id, evento_id, palabra, accion, creado_en
+	`, eventoID, req.Palabra, req.Accion)
+	return &f, err
+}
+
+func (r *ModeracionRepo) EliminarFiltro(ctx context.Context, eventoID, id string) error {
+	res, err := r.db.ExecContext(ctx, `
+		DELETE FROM chat_filtros WHERE id = $1 AND evento_id = $2
+	`, id, eventoID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNoEncontrado
+	}
+	return nil
+}
+
+// ─── Conversación ─────────────────────────────────────────────────────────────
+
+type ConversacionRepo struct{ db *sqlx.DB }
+
+func NewConversacionRepo(db *sqlx.DB) *ConversacionRepo { return &ConversacionRepo{db: db} }
+
+const columnasConversacion = `c.id, c.evento_id, c.tipo, c.nombre, c.rol, c.zona_id, c.creada_por, c.creada_en`
+
+// canalesBase: nombre y rol de cada canal por rol que se crea con el evento
+// (028 hizo lo mismo con los eventos que ya existían)
+func canalesBase() (nombres, roles []string) {
+	for _, rol := range []models.Rol{models.RolAseo, models.RolGuardia, models.RolMedico, models.RolLogistica, models.RolSupervisor} {
+		nombres = append(nombres, rol.Etiqueta())
+		roles = append(roles, string(rol))
+	}
+	return nombres, roles
+}
+
+// ObtenerGeneral devuelve la sala general del evento
+func (r *ConversacionRepo) ObtenerGeneral(ctx context.Context, eventoID string) (*models.Conversacion, error) {
+	var conv models.Conversacion
+	err := r.db.GetContext(ctx, &conv, `
+		SELECT `+columnasConversacion+` FROM conversaciones c
+		WHERE c.evento_id = $1 AND c.tipo = 'evento'
+	`, eventoID)
+	return &conv, err
+}
+
+func (r *ConversacionRepo) ObtenerPorID(ctx context.Context, id string) (*models.Conversacion, error) {
+	var conv models.Conversacion
+	err := r.db.GetContext(ctx, &conv, `
+		SELECT `+columnasConversacion+` FROM conversaciones c WHERE c.id = $1
+	`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return &conv, err
+}
+
+// ListarDeUsuario devuelve las conversaciones visibles para el usuario con su conteo de no leídos
+func (r *ConversacionRepo) ListarDeUsuario(ctx context.Context, eventoID, usuarioID string, rol models.Rol) ([]models.Conversacion, error) {
+	var lista []models.Conversacion
+	err := r.db.SelectContext(ctx, &lista, `
+		SELECT c.id, c.evento_id, c.tipo,
+		       CASE WHEN c.tipo = 'directo' THEN COALESCE(o.nombre, c.nombre) ELSE c.nombre END AS nombre,
+		       c.rol, c.zona_id, c.creada_por, c.creada_en,
+		       (SELECT COUNT(*) FROM mensajes m
+		        WHERE m.conversacion_id = c.id AND m.usuario_id <> $2 AND m.eliminado_en IS NULL
+		          AND m.enviado_en > COALESCE(l.leido_hasta, '-infinity')) AS no_leidos
+		FROM conversaciones c
+		LEFT JOIN conversaciones_lecturas l ON l.conversacion_id = c.id AND l.usuario_id = $2
+		LEFT JOIN LATERAL (
+			SELECT u.nombre FROM conversaciones_miembros cm
+			JOIN usuarios u ON u.id = cm.usuario_id
+			WHERE cm.conversacion_id = c.id AND cm.usuario_id <> $2
+			LIMIT 1
+		) o ON c.tipo = 'directo'
+		WHERE c.evento_id = $1
+		  AND (c.tipo = 'evento'
+		       OR (c.tipo = 'rol' AND (c.rol = $3 OR $3 = 'admin'))
+		       OR EXISTS (SELECT 1 FROM conversaciones_miembros cm
+		                  WHERE cm.conversacion_id = c.id AND cm.usuario_id = $2))
+		ORDER BY CASE c.tipo WHEN 'evento' THEN 0 WHEN 'rol' THEN 1 ELSE 2 END, c.creada_en
+	`, eventoID, usuarioID, rol)
+	return lista, err
+}
+
+// ContarNoLeidos resume los no leídos del usuario por conversación y en total
+func (r *ConversacionRepo) ContarNoLeidos(ctx context.Context, eventoID, usuarioID string, rol models.Rol) (*models.NoLeidosResponse, error) {
+	resp := &models.NoLeidosResponse{Conversaciones: []models.NoLeidosConversacion{}}
+	if eventoID == "" {
+		return resp, nil
+	}
+	lista, err := r.ListarDeUsuario(ctx, eventoID, usuarioID, rol)
+	if err != nil {
+		return nil, err
+	}
+	for _, conv := range lista {
+		resp.Total += conv.NoLeidos
+		resp.Conversaciones = append(resp.Conversaciones, models.NoLeidosConversacion{
+			ConversacionID: conv.ID,
+			NoLeidos:       conv.NoLeidos,
+		})
+	}
+	return resp, nil
+}
+
+// Crear da de alta un grupo o canal de zona con sus miembros (el creador incluido)
+func (r *ConversacionRepo) Crear(ctx context.Context, eventoID, creadorID string, req *models.CrearConversacionRequest) (*models.Conversacion, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var zonaID *string
+	if req.ZonaID != "" {
+		zonaID = &req.ZonaID
+	}
+	var conv models.Conversacion
+	err = tx.GetContext(ctx, &conv, `
+		INSERT INTO conversaciones (evento_id, tipo, nombre, zona_id, creada_por)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, evento_id, tipo, nombre, rol, zona_id, creada_por, creada_en
+	`, eventoID, req.Tipo, req.Nombre, zonaID, creadorID)
+	if err != nil {
+		return nil, err
+	}
+	if err := agregarMiembros(ctx, tx, conv.ID, append([]string{creadorID}, req.Miembros...)); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &conv, nil
+}
+
+// AbrirDirecto devuelve la conversación 1:1 entre solicitante y otro, creándola la primera vez
+func (r *ConversacionRepo) AbrirDirecto(ctx context.Context, eventoID, solicitanteID, otroID string) (*models.Conversacion, error) {
+	a, b := solicitanteID, otroID
+	if b < a {
+		a, b = b, a
+	}
+	clave := a + ":" + b
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var conv models.Conversacion
+	err = tx.GetContext(ctx, &conv, `
+		INSERT INTO conversaciones (evento_id, tipo, clave_directo, creada_por)
+		VALUES ($1, 'directo', $2, $3)
+		ON CONFLICT (evento_id, clave_directo) WHERE tipo = 'directo'
+		DO UPDATE SET clave_directo = EXCLUDED.clave_directo
+		RETURNING id, evento_id, tipo, nombre, rol, zona_id, creada_por, creada_en
+	`, eventoID, clave, solicitanteID)
+	if err != nil {
+		return nil, err
+	}
+	if err := agregarMiembros(ctx, tx, conv.ID, []string{a, b}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &conv, nil
+}
+
+func (r *ConversacionRepo) AgregarMiembros(ctx context.Context, conversacionID string, usuarioIDs []string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := agregarMiembros(ctx, tx, conversacionID, usuarioIDs); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// AjenosAlEvento devuelve los ids de usuarioIDs que no son usuarios activos del
+// evento (los inexistentes incluidos). El admin no está atado a un evento.
+func (r *ConversacionRepo) AjenosAlEvento(ctx context.Context, eventoID string, usuarioIDs []string) ([]string, error) {
+	ajenos := []string{}
+	if len(usuarioIDs) == 0 {
+		return ajenos, nil
+	}
+	err := r.db.SelectContext(ctx, &ajenos, `
+		SELECT DISTINCT x.id FROM unnest($2::text[]) AS x(id)
+		WHERE NOT EXISTS (
+			SELECT 1 FROM usuarios u
+			WHERE u.id::text = x.id AND u.activo
+			  AND (u.rol = 'admin' OR u.evento_id = $1)
+		)
+	`, eventoID, pq.Array(usuarioIDs))
+	return ajenos, err
+}
+
+// ZonaDelEvento indica si la zona existe en el evento y no está archivada
+func (r *ConversacionRepo) ZonaDelEvento(ctx context.Context, zonaID, eventoID string) (bool, error) {
+	var ok bool
+	err := r.db.GetContext(ctx, &ok, `
+		SELECT EXISTS (SELECT 1 FROM zonas WHERE id = $1 AND evento_id = $2 AND archivada_en IS NULL)
+	`, zonaID, eventoID)
+	return ok, err
+}
+
+func agregarMiembros(ctx context.Context, tx *sqlx.Tx, conversacionID string, usuarioIDs []string) error {
+	for _, id := range usuarioIDs {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO conversaciones_miembros (conversacion_id, usuario_id) VALUES ($1, $2)
+			ON CONFLICT DO NOTHING
+		`, conversacionID, id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EsMiembro: la sala general es de todo el evento, los canales de rol de ese
+// rol (y del admin), el resto exige fila en conversaciones_miembros.
+func (r *ConversacionRepo) EsMiembro(ctx context.Context, conv *models.Conversacion, usuarioID string, rol models.Rol) (bool, error) {
+	switch conv.Tipo {
+	case models.ConversacionEvento:
+		return true, nil
+	case models.ConversacionRol:
+		return rol == models.RolAdmin || (conv.Rol != nil && *conv.Rol == rol), nil
+	}
+	var existe bool
+	err := r.db.GetContext(ctx, &existe, `
+		SELECT EXISTS (SELECT 1 FROM conversaciones_miembros WHERE conversacion_id = $1 AND usuario_id = $2)
+	`, conv.ID, usuarioID)
+	return existe, err
+}
+
+// MiembrosIDs lista los usuarios que deben recibir por WS lo que pase en la
+// conversación. No aplica a la sala general, que se publica a todo el evento.
+func (r *ConversacionRepo) MiembrosIDs(ctx context.Context, conv *models.Conversacion) ([]string, error) {
+	var ids []string
+	if conv.Tipo == models.ConversacionRol {
+		err := r.db.SelectContext(ctx, &ids, `
+			SELECT id FROM usuarios
+			WHERE activo = true AND ((evento_id = $1 AND rol = $2) OR rol = 'admin')
+		`, conv.EventoID, conv.Rol)
+		return ids, err
+	}
+	err := r.db.SelectContext(ctx, &ids, `
+		SELECT usuario_id FROM conversaciones_miembros WHERE conversacion_id = $1
+	`, conv.ID)
+	return ids, err
+}
+
+// MarcarLeido mueve el cursor de lectura del usuario hasta mensajeID. Nunca
+// retrocede: devuelve nil si el mensaje no es de la conversación o es anterior
+// al cursor actual, y la lectura registrada si avanzó.
+func (r *ConversacionRepo) MarcarLeido(ctx context.Context, conversacionID, usuarioID, mensajeID string) (*models.LecturaMensaje, error) {
+	var l models.LecturaMensaje
+	err := r.db.GetContext(ctx, &l, `
+		WITH upserted AS (
+			INSERT INTO conversaciones_lecturas (conversacion_id, usuario_id, leido_hasta, ultimo_mensaje_id)
+			SELECT m.conversacion_id, $2::uuid, m.enviado_en, m.id
+			FROM mensajes m WHERE m.id = $3 AND m.conversacion_id = $1
+			ON CONFLICT (conversacion_id, usuario_id) DO UPDATE
+			SET leido_hasta = EXCLUDED.leido_hasta, ultimo_mensaje_id = EXCLUDED.ultimo_mensaje_id,
+			    leido_en = NOW()
+			WHERE conversaciones_lecturas.leido_hasta < EXCLUDED.leido_hasta
+			RETURNING conversacion_id, usuario_id, ultimo_mensaje_id, leido_en
+		)
+		SELECT up.conversacion_id, up.usuario_id, u.nombre AS nombre_usuario,
+		       up.ultimo_mensaje_id AS mensaje_id, up.leido_en
+		FROM upserted up
+		JOIN usuarios u ON u.id = up.usuario_id
+	`, conversacionID, usuarioID, mensajeID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return &l, err
+}
+
+// ListarLecturas devuelve quién (además del autor) ya leyó el mensaje
+func (r *ConversacionRepo) ListarLecturas(ctx context.Context, conversacionID, mensajeID string) ([]models.LecturaMensaje, error) {
+	var lista []models.LecturaMensaje
+	err := r.db.SelectContext(ctx, &lista, `
+		SELECT l.conversacion_id, l.usuario_id, u.nombre AS nombre_usuario,
+		       m.id AS mensaje_id, l.leido_en
+		FROM mensajes m
+		JOIN conversaciones_lecturas l ON l.conversacion_id = m.conversacion_id
+		JOIN usuarios u ON u.id = l.usuario_id
+		WHERE m.id = $2 AND m.conversacion_id = $1
+		  AND l.leido_hasta >= m.enviado_en AND l.usuario_id <> m.usuario_id
+		ORDER BY u.nombre
+	`, conversacionID, mensajeID)
+	return lista, err
+}
@@ -0,0 +1,512 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"strings"
+
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ─── Cierre de evento ─────────────────────────────────────────────────────────
+
+var (
+	ErrCierreEnCurso  = errors.New("el evento ya tiene un cierre pendiente")
+	ErrDestinoCierre  = errors.New("el evento destino no existe o no está en borrador o programado")
+	ErrCierreNoAplica = errors.New("el evento ya no está en curso")
+)
+
+type CierreRepo struct{ db *sqlx.DB }
+
+func NewCierreRepo(db *sqlx.DB) *CierreRepo { return &CierreRepo{db: db} }
+
+const columnasCierre = `id, evento_id, estado, opciones, motivo, solicitado_por, intentos, error, reporte, creado_en, terminado_en`
+
+// CierreEjecutado es lo que queda por avisar después del commit
+type CierreEjecutado struct {
+	Cierre   *models.CierreEvento
+	Evento   *models.Evento
+	Personal []string // cuentas cuyas sesiones hay que revocar
+	// SOS que el cierre dio por resueltos y a quiénes avisar (leídos antes
+	// de sacar al personal del evento)
+	SOS          []models.SOS
+	RespuestaSOS []string
+}
+
+// Pendientes: lo abierto que el cierre va a resolver o trasladar. Con
+// destinoID, además, las zonas de eso que el destino no tiene.
+func (r *CierreRepo) Pendientes(ctx context.Context, eventoID, destinoID string) (*models.PendientesCierre, error) {
+	p := &models.PendientesCierre{EventoID: eventoID, Incidencias: []models.ResumenIncidencia{}, Tareas: []models.ResumenTarea{}}
+	err := r.db.SelectContext(ctx, &p.Incidencias, `
+		SELECT i.id, i.tipo, i.estado, i.zona_id, z.nombre AS zona_nombre, i.descripcion, u.nombre AS nombre_asignado
+		FROM incidencias i
+		LEFT JOIN zonas z ON z.id = i.zona_id AND z.evento_id = i.evento_id
+		LEFT JOIN usuarios u ON u.id = i.asignada_a
+		WHERE i.evento_id = $1 AND i.estado <> 'resuelta'
+		ORDER BY i.creada_en
+	`, eventoID)
+	if err != nil {
+		return nil, err
+	}
+	err = r.db.SelectContext(ctx, &p.Tareas, `
+		SELECT t.id, t.titulo, t.estado, t.prioridad, t.zona_id, z.nombre AS zona_nombre, u.nombre AS nombre_asignado
+		FROM tareas t
+		LEFT JOIN zonas z ON z.id = t.zona_id AND z.evento_id = t.evento_id
+		LEFT JOIN usuarios u ON u.id = t.asignada_a
+		WHERE t.evento_id = $1 AND t.estado NOT IN ('completada','cancelada')
+		ORDER BY t.creada_en
+	`, eventoID)
+	if err != nil {
+		return nil, err
+	}
+	var conteos struct {
+		Rondas   int `db:"rondas"`
+		Turnos   int `db:"turnos"`
+		Anuncios int `db:"anuncios"`
+		Personal int `db:"personal"`
+	}
+	err = r.db.GetContext(ctx, &conteos, `
+		SELECT
+			(SELECT COUNT(*) FROM rondas WHERE evento_id = $1 AND estado = 'en_curso')   AS rondas,
+			(SELECT COUNT(*) FROM turnos WHERE evento_id = $1 AND fin IS NULL)           AS turnos,
+			(SELECT COUNT(*) FROM anuncios WHERE evento_id = $1 AND cerrado_en IS NULL)  AS anuncios,
+			(SELECT COUNT(*) FROM usuarios WHERE evento_id = $1 AND rol <> 'admin')      AS personal
+	`, eventoID)
+	if err != nil {
+		return nil, err
+	}
+	p.RondasEnCurso, p.TurnosAbiertos, p.AnunciosAbiertos, p.Personal = conteos.Rondas, conteos.Turnos, conteos.Anuncios, conteos.Personal
+	if destinoID != "" {
+		if p.ZonasACopiar, err = zonasFaltantes(ctx, r.db, eventoID, destinoID, true, true); err != nil {
+			return nil, err
+		}
+	}
+	if p.UltimoCierre, err = r.Ultimo(ctx, eventoID); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Crear deja el cierre pendiente para el trabajo
+func (r *CierreRepo) Crear(ctx context.Context, eventoID string, o models.OpcionesCierre, motivo models.MotivoTransicion, usuarioID *string) (*models.CierreEvento, error) {
+	var c models.CierreEvento
+	err := r.db.GetContext(ctx, &c, `
+		INSERT INTO cierres_evento (evento_id, opciones, motivo, solicitado_por)
+		VALUES ($1, $2::jsonb, $3, $4)
+		RETURNING `+columnasCierre, eventoID, o, motivo, usuarioID)
+	if err != nil && (strings.Contains(err.Error(), "duplicate") || strings.Contains(err.Error(), "unique")) {
+		return nil, ErrCierreEnCurso
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Ultimo cierre pedido para el evento; nil si nunca se pidió
+func (r *CierreRepo) Ultimo(ctx context.Context, eventoID string) (*models.CierreEvento, error) {
+	var c models.CierreEvento
+	err := r.db.GetContext(ctx, &c, `
+		SELECT `+columnasCierre+` FROM cierres_evento WHERE evento_id = $1 ORDER BY creado_en DESC LIMIT 1
+	`, eventoID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return &c, err
+}
+
+// MarcarFallo registra el error. Un fallo definitivo (el evento ya no está en
+// curso, el destino no sirve) o el último intento lo dejan fallido; si no,
+// sigue pendiente para la próxima revisión.
+func (r *CierreRepo) MarcarFallo(ctx context.Context, id string, causa error, definitivo bool, maxIntentos int) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE cierres_evento SET
+			intentos     = intentos + 1,
+			error        = $2,
+			estado       = CASE WHEN $3 OR intentos + 1 >= $4 THEN 'fallido' ELSE 'pendiente' END,
+			terminado_en = CASE WHEN $3 OR intentos + 1 >= $4 THEN NOW() END
+		WHERE id = $1 AND estado = 'pendiente'
+	`, id, causa.Error(), definitivo, maxIntentos)
+	return err
+}
+
+// RevocacionPendiente es un cierre ejecutado que todavía no revocó las
+// sesiones de su personal
+type RevocacionPendiente struct {
+	models.CierreEvento
+	Personal pq.StringArray `db:"personal"`
+}
+
+// RevocacionesPendientes: los cierres en estado revocando, el más viejo primero
+func (r *CierreRepo) RevocacionesPendientes(ctx context.Context) ([]RevocacionPendiente, error) {
+	var lista []RevocacionPendiente
+	err := r.db.SelectContext(ctx, &lista, `
+		SELECT `+columnasCierre+`, personal FROM cierres_evento
+		WHERE estado = 'revocando' ORDER BY terminado_en
+	`)
+	return lista, err
+}
+
+// TerminarRevocacion completa el cierre una vez revocadas las sesiones. Con
+// causa != nil solo registra el error: sigue revocando hasta lograrlo.
+func (r *CierreRepo) TerminarRevocacion(ctx context.Context, id string, causa error) error {
+	if causa != nil {
+		_, err := r.db.ExecContext(ctx, `
+			UPDATE cierres_evento SET error = $2 WHERE id = $1 AND estado = 'revocando'
+		`, id, "revocando sesiones: "+causa.Error())
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE cierres_evento SET estado = 'completado', error = NULL WHERE id = $1 AND estado = 'revocando'
+	`, id)
+	return err
+}
+
+// RestaurarPersonal: al reabrir, vuelve a vincular (y activar) a quienes
+// desvinculó el último cierre, salvo a quien ya esté en otro evento. Si ese
+// cierre todavía estaba revocando sesiones, ya no hace falta: se completa.
+func (r *CierreRepo) RestaurarPersonal(ctx context.Context, eventoID string) (int, error) {
+	res, err := r.db.ExecContext(ctx, `
+		WITH ultimo AS (
+			SELECT id, personal FROM cierres_evento
+			WHERE evento_id = $1 AND estado IN ('revocando','completado')
+			ORDER BY terminado_en DESC LIMIT 1
+		), revocacion AS (
+			UPDATE cierres_evento c SET estado = 'completado', error = NULL
+			FROM ultimo WHERE c.id = ultimo.id AND c.estado = 'revocando'
+		)
+		UPDATE usuarios SET evento_id = $1, activo = true
+		WHERE evento_id IS NULL AND id = ANY((SELECT personal FROM ultimo))
+	`, eventoID)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// EjecutarSiguiente toma un cierre pendiente y lo hace entero en una
+// transacción: si algo falla (o la instancia cae) no queda nada a medias. Con
+// varias instancias cada una toma uno distinto. Devuelve nil si no hay
+// ninguno; ante un error devuelve igual el cierre, para registrarlo.
+func (r *CierreRepo) EjecutarSiguiente(ctx context.Context, saltar []string) (*CierreEjecutado, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var c models.CierreEvento
+	err = tx.GetContext(ctx, &c, `
+		SELECT `+columnasCierre+` FROM cierres_evento
+		WHERE estado = 'pendiente' AND id <> ALL($1::uuid[])
+		ORDER BY creado_en LIMIT 1
+		FOR UPDATE SKIP LOCKED
+	`, pq.Array(saltar))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	res := &CierreEjecutado{Cierre: &c}
+	if err := cerrar(ctx, tx, res); err != nil {
+		return res, err
+	}
+	if err := tx.Commit(); err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
+func cerrar(ctx context.Context, tx *sqlx.Tx, res *CierreEjecutado) error {
+	c := res.Cierre
+	o := c.Opciones.ConDefectos()
+	ev, err := eventoBloqueado(ctx, tx, c.EventoID)
+	if err != nil {
+		return err
+	}
+	if !ev.Estado.EnCurso() {
+		return ErrCierreNoAplica
+	}
+	reporte, err := reporteBase(ctx, tx, ev)
+	if err != nil {
+		return err
+	}
+	reporte.Nota = o.Nota
+
+	// Traslado: las zonas de lo abierto que el destino no tenga se le copian
+	var destino string
+	if o.Traslada() {
+		if o.DestinoEventoID == nil {
+			return ErrDestinoCierre
+		}
+		destino = *o.DestinoEventoID
+		d, err := eventoBloqueado(ctx, tx, destino)
+		if errors.Is(err, ErrNoEncontrado) || (err == nil && d.Estado != models.EventoBorrador && d.Estado != models.EventoProgramado) {
+			return ErrDestinoCierre
+		}
+		if err != nil {
+			return err
+		}
+		faltan, err := zonasFaltantes(ctx, tx, ev.ID, destino, o.Incidencias == models.PendientesTrasladar, o.Tareas == models.PendientesTrasladar)
+		if err != nil {
+			return err
+		}
+		if len(faltan) > 0 {
+			_, err := tx.ExecContext(ctx, `
+				INSERT INTO zonas (id, evento_id, nombre, tipo, padre_id, latitud, longitud, radio_m, poligono, piso, capacidad, umbral_ocupacion)
+				SELECT z.id, $2, z.nombre, z.tipo,
+				       CASE WHEN z.padre_id = ANY($3) OR EXISTS (
+				                 SELECT 1 FROM zonas d WHERE d.id = z.padre_id AND d.evento_id = $2)
+				            THEN z.padre_id END,
+				       z.latitud, z.longitud, z.radio_m, z.poligono, z.piso, z.capacidad, z.umbral_ocupacion
+				FROM zonas z WHERE z.evento_id = $1 AND z.id = ANY($3)
+			`, ev.ID, destino, pq.Array(faltan))
+			if err != nil {
+				return err
+			}
+		}
+		reporte.DestinoEventoID, reporte.ZonasCopiadas = &destino, len(faltan)
+	}
+
+	if o.Incidencias == models.PendientesTrasladar {
+		reporte.Incidencias.Trasladadas, err = trasladarIncidencias(ctx, tx, ev.ID, destino)
+	} else {
+		var sosIDs []string
+		err = tx.SelectContext(ctx, &sosIDs, `
+			SELECT s.id FROM sos_alertas s JOIN incidencias i ON i.id = s.incidencia_id
+			WHERE s.evento_id = $1 AND i.estado <> 'resuelta'
+		`, ev.ID)
+		if err != nil {
+			return err
+		}
+		reporte.Incidencias.ResueltasAlCierre, err = contar(ctx, tx, `
+			WITH previas AS (
+				SELECT id, estado FROM incidencias WHERE evento_id = $1 AND estado <> 'resuelta' FOR UPDATE
+			), resueltas AS (
+				UPDATE incidencias i SET estado = 'resuelta' FROM previas WHERE i.id = previas.id
+			), historial AS (
+				INSERT INTO incidencias_historial (incidencia_id, estado_anterior, estado_nuevo, usuario_id, origen)
+				SELECT id, estado, 'resuelta', $2, 'cierre' FROM previas
+			)
+			SELECT COUNT(*) FROM previas
+		`, ev.ID, c.SolicitadoPor)
+		if err == nil && len(sosIDs) > 0 {
+			err = tx.SelectContext(ctx, &res.SOS, selectSOS+` WHERE s.id = ANY($1)`, pq.Array(sosIDs))
+			if err == nil {
+				res.RespuestaSOS, err = idsRespuestaSOS(ctx, tx, ev.ID)
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if o.Tareas == models.PendientesTrasladar {
+		reporte.Tareas.Trasladadas, err = contar(ctx, tx, `
+			WITH movidas AS (
+				UPDATE tareas t SET
+					evento_id  = $2,
+					asignada_a = CASE WHEN u.id IS NOT NULL THEN t.asignada_a END,
+					estado     = CASE WHEN u.id IS NOT NULL THEN t.estado ELSE 'pendiente' END
+				FROM tareas o
+				LEFT JOIN usuarios u ON u.id = o.asignada_a AND u.evento_id = $2
+				WHERE t.id = o.id AND o.evento_id = $1 AND o.estado NOT IN ('completada','cancelada')
+				RETURNING t.id
+			)
+			SELECT COUNT(*) FROM movidas
+		`, ev.ID, destino)
+	} else {
+		// Las tareas no se dan por completadas: nadie las hizo ni subió la
+		// evidencia que exigían
+		reporte.Tareas.CanceladasAlCierre, err = contar(ctx, tx, `
+			WITH previas AS (
+				SELECT id, estado FROM tareas WHERE evento_id = $1 AND estado NOT IN ('completada','cancelada') FOR UPDATE
+			), canceladas AS (
+				UPDATE tareas t SET estado = 'cancelada' FROM previas WHERE t.id = previas.id
+			), historial AS (
+				INSERT INTO tareas_historial (tarea_id, estado_anterior, estado_nuevo, usuario_id, origen)
+				SELECT id, estado, 'cancelada', $2, 'cierre' FROM previas
+			)
+			SELECT COUNT(*) FROM previas
+		`, ev.ID, c.SolicitadoPor)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Lo que sigue corriendo se corta. Los turnos abiertos los cierra el
+	// rastreador en su próxima revisión (motivo "evento"), que además los
+	// saca del mapa en vivo.
+	_, err = tx.ExecContext(ctx, `
+		UPDATE rondas SET estado = 'cancelada', vence_en = NULL, terminada_en = NOW()
+		WHERE evento_id = $1 AND estado = 'en_curso'
+	`, ev.ID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `UPDATE anuncios SET cerrado_en = NOW() WHERE evento_id = $1 AND cerrado_en IS NULL`, ev.ID)
+	if err != nil {
+		return err
+	}
+	err = tx.GetContext(ctx, &reporte.Rondas, `
+		SELECT COUNT(*) AS total,
+		       COUNT(*) FILTER (WHERE estado = 'completada') AS completadas,
+		       COUNT(*) FILTER (WHERE estado = 'incompleta') AS incompletas,
+		       COUNT(*) FILTER (WHERE estado = 'cancelada')  AS canceladas
+		FROM rondas WHERE evento_id = $1
+	`, ev.ID)
+	if err != nil {
+		return err
+	}
+
+	// Personal: fuera del evento, y desactivado si se pidió
+	var cuentas []struct {
+		ID  string `db:"id"`
+		Rol string `db:"rol"`
+	}
+	err = tx.SelectContext(ctx, &cuentas, `
+		UPDATE usuarios SET evento_id = NULL, activo = CASE WHEN $2 THEN false ELSE activo END
+		WHERE evento_id = $1 AND rol <> 'admin'
+		RETURNING id, rol
+	`, ev.ID, o.Personal == models.PersonalDesactivar)
+	if err != nil {
+		return err
+	}
+	reporte.Personal = models.ReportePersonal{Total: len(cuentas), PorRol: map[string]int{}}
+	for _, u := range cuentas {
+		res.Personal = append(res.Personal, u.ID)
+		reporte.Personal.PorRol[u.Rol]++
+	}
+	if o.Personal == models.PersonalDesactivar {
+		reporte.Personal.Desactivados = len(cuentas)
+	} else {
+		reporte.Personal.Desvinculados = len(cuentas)
+	}
+
+	res.Evento, err = cambiarEstado(ctx, tx, ev.ID, ev.Estado, models.EventoTerminado, c.Motivo, c.SolicitadoPor, nil)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrCierreNoAplica
+	}
+	if err != nil {
+		return err
+	}
+	reporte.TerminadoEn = *res.Evento.TerminadoEn
+	if res.Evento.IniciadoEn != nil {
+		reporte.DuracionMin = int(reporte.TerminadoEn.Sub(*res.Evento.IniciadoEn).Minutes())
+	}
+
+	// Con personal queda revocando: sus sesiones se revocan en Redis después
+	// del commit, y el cierre no se completa hasta lograrlo
+	return tx.GetContext(ctx, c, `
+		UPDATE cierres_evento SET
+			estado = CASE WHEN cardinality($2::uuid[]) > 0 THEN 'revocando' ELSE 'completado' END,
+			terminado_en = NOW(), error = NULL,
+			intentos = intentos + 1, personal = $2::uuid[], reporte = $3::jsonb
+		WHERE id = $1
+		RETURNING `+columnasCierre, c.ID, pq.Array(res.Personal), reporte)
+}
+
+// reporteBase cuenta lo que pasó en el evento antes de que el cierre toque nada
+func reporteBase(ctx context.Context, tx *sqlx.Tx, ev *models.Evento) (*models.ReporteFinal, error) {
+	r := &models.ReporteFinal{EventoID: ev.ID, Nombre: ev.Nombre, IniciadoEn: ev.IniciadoEn}
+	err := tx.GetContext(ctx, &r.Incidencias, `
+		SELECT COUNT(*) AS total,
+		       COUNT(*) FILTER (WHERE estado = 'resuelta') AS resueltas,
+		       0 AS resueltas_al_cierre, 0 AS trasladadas,
+		       (SELECT AVG(EXTRACT(EPOCH FROM h.cambiado_en - i.creada_en) / 60)
+		          FROM incidencias_historial h JOIN incidencias i ON i.id = h.incidencia_id
+		         WHERE i.evento_id = $1 AND h.estado_nuevo = 'resuelta' AND h.origen <> 'cierre') AS resolucion_promedio_min
+		FROM incidencias WHERE evento_id = $1
+	`, ev.ID)
+	if err != nil {
+		return nil, err
+	}
+	if p := r.Incidencias.ResolucionPromedioMin; p != nil {
+		*p = math.Round(*p*10) / 10
+	}
+	var porTipo []struct {
+		Tipo  string `db:"tipo"`
+		Total int    `db:"total"`
+	}
+	err = tx.SelectContext(ctx, &porTipo, `
+		SELECT tipo, COUNT(*) AS total FROM incidencias WHERE evento_id = $1 GROUP BY tipo
+	`, ev.ID)
+	if err != nil {
+		return nil, err
+	}
+	r.Incidencias.PorTipo = map[string]int{}
+	for _, t := range porTipo {
+		r.Incidencias.PorTipo[t.Tipo] = t.Total
+	}
+	err = tx.GetContext(ctx, &r.Tareas, `
+		SELECT COUNT(*) AS total, COUNT(*) FILTER (WHERE estado = 'completada') AS completadas,
+		       0 AS canceladas_al_cierre, 0 AS trasladadas
+		FROM tareas WHERE evento_id = $1
+	`, ev.ID)
+	if err != nil {
+		return nil, err
+	}
+	var otros struct {
+		SOS      int `db:"sos"`
+		Checkins int `db:"checkins"`
+		Mensajes int `db:"mensajes"`
+		Anuncios int `db:"anuncios"`
+	}
+	err = tx.GetContext(ctx, &otros, `
+		SELECT
+			(SELECT COUNT(*) FROM sos_alertas WHERE evento_id = $1) AS sos,
+			(SELECT COUNT(*) FROM checkins WHERE evento_id = $1)    AS checkins,
+			(SELECT COUNT(*) FROM mensajes WHERE evento_id = $1)    AS mensajes,
+			(SELECT COUNT(*) FROM anuncios WHERE evento_id = $1)    AS anuncios
+	`, ev.ID)
+	r.SOS, r.Checkins, r.Mensajes, r.Anuncios = otros.SOS, otros.Checkins, otros.Mensajes, otros.Anuncios
+	return r, err
+}
+
+// trasladarIncidencias pasa las abiertas (y sus SOS) al destino. Quedan
+// asignadas solo si su responsable ya está en el destino; si no, pendientes.
+func trasladarIncidencias(ctx context.Context, tx *sqlx.Tx, eventoID, destino string) (int, error) {
+	return contar(ctx, tx, `
+		WITH movidas AS (
+			UPDATE incidencias i SET
+				evento_id  = $2,
+				asignada_a = CASE WHEN u.id IS NOT NULL THEN i.asignada_a END,
+				estado     = CASE WHEN u.id IS NOT NULL THEN i.estado ELSE 'pendiente' END
+			FROM incidencias o
+			LEFT JOIN usuarios u ON u.id = o.asignada_a AND u.evento_id = $2
+			WHERE i.id = o.id AND o.evento_id = $1 AND o.estado <> 'resuelta'
+			RETURNING i.id
+		), sos AS (
+			UPDATE sos_alertas s SET evento_id = $2 FROM movidas WHERE s.incidencia_id = movidas.id
+		)
+		SELECT COUNT(*) FROM movidas
+	`, eventoID, destino)
+}
+
+// zonasFaltantes: zonas de incidencias o tareas abiertas del evento que el destino no tiene
+func zonasFaltantes(ctx context.Context, q sqlx.QueryerContext, eventoID, destino string, incidencias, tareas bool) ([]string, error) {
+	ids := []string{}
+	err := sqlx.SelectContext(ctx, q, &ids, `
+		SELECT DISTINCT x.zona_id FROM (
+			SELECT zona_id FROM incidencias WHERE evento_id = $1 AND estado <> 'resuelta' AND $3
+			UNION
+			SELECT zona_id FROM tareas WHERE evento_id = $1 AND estado NOT IN ('completada','cancelada') AND zona_id IS NOT NULL AND $4
+		) x
+		WHERE EXISTS (SELECT 1 FROM zonas z WHERE z.id = x.zona_id AND z.evento_id = $1)
+		  AND NOT EXISTS (SELECT 1 FROM zonas d WHERE d.id = x.zona_id AND d.evento_id = $2)
+		ORDER BY x.zona_id
+	`, eventoID, destino, incidencias, tareas)
+	return ids, err
+}
+
+func contar(ctx context.Context, tx *sqlx.Tx, query string, args ...interface{}) (int, error) {
+	var n int
+	err := tx.GetContext(ctx, &n, query, args...)
+	return n, err
+}
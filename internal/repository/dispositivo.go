@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// ─── Dispositivo IoT ──────────────────────────────────────────────────────────
+
+type DispositivoRepo struct{ db *sqlx.DB }
+
+func NewDispositivoRepo(db *sqlx.DB) *DispositivoRepo { return &DispositivoRepo{db: db} }
+
+const columnasDispositivo = `id, evento_id, nombre, zona_id, campo_zona, tema_mqtt, reglas, ventana_segundos,
+	activo, creado_por, creado_en, ultimo_dato_en, ultimo_payload, version`
+
+func (r *DispositivoRepo) Crear(ctx context.Context, eventoID, adminID, tokenHash string, req *models.CrearDispositivoRequest) (*models.Dispositivo, error) {
+	ventana := 300
+	if req.VentanaSegundos != nil {
+		ventana = *req.VentanaSegundos
+	}
+	var d models.Dispositivo
+	err := r.db.GetContext(ctx, &d, `
+		INSERT INTO dispositivos (evento_id, nombre, zona_id, campo_zona, tema_mqtt, reglas, ventana_segundos, token_hash, creado_por)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), $6::jsonb, $7, $8, $9)
+		RETURNING `+columnasDispositivo+`
+	`, eventoID, req.Nombre, req.ZonaID, req.CampoZona, req.TemaMQTT, models.ReglasDispositivo(req.Reglas), ventana, tokenHash, adminID)
+	return &d, err
+}
+
+func (r *DispositivoRepo) Listar(ctx context.Context, eventoID string) ([]models.Dispositivo, error) {
+	var lista []models.Dispositivo
+	err := r.db.SelectContext(ctx, &lista, `
+		SELECT `+columnasDispositivo+` FROM dispositivos WHERE evento_id = $1 ORDER BY nombre
+	`, eventoID)
+	return lista, err
+}
+
+func (r *DispositivoRepo) ObtenerPorID(ctx context.Context, id string) (*models.Dispositivo, error) {
+	var d models.Dispositivo
+	err := r.db.GetContext(ctx, &d, `SELECT `+columnasDispositivo+` FROM dispositivos WHERE id = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return &d, err
+}
+
+// ObtenerPorToken autentica la lectura entrante; solo dispositivos activos
+func (r *DispositivoRepo) ObtenerPorToken(ctx context.Context, tokenHash string) (*models.Dispositivo, error) {
+	var d models.Dispositivo
+	err := r.db.GetContext(ctx, &d, `
+		SELECT `+columnasDispositivo+` FROM dispositivos WHERE token_hash = $1 AND activo
+	`, tokenHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return &d, err
+}
+
+// ObtenerPorTema identifica al dispositivo que publicó por MQTT
+func (r *DispositivoRepo) ObtenerPorTema(ctx context.Context, tema string) (*models.Dispositivo, error) {
+	var d models.Dispositivo
+	err := r.db.GetContext(ctx, &d, `
+		SELECT `+columnasDispositivo+` FROM dispositivos WHERE tema_mqtt = $1 AND activo
+	`, tema)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return &d, err
+}
+
+func (r *DispositivoRepo) Editar(ctx context.Context, id string, req *models.EditarDispositivoRequest, version *int) (*models.Dispositivo, error) {
+	var reglas interface{}
+	if req.Reglas != nil {
+		reglas = models.ReglasDispositivo(req.Reglas)
+	}
+	var d models.Dispositivo
+	err := r.db.GetContext(ctx, &d, `
+		UPDATE dispositivos
+		SET nombre = COALESCE($2, nombre), zona_id = COALESCE($3, zona_id),
+		    campo_zona = CASE WHEN $4::text IS NULL THEN campo_zona ELSE NULLIF($4, '') END,
+		    tema_mqtt = CASE WHEN $5::text IS NULL THEN tema_mqtt ELSE NULLIF($5, '') END,
+		    reglas = COALESCE($6::jsonb, reglas),
+		    ventana_segundos = COALESCE($7, ventana_segundos), activo = COALESCE($8, activo)
+		WHERE id = $1 AND ($9::int IS NULL OR version = $9)
+		RETURNING `+columnasDispositivo+`
+	`, id, req.Nombre, req.ZonaID, req.CampoZona, req.TemaMQTT, reglas, req.VentanaSegundos, req.Activo, version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, sinCambio(ctx, r.db, version, `SELECT 1 FROM dispositivos WHERE id = $1`, id)
+	}
+	return &d, err
+}
+
+// RotarToken invalida el token anterior al instante
+func (r *DispositivoRepo) RotarToken(ctx context.Context, id, tokenHash string) (*models.Dispositivo, error) {
+	var d models.Dispositivo
+	err := r.db.GetContext(ctx, &d, `
+		UPDATE dispositivos SET token_hash = $2 WHERE id = $1
+		RETURNING `+columnasDispositivo+`
+	`, id, tokenHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoEncontrado
+	}
+	return &d, err
+}
+
+func (r *DispositivoRepo) Eliminar(ctx context.Context, id string) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM dispositivos WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	n, _ := res.RowsAffected()
+	if n == 0 {
+		return ErrNoEncontrado
+	}
+	return nil
+}
+
+// RegistrarDato guarda la última lectura para diagnóstico desde el panel
+func (r *DispositivoRepo) RegistrarDato(ctx context.Context, id string, payload []byte) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE dispositivos SET ultimo_dato_en = NOW(), ultimo_payload = $2::jsonb WHERE id = $1
+	`, id, string(payload))
+	return err
+}
+
+// ZonaExiste valida zonas que llegan en el payload o en las reglas
+func (r *DispositivoRepo) ZonaExiste(ctx context.Context, eventoID, zonaID string) (bool, error) {
+	var existe bool
+	err := r.db.GetContext(ctx, &existe, `
+		SELECT EXISTS(SELECT 1 FROM zonas WHERE id = $1 AND evento_id = $2 AND archivada_en IS NULL)
+	`, zonaID, eventoID)
+	return existe, err
+}
+
+// ReclamarAlerta decide, de forma atómica, si esta lectura abre incidencia.
+// Devuelve true si no hay alerta igual dentro de la ventana (y la reserva).
+// Si no, suma la lectura a `suprimidas` y devuelve la incidencia ya abierta.
+func (r *DispositivoRepo) ReclamarAlerta(ctx context.Context, dispositivoID string, tipo models.TipoIncidencia, zonaID string, ventanaSegundos int) (bool, *string, int, error) {
+	var reclamada bool
+	err := r.db.GetContext(ctx, &reclamada, `
+		INSERT INTO dispositivos_alertas (dispositivo_id, tipo, zona_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (dispositivo_id, tipo, zona_id) DO UPDATE
+		SET desde = NOW(), suprimidas = 0, incidencia_id = NULL
+		WHERE dispositivos_alertas.desde < NOW() - make_interval(secs => $4)
+		RETURNING true
+	`, dispositivoID, tipo, zonaID, ventanaSegundos)
+	if err == nil {
+		return reclamada, nil, 0, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return false, nil, 0, err
+	}
+
+	var previa struct {
+		IncidenciaID *string `db:"incidencia_id"`
+		Suprimidas   int     `db:"suprimidas"`
+	}
+	err = r.db.GetContext(ctx, &previa, `
+		UPDATE dispositivos_alertas SET suprimidas = suprimidas + 1
+		WHERE dispositivo_id = $1 AND tipo = $2 AND zona_id = $3
+		RETURNING incidencia_id, suprimidas
+	`, dispositivoID, tipo, zonaID)
+	return false, previa.IncidenciaID, previa.Suprimidas, err
+}
+
+// VincularAlerta anota la incidencia creada para las lecturas siguientes
+func (r *DispositivoRepo) VincularAlerta(ctx context.Context, dispositivoID string, tipo models.TipoIncidencia, zonaID, incidenciaID string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE dispositivos_alertas SET incidencia_id = $4
+		WHERE dispositivo_id = $1 AND tipo = $2 AND zona_id = $3
+	`, dispositivoID, tipo, zonaID, incidenciaID)
+	return err
+}
+
+// LiberarAlerta deshace la reserva si no se pudo crear la incidencia, para
+// que la próxima lectura lo vuelva a intentar
+func (r *DispositivoRepo) LiberarAlerta(ctx context.Context, dispositivoID string, tipo models.TipoIncidencia, zonaID string) error {
+	_, err := r.db.ExecContext(ctx, `
+		DELETE FROM dispositivos_alertas WHERE dispositivo_id = $1 AND tipo = $2 AND zona_id = $3
+	`, dispositivoID, tipo, zonaID)
+	return err
+}
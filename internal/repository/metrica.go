@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ─── Métricas en vivo ─────────────────────────────────────────────────────────
+
+type MetricaRepo struct{ db *sqlx.DB }
+
+func NewMetricaRepo(db *sqlx.DB) *MetricaRepo { return &MetricaRepo{db: db} }
+
+// Ventanas de los tiempos de respuesta, en minutos
+var VentanasTiempos = []int{15, 60, 240}
+
+// recientes: ventana de las incidencias "recientes" de una zona caliente
+const recientes = 30 * time.Minute
+
+// Metricas arma el tablero con agregados de Postgres. Lo que vive en Redis
+// (ubicaciones, aforo) lo agrega quien llama.
+func (r *MetricaRepo) Metricas(ctx context.Context, eventoID string) (*models.MetricasEvento, error) {
+	m := &models.MetricasEvento{
+		EventoID: eventoID,
+		Incidencias: models.MetricasIncidencias{
+			PorEstado: map[string]int{}, PorTipo: map[string]int{}, PorZona: []models.ConteoZona{},
+		},
+		Tareas:         models.MetricasTareas{PorPrioridad: map[string]int{}, PorEstado: map[string]int{}},
+		Personal:       models.MetricasPersonal{EnTurnoPorRol: map[string]int{}, ConUbicacionPorRol: map[string]int{}},
+		ZonasCalientes: []models.ZonaCaliente{},
+		GeneradoEn:     time.Now(),
+	}
+
+	var incidencias []struct {
+		Estado string `db:"estado"`
+		Tipo   string `db:"tipo"`
+		models.ConteoZona
+	}
+	err := r.db.SelectContext(ctx, &incidencias, `
+		SELECT i.estado, i.tipo, i.zona_id, z.nombre AS zona_nombre, COUNT(*) AS total
+		FROM incidencias i
+		LEFT JOIN zonas z ON z.id = i.zona_id AND z.evento_id = i.evento_id
+		WHERE i.evento_id = $1 AND i.estado <> 'resuelta'
+		GROUP BY i.estado, i.tipo, i.zona_id, z.nombre
+		ORDER BY i.zona_id
+	`, eventoID)
+	if err != nil {
+		return nil, err
+	}
+	porZona := map[string]int{}
+	for _, f := range incidencias {
+		m.Incidencias.Abiertas += f.Total
+		m.Incidencias.PorEstado[f.Estado] += f.Total
+		m.Incidencias.PorTipo[f.Tipo] += f.Total
+		i, ok := porZona[f.ZonaID]
+		if !ok {
+			i = len(m.Incidencias.PorZona)
+			porZona[f.ZonaID] = i
+			m.Incidencias.PorZona = append(m.Incidencias.PorZona, models.ConteoZona{ZonaID: f.ZonaID, ZonaNombre: f.ZonaNombre})
+		}
+		m.Incidencias.PorZona[i].Total += f.Total
+	}
+
+	var tareas []struct {
+		Prioridad  string `db:"prioridad"`
+		Estado     string `db:"estado"`
+		SinAsignar bool   `db:"sin_asignar"`
+		Total      int    `db:"total"`
+	}
+	err = r.db.SelectContext(ctx, &tareas, `
+		SELECT prioridad, estado, asignada_a IS NULL AS sin_asignar, COUNT(*) AS total
+		FROM tareas WHERE evento_id = $1 AND estado NOT IN ('completada','cancelada')
+		GROUP BY 1, 2, 3
+	`, eventoID)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range tareas {
+		m.Tareas.Pendientes += f.Total
+		m.Tareas.PorPrioridad[f.Prioridad] += f.Total
+		m.Tareas.PorEstado[f.Estado] += f.Total
+		if f.SinAsignar {
+			m.Tareas.SinAsignar += f.Total
+		}
+	}
+
+	// El primer en_atencion y el primer resuelta de cada incidencia tocada
+	// dentro de la ventana más larga; cada ventana promedia los suyos. Lo
+	// que resolvió el cierre del evento no cuenta.
+	ventanaMax := VentanasTiempos[len(VentanasTiempos)-1]
+	m.Tiempos = []models.VentanaTiempos{}
+	err = r.db.SelectContext(ctx, &m.Tiempos, `
+		WITH primeros AS (
+			SELECT i.creada_en,
+			       MIN(h.cambiado_en) FILTER (WHERE h.estado_nuevo = 'en_atencion') AS atendida_en,
+			       MIN(h.cambiado_en) FILTER (WHERE h.estado_nuevo = 'resuelta')    AS resuelta_en
+			FROM incidencias i
+			JOIN incidencias_historial h ON h.incidencia_id = i.id AND h.origen <> 'cierre'
+			WHERE i.evento_id = $1 AND i.actualizada_en >= NOW() - make_interval(mins => $3)
+			GROUP BY i.id
+		)
+		SELECT v.minutos,
+			COUNT(*) FILTER (WHERE p.atendida_en >= NOW() - make_interval(mins => v.minutos)) AS atendidas,
+			ROUND((AVG(EXTRACT(EPOCH FROM p.atendida_en - p.creada_en) / 60)
+				FILTER (WHERE p.atendida_en >= NOW() - make_interval(mins => v.minutos)))::numeric, 1)::float8 AS atencion_promedio_min,
+			COUNT(*) FILTER (WHERE p.resuelta_en >= NOW() - make_interval(mins => v.minutos)) AS resueltas,
+			ROUND((AVG(EXTRACT(EPOCH FROM p.resuelta_en - p.creada_en) / 60)
+				FILTER (WHERE p.resuelta_en >= NOW() - make_interval(mins => v.minutos)))::numeric, 1)::float8 AS resolucion_promedio_min
+		FROM unnest($2::int[]) AS v(minutos)
+		LEFT JOIN primeros p ON true
+		GROUP BY v.minutos ORDER BY v.minutos
+	`, eventoID, pq.Array(VentanasTiempos), ventanaMax)
+	if err != nil {
+		return nil, err
+	}
+
+	var turnos []struct {
+		Rol   string `db:"rol"`
+		Total int    `db:"total"`
+	}
+	err = r.db.SelectContext(ctx, &turnos, `
+		SELECT u.rol, COUNT(*) AS total
+		FROM turnos t JOIN usuarios u ON u.id = t.usuario_id
+		WHERE t.evento_id = $1 AND t.fin IS NULL
+		GROUP BY u.rol
+	`, eventoID)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range turnos {
+		m.Personal.EnTurno += f.Total
+		m.Personal.EnTurnoPorRol[f.Rol] = f.Total
+	}
+
+	err = r.db.SelectContext(ctx, &m.ZonasCalientes, `
+		SELECT i.zona_id, z.nombre AS zona_nombre,
+		       COUNT(*) FILTER (WHERE i.estado <> 'resuelta') AS abiertas,
+		       COUNT(*) FILTER (WHERE i.creada_en >= NOW() - make_interval(secs => $2)) AS recientes
+		FROM incidencias i
+		LEFT JOIN zonas z ON z.id = i.zona_id AND z.evento_id = i.evento_id
+		WHERE i.evento_id = $1 AND (i.estado <> 'resuelta' OR i.creada_en >= NOW() - make_interval(secs => $2))
+		GROUP BY i.zona_id, z.nombre
+	`, eventoID, recientes.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
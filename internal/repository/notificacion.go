@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ─── Notificación ─────────────────────────────────────────────────────────────
+
+type NotificacionRepo struct{ db *sqlx.DB }
+
+func NewNotificacionRepo(db *sqlx.DB) *NotificacionRepo { return &NotificacionRepo{db: db} }
+
+// Los valores por defecto viven aquí y en la migración: sin fila de
+// preferencias el usuario recibe todo, solo por push.
+const selectPreferencias = `
+	SELECT u.id AS usuario_id,
+	       COALESCE(p.canales, '{push}') AS canales,
+	       COALESCE(p.tipos, '{asignacion,escalamiento,anuncio,ronda}') AS tipos,
+	       p.email, p.telefono, p.silencio_desde, p.silencio_hasta,
+	       COALESCE(p.zona_horaria, 'UTC') AS zona_horaria,
+	       COALESCE(p.urgentes_en_silencio, true) AS urgentes_en_silencio
+	FROM usuarios u
+	LEFT JOIN notif_preferencias p ON p.usuario_id = u.id`
+
+func (r *NotificacionRepo) ObtenerPreferencias(ctx context.Context, usuarioID string) (*models.PreferenciasNotificacion, error) {
+	var p models.PreferenciasNotificacion
+	err := r.db.GetContext(ctx, &p, selectPreferencias+` WHERE u.id = $1`, usuarioID)
+	return &p, err
+}
+
+// PreferenciasDe carga las preferencias de varios usuarios activos a la vez
+func (r *NotificacionRepo) PreferenciasDe(ctx context.Context, usuarioIDs []string) ([]models.PreferenciasNotificacion, error) {
+	var lista []models.PreferenciasNotificacion
+	err := r.db.SelectContext(ctx, &lista, selectPreferencias+`
+		WHERE u.id = ANY($1) AND u.activo = true
+	`, pq.Array(usuarioIDs))
+	return lista, err
+}
+
+func (r *NotificacionRepo) GuardarPreferencias(ctx context.Context, usuarioID string, req *models.PreferenciasNotificacionRequest) (*models.PreferenciasNotificacion, error) {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO notif_preferencias (usuario_id, canales, tipos, email, telefono,
+		                                silencio_desde, silencio_hasta, zona_horaria, urgentes_en_silencio)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (usuario_id) DO UPDATE
+		SET canales = EXCLUDED.canales, tipos = EXCLUDED.tipos, email = EXCLUDED.email,
+		    telefono = EXCLUDED.telefono, silencio_desde = EXCLUDED.silencio_desde,
+		    silencio_hasta = EXCLUDED.silencio_hasta, zona_horaria = EXCLUDED.zona_horaria,
+		    urgentes_en_silencio = EXCLUDED.urgentes_en_silencio, actualizada_en = NOW()
+	`, usuarioID, pq.Array(req.Canales), pq.Array(req.Tipos), req.Email, req.Telefono,
+		req.SilencioDesde, req.SilencioHasta, req.ZonaHoraria, req.UrgentesEnSilencio == nil || *req.UrgentesEnSilencio)
+	if err != nil {
+		return nil, err
+	}
+	return r.ObtenerPreferencias(ctx, usuarioID)
+}
+
+// Suscribir registra (o reasigna) la suscripción push de un navegador
+func (r *NotificacionRepo) Suscribir(ctx context.Context, usuarioID string, req *models.SuscribirPushRequest) (*models.SuscripcionPush, error) {
+	var s models.SuscripcionPush
+	err := r.db.GetContext(ctx, &s, `
+		INSERT INTO notif_push_suscripciones (usuario_id, endpoint, p256dh, auth)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (endpoint) DO UPDATE
+		SET usuario_id = EXCLUDED.usuario_id, p256dh = EXCLUDED.p256dh,
+		    auth = EXCLUDED.auth, creada_en = NOW()
+		RETURNING id, usuario_id, endpoint, p256dh, auth, creada_en
+	`, usuarioID, req.Endpoint, req.Keys.P256dh, req.Keys.Auth)
+	return &s, err
+}
+
+// Desuscribir borra una suscripción del usuario (usuarioID vacío = cualquiera,
+// para las que el servicio push da por vencidas).
+func (r *NotificacionRepo) Desuscribir(ctx context.Context, usuarioID, endpoint string) error {
+	res, err := r.db.ExecContext(ctx, `
+		DELETE FROM notif_push_suscripciones
+		WHERE endpoint = $1 AND ($2 = '' OR usuario_id::text = $2)
+	`, endpoint, usuarioID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNoEncontrado
+	}
+	return nil
+}
+
+func (r *NotificacionRepo) SuscripcionesDe(ctx context.Context, usuarioID string) ([]models.SuscripcionPush, error) {
+	var lista []models.SuscripcionPush
+	err := r.db.SelectContext(ctx, &lista, `
+		SELECT id, usuario_id, endpoint, p256dh, auth, creada_en
+		FROM notif_push_suscripciones WHERE usuario_id = $1
+	`, usuarioID)
+	return lista, err
+}
+
+func (r *NotificacionRepo) RegistrarEnvio(ctx context.Context, usuarioID string, n models.Notificacion, canal *models.CanalNotificacion, estado models.EstadoEnvio, detalle string) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO notif_envios (usuario_id, tipo, canal, titulo, cuerpo, estado, detalle)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''))
+	`, usuarioID, n.Tipo, canal, n.Titulo, n.Cuerpo, estado, detalle)
+	return err
+}
+
+// ListarEnvios devuelve las últimas notificaciones del usuario
+func (r *NotificacionRepo) ListarEnvios(ctx context.Context, usuarioID string, limite int) ([]models.EnvioNotificacion, error) {
+	var lista []models.EnvioNotificacion
+	err := r.db.SelectContext(ctx, &lista, `
+		SELECT id, usuario_id, tipo, canal, titulo, cuerpo, estado, detalle, creado_en
+		FROM notif_envios WHERE usuario_id = $1
+		ORDER BY creado_en DESC
+		LIMIT $2
+	`, usuarioID, limite)
+	return lista, err
+}
+
+// ─── Escalamiento ─────────────────────────────────────────────────────────────
+
+// IncidenciaEscalada es lo necesario para avisar a supervisión
+type IncidenciaEscalada struct {
+	ID          string    `db:"id"`
+	EventoID    string    `db:"evento_id"`
+	ZonaID      string    `db:"zona_id"`
+	ZonaNombre  *string   `db:"zona_nombre"`
+	Tipo        string    `db:"tipo"`
+	Descripcion string    `db:"descripcion"`
+	CreadaEn    time.Time `db:"creada_en"`
+}
+
+// TomarIncidenciasParaEscalar marca como escaladas las incidencias que llevan
+// más de `minutos` pendientes y las devuelve. Cada una se escala una sola vez.
+func (r *NotificacionRepo) TomarIncidenciasParaEscalar(ctx context.Context, minutos int) ([]IncidenciaEscalada, error) {
+	var lista []IncidenciaEscalada
+	err := r.db.SelectContext(ctx, &lista, `
+		WITH nuevas AS (
+			INSERT INTO incidencias_escalamientos (incidencia_id)
+			SELECT i.id FROM incidencias i
+			JOIN eventos e ON e.id = i.evento_id AND e.estado = 'activo'
+			WHERE i.estado = 'pendiente'
+			  AND i.creada_en < NOW() - make_interval(mins => $1)
+			ON CONFLICT DO NOTHING
+			RETURNING incidencia_id
+		)
+		SELECT i.id, i.evento_id, i.zona_id, z.nombre AS zona_nombre, i.tipo, i.descripcion, i.creada_en
+		FROM nuevas n
+		JOIN incidencias i ON i.id = n.incidencia_id
+		LEFT JOIN zonas z ON z.id = i.zona_id AND z.evento_id = i.evento_id
+	`, minutos)
+	return lista, err
+}
+
+// IDsMando devuelve los admins y los supervisores del evento
+func (r *NotificacionRepo) IDsMando(ctx context.Context, eventoID string) ([]string, error) {
+	var ids []string
+	err := r.db.SelectContext(ctx, &ids, `
+		SELECT id FROM usuarios
+		WHERE activo = true AND (rol = 'admin' OR (rol = 'supervisor' AND evento_id = $1))
+	`, eventoID)
+	return ids, err
+}
@@ -0,0 +1,305 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// ─── Patrullas ────────────────────────────────────────────────────────────────
+
+var (
+	ErrRondaEnCurso     = errors.New("ya hay una ronda en curso")
+	ErrZonaNoDisponible = errors.New("zona inexistente o archivada")
+)
+
+type PatrullaRepo struct{ db *sqlx.DB }
+
+func NewPatrullaRepo(db *sqlx.DB) *PatrullaRepo { return &PatrullaRepo{db: db} }
+
+// ─── Check-in ───
+
+const selectCheckin = `
+	SELECT c.id, c.evento_id, c.zona_id, z.nombre AS zona_nombre, c.usuario_id,
+	       u.nombre AS nombre_usuario, c.ronda_id, c.registrado_en
+	FROM checkins c
+	JOIN zonas z ON z.id = c.zona_id AND z.evento_id = c.evento_id
+	JOIN usuarios u ON u.id = c.usuario_id`
+
+func (r *PatrullaRepo) GuardarCheckin(ctx context.Context, eventoID, zonaID, usuarioID string) (*models.Checkin, error) {
+	var id string
+	err := r.db.GetContext(ctx, &id, `
+		INSERT INTO checkins (evento_id, zona_id, usuario_id) VALUES ($1, $2, $3) RETURNING id
+	`, eventoID, zonaID, usuarioID)
+	if err != nil {
+		return nil, err
+	}
+	var c models.Checkin
+	err = r.db.GetContext(ctx, &c, selectCheckin+` WHERE c.id = $1`, id)
+	return &c, err
+}
+
+// Checkins filtra por zona y/o usuario (vacío = todos), del más nuevo al más viejo
+func (r *PatrullaRepo) Checkins(ctx context.Context, eventoID, zonaID, usuarioID string, desde time.Time, limite int) ([]models.Checkin, error) {
+	lista := []models.Checkin{}
+	err := r.db.SelectContext(ctx, &lista, selectCheckin+`
+		WHERE c.evento_id = $1 AND ($2 = '' OR c.zona_id = $2)
+		  AND ($3 = '' OR c.usuario_id::text = $3) AND c.registrado_en >= $4
+		ORDER BY c.registrado_en DESC LIMIT $5
+	`, eventoID, zonaID, usuarioID, desde, limite)
+	return lista, err
+}
+
+// ─── Rutas ───
+
+// CrearRuta guarda la ruta con sus puntos; todas las zonas deben existir en
+// el evento y no estar archivadas
+func (r *PatrullaRepo) CrearRuta(ctx context.Context, eventoID, creadaPor string, req *models.CrearRutaRequest) (*models.RutaPatrulla, error) {
+	tolerancia := 5
+	if req.ToleranciaMin != nil {
+		tolerancia = *req.ToleranciaMin
+	}
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var id string
+	err = tx.GetContext(ctx, &id, `
+		INSERT INTO rutas_patrulla (evento_id, nombre, tolerancia_min, creada_por)
+		VALUES ($1, $2, $3, $4) RETURNING id
+	`, eventoID, strings.TrimSpace(req.Nombre), tolerancia, creadaPor)
+	if err != nil {
+		return nil, err
+	}
+	for i, p := range req.Puntos {
+		res, err := tx.ExecContext(ctx, `
+			INSERT INTO rutas_puntos (ruta_id, orden, zona_id, intervalo_min)
+			SELECT $1, $2, z.id, $4 FROM zonas z
+			WHERE z.id = $3 AND z.evento_id = $5 AND z.archivada_en IS NULL
+		`, id, i+1, p.ZonaID, p.IntervaloMin, eventoID)
+		if err != nil {
+			return nil, err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return nil, ErrZonaNoDisponible
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.Ruta(ctx, id)
+}
+
+// Ruta con sus puntos en orden; nil si no existe
+func (r *PatrullaRepo) Ruta(ctx context.Context, id string) (*models.RutaPatrulla, error) {
+	var ruta models.RutaPatrulla
+	err := r.db.GetContext(ctx, &ruta, `
+		SELECT id, evento_id, nombre, tolerancia_min, activa, creada_por, creada_en
+		FROM rutas_patrulla WHERE id = $1
+	`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = r.db.SelectContext(ctx, &ruta.Puntos, `
+		SELECT p.orden, p.zona_id, z.nombre AS zona_nombre, p.intervalo_min
+		FROM rutas_puntos p
+		JOIN zonas z ON z.id = p.zona_id AND z.evento_id = $2
+		WHERE p.ruta_id = $1 ORDER BY p.orden
+	`, id, ruta.EventoID)
+	return &ruta, err
+}
+
+func (r *PatrullaRepo) Rutas(ctx context.Context, eventoID string, conInactivas bool) ([]models.RutaPatrulla, error) {
+	var ids []string
+	err := r.db.SelectContext(ctx, &ids, `
+		SELECT id FROM rutas_patrulla WHERE evento_id = $1 AND ($2 OR activa) ORDER BY nombre
+	`, eventoID, conInactivas)
+	if err != nil {
+		return nil, err
+	}
+	lista := make([]models.RutaPatrulla, 0, len(ids))
+	for _, id := range ids {
+		ruta, err := r.Ruta(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if ruta != nil {
+			lista = append(lista, *ruta)
+		}
+	}
+	return lista, nil
+}
+
+// DesactivarRuta: deja de ofrecerse; las rondas en curso siguen hasta terminar
+func (r *PatrullaRepo) DesactivarRuta(ctx context.Context, id, eventoID string) error {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE rutas_patrulla SET activa = false WHERE id = $1 AND evento_id = $2
+	`, id, eventoID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNoEncontrado
+	}
+	return nil
+}
+
+// ─── Rondas ───
+
+const selectRonda = `
+	SELECT r.id, r.ruta_id, rp.nombre AS ruta_nombre, r.evento_id, r.usuario_id,
+	       u.nombre AS nombre_usuario, r.estado, r.siguiente, r.vence_en, r.iniciada_en, r.terminada_en
+	FROM rondas r
+	JOIN rutas_patrulla rp ON rp.id = r.ruta_id
+	JOIN usuarios u ON u.id = r.usuario_id`
+
+func (r *PatrullaRepo) IniciarRonda(ctx context.Context, rutaID, eventoID, usuarioID string, venceEn time.Time) (*models.Ronda, error) {
+	var id string
+	err := r.db.GetContext(ctx, &id, `
+		INSERT INTO rondas (ruta_id, evento_id, usuario_id, vence_en) VALUES ($1, $2, $3, $4) RETURNING id
+	`, rutaID, eventoID, usuarioID, venceEn)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate") || strings.Contains(err.Error(), "unique") {
+			return nil, ErrRondaEnCurso
+		}
+		return nil, err
+	}
+	return r.Ronda(ctx, id)
+}
+
+// Ronda con sus marcas; nil si no existe
+func (r *PatrullaRepo) Ronda(ctx context.Context, id string) (*models.Ronda, error) {
+	return r.unaRonda(ctx, ` WHERE r.id = $1`, id)
+}
+
+// RondaEnCurso del usuario, o nil
+func (r *PatrullaRepo) RondaEnCurso(ctx context.Context, usuarioID string) (*models.Ronda, error) {
+	return r.unaRonda(ctx, ` WHERE r.usuario_id = $1 AND r.estado = 'en_curso'`, usuarioID)
+}
+
+func (r *PatrullaRepo) unaRonda(ctx context.Context, filtro string, arg string) (*models.Ronda, error) {
+	var ronda models.Ronda
+	err := r.db.GetContext(ctx, &ronda, selectRonda+filtro, arg)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ronda.Marcas, err = r.marcas(ctx, ronda.ID)
+	return &ronda, err
+}
+
+func (r *PatrullaRepo) marcas(ctx context.Context, rondaID string) ([]models.MarcaRonda, error) {
+	marcas := []models.MarcaRonda{}
+	err := r.db.SelectContext(ctx, &marcas, `
+		SELECT orden, zona_id, estado, esperado_en, marcada_en
+		FROM rondas_marcas WHERE ronda_id = $1 ORDER BY orden
+	`, rondaID)
+	return marcas, err
+}
+
+// Rondas del evento, las más nuevas primero; estado y usuario vacíos = todos
+func (r *PatrullaRepo) Rondas(ctx context.Context, eventoID, estado, usuarioID string, desde time.Time) ([]models.Ronda, error) {
+	lista := []models.Ronda{}
+	err := r.db.SelectContext(ctx, &lista, selectRonda+`
+		WHERE r.evento_id = $1 AND ($2 = '' OR r.estado = $2)
+		  AND ($3 = '' OR r.usuario_id::text = $3) AND r.iniciada_en >= $4
+		ORDER BY r.iniciada_en DESC LIMIT 500
+	`, eventoID, estado, usuarioID, desde)
+	if err != nil {
+		return nil, err
+	}
+	for i := range lista {
+		if lista[i].Marcas, err = r.marcas(ctx, lista[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return lista, nil
+}
+
+// Vencidas: rondas en curso cuyo punto siguiente ya pasó la tolerancia. Con
+// el evento pausado no se marcan omisiones.
+func (r *PatrullaRepo) Vencidas(ctx context.Context) ([]models.Ronda, error) {
+	lista := []models.Ronda{}
+	err := r.db.SelectContext(ctx, &lista, selectRonda+`
+		WHERE r.estado = 'en_curso' AND r.vence_en < NOW()
+		  AND EXISTS (SELECT 1 FROM eventos e WHERE e.id = r.evento_id AND e.estado = 'activo')
+	`)
+	return lista, err
+}
+
+// Desplazar corre el vencimiento de las rondas en curso del evento (al
+// reanudarlo, lo que duró la pausa)
+func (r *PatrullaRepo) Desplazar(ctx context.Context, eventoID string, d time.Duration) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE rondas SET vence_en = vence_en + make_interval(secs => $2)
+		WHERE evento_id = $1 AND estado = 'en_curso' AND vence_en IS NOT NULL
+	`, eventoID, d.Seconds())
+	return err
+}
+
+// Avanzar guarda las marcas nuevas y el estado resultante de la ronda. Solo
+// aplica si nadie la movió desde que se leyó (siguiente sigue igual); si no,
+// devuelve false y no toca nada. checkinID, si viene, queda vinculado a la ronda.
+func (r *PatrullaRepo) Avanzar(ctx context.Context, ronda *models.Ronda, siguienteLeido int, marcas []models.MarcaRonda, checkinID *string) (bool, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE rondas SET siguiente = $3, vence_en = $4, estado = $5,
+		       terminada_en = CASE WHEN $5 = 'en_curso' THEN NULL ELSE NOW() END
+		WHERE id = $1 AND estado = 'en_curso' AND siguiente = $2
+	`, ronda.ID, siguienteLeido, ronda.Siguiente, ronda.VenceEn, ronda.Estado)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	for _, m := range marcas {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO rondas_marcas (ronda_id, orden, zona_id, estado, esperado_en, marcada_en)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`, ronda.ID, m.Orden, m.ZonaID, m.Estado, m.EsperadoEn, m.MarcadaEn)
+		if err != nil {
+			return false, err
+		}
+	}
+	if checkinID != nil {
+		if _, err := tx.ExecContext(ctx, `UPDATE checkins SET ronda_id = $2 WHERE id = $1`, *checkinID, ronda.ID); err != nil {
+			return false, err
+		}
+	}
+	return true, tx.Commit()
+}
+
+// CancelarRonda termina la ronda en curso del usuario sin evaluar lo que falta
+func (r *PatrullaRepo) CancelarRonda(ctx context.Context, usuarioID string) (*models.Ronda, error) {
+	var id string
+	err := r.db.GetContext(ctx, &id, `
+		UPDATE rondas SET estado = 'cancelada', vence_en = NULL, terminada_en = NOW()
+		WHERE usuario_id = $1 AND estado = 'en_curso'
+		RETURNING id
+	`, usuarioID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoEncontrado
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r.Ronda(ctx, id)
+}
@@ -0,0 +1,512 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// ─── Plantillas de evento ─────────────────────────────────────────────────────
+
+type PlantillaRepo struct{ db *sqlx.DB }
+
+func NewPlantillaRepo(db *sqlx.DB) *PlantillaRepo { return &PlantillaRepo{db: db} }
+
+const columnasPlantilla = `p.id, p.nombre, p.descripcion, p.origen_evento_id, p.creada_por, p.creada_en,
+	COALESCE(jsonb_array_length(p.contenido->'zonas'), 0)    AS "resumen.zonas",
+	COALESCE(jsonb_array_length(p.contenido->'tareas'), 0)   AS "resumen.tareas",
+	COALESCE(jsonb_array_length(p.contenido->'personal'), 0) AS "resumen.personal",
+	COALESCE(jsonb_array_length(p.contenido->'rutas'), 0)    AS "resumen.rutas",
+	COALESCE(jsonb_array_length(p.contenido->'canales'), 0)  AS "resumen.canales",
+	COALESCE(jsonb_array_length(p.contenido->'filtros'), 0)  AS "resumen.filtros"`
+
+// Capturar arma el contenido de plantilla de un evento. El personal es quien
+// está vinculado hoy y quien trabajó en él (turnos o tareas asignadas), así
+// un evento pasado conserva su equipo aunque las cuentas ya estén en otro.
+func (r *PlantillaRepo) Capturar(ctx context.Context, eventoID string) (*models.ContenidoPlantilla, error) {
+	c := &models.ContenidoPlantilla{}
+
+	var zonas []models.Zona
+	err := r.db.SelectContext(ctx, &zonas, `
+		SELECT `+columnasZona+` FROM zonas WHERE evento_id = $1 AND archivada_en IS NULL ORDER BY id
+	`, eventoID)
+	if err != nil {
+		return nil, err
+	}
+	activas := make(map[string]bool, len(zonas))
+	for _, z := range zonas {
+		activas[z.ID] = true
+	}
+	c.Zonas = make([]models.CrearZonaRequest, 0, len(zonas))
+	for _, z := range zonas {
+		var padre *string
+		if z.PadreID != nil && activas[*z.PadreID] {
+			padre = z.PadreID
+		}
+		c.Zonas = append(c.Zonas, models.CrearZonaRequest{
+			ID: z.ID, Nombre: z.Nombre, Tipo: z.Tipo, PadreID: padre,
+			Latitud: z.Latitud, Longitud: z.Longitud, RadioM: z.RadioM, Poligono: z.Poligono, Piso: z.Piso,
+			Capacidad: z.Capacidad, UmbralOcupacion: z.UmbralOcupacion,
+		})
+	}
+	// Padres antes que hijas: el nivel estrictamente creciente lo garantiza
+	sort.SliceStable(c.Zonas, func(i, j int) bool { return c.Zonas[i].Tipo.Nivel() < c.Zonas[j].Tipo.Nivel() })
+
+	// Una tarea repetida (mismo título y zona) cuenta una vez
+	c.Tareas = []models.TareaPlantilla{}
+	err = r.db.SelectContext(ctx, &c.Tareas, `
+		SELECT DISTINCT ON (titulo, COALESCE(zona_id, ''))
+		       titulo, COALESCE(descripcion, '') AS descripcion, prioridad, zona_id, asignada_a, requiere_evidencia
+		FROM tareas WHERE evento_id = $1
+		ORDER BY titulo, COALESCE(zona_id, ''), creada_en
+	`, eventoID)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Personal = []models.MiembroPlantilla{}
+	err = r.db.SelectContext(ctx, &c.Personal, `
+		SELECT id, nombre_usuario, nombre, rol FROM usuarios
+		WHERE rol <> 'admin' AND (
+		      evento_id = $1
+		   OR id IN (SELECT usuario_id FROM turnos WHERE evento_id = $1)
+		   OR id IN (SELECT asignada_a FROM tareas WHERE evento_id = $1))
+		ORDER BY rol, nombre
+	`, eventoID)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Rutas = []models.RutaPlantilla{}
+	var rutas []struct {
+		ID string `db:"id"`
+		models.RutaPlantilla
+	}
+	err = r.db.SelectContext(ctx, &rutas, `
+		SELECT id, nombre, tolerancia_min FROM rutas_patrulla
+		WHERE evento_id = $1 AND activa ORDER BY nombre
+	`, eventoID)
+	if err != nil {
+		return nil, err
+	}
+	for _, ruta := range rutas {
+		err := r.db.SelectContext(ctx, &ruta.Puntos, `
+			SELECT orden, zona_id, intervalo_min FROM rutas_puntos WHERE ruta_id = $1 ORDER BY orden
+		`, ruta.ID)
+		if err != nil {
+			return nil, err
+		}
+		c.Rutas = append(c.Rutas, ruta.RutaPlantilla)
+	}
+
+	c.Canales = []models.CanalPlantilla{}
+	err = r.db.SelectContext(ctx, &c.Canales, `
+		SELECT c.tipo, c.nombre, c.zona_id,
+		       ARRAY(SELECT m.usuario_id::text FROM conversaciones_miembros m
+		             WHERE m.conversacion_id = c.id ORDER BY m.unido_en) AS miembros
+		FROM conversaciones c
+		WHERE c.evento_id = $1 AND c.tipo IN ('grupo','zona')
+		ORDER BY c.creada_en
+	`, eventoID)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Filtros = []models.FiltroPlantilla{}
+	err = r.db.SelectContext(ctx, &c.Filtros, `
+		SELECT palabra, accion FROM chat_filtros WHERE evento_id = $1 ORDER BY palabra
+	`, eventoID)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (r *PlantillaRepo) Crear(ctx context.Context, req *models.CrearPlantillaRequest, contenido *models.ContenidoPlantilla, adminID string) (*models.PlantillaEvento, error) {
+	var id string
+	err := r.db.GetContext(ctx, &id, `
+		INSERT INTO plantillas_evento (nombre, descripcion, contenido, origen_evento_id, creada_por)
+		VALUES ($1, $2, $3::jsonb, $4, $5) RETURNING id
+	`, strings.TrimSpace(req.Nombre), req.Descripcion, contenido, req.EventoID, adminID)
+	if err != nil {
+		return nil, err
+	}
+	return r.ObtenerPorID(ctx, id)
+}
+
+// Listar devuelve las plantillas sin su contenido, con lo que trae cada parte
+func (r *PlantillaRepo) Listar(ctx context.Context) ([]models.PlantillaEvento, error) {
+	lista := []models.PlantillaEvento{}
+	err := r.db.SelectContext(ctx, &lista, `
+		SELECT `+columnasPlantilla+` FROM plantillas_evento p ORDER BY p.creada_en DESC
+	`)
+	return lista, err
+}
+
+// ObtenerPorID devuelve la plantilla con su contenido; nil si no existe
+func (r *PlantillaRepo) ObtenerPorID(ctx context.Context, id string) (*models.PlantillaEvento, error) {
+	var p models.PlantillaEvento
+	err := r.db.GetContext(ctx, &p, `
+		SELECT `+columnasPlantilla+`, p.contenido FROM plantillas_evento p WHERE p.id = $1
+	`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return &p, err
+}
+
+func (r *PlantillaRepo) Eliminar(ctx context.Context, id string) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM plantillas_evento WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNoEncontrado
+	}
+	return nil
+}
+
+// ─── Instanciar ───
+
+// Instanciar copia las partes pedidas al evento destino (eventoID) o a uno
+// nuevo (nuevo), todo en una transacción. Lo que ya existe en el destino o
+// depende de algo que no está se omite, y cada decisión queda en el diff. En
+// vista previa se hace exactamente lo mismo y se deshace al final, así el diff
+// es el que resultaría al confirmar.
+func (r *PlantillaRepo) Instanciar(ctx context.Context, c *models.ContenidoPlantilla, partes map[models.ParteEvento]bool,
+	eventoID string, nuevo *models.CrearEventoRequest, adminID string, vistaPrevia bool) (*models.InstanciaEvento, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	inst := &models.InstanciaEvento{VistaPrevia: vistaPrevia, Resumen: map[models.ParteEvento]*models.ConteoCambios{}, Cambios: []models.CambioInstancia{}}
+	if nuevo != nil {
+		inst.Evento, err = insertarEvento(ctx, tx, nuevo, adminID)
+	} else {
+		inst.Evento, err = eventoBloqueado(ctx, tx, eventoID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	i := &instanciador{tx: tx, inst: inst, eventoID: inst.Evento.ID, adminID: adminID}
+
+	pasos := map[models.ParteEvento]func(context.Context, *models.ContenidoPlantilla) error{
+		models.ParteZonas:    i.zonas,
+		models.PartePersonal: i.personal,
+		models.ParteTareas:   i.tareas,
+		models.ParteReglas:   i.rutas,
+		models.ParteCanales:  i.canales,
+	}
+	for _, parte := range models.PartesEvento {
+		if !partes[parte] {
+			continue
+		}
+		if err := pasos[parte](ctx, c); err != nil {
+			return nil, fmt.Errorf("%s: %w", parte, err)
+		}
+	}
+
+	if vistaPrevia {
+		if nuevo != nil {
+			inst.Evento.ID = ""
+		}
+		return inst, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return inst, nil
+}
+
+func eventoBloqueado(ctx context.Context, tx *sqlx.Tx, eventoID string) (*models.Evento, error) {
+	var e models.Evento
+	err := tx.GetContext(ctx, &e, `SELECT `+columnasEvento+` FROM eventos WHERE id = $1 FOR UPDATE`, eventoID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoEncontrado
+	}
+	return &e, err
+}
+
+// instanciador aplica las partes en orden; cada una consulta el destino ya
+// modificado por las anteriores (zonas disponibles, personal vinculado)
+type instanciador struct {
+	tx       *sqlx.Tx
+	inst     *models.InstanciaEvento
+	eventoID string
+	adminID  string
+}
+
+// zonasDisponibles: las activas del destino
+func (i *instanciador) zonasDisponibles(ctx context.Context) (map[string]bool, error) {
+	var ids []string
+	err := i.tx.SelectContext(ctx, &ids, `
+		SELECT id FROM zonas WHERE evento_id = $1 AND archivada_en IS NULL
+	`, i.eventoID)
+	return conjunto(ids), err
+}
+
+// personalVinculado: cuentas del destino, más los admins (ven todos los eventos)
+func (i *instanciador) personalVinculado(ctx context.Context) (map[string]bool, error) {
+	var ids []string
+	err := i.tx.SelectContext(ctx, &ids, `
+		SELECT id FROM usuarios WHERE activo AND (evento_id = $1 OR rol = 'admin')
+	`, i.eventoID)
+	return conjunto(ids), err
+}
+
+func (i *instanciador) zonas(ctx context.Context, c *models.ContenidoPlantilla) error {
+	var existentes []string
+	err := i.tx.SelectContext(ctx, &existentes, `SELECT id FROM zonas WHERE evento_id = $1`, i.eventoID)
+	if err != nil {
+		return err
+	}
+	ya := conjunto(existentes)
+	for _, z := range c.Zonas {
+		if ya[z.ID] {
+			i.inst.Anotar(models.ParteZonas, models.CambioOmitir, z.ID, "ya existe en el evento")
+			continue
+		}
+		_, err := i.tx.ExecContext(ctx, `
+			INSERT INTO zonas (id, evento_id, nombre, tipo, padre_id, latitud, longitud, radio_m, poligono, piso, capacidad, umbral_ocupacion)
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8, $9::jsonb, $10, $11, $12)
+		`, z.ID, i.eventoID, z.Nombre, z.Tipo, z.PadreID, z.Latitud, z.Longitud, z.RadioM, z.Poligono, z.Piso,
+			z.Capacidad, z.UmbralOcupacion)
+		if err != nil {
+			return err
+		}
+		ya[z.ID] = true
+		i.inst.Anotar(models.ParteZonas, models.CambioCrear, z.ID, "")
+	}
+	return nil
+}
+
+// personal vincula cada cuenta al destino. No se lleva a nadie que esté en el
+// evento en curso o en otro que todavía no empieza.
+func (i *instanciador) personal(ctx context.Context, c *models.ContenidoPlantilla) error {
+	for _, m := range c.Personal {
+		var u struct {
+			Activo   bool    `db:"activo"`
+			EventoID *string `db:"evento_id"`
+			Estado   *string `db:"estado"`
+		}
+		err := i.tx.GetContext(ctx, &u, `
+			SELECT u.activo, u.evento_id, e.estado
+			FROM usuarios u LEFT JOIN eventos e ON e.id = u.evento_id
+			WHERE u.id = $1 AND u.rol <> 'admin'
+			FOR UPDATE OF u
+		`, m.UsuarioID)
+		motivo := ""
+		switch {
+		case errors.Is(err, sql.ErrNoRows) || (err == nil && !u.Activo):
+			motivo = "la cuenta ya no existe o está desactivada"
+		case err != nil:
+			return err
+		case u.EventoID != nil && *u.EventoID == i.eventoID:
+			motivo = "ya está en el evento"
+		case u.Estado != nil && models.EstadoEvento(*u.Estado).EnCurso():
+			motivo = "trabaja en el evento en curso"
+		case u.Estado != nil && *u.Estado != string(models.EventoTerminado):
+			motivo = "está vinculado a otro evento que todavía no empieza"
+		}
+		if motivo != "" {
+			i.inst.Anotar(models.PartePersonal, models.CambioOmitir, m.NombreUsuario, motivo)
+			continue
+		}
+		if _, err := i.tx.ExecContext(ctx, `UPDATE usuarios SET evento_id = $2 WHERE id = $1`, m.UsuarioID, i.eventoID); err != nil {
+			return err
+		}
+		i.inst.Anotar(models.PartePersonal, models.CambioVincular, m.NombreUsuario, m.Nombre+" ("+string(m.Rol)+")")
+	}
+	return nil
+}
+
+// tareas crea cada una pendiente. Si su zona o su responsable no están en el
+// destino, se crea igual sin ellos y el diff lo dice.
+func (i *instanciador) tareas(ctx context.Context, c *models.ContenidoPlantilla) error {
+	zonas, err := i.zonasDisponibles(ctx)
+	if err != nil {
+		return err
+	}
+	personal, err := i.personalVinculado(ctx)
+	if err != nil {
+		return err
+	}
+	var existentes []string
+	err = i.tx.SelectContext(ctx, &existentes, `
+		SELECT titulo || '|' || COALESCE(zona_id, '') FROM tareas
+		WHERE evento_id = $1 AND estado NOT IN ('completada','cancelada')
+	`, i.eventoID)
+	if err != nil {
+		return err
+	}
+	ya := conjunto(existentes)
+	for _, t := range c.Tareas {
+		clave := t.Titulo
+		if t.ZonaID != nil {
+			clave += " · " + *t.ZonaID
+		}
+		ajustes := []string{}
+		zonaID, asignada := t.ZonaID, t.AsignadaA
+		if zonaID != nil && !zonas[*zonaID] {
+			ajustes = append(ajustes, "sin zona: "+*zonaID+" no está en el evento")
+			zonaID = nil
+		}
+		if ya[t.Titulo+"|"+valorOVacio(zonaID)] {
+			i.inst.Anotar(models.ParteTareas, models.CambioOmitir, clave, "ya hay una abierta igual")
+			continue
+		}
+		if asignada != nil && !personal[*asignada] {
+			ajustes = append(ajustes, "sin asignar: su responsable no está en el evento")
+			asignada = nil
+		}
+		_, err := i.tx.ExecContext(ctx, `
+			INSERT INTO tareas (evento_id, zona_id, titulo, descripcion, prioridad, creada_por, asignada_a, requiere_evidencia)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		`, i.eventoID, zonaID, t.Titulo, t.Descripcion, t.Prioridad, i.adminID, asignada, t.RequiereEvidencia)
+		if err != nil {
+			return err
+		}
+		ya[t.Titulo+"|"+valorOVacio(zonaID)] = true
+		i.inst.Anotar(models.ParteTareas, models.CambioCrear, clave, strings.Join(ajustes, "; "))
+	}
+	return nil
+}
+
+// rutas: una ruta solo sirve con todos sus puntos, así que si falta una zona
+// no se crea
+func (i *instanciador) rutas(ctx context.Context, c *models.ContenidoPlantilla) error {
+	zonas, err := i.zonasDisponibles(ctx)
+	if err != nil {
+		return err
+	}
+	var existentes []string
+	err = i.tx.SelectContext(ctx, &existentes, `
+		SELECT nombre FROM rutas_patrulla WHERE evento_id = $1 AND activa
+	`, i.eventoID)
+	if err != nil {
+		return err
+	}
+	ya := conjunto(existentes)
+Rutas:
+	for _, ruta := range c.Rutas {
+		if ya[ruta.Nombre] {
+			i.inst.Anotar(models.ParteReglas, models.CambioOmitir, ruta.Nombre, "ya hay una ruta activa con ese nombre")
+			continue
+		}
+		for _, p := range ruta.Puntos {
+			if !zonas[p.ZonaID] {
+				i.inst.Anotar(models.ParteReglas, models.CambioOmitir, ruta.Nombre, "la zona "+p.ZonaID+" no está en el evento")
+				continue Rutas
+			}
+		}
+		var id string
+		err := i.tx.GetContext(ctx, &id, `
+			INSERT INTO rutas_patrulla (evento_id, nombre, tolerancia_min, creada_por)
+			VALUES ($1, $2, $3, $4) RETURNING id
+		`, i.eventoID, ruta.Nombre, ruta.ToleranciaMin, i.adminID)
+		if err != nil {
+			return err
+		}
+		for n, p := range ruta.Puntos {
+			_, err := i.tx.ExecContext(ctx, `
+				INSERT INTO rutas_puntos (ruta_id, orden, zona_id, intervalo_min) VALUES ($1, $2, $3, $4)
+			`, id, n+1, p.ZonaID, p.IntervaloMin)
+			if err != nil {
+				return err
+			}
+		}
+		ya[ruta.Nombre] = true
+		i.inst.Anotar(models.ParteReglas, models.CambioCrear, ruta.Nombre, fmt.Sprintf("%d puntos, tolerancia %d min", len(ruta.Puntos), ruta.ToleranciaMin))
+	}
+	return nil
+}
+
+// canales copia grupos y canales de zona con los miembros que estén en el
+// destino, y las palabras filtradas del chat
+func (i *instanciador) canales(ctx context.Context, c *models.ContenidoPlantilla) error {
+	zonas, err := i.zonasDisponibles(ctx)
+	if err != nil {
+		return err
+	}
+	personal, err := i.personalVinculado(ctx)
+	if err != nil {
+		return err
+	}
+	var existentes []string
+	err = i.tx.SelectContext(ctx, &existentes, `
+		SELECT tipo || '|' || nombre FROM conversaciones WHERE evento_id = $1 AND tipo IN ('grupo','zona')
+	`, i.eventoID)
+	if err != nil {
+		return err
+	}
+	ya := conjunto(existentes)
+	for _, canal := range c.Canales {
+		clave := string(canal.Tipo) + "|" + canal.Nombre
+		switch {
+		case ya[clave]:
+			i.inst.Anotar(models.ParteCanales, models.CambioOmitir, canal.Nombre, "ya existe en el evento")
+			continue
+		case canal.ZonaID != nil && !zonas[*canal.ZonaID]:
+			i.inst.Anotar(models.ParteCanales, models.CambioOmitir, canal.Nombre, "la zona "+*canal.ZonaID+" no está en el evento")
+			continue
+		}
+		miembros := []string{i.adminID}
+		for _, id := range canal.Miembros {
+			if personal[id] && id != i.adminID {
+				miembros = append(miembros, id)
+			}
+		}
+		var id string
+		err := i.tx.GetContext(ctx, &id, `
+			INSERT INTO conversaciones (evento_id, tipo, nombre, zona_id, creada_por)
+			VALUES ($1, $2, $3, $4, $5) RETURNING id
+		`, i.eventoID, canal.Tipo, canal.Nombre, canal.ZonaID, i.adminID)
+		if err != nil {
+			return err
+		}
+		if err := agregarMiembros(ctx, i.tx, id, miembros); err != nil {
+			return err
+		}
+		ya[clave] = true
+		i.inst.Anotar(models.ParteCanales, models.CambioCrear, canal.Nombre, fmt.Sprintf("%d miembros", len(miembros)))
+	}
+
+	for _, f := range c.Filtros {
+		res, err := i.tx.ExecContext(ctx, `
+			INSERT INTO chat_filtros (evento_id, palabra, accion) VALUES ($1, $2, $3)
+			ON CONFLICT (evento_id, palabra) DO NOTHING
+		`, i.eventoID, f.Palabra, f.Accion)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			i.inst.Anotar(models.ParteCanales, models.CambioOmitir, "filtro: "+f.Palabra, "ya está filtrada")
+			continue
+		}
+		i.inst.Anotar(models.ParteCanales, models.CambioCrear, "filtro: "+f.Palabra, string(f.Accion))
+	}
+	return nil
+}
+
+func conjunto(ids []string) map[string]bool {
+	m := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		m[id] = true
+	}
+	return m
+}
+
+func valorOVacio(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
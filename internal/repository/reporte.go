@@ -0,0 +1,269 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// ─── Reportes de evento ───────────────────────────────────────────────────────
+
+type ReporteRepo struct{ db *sqlx.DB }
+
+func NewReporteRepo(db *sqlx.DB) *ReporteRepo { return &ReporteRepo{db: db} }
+
+const columnasReporte = `id, evento_id, formato, estado, solicitado_por, intentos, error, nombre_archivo,
+	tamano, creado_en, iniciado_en, terminado_en`
+
+// Crear encola el reporte. Si ya hay uno igual sin terminar se devuelve ese.
+func (r *ReporteRepo) Crear(ctx context.Context, eventoID string, formato models.FormatoReporte, usuarioID string) (*models.ReporteEvento, error) {
+	var rep models.ReporteEvento
+	err := r.db.GetContext(ctx, &rep, `
+		SELECT `+columnasReporte+` FROM reportes_evento
+		WHERE evento_id = $1 AND formato = $2 AND estado IN ('pendiente','generando')
+		ORDER BY creado_en DESC LIMIT 1
+	`, eventoID, formato)
+	if err == nil {
+		return &rep, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	err = r.db.GetContext(ctx, &rep, `
+		INSERT INTO reportes_evento (evento_id, formato, solicitado_por) VALUES ($1, $2, $3)
+		RETURNING `+columnasReporte, eventoID, formato, usuarioID)
+	if err != nil {
+		return nil, err
+	}
+	return &rep, nil
+}
+
+func (r *ReporteRepo) Listar(ctx context.Context, eventoID string) ([]models.ReporteEvento, error) {
+	lista := []models.ReporteEvento{}
+	err := r.db.SelectContext(ctx, &lista, `
+		SELECT `+columnasReporte+` FROM reportes_evento WHERE evento_id = $1 ORDER BY creado_en DESC
+	`, eventoID)
+	return lista, err
+}
+
+func (r *ReporteRepo) ObtenerPorID(ctx context.Context, id string) (*models.ReporteEvento, error) {
+	var rep models.ReporteEvento
+	err := r.db.GetContext(ctx, &rep, `SELECT `+columnasReporte+` FROM reportes_evento WHERE id = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return &rep, err
+}
+
+// Archivo generado; nil si el reporte no existe o todavía no está listo
+func (r *ReporteRepo) Archivo(ctx context.Context, id string) ([]byte, error) {
+	var archivo []byte
+	err := r.db.GetContext(ctx, &archivo, `
+		SELECT archivo FROM reportes_evento WHERE id = $1 AND estado = 'listo'
+	`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return archivo, err
+}
+
+// Tomar marca como generando el pendiente más viejo, o uno que lleva más de
+// abandono generándose (la instancia que lo tomó se cayó). Con varias
+// instancias cada una toma uno distinto. nil si no hay ninguno.
+func (r *ReporteRepo) Tomar(ctx context.Context, abandono time.Duration) (*models.ReporteEvento, error) {
+	var rep models.ReporteEvento
+	err := r.db.GetContext(ctx, &rep, `
+		UPDATE reportes_evento SET estado = 'generando', intentos = intentos + 1, iniciado_en = NOW()
+		WHERE id = (
+			SELECT id FROM reportes_evento
+			WHERE estado = 'pendiente'
+			   OR (estado = 'generando' AND iniciado_en < NOW() - make_interval(secs => $1))
+			ORDER BY creado_en LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+columnasReporte, abandono.Seconds())
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return &rep, err
+}
+
+func (r *ReporteRepo) Guardar(ctx context.Context, id string, archivo []byte, nombre string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE reportes_evento SET estado = 'listo', archivo = $2, nombre_archivo = $3, tamano = $4,
+		       error = NULL, terminado_en = NOW()
+		WHERE id = $1
+	`, id, archivo, nombre, len(archivo))
+	return err
+}
+
+// MarcarFallo: vuelve a pendiente para otro intento, o queda fallido al
+// llegar a maxIntentos
+func (r *ReporteRepo) MarcarFallo(ctx context.Context, id string, causa error, maxIntentos int) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE reportes_evento SET
+			error        = $2,
+			estado       = CASE WHEN intentos >= $3 THEN 'fallido' ELSE 'pendiente' END,
+			terminado_en = CASE WHEN intentos >= $3 THEN NOW() END
+		WHERE id = $1
+	`, id, causa.Error(), maxIntentos)
+	return err
+}
+
+// Purgar borra los reportes terminados más viejos que la retención
+func (r *ReporteRepo) Purgar(ctx context.Context, dias int) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `
+		DELETE FROM reportes_evento
+		WHERE estado IN ('listo','fallido') AND terminado_en < NOW() - make_interval(days => $1)
+	`, dias)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Datos reúne todo lo que lleva el reporte. Lo resuelto o cancelado por el
+// cierre del evento cuenta en los totales pero no en tiempos ni tasas: su
+// historial lleva origen 'cierre'.
+func (r *ReporteRepo) Datos(ctx context.Context, eventoID string) (*models.DatosReporte, error) {
+	d := &models.DatosReporte{GeneradoEn: time.Now()}
+	err := r.db.GetContext(ctx, &d.Evento, `SELECT `+columnasEvento+` FROM eventos WHERE id = $1`, eventoID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoEncontrado
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Tiempos por incidencia, agrupados después por tipo, por zona o en total
+	const tiempos = `
+		WITH t AS (
+			SELECT i.id, i.tipo, i.zona_id, i.estado,
+			       EXTRACT(EPOCH FROM MIN(h.cambiado_en) FILTER (WHERE h.estado_nuevo = 'en_atencion') - i.creada_en) / 60 AS atencion,
+			       EXTRACT(EPOCH FROM MIN(h.cambiado_en) FILTER (WHERE h.estado_nuevo = 'resuelta') - i.creada_en) / 60 AS resolucion
+			FROM incidencias i
+			LEFT JOIN incidencias_historial h ON h.incidencia_id = i.id
+			     AND h.origen <> 'cierre'
+			WHERE i.evento_id = $1
+			GROUP BY i.id
+		)`
+	const columnas = `
+		COUNT(*) AS total, COUNT(*) FILTER (WHERE t.estado = 'resuelta') AS resueltas,
+		ROUND(AVG(t.atencion)::numeric, 1)::float8 AS atencion_promedio_min,
+		ROUND(AVG(t.resolucion)::numeric, 1)::float8 AS resolucion_promedio_min`
+	err = r.db.GetContext(ctx, &d.Incidencias, tiempos+`
+		SELECT 'total' AS clave, NULL::text AS nombre, `+columnas+` FROM t
+	`, eventoID)
+	if err != nil {
+		return nil, err
+	}
+	d.PorTipo = []models.FilaIncidencias{}
+	err = r.db.SelectContext(ctx, &d.PorTipo, tiempos+`
+		SELECT t.tipo AS clave, NULL::text AS nombre, `+columnas+` FROM t
+		GROUP BY t.tipo ORDER BY total DESC, t.tipo
+	`, eventoID)
+	if err != nil {
+		return nil, err
+	}
+	d.PorZona = []models.FilaIncidencias{}
+	err = r.db.SelectContext(ctx, &d.PorZona, tiempos+`
+		SELECT t.zona_id AS clave, z.nombre, `+columnas+` FROM t
+		LEFT JOIN zonas z ON z.id = t.zona_id AND z.evento_id = $1
+		GROUP BY t.zona_id, z.nombre ORDER BY total DESC, t.zona_id
+	`, eventoID)
+	if err != nil {
+		return nil, err
+	}
+
+	d.TareasRol = []models.FilaTareasRol{}
+	err = r.db.SelectContext(ctx, &d.TareasRol, `
+		SELECT COALESCE(u.rol, 'sin_asignar') AS rol, COUNT(*) AS total,
+		       COUNT(*) FILTER (WHERE t.estado = 'completada') AS completadas
+		FROM tareas t
+		LEFT JOIN usuarios u ON u.id = t.asignada_a
+		WHERE t.evento_id = $1
+		GROUP BY 1 ORDER BY 1
+	`, eventoID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Personal: quien estuvo vinculado (aunque el cierre lo haya sacado) o
+	// dejó rastro en el evento
+	d.Personal = []models.FilaPersonal{}
+	err = r.db.SelectContext(ctx, &d.Personal, `
+		WITH personas AS (
+			SELECT id FROM usuarios WHERE evento_id = $1
+			UNION SELECT unnest(personal) FROM cierres_evento WHERE evento_id = $1 AND estado IN ('revocando','completado')
+			UNION SELECT asignada_a FROM incidencias WHERE evento_id = $1
+			UNION SELECT asignada_a FROM tareas WHERE evento_id = $1
+			UNION SELECT usuario_id FROM mensajes WHERE evento_id = $1
+			UNION SELECT usuario_id FROM checkins WHERE evento_id = $1
+			UNION SELECT usuario_id FROM turnos WHERE evento_id = $1
+		)
+		SELECT u.id, u.nombre, u.rol,
+			(SELECT COUNT(*) FROM incidencias i WHERE i.evento_id = $1 AND i.asignada_a = u.id) AS incidencias_asignadas,
+			(SELECT COUNT(DISTINCT h.incidencia_id) FROM incidencias_historial h JOIN incidencias i ON i.id = h.incidencia_id
+			  WHERE i.evento_id = $1 AND h.usuario_id = u.id AND h.estado_nuevo = 'resuelta' AND h.origen <> 'cierre') AS incidencias_resueltas,
+			(SELECT COUNT(*) FROM tareas t WHERE t.evento_id = $1 AND t.asignada_a = u.id) AS tareas_asignadas,
+			(SELECT COUNT(DISTINCT h.tarea_id) FROM tareas_historial h JOIN tareas t ON t.id = h.tarea_id
+			  WHERE t.evento_id = $1 AND h.usuario_id = u.id AND h.estado_nuevo = 'completada') AS tareas_completadas,
+			(SELECT COUNT(*) FROM mensajes m WHERE m.evento_id = $1 AND m.usuario_id = u.id AND m.eliminado_en IS NULL) AS mensajes,
+			(SELECT COUNT(*) FROM checkins c WHERE c.evento_id = $1 AND c.usuario_id = u.id) AS checkins,
+			(SELECT COALESCE(ROUND((SUM(EXTRACT(EPOCH FROM COALESCE(tu.fin, NOW()) - tu.inicio)) / 3600)::numeric, 1), 0)::float8
+			   FROM turnos tu WHERE tu.evento_id = $1 AND tu.usuario_id = u.id) AS horas_turno
+		FROM usuarios u JOIN personas p ON p.id = u.id
+		ORDER BY u.rol, u.nombre
+	`, eventoID)
+	if err != nil {
+		return nil, err
+	}
+
+	d.Evidencias = []models.FilaEvidencias{}
+	err = r.db.SelectContext(ctx, &d.Evidencias, `
+		SELECT t.id AS tarea_id, t.titulo, t.zona_id, t.estado, t.requiere_evidencia AS requeridas,
+		       COUNT(e.id) FILTER (WHERE e.tipo = 'foto') AS fotos,
+		       COUNT(e.id) FILTER (WHERE e.tipo = 'nota') AS notas,
+		       COUNT(e.id) FILTER (WHERE e.tipo = 'qr')   AS qrs,
+		       COUNT(e.id) FILTER (WHERE e.tipo = 'gps')  AS gps
+		FROM tareas t
+		LEFT JOIN tareas_evidencias e ON e.tarea_id = t.id
+		WHERE t.evento_id = $1 AND (cardinality(t.requiere_evidencia) > 0 OR e.id IS NOT NULL)
+		GROUP BY t.id
+		ORDER BY t.creada_en
+	`, eventoID)
+	if err != nil {
+		return nil, err
+	}
+
+	var horas []models.PuntoChat
+	err = r.db.SelectContext(ctx, &horas, `
+		SELECT date_trunc('hour', enviado_en) AS hora, COUNT(*) AS mensajes
+		FROM mensajes WHERE evento_id = $1 AND eliminado_en IS NULL
+		GROUP BY 1 ORDER BY 1
+	`, eventoID)
+	if err != nil {
+		return nil, err
+	}
+	d.ChatPorHora = rellenarHoras(horas)
+	return d, nil
+}
+
+// rellenarHoras agrega las horas sin mensajes para que la línea de tiempo no
+// tenga saltos
+func rellenarHoras(horas []models.PuntoChat) []models.PuntoChat {
+	serie := []models.PuntoChat{}
+	for i, p := range horas {
+		if i > 0 {
+			for h := horas[i-1].Hora.Add(time.Hour); h.Before(p.Hora); h = h.Add(time.Hour) {
+				serie = append(serie, models.PuntoChat{Hora: h})
+			}
+		}
+		serie = append(serie, p)
+	}
+	return serie
+}
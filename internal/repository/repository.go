@@ -4,13 +4,39 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"time"
+	"strings"
 
 	"github.com/eventpulse/backend/internal/models"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// Errores compartidos por los repos con control de concurrencia
+var (
+	ErrNoEncontrado     = errors.New("registro no encontrado")
+	ErrVersionConflicto = errors.New("el registro fue modificado por otra persona")
+	ErrYaAsignada       = errors.New("ya fue tomada por otra persona")
+	ErrTareaCancelada   = errors.New("la tarea se canceló al cerrar el evento")
+)
+
+// sinCambio explica un UPDATE condicional por versión que no tocó filas: sin
+// If-Match es que no existe; con If-Match, si existe, es que cambió entretanto.
+// existe es un SELECT 1 sobre el registro.
+func sinCambio(ctx context.Context, q sqlx.QueryerContext, version *int, existe string, args ...interface{}) error {
+	if version == nil {
+		return ErrNoEncontrado
+	}
+	var ok bool
+	if err := sqlx.GetContext(ctx, q, &ok, `SELECT EXISTS (`+existe+`)`, args...); err != nil {
+		return err
+	}
+	if ok {
+		return ErrVersionConflicto
+	}
+	return ErrNoEncontrado
+}
+
 // ─── Usuario ──────────────────────────────────────────────────────────────────
 
 type UsuarioRepo struct{ db *sqlx.DB }
@@ -76,22 +102,52 @@ type EventoRepo struct{ db *sqlx.DB }
 
 func NewEventoRepo(db *sqlx.DB) *EventoRepo { return &EventoRepo{db: db} }
 
+const columnasEvento = `id, nombre, descripcion, estado, creado_por, creado_en, terminado_en, version,
+	inicio_programado, fin_programado, iniciado_en, pausado_en`
+
+var (
+	ErrTransicionInvalida = errors.New("el evento no admite ese cambio de estado")
+	ErrOtroEventoEnCurso  = errors.New("ya hay otro evento en curso")
+)
+
+// Crear deja el evento en borrador, o programado si trae inicio
 func (r *EventoRepo) Crear(ctx context.Context, req *models.CrearEventoRequest, adminID string) (*models.Evento, error) {
+	return insertarEvento(ctx, r.db, req, adminID)
+}
+
+// insertarEvento recibe la conexión o una transacción (instanciar plantillas)
+func insertarEvento(ctx context.Context, q sqlx.QueryerContext, req *models.CrearEventoRequest, adminID string) (*models.Evento, error) {
+	estado := models.EventoBorrador
+	if req.InicioProgramado != nil {
+		estado = models.EventoProgramado
+	}
+	nombres, roles := canalesBase()
 	var e models.Evento
-	err := r.db.GetContext(ctx, &e, `
-		INSERT INTO eventos (nombre, descripcion, creado_por)
-		VALUES ($1, $2, $3)
-		RETURNING id, nombre, descripcion, estado, creado_por, creado_en, terminado_en
-	`, req.Nombre, req.Descripcion, adminID)
+	// La sala general y los canales por rol nacen con el evento
+	err := sqlx.GetContext(ctx, q, &e, `
+		WITH e AS (
+			INSERT INTO eventos (nombre, descripcion, creado_por, estado, inicio_programado, fin_programado)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING *
+		), canales AS (
+			INSERT INTO conversaciones (evento_id, tipo, nombre, rol)
+			SELECT e.id, 'evento', 'General', NULL FROM e
+			UNION ALL
+			SELECT e.id, 'rol', c.nombre, c.rol FROM e, unnest($7::text[], $8::text[]) AS c(nombre, rol)
+		)
+		SELECT `+columnasEvento+` FROM e
+	`, req.Nombre, req.Descripcion, adminID, estado, req.InicioProgramado, req.FinProgramado,
+		pq.Array(nombres), pq.Array(roles))
 	return &e, err
 }
 
+// ObtenerActivo devuelve el evento en curso: activo o, si no hay, pausado
 func (r *EventoRepo) ObtenerActivo(ctx context.Context) (*models.Evento, error) {
 	var e models.Evento
 	err := r.db.GetContext(ctx, &e, `
-		SELECT id, nombre, descripcion, estado, creado_por, creado_en, terminado_en
-		FROM eventos WHERE estado = 'activo'
-		ORDER BY creado_en DESC LIMIT 1
+		SELECT `+columnasEvento+`
+		FROM eventos WHERE estado IN ('activo','pausado')
+		ORDER BY estado = 'pausado', creado_en DESC LIMIT 1
 	`)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, nil
@@ -99,27 +155,145 @@ func (r *EventoRepo) ObtenerActivo(ctx context.Context) (*models.Evento, error)
 	return &e, err
 }
 
+func (r *EventoRepo) ObtenerPorID(ctx context.Context, eventoID string) (*models.Evento, error) {
+	var e models.Evento
+	err := r.db.GetContext(ctx, &e, `
+		SELECT `+columnasEvento+`
+		FROM eventos WHERE id = $1
+	`, eventoID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return &e, err
+}
+
 func (r *EventoRepo) Listar(ctx context.Context) ([]models.Evento, error) {
 	var lista []models.Evento
 	err := r.db.SelectContext(ctx, &lista, `
-		SELECT id, nombre, descripcion, estado, creado_por, creado_en, terminado_en
+		SELECT `+columnasEvento+`
 		FROM eventos ORDER BY creado_en DESC
 	`)
 	return lista, err
 }
 
-func (r *EventoRepo) Terminar(ctx context.Context, eventoID string) (*models.Evento, error) {
-	ahora := time.Now()
-	var e models.Evento
-	err := r.db.GetContext(ctx, &e, `
-		UPDATE eventos SET estado = 'terminado', terminado_en = $1
-		WHERE id = $2 AND estado = 'activo'
-		RETURNING id, nombre, descripcion, estado, creado_por, creado_en, terminado_en
-	`, ahora, eventoID)
+// Actualizar guarda nombre, descripción y programa ya validados (el handler
+// mezcla el PATCH). No aplica si el evento terminó o cambió de versión.
+func (r *EventoRepo) Actualizar(ctx context.Context, e *models.Evento, version *int) (*models.Evento, error) {
+	var out models.Evento
+	err := r.db.GetContext(ctx, &out, `
+		UPDATE eventos
+		SET nombre = $2, descripcion = $3, inicio_programado = $4, fin_programado = $5
+		WHERE id = $1 AND estado <> 'terminado' AND ($6::int IS NULL OR version = $6)
+		RETURNING `+columnasEvento+`
+	`, e.ID, e.Nombre, e.Descripcion, e.InicioProgramado, e.FinProgramado, version)
 	if errors.Is(err, sql.ErrNoRows) {
-		return nil, errors.New("evento no encontrado o ya terminado")
+		return nil, r.porQueNoCambio(ctx, e.ID, []models.EstadoEvento{models.EventoBorrador, models.EventoProgramado, models.EventoActivo, models.EventoPausado}, version)
 	}
-	return &e, err
+	return &out, err
+}
+
+// CambiarEstado pasa el evento de desde a hacia y deja la transición en el
+// historial. Para entrar en curso no puede haber otro evento activo o
+// pausado (lo garantiza uq_eventos_en_curso aun con activaciones
+// simultáneas). Sella iniciado_en, pausado_en y terminado_en según corresponda;
+// al reabrir descarta un fin programado que ya pasó.
+func (r *EventoRepo) CambiarEstado(ctx context.Context, eventoID string, desde, hacia models.EstadoEvento, motivo models.MotivoTransicion, usuarioID *string, version *int) (*models.Evento, error) {
+	e, err := cambiarEstado(ctx, r.db, eventoID, desde, hacia, motivo, usuarioID, version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, r.porQueNoCambio(ctx, eventoID, []models.EstadoEvento{desde}, version)
+	}
+	return e, err
+}
+
+// cambiarEstado recibe la conexión o una transacción (el cierre termina el
+// evento dentro de la suya). sql.ErrNoRows: no se cumplió alguna condición.
+func cambiarEstado(ctx context.Context, q sqlx.QueryerContext, eventoID string, desde, hacia models.EstadoEvento, motivo models.MotivoTransicion, usuarioID *string, version *int) (*models.Evento, error) {
+	var e models.Evento
+	err := sqlx.GetContext(ctx, q, &e, `
+		WITH cambiado AS (
+			UPDATE eventos SET
+				estado         = $3::varchar,
+				iniciado_en    = CASE WHEN $3::varchar = 'activo' THEN COALESCE(iniciado_en, NOW()) ELSE iniciado_en END,
+				pausado_en     = CASE WHEN $3::varchar = 'pausado' THEN NOW() END,
+				terminado_en   = CASE WHEN $3::varchar = 'terminado' THEN NOW() WHEN $3::varchar = 'activo' THEN NULL ELSE terminado_en END,
+				fin_programado = CASE WHEN $2::varchar = 'terminado' AND fin_programado <= NOW() THEN NULL ELSE fin_programado END
+			WHERE id = $1 AND estado = $2::varchar AND ($6::int IS NULL OR version = $6)
+			  AND ($3::varchar NOT IN ('activo','pausado') OR $2::varchar IN ('activo','pausado') OR NOT EXISTS (
+			      SELECT 1 FROM eventos o WHERE o.estado IN ('activo','pausado') AND o.id <> $1))
+			RETURNING *
+		), transicion AS (
+			INSERT INTO eventos_transiciones (evento_id, desde, hacia, motivo, usuario_id)
+			SELECT id, $2::varchar, $3::varchar, $4, $5 FROM cambiado
+		)
+		SELECT `+columnasEvento+` FROM cambiado
+	`, eventoID, desde, hacia, motivo, usuarioID, version)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "uq_eventos_en_curso" {
+		return nil, ErrOtroEventoEnCurso // otra activación ganó la carrera
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// porQueNoCambio explica un UPDATE que no tocó filas
+func (r *EventoRepo) porQueNoCambio(ctx context.Context, eventoID string, admitidos []models.EstadoEvento, version *int) error {
+	actual, err := r.ObtenerPorID(ctx, eventoID)
+	switch {
+	case err != nil:
+		return err
+	case actual == nil:
+		return ErrNoEncontrado
+	case version != nil && actual.Version != *version:
+		return ErrVersionConflicto
+	}
+	for _, e := range admitidos {
+		if actual.Estado == e {
+			return ErrOtroEventoEnCurso // el único otro filtro
+		}
+	}
+	return ErrTransicionInvalida
+}
+
+// PorIniciar: programados cuya hora de inicio ya llegó, el más atrasado primero
+func (r *EventoRepo) PorIniciar(ctx context.Context) ([]models.Evento, error) {
+	lista := []models.Evento{}
+	err := r.db.SelectContext(ctx, &lista, `
+		SELECT `+columnasEvento+` FROM eventos
+		WHERE estado = 'programado' AND inicio_programado <= NOW()
+		ORDER BY inicio_programado
+	`)
+	return lista, err
+}
+
+// PorTerminar: activos o pausados cuya hora de fin ya llegó, sin un cierre
+// en marcha ni uno programado que ya falló para ese fin (si el admin mueve el
+// fin, se vuelve a intentar)
+func (r *EventoRepo) PorTerminar(ctx context.Context) ([]models.Evento, error) {
+	lista := []models.Evento{}
+	err := r.db.SelectContext(ctx, &lista, `
+		SELECT `+columnasEvento+` FROM eventos e
+		WHERE estado IN ('activo','pausado') AND fin_programado <= NOW()
+		  AND NOT EXISTS (
+			SELECT 1 FROM cierres_evento c
+			WHERE c.evento_id = e.id AND (c.estado = 'pendiente'
+			   OR (c.estado = 'fallido' AND c.motivo = 'programa' AND c.creado_en >= e.fin_programado)))
+	`)
+	return lista, err
+}
+
+// Transiciones del evento, de la más vieja a la más nueva
+func (r *EventoRepo) Transiciones(ctx context.Context, eventoID string) ([]models.TransicionEvento, error) {
+	lista := []models.TransicionEvento{}
+	err := r.db.SelectContext(ctx, &lista, `
+		SELECT t.id, t.evento_id, t.desde, t.hacia, t.motivo, t.usuario_id, u.nombre AS nombre_usuario, t.creada_en
+		FROM eventos_transiciones t
+		LEFT JOIN usuarios u ON u.id = t.usuario_id
+		WHERE t.evento_id = $1
+		ORDER BY t.creada_en
+	`, eventoID)
+	return lista, err
 }
 
 // ─── Zona ─────────────────────────────────────────────────────────────────────
@@ -128,35 +302,286 @@ type ZonaRepo struct{ db *sqlx.DB }
 
 func NewZonaRepo(db *sqlx.DB) *ZonaRepo { return &ZonaRepo{db: db} }
 
+const columnasZona = `id, evento_id, nombre, tipo, padre_id, latitud, longitud, radio_m, poligono, piso,
+	capacidad, umbral_ocupacion, archivada_en, version`
+
+// ErrZonaEnUso: hay trabajo abierto en la zona y no se indicó a dónde moverlo
+var ErrZonaEnUso = errors.New("la zona tiene trabajo abierto")
+
 func (r *ZonaRepo) Crear(ctx context.Context, req *models.CrearZonaRequest, eventoID string) (*models.Zona, error) {
+	tipo := req.Tipo
+	if tipo == "" {
+		tipo = models.ZonaArea
+	}
+	var z models.Zona
+	err := r.db.GetContext(ctx, &z, `
+		INSERT INTO zonas (id, evento_id, nombre, tipo, padre_id, latitud, longitud, radio_m, poligono, piso, capacidad, umbral_ocupacion)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8, $9::jsonb, $10, $11, $12)
+		RETURNING `+columnasZona+`
+	`, req.ID, eventoID, req.Nombre, tipo, req.PadreID, req.Latitud, req.Longitud, req.RadioM, req.Poligono, req.Piso,
+		req.Capacidad, req.UmbralOcupacion)
+	return &z, err
+}
+
+// CrearVarias inserta un lote ya validado en una sola transacción. Los
+// padres tienen que venir antes que sus hijas (el handler ordena por nivel).
+func (r *ZonaRepo) CrearVarias(ctx context.Context, lista []models.CrearZonaRequest, eventoID string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, z := range lista {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO zonas (id, evento_id, nombre, tipo, padre_id, latitud, longitud, radio_m, poligono, piso, capacidad, umbral_ocupacion)
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8, $9::jsonb, $10, $11, $12)
+		`, z.ID, eventoID, z.Nombre, z.Tipo, z.PadreID, z.Latitud, z.Longitud, z.RadioM, z.Poligono, z.Piso,
+			z.Capacidad, z.UmbralOcupacion)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Clonar copia las zonas no archivadas de otro evento con su jerarquía y
+// geometría. Un padre archivado no se copia: su hija queda en la raíz.
+func (r *ZonaRepo) Clonar(ctx context.Context, desdeEventoID, eventoID string) (int, error) {
+	// Las FK se revisan al final de la sentencia, así que padres e hijas
+	// entran juntos sin importar el orden
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO zonas (id, evento_id, nombre, tipo, padre_id, latitud, longitud, radio_m, poligono, piso, capacidad, umbral_ocupacion)
+		SELECT z.id, $2, z.nombre, z.tipo,
+		       CASE WHEN p.archivada_en IS NULL THEN z.padre_id END,
+		       z.latitud, z.longitud, z.radio_m, z.poligono, z.piso, z.capacidad, z.umbral_ocupacion
+		FROM zonas z
+		LEFT JOIN zonas p ON p.id = z.padre_id AND p.evento_id = z.evento_id
+		WHERE z.evento_id = $1 AND z.archivada_en IS NULL
+	`, desdeEventoID, eventoID)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// IDsEnComun: zonas activas del origen cuyo ID ya usa el destino
+func (r *ZonaRepo) IDsEnComun(ctx context.Context, desdeEventoID, eventoID string) ([]string, error) {
+	ids := []string{}
+	err := r.db.SelectContext(ctx, &ids, `
+		SELECT o.id FROM zonas o
+		JOIN zonas d ON d.id = o.id AND d.evento_id = $2
+		WHERE o.evento_id = $1 AND o.archivada_en IS NULL
+		ORDER BY o.id
+	`, desdeEventoID, eventoID)
+	return ids, err
+}
+
+func (r *ZonaRepo) Listar(ctx context.Context, eventoID string, conArchivadas bool) ([]models.Zona, error) {
+	var lista []models.Zona
+	err := r.db.SelectContext(ctx, &lista, `
+		SELECT `+columnasZona+` FROM zonas
+		WHERE evento_id = $1 AND ($2 OR archivada_en IS NULL)
+		ORDER BY nombre
+	`, eventoID, conArchivadas)
+	return lista, err
+}
+
+func (r *ZonaRepo) ObtenerPorID(ctx context.Context, zonaID, eventoID string) (*models.Zona, error) {
 	var z models.Zona
 	err := r.db.GetContext(ctx, &z, `
-		INSERT INTO zonas (id, evento_id, nombre) VALUES ($1, $2, $3)
-		RETURNING id, evento_id, nombre
-	`, req.ID, eventoID, req.Nombre)
+		SELECT `+columnasZona+` FROM zonas WHERE id = $1 AND evento_id = $2
+	`, zonaID, eventoID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
 	return &z, err
 }
 
-func (r *ZonaRepo) Listar(ctx context.Context, eventoID string) ([]models.Zona, error) {
+// ConGeometria devuelve las zonas con punto o polígono (para ubicar coordenadas)
+func (r *ZonaRepo) ConGeometria(ctx context.Context, eventoID string) ([]models.Zona, error) {
 	var lista []models.Zona
 	err := r.db.SelectContext(ctx, &lista, `
-		SELECT id, evento_id, nombre FROM zonas WHERE evento_id = $1 ORDER BY nombre
+		SELECT `+columnasZona+` FROM zonas
+		WHERE evento_id = $1 AND archivada_en IS NULL AND (latitud IS NOT NULL OR poligono IS NOT NULL)
 	`, eventoID)
 	return lista, err
 }
 
-func (r *ZonaRepo) Eliminar(ctx context.Context, zonaID, eventoID string) error {
-	res, err := r.db.ExecContext(ctx, `
-		DELETE FROM zonas WHERE id = $1 AND evento_id = $2
+// ElementosAbiertos: incidencias no resueltas y tareas no completadas del
+// evento, para dibujarlas sobre su zona
+func (r *ZonaRepo) ElementosAbiertos(ctx context.Context, eventoID string) ([]models.ElementoMapa, error) {
+	var lista []models.ElementoMapa
+	err := r.db.SelectContext(ctx, &lista, `
+		SELECT 'incidencia' AS capa, id, zona_id, tipo AS etiqueta, estado
+		FROM incidencias WHERE evento_id = $1 AND estado <> 'resuelta'
+		UNION ALL
+		SELECT 'tarea', id, zona_id, titulo, estado
+		FROM tareas WHERE evento_id = $1 AND estado NOT IN ('completada','cancelada') AND zona_id IS NOT NULL
+	`, eventoID)
+	return lista, err
+}
+
+// Actualizar guarda la zona completa ya validada (el handler mezcla el PATCH)
+func (r *ZonaRepo) Actualizar(ctx context.Context, z *models.Zona, version *int) (*models.Zona, error) {
+	var out models.Zona
+	err := r.db.GetContext(ctx, &out, `
+		UPDATE zonas
+		SET nombre = $3, tipo = $4, padre_id = $5, latitud = $6, longitud = $7,
+		    radio_m = $8, poligono = $9::jsonb, piso = $10, capacidad = $11, umbral_ocupacion = $12
+		WHERE id = $1 AND evento_id = $2 AND ($13::int IS NULL OR version = $13)
+		RETURNING `+columnasZona+`
+	`, z.ID, z.EventoID, z.Nombre, z.Tipo, z.PadreID, z.Latitud, z.Longitud, z.RadioM, z.Poligono, z.Piso,
+		z.Capacidad, z.UmbralOcupacion, version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, sinCambio(ctx, r.db, version, `SELECT 1 FROM zonas WHERE id = $1 AND evento_id = $2`, z.ID, z.EventoID)
+	}
+	return &out, err
+}
+
+// NivelMinimoHijas: el nivel más alto (menor número) entre las hijas; 0 si no tiene
+func (r *ZonaRepo) NivelMinimoHijas(ctx context.Context, zonaID, eventoID string) (int, error) {
+	var nivel int
+	err := r.db.GetContext(ctx, &nivel, `
+		SELECT COALESCE(MIN(CASE tipo WHEN 'sector' THEN 1 WHEN 'area' THEN 2 ELSE 3 END), 0)
+		FROM zonas WHERE padre_id = $1 AND evento_id = $2
 	`, zonaID, eventoID)
+	return nivel, err
+}
+
+const consultaReferenciasZona = `
+	SELECT
+		(SELECT COUNT(*) FROM incidencias WHERE evento_id = $2 AND zona_id = $1 AND estado <> 'resuelta') AS incidencias_abiertas,
+		(SELECT COUNT(*) FROM tareas WHERE evento_id = $2 AND zona_id = $1 AND estado NOT IN ('completada','cancelada')) AS tareas_abiertas,
+		(SELECT COUNT(*) FROM dispositivos WHERE evento_id = $2 AND zona_id = $1) AS dispositivos,
+		(SELECT COUNT(*) FROM incidencias WHERE evento_id = $2 AND zona_id = $1 AND estado = 'resuelta')
+		+ (SELECT COUNT(*) FROM tareas WHERE evento_id = $2 AND zona_id = $1 AND estado IN ('completada','cancelada'))
+		+ (SELECT COUNT(*) FROM conversaciones WHERE evento_id = $2 AND zona_id = $1)
+		+ (SELECT COUNT(*) FROM anuncios WHERE evento_id = $2 AND zona_id = $1)
+		+ (SELECT COUNT(*) FROM checkins WHERE evento_id = $2 AND zona_id = $1)
+		+ (SELECT COUNT(*) FROM rutas_puntos p JOIN rutas_patrulla r ON r.id = p.ruta_id
+		   WHERE r.evento_id = $2 AND p.zona_id = $1) AS historial`
+
+// Referencias cuenta lo que apunta a la zona
+func (r *ZonaRepo) Referencias(ctx context.Context, zonaID, eventoID string) (*models.ReferenciasZona, error) {
+	var ref models.ReferenciasZona
+	err := r.db.GetContext(ctx, &ref, consultaReferenciasZona, zonaID, eventoID)
+	return &ref, err
+}
+
+// Retirar borra o archiva la zona en una transacción. Si hay trabajo abierto
+// exige reasignarA (incidencias, tareas y dispositivos pasan ahí) o devuelve
+// ErrZonaEnUso con las referencias. Si queda historial, o si se pidió
+// archivar, la zona se archiva en vez de borrarse para no dejar huérfanos.
+// Las incidencias y tareas reasignadas vuelven en el resultado.
+func (r *ZonaRepo) Retirar(ctx context.Context, zonaID, eventoID string, reasignarA *string, archivar bool) (*models.RetiroZona, *models.ReferenciasZona, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
-		return err
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	var existe bool
+	err = tx.GetContext(ctx, &existe, `
+		SELECT true FROM zonas WHERE id = $1 AND evento_id = $2 FOR UPDATE
+	`, zonaID, eventoID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, ErrNoEncontrado
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	var ref models.ReferenciasZona
+	if err := tx.GetContext(ctx, &ref, consultaReferenciasZona, zonaID, eventoID); err != nil {
+		return nil, nil, err
+	}
+
+	res := &models.RetiroZona{ReasignadaA: reasignarA}
+	if ref.Abiertas() > 0 {
+		if reasignarA == nil {
+			return nil, &ref, ErrZonaEnUso
+		}
+		// El destino no puede desaparecer a mitad del traspaso
+		err = tx.GetContext(ctx, &existe, `
+			SELECT true FROM zonas WHERE id = $1 AND evento_id = $2 AND archivada_en IS NULL FOR SHARE
+		`, *reasignarA, eventoID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &ref, ErrNoEncontrado
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		// Se devuelven completas (y con la versión nueva) para avisar por WebSocket
+		err = tx.SelectContext(ctx, &res.Incidencias, `
+			WITH movidas AS (
+				UPDATE incidencias SET zona_id = $3
+				WHERE evento_id = $2 AND zona_id = $1 AND estado <> 'resuelta'
+				RETURNING *
+			)
+			SELECT i.id, i.evento_id, i.zona_id, z.nombre as zona_nombre,
+			       i.tipo, i.descripcion, i.estado, i.creada_por, i.asignada_a,
+			       u.nombre as nombre_asignado,
+			       i.creada_en, i.actualizada_en, i.version
+			FROM movidas i
+			LEFT JOIN zonas z ON z.id = i.zona_id AND z.evento_id = i.evento_id
+			LEFT JOIN usuarios u ON u.id = i.asignada_a
+		`, zonaID, eventoID, *reasignarA)
+		if err != nil {
+			return nil, nil, err
+		}
+		err = tx.SelectContext(ctx, &res.Tareas, `
+			WITH movidas AS (
+				UPDATE tareas SET zona_id = $3
+				WHERE evento_id = $2 AND zona_id = $1 AND estado NOT IN ('completada','cancelada')
+				RETURNING *
+			)
+			SELECT t.id, t.evento_id, t.zona_id, z.nombre as zona_nombre,
+			       t.titulo, t.descripcion, t.estado, t.prioridad,
+			       t.creada_por, t.asignada_a, u.nombre as nombre_asignado,
+			       t.completada_en, t.creada_en, t.version, t.requiere_evidencia
+			FROM movidas t
+			LEFT JOIN zonas z ON z.id = t.zona_id AND z.evento_id = t.evento_id
+			LEFT JOIN usuarios u ON u.id = t.asignada_a
+		`, zonaID, eventoID, *reasignarA)
+		if err != nil {
+			return nil, nil, err
+		}
+		_, err = tx.ExecContext(ctx, `
+			UPDATE dispositivos SET zona_id = $3 WHERE evento_id = $2 AND zona_id = $1
+		`, zonaID, eventoID, *reasignarA)
+		if err != nil {
+			return nil, nil, err
+		}
+		res.Movidas = ref
+		res.Movidas.Historial = 0
+	}
+
+	if archivar || ref.Historial > 0 {
+		_, err = tx.ExecContext(ctx, `
+			UPDATE zonas SET archivada_en = COALESCE(archivada_en, NOW()) WHERE id = $1 AND evento_id = $2
+		`, zonaID, eventoID)
+		res.Accion = "archivada"
+	} else {
+		_, err = tx.ExecContext(ctx, `DELETE FROM zonas WHERE id = $1 AND evento_id = $2`, zonaID, eventoID)
+		res.Accion = "eliminada"
 	}
-	n, _ := res.RowsAffected()
-	if n == 0 {
-		return errors.New("zona no encontrada")
+	if err != nil {
+		return nil, nil, err
 	}
-	return nil
+	return res, &ref, tx.Commit()
+}
+
+// Restaurar saca una zona del archivo
+func (r *ZonaRepo) Restaurar(ctx context.Context, zonaID, eventoID string) (*models.Zona, error) {
+	var z models.Zona
+	err := r.db.GetContext(ctx, &z, `
+		UPDATE zonas SET archivada_en = NULL WHERE id = $1 AND evento_id = $2
+		RETURNING `+columnasZona+`
+	`, zonaID, eventoID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoEncontrado
+	}
+	return &z, err
 }
 
 // ─── Incidencia ───────────────────────────────────────────────────────────────
@@ -176,7 +601,7 @@ func (r *IncidenciaRepo) Crear(ctx context.Context, req *models.CrearIncidenciaR
 		SELECT i.id, i.evento_id, i.zona_id, z.nombre as zona_nombre,
 		       i.tipo, i.descripcion, i.estado, i.creada_por, i.asignada_a,
 		       u.nombre as nombre_asignado,
-		       i.creada_en, i.actualizada_en
+		       i.creada_en, i.actualizada_en, i.version
 		FROM inserted i
 		LEFT JOIN zonas z ON z.id = i.zona_id AND z.evento_id = i.evento_id
 		LEFT JOIN usuarios u ON u.id = i.asignada_a
@@ -190,7 +615,7 @@ func (r *IncidenciaRepo) Listar(ctx context.Context, eventoID string) ([]models.
 		SELECT i.id, i.evento_id, i.zona_id, z.nombre as zona_nombre,
 		       i.tipo, i.descripcion, i.estado, i.creada_por, i.asignada_a,
 		       u.nombre as nombre_asignado,
-		       i.creada_en, i.actualizada_en
+		       i.creada_en, i.actualizada_en, i.version
 		FROM incidencias i
 		LEFT JOIN zonas z ON z.id = i.zona_id AND z.evento_id = i.evento_id
 		LEFT JOIN usuarios u ON u.id = i.asignada_a
@@ -206,7 +631,7 @@ func (r *IncidenciaRepo) ObtenerPorID(ctx context.Context, id string) (*models.I
 		SELECT i.id, i.evento_id, i.zona_id, z.nombre as zona_nombre,
 		       i.tipo, i.descripcion, i.estado, i.creada_por, i.asignada_a,
 		       u.nombre as nombre_asignado,
-		       i.creada_en, i.actualizada_en
+		       i.creada_en, i.actualizada_en, i.version
 		FROM incidencias i
 		LEFT JOIN zonas z ON z.id = i.zona_id AND z.evento_id = i.evento_id
 		LEFT JOIN usuarios u ON u.id = i.asignada_a
@@ -218,42 +643,100 @@ func (r *IncidenciaRepo) ObtenerPorID(ctx context.Context, id string) (*models.I
 	return &inc, err
 }
 
-// Editar permite al trabajador cambiar estado, o al admin cambiar cualquier campo
-func (r *IncidenciaRepo) Editar(ctx context.Context, id string, req *models.EditarIncidenciaRequest, usuarioID string) (*models.Incidencia, error) {
+// Editar permite al trabajador cambiar estado, o al admin cambiar cualquier campo.
+// Si version != nil solo escribe cuando coincide con la actual (If-Match).
+func (r *IncidenciaRepo) Editar(ctx context.Context, id string, req *models.EditarIncidenciaRequest, usuarioID string, version *int) (*models.Incidencia, error) {
 	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
 
-	// Leer estado actual para historial
+	// Leer estado y versión actuales (bloqueando la fila hasta el commit)
 	var estadoActual string
-	tx.QueryRowContext(ctx, `SELECT estado FROM incidencias WHERE id = $1`, id).Scan(&estadoActual)
+	var versionActual int
+	err = tx.QueryRowContext(ctx, `
+		SELECT estado, version FROM incidencias WHERE id = $1 FOR UPDATE
+	`, id).Scan(&estadoActual, &versionActual)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoEncontrado
+	}
+	if err != nil {
+		return nil, err
+	}
+	if version != nil && *version != versionActual {
+		return nil, ErrVersionConflicto
+	}
 
-	// Construir update dinámico
-	if req.Estado != nil {
-		_, err = tx.ExecContext(ctx, `UPDATE incidencias SET estado = $1 WHERE id = $2`, *req.Estado, id)
-		if err != nil {
+	// Un solo UPDATE para que la versión suba exactamente una vez
+	_, err = tx.ExecContext(ctx, `
+		UPDATE incidencias
+		SET estado = COALESCE($1, estado), asignada_a = COALESCE($2, asignada_a)
+		WHERE id = $3
+	`, req.Estado, req.AsignadaA, id)
+	if err != nil {
+		return nil, err
+	}
+	if req.Estado != nil && string(*req.Estado) != estadoActual {
+		if err := insertarHistorialIncidencia(ctx, tx, id, estadoActual, string(*req.Estado), usuarioID); err != nil {
 			return nil, err
 		}
-		// Historial
-		tx.ExecContext(ctx, `
-			INSERT INTO incidencias_historial (incidencia_id, estado_anterior, estado_nuevo, usuario_id)
-			VALUES ($1, $2, $3, $4)
-		`, id, estadoActual, *req.Estado, usuarioID)
-	}
-	if req.AsignadaA != nil {
-		_, err = tx.ExecContext(ctx, `UPDATE incidencias SET asignada_a = $1 WHERE id = $2`, *req.AsignadaA, id)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.ObtenerPorID(ctx, id)
+}
+
+// Reclamar asigna la incidencia a usuarioID solo si sigue pendiente y libre
+// (o pre-asignada a él). Es un único UPDATE condicional: de dos "la tomo"
+// simultáneos gana exactamente uno. El perdedor recibe ErrYaAsignada junto
+// con la incidencia actual para saber quién la tiene.
+func (r *IncidenciaRepo) Reclamar(ctx context.Context, id, usuarioID string) (*models.Incidencia, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE incidencias SET asignada_a = $1, estado = 'en_atencion'
+		WHERE id = $2 AND estado = 'pendiente'
+		  AND (asignada_a IS NULL OR asignada_a = $1)
+	`, usuarioID, id)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		actual, err := r.ObtenerPorID(ctx, id)
 		if err != nil {
 			return nil, err
 		}
+		if actual == nil {
+			return nil, ErrNoEncontrado
+		}
+		return actual, ErrYaAsignada
+	}
+	err = insertarHistorialIncidencia(ctx, tx, id, string(models.IncidenciaPendiente), string(models.IncidenciaEnAtencion), usuarioID)
+	if err != nil {
+		return nil, err
 	}
 
-	tx.Commit()
-
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
 	return r.ObtenerPorID(ctx, id)
 }
 
+func insertarHistorialIncidencia(ctx context.Context, tx *sqlx.Tx, id, anterior, nuevo, usuarioID string) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO incidencias_historial (incidencia_id, estado_anterior, estado_nuevo, usuario_id)
+		VALUES ($1, $2, $3, $4)
+	`, id, anterior, nuevo, usuarioID)
+	return err
+}
+
 // ─── Tarea ────────────────────────────────────────────────────────────────────
 
 type TareaRepo struct{ db *sqlx.DB }
@@ -268,18 +751,18 @@ func (r *TareaRepo) Crear(ctx context.Context, req *models.CrearTareaRequest, ev
 	var t models.Tarea
 	err := r.db.GetContext(ctx, &t, `
 		WITH inserted AS (
-			INSERT INTO tareas (evento_id, zona_id, titulo, descripcion, prioridad, creada_por, asignada_a)
-			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			INSERT INTO tareas (evento_id, zona_id, titulo, descripcion, prioridad, creada_por, asignada_a, requiere_evidencia)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 			RETURNING *
 		)
 		SELECT t.id, t.evento_id, t.zona_id, z.nombre as zona_nombre,
 		       t.titulo, t.descripcion, t.estado, t.prioridad,
 		       t.creada_por, t.asignada_a, u.nombre as nombre_asignado,
-		       t.completada_en, t.creada_en
+		       t.completada_en, t.creada_en, t.version, t.requiere_evidencia
 		FROM inserted t
 		LEFT JOIN zonas z ON z.id = t.zona_id AND z.evento_id = t.evento_id
 		LEFT JOIN usuarios u ON u.id = t.asignada_a
-	`, eventoID, zonaID, req.Titulo, req.Descripcion, req.Prioridad, adminID, req.AsignadaA)
+	`, eventoID, zonaID, req.Titulo, req.Descripcion, req.Prioridad, adminID, req.AsignadaA, pq.Array(tiposEvidencia(req.RequiereEvidencia)))
 	return &t, err
 }
 
@@ -289,7 +772,7 @@ func (r *TareaRepo) Listar(ctx context.Context, eventoID string) ([]models.Tarea
 		SELECT t.id, t.evento_id, t.zona_id, z.nombre as zona_nombre,
 		       t.titulo, t.descripcion, t.estado, t.prioridad,
 		       t.creada_por, t.asignada_a, u.nombre as nombre_asignado,
-		       t.completada_en, t.creada_en
+		       t.completada_en, t.creada_en, t.version, t.requiere_evidencia
 		FROM tareas t
 		LEFT JOIN zonas z ON z.id = t.zona_id AND z.evento_id = t.evento_id
 		LEFT JOIN usuarios u ON u.id = t.asignada_a
@@ -307,7 +790,7 @@ func (r *TareaRepo) ObtenerPorID(ctx context.Context, id string) (*models.Tarea,
 		SELECT t.id, t.evento_id, t.zona_id, z.nombre as zona_nombre,
 		       t.titulo, t.descripcion, t.estado, t.prioridad,
 		       t.creada_por, t.asignada_a, u.nombre as nombre_asignado,
-		       t.completada_en, t.creada_en
+		       t.completada_en, t.creada_en, t.version, t.requiere_evidencia
 		FROM tareas t
 		LEFT JOIN zonas z ON z.id = t.zona_id AND z.evento_id = t.evento_id
 		LEFT JOIN usuarios u ON u.id = t.asignada_a
@@ -319,62 +802,184 @@ func (r *TareaRepo) ObtenerPorID(ctx context.Context, id string) (*models.Tarea,
 	return &t, err
 }
 
-func (r *TareaRepo) Editar(ctx context.Context, id string, req *models.EditarTareaRequest) (*models.Tarea, error) {
-	if req.Estado != nil {
-		_, err := r.db.ExecContext(ctx, `UPDATE tareas SET estado = $1 WHERE id = $2`, *req.Estado, id)
+// Editar cambia estado y/o asignación. Si version != nil solo escribe cuando
+// coincide con la actual (If-Match). Pasar a completada exige que ya exista
+// la evidencia de cada tipo en requiere_evidencia (*EvidenciaFaltanteError).
+// Una tarea cancelada por el cierre del evento ya no cambia (ErrTareaCancelada).
+func (r *TareaRepo) Editar(ctx context.Context, id string, req *models.EditarTareaRequest, usuarioID string, version *int) (*models.Tarea, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var estadoActual string
+	var versionActual int
+	err = tx.QueryRowContext(ctx, `
+		SELECT estado, version FROM tareas WHERE id = $1 FOR UPDATE
+	`, id).Scan(&estadoActual, &versionActual)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoEncontrado
+	}
+	if err != nil {
+		return nil, err
+	}
+	if version != nil && *version != versionActual {
+		return nil, ErrVersionConflicto
+	}
+	if estadoActual == string(models.TareaCancelada) {
+		return nil, ErrTareaCancelada
+	}
+
+	if req.Estado != nil && *req.Estado == models.TareaCompletada && estadoActual != string(models.TareaCompletada) {
+		var faltantes []string
+		err = tx.SelectContext(ctx, &faltantes, `
+			SELECT r.tipo
+			FROM tareas t, unnest(t.requiere_evidencia) AS r(tipo)
+			WHERE t.id = $1
+			  AND NOT EXISTS (
+			      SELECT 1 FROM tareas_evidencias e WHERE e.tarea_id = t.id AND e.tipo = r.tipo
+			  )
+		`, id)
 		if err != nil {
 			return nil, err
 		}
+		if len(faltantes) > 0 {
+			return nil, &EvidenciaFaltanteError{Tipos: faltantes}
+		}
 	}
-	if req.AsignadaA != nil {
-		_, err := r.db.ExecContext(ctx, `UPDATE tareas SET asignada_a = $1 WHERE id = $2`, *req.AsignadaA, id)
-		if err != nil {
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE tareas
+		SET estado = COALESCE($1, estado), asignada_a = COALESCE($2, asignada_a)
+		WHERE id = $3
+	`, req.Estado, req.AsignadaA, id)
+	if err != nil {
+		return nil, err
+	}
+	if req.Estado != nil && string(*req.Estado) != estadoActual {
+		if err := insertarHistorialTarea(ctx, tx, id, estadoActual, string(*req.Estado), usuarioID); err != nil {
 			return nil, err
 		}
 	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
 	return r.ObtenerPorID(ctx, id)
 }
 
-// ─── Mensaje ──────────────────────────────────────────────────────────────────
-
-type MensajeRepo struct{ db *sqlx.DB }
+// Reclamar asigna la tarea a usuarioID y la pasa a en_progreso solo si sigue
+// pendiente y libre (o pre-asignada a él). Ver IncidenciaRepo.Reclamar.
+func (r *TareaRepo) Reclamar(ctx context.Context, id, usuarioID string) (*models.Tarea, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
 
-func NewMensajeRepo(db *sqlx.DB) *MensajeRepo { return &MensajeRepo{db: db} }
+	res, err := tx.ExecContext(ctx, `
+		UPDATE tareas SET asignada_a = $1, estado = 'en_progreso'
+		WHERE id = $2 AND estado = 'pendiente'
+		  AND (asignada_a IS NULL OR asignada_a = $1)
+	`, usuarioID, id)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		actual, err := r.ObtenerPorID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if actual == nil {
+			return nil, ErrNoEncontrado
+		}
+		return actual, ErrYaAsignada
+	}
+	err = insertarHistorialTarea(ctx, tx, id, string(models.TareaPendiente), string(models.TareaEnProgreso), usuarioID)
+	if err != nil {
+		return nil, err
+	}
 
-func (r *MensajeRepo) Listar(ctx context.Context, eventoID string, limite int) ([]models.Mensaje, error) {
-	var lista []models.Mensaje
-	err := r.db.SelectContext(ctx, &lista, `
-		SELECT m.id, m.evento_id, m.usuario_id,
-		       u.nombre    AS nombre_usuario,
-		       u.rol       AS rol_usuario,
-		       m.contenido, m.enviado_en
-		FROM mensajes m
-		JOIN usuarios u ON u.id = m.usuario_id
-		WHERE m.evento_id = $1
-		ORDER BY m.enviado_en DESC
-		LIMIT $2
-	`, eventoID, limite)
-	// Invertir a orden cronológico
-	for i, j := 0, len(lista)-1; i < j; i, j = i+1, j-1 {
-		lista[i], lista[j] = lista[j], lista[i]
+	if err := tx.Commit(); err != nil {
+		return nil, err
 	}
-	return lista, err
+	return r.ObtenerPorID(ctx, id)
 }
 
-func (r *MensajeRepo) Crear(ctx context.Context, eventoID, usuarioID, contenido string) (*models.Mensaje, error) {
-	var msg models.Mensaje
-	err := r.db.GetContext(ctx, &msg, `
+// AgregarEvidencia guarda una prueba de realización. La validación del
+// contenido según el tipo la hace el handler.
+func (r *TareaRepo) AgregarEvidencia(ctx context.Context, tareaID, usuarioID string, req *models.SubirEvidenciaRequest, foto []byte, fotoMime string) (*models.EvidenciaTarea, error) {
+	var ev models.EvidenciaTarea
+	err := r.db.GetContext(ctx, &ev, `
 		WITH inserted AS (
-			INSERT INTO mensajes (evento_id, usuario_id, contenido)
-			VALUES ($1, $2, $3)
-			RETURNING id, evento_id, usuario_id, contenido, enviado_en
+			INSERT INTO tareas_evidencias (tarea_id, tipo, nota, qr, latitud, longitud, foto, foto_mime, usuario_id)
+			VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), $5, $6, $7, NULLIF($8, ''), $9)
+			RETURNING *
 		)
-		SELECT i.id, i.evento_id, i.usuario_id,
-		       u.nombre AS nombre_usuario,
-		       u.rol    AS rol_usuario,
-		       i.contenido, i.enviado_en
-		FROM inserted i
-		JOIN usuarios u ON u.id = i.usuario_id
-	`, eventoID, usuarioID, contenido)
-	return &msg, err
+		SELECT e.id, e.tarea_id, e.tipo, e.nota, e.qr, e.latitud, e.longitud, e.foto_mime,
+		       e.usuario_id, u.nombre AS nombre_usuario, e.creada_en
+		FROM inserted e
+		JOIN usuarios u ON u.id = e.usuario_id
+	`, tareaID, req.Tipo, req.Nota, req.QR, req.Latitud, req.Longitud, foto, fotoMime, usuarioID)
+	return &ev, err
+}
+
+func (r *TareaRepo) ListarEvidencias(ctx context.Context, tareaID string) ([]models.EvidenciaTarea, error) {
+	var lista []models.EvidenciaTarea
+	err := r.db.SelectContext(ctx, &lista, `
+		SELECT e.id, e.tarea_id, e.tipo, e.nota, e.qr, e.latitud, e.longitud, e.foto_mime,
+		       e.usuario_id, u.nombre AS nombre_usuario, e.creada_en
+		FROM tareas_evidencias e
+		JOIN usuarios u ON u.id = e.usuario_id
+		WHERE e.tarea_id = $1
+		ORDER BY e.creada_en
+	`, tareaID)
+	return lista, err
+}
+
+// ObtenerFotoEvidencia devuelve los bytes y el content-type de una evidencia tipo foto
+func (r *TareaRepo) ObtenerFotoEvidencia(ctx context.Context, tareaID, evidenciaID string) ([]byte, string, error) {
+	var fila struct {
+		Foto     []byte  `db:"foto"`
+		FotoMime *string `db:"foto_mime"`
+	}
+	err := r.db.GetContext(ctx, &fila, `
+		SELECT foto, foto_mime FROM tareas_evidencias
+		WHERE id = $1 AND tarea_id = $2 AND tipo = 'foto'
+	`, evidenciaID, tareaID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, "", ErrNoEncontrado
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	mime := "application/octet-stream"
+	if fila.FotoMime != nil {
+		mime = *fila.FotoMime
+	}
+	return fila.Foto, mime, nil
+}
+
+// EvidenciaFaltanteError indica qué tipos de evidencia faltan para completar la tarea
+type EvidenciaFaltanteError struct{ Tipos []string }
+
+func (e *EvidenciaFaltanteError) Error() string {
+	return "falta evidencia para completar la tarea: " + strings.Join(e.Tipos, ", ")
+}
+
+func insertarHistorialTarea(ctx context.Context, tx *sqlx.Tx, id, anterior, nuevo, usuarioID string) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO tareas_historial (tarea_id, estado_anterior, estado_nuevo, usuario_id)
+		VALUES ($1, $2, $3, $4)
+	`, id, anterior, nuevo, usuarioID)
+	return err
+}
+
+func tiposEvidencia(tipos []models.TipoEvidencia) []string {
+	out := make([]string, 0, len(tipos))
+	for _, t := range tipos {
+		out = append(out, string(t))
+	}
+	return out
 }
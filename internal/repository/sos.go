@@ -0,0 +1,206 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// ─── SOS ──────────────────────────────────────────────────────────────────────
+
+type SOSRepo struct{ db *sqlx.DB }
+
+func NewSOSRepo(db *sqlx.DB) *SOSRepo { return &SOSRepo{db: db} }
+
+// La alerta sigue activa mientras su incidencia no esté resuelta; la
+// ubicación es el último punto del rastro
+const selectSOS = `
+	SELECT s.id, s.incidencia_id, s.evento_id, s.usuario_id,
+	       u.nombre AS nombre_usuario, u.rol AS rol_usuario,
+	       i.zona_id, z.nombre AS zona_nombre, i.estado, i.estado <> 'resuelta' AS activa,
+	       ub.latitud, ub.longitud, ub.precision_m, ub.registrada_en AS ubicacion_en,
+	       s.creada_en
+	FROM sos_alertas s
+	JOIN incidencias i ON i.id = s.incidencia_id
+	JOIN usuarios u ON u.id = s.usuario_id
+	LEFT JOIN zonas z ON z.id = i.zona_id AND z.evento_id = i.evento_id
+	LEFT JOIN LATERAL (
+		SELECT latitud, longitud, precision_m, registrada_en FROM sos_ubicaciones
+		WHERE sos_id = s.id ORDER BY registrada_en DESC LIMIT 1
+	) ub ON true`
+
+// Activar crea la incidencia 'sos' y la alerta en una transacción. Si el
+// usuario ya tiene un SOS abierto en el evento lo devuelve con nuevo=false:
+// un segundo toque (o reintento de la app) no duplica la alerta.
+func (r *SOSRepo) Activar(ctx context.Context, eventoID, usuarioID, zonaID, descripcion string, inicial *models.UbicacionSOS) (sos *models.SOS, nuevo bool, err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	// Serializa los toques simultáneos del mismo usuario
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('sos:' || $1))`, usuarioID); err != nil {
+		return nil, false, err
+	}
+	var existente string
+	err = tx.GetContext(ctx, &existente, `
+		SELECT s.id FROM sos_alertas s
+		JOIN incidencias i ON i.id = s.incidencia_id
+		WHERE s.usuario_id = $1 AND s.evento_id = $2 AND i.estado <> 'resuelta'
+		ORDER BY s.creada_en DESC LIMIT 1
+	`, usuarioID, eventoID)
+	if err == nil {
+		if err := tx.Commit(); err != nil {
+			return nil, false, err
+		}
+		sos, err := r.ObtenerPorID(ctx, existente)
+		return sos, false, err
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, false, err
+	}
+
+	var incidenciaID string
+	err = tx.GetContext(ctx, &incidenciaID, `
+		INSERT INTO incidencias (evento_id, zona_id, tipo, descripcion, creada_por)
+		VALUES ($1, $2, 'sos', $3, $4)
+		RETURNING id
+	`, eventoID, zonaID, descripcion, usuarioID)
+	if err != nil {
+		return nil, false, err
+	}
+	// Ya se avisó a toda la supervisión: el escalador no tiene nada que agregar
+	if _, err := tx.ExecContext(ctx, `INSERT INTO incidencias_escalamientos (incidencia_id) VALUES ($1)`, incidenciaID); err != nil {
+		return nil, false, err
+	}
+	var sosID string
+	err = tx.GetContext(ctx, &sosID, `
+		INSERT INTO sos_alertas (incidencia_id, evento_id, usuario_id) VALUES ($1, $2, $3)
+		RETURNING id
+	`, incidenciaID, eventoID, usuarioID)
+	if err != nil {
+		return nil, false, err
+	}
+	if inicial != nil {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO sos_ubicaciones (sos_id, latitud, longitud, precision_m, zona_id, registrada_en)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`, sosID, inicial.Latitud, inicial.Longitud, inicial.PrecisionM, inicial.ZonaID, inicial.RegistradaEn)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
+	}
+	sos, err = r.ObtenerPorID(ctx, sosID)
+	return sos, true, err
+}
+
+func (r *SOSRepo) ObtenerPorID(ctx context.Context, id string) (*models.SOS, error) {
+	var s models.SOS
+	err := r.db.GetContext(ctx, &s, selectSOS+` WHERE s.id = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return &s, err
+}
+
+// ObtenerPorIncidencia sirve para reaccionar a cambios de la incidencia
+func (r *SOSRepo) ObtenerPorIncidencia(ctx context.Context, incidenciaID string) (*models.SOS, error) {
+	var s models.SOS
+	err := r.db.GetContext(ctx, &s, selectSOS+` WHERE s.incidencia_id = $1`, incidenciaID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return &s, err
+}
+
+// ActivaDe devuelve el SOS abierto del usuario (la app lo retoma al reabrirse)
+func (r *SOSRepo) ActivaDe(ctx context.Context, usuarioID string) (*models.SOS, error) {
+	var s models.SOS
+	err := r.db.GetContext(ctx, &s, selectSOS+`
+		WHERE s.usuario_id = $1 AND i.estado <> 'resuelta'
+		ORDER BY s.creada_en DESC LIMIT 1
+	`, usuarioID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return &s, err
+}
+
+func (r *SOSRepo) Listar(ctx context.Context, eventoID string, soloActivas bool) ([]models.SOS, error) {
+	var lista []models.SOS
+	err := r.db.SelectContext(ctx, &lista, selectSOS+`
+		WHERE s.evento_id = $1 AND (NOT $2 OR i.estado <> 'resuelta')
+		ORDER BY s.creada_en DESC
+	`, eventoID, soloActivas)
+	return lista, err
+}
+
+func (r *SOSRepo) RegistrarUbicacion(ctx context.Context, sosID string, req *models.UbicacionSOSRequest) (*models.UbicacionSOS, error) {
+	var u models.UbicacionSOS
+	err := r.db.GetContext(ctx, &u, `
+		INSERT INTO sos_ubicaciones (sos_id, latitud, longitud, precision_m, zona_id)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''))
+		RETURNING sos_id, latitud, longitud, precision_m, zona_id, registrada_en
+	`, sosID, req.Latitud, req.Longitud, req.PrecisionM, req.ZonaID)
+	return &u, err
+}
+
+// Ubicaciones devuelve el rastro, del punto más reciente al más antiguo
+func (r *SOSRepo) Ubicaciones(ctx context.Context, sosID string, limite int) ([]models.UbicacionSOS, error) {
+	var lista []models.UbicacionSOS
+	err := r.db.SelectContext(ctx, &lista, `
+		SELECT sos_id, latitud, longitud, precision_m, zona_id, registrada_en
+		FROM sos_ubicaciones WHERE sos_id = $1
+		ORDER BY registrada_en DESC LIMIT $2
+	`, sosID, limite)
+	return lista, err
+}
+
+// UltimaPosicion busca lo último que se sabe del usuario: historial de
+// ubicación de sus turnos, rastros de SOS anteriores y evidencias GPS/QR de
+// sus tareas (el QR da la zona).
+func (r *SOSRepo) UltimaPosicion(ctx context.Context, usuarioID string) (*models.PosicionConocida, error) {
+	var p models.PosicionConocida
+	err := r.db.GetContext(ctx, &p, `
+		SELECT latitud, longitud, zona_id, en FROM (
+			SELECT ub.latitud, ub.longitud, ub.zona_id, ub.registrada_en AS en
+			FROM sos_ubicaciones ub JOIN sos_alertas s ON s.id = ub.sos_id
+			WHERE s.usuario_id = $1
+			UNION ALL
+			SELECT latitud, longitud, zona_id, registrada_en AS en
+			FROM ubicaciones WHERE usuario_id = $1
+			UNION ALL
+			SELECT e.latitud, e.longitud, t.zona_id, e.creada_en AS en
+			FROM tareas_evidencias e JOIN tareas t ON t.id = e.tarea_id
+			WHERE e.usuario_id = $1 AND e.tipo IN ('gps', 'qr')
+		) x
+		ORDER BY en DESC LIMIT 1
+	`, usuarioID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return &p, err
+}
+
+// IDsRespuesta: quienes reciben la alerta — admins y, del evento,
+// supervisores, guardias y médicos
+func (r *SOSRepo) IDsRespuesta(ctx context.Context, eventoID string) ([]string, error) {
+	return idsRespuestaSOS(ctx, r.db, eventoID)
+}
+
+func idsRespuestaSOS(ctx context.Context, q sqlx.QueryerContext, eventoID string) ([]string, error) {
+	var ids []string
+	err := sqlx.SelectContext(ctx, q, &ids, `
+		SELECT id FROM usuarios
+		WHERE activo = true
+		  AND (rol = 'admin' OR (evento_id = $1 AND rol IN ('supervisor', 'guardia', 'medico')))
+	`, eventoID)
+	return ids, err
+}
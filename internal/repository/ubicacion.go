@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// ─── Ubicación del staff ──────────────────────────────────────────────────────
+
+type UbicacionRepo struct{ db *sqlx.DB }
+
+func NewUbicacionRepo(db *sqlx.DB) *UbicacionRepo { return &UbicacionRepo{db: db} }
+
+const selectTurno = `
+	SELECT t.id, t.usuario_id, u.nombre AS nombre_usuario, u.rol AS rol_usuario,
+	       t.evento_id, t.inicio, t.fin, t.motivo_fin
+	FROM turnos t JOIN usuarios u ON u.id = t.usuario_id`
+
+// IniciarTurno abre un turno; si ya hay uno abierto lo devuelve con nuevo=false
+func (r *UbicacionRepo) IniciarTurno(ctx context.Context, usuarioID, eventoID string) (*models.Turno, bool, error) {
+	var id string
+	err := r.db.GetContext(ctx, &id, `
+		INSERT INTO turnos (usuario_id, evento_id) VALUES ($1, $2)
+		ON CONFLICT (usuario_id) WHERE fin IS NULL DO NOTHING
+		RETURNING id
+	`, usuarioID, eventoID)
+	nuevo := err == nil
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, false, err
+	}
+	t, err := r.TurnoAbierto(ctx, usuarioID)
+	if err == nil && t == nil {
+		// Se cerró entre el INSERT y la lectura
+		return nil, false, ErrNoEncontrado
+	}
+	return t, nuevo, err
+}
+
+// TurnoAbierto devuelve el turno en curso del usuario, o nil
+func (r *UbicacionRepo) TurnoAbierto(ctx context.Context, usuarioID string) (*models.Turno, error) {
+	var t models.Turno
+	err := r.db.GetContext(ctx, &t, selectTurno+` WHERE t.usuario_id = $1 AND t.fin IS NULL`, usuarioID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return &t, err
+}
+
+// TerminarTurno cierra el turno abierto del usuario. Con eventoID solo si el
+// turno es de ese evento (un supervisor no cierra turnos de otro evento).
+func (r *UbicacionRepo) TerminarTurno(ctx context.Context, usuarioID string, eventoID *string, motivo models.MotivoFinTurno) (*models.Turno, error) {
+	var id string
+	err := r.db.GetContext(ctx, &id, `
+		UPDATE turnos SET fin = NOW(), motivo_fin = $2
+		WHERE usuario_id = $1 AND fin IS NULL AND ($3::uuid IS NULL OR evento_id = $3)
+		RETURNING id
+	`, usuarioID, motivo, eventoID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoEncontrado
+	}
+	if err != nil {
+		return nil, err
+	}
+	var t models.Turno
+	err = r.db.GetContext(ctx, &t, selectTurno+` WHERE t.id = $1`, id)
+	return &t, err
+}
+
+// CerrarVencidos cierra los turnos que superaron la duración máxima o cuyo
+// evento ya no está en curso, y los devuelve para retirarlos del mapa
+func (r *UbicacionRepo) CerrarVencidos(ctx context.Context, maxHoras int) ([]models.Turno, error) {
+	var lista []models.Turno
+	err := r.db.SelectContext(ctx, &lista, `
+		WITH cerrados AS (
+			UPDATE turnos t
+			SET fin = NOW(),
+			    motivo_fin = CASE WHEN e.estado NOT IN ('activo','pausado') THEN 'evento' ELSE 'duracion' END
+			FROM eventos e
+			WHERE e.id = t.evento_id AND t.fin IS NULL
+			  AND (e.estado NOT IN ('activo','pausado') OR ($1 > 0 AND t.inicio < NOW() - make_interval(hours => $1)))
+			RETURNING t.*
+		)
+		SELECT t.id, t.usuario_id, u.nombre AS nombre_usuario, u.rol AS rol_usuario,
+		       t.evento_id, t.inicio, t.fin, t.motivo_fin
+		FROM cerrados t JOIN usuarios u ON u.id = t.usuario_id
+	`, maxHoras)
+	return lista, err
+}
+
+// Turnos del usuario que se cruzan con [desde, hasta], de un evento si eventoID != nil
+func (r *UbicacionRepo) Turnos(ctx context.Context, usuarioID string, eventoID *string, desde, hasta time.Time) ([]models.Turno, error) {
+	var lista []models.Turno
+	err := r.db.SelectContext(ctx, &lista, selectTurno+`
+		WHERE t.usuario_id = $1 AND t.inicio <= $3 AND COALESCE(t.fin, NOW()) >= $2
+		  AND ($4::uuid IS NULL OR t.evento_id = $4)
+		ORDER BY t.inicio DESC
+	`, usuarioID, desde, hasta, eventoID)
+	return lista, err
+}
+
+// Guardar agrega un punto al historial muestreado
+func (r *UbicacionRepo) Guardar(ctx context.Context, turnoID string, u *models.Ubicacion) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO ubicaciones (usuario_id, evento_id, turno_id, latitud, longitud, precision_m, zona_id, fuente, registrada_en)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, u.UsuarioID, u.EventoID, turnoID, u.Latitud, u.Longitud, u.PrecisionM, u.ZonaID, u.Fuente, u.RegistradaEn)
+	return err
+}
+
+// Rastro devuelve el historial del usuario en la ventana, del más reciente al más antiguo
+func (r *UbicacionRepo) Rastro(ctx context.Context, usuarioID string, eventoID *string, desde, hasta time.Time, limite int) ([]models.Ubicacion, error) {
+	var lista []models.Ubicacion
+	err := r.db.SelectContext(ctx, &lista, `
+		SELECT usuario_id, evento_id, latitud, longitud, precision_m, zona_id, fuente, registrada_en
+		FROM ubicaciones
+		WHERE usuario_id = $1 AND registrada_en BETWEEN $2 AND $3
+		  AND ($5::uuid IS NULL OR evento_id = $5)
+		ORDER BY registrada_en DESC LIMIT $4
+	`, usuarioID, desde, hasta, limite, eventoID)
+	return lista, err
+}
+
+// Purgar borra el historial más viejo que la retención configurada
+func (r *UbicacionRepo) Purgar(ctx context.Context, dias int) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `
+		DELETE FROM ubicaciones WHERE registrada_en < NOW() - make_interval(days => $1)
+	`, dias)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (r *UbicacionRepo) ZonaExiste(ctx context.Context, eventoID, zonaID string) (bool, error) {
+	var existe bool
+	err := r.db.GetContext(ctx, &existe, `
+		SELECT EXISTS(SELECT 1 FROM zonas WHERE id = $1 AND evento_id = $2 AND archivada_en IS NULL)
+	`, zonaID, eventoID)
+	return existe, err
+}
+
+// IDsMando: quienes ven el mapa — admins y supervisores del evento
+func (r *UbicacionRepo) IDsMando(ctx context.Context, eventoID string) ([]string, error) {
+	var ids []string
+	err := r.db.SelectContext(ctx, &ids, `
+		SELECT id FROM usuarios
+		WHERE activo = true AND (rol = 'admin' OR (evento_id = $1 AND rol = 'supervisor'))
+	`, eventoID)
+	return ids, err
+}
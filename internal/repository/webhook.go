@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ─── Webhook ──────────────────────────────────────────────────────────────────
+
+type WebhookRepo struct{ db *sqlx.DB }
+
+func NewWebhookRepo(db *sqlx.DB) *WebhookRepo { return &WebhookRepo{db: db} }
+
+// El secreto no se incluye: solo se muestra una vez, al crear
+const columnasWebhook = `id, evento_id, url, descripcion, tipos, activo, creado_por, creado_en, version`
+
+const columnasEntrega = `id, webhook_id, tipo, payload, estado, intentos, ultimo_status,
+	ultimo_error, proximo_intento, creada_en, entregada_en`
+
+func (r *WebhookRepo) Crear(ctx context.Context, eventoID, adminID, secreto string, req *models.CrearWebhookRequest) (*models.Webhook, error) {
+	var w models.Webhook
+	err := r.db.GetContext(ctx, &w, `
+		INSERT INTO webhooks (evento_id, url, descripcion, tipos, secreto, creado_por)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6)
+		RETURNING `+columnasWebhook+`, secreto
+	`, eventoID, req.URL, req.Descripcion, pq.Array(req.Tipos), secreto, adminID)
+	return &w, err
+}
+
+func (r *WebhookRepo) Listar(ctx context.Context, eventoID string) ([]models.Webhook, error) {
+	var lista []models.Webhook
+	err := r.db.SelectContext(ctx, &lista, `
+		SELECT `+columnasWebhook+` FROM webhooks WHERE evento_id = $1 ORDER BY creado_en
+	`, eventoID)
+	return lista, err
+}
+
+func (r *WebhookRepo) ObtenerPorID(ctx context.Context, id string) (*models.Webhook, error) {
+	var w models.Webhook
+	err := r.db.GetContext(ctx, &w, `SELECT `+columnasWebhook+` FROM webhooks WHERE id = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return &w, err
+}
+
+// ObtenerConSecreto es para el despachador, que necesita firmar
+func (r *WebhookRepo) ObtenerConSecreto(ctx context.Context, id string) (*models.Webhook, error) {
+	var w models.Webhook
+	err := r.db.GetContext(ctx, &w, `SELECT `+columnasWebhook+`, secreto FROM webhooks WHERE id = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return &w, err
+}
+
+// Editar aplica los campos presentes. Si version != nil solo escribe cuando
+// coincide con la actual (If-Match).
+func (r *WebhookRepo) Editar(ctx context.Context, id string, req *models.EditarWebhookRequest, version *int) (*models.Webhook, error) {
+	var tipos interface{}
+	if req.Tipos != nil {
+		tipos = pq.Array(req.Tipos)
+	}
+	var w models.Webhook
+	err := r.db.GetContext(ctx, &w, `
+		UPDATE webhooks
+		SET url = COALESCE($2, url), descripcion = COALESCE($3, descripcion),
+		    tipos = COALESCE($4, tipos), activo = COALESCE($5, activo)
+		WHERE id = $1 AND ($6::int IS NULL OR version = $6)
+		RETURNING `+columnasWebhook+`
+	`, id, req.URL, req.Descripcion, tipos, req.Activo, version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, sinCambio(ctx, r.db, version, `SELECT 1 FROM webhooks WHERE id = $1`, id)
+	}
+	return &w, err
+}
+
+func (r *WebhookRepo) Eliminar(ctx context.Context, id string) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM webhooks WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNoEncontrado
+	}
+	return nil
+}
+
+// CrearEntregas registra una entrega pendiente por cada webhook activo del
+// evento suscrito al tipo, y devuelve sus ids para encolarlas.
+func (r *WebhookRepo) CrearEntregas(ctx context.Context, eventoID string, tipo models.TipoEventoWS, payload []byte) ([]string, error) {
+	var ids []string
+	err := r.db.SelectContext(ctx, &ids, `
+		INSERT INTO webhooks_entregas (webhook_id, tipo, payload)
+		SELECT id, $2::text, $3::jsonb FROM webhooks
+		WHERE evento_id = $1 AND activo = true AND $2::text = ANY(tipos)
+		RETURNING id
+	`, eventoID, tipo, string(payload))
+	return ids, err
+}
+
+func (r *WebhookRepo) ObtenerEntrega(ctx context.Context, id string) (*models.EntregaWebhook, error) {
+	var e models.EntregaWebhook
+	err := r.db.GetContext(ctx, &e, `SELECT `+columnasEntrega+` FROM webhooks_entregas WHERE id = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return &e, err
+}
+
+// ListarEntregas devuelve el registro de entregas más reciente, opcionalmente por estado
+func (r *WebhookRepo) ListarEntregas(ctx context.Context, webhookID, estado string, limite int) ([]models.EntregaWebhook, error) {
+	var lista []models.EntregaWebhook
+	err := r.db.SelectContext(ctx, &lista, `
+		SELECT `+columnasEntrega+` FROM webhooks_entregas
+		WHERE webhook_id = $1 AND ($2 = '' OR estado = $2)
+		ORDER BY creada_en DESC
+		LIMIT $3
+	`, webhookID, estado, limite)
+	return lista, err
+}
+
+func (r *WebhookRepo) MarcarEntregada(ctx context.Context, id string, status int) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE webhooks_entregas
+		SET estado = 'entregada', intentos = intentos + 1, ultimo_status = $2,
+		    ultimo_error = NULL, entregada_en = NOW()
+		WHERE id = $1
+	`, id, status)
+	return err
+}
+
+// RegistrarFallo suma un intento. Si quedan intentos la deja pendiente para
+// `proximo`; si no, pasa a muerta. Devuelve el estado resultante.
+func (r *WebhookRepo) RegistrarFallo(ctx context.Context, id string, status *int, motivo string, proximo time.Time, maxIntentos int) (models.EstadoEntrega, error) {
+	var estado models.EstadoEntrega
+	err := r.db.GetContext(ctx, &estado, `
+		UPDATE webhooks_entregas
+		SET intentos = intentos + 1, ultimo_status = $2, ultimo_error = $3,
+		    proximo_intento = $4,
+		    estado = CASE WHEN intentos + 1 >= $5 THEN 'muerta' ELSE 'pendiente' END
+		WHERE id = $1
+		RETURNING estado
+	`, id, status, motivo, proximo, maxIntentos)
+	return estado, err
+}
+
+// Reenviar vuelve a poner en cola una entrega (muerta o ya entregada) desde cero
+func (r *WebhookRepo) Reenviar(ctx context.Context, id string) (*models.EntregaWebhook, error) {
+	var e models.EntregaWebhook
+	err := r.db.GetContext(ctx, &e, `
+		UPDATE webhooks_entregas
+		SET estado = 'pendiente', intentos = 0, proximo_intento = NOW(), entregada_en = NULL
+		WHERE id = $1
+		RETURNING `+columnasEntrega, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoEncontrado
+	}
+	return &e, err
+}
+
+// EntregaPendiente es lo mínimo para reconstruir la cola de Redis
+type EntregaPendiente struct {
+	ID             string    `db:"id"`
+	ProximoIntento time.Time `db:"proximo_intento"`
+}
+
+// Pendientes lista las entregas que deberían estar en la cola (al arrancar,
+// por si Redis perdió la cola o el proceso cayó a mitad de un envío).
+func (r *WebhookRepo) Pendientes(ctx context.Context) ([]EntregaPendiente, error) {
+	var lista []EntregaPendiente
+	err := r.db.SelectContext(ctx, &lista, `
+		SELECT id, proximo_intento FROM webhooks_entregas WHERE estado = 'pendiente'
+	`)
+	return lista, err
+}
@@ -0,0 +1,308 @@
+package ubicaciones
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/eventpulse/backend/config"
+	"github.com/eventpulse/backend/internal/geo"
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/eventpulse/backend/internal/repository"
+	"github.com/eventpulse/backend/internal/ws"
+	"github.com/redis/go-redis/v9"
+)
+
+// Claves en Redis:
+//   - ep:ubicacion:<usuario>          última posición (JSON), expira a los TTLSegundos
+//   - ep:ubicaciones:<evento>         sorted set usuario → unix del último reporte
+//   - ep:ubicacion:muestra:<usuario>  marca que limita la escritura del historial
+const (
+	prefijoPosicion = "ep:ubicacion:"
+	prefijoEvento   = "ep:ubicaciones:"
+	prefijoMuestra  = "ep:ubicacion:muestra:"
+)
+
+var (
+	ErrSinTurno          = errors.New("no hay turno abierto")
+	ErrUbicacionInvalida = errors.New("ubicación inválida")
+	ErrZonaInexistente   = errors.New("la zona no existe en el evento")
+)
+
+// Rastreador recibe los reportes de ubicación del staff: la última posición va
+// a Redis (mapa en vivo) y una muestra a Postgres (rastro para revisar
+// incidentes). Solo acepta reportes dentro de un turno abierto.
+type Rastreador struct {
+	repo     *repository.UbicacionRepo
+	zonaRepo *repository.ZonaRepo
+	redis    *redis.Client
+	hub      *ws.Hub
+	cfg      config.UbicacionesConfig
+}
+
+func NewRastreador(r *repository.UbicacionRepo, z *repository.ZonaRepo, rdb *redis.Client, h *ws.Hub, cfg config.UbicacionesConfig) *Rastreador {
+	return &Rastreador{repo: r, zonaRepo: z, redis: rdb, hub: h, cfg: cfg}
+}
+
+// Registrar valida y guarda un reporte del usuario
+func (r *Rastreador) Registrar(ctx context.Context, usuarioID string, req *models.UbicacionRequest) (*models.Ubicacion, error) {
+	if req.Fuente == "" {
+		req.Fuente = models.FuenteGPS
+	}
+	if err := validar(req); err != nil {
+		return nil, err
+	}
+	turno, err := r.turnoVigente(ctx, usuarioID)
+	if err != nil {
+		return nil, err
+	}
+	if req.ZonaID != nil && *req.ZonaID == "" {
+		req.ZonaID = nil
+	}
+	if req.ZonaID != nil {
+		existe, err := r.repo.ZonaExiste(ctx, turno.EventoID, *req.ZonaID)
+		if err != nil {
+			return nil, err
+		}
+		if !existe {
+			return nil, ErrZonaInexistente
+		}
+	} else if req.Latitud != nil {
+		// GPS sin zona: la más específica cuya geometría contiene el punto
+		zonas, err := r.zonaRepo.ConGeometria(ctx, turno.EventoID)
+		if err != nil {
+			log.Println("❌ Error buscando zona de la ubicación:", err)
+		} else if z := geo.Cercana(zonas, *req.Latitud, *req.Longitud, nil); z != nil && z.Dentro {
+			req.ZonaID = &z.Zona.ID
+		}
+	}
+
+	u := &models.Ubicacion{
+		UsuarioID:     usuarioID,
+		NombreUsuario: turno.NombreUsuario,
+		RolUsuario:    turno.RolUsuario,
+		EventoID:      turno.EventoID,
+		Latitud:       req.Latitud,
+		Longitud:      req.Longitud,
+		PrecisionM:    req.PrecisionM,
+		ZonaID:        req.ZonaID,
+		Fuente:        req.Fuente,
+		RegistradaEn:  time.Now(),
+	}
+	previa := r.posicion(ctx, usuarioID)
+	if err := r.guardarEnVivo(ctx, u); err != nil {
+		return nil, err
+	}
+
+	// Historial muestreado: un punto cada MuestreoSegundos, más cada cambio de zona
+	cambioZona := u.ZonaID != nil && (previa == nil || previa.ZonaID == nil || *previa.ZonaID != *u.ZonaID)
+	muestra, err := r.redis.SetNX(ctx, prefijoMuestra+usuarioID, 1, time.Duration(r.cfg.MuestreoSegundos)*time.Second).Result()
+	if err != nil {
+		log.Println("❌ Error muestreando ubicación en Redis:", err)
+	}
+	if muestra || cambioZona {
+		if err := r.repo.Guardar(ctx, turno.ID, u); err != nil {
+			log.Println("❌ Error guardando historial de ubicación:", err)
+		}
+	}
+
+	r.publicarAMando(ctx, turno.EventoID, nil, models.EventoWS{Tipo: models.WSUbicacionActualizada, Payload: u, EventoID: turno.EventoID})
+	return u, nil
+}
+
+// IniciarTurno abre el turno del usuario en el evento; nuevo=false si ya estaba abierto
+func (r *Rastreador) IniciarTurno(ctx context.Context, usuarioID, eventoID string) (*models.Turno, bool, error) {
+	return r.repo.IniciarTurno(ctx, usuarioID, eventoID)
+}
+
+// TerminarTurno cierra el turno y borra la posición en vivo al instante.
+// Con eventoID solo cierra un turno de ese evento.
+func (r *Rastreador) TerminarTurno(ctx context.Context, usuarioID string, eventoID *string, motivo models.MotivoFinTurno) (*models.Turno, error) {
+	t, err := r.repo.TerminarTurno(ctx, usuarioID, eventoID, motivo)
+	if err != nil {
+		return nil, err
+	}
+	r.retirar(ctx, t)
+	return t, nil
+}
+
+// EnVivo devuelve la última posición de cada usuario con reporte reciente
+func (r *Rastreador) EnVivo(ctx context.Context, eventoID string) ([]models.Ubicacion, error) {
+	clave := prefijoEvento + eventoID
+	corte := time.Now().Add(-time.Duration(r.cfg.TTLSegundos) * time.Second).Unix()
+	if err := r.redis.ZRemRangeByScore(ctx, clave, "-inf", "("+strconv.FormatInt(corte, 10)).Err(); err != nil {
+		return nil, err
+	}
+	ids, err := r.redis.ZRange(ctx, clave, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	lista := []models.Ubicacion{}
+	if len(ids) == 0 {
+		return lista, nil
+	}
+	claves := make([]string, len(ids))
+	for i, id := range ids {
+		claves[i] = prefijoPosicion + id
+	}
+	valores, err := r.redis.MGet(ctx, claves...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range valores {
+		s, ok := v.(string)
+		if !ok {
+			continue // expiró entre el ZRANGE y el MGET
+		}
+		var u models.Ubicacion
+		if err := json.Unmarshal([]byte(s), &u); err != nil || u.EventoID != eventoID {
+			continue
+		}
+		lista = append(lista, u)
+	}
+	return lista, nil
+}
+
+// Run cierra los turnos olvidados o de eventos terminados y purga el historial viejo
+func (r *Rastreador) Run(ctx context.Context) {
+	revision := time.NewTicker(time.Minute)
+	defer revision.Stop()
+	purga := time.NewTicker(time.Hour)
+	defer purga.Stop()
+	for {
+		select {
+		case <-revision.C:
+			r.cerrarVencidos(ctx)
+		case <-purga.C:
+			if r.cfg.RetencionDias <= 0 {
+				continue
+			}
+			n, err := r.repo.Purgar(ctx, r.cfg.RetencionDias)
+			if err != nil {
+				log.Println("❌ Error purgando historial de ubicaciones:", err)
+			} else if n > 0 {
+				log.Printf("🧹 %d ubicaciones de más de %d días borradas", n, r.cfg.RetencionDias)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *Rastreador) cerrarVencidos(ctx context.Context) {
+	cerrados, err := r.repo.CerrarVencidos(ctx, r.cfg.TurnoMaxHoras)
+	if err != nil {
+		log.Println("❌ Error cerrando turnos vencidos:", err)
+		return
+	}
+	for i := range cerrados {
+		r.retirar(ctx, &cerrados[i])
+	}
+	if len(cerrados) > 0 {
+		log.Printf("⏹️ %d turnos cerrados automáticamente", len(cerrados))
+	}
+}
+
+// turnoVigente devuelve el turno abierto, cerrándolo si ya superó la duración
+// máxima (el revisor periódico podría no haber pasado todavía)
+func (r *Rastreador) turnoVigente(ctx context.Context, usuarioID string) (*models.Turno, error) {
+	t, err := r.repo.TurnoAbierto(ctx, usuarioID)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, ErrSinTurno
+	}
+	if r.cfg.TurnoMaxHoras > 0 && time.Since(t.Inicio) > time.Duration(r.cfg.TurnoMaxHoras)*time.Hour {
+		if _, err := r.TerminarTurno(ctx, usuarioID, nil, models.FinPorDuracion); err != nil && !errors.Is(err, repository.ErrNoEncontrado) {
+			return nil, err
+		}
+		return nil, ErrSinTurno
+	}
+	return t, nil
+}
+
+func (r *Rastreador) guardarEnVivo(ctx context.Context, u *models.Ubicacion) error {
+	data, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	pipe := r.redis.TxPipeline()
+	pipe.Set(ctx, prefijoPosicion+u.UsuarioID, data, time.Duration(r.cfg.TTLSegundos)*time.Second)
+	pipe.ZAdd(ctx, prefijoEvento+u.EventoID, redis.Z{Score: float64(u.RegistradaEn.Unix()), Member: u.UsuarioID})
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (r *Rastreador) posicion(ctx context.Context, usuarioID string) *models.Ubicacion {
+	data, err := r.redis.Get(ctx, prefijoPosicion+usuarioID).Bytes()
+	if err != nil {
+		return nil
+	}
+	var u models.Ubicacion
+	if json.Unmarshal(data, &u) != nil {
+		return nil
+	}
+	return &u
+}
+
+// retirar borra la posición en vivo y avisa al mapa y a la app del usuario
+// (para que deje de reportar)
+func (r *Rastreador) retirar(ctx context.Context, t *models.Turno) {
+	pipe := r.redis.TxPipeline()
+	pipe.Del(ctx, prefijoPosicion+t.UsuarioID, prefijoMuestra+t.UsuarioID)
+	pipe.ZRem(ctx, prefijoEvento+t.EventoID, t.UsuarioID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Println("❌ Error borrando ubicación en vivo:", err)
+	}
+	motivo := models.FinPorUsuario
+	if t.MotivoFin != nil {
+		motivo = *t.MotivoFin
+	}
+	r.publicarAMando(ctx, t.EventoID, []string{t.UsuarioID}, models.EventoWS{
+		Tipo:     models.WSUbicacionRetirada,
+		Payload:  models.UbicacionRetirada{UsuarioID: t.UsuarioID, Motivo: motivo},
+		EventoID: t.EventoID,
+	})
+}
+
+// publicarAMando envía a admins y supervisores del evento, más los extra
+func (r *Rastreador) publicarAMando(ctx context.Context, eventoID string, extra []string, evento models.EventoWS) {
+	ids, err := r.repo.IDsMando(ctx, eventoID)
+	if err != nil {
+		log.Println("❌ Error buscando destinatarios de ubicación:", err)
+		return
+	}
+	for _, id := range extra {
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	if err := r.hub.PublicarAUsuarios(ctx, ids, evento); err != nil {
+		log.Printf("❌ Error publicando %s en Redis: %v", evento.Tipo, err)
+	}
+}
+
+func validar(req *models.UbicacionRequest) error {
+	if !req.Fuente.EsValida() {
+		return ErrUbicacionInvalida
+	}
+	if (req.Latitud == nil) != (req.Longitud == nil) {
+		return ErrUbicacionInvalida
+	}
+	if req.Latitud != nil && (*req.Latitud < -90 || *req.Latitud > 90 || *req.Longitud < -180 || *req.Longitud > 180) {
+		return ErrUbicacionInvalida
+	}
+	// GPS necesita coordenadas; QR y baliza, la zona escaneada
+	if req.Fuente == models.FuenteGPS && req.Latitud == nil {
+		return ErrUbicacionInvalida
+	}
+	if req.Fuente != models.FuenteGPS && (req.ZonaID == nil || *req.ZonaID == "") {
+		return ErrUbicacionInvalida
+	}
+	return nil
+}
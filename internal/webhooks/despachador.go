@@ -0,0 +1,300 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/eventpulse/backend/config"
+	"github.com/eventpulse/backend/internal/models"
+	"github.com/eventpulse/backend/internal/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+// claveCola es un sorted set de Redis: miembro = id de la entrega,
+// score = unix del próximo intento. Cualquier instancia puede tomar una
+// entrega vencida; el script mover decide cuál se la queda.
+//
+// claveEnCurso guarda las entregas tomadas con score = unix en que vence el
+// plazo de quien la tomó. Si la instancia cae a mitad del envío, el barrido
+// la devuelve a la cola al vencer el plazo.
+const (
+	claveCola    = "ep:webhooks:cola"
+	claveEnCurso = "ep:webhooks:en-curso"
+)
+
+const (
+	concurrencia = 8
+	backoffMax   = time.Hour
+	// Lo que el plazo de una entrega tomada supera al timeout del POST
+	margenPlazo = time.Minute
+	barrido     = time.Minute
+)
+
+// mover pasa la entrega ARGV[1] de KEYS[1] a KEYS[2] con score ARGV[2], solo
+// si todavía estaba en KEYS[1]. Devuelve 1 si la movió.
+var mover = redis.NewScript(`
+if redis.call('ZREM', KEYS[1], ARGV[1]) == 0 then
+  return 0
+end
+redis.call('ZADD', KEYS[2], 'NX', ARGV[2], ARGV[1])
+return 1
+`)
+
+// Despachador convierte las publicaciones del Hub en entregas firmadas a los
+// webhooks suscritos, con reintentos y backoff exponencial.
+type Despachador struct {
+	repo    *repository.WebhookRepo
+	redis   *redis.Client
+	cliente *http.Client
+	cfg     config.WebhooksConfig
+	cupos   chan struct{}
+}
+
+func NewDespachador(r *repository.WebhookRepo, rdb *redis.Client, cfg config.WebhooksConfig) *Despachador {
+	return &Despachador{
+		repo:    r,
+		redis:   rdb,
+		cliente: &http.Client{Timeout: time.Duration(cfg.TimeoutSegundos) * time.Second},
+		cfg:     cfg,
+		cupos:   make(chan struct{}, concurrencia),
+	}
+}
+
+// Cuerpo del POST que recibe el destino
+type cuerpoEntrega struct {
+	EntregaID string              `json:"entrega_id"`
+	WebhookID string              `json:"webhook_id"`
+	EventoID  string              `json:"evento_id"`
+	Tipo      models.TipoEventoWS `json:"tipo"`
+	CreadaEn  time.Time           `json:"creada_en"`
+	Intento   int                 `json:"intento"`
+	Payload   json.RawMessage     `json:"payload"`
+}
+
+// Encolar es el observador de Hub.Publicar: registra una entrega por cada
+// webhook suscrito al tipo y la pone en la cola para ahora.
+func (d *Despachador) Encolar(ctx context.Context, eventoID string, evento models.EventoWS) {
+	if !evento.Tipo.AdmiteWebhook() {
+		return
+	}
+	payload, err := json.Marshal(evento.Payload)
+	if err != nil {
+		log.Printf("❌ Webhook: payload de %s inválido: %v", evento.Tipo, err)
+		return
+	}
+	ids, err := d.repo.CrearEntregas(ctx, eventoID, evento.Tipo, payload)
+	if err != nil {
+		log.Printf("❌ Webhook: error registrando entregas de %s: %v", evento.Tipo, err)
+		return
+	}
+	for _, id := range ids {
+		d.Programar(ctx, id, time.Now())
+	}
+}
+
+// Programar pone (o vuelve a poner) una entrega en la cola para `cuando`
+func (d *Despachador) Programar(ctx context.Context, id string, cuando time.Time) {
+	err := d.redis.ZAdd(ctx, claveCola, redis.Z{Score: float64(cuando.Unix()), Member: id}).Err()
+	if err != nil {
+		log.Printf("❌ Webhook: error encolando entrega %s: %v", id, err)
+	}
+}
+
+func (d *Despachador) Run(ctx context.Context) {
+	d.recuperar(ctx)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	plazos := time.NewTicker(barrido)
+	defer plazos.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			d.tomarVencidas(ctx)
+		case <-plazos.C:
+			d.devolverVencidas(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// recuperar vuelve a encolar las entregas pendientes según la base, por si
+// Redis perdió la cola o una instancia cayó a mitad de un envío.
+func (d *Despachador) recuperar(ctx context.Context) {
+	pendientes, err := d.repo.Pendientes(ctx)
+	if err != nil {
+		log.Println("❌ Webhook: error recuperando entregas pendientes:", err)
+		return
+	}
+	for _, p := range pendientes {
+		d.redis.ZAddNX(ctx, claveCola, redis.Z{Score: float64(p.ProximoIntento.Unix()), Member: p.ID})
+	}
+	if len(pendientes) > 0 {
+		log.Printf("🔁 Webhook: %d entregas pendientes recuperadas", len(pendientes))
+	}
+}
+
+func (d *Despachador) tomarVencidas(ctx context.Context) {
+	ids, err := d.redis.ZRangeByScore(ctx, claveCola, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(time.Now().Unix(), 10),
+		Count: 50,
+	}).Result()
+	if err != nil {
+		log.Println("❌ Webhook: error leyendo la cola:", err)
+		return
+	}
+	plazo := time.Now().Add(time.Duration(d.cfg.TimeoutSegundos)*time.Second + margenPlazo).Unix()
+	for _, id := range ids {
+		// Solo quien logra sacarla de la cola la entrega
+		n, err := mover.Run(ctx, d.redis, []string{claveCola, claveEnCurso}, id, plazo).Int()
+		if err != nil || n == 0 {
+			continue
+		}
+		select {
+		case d.cupos <- struct{}{}:
+		case <-ctx.Done():
+			d.Programar(context.Background(), id, time.Now())
+			d.redis.ZRem(context.Background(), claveEnCurso, id)
+			return
+		}
+		go func(id string) {
+			defer func() { <-d.cupos }()
+			d.entregar(ctx, id)
+			// Ya quedó entregada, reprogramada o muerta. Si se apagó a mitad
+			// (ctx cancelado) sigue en curso y la devuelve el barrido.
+			d.redis.ZRem(ctx, claveEnCurso, id)
+		}(id)
+	}
+}
+
+// devolverVencidas pone otra vez en la cola las entregas tomadas cuyo plazo
+// venció: quien las tomó cayó o perdió Redis antes de terminar. entregar
+// descarta las que igual alcanzaron a cerrarse en la base.
+func (d *Despachador) devolverVencidas(ctx context.Context) {
+	ahora := time.Now().Unix()
+	ids, err := d.redis.ZRangeByScore(ctx, claveEnCurso, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(ahora, 10),
+	}).Result()
+	if err != nil {
+		log.Println("❌ Webhook: error leyendo las entregas en curso:", err)
+		return
+	}
+	devueltas := 0
+	for _, id := range ids {
+		if n, err := mover.Run(ctx, d.redis, []string{claveEnCurso, claveCola}, id, ahora).Int(); err == nil && n == 1 {
+			devueltas++
+		}
+	}
+	if devueltas > 0 {
+		log.Printf("🔁 Webhook: %d entregas en curso vencidas vuelven a la cola", devueltas)
+	}
+}
+
+func (d *Despachador) entregar(ctx context.Context, id string) {
+	e, err := d.repo.ObtenerEntrega(ctx, id)
+	if err != nil || e == nil || e.Estado != models.EntregaPendiente {
+		return
+	}
+	w, err := d.repo.ObtenerConSecreto(ctx, e.WebhookID)
+	if err != nil || w == nil {
+		return
+	}
+	if !w.Activo {
+		// Desactivado con entregas en cola: no se reintenta, queda para reenvío manual
+		d.repo.RegistrarFallo(ctx, e.ID, nil, "webhook desactivado", time.Now(), 0)
+		return
+	}
+
+	cuerpo, _ := json.Marshal(cuerpoEntrega{
+		EntregaID: e.ID,
+		WebhookID: w.ID,
+		EventoID:  w.EventoID,
+		Tipo:      e.Tipo,
+		CreadaEn:  e.CreadaEn,
+		Intento:   e.Intentos + 1,
+		Payload:   e.Payload,
+	})
+	status, motivo := d.enviar(ctx, w, e, cuerpo)
+	if motivo == "" {
+		if err := d.repo.MarcarEntregada(ctx, e.ID, *status); err != nil {
+			log.Printf("❌ Webhook: error registrando entrega %s: %v", e.ID, err)
+		}
+		return
+	}
+
+	proximo := time.Now().Add(d.backoff(e.Intentos))
+	estado, err := d.repo.RegistrarFallo(ctx, e.ID, status, motivo, proximo, d.cfg.MaxIntentos)
+	if err != nil {
+		log.Printf("❌ Webhook: error registrando fallo de %s: %v", e.ID, err)
+		return
+	}
+	if estado == models.EntregaMuerta {
+		log.Printf("💀 Webhook %s: entrega %s agotó sus intentos (%s)", w.ID, e.ID, motivo)
+		return
+	}
+	d.Programar(ctx, e.ID, proximo)
+}
+
+// enviar hace el POST firmado. Devuelve el status (si hubo respuesta) y un
+// motivo de fallo vacío si el destino respondió 2xx.
+func (d *Despachador) enviar(ctx context.Context, w *models.Webhook, e *models.EntregaWebhook, cuerpo []byte) (*int, string) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(cuerpo))
+	if err != nil {
+		return nil, err.Error()
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "EventPulse-Webhooks/2.0")
+	req.Header.Set("X-EventPulse-Evento", string(e.Tipo))
+	req.Header.Set("X-EventPulse-Entrega", e.ID)
+	req.Header.Set("X-EventPulse-Firma", "t="+ts+",v1="+Firmar(w.Secreto, ts, cuerpo))
+
+	resp, err := d.cliente.Do(req)
+	if err != nil {
+		return nil, err.Error()
+	}
+	defer resp.Body.Close()
+	inicio, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	status := resp.StatusCode
+	if status >= 200 && status < 300 {
+		return &status, ""
+	}
+	return &status, fmt.Sprintf("HTTP %d: %s", status, inicio)
+}
+
+// backoff: BackoffSegundos × 2^intentos previos, con tope de una hora
+func (d *Despachador) backoff(intentosPrevios int) time.Duration {
+	espera := time.Duration(d.cfg.BackoffSegundos) * time.Second
+	for i := 0; i < intentosPrevios && espera < backoffMax; i++ {
+		espera *= 2
+	}
+	if espera > backoffMax {
+		espera = backoffMax
+	}
+	return espera
+}
+
+// Firmar calcula la firma que el destino debe verificar:
+// hex(HMAC-SHA256(secreto, "<timestamp>.<cuerpo>")).
+func Firmar(secreto, timestamp string, cuerpo []byte) string {
+	mac := hmac.New(sha256.New, []byte(secreto))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(cuerpo)
+	return hex.EncodeToString(mac.Sum(nil))
+}
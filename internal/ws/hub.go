@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
@@ -28,6 +29,7 @@ type Cliente struct {
 	send      chan []byte
 	UsuarioID string
 	EventoID  string
+	Rol       models.Rol
 }
 
 func (c *Cliente) leer(maxSize int64, pongWait time.Duration) {
@@ -42,15 +44,17 @@ func (c *Cliente) leer(maxSize int64, pongWait time.Duration) {
 		return nil
 	})
 	for {
-		// El cliente solo recibe. No procesa mensajes entrantes por WS.
-		// Todo se envía por REST y se distribuye por WS.
-		_, _, err := c.conn.ReadMessage()
+		// Todo lo que se persiste entra por REST y se distribuye por WS. Por el
+		// socket solo llegan comandos efímeros (ej: "escribiendo") que se pasan
+		// al manejador registrado para su tipo.
+		_, data, err := c.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("WS cliente %s cerró inesperadamente: %v", c.UsuarioID, err)
 			}
 			break
 		}
+		c.hub.despachar(c, data)
 	}
 }
 
@@ -82,8 +86,17 @@ func (c *Cliente) escribir(pingPeriod time.Duration) {
 
 // ─── Hub ──────────────────────────────────────────────────────────────────────
 
+// ManejadorComando procesa un comando entrante de un cliente
+type ManejadorComando func(c *Cliente, payload json.RawMessage)
+
+// ObservadorPublicacion recibe cada evento que se publica a todo un evento
+// (ej: los webhooks salientes). Corre en la goroutine de quien publica.
+type ObservadorPublicacion func(ctx context.Context, eventoID string, evento models.EventoWS)
+
 type Hub struct {
 	clientes     map[string]map[*Cliente]bool // eventoID → clientes
+	comandos     map[models.TipoEventoWS]ManejadorComando
+	observadores []ObservadorPublicacion
 	mu           sync.RWMutex
 	registrar    chan *Cliente
 	desregistrar chan *Cliente
@@ -94,6 +107,7 @@ type Hub struct {
 func NewHub(r *redis.Client, cfg *config.Config) *Hub {
 	return &Hub{
 		clientes:     make(map[string]map[*Cliente]bool),
+		comandos:     make(map[models.TipoEventoWS]ManejadorComando),
 		registrar:    make(chan *Cliente, 64),
 		desregistrar: make(chan *Cliente, 64),
 		redis:        r,
@@ -101,6 +115,31 @@ func NewHub(r *redis.Client, cfg *config.Config) *Hub {
 	}
 }
 
+// RegistrarComando asocia un tipo de comando entrante a su manejador.
+// Debe llamarse antes de Run (el mapa no se protege con lock).
+func (h *Hub) RegistrarComando(tipo models.TipoEventoWS, fn ManejadorComando) {
+	h.comandos[tipo] = fn
+}
+
+// AlPublicar registra un observador de Publicar. Como RegistrarComando, debe
+// llamarse antes de Run.
+func (h *Hub) AlPublicar(fn ObservadorPublicacion) {
+	h.observadores = append(h.observadores, fn)
+}
+
+func (h *Hub) despachar(c *Cliente, data []byte) {
+	var cmd models.ComandoWS
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		log.Printf("WS comando inválido de %s: %v", c.UsuarioID, err)
+		return
+	}
+	fn, ok := h.comandos[cmd.Tipo]
+	if !ok {
+		return
+	}
+	fn(c, cmd.Payload)
+}
+
 func (h *Hub) Run(ctx context.Context) {
 	go h.escucharRedis(ctx)
 	for {
@@ -138,11 +177,38 @@ func (h *Hub) Publicar(ctx context.Context, eventoID string, evento models.Event
 	if err != nil {
 		return err
 	}
-	return h.redis.Publish(ctx, "ep:evento:"+eventoID, data).Err()
+	if err := h.redis.Publish(ctx, "ep:evento:"+eventoID, data).Err(); err != nil {
+		return err
+	}
+	// Cada instancia solo observa lo que publica ella misma, así que no hay duplicados
+	for _, fn := range h.observadores {
+		fn(ctx, eventoID, evento)
+	}
+	return nil
+}
+
+// PublicarAUsuario envía un evento solo a las conexiones de un usuario dentro del evento
+// (ej: rollback de un conflicto de asignación que solo le interesa a quien perdió).
+func (h *Hub) PublicarAUsuario(ctx context.Context, usuarioID string, evento models.EventoWS) error {
+	return h.PublicarAUsuarios(ctx, []string{usuarioID}, evento)
+}
+
+// PublicarAUsuarios envía un evento solo a un conjunto de usuarios (ej: miembros de una conversación)
+func (h *Hub) PublicarAUsuarios(ctx context.Context, usuarioIDs []string, evento models.EventoWS) error {
+	data, err := json.Marshal(evento)
+	if err != nil {
+		return err
+	}
+	pipe := h.redis.Pipeline()
+	for _, id := range usuarioIDs {
+		pipe.Publish(ctx, "ep:usuario:"+id, data)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
 }
 
 func (h *Hub) escucharRedis(ctx context.Context) {
-	pubsub := h.redis.PSubscribe(ctx, "ep:evento:*")
+	pubsub := h.redis.PSubscribe(ctx, "ep:evento:*", "ep:usuario:*")
 	defer pubsub.Close()
 
 	log.Println("👂 Escuchando Redis...")
@@ -162,7 +228,16 @@ func (h *Hub) escucharRedis(ctx context.Context) {
 				continue
 			}
 
-			h.distribuir(evento.EventoID, []byte(msg.Payload))
+			if usuarioID, ok := strings.CutPrefix(msg.Channel, "ep:usuario:"); ok {
+				h.distribuir(evento.EventoID, []byte(msg.Payload), func(c *Cliente) bool {
+					return c.UsuarioID == usuarioID
+				})
+				if evento.Tipo == models.WSSesionRevocada {
+					h.expulsar(evento.EventoID, usuarioID)
+				}
+				continue
+			}
+			h.distribuir(evento.EventoID, []byte(msg.Payload), nil)
 
 		case <-ctx.Done():
 			return
@@ -170,10 +245,14 @@ func (h *Hub) escucharRedis(ctx context.Context) {
 	}
 }
 
-func (h *Hub) distribuir(eventoID string, data []byte) {
+// distribuir entrega data a los clientes del evento; si filtro != nil solo a los que lo cumplen
+func (h *Hub) distribuir(eventoID string, data []byte, filtro func(*Cliente) bool) {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 	for c := range h.clientes[eventoID] {
+		if filtro != nil && !filtro(c) {
+			continue
+		}
 		select {
 		case c.send <- data:
 		default:
@@ -183,8 +262,21 @@ func (h *Hub) distribuir(eventoID string, data []byte) {
 	}
 }
 
+// expulsar cierra las conexiones del usuario en el evento. El aviso ya está
+// en el buffer: el escritor lo entrega antes de ver el canal cerrado.
+func (h *Hub) expulsar(eventoID, usuarioID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for c := range h.clientes[eventoID] {
+		if c.UsuarioID == usuarioID {
+			close(c.send)
+			delete(h.clientes[eventoID], c)
+		}
+	}
+}
+
 // HandleConexion hace el upgrade HTTP→WS y registra el cliente
-func (h *Hub) HandleConexion(w http.ResponseWriter, r *http.Request, usuarioID, eventoID string) {
+func (h *Hub) HandleConexion(w http.ResponseWriter, r *http.Request, usuarioID, eventoID string, rol models.Rol) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("Error upgrade WS: %v", err)
@@ -196,6 +288,7 @@ func (h *Hub) HandleConexion(w http.ResponseWriter, r *http.Request, usuarioID,
 		send:      make(chan []byte, 512),
 		UsuarioID: usuarioID,
 		EventoID:  eventoID,
+		Rol:       rol,
 	}
 	h.registrar <- c
 
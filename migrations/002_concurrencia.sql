@@ -0,0 +1,26 @@
+-- ============================================================
+-- EventPulse - Control de concurrencia optimista
+-- ============================================================
+-- Cada UPDATE incrementa `version`. Los PATCH comparan la versión que
+-- el cliente leyó (header If-Match) con la actual antes de escribir.
+
+ALTER TABLE eventos     ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE incidencias ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE tareas      ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+
+CREATE OR REPLACE FUNCTION incrementar_version()
+RETURNS TRIGGER AS $$
+BEGIN NEW.version = OLD.version + 1; RETURN NEW; END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_eventos_version
+    BEFORE UPDATE ON eventos
+    FOR EACH ROW EXECUTE FUNCTION incrementar_version();
+
+CREATE TRIGGER trg_incidencias_version
+    BEFORE UPDATE ON incidencias
+    FOR EACH ROW EXECUTE FUNCTION incrementar_version();
+
+CREATE TRIGGER trg_tareas_version
+    BEFORE UPDATE ON tareas
+    FOR EACH ROW EXECUTE FUNCTION incrementar_version();
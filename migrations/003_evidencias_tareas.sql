@@ -0,0 +1,35 @@
+-- ============================================================
+-- EventPulse - Evidencia obligatoria para completar tareas
+-- ============================================================
+-- requiere_evidencia: tipos que deben existir en tareas_evidencias antes de
+-- pasar la tarea a 'completada' (foto | nota | qr | gps).
+
+ALTER TABLE tareas ADD COLUMN IF NOT EXISTS requiere_evidencia TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE TABLE IF NOT EXISTS tareas_evidencias (
+    id          UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tarea_id    UUID NOT NULL REFERENCES tareas(id) ON DELETE CASCADE,
+    tipo        VARCHAR(10) NOT NULL CHECK (tipo IN ('foto','nota','qr','gps')),
+    nota        TEXT,
+    qr          TEXT,                        -- contenido escaneado del QR de la zona
+    latitud     DOUBLE PRECISION,
+    longitud    DOUBLE PRECISION,
+    foto        BYTEA,
+    foto_mime   VARCHAR(50),
+    usuario_id  UUID NOT NULL REFERENCES usuarios(id),
+    creada_en   TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_tareas_evidencias_tarea ON tareas_evidencias(tarea_id);
+
+-- Historial de estados de tareas (equivalente a incidencias_historial)
+CREATE TABLE IF NOT EXISTS tareas_historial (
+    id              UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tarea_id        UUID NOT NULL REFERENCES tareas(id) ON DELETE CASCADE,
+    estado_anterior VARCHAR(20),
+    estado_nuevo    VARCHAR(20),
+    usuario_id      UUID REFERENCES usuarios(id),
+    cambiado_en     TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_tareas_historial_tarea ON tareas_historial(tarea_id);
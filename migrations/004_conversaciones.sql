@@ -0,0 +1,64 @@
+-- ============================================================
+-- EventPulse - Conversaciones de chat
+-- ============================================================
+-- tipo:
+--   evento  → sala general del evento (todos los vinculados + admin)
+--   rol     → canal por rol, ej: todos los guardias (+ admin)
+--   directo → 1:1, miembros explícitos
+--   grupo   → ad-hoc, miembros explícitos
+--   zona    → canal de una zona, miembros explícitos
+
+CREATE TABLE IF NOT EXISTS conversaciones (
+    id            UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    evento_id     UUID NOT NULL REFERENCES eventos(id) ON DELETE CASCADE,
+    tipo          VARCHAR(10) NOT NULL
+                  CHECK (tipo IN ('evento','rol','directo','grupo','zona')),
+    nombre        VARCHAR(100) NOT NULL DEFAULT '',
+    rol           VARCHAR(20),                   -- solo tipo rol
+    zona_id       VARCHAR(50),                   -- solo tipo zona
+    clave_directo VARCHAR(80),                   -- solo tipo directo: "<uuid menor>:<uuid mayor>"
+    creada_por    UUID REFERENCES usuarios(id),
+    creada_en     TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_conversaciones_evento
+    ON conversaciones(evento_id) WHERE tipo = 'evento';
+CREATE UNIQUE INDEX IF NOT EXISTS uq_conversaciones_rol
+    ON conversaciones(evento_id, rol) WHERE tipo = 'rol';
+CREATE UNIQUE INDEX IF NOT EXISTS uq_conversaciones_directo
+    ON conversaciones(evento_id, clave_directo) WHERE tipo = 'directo';
+CREATE UNIQUE INDEX IF NOT EXISTS uq_conversaciones_zona
+    ON conversaciones(evento_id, zona_id) WHERE tipo = 'zona';
+
+-- Miembros explícitos (directo, grupo, zona). evento y rol son implícitos.
+CREATE TABLE IF NOT EXISTS conversaciones_miembros (
+    conversacion_id UUID NOT NULL REFERENCES conversaciones(id) ON DELETE CASCADE,
+    usuario_id      UUID NOT NULL REFERENCES usuarios(id) ON DELETE CASCADE,
+    unido_en        TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (conversacion_id, usuario_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_conversaciones_miembros_usuario ON conversaciones_miembros(usuario_id);
+
+-- Hasta dónde leyó cada usuario cada conversación (para no leídos)
+CREATE TABLE IF NOT EXISTS conversaciones_lecturas (
+    conversacion_id UUID NOT NULL REFERENCES conversaciones(id) ON DELETE CASCADE,
+    usuario_id      UUID NOT NULL REFERENCES usuarios(id) ON DELETE CASCADE,
+    leido_hasta     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (conversacion_id, usuario_id)
+);
+
+-- Los mensajes existentes pasan a la sala general de su evento
+INSERT INTO conversaciones (evento_id, tipo, nombre)
+SELECT id, 'evento', 'General' FROM eventos
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE mensajes ADD COLUMN IF NOT EXISTS conversacion_id UUID REFERENCES conversaciones(id) ON DELETE CASCADE;
+
+UPDATE mensajes m SET conversacion_id = c.id
+FROM conversaciones c
+WHERE c.evento_id = m.evento_id AND c.tipo = 'evento' AND m.conversacion_id IS NULL;
+
+ALTER TABLE mensajes ALTER COLUMN conversacion_id SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_mensajes_conversacion ON mensajes(conversacion_id, enviado_en DESC);
@@ -0,0 +1,10 @@
+-- ============================================================
+-- EventPulse - Confirmaciones de lectura del chat
+-- ============================================================
+-- El cursor de lectura apunta al último mensaje visto; leido_hasta es su
+-- enviado_en y es lo que se compara para contar no leídos; leido_en es
+-- cuándo lo leyó (lo que se muestra en la confirmación).
+
+ALTER TABLE conversaciones_lecturas
+    ADD COLUMN IF NOT EXISTS ultimo_mensaje_id UUID REFERENCES mensajes(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS leido_en TIMESTAMPTZ NOT NULL DEFAULT NOW();
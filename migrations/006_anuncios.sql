@@ -0,0 +1,33 @@
+-- ============================================================
+-- EventPulse - Anuncios prioritarios con confirmación
+-- ============================================================
+-- Los destinatarios se fijan al crear el anuncio. Mientras el anuncio esté
+-- abierto, a quien no confirmó se le reenvía cada reenviar_cada_seg.
+
+CREATE TABLE IF NOT EXISTS anuncios (
+    id                UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    evento_id         UUID NOT NULL REFERENCES eventos(id) ON DELETE CASCADE,
+    titulo            VARCHAR(150) NOT NULL,
+    contenido         TEXT NOT NULL,
+    destino           VARCHAR(10) NOT NULL CHECK (destino IN ('todos','rol','zona')),
+    rol               VARCHAR(20),
+    zona_id           VARCHAR(50),
+    reenviar_cada_seg INTEGER NOT NULL DEFAULT 60 CHECK (reenviar_cada_seg >= 10),
+    creado_por        UUID NOT NULL REFERENCES usuarios(id),
+    creado_en         TIMESTAMPTZ DEFAULT NOW(),
+    cerrado_en        TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_anuncios_evento ON anuncios(evento_id);
+
+CREATE TABLE IF NOT EXISTS anuncios_destinatarios (
+    anuncio_id    UUID NOT NULL REFERENCES anuncios(id) ON DELETE CASCADE,
+    usuario_id    UUID NOT NULL REFERENCES usuarios(id) ON DELETE CASCADE,
+    entregas      INTEGER NOT NULL DEFAULT 1,
+    ultimo_envio  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    confirmado_en TIMESTAMPTZ,
+    PRIMARY KEY (anuncio_id, usuario_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_anuncios_pendientes
+    ON anuncios_destinatarios(ultimo_envio) WHERE confirmado_en IS NULL;
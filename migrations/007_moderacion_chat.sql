@@ -0,0 +1,41 @@
+-- ============================================================
+-- EventPulse - Edición, borrado y moderación del chat
+-- ============================================================
+
+-- Borrado lógico: el mensaje se conserva para auditoría pero se oculta
+ALTER TABLE mensajes
+    ADD COLUMN IF NOT EXISTS editado_en    TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS eliminado_en  TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS eliminado_por UUID REFERENCES usuarios(id);
+
+-- Contenido anterior de cada edición
+CREATE TABLE IF NOT EXISTS mensajes_revisiones (
+    id          UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    mensaje_id  UUID NOT NULL REFERENCES mensajes(id) ON DELETE CASCADE,
+    contenido   TEXT NOT NULL,
+    editado_por UUID NOT NULL REFERENCES usuarios(id),
+    editado_en  TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_mensajes_revisiones_mensaje ON mensajes_revisiones(mensaje_id);
+
+-- Usuarios silenciados temporalmente en el chat de un evento
+CREATE TABLE IF NOT EXISTS chat_silencios (
+    evento_id  UUID NOT NULL REFERENCES eventos(id) ON DELETE CASCADE,
+    usuario_id UUID NOT NULL REFERENCES usuarios(id) ON DELETE CASCADE,
+    hasta      TIMESTAMPTZ NOT NULL,
+    motivo     TEXT,
+    creado_por UUID NOT NULL REFERENCES usuarios(id),
+    creado_en  TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (evento_id, usuario_id)
+);
+
+-- Palabras filtradas: censurar (se reemplaza por ***) o bloquear (se rechaza el mensaje)
+CREATE TABLE IF NOT EXISTS chat_filtros (
+    id        UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    evento_id UUID NOT NULL REFERENCES eventos(id) ON DELETE CASCADE,
+    palabra   VARCHAR(100) NOT NULL,
+    accion    VARCHAR(10) NOT NULL DEFAULT 'censurar' CHECK (accion IN ('censurar','bloquear')),
+    creado_en TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (evento_id, palabra)
+);
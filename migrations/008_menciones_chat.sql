@@ -0,0 +1,19 @@
+-- ============================================================
+-- EventPulse - Menciones y referencias en el chat
+-- ============================================================
+-- Cada mención resuelta del contenido de un mensaje:
+--   usuario    → @nombre_usuario   (objetivo = id del usuario)
+--   rol        → @guardia, @medico (objetivo = rol)
+--   incidencia → #inc-3f2a9c       (objetivo = id de la incidencia)
+--   tarea      → #tarea-81b0d2     (objetivo = id de la tarea)
+-- texto guarda el token tal como se escribió para que el cliente lo resalte.
+
+CREATE TABLE IF NOT EXISTS mensajes_menciones (
+    mensaje_id UUID NOT NULL REFERENCES mensajes(id) ON DELETE CASCADE,
+    tipo       VARCHAR(12) NOT NULL CHECK (tipo IN ('usuario','rol','incidencia','tarea')),
+    objetivo   VARCHAR(50) NOT NULL,
+    texto      VARCHAR(60) NOT NULL,
+    PRIMARY KEY (mensaje_id, tipo, objetivo)
+);
+
+CREATE INDEX IF NOT EXISTS idx_mensajes_menciones_objetivo ON mensajes_menciones(tipo, objetivo);
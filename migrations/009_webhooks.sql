@@ -0,0 +1,42 @@
+-- ============================================================
+-- EventPulse - Webhooks salientes
+-- ============================================================
+-- Suscripciones del admin a tipos de evento WS de un evento. Cada
+-- publicación que coincide crea una entrega; la cola de reintentos vive en
+-- Redis (ep:webhooks:cola) y esta tabla es el registro de entregas.
+
+CREATE TABLE IF NOT EXISTS webhooks (
+    id          UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    evento_id   UUID NOT NULL REFERENCES eventos(id) ON DELETE CASCADE,
+    url         TEXT NOT NULL,
+    descripcion VARCHAR(255),
+    tipos       TEXT[] NOT NULL,                 -- tipos de EventoWS suscritos
+    secreto     VARCHAR(64) NOT NULL,            -- clave HMAC-SHA256 de la firma
+    activo      BOOLEAN NOT NULL DEFAULT true,
+    creado_por  UUID NOT NULL REFERENCES usuarios(id),
+    creado_en   TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhooks_evento ON webhooks(evento_id) WHERE activo;
+
+-- estado:
+--   pendiente → en cola (primer intento o reintento programado)
+--   entregada → el destino respondió 2xx
+--   muerta    → agotó los intentos (dead-letter); se puede reenviar a mano
+CREATE TABLE IF NOT EXISTS webhooks_entregas (
+    id              UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    webhook_id      UUID NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    tipo            VARCHAR(40) NOT NULL,
+    payload         JSONB NOT NULL,
+    estado          VARCHAR(10) NOT NULL DEFAULT 'pendiente'
+                    CHECK (estado IN ('pendiente','entregada','muerta')),
+    intentos        INTEGER NOT NULL DEFAULT 0,
+    ultimo_status   INTEGER,
+    ultimo_error    TEXT,
+    proximo_intento TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    creada_en       TIMESTAMPTZ DEFAULT NOW(),
+    entregada_en    TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhooks_entregas_webhook ON webhooks_entregas(webhook_id, creada_en DESC);
+CREATE INDEX IF NOT EXISTS idx_webhooks_entregas_pendientes ON webhooks_entregas(proximo_intento) WHERE estado = 'pendiente';
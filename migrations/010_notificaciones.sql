@@ -0,0 +1,53 @@
+-- ============================================================
+-- EventPulse - Notificaciones fuera de la app (email, SMS, Web Push)
+-- ============================================================
+
+-- Preferencias por usuario. Sin fila = valores por defecto (solo push, todos los tipos).
+-- silencio_desde/hasta en "HH:MM" de la zona horaria del usuario; el rango puede
+-- cruzar la medianoche (22:00 → 07:00).
+CREATE TABLE IF NOT EXISTS notif_preferencias (
+    usuario_id           UUID PRIMARY KEY REFERENCES usuarios(id) ON DELETE CASCADE,
+    canales              TEXT[] NOT NULL DEFAULT '{push}',
+    tipos                TEXT[] NOT NULL DEFAULT '{asignacion,escalamiento,anuncio}',
+    email                VARCHAR(255),
+    telefono             VARCHAR(30),
+    silencio_desde       VARCHAR(5),
+    silencio_hasta       VARCHAR(5),
+    zona_horaria         VARCHAR(50) NOT NULL DEFAULT 'UTC',
+    urgentes_en_silencio BOOLEAN NOT NULL DEFAULT true,
+    actualizada_en       TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Suscripciones Web Push (una por navegador/dispositivo)
+CREATE TABLE IF NOT EXISTS notif_push_suscripciones (
+    id         UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    usuario_id UUID NOT NULL REFERENCES usuarios(id) ON DELETE CASCADE,
+    endpoint   TEXT NOT NULL UNIQUE,
+    p256dh     VARCHAR(100) NOT NULL,
+    auth       VARCHAR(50) NOT NULL,
+    creada_en  TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notif_push_usuario ON notif_push_suscripciones(usuario_id);
+
+-- Registro de cada intento por canal (también lo omitido por horario o sin contacto)
+CREATE TABLE IF NOT EXISTS notif_envios (
+    id         UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    usuario_id UUID NOT NULL REFERENCES usuarios(id) ON DELETE CASCADE,
+    tipo       VARCHAR(20) NOT NULL,
+    canal      VARCHAR(10),                      -- NULL si se omitió antes de elegir canal
+    titulo     VARCHAR(255) NOT NULL,
+    cuerpo     TEXT NOT NULL,
+    estado     VARCHAR(10) NOT NULL CHECK (estado IN ('enviado','fallido','omitido')),
+    detalle    TEXT,
+    creado_en  TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notif_envios_usuario ON notif_envios(usuario_id, creado_en DESC);
+
+-- Incidencias escaladas a supervisión por seguir pendientes demasiado tiempo.
+-- Tabla aparte para no tocar incidencias (y no subir su version).
+CREATE TABLE IF NOT EXISTS incidencias_escalamientos (
+    incidencia_id UUID PRIMARY KEY REFERENCES incidencias(id) ON DELETE CASCADE,
+    escalada_en   TIMESTAMPTZ DEFAULT NOW()
+);
@@ -0,0 +1,38 @@
+-- ============================================================
+-- EventPulse - Sensores IoT que crean incidencias
+-- ============================================================
+-- Cada dispositivo (detector de humo, alarma de puerta, sensor de llenado)
+-- se autentica con un token propio y hace POST de su lectura. Las reglas
+-- (JSONB, en orden) deciden si la lectura abre una incidencia, de qué tipo
+-- y en qué zona.
+
+CREATE TABLE IF NOT EXISTS dispositivos (
+    id               UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    evento_id        UUID NOT NULL REFERENCES eventos(id) ON DELETE CASCADE,
+    nombre           VARCHAR(100) NOT NULL,
+    zona_id          VARCHAR(50) NOT NULL,            -- zona por defecto
+    campo_zona       VARCHAR(100),                    -- campo del payload con la zona (gateways)
+    reglas           JSONB NOT NULL DEFAULT '[]',
+    ventana_segundos INTEGER NOT NULL DEFAULT 300 CHECK (ventana_segundos >= 0),
+    token_hash       CHAR(64) NOT NULL UNIQUE,        -- sha256 del token; el token no se guarda
+    activo           BOOLEAN NOT NULL DEFAULT true,
+    creado_por       UUID NOT NULL REFERENCES usuarios(id),  -- figura como creador de sus incidencias
+    creado_en        TIMESTAMPTZ DEFAULT NOW(),
+    ultimo_dato_en   TIMESTAMPTZ,
+    ultimo_payload   JSONB
+);
+
+CREATE INDEX IF NOT EXISTS idx_dispositivos_evento ON dispositivos(evento_id);
+
+-- Deduplicación de ráfagas: una fila por (dispositivo, tipo, zona). Mientras
+-- no pase la ventana desde `desde`, las lecturas repetidas solo suman a
+-- `suprimidas` y no abren otra incidencia.
+CREATE TABLE IF NOT EXISTS dispositivos_alertas (
+    dispositivo_id UUID NOT NULL REFERENCES dispositivos(id) ON DELETE CASCADE,
+    tipo           VARCHAR(30) NOT NULL,
+    zona_id        VARCHAR(50) NOT NULL,
+    incidencia_id  UUID REFERENCES incidencias(id) ON DELETE SET NULL,
+    desde          TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    suprimidas     INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (dispositivo_id, tipo, zona_id)
+);
@@ -0,0 +1,7 @@
+-- ============================================================
+-- EventPulse - Puente MQTT
+-- ============================================================
+-- Un dispositivo puede publicar por MQTT en vez de HTTP: el tema en el que
+-- publica lo identifica (la autenticación la hace el broker con sus ACL).
+
+ALTER TABLE dispositivos ADD COLUMN IF NOT EXISTS tema_mqtt VARCHAR(200) UNIQUE;
@@ -0,0 +1,33 @@
+-- ============================================================
+-- EventPulse - Botón de pánico (SOS)
+-- ============================================================
+-- Un SOS es una incidencia de tipo 'sos' creada por quien está en peligro
+-- (creada_por = quien la reporta), más el rastro de ubicaciones que su app
+-- envía mientras la incidencia no esté resuelta.
+
+ALTER TABLE incidencias DROP CONSTRAINT IF EXISTS incidencias_tipo_check;
+ALTER TABLE incidencias ADD CONSTRAINT incidencias_tipo_check
+    CHECK (tipo IN ('derrame','seguridad','reabastecimiento','medico','otro','sos'));
+
+CREATE TABLE IF NOT EXISTS sos_alertas (
+    id            UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    incidencia_id UUID NOT NULL UNIQUE REFERENCES incidencias(id) ON DELETE CASCADE,
+    evento_id     UUID NOT NULL REFERENCES eventos(id) ON DELETE CASCADE,
+    usuario_id    UUID NOT NULL REFERENCES usuarios(id),
+    creada_en     TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_sos_alertas_usuario ON sos_alertas(usuario_id, creada_en DESC);
+CREATE INDEX IF NOT EXISTS idx_sos_alertas_evento ON sos_alertas(evento_id, creada_en DESC);
+
+CREATE TABLE IF NOT EXISTS sos_ubicaciones (
+    id            UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    sos_id        UUID NOT NULL REFERENCES sos_alertas(id) ON DELETE CASCADE,
+    latitud       DOUBLE PRECISION NOT NULL,
+    longitud      DOUBLE PRECISION NOT NULL,
+    precision_m   DOUBLE PRECISION,
+    zona_id       VARCHAR(50),
+    registrada_en TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_sos_ubicaciones_sos ON sos_ubicaciones(sos_id, registrada_en DESC);
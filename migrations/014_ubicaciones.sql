@@ -0,0 +1,38 @@
+-- ============================================================
+-- EventPulse - Ubicación del staff
+-- ============================================================
+-- La posición en vivo vive en Redis con TTL; aquí queda solo el historial
+-- muestreado para revisar incidentes. Solo se rastrea dentro de un turno
+-- abierto: al cerrarlo se deja de aceptar y de guardar ubicación.
+
+CREATE TABLE IF NOT EXISTS turnos (
+    id         UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    usuario_id UUID NOT NULL REFERENCES usuarios(id) ON DELETE CASCADE,
+    evento_id  UUID NOT NULL REFERENCES eventos(id) ON DELETE CASCADE,
+    inicio     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    fin        TIMESTAMPTZ,
+    motivo_fin VARCHAR(20)
+               CHECK (motivo_fin IN ('usuario','supervisor','duracion','evento'))
+);
+
+-- Un solo turno abierto por usuario
+CREATE UNIQUE INDEX IF NOT EXISTS idx_turnos_abierto ON turnos(usuario_id) WHERE fin IS NULL;
+CREATE INDEX IF NOT EXISTS idx_turnos_usuario ON turnos(usuario_id, inicio DESC);
+
+CREATE TABLE IF NOT EXISTS ubicaciones (
+    id            UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    usuario_id    UUID NOT NULL REFERENCES usuarios(id) ON DELETE CASCADE,
+    evento_id     UUID NOT NULL REFERENCES eventos(id) ON DELETE CASCADE,
+    turno_id      UUID NOT NULL REFERENCES turnos(id) ON DELETE CASCADE,
+    latitud       DOUBLE PRECISION,
+    longitud      DOUBLE PRECISION,
+    precision_m   DOUBLE PRECISION,
+    zona_id       VARCHAR(50),
+    fuente        VARCHAR(10) NOT NULL CHECK (fuente IN ('gps','qr','baliza')),
+    registrada_en TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK ((latitud IS NULL) = (longitud IS NULL)),
+    CHECK (latitud IS NOT NULL OR zona_id IS NOT NULL)
+);
+
+CREATE INDEX IF NOT EXISTS idx_ubicaciones_usuario ON ubicaciones(usuario_id, registrada_en DESC);
+CREATE INDEX IF NOT EXISTS idx_ubicaciones_registrada ON ubicaciones(registrada_en);
@@ -0,0 +1,26 @@
+-- ============================================================
+-- EventPulse - Geometría y jerarquía de zonas
+-- ============================================================
+-- Jerarquía: sector → area → punto (ej: "gradas-norte" → "bloque-b" →
+-- "bano-b2"). Geometría opcional: un punto (latitud/longitud + radio) o un
+-- polígono GeoJSON ([[[lng, lat], ...]], primer anillo exterior, el resto
+-- huecos). Sin PostGIS: las cuentas geométricas se hacen en la API.
+
+ALTER TABLE zonas ADD COLUMN IF NOT EXISTS tipo VARCHAR(10) NOT NULL DEFAULT 'area'
+    CHECK (tipo IN ('sector','area','punto'));
+ALTER TABLE zonas ADD COLUMN IF NOT EXISTS padre_id  VARCHAR(50);
+ALTER TABLE zonas ADD COLUMN IF NOT EXISTS latitud   DOUBLE PRECISION;
+ALTER TABLE zonas ADD COLUMN IF NOT EXISTS longitud  DOUBLE PRECISION;
+ALTER TABLE zonas ADD COLUMN IF NOT EXISTS radio_m   DOUBLE PRECISION CHECK (radio_m > 0);
+ALTER TABLE zonas ADD COLUMN IF NOT EXISTS poligono  JSONB;
+ALTER TABLE zonas ADD COLUMN IF NOT EXISTS piso      INT;
+
+ALTER TABLE zonas ADD CONSTRAINT zonas_punto_completo
+    CHECK ((latitud IS NULL) = (longitud IS NULL));
+
+-- El padre es del mismo evento; al borrarlo los hijos quedan en la raíz
+ALTER TABLE zonas ADD CONSTRAINT fk_zonas_padre
+    FOREIGN KEY (padre_id, evento_id) REFERENCES zonas(id, evento_id)
+    ON DELETE SET NULL (padre_id);
+
+CREATE INDEX IF NOT EXISTS idx_zonas_padre ON zonas(evento_id, padre_id);
@@ -0,0 +1,11 @@
+-- ============================================================
+-- EventPulse - Edición, archivo y borrado seguro de zonas
+-- ============================================================
+-- Una zona archivada deja de ofrecerse para trabajo nuevo y del mapa, pero
+-- sigue existiendo: las incidencias, tareas y anuncios que la referencian
+-- conservan su nombre.
+
+ALTER TABLE zonas ADD COLUMN IF NOT EXISTS archivada_en TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_incidencias_zona ON incidencias(evento_id, zona_id);
+CREATE INDEX IF NOT EXISTS idx_tareas_zona ON tareas(evento_id, zona_id);
@@ -0,0 +1,75 @@
+-- ============================================================
+-- EventPulse - Check-in por QR y rondas de vigilancia
+-- ============================================================
+-- Cada zona tiene un QR impreso con un token firmado (evento + zona). Escanearlo
+-- deja un check-in. Una ruta es una lista ordenada de zonas con el tiempo
+-- esperado entre una y otra; una ronda es una pasada de un guardia por la
+-- ruta, y cada punto queda marcado a tiempo, tarde u omitido.
+
+CREATE TABLE IF NOT EXISTS checkins (
+    id            UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    evento_id     UUID NOT NULL REFERENCES eventos(id) ON DELETE CASCADE,
+    zona_id       VARCHAR(50) NOT NULL,
+    usuario_id    UUID NOT NULL REFERENCES usuarios(id) ON DELETE CASCADE,
+    ronda_id      UUID,                            -- si contó para una ronda
+    registrado_en TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (zona_id, evento_id) REFERENCES zonas(id, evento_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_checkins_zona ON checkins(evento_id, zona_id, registrado_en DESC);
+CREATE INDEX IF NOT EXISTS idx_checkins_usuario ON checkins(usuario_id, registrado_en DESC);
+
+CREATE TABLE IF NOT EXISTS rutas_patrulla (
+    id             UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    evento_id      UUID NOT NULL REFERENCES eventos(id) ON DELETE CASCADE,
+    nombre         VARCHAR(100) NOT NULL,
+    tolerancia_min INTEGER NOT NULL DEFAULT 5 CHECK (tolerancia_min >= 0),
+    activa         BOOLEAN NOT NULL DEFAULT TRUE,
+    creada_por     UUID REFERENCES usuarios(id) ON DELETE SET NULL,
+    creada_en      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_rutas_patrulla_evento ON rutas_patrulla(evento_id);
+
+-- intervalo_min: minutos esperados desde el punto anterior (o desde el
+-- inicio de la ronda, para el primero)
+CREATE TABLE IF NOT EXISTS rutas_puntos (
+    ruta_id       UUID NOT NULL REFERENCES rutas_patrulla(id) ON DELETE CASCADE,
+    orden         INTEGER NOT NULL CHECK (orden >= 1),
+    zona_id       VARCHAR(50) NOT NULL,
+    intervalo_min INTEGER NOT NULL CHECK (intervalo_min > 0),
+    PRIMARY KEY (ruta_id, orden)
+);
+
+CREATE TABLE IF NOT EXISTS rondas (
+    id            UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    ruta_id       UUID NOT NULL REFERENCES rutas_patrulla(id) ON DELETE CASCADE,
+    evento_id     UUID NOT NULL REFERENCES eventos(id) ON DELETE CASCADE,
+    usuario_id    UUID NOT NULL REFERENCES usuarios(id) ON DELETE CASCADE,
+    estado        VARCHAR(15) NOT NULL DEFAULT 'en_curso'
+                  CHECK (estado IN ('en_curso','completada','incompleta','cancelada')),
+    siguiente     INTEGER NOT NULL DEFAULT 1,      -- orden del próximo punto
+    vence_en      TIMESTAMPTZ,                     -- límite del próximo punto (con tolerancia)
+    iniciada_en   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    terminada_en  TIMESTAMPTZ
+);
+
+-- Una ronda en curso por guardia
+CREATE UNIQUE INDEX IF NOT EXISTS idx_rondas_en_curso ON rondas(usuario_id) WHERE estado = 'en_curso';
+CREATE INDEX IF NOT EXISTS idx_rondas_vence ON rondas(vence_en) WHERE estado = 'en_curso';
+
+CREATE TABLE IF NOT EXISTS rondas_marcas (
+    ronda_id    UUID NOT NULL REFERENCES rondas(id) ON DELETE CASCADE,
+    orden       INTEGER NOT NULL,
+    zona_id     VARCHAR(50) NOT NULL,
+    estado      VARCHAR(10) NOT NULL CHECK (estado IN ('a_tiempo','tarde','omitido')),
+    esperado_en TIMESTAMPTZ NOT NULL,               -- sin tolerancia
+    marcada_en  TIMESTAMPTZ,                        -- NULL si se omitió
+    PRIMARY KEY (ronda_id, orden)
+);
+
+ALTER TABLE checkins ADD CONSTRAINT fk_checkins_ronda
+    FOREIGN KEY (ronda_id) REFERENCES rondas(id) ON DELETE SET NULL;
+
+-- Aviso de puntos omitidos; los usuarios nuevos lo reciben por defecto
+ALTER TABLE notif_preferencias ALTER COLUMN tipos SET DEFAULT '{asignacion,escalamiento,anuncio,ronda}';
@@ -0,0 +1,9 @@
+-- ============================================================
+-- EventPulse - Aforo de zonas
+-- ============================================================
+-- El conteo en vivo vive en Redis; aquí solo la configuración. Sin
+-- capacidad la zona cuenta gente pero no alerta.
+
+ALTER TABLE zonas ADD COLUMN IF NOT EXISTS capacidad INT CHECK (capacidad > 0);
+-- Porcentaje de la capacidad que dispara la alerta temprana (NULL = el global)
+ALTER TABLE zonas ADD COLUMN IF NOT EXISTS umbral_ocupacion INT CHECK (umbral_ocupacion BETWEEN 1 AND 100);
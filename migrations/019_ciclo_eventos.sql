@@ -0,0 +1,65 @@
+-- ============================================================
+-- EventPulse - Ciclo de vida de los eventos
+-- ============================================================
+-- borrador → programado → activo ⇄ pausado → terminado. Un evento nace en
+-- borrador (o programado si trae inicio) y el programador lo inicia y lo
+-- termina a la hora indicada. Un evento terminado se puede reabrir durante
+-- un plazo de gracia. Cada cambio queda en eventos_transiciones.
+
+CREATE TABLE IF NOT EXISTS eventos_transiciones (
+    id         UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    evento_id  UUID NOT NULL REFERENCES eventos(id) ON DELETE CASCADE,
+    desde      VARCHAR(20) NOT NULL,
+    hacia      VARCHAR(20) NOT NULL,
+    motivo     VARCHAR(20) NOT NULL CHECK (motivo IN ('admin','programa','migracion')),
+    usuario_id UUID REFERENCES usuarios(id) ON DELETE SET NULL,  -- NULL si fue el programador
+    creada_en  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_eventos_transiciones ON eventos_transiciones(evento_id, creada_en);
+
+-- Antes de este cambio todo evento nacía activo y muchos quedaron así. Solo el
+-- más reciente sigue en curso; el resto se da por terminado (con su
+-- transición) para que el índice único de más abajo se pueda crear.
+WITH viejos AS (
+    UPDATE eventos SET estado = 'terminado', terminado_en = COALESCE(terminado_en, NOW())
+    WHERE estado = 'activo'
+      AND id <> (SELECT id FROM eventos WHERE estado = 'activo'
+                 ORDER BY creado_en DESC, id DESC LIMIT 1)
+    RETURNING id
+)
+INSERT INTO eventos_transiciones (evento_id, desde, hacia, motivo)
+SELECT id, 'activo', 'terminado', 'migracion' FROM viejos;
+
+-- Cualquier estado fuera del ciclo nuevo se da por terminado
+UPDATE eventos SET estado = 'terminado', terminado_en = COALESCE(terminado_en, NOW())
+WHERE estado NOT IN ('borrador','programado','activo','pausado','terminado');
+
+ALTER TABLE eventos DROP CONSTRAINT IF EXISTS eventos_estado_check;
+ALTER TABLE eventos ADD CONSTRAINT eventos_estado_check
+    CHECK (estado IN ('borrador','programado','activo','pausado','terminado'));
+ALTER TABLE eventos ALTER COLUMN estado SET DEFAULT 'borrador';
+
+ALTER TABLE eventos ADD COLUMN IF NOT EXISTS inicio_programado TIMESTAMPTZ;
+ALTER TABLE eventos ADD COLUMN IF NOT EXISTS fin_programado    TIMESTAMPTZ;
+ALTER TABLE eventos ADD COLUMN IF NOT EXISTS iniciado_en       TIMESTAMPTZ;
+ALTER TABLE eventos ADD COLUMN IF NOT EXISTS pausado_en        TIMESTAMPTZ;
+
+-- Un programa invertido (si las columnas ya existían) pierde el fin
+UPDATE eventos SET fin_programado = NULL
+WHERE inicio_programado IS NOT NULL AND fin_programado <= inicio_programado;
+
+ALTER TABLE eventos DROP CONSTRAINT IF EXISTS eventos_programa_valido;
+ALTER TABLE eventos ADD CONSTRAINT eventos_programa_valido
+    CHECK (inicio_programado IS NULL OR fin_programado IS NULL OR fin_programado > inicio_programado);
+
+-- Los eventos que ya estaban activos arrancaron al crearse
+UPDATE eventos SET iniciado_en = creado_en WHERE iniciado_en IS NULL AND estado IN ('activo','terminado');
+
+CREATE INDEX IF NOT EXISTS idx_eventos_programa ON eventos(estado, inicio_programado, fin_programado);
+
+-- Un solo evento en curso (activo o pausado). El NOT EXISTS del UPDATE no
+-- alcanza con dos activaciones simultáneas (programador en dos instancias y
+-- un admin): el índice hace que la segunda falle.
+CREATE UNIQUE INDEX IF NOT EXISTS uq_eventos_en_curso ON eventos ((true))
+    WHERE estado IN ('activo','pausado');
@@ -0,0 +1,19 @@
+-- ============================================================
+-- EventPulse - Plantillas de evento
+-- ============================================================
+-- Una plantilla guarda la preparación de un evento (zonas, tareas, personal,
+-- rutas de patrulla, canales y filtros del chat) como JSON, para armar las
+-- siguientes ediciones sin empezar de cero. No depende del evento de origen:
+-- borrarlo no borra la plantilla.
+
+CREATE TABLE IF NOT EXISTS plantillas_evento (
+    id               UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    nombre           VARCHAR(255) NOT NULL,
+    descripcion      TEXT NOT NULL DEFAULT '',
+    contenido        JSONB NOT NULL,
+    origen_evento_id UUID REFERENCES eventos(id) ON DELETE SET NULL,
+    creada_por       UUID REFERENCES usuarios(id) ON DELETE SET NULL,
+    creada_en        TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_plantillas_evento_creada ON plantillas_evento(creada_en DESC);
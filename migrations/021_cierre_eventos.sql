@@ -0,0 +1,46 @@
+-- ============================================================
+-- EventPulse - Cierre de eventos
+-- ============================================================
+-- Terminar un evento ya no es un cambio de estado instantáneo: se pide un
+-- cierre con qué hacer con lo abierto (resolverlo o trasladarlo a otro
+-- evento) y con el personal (desvincularlo o desactivarlo). Un trabajo lo
+-- ejecuta en una sola transacción y guarda el reporte final. Si la instancia
+-- cae a mitad, la transacción se deshace y el cierre sigue pendiente. La
+-- revocación de sesiones va en Redis, fuera de la transacción: el cierre
+-- queda 'revocando' hasta que se logra.
+
+CREATE TABLE IF NOT EXISTS cierres_evento (
+    id             UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    evento_id      UUID NOT NULL REFERENCES eventos(id) ON DELETE CASCADE,
+    -- revocando: ya se ejecutó, faltan revocar las sesiones del personal
+    estado         VARCHAR(12) NOT NULL DEFAULT 'pendiente'
+                   CHECK (estado IN ('pendiente','revocando','completado','fallido')),
+    opciones       JSONB NOT NULL,
+    motivo         VARCHAR(10) NOT NULL CHECK (motivo IN ('admin','programa')),
+    solicitado_por UUID REFERENCES usuarios(id) ON DELETE SET NULL,
+    intentos       INTEGER NOT NULL DEFAULT 0,
+    error          TEXT,
+    personal       UUID[] NOT NULL DEFAULT '{}',   -- cuentas desvinculadas o desactivadas
+    reporte        JSONB,
+    creado_en      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    terminado_en   TIMESTAMPTZ
+);
+
+-- Un solo cierre pendiente por evento
+CREATE UNIQUE INDEX IF NOT EXISTS uq_cierres_evento_pendiente
+    ON cierres_evento(evento_id) WHERE estado = 'pendiente';
+CREATE INDEX IF NOT EXISTS idx_cierres_evento ON cierres_evento(evento_id, creado_en DESC);
+CREATE INDEX IF NOT EXISTS idx_cierres_revocando ON cierres_evento(terminado_en) WHERE estado = 'revocando';
+
+-- El historial que escribe el cierre va marcado: cuenta en los totales pero no
+-- en tiempos ni tasas (nadie atendió ni resolvió eso)
+ALTER TABLE incidencias_historial ADD COLUMN IF NOT EXISTS origen VARCHAR(10) NOT NULL DEFAULT 'operacion'
+    CHECK (origen IN ('operacion','cierre'));
+ALTER TABLE tareas_historial ADD COLUMN IF NOT EXISTS origen VARCHAR(10) NOT NULL DEFAULT 'operacion'
+    CHECK (origen IN ('operacion','cierre'));
+
+-- Lo que el cierre no resuelve se cancela: una tarea abierta no se da por
+-- completada (se saltearía la evidencia exigida y contaría como hecha)
+ALTER TABLE tareas DROP CONSTRAINT IF EXISTS tareas_estado_check;
+ALTER TABLE tareas ADD CONSTRAINT tareas_estado_check
+    CHECK (estado IN ('pendiente','en_progreso','completada','cancelada'));
@@ -0,0 +1,30 @@
+-- ============================================================
+-- EventPulse - Reportes de evento (PDF y CSV)
+-- ============================================================
+-- Un admin pide el reporte y un trabajo lo arma en segundo plano; el archivo
+-- queda acá hasta que vence la retención. Si la instancia cae mientras lo
+-- genera, pasado un rato otra lo retoma.
+
+CREATE TABLE IF NOT EXISTS reportes_evento (
+    id             UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    evento_id      UUID NOT NULL REFERENCES eventos(id) ON DELETE CASCADE,
+    formato        VARCHAR(5) NOT NULL CHECK (formato IN ('pdf','csv')),
+    estado         VARCHAR(10) NOT NULL DEFAULT 'pendiente'
+                   CHECK (estado IN ('pendiente','generando','listo','fallido')),
+    solicitado_por UUID REFERENCES usuarios(id) ON DELETE SET NULL,
+    intentos       INTEGER NOT NULL DEFAULT 0,
+    error          TEXT,
+    archivo        BYTEA,
+    nombre_archivo VARCHAR(255),
+    tamano         INTEGER,
+    creado_en      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    iniciado_en    TIMESTAMPTZ,
+    terminado_en   TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_reportes_evento ON reportes_evento(evento_id, creado_en DESC);
+CREATE INDEX IF NOT EXISTS idx_reportes_cola ON reportes_evento(creado_en) WHERE estado IN ('pendiente','generando');
+
+-- Tiempos de atención y resolución: primer cambio a cada estado
+CREATE INDEX IF NOT EXISTS idx_incidencias_historial_estado
+    ON incidencias_historial(incidencia_id, estado_nuevo, cambiado_en);
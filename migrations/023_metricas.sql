@@ -0,0 +1,18 @@
+-- ============================================================
+-- EventPulse - Métricas en vivo del tablero de operación
+-- ============================================================
+-- El tablero se recalcula cada pocos segundos: estos índices mantienen los
+-- agregados sobre lo abierto y lo reciente sin recorrer todo el evento.
+
+-- Incidencias y tareas abiertas del evento
+CREATE INDEX IF NOT EXISTS idx_incidencias_abiertas ON incidencias(evento_id, zona_id)
+    WHERE estado <> 'resuelta';
+CREATE INDEX IF NOT EXISTS idx_tareas_abiertas ON tareas(evento_id, prioridad)
+    WHERE estado NOT IN ('completada','cancelada');
+
+-- Tiempos de respuesta y zonas calientes: lo tocado o creado hace poco
+CREATE INDEX IF NOT EXISTS idx_incidencias_actualizada ON incidencias(evento_id, actualizada_en);
+CREATE INDEX IF NOT EXISTS idx_incidencias_creada ON incidencias(evento_id, creada_en);
+
+-- Personal en turno
+CREATE INDEX IF NOT EXISTS idx_turnos_abiertos_evento ON turnos(evento_id) WHERE fin IS NULL;
@@ -0,0 +1,32 @@
+-- ============================================================
+-- EventPulse - Analítica de incidencias entre eventos
+-- ============================================================
+-- Conteo de incidencias por evento, zona, tipo y hora (en UTC). Un trabajo la
+-- refresca cada tantos minutos; lo creado después del último refresco se
+-- cuenta directo de incidencias, así las consultas siempre están al día.
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS mv_incidencias_hora AS
+SELECT evento_id, zona_id, tipo,
+       date_trunc('hour', creada_en, 'UTC') AS hora,
+       COUNT(*)::int AS total
+FROM incidencias
+GROUP BY 1, 2, 3, 4;
+
+-- Requerido por REFRESH MATERIALIZED VIEW CONCURRENTLY
+CREATE UNIQUE INDEX IF NOT EXISTS idx_mv_incidencias_hora
+    ON mv_incidencias_hora(evento_id, zona_id, tipo, hora);
+CREATE INDEX IF NOT EXISTS idx_mv_incidencias_hora_hora ON mv_incidencias_hora(hora);
+
+-- Cuándo se refrescó cada vista: las consultas leen de la vista las horas
+-- anteriores y de la tabla las demás
+CREATE TABLE IF NOT EXISTS analitica_refrescos (
+    vista         VARCHAR(63) PRIMARY KEY,
+    refrescada_en TIMESTAMPTZ NOT NULL
+);
+
+INSERT INTO analitica_refrescos (vista, refrescada_en) VALUES ('mv_incidencias_hora', NOW())
+ON CONFLICT (vista) DO NOTHING;
+
+-- Lo posterior al refresco, y filtros por tipo entre eventos
+CREATE INDEX IF NOT EXISTS idx_incidencias_creada_global ON incidencias(creada_en);
+CREATE INDEX IF NOT EXISTS idx_incidencias_tipo_creada ON incidencias(tipo, creada_en);
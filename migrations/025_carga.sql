@@ -0,0 +1,25 @@
+-- ============================================================
+-- EventPulse - Carga y desempeño del personal
+-- ============================================================
+-- La vista de carga se consulta a cada rato (y la recalcula el tablero):
+-- lo abierto por persona y su última toma salen de estos índices.
+
+-- Lo abierto que tiene asignado cada uno
+CREATE INDEX IF NOT EXISTS idx_incidencias_asignada_abierta ON incidencias(asignada_a, evento_id)
+    WHERE estado <> 'resuelta';
+CREATE INDEX IF NOT EXISTS idx_tareas_asignada_abierta ON tareas(asignada_a, evento_id)
+    WHERE estado NOT IN ('completada','cancelada');
+
+-- Desempeño: todo lo asignado en el evento
+CREATE INDEX IF NOT EXISTS idx_incidencias_evento_asignada ON incidencias(evento_id, asignada_a);
+CREATE INDEX IF NOT EXISTS idx_tareas_evento_asignada ON tareas(evento_id, asignada_a);
+
+-- Última toma de cada uno
+CREATE INDEX IF NOT EXISTS idx_incidencias_historial_usuario
+    ON incidencias_historial(usuario_id, estado_nuevo, cambiado_en DESC);
+CREATE INDEX IF NOT EXISTS idx_tareas_historial_usuario
+    ON tareas_historial(usuario_id, estado_nuevo, cambiado_en DESC);
+
+-- Tiempos de tareas: primer cambio a cada estado
+CREATE INDEX IF NOT EXISTS idx_tareas_historial_estado
+    ON tareas_historial(tarea_id, estado_nuevo, cambiado_en);
@@ -0,0 +1,31 @@
+-- ============================================================
+-- EventPulse - Concurrencia optimista en zonas, webhooks, dispositivos y mensajes
+-- ============================================================
+-- Igual que eventos, incidencias y tareas (002): cada cambio sube `version`
+-- y los PATCH con If-Match solo escriben si el cliente leyó la última.
+
+ALTER TABLE zonas        ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE webhooks     ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE dispositivos ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE mensajes     ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+
+CREATE TRIGGER trg_zonas_version
+    BEFORE UPDATE ON zonas
+    FOR EACH ROW EXECUTE FUNCTION incrementar_version();
+
+CREATE TRIGGER trg_webhooks_version
+    BEFORE UPDATE ON webhooks
+    FOR EACH ROW EXECUTE FUNCTION incrementar_version();
+
+-- Cada lectura del sensor actualiza ultimo_dato_en: solo cuenta la configuración
+CREATE TRIGGER trg_dispositivos_version
+    BEFORE UPDATE ON dispositivos
+    FOR EACH ROW
+    WHEN ((OLD.nombre, OLD.zona_id, OLD.campo_zona, OLD.tema_mqtt, OLD.reglas, OLD.ventana_segundos, OLD.activo, OLD.token_hash)
+          IS DISTINCT FROM
+          (NEW.nombre, NEW.zona_id, NEW.campo_zona, NEW.tema_mqtt, NEW.reglas, NEW.ventana_segundos, NEW.activo, NEW.token_hash))
+    EXECUTE FUNCTION incrementar_version();
+
+CREATE TRIGGER trg_mensajes_version
+    BEFORE UPDATE ON mensajes
+    FOR EACH ROW EXECUTE FUNCTION incrementar_version();
@@ -0,0 +1,32 @@
+-- ============================================================
+-- EventPulse - Analítica: cambios de zona, tipo o evento tras el refresco
+-- ============================================================
+-- mv_incidencias_hora cuenta cada incidencia con la zona, el tipo y el evento
+-- que tenía al refrescarse. Si alguno cambia después (retiro de zona, traslado
+-- al cerrar un evento) o se borra la incidencia, la vista queda vieja: se
+-- marca invalidada_en y, hasta el siguiente refresco, las consultas cuentan
+-- todo directo de incidencias.
+
+ALTER TABLE analitica_refrescos ADD COLUMN IF NOT EXISTS invalidada_en TIMESTAMPTZ;
+
+CREATE OR REPLACE FUNCTION invalidar_analitica()
+RETURNS TRIGGER AS $$
+BEGIN
+    -- clock_timestamp(): la hora del cambio, no la del inicio de la transacción
+    UPDATE analitica_refrescos SET invalidada_en = clock_timestamp()
+    WHERE vista = 'mv_incidencias_hora';
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_incidencias_analitica
+    AFTER UPDATE OF zona_id, tipo, evento_id ON incidencias
+    FOR EACH ROW
+    WHEN (OLD.zona_id IS DISTINCT FROM NEW.zona_id
+       OR OLD.tipo IS DISTINCT FROM NEW.tipo
+       OR OLD.evento_id IS DISTINCT FROM NEW.evento_id)
+    EXECUTE FUNCTION invalidar_analitica();
+
+CREATE TRIGGER trg_incidencias_analitica_borrado
+    AFTER DELETE ON incidencias
+    FOR EACH STATEMENT EXECUTE FUNCTION invalidar_analitica();
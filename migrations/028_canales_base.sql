@@ -0,0 +1,21 @@
+-- ============================================================
+-- EventPulse - Canales base del chat al crear el evento
+-- ============================================================
+-- La sala general y los canales por rol se crean junto con el evento; antes
+-- se aseguraban en cada listado de conversaciones. Acá se completan los de
+-- los eventos que ya existían.
+
+INSERT INTO conversaciones (evento_id, tipo, nombre)
+SELECT id, 'evento', 'General' FROM eventos
+ON CONFLICT DO NOTHING;
+
+INSERT INTO conversaciones (evento_id, tipo, nombre, rol)
+SELECT e.id, 'rol', c.nombre, c.rol
+FROM eventos e, (VALUES
+    ('🧹 Aseo',       'aseo'),
+    ('🛡️ Guardia',    'guardia'),
+    ('🏥 Médico',     'medico'),
+    ('📦 Logística',  'logistica'),
+    ('⭐ Supervisor', 'supervisor')
+) AS c(nombre, rol)
+ON CONFLICT DO NOTHING;